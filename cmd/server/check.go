@@ -0,0 +1,43 @@
+package main
+
+import (
+	"fmt"
+	"io"
+
+	"mynewmangaui/internal/config"
+	scansvc "mynewmangaui/internal/scan"
+)
+
+// checkConfig reports on a loaded config for -check-config without touching
+// the filesystem or database: the paths the server would create and every
+// problem that would keep it from starting or scanning. It returns the exit
+// code, non-zero when there are problems.
+func checkConfig(w io.Writer, source string, cfg config.Config, loadErr error) int {
+	if loadErr != nil {
+		fmt.Fprintf(w, "config %s is invalid:\n  %v\n", source, loadErr)
+		return 1
+	}
+
+	report := config.CheckPaths(cfg)
+	for _, shelf := range cfg.Storage.Bookshelves {
+		if shelf.TitlePattern == "" {
+			continue
+		}
+		if _, err := scansvc.CompileTitlePattern(shelf.TitlePattern); err != nil {
+			report.Problems = append(report.Problems, fmt.Sprintf("bookshelf %q title pattern: %v", shelf.Name, err))
+		}
+	}
+
+	for _, path := range report.WouldCreate {
+		fmt.Fprintf(w, "would create %s\n", path)
+	}
+	if !report.OK() {
+		fmt.Fprintf(w, "config %s has %d problem(s):\n", source, len(report.Problems))
+		for _, problem := range report.Problems {
+			fmt.Fprintf(w, "  %s\n", problem)
+		}
+		return 1
+	}
+	fmt.Fprintf(w, "config %s is valid, bookshelves: %d\n", source, len(cfg.Storage.Bookshelves))
+	return 0
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"mynewmangaui/internal/config"
+)
+
+func TestCheckConfig(t *testing.T) {
+	tests := []struct {
+		name string
+		// edit adjusts the config document, whose paths all sit under dir.
+		edit     func(t *testing.T, dir string, document map[string]any)
+		raw      string
+		wantCode int
+		want     []string
+	}{
+		{
+			name:     "valid",
+			wantCode: 0,
+			want:     []string{"would create {dir}/cache/thumbs", "is valid, bookshelves: 1"},
+		},
+		{
+			name:     "unparsable",
+			raw:      `{"storage":`,
+			wantCode: 1,
+			want:     []string{"is invalid:"},
+		},
+		{
+			name: "fails validation",
+			edit: func(t *testing.T, dir string, document map[string]any) {
+				document["storage"].(map[string]any)["maxQueuedScans"] = 0
+			},
+			wantCode: 1,
+			want:     []string{"is invalid:", "storage.maxQueuedScans must be positive"},
+		},
+		{
+			name: "missing bookshelf root",
+			edit: func(t *testing.T, dir string, document map[string]any) {
+				document["storage"].(map[string]any)["bookshelves"] = []any{map[string]any{"name": "main", "path": filepath.Join(dir, "gone")}}
+			},
+			wantCode: 1,
+			want:     []string{"has 1 problem(s):", `storage.bookshelves[0] "main" root "{dir}/gone"`},
+		},
+		{
+			name: "paths blocked by files",
+			edit: func(t *testing.T, dir string, document map[string]any) {
+				writeFile(t, filepath.Join(dir, "blocker"))
+				document["storage"].(map[string]any)["cachePath"] = filepath.Join(dir, "blocker", "thumbs")
+				document["database"] = map[string]any{"path": filepath.Join(dir, "blocker", "app.db")}
+			},
+			wantCode: 1,
+			want: []string{
+				"has 2 problem(s):",
+				`database.path directory "{dir}/blocker" is not a directory`,
+				`storage.cachePath "{dir}/blocker/thumbs": stat {dir}/blocker/thumbs: not a directory`,
+			},
+		},
+		{
+			name: "bad title pattern",
+			edit: func(t *testing.T, dir string, document map[string]any) {
+				document["storage"].(map[string]any)["bookshelves"] = []any{map[string]any{"name": "main", "path": filepath.Join(dir, "library"), "titlePattern": "{author} - {name}"}}
+			},
+			wantCode: 1,
+			want:     []string{`bookshelf "main" title pattern:`},
+		},
+		{
+			name: "missing tls files",
+			edit: func(t *testing.T, dir string, document map[string]any) {
+				document["server"] = map[string]any{"tls": map[string]any{"certFile": filepath.Join(dir, "cert.pem"), "keyFile": filepath.Join(dir, "key.pem")}}
+			},
+			wantCode: 1,
+			want:     []string{"has 2 problem(s):", `server.tls.certFile "{dir}/cert.pem"`, `server.tls.keyFile "{dir}/key.pem"`},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			if err := os.MkdirAll(filepath.Join(dir, "library"), 0o755); err != nil {
+				t.Fatal(err)
+			}
+			document := map[string]any{
+				"database": map[string]any{"path": filepath.Join(dir, "app.db")},
+				"storage": map[string]any{
+					"bookshelves": []any{map[string]any{"name": "main", "path": filepath.Join(dir, "library")}},
+					"cachePath":   filepath.Join(dir, "cache", "thumbs"),
+					"trashPath":   filepath.Join(dir, "trash"),
+				},
+				"online": map[string]any{"cachePath": dir, "downloadsPath": dir},
+			}
+			if tt.edit != nil {
+				tt.edit(t, dir, document)
+			}
+			raw := []byte(tt.raw)
+			if tt.raw == "" {
+				var err error
+				if raw, err = json.Marshal(document); err != nil {
+					t.Fatal(err)
+				}
+			}
+			path := filepath.Join(dir, "config.json")
+			if err := os.WriteFile(path, raw, 0o644); err != nil {
+				t.Fatal(err)
+			}
+
+			cfg, err := config.Load(path)
+			var out strings.Builder
+			code := checkConfig(&out, path, cfg, err)
+			if code != tt.wantCode {
+				t.Errorf("exit code = %d, want %d; report:\n%s", code, tt.wantCode, out.String())
+			}
+			for _, want := range tt.want {
+				if want = strings.ReplaceAll(want, "{dir}", dir); !strings.Contains(out.String(), want) {
+					t.Errorf("report is missing %q:\n%s", want, out.String())
+				}
+			}
+			// Checking never creates what it reports.
+			if _, err := os.Stat(filepath.Join(dir, "cache")); !os.IsNotExist(err) {
+				t.Errorf("cache directory after check: %v, want it left uncreated", err)
+			}
+		})
+	}
+}
+
+func writeFile(t *testing.T, path string) {
+	t.Helper()
+	if err := os.WriteFile(path, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
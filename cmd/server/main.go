@@ -7,11 +7,14 @@ import (
 	"flag"
 	"fmt"
 	"log/slog"
+	"net"
 	"net/http"
 	"os"
+	"os/exec"
 	"os/signal"
 	"path/filepath"
 	"strings"
+	"sync/atomic"
 	"syscall"
 	"time"
 
@@ -20,15 +23,41 @@ import (
 	"mynewmangaui/internal/db"
 	downloadsvc "mynewmangaui/internal/download"
 	imagesvc "mynewmangaui/internal/image"
+	"mynewmangaui/internal/media"
+	"mynewmangaui/internal/metrics"
 	onlinesvc "mynewmangaui/internal/online"
 	scansvc "mynewmangaui/internal/scan"
+	"mynewmangaui/internal/settings"
 )
 
 func main() {
-	cfgPath := flag.String("config", "config.json", "Path to JSON config file")
-	flag.Parse()
+	cfgPath := flag.String("config", "config.json", "Path to JSON config file, or a directory of fragments")
+	cfgDir := flag.String("config-dir", "", "Directory of *.json fragments merged over the config file in lexical order")
+	resume := flag.Bool("resume", false, "Resume an interrupted library scan on startup")
+	migrateOnly := flag.Bool("migrate", false, "Apply pending database migrations and exit")
+	checkOnly := flag.Bool("check-config", false, "Validate the config and its paths without changing anything, report problems and exit")
+	// "server migrate [flags]" is the same as "server -migrate [flags]".
+	args := os.Args[1:]
+	if len(args) > 0 && args[0] == "migrate" {
+		*migrateOnly = true
+		args = args[1:]
+	}
+	flag.CommandLine.Parse(args)
 
-	cfg, err := config.Load(*cfgPath)
+	var cfg config.Config
+	var err error
+	if *cfgDir != "" {
+		cfg, err = config.LoadWithFragments(*cfgPath, *cfgDir)
+	} else {
+		cfg, err = config.Load(*cfgPath)
+	}
+	if *checkOnly {
+		source := *cfgPath
+		if *cfgDir != "" {
+			source += " + " + *cfgDir
+		}
+		os.Exit(checkConfig(os.Stdout, source, cfg, err))
+	}
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "failed to load config: %v\n", err)
 		os.Exit(1)
@@ -40,12 +69,15 @@ func main() {
 	}
 
 	logger := newLogger(cfg.LogLevel)
+	if *migrateOnly {
+		os.Exit(runMigrate(context.Background(), os.Stdout, cfg.Database, logger))
+	}
 	logger.Info("starting server", "addr", cfg.Server.Address)
 
 	rootCtx, cancelBackground := context.WithCancel(context.Background())
 	defer cancelBackground()
 
-	database, err := db.OpenAndMigrate(rootCtx, cfg.Database.Path, logger)
+	database, err := openDatabase(rootCtx, cfg.Database, logger)
 	if err != nil {
 		logger.Error("database initialization failed", "error", err)
 		os.Exit(1)
@@ -54,9 +86,17 @@ func main() {
 
 	bookshelves := make([]scansvc.Bookshelf, 0, len(cfg.Storage.Bookshelves))
 	for _, shelf := range cfg.Storage.Bookshelves {
+		if shelf.TitlePattern != "" {
+			if _, err := scansvc.CompileTitlePattern(shelf.TitlePattern); err != nil {
+				logger.Error("invalid bookshelf title pattern", "bookshelf", shelf.Name, "error", err)
+				os.Exit(1)
+			}
+		}
 		bookshelves = append(bookshelves, scansvc.Bookshelf{
-			Name: shelf.Name,
-			Path: shelf.Path,
+			Name:            shelf.Name,
+			Path:            shelf.Path,
+			SkipStartupScan: !shelf.StartupScan(),
+			TitlePattern:    shelf.TitlePattern,
 		})
 	}
 	if cfg.Online.Enabled && cfg.Online.DownloadsPath != "" {
@@ -98,8 +138,40 @@ func main() {
 		}
 	}
 
-	scanner := scansvc.NewService(database, bookshelves, logger)
+	if cfg.Storage.EnablePDF {
+		if _, err := exec.LookPath(cfg.Storage.PDFRenderer); err != nil {
+			logger.Warn("pdf renderer not found, pdf pages will fail to display", "renderer", cfg.Storage.PDFRenderer, "error", err)
+		}
+		media.ConfigurePDF(filepath.Join(cfg.Storage.CachePath, "pdf"), cfg.Storage.PDFRenderer)
+	}
+
+	if _, err := scansvc.CompilePageFilter(cfg.Storage.ExcludePagePatterns); err != nil {
+		logger.Error("invalid storage.excludePagePatterns", "error", err)
+		os.Exit(1)
+	}
+
+	scanner := scansvc.NewService(database, bookshelves, scansvc.Options{
+		SniffMime:               cfg.Storage.SniffMime,
+		MaxChapterNumber:        cfg.Storage.MaxChapterNumber,
+		EnablePDF:               cfg.Storage.EnablePDF,
+		IndexCoverOnly:          cfg.Storage.IndexCoverOnly,
+		IncompleteChapterWindow: time.Duration(cfg.Storage.IncompleteChapterWindowSeconds) * time.Second,
+		DeferIncompleteChapters: cfg.Storage.DeferIncompleteChapters,
+		PreserveFilenameNumbers: cfg.Storage.PreserveFilenameNumbers,
+		ArchiveRoots:            cfg.Storage.ArchiveRoots,
+		TitleSource:             cfg.Storage.TitleSource,
+		SkipHiddenFiles:         cfg.Storage.SkipHiddenFiles,
+		CaseInsensitivePaths:    cfg.Storage.CaseInsensitivePaths,
+		MaxQueuedScans:          cfg.Storage.MaxQueuedScans,
+		ChecksumMode:            cfg.Storage.ChecksumMode,
+		ExcludePagePatterns:     cfg.Storage.ExcludePagePatterns,
+	}, logger)
+	go scanner.RunChecksumBackfill(rootCtx)
+	go scanner.RunPeriodicScans(rootCtx, scanInterval(settings.New(database), logger))
 	images := imagesvc.NewService(database, cfg.Storage.CachePath, logger)
+	images.ConfigurePageFormats(availablePageFormats(cfg.Storage, logger), cfg.Storage.PageEncoders)
+	images.ConfigureThumbnails(thumbnailOptions(cfg.Storage, logger))
+	images.ConfigureCacheMinFree(cfg.Storage.CacheMinFreeBytes)
 	online, err := onlinesvc.NewDefaultService(cfg.Online)
 	if err != nil {
 		logger.Error("online service initialization failed", "error", err)
@@ -111,6 +183,8 @@ func main() {
 	routerConfig := cfg
 	routerConfig.Storage.Bookshelves = bookshelfConfigs(bookshelves)
 
+	db.RegisterMetrics(metrics.Default, database)
+
 	handler := api.NewRouter(api.Dependencies{
 		Logger:      logger,
 		Config:      routerConfig,
@@ -120,6 +194,7 @@ func main() {
 		Online:      online,
 		OnlineCache: onlineCache,
 		Downloads:   downloads,
+		Context:     rootCtx,
 	})
 
 	httpServer := &http.Server{
@@ -127,27 +202,74 @@ func main() {
 		Handler:           handler,
 		ReadHeaderTimeout: 5 * time.Second,
 	}
+	// openConns counts the connections a shutdown would have to wait for.
+	var openConns atomic.Int64
+	httpServer.ConnState = func(_ net.Conn, state http.ConnState) {
+		switch state {
+		case http.StateNew:
+			openConns.Add(1)
+		case http.StateHijacked, http.StateClosed:
+			openConns.Add(-1)
+		}
+	}
+
+	tlsEnabled := cfg.Server.TLS.Enabled()
+	if tlsEnabled {
+		certs, err := newCertReloader(cfg.Server.TLS, logger)
+		if err != nil {
+			logger.Error("tls initialization failed", "error", err)
+			os.Exit(1)
+		}
+		httpServer.TLSConfig = certs.tlsConfig()
+		go certs.watchSignals(rootCtx.Done())
+	}
 
 	errCh := make(chan error, 1)
 	go func() {
-		logger.Info("http server listening", "addr", cfg.Server.Address)
+		logger.Info("http server listening", "addr", cfg.Server.Address, "tls", tlsEnabled)
+		if tlsEnabled {
+			// The certificate comes from TLSConfig.GetCertificate; HTTP/2 is
+			// negotiated automatically over TLS.
+			errCh <- httpServer.ListenAndServeTLS("", "")
+			return
+		}
 		errCh <- httpServer.ListenAndServe()
 	}()
 
 	go func() {
+		if !cfg.Storage.StartupScan() {
+			logger.Info("initial library scan skipped", "reason", "disabled by storage.scanOnStartup")
+			return
+		}
+		resumeScan := false
 		needsScan, err := needsInitialLibraryScan(rootCtx, database)
 		if err != nil {
 			logger.Warn("failed to inspect library cache before initial scan", "error", err)
 		}
+		if *resume {
+			pending, err := scanner.HasPendingScan(rootCtx)
+			if err != nil {
+				logger.Warn("failed to inspect interrupted library scan", "error", err)
+			}
+			if pending {
+				needsScan = true
+				resumeScan = true
+				logger.Info("resuming interrupted library scan")
+			}
+		}
 		if !needsScan {
 			logger.Info("initial library scan skipped", "reason", "library cache already exists")
 			return
 		}
 
 		logger.Info("initial library scan started in background")
-		summary, err := scanner.Scan(rootCtx)
+		summary, err := scanner.ScanStartup(rootCtx, resumeScan)
 		if err != nil {
-			if rootCtx.Err() != nil {
+			if errors.Is(err, scansvc.ErrPaused) {
+				logger.Info("initial library scan skipped", "reason", "maintenance mode")
+				return
+			}
+			if rootCtx.Err() != nil || errors.Is(err, scansvc.ErrStopped) {
 				logger.Info("initial library scan cancelled")
 				return
 			}
@@ -176,18 +298,126 @@ func main() {
 		}
 	}
 
-	shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	// In-flight requests drain while a running scan gets to commit the
+	// manga it is on rather than throwing the work away, both within the
+	// shutdown timeout. Then everything in the background is cancelled.
+	shutdownTimeout := time.Duration(cfg.Server.ShutdownTimeoutSeconds) * time.Second
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
 	defer cancel()
+	drained := make(chan error, 1)
+	go func() {
+		drained <- httpServer.Shutdown(shutdownCtx)
+	}()
+
+	scanCtx, cancelScan := context.WithTimeout(shutdownCtx, time.Duration(cfg.Server.ScanShutdownGraceSeconds)*time.Second)
+	if err := scanner.Stop(scanCtx); err != nil {
+		logger.Warn("library scan still running after shutdown grace, cancelling it")
+	}
+	cancelScan()
 	cancelBackground()
 
-	if err := httpServer.Shutdown(shutdownCtx); err != nil {
-		logger.Error("graceful shutdown failed", "error", err)
+	if err := <-drained; err != nil {
+		if errors.Is(err, context.DeadlineExceeded) {
+			logger.Warn("shutdown timeout elapsed, closing remaining connections", "timeout_seconds", cfg.Server.ShutdownTimeoutSeconds, "connections", openConns.Load())
+			httpServer.Close()
+		} else {
+			logger.Error("graceful shutdown failed", "error", err)
+		}
 		os.Exit(1)
 	}
 
 	logger.Info("server shutdown complete")
 }
 
+// openDatabase migrates the database at startup unless auto-migration is
+// turned off, in which case pending migrations stop the server so schema
+// changes only happen through the -migrate step. It then applies the
+// connection lifetimes and starts the periodic WAL checkpoints, which stop
+// with ctx.
+func openDatabase(ctx context.Context, cfg config.DatabaseConfig, logger *slog.Logger) (*sql.DB, error) {
+	pragmas, err := db.EffectivePragmas(cfg.Pragmas)
+	if err != nil {
+		return nil, err
+	}
+	logger.Info("database pragmas", "pragmas", pragmas)
+
+	var queryLog *db.QueryLog
+	if cfg.LogQueries {
+		queryLog = &db.QueryLog{
+			Logger:        logger,
+			SlowThreshold: time.Duration(cfg.SlowQueryThresholdMs) * time.Millisecond,
+		}
+		logger.Info("logging sql query timings", "slow_threshold_ms", cfg.SlowQueryThresholdMs)
+	}
+
+	var database *sql.DB
+	if cfg.AutoMigrate {
+		database, err = db.OpenAndMigrate(ctx, cfg.Path, cfg.Pragmas, queryLog, logger)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		database, err = db.OpenMigrated(ctx, cfg.Path, cfg.Pragmas, queryLog)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	database.SetConnMaxLifetime(time.Duration(cfg.ConnMaxLifetimeSeconds) * time.Second)
+	database.SetConnMaxIdleTime(time.Duration(cfg.ConnMaxIdleSeconds) * time.Second)
+	if cfg.CheckpointIntervalSeconds > 0 {
+		go db.RunCheckpoints(ctx, database, time.Duration(cfg.CheckpointIntervalSeconds)*time.Second, logger)
+	}
+	return database, nil
+}
+
+// availablePageFormats drops page formats whose encoder is not installed, so
+// pages are served unconverted instead of failing to transcode every time.
+func availablePageFormats(cfg config.StorageConfig, logger *slog.Logger) []string {
+	formats := make([]string, 0, len(cfg.PageFormats))
+	for _, format := range cfg.PageFormats {
+		encoder := imagesvc.PageEncoder(format, cfg.PageEncoders)
+		if _, err := exec.LookPath(encoder); err != nil {
+			logger.Warn("page encoder not found, pages will not be converted to this format", "format", format, "encoder", encoder, "error", err)
+			continue
+		}
+		formats = append(formats, format)
+	}
+	return formats
+}
+
+// scanInterval reads the periodic scan interval from the settings. A value
+// that cannot be read is logged and treated as off.
+func scanInterval(store *settings.Store, logger *slog.Logger) func(context.Context) time.Duration {
+	return func(ctx context.Context) time.Duration {
+		minutes, err := settings.ScanIntervalMinutes.Get(ctx, store)
+		if err != nil {
+			logger.Warn("failed to read scan interval", "error", err)
+			return 0
+		}
+		return time.Duration(minutes) * time.Minute
+	}
+}
+
+// thumbnailOptions falls back to JPEG thumbnails when WebP is configured
+// but its encoder is not installed.
+func thumbnailOptions(cfg config.StorageConfig, logger *slog.Logger) imagesvc.ThumbnailOptions {
+	options := imagesvc.ThumbnailOptions{
+		Format:       cfg.Thumbnail.Format,
+		Quality:      cfg.Thumbnail.Quality,
+		MaxDimension: cfg.Thumbnail.MaxDimension,
+		ChapterPage:  cfg.Thumbnail.ChapterPage,
+	}
+	if options.Format == "webp" {
+		options.Encoder = imagesvc.PageEncoder("webp", cfg.PageEncoders)
+		if _, err := exec.LookPath(options.Encoder); err != nil {
+			logger.Warn("webp encoder not found, thumbnails will be jpeg", "encoder", options.Encoder, "error", err)
+			options.Format = "jpeg"
+		}
+	}
+	return options
+}
+
 func needsInitialLibraryScan(ctx context.Context, db *sql.DB) (bool, error) {
 	var count int
 	if err := db.QueryRowContext(ctx, `SELECT COUNT(*) FROM bookshelf`).Scan(&count); err != nil {
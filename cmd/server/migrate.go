@@ -0,0 +1,57 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+
+	"mynewmangaui/internal/config"
+	"mynewmangaui/internal/db"
+)
+
+// runMigrate applies pending migrations for the migrate subcommand and
+// -migrate, without starting the server, then prints the migrations that
+// ran and every version now applied. It returns the exit code, non-zero
+// when the database cannot be opened or a migration fails.
+func runMigrate(ctx context.Context, w io.Writer, cfg config.DatabaseConfig, logger *slog.Logger) int {
+	database, err := db.Open(ctx, cfg.Path, cfg.Pragmas, nil)
+	if err != nil {
+		logger.Error("database open failed", "path", cfg.Path, "error", err)
+		return 1
+	}
+	pending, err := db.PendingMigrations(ctx, database)
+	database.Close()
+	if err != nil {
+		logger.Error("failed to inspect pending migrations", "path", cfg.Path, "error", err)
+		return 1
+	}
+	logger.Info("running database migrations", "path", cfg.Path, "pending", len(pending))
+
+	database, err = db.OpenAndMigrate(ctx, cfg.Path, cfg.Pragmas, nil, logger)
+	if err != nil {
+		logger.Error("database migration failed", "path", cfg.Path, "error", err)
+		return 1
+	}
+	defer database.Close()
+	applied, err := db.AppliedMigrations(ctx, database)
+	if err != nil {
+		logger.Error("failed to list applied migrations", "path", cfg.Path, "error", err)
+		return 1
+	}
+	logger.Info("database migrations applied", "path", cfg.Path, "ran", len(pending), "total", len(applied))
+
+	if len(pending) == 0 {
+		fmt.Fprintln(w, "no pending migrations")
+	} else {
+		fmt.Fprintf(w, "ran %d migration(s):\n", len(pending))
+		for _, version := range pending {
+			fmt.Fprintf(w, "  %s\n", version)
+		}
+	}
+	fmt.Fprintf(w, "applied versions (%d):\n", len(applied))
+	for _, migration := range applied {
+		fmt.Fprintf(w, "  %s  %s\n", migration.Version, migration.AppliedAt)
+	}
+	return 0
+}
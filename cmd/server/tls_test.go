@@ -0,0 +1,161 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io"
+	"log/slog"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"mynewmangaui/internal/config"
+)
+
+// writeTestCert writes a self-signed certificate for 127.0.0.1 and its key
+// into dir and returns the parsed certificate.
+func writeTestCert(t *testing.T, dir string, serial int64) (*x509.Certificate, config.TLSConfig) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(serial),
+		Subject:               pkix.Name{CommonName: "mynewmangaui test"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IPAddresses:           []net.IP{net.IPv4(127, 0, 0, 1)},
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := config.TLSConfig{CertFile: filepath.Join(dir, "cert.pem"), KeyFile: filepath.Join(dir, "key.pem")}
+	if err := os.WriteFile(cfg.CertFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(cfg.KeyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return cert, cfg
+}
+
+// serveTLS serves handler over TLS as main does, with the certificate from
+// certs, and returns the server's address.
+func serveTLS(t *testing.T, certs *certReloader, handler http.Handler) string {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := &http.Server{Handler: handler, TLSConfig: certs.tlsConfig()}
+	go server.ServeTLS(listener, "", "")
+	t.Cleanup(func() { server.Close() })
+	return listener.Addr().String()
+}
+
+// tlsClient trusts only cert and negotiates HTTP/2 when the server offers
+// it.
+func tlsClient(cert *x509.Certificate) *http.Client {
+	roots := x509.NewCertPool()
+	roots.AddCert(cert)
+	return &http.Client{Transport: &http.Transport{
+		TLSClientConfig:   &tls.Config{RootCAs: roots},
+		ForceAttemptHTTP2: true,
+	}}
+}
+
+func TestServerServesOverTLS(t *testing.T) {
+	cert, cfg := writeTestCert(t, t.TempDir(), 1)
+	certs, err := newCertReloader(cfg, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	if err != nil {
+		t.Fatalf("load certificate: %v", err)
+	}
+	addr := serveTLS(t, certs, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "ok")
+	}))
+
+	resp, err := tlsClient(cert).Get("https://" + addr + "/")
+	if err != nil {
+		t.Fatalf("https request: %v", err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK || string(body) != "ok" {
+		t.Fatalf("response = %d %q", resp.StatusCode, body)
+	}
+	if resp.ProtoMajor != 2 {
+		t.Errorf("protocol = %s, want HTTP/2", resp.Proto)
+	}
+
+	// The listener only speaks TLS; Go answers plaintext with a 400.
+	if plain, err := http.Get("http://" + addr + "/"); err == nil {
+		plain.Body.Close()
+		if plain.StatusCode != http.StatusBadRequest {
+			t.Errorf("plaintext request status = %d, want 400", plain.StatusCode)
+		}
+	}
+}
+
+func TestCertReloaderReload(t *testing.T) {
+	dir := t.TempDir()
+	_, cfg := writeTestCert(t, dir, 1)
+	certs, err := newCertReloader(cfg, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	if err != nil {
+		t.Fatalf("load certificate: %v", err)
+	}
+	addr := serveTLS(t, certs, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	// A renewal replaces both files; connections made after the reload
+	// get the new certificate.
+	renewed, _ := writeTestCert(t, dir, 2)
+	if err := certs.reload(); err != nil {
+		t.Fatalf("reload: %v", err)
+	}
+	resp, err := tlsClient(renewed).Get("https://" + addr + "/")
+	if err != nil {
+		t.Fatalf("request after reload: %v", err)
+	}
+	resp.Body.Close()
+	if serial := resp.TLS.PeerCertificates[0].SerialNumber.Int64(); serial != 2 {
+		t.Errorf("served certificate serial = %d, want 2", serial)
+	}
+
+	// A broken renewal keeps the previous certificate.
+	if err := os.WriteFile(cfg.KeyFile, []byte("garbage"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := certs.reload(); err == nil {
+		t.Fatal("reload of a broken key pair returned no error")
+	}
+	current, err := certs.getCertificate(nil)
+	if err != nil || current == nil {
+		t.Fatalf("certificate after failed reload = %v, %v", current, err)
+	}
+	if leaf, err := x509.ParseCertificate(current.Certificate[0]); err != nil || leaf.SerialNumber.Int64() != 2 {
+		t.Errorf("certificate after failed reload = %v, %v; want serial 2", leaf, err)
+	}
+}
@@ -5,6 +5,7 @@ go 1.25.0
 require github.com/go-chi/chi/v5 v5.1.0
 
 require (
+	github.com/google/uuid v1.6.0
 	github.com/nwaples/rardecode/v2 v2.2.2
 	golang.org/x/image v0.39.0
 	modernc.org/sqlite v1.30.1
@@ -12,7 +13,6 @@ require (
 
 require (
 	github.com/dustin/go-humanize v1.0.1 // indirect
-	github.com/google/uuid v1.6.0 // indirect
 	github.com/hashicorp/golang-lru/v2 v2.0.7 // indirect
 	github.com/mattn/go-isatty v0.0.20 // indirect
 	github.com/ncruces/go-strftime v0.1.9 // indirect
@@ -0,0 +1,584 @@
+package api
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"mynewmangaui/internal/clock"
+)
+
+const (
+	backupFormatVersion  = 1
+	maxBackupImportBytes = 32 << 20
+)
+
+// libraryBackup is the portable export document. It carries only what the
+// user set by hand, keyed by the stable manga and chapter ids, so it can be
+// merged into a freshly scanned library on another machine.
+type libraryBackup struct {
+	Version         int                    `json:"version"`
+	ExportedAt      string                 `json:"exportedAt"`
+	Tags            []backupTag            `json:"tags"`
+	Manga           []backupManga          `json:"manga"`
+	Chapters        []backupChapter        `json:"chapters"`
+	OnlineBookmarks []backupOnlineBookmark `json:"onlineBookmarks"`
+}
+
+type backupTag struct {
+	ID       string `json:"id"`
+	Name     string `json:"name"`
+	Slug     string `json:"slug"`
+	Color    string `json:"color"`
+	Group    string `json:"group"`
+	Priority int    `json:"priority"`
+	Order    int    `json:"order"`
+	Pinned   bool   `json:"pinned"`
+}
+
+type backupManga struct {
+	ID         string   `json:"id"`
+	Title      string   `json:"title"`
+	Favorite   bool     `json:"favorite"`
+	SortName   string   `json:"sortName,omitempty"`
+	Collection string   `json:"collection,omitempty"`
+	TagIDs     []string `json:"tagIds"`
+}
+
+type backupChapter struct {
+	ID        string          `json:"id"`
+	MangaID   string          `json:"mangaId"`
+	Title     string          `json:"title"`
+	Volume    *int            `json:"volume,omitempty"`
+	VolumeSet bool            `json:"volumeLocked"`
+	PageOrder []string        `json:"pageOrder,omitempty"`
+	Progress  *backupProgress `json:"progress,omitempty"`
+}
+
+type backupProgress struct {
+	PageIndex int    `json:"pageIndex"`
+	Completed bool   `json:"completed"`
+	UpdatedAt string `json:"updatedAt"`
+}
+
+type backupOnlineBookmark struct {
+	SourceID              string `json:"sourceId"`
+	MangaID               string `json:"mangaId"`
+	FavoriteAt            string `json:"favoriteAt,omitempty"`
+	FollowedAt            string `json:"followedAt,omitempty"`
+	LastKnownChapterCount int    `json:"lastKnownChapterCount"`
+	LatestChapterID       string `json:"latestChapterId"`
+}
+
+type backupImportResponse struct {
+	Tags             int      `json:"tags"`
+	Manga            int      `json:"manga"`
+	Chapters         int      `json:"chapters"`
+	OnlineBookmarks  int      `json:"onlineBookmarks"`
+	SkippedManga     []string `json:"skippedManga"`
+	SkippedChapters  []string `json:"skippedChapters"`
+	SkippedBookmarks []string `json:"skippedBookmarks"`
+}
+
+type backupHandler struct {
+	db     *sql.DB
+	counts *LibraryCountCache
+	clock  clock.Clock
+}
+
+func newBackupHandler(db *sql.DB, counts *LibraryCountCache, clock clock.Clock) *backupHandler {
+	return &backupHandler{db: db, counts: counts, clock: clock}
+}
+
+// exportLibrary writes favorites, tags, sort names and collections, volume
+// and page order overrides, reading progress and online bookmarks as one
+// JSON document.
+func (h *backupHandler) exportLibrary(w http.ResponseWriter, r *http.Request) {
+	if h.db == nil {
+		writeError(w, http.StatusInternalServerError, "database not initialized")
+		return
+	}
+
+	backup := libraryBackup{
+		Version:    backupFormatVersion,
+		ExportedAt: h.clock.Now().UTC().Format(time.RFC3339),
+	}
+	var err error
+	if backup.Tags, err = exportTags(r.Context(), h.db); err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to export tags")
+		return
+	}
+	if backup.Manga, err = exportManga(r.Context(), h.db); err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to export manga")
+		return
+	}
+	if backup.Chapters, err = exportChapters(r.Context(), h.db); err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to export chapters")
+		return
+	}
+	if backup.OnlineBookmarks, err = exportOnlineBookmarks(r.Context(), h.db); err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to export online bookmarks")
+		return
+	}
+
+	w.Header().Set("Content-Disposition", `attachment; filename="library-backup.json"`)
+	writeJSON(w, http.StatusOK, backup)
+}
+
+// importLibrary merges an export into the current library. Favorites and tag
+// assignments are added, never removed; progress only replaces entries that
+// are older than the imported one. Ids the library does not know are skipped
+// and reported back.
+func (h *backupHandler) importLibrary(w http.ResponseWriter, r *http.Request) {
+	if h.db == nil {
+		writeError(w, http.StatusInternalServerError, "database not initialized")
+		return
+	}
+
+	var backup libraryBackup
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBackupImportBytes)).Decode(&backup); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid backup payload")
+		return
+	}
+	if backup.Version != backupFormatVersion {
+		writeError(w, http.StatusBadRequest, "unsupported backup version")
+		return
+	}
+
+	tx, err := h.db.BeginTx(r.Context(), nil)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to start transaction")
+		return
+	}
+	defer tx.Rollback()
+
+	response := backupImportResponse{
+		SkippedManga:     make([]string, 0),
+		SkippedChapters:  make([]string, 0),
+		SkippedBookmarks: make([]string, 0),
+	}
+
+	tagIDs, err := importTags(r.Context(), tx, backup.Tags)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to import tags")
+		return
+	}
+	response.Tags = len(backup.Tags)
+
+	for _, item := range backup.Manga {
+		ok, err := importManga(r.Context(), tx, item, tagIDs)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, "failed to import manga")
+			return
+		}
+		if !ok {
+			response.SkippedManga = append(response.SkippedManga, item.ID)
+			continue
+		}
+		response.Manga++
+	}
+
+	for _, item := range backup.Chapters {
+		ok, err := importChapter(r.Context(), tx, item, h.clock.Now())
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, "failed to import chapters")
+			return
+		}
+		if !ok {
+			response.SkippedChapters = append(response.SkippedChapters, item.ID)
+			continue
+		}
+		response.Chapters++
+	}
+
+	for _, item := range backup.OnlineBookmarks {
+		ok, err := importOnlineBookmark(r.Context(), tx, item)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, "failed to import online bookmarks")
+			return
+		}
+		if !ok {
+			response.SkippedBookmarks = append(response.SkippedBookmarks, item.SourceID+"/"+item.MangaID)
+			continue
+		}
+		response.OnlineBookmarks++
+	}
+
+	if err := tx.Commit(); err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to commit import")
+		return
+	}
+	h.counts.Invalidate()
+
+	writeJSON(w, http.StatusOK, response)
+}
+
+func exportTags(ctx context.Context, db *sql.DB) ([]backupTag, error) {
+	rows, err := db.QueryContext(ctx, `
+		SELECT id, name, slug, color, group_name, priority, sort_order, is_pinned
+		FROM tag
+		ORDER BY sort_order ASC, id ASC
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := make([]backupTag, 0)
+	for rows.Next() {
+		var item backupTag
+		if err := rows.Scan(&item.ID, &item.Name, &item.Slug, &item.Color, &item.Group, &item.Priority, &item.Order, &item.Pinned); err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+	return items, rows.Err()
+}
+
+func exportManga(ctx context.Context, db *sql.DB) ([]backupManga, error) {
+	rows, err := db.QueryContext(ctx, `
+		SELECT
+			m.id, m.title, m.favorite,
+			CASE WHEN m.sort_name_locked = 1 THEN m.sort_name ELSE '' END,
+			m.collection,
+			COALESCE(mt.tag_id, '')
+		FROM manga m
+		LEFT JOIN manga_tag mt ON mt.manga_id = m.id
+		WHERE m.favorite = 1 OR m.sort_name_locked = 1 OR m.collection <> '' OR mt.tag_id IS NOT NULL
+		ORDER BY m.id ASC, mt.tag_id ASC
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := make([]backupManga, 0)
+	for rows.Next() {
+		var item backupManga
+		var tagID string
+		if err := rows.Scan(&item.ID, &item.Title, &item.Favorite, &item.SortName, &item.Collection, &tagID); err != nil {
+			return nil, err
+		}
+		if len(items) == 0 || items[len(items)-1].ID != item.ID {
+			item.TagIDs = make([]string, 0)
+			items = append(items, item)
+		}
+		if tagID != "" {
+			last := &items[len(items)-1]
+			last.TagIDs = append(last.TagIDs, tagID)
+		}
+	}
+	return items, rows.Err()
+}
+
+func exportChapters(ctx context.Context, db *sql.DB) ([]backupChapter, error) {
+	rows, err := db.QueryContext(ctx, `
+		SELECT
+			c.id, c.manga_id, c.title, c.volume, c.volume_locked, c.page_order_locked,
+			p.page_index, p.completed,
+			COALESCE(strftime('%Y-%m-%dT%H:%M:%SZ', p.updated_at), '')
+		FROM chapter c
+		LEFT JOIN reading_progress p ON p.chapter_id = c.id
+		WHERE c.volume_locked = 1 OR c.page_order_locked = 1 OR p.chapter_id IS NOT NULL
+		ORDER BY c.manga_id ASC, c.id ASC
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := make([]backupChapter, 0)
+	orderLocked := make([]int, 0)
+	for rows.Next() {
+		var item backupChapter
+		var volume sql.NullInt64
+		var pageOrderLocked bool
+		var pageIndex sql.NullInt64
+		var completed sql.NullBool
+		var updatedAt string
+		if err := rows.Scan(&item.ID, &item.MangaID, &item.Title, &volume, &item.VolumeSet, &pageOrderLocked, &pageIndex, &completed, &updatedAt); err != nil {
+			return nil, err
+		}
+		if item.VolumeSet && volume.Valid {
+			value := int(volume.Int64)
+			item.Volume = &value
+		}
+		if pageIndex.Valid {
+			item.Progress = &backupProgress{
+				PageIndex: int(pageIndex.Int64),
+				Completed: completed.Bool,
+				UpdatedAt: updatedAt,
+			}
+		}
+		if pageOrderLocked {
+			orderLocked = append(orderLocked, len(items))
+		}
+		items = append(items, item)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	rows.Close()
+
+	for _, index := range orderLocked {
+		pageIDs, err := chapterPageIDs(ctx, db, items[index].ID)
+		if err != nil {
+			return nil, err
+		}
+		items[index].PageOrder = pageIDs
+	}
+	return items, nil
+}
+
+type rowQuerier interface {
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+}
+
+func chapterPageIDs(ctx context.Context, q rowQuerier, chapterID string) ([]string, error) {
+	rows, err := q.QueryContext(ctx, `SELECT id FROM page WHERE chapter_id = ? ORDER BY page_index ASC`, chapterID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ids := make([]string, 0)
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+func exportOnlineBookmarks(ctx context.Context, db *sql.DB) ([]backupOnlineBookmark, error) {
+	rows, err := db.QueryContext(ctx, `
+		SELECT
+			source_id, external_id,
+			COALESCE(strftime('%Y-%m-%dT%H:%M:%SZ', favorite_at), ''),
+			COALESCE(strftime('%Y-%m-%dT%H:%M:%SZ', followed_at), ''),
+			last_known_chapter_count, latest_chapter_id
+		FROM online_manga_bookmark
+		ORDER BY source_id ASC, external_id ASC
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := make([]backupOnlineBookmark, 0)
+	for rows.Next() {
+		var item backupOnlineBookmark
+		if err := rows.Scan(&item.SourceID, &item.MangaID, &item.FavoriteAt, &item.FollowedAt, &item.LastKnownChapterCount, &item.LatestChapterID); err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+	return items, rows.Err()
+}
+
+// importTags makes sure every exported tag exists and returns how exported
+// tag ids map onto local ones. A tag is matched by id first and by slug
+// second, so renamed default tags and tags created on both machines under
+// the same name are not duplicated.
+func importTags(ctx context.Context, tx *sql.Tx, tags []backupTag) (map[string]string, error) {
+	mapping := make(map[string]string, len(tags))
+	for _, item := range tags {
+		id := strings.TrimSpace(item.ID)
+		slug := strings.TrimSpace(item.Slug)
+		if id == "" {
+			continue
+		}
+
+		var localID string
+		err := tx.QueryRowContext(ctx, `SELECT id FROM tag WHERE id = ?`, id).Scan(&localID)
+		if err == sql.ErrNoRows && slug != "" {
+			err = tx.QueryRowContext(ctx, `SELECT id FROM tag WHERE slug = ?`, slug).Scan(&localID)
+		}
+		if err == sql.ErrNoRows {
+			if slug == "" {
+				continue
+			}
+			if _, err := tx.ExecContext(ctx, `
+				INSERT INTO tag(id, name, slug, color, group_name, priority, sort_order, is_pinned)
+				VALUES(?, ?, ?, ?, ?, ?, ?, ?)
+			`, id, item.Name, slug, item.Color, item.Group, item.Priority, item.Order, boolToInt(item.Pinned)); err != nil {
+				return nil, err
+			}
+			localID = id
+		} else if err != nil {
+			return nil, err
+		}
+		mapping[id] = localID
+	}
+	return mapping, nil
+}
+
+func importManga(ctx context.Context, tx *sql.Tx, item backupManga, tagIDs map[string]string) (bool, error) {
+	if item.Favorite {
+		result, err := tx.ExecContext(ctx, `UPDATE manga SET favorite = 1 WHERE id = ?`, item.ID)
+		if err != nil {
+			return false, err
+		}
+		if affected, _ := result.RowsAffected(); affected == 0 {
+			return false, nil
+		}
+	} else {
+		var exists int
+		err := tx.QueryRowContext(ctx, `SELECT 1 FROM manga WHERE id = ?`, item.ID).Scan(&exists)
+		if err == sql.ErrNoRows {
+			return false, nil
+		}
+		if err != nil {
+			return false, err
+		}
+	}
+
+	if sortName := strings.TrimSpace(item.SortName); sortName != "" {
+		if _, err := tx.ExecContext(ctx, `UPDATE manga SET sort_name = ?, sort_name_locked = 1 WHERE id = ?`, sortName, item.ID); err != nil {
+			return false, err
+		}
+	}
+	if collection := strings.TrimSpace(item.Collection); collection != "" {
+		if _, err := tx.ExecContext(ctx, `UPDATE manga SET collection = ? WHERE id = ?`, collection, item.ID); err != nil {
+			return false, err
+		}
+	}
+
+	for _, tagID := range item.TagIDs {
+		localID, ok := tagIDs[tagID]
+		if !ok {
+			localID = tagID
+		}
+		if _, err := tx.ExecContext(ctx, `
+			INSERT OR IGNORE INTO manga_tag(manga_id, tag_id)
+			SELECT ?, id FROM tag WHERE id = ?
+		`, item.ID, localID); err != nil {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+func importChapter(ctx context.Context, tx *sql.Tx, item backupChapter, now time.Time) (bool, error) {
+	var mangaID string
+	err := tx.QueryRowContext(ctx, `SELECT manga_id FROM chapter WHERE id = ?`, item.ID).Scan(&mangaID)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	if item.VolumeSet {
+		if _, err := tx.ExecContext(ctx, `UPDATE chapter SET volume = ?, volume_locked = 1 WHERE id = ?`, item.Volume, item.ID); err != nil {
+			return false, err
+		}
+	}
+
+	// A page order only applies to the same set of pages; if the chapter
+	// was repacked since the export the order is left to the scanner.
+	if len(item.PageOrder) > 0 {
+		current, err := chapterPageIDs(ctx, tx, item.ID)
+		if err != nil {
+			return false, err
+		}
+		if samePageSet(current, item.PageOrder) {
+			if err := setPageOrder(ctx, tx, item.ID, item.PageOrder); err != nil {
+				return false, err
+			}
+		}
+	}
+
+	if item.Progress != nil {
+		if _, err := importProgress(ctx, tx, item.ID, mangaID, *item.Progress, now); err != nil {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+// importProgress stores imported progress for a chapter unless the local
+// progress is at least as recent, reporting whether it was stored. Progress
+// without a valid timestamp counts as recorded now.
+func importProgress(ctx context.Context, tx *sql.Tx, chapterID, mangaID string, progress backupProgress, now time.Time) (bool, error) {
+	if progress.PageIndex < 0 {
+		return false, nil
+	}
+	updatedAt := now.UTC()
+	if parsed, err := time.Parse(time.RFC3339, progress.UpdatedAt); err == nil {
+		updatedAt = parsed.UTC()
+	}
+	result, err := tx.ExecContext(ctx, `
+		INSERT INTO reading_progress(chapter_id, manga_id, page_index, completed, updated_at)
+		VALUES(?, ?, ?, ?, ?)
+		ON CONFLICT(chapter_id) DO UPDATE SET
+			manga_id = excluded.manga_id,
+			page_index = excluded.page_index,
+			completed = excluded.completed,
+			updated_at = excluded.updated_at
+		WHERE excluded.updated_at > reading_progress.updated_at
+	`, chapterID, mangaID, progress.PageIndex, boolToInt(progress.Completed), updatedAt.Format("2006-01-02 15:04:05"))
+	if err != nil {
+		return false, err
+	}
+	affected, _ := result.RowsAffected()
+	return affected > 0, nil
+}
+
+func samePageSet(current []string, order []string) bool {
+	if len(current) != len(order) {
+		return false
+	}
+	left := append([]string(nil), current...)
+	right := append([]string(nil), order...)
+	sort.Strings(left)
+	sort.Strings(right)
+	for i := range left {
+		if left[i] != right[i] || (i > 0 && right[i] == right[i-1]) {
+			return false
+		}
+	}
+	return true
+}
+
+// importOnlineBookmark restores a bookmark for a source that is registered
+// locally. Bookmarks already present keep their own state.
+func importOnlineBookmark(ctx context.Context, tx *sql.Tx, item backupOnlineBookmark) (bool, error) {
+	var exists int
+	err := tx.QueryRowContext(ctx, `SELECT 1 FROM source WHERE id = ?`, item.SourceID).Scan(&exists)
+	if err == sql.ErrNoRows || strings.TrimSpace(item.MangaID) == "" {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	favoriteAt := backupTimeValue(item.FavoriteAt)
+	followedAt := backupTimeValue(item.FollowedAt)
+	if favoriteAt == nil && followedAt == nil {
+		return false, nil
+	}
+	if _, err := tx.ExecContext(ctx, `
+		INSERT OR IGNORE INTO online_manga_bookmark(
+			source_id, external_id, favorite_at, followed_at,
+			last_known_chapter_count, latest_chapter_id
+		) VALUES(?, ?, ?, ?, ?, ?)
+	`, item.SourceID, item.MangaID, favoriteAt, followedAt, item.LastKnownChapterCount, item.LatestChapterID); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func backupTimeValue(raw string) any {
+	parsed, err := time.Parse(time.RFC3339, strings.TrimSpace(raw))
+	if err != nil {
+		return nil
+	}
+	return parsed.UTC().Format("2006-01-02 15:04:05")
+}
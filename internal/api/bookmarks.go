@@ -0,0 +1,184 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+
+	"mynewmangaui/internal/clock"
+	"mynewmangaui/internal/store"
+)
+
+// maxBookmarkNoteLength caps bookmark notes, in characters.
+const maxBookmarkNoteLength = 2000
+
+// bookmarkItem is a page the reader marked, with an optional note. Like
+// reading progress, bookmarks reference chapters by id without a foreign
+// key, so they survive the chapter rows being rebuilt by a rescan.
+type bookmarkItem struct {
+	ID           string `json:"id"`
+	MangaID      string `json:"mangaId"`
+	ChapterID    string `json:"chapterId"`
+	ChapterTitle string `json:"chapterTitle,omitempty"`
+	PageIndex    int    `json:"pageIndex"`
+	Note         string `json:"note"`
+	CreatedAt    string `json:"createdAt"`
+	ImageURL     string `json:"imageUrl"`
+}
+
+type bookmarkRequest struct {
+	PageIndex *int   `json:"pageIndex"`
+	Note      string `json:"note"`
+}
+
+type mangaBookmarksResponse struct {
+	MangaID string         `json:"mangaId"`
+	Items   []bookmarkItem `json:"items"`
+}
+
+type bookmarkHandler struct {
+	db    *sql.DB
+	store *store.Store
+	clock clock.Clock
+}
+
+func newBookmarkHandler(db *sql.DB, store *store.Store, clock clock.Clock) *bookmarkHandler {
+	return &bookmarkHandler{db: db, store: store, clock: clock}
+}
+
+// createBookmark marks a page of a chapter. The page must exist, which
+// also keeps the index within the chapter.
+func (h *bookmarkHandler) createBookmark(w http.ResponseWriter, r *http.Request) {
+	if h.db == nil {
+		writeError(w, http.StatusInternalServerError, "database not initialized")
+		return
+	}
+
+	chapterID := strings.TrimSpace(chi.URLParam(r, "chapterID"))
+	var request bookmarkRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil || request.PageIndex == nil {
+		writeError(w, http.StatusBadRequest, "invalid bookmark payload")
+		return
+	}
+	note := strings.TrimSpace(request.Note)
+	if utf8.RuneCountInString(note) > maxBookmarkNoteLength {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("note must be at most %d characters", maxBookmarkNoteLength))
+		return
+	}
+
+	chapter, err := h.store.GetChapter(r.Context(), chapterID)
+	if err == sql.ErrNoRows {
+		writeError(w, http.StatusNotFound, "chapter not found")
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to load chapter")
+		return
+	}
+
+	var exists int
+	err = h.db.QueryRowContext(r.Context(), `
+		SELECT 1 FROM page WHERE chapter_id = ? AND page_index = ?
+	`, chapterID, *request.PageIndex).Scan(&exists)
+	if err == sql.ErrNoRows {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("page index %d is out of range for a chapter of %d pages", *request.PageIndex, chapter.PageCount))
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to load page")
+		return
+	}
+
+	item := bookmarkItem{
+		ID:           uuid.NewString(),
+		MangaID:      chapter.MangaID,
+		ChapterID:    chapterID,
+		ChapterTitle: chapter.Title,
+		PageIndex:    *request.PageIndex,
+		Note:         note,
+		CreatedAt:    h.clock.Now().UTC().Format("2006-01-02 15:04:05"),
+	}
+	item.ImageURL = bookmarkImageURL(item)
+	if _, err := h.db.ExecContext(r.Context(), `
+		INSERT INTO bookmark(id, chapter_id, manga_id, page_index, note, created_at)
+		VALUES(?, ?, ?, ?, ?, ?)
+	`, item.ID, item.ChapterID, item.MangaID, item.PageIndex, item.Note, item.CreatedAt); err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to save bookmark")
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, item)
+}
+
+// getMangaBookmarks lists a manga's bookmarks in reading order. Bookmarks
+// on chapters that are no longer in the library are left out.
+func (h *bookmarkHandler) getMangaBookmarks(w http.ResponseWriter, r *http.Request) {
+	if h.db == nil {
+		writeError(w, http.StatusInternalServerError, "database not initialized")
+		return
+	}
+
+	mangaID := chi.URLParam(r, "mangaID")
+	rows, err := h.db.QueryContext(r.Context(), `
+		SELECT b.id, b.chapter_id, c.title, b.page_index, b.note, b.created_at
+		FROM bookmark b
+		JOIN chapter c ON c.id = b.chapter_id
+		WHERE b.manga_id = ?
+		ORDER BY c.chapter_number ASC, c.title ASC, c.id ASC, b.page_index ASC, b.created_at ASC, b.id ASC
+	`, mangaID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to load bookmarks")
+		return
+	}
+	defer rows.Close()
+
+	items := make([]bookmarkItem, 0)
+	for rows.Next() {
+		item := bookmarkItem{MangaID: mangaID}
+		if err := rows.Scan(&item.ID, &item.ChapterID, &item.ChapterTitle, &item.PageIndex, &item.Note, &item.CreatedAt); err != nil {
+			writeError(w, http.StatusInternalServerError, "failed to read bookmark row")
+			return
+		}
+		item.ImageURL = bookmarkImageURL(item)
+		items = append(items, item)
+	}
+	if err := rows.Err(); err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to iterate bookmark rows")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, mangaBookmarksResponse{MangaID: mangaID, Items: items})
+}
+
+func (h *bookmarkHandler) deleteBookmark(w http.ResponseWriter, r *http.Request) {
+	if h.db == nil {
+		writeError(w, http.StatusInternalServerError, "database not initialized")
+		return
+	}
+
+	bookmarkID := chi.URLParam(r, "bookmarkID")
+	result, err := h.db.ExecContext(r.Context(), `DELETE FROM bookmark WHERE id = ?`, bookmarkID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to delete bookmark")
+		return
+	}
+	affected, _ := result.RowsAffected()
+	if affected == 0 {
+		writeError(w, http.StatusNotFound, "bookmark not found")
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{
+		"bookmarkId": bookmarkID,
+		"deleted":    true,
+	})
+}
+
+func bookmarkImageURL(item bookmarkItem) string {
+	return fmt.Sprintf("/api/images/chapters/%s/pages/%d", item.ChapterID, item.PageIndex)
+}
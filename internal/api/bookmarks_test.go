@@ -0,0 +1,90 @@
+package api
+
+import (
+	"net/http"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestBookmarks(t *testing.T) {
+	server := newTestServer(t, "")
+	writeChapter(t, server.root, "Alpha", "Chapter 1", 3)
+	writeChapter(t, server.root, "Alpha", "Chapter 2", 2)
+	writeChapter(t, server.root, "Beta", "Chapter 1", 1)
+	server.scan()
+	alpha := server.queryString(`SELECT id FROM manga WHERE title = 'Alpha'`)
+	beta := server.queryString(`SELECT id FROM manga WHERE title = 'Beta'`)
+	chapter1 := server.queryString(`SELECT id FROM chapter WHERE manga_id = ? AND title = 'Chapter 1'`, alpha)
+	chapter2 := server.queryString(`SELECT id FROM chapter WHERE manga_id = ? AND title = 'Chapter 2'`, alpha)
+
+	create := func(chapterID string, body string) bookmarkItem {
+		t.Helper()
+		rec := server.do(http.MethodPost, "/api/chapters/"+chapterID+"/bookmarks", body)
+		if rec.Code != http.StatusCreated {
+			t.Fatalf("create status = %d, body %s", rec.Code, rec.Body.String())
+		}
+		return decodeJSON[bookmarkItem](t, rec)
+	}
+	// Created out of reading order, to check the listing sorts them.
+	late := create(chapter2, `{"pageIndex":1}`)
+	noted := create(chapter1, `{"pageIndex":2,"note":"  big reveal  "}`)
+	first := create(chapter1, `{"pageIndex":0}`)
+	if noted.Note != "big reveal" || noted.MangaID != alpha || noted.ChapterTitle != "Chapter 1" {
+		t.Errorf("created bookmark = %+v, want the trimmed note on Alpha chapter 1", noted)
+	}
+	if want := "/api/images/chapters/" + chapter1 + "/pages/2"; noted.ImageURL != want {
+		t.Errorf("image url = %q, want %q", noted.ImageURL, want)
+	}
+	create(server.queryString(`SELECT id FROM chapter WHERE manga_id = ?`, beta), `{"pageIndex":0}`)
+
+	list := func(mangaID string) []string {
+		t.Helper()
+		rec := server.do(http.MethodGet, "/api/manga/"+mangaID+"/bookmarks", "")
+		if rec.Code != http.StatusOK {
+			t.Fatalf("list status = %d, body %s", rec.Code, rec.Body.String())
+		}
+		ids := []string{}
+		for _, item := range decodeJSON[mangaBookmarksResponse](t, rec).Items {
+			ids = append(ids, item.ID)
+		}
+		return ids
+	}
+	if got, want := list(alpha), []string{first.ID, noted.ID, late.ID}; !slices.Equal(got, want) {
+		t.Fatalf("Alpha bookmarks = %q, want %q in reading order", got, want)
+	}
+	if got := list(beta); len(got) != 1 {
+		t.Fatalf("Beta bookmarks = %q, want only its own", got)
+	}
+
+	t.Run("validation", func(t *testing.T) {
+		for body, want := range map[string]string{
+			`{"pageIndex":3}`:  "page index 3 is out of range for a chapter of 3 pages",
+			`{"pageIndex":-1}`: "page index -1 is out of range",
+			`{"note":"x"}`:     "invalid bookmark payload",
+			`not json`:         "invalid bookmark payload",
+			`{"pageIndex":0,"note":"` + strings.Repeat("é", maxBookmarkNoteLength+1) + `"}`: "note must be at most",
+		} {
+			rec := server.do(http.MethodPost, "/api/chapters/"+chapter1+"/bookmarks", body)
+			if rec.Code != http.StatusBadRequest || !strings.Contains(rec.Body.String(), want) {
+				t.Errorf("create with %.40s = %d %s, want 400 %q", body, rec.Code, rec.Body.String(), want)
+			}
+		}
+		// A note at the limit counts characters, not bytes.
+		create(chapter1, `{"pageIndex":1,"note":"`+strings.Repeat("é", maxBookmarkNoteLength)+`"}`)
+		if rec := server.do(http.MethodPost, "/api/chapters/missing/bookmarks", `{"pageIndex":0}`); rec.Code != http.StatusNotFound {
+			t.Errorf("create on a missing chapter = %d, want 404", rec.Code)
+		}
+	})
+
+	rec := server.do(http.MethodDelete, "/api/bookmarks/"+noted.ID, "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("delete status = %d, body %s", rec.Code, rec.Body.String())
+	}
+	if got := list(alpha); slices.Contains(got, noted.ID) || !slices.Contains(got, first.ID) {
+		t.Fatalf("Alpha bookmarks after delete = %q, want only %s gone", got, noted.ID)
+	}
+	if rec := server.do(http.MethodDelete, "/api/bookmarks/"+noted.ID, ""); rec.Code != http.StatusNotFound {
+		t.Errorf("second delete = %d, want 404", rec.Code)
+	}
+}
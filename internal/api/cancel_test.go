@@ -0,0 +1,144 @@
+package api
+
+import (
+	"archive/zip"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"mynewmangaui/internal/config"
+	imagesvc "mynewmangaui/internal/image"
+)
+
+// openFiles counts the file descriptors the test process holds.
+func openFiles(t *testing.T) int {
+	t.Helper()
+	entries, err := os.ReadDir("/proc/self/fd")
+	if err != nil {
+		t.Skipf("cannot list open files: %v", err)
+	}
+	return len(entries)
+}
+
+func TestTranscodeStopsWhenClientLeaves(t *testing.T) {
+	server := newTestServer(t, "")
+	writeChapter(t, server.root, "Alpha", "Chapter 1", 1)
+	server.scan()
+	chapterID := server.queryString(`SELECT id FROM chapter`)
+
+	// The encoder records its pid and then hangs, as a huge page would.
+	pidFile := filepath.Join(t.TempDir(), "pid")
+	encoder := writeEncoderScript(t, "echo $$ > "+pidFile+"\nexec sleep 30\n")
+	images := imagesvc.NewService(server.db, t.TempDir(), testLogger())
+	images.ConfigurePageFormats([]string{"webp"}, map[string]string{"webp": encoder})
+	limiter := newDecodeLimiter(1)
+	handler := newImageHandler(context.Background(), server.db, images, 1<<20, time.Hour, false, false, limiter, 0, newETagger(server.db, config.ETagWeak), testLogger())
+	router := chi.NewRouter()
+	router.Get("/api/images/chapters/{chapterID}/pages/{pageIndex}", handler.getChapterPage)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	req := httptest.NewRequestWithContext(ctx, http.MethodGet, "/api/images/chapters/"+chapterID+"/pages/0", nil)
+	req.Header.Set("Accept", "image/webp")
+	rec := httptest.NewRecorder()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		router.ServeHTTP(rec, req)
+	}()
+
+	var pid int
+	for deadline := time.Now().Add(5 * time.Second); pid == 0; {
+		if raw, err := os.ReadFile(pidFile); err == nil && strings.HasSuffix(string(raw), "\n") {
+			pid, _ = strconv.Atoi(strings.TrimSpace(string(raw)))
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("encoder never started")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("handler still transcoding after the client went away")
+	}
+
+	if rec.Body.Len() != 0 || rec.Header().Get("Content-Type") != "" {
+		t.Errorf("response = %q with %d bytes, want nothing sent", rec.Header().Get("Content-Type"), rec.Body.Len())
+	}
+	if err := syscall.Kill(pid, 0); !errors.Is(err, syscall.ESRCH) {
+		t.Errorf("encoder %d still running: %v", pid, err)
+	}
+	release, ok := limiter.tryAcquire()
+	if !ok {
+		t.Fatal("decode slot not released")
+	}
+	release()
+}
+
+func TestArchivePageStopsWhenClientLeaves(t *testing.T) {
+	server := newTestServer(t, "")
+	page := filepath.Join(t.TempDir(), "page.png")
+	writeNoisePNG(t, page, 512, 512)
+	raw, err := os.ReadFile(page)
+	if err != nil {
+		t.Fatal(err)
+	}
+	archivePath := filepath.Join(server.root, "Alpha.cbz")
+	file, err := os.Create(archivePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	archive := zip.NewWriter(file)
+	entry, err := archive.CreateHeader(&zip.FileHeader{Name: "01.png", Method: zip.Store})
+	if err == nil {
+		_, err = entry.Write(raw)
+	}
+	if err == nil {
+		err = archive.Close()
+	}
+	if err := errors.Join(err, file.Close()); err != nil {
+		t.Fatal(err)
+	}
+	server.scan()
+	chapterID := server.queryString(`SELECT id FROM chapter`)
+
+	opened := openFiles(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	req := httptest.NewRequestWithContext(ctx, http.MethodGet, "/api/images/chapters/"+chapterID+"/pages/0", nil)
+	req.RemoteAddr = "127.0.0.1:5000"
+	// The client goes away as soon as the first bytes arrive.
+	w := &cancellingWriter{ResponseRecorder: httptest.NewRecorder(), cancel: cancel}
+	server.handler.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK || w.Body.Len() == 0 || w.Body.Len() >= len(raw) {
+		t.Fatalf("sent %d of %d bytes with status %d, want the copy cut short", w.Body.Len(), len(raw), w.Code)
+	}
+	if got := openFiles(t); got != opened {
+		t.Fatalf("open files = %d after the request, %d before", got, opened)
+	}
+}
+
+// cancellingWriter cancels the request after the first write, like a client
+// disconnecting mid-transfer.
+type cancellingWriter struct {
+	*httptest.ResponseRecorder
+	cancel context.CancelFunc
+}
+
+func (w *cancellingWriter) Write(p []byte) (int, error) {
+	defer w.cancel()
+	return w.ResponseRecorder.Write(p)
+}
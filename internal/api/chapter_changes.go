@@ -0,0 +1,111 @@
+package api
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	scansvc "mynewmangaui/internal/scan"
+)
+
+type chapterChangesResponse struct {
+	MangaID   string        `json:"mangaId"`
+	Since     string        `json:"since"`
+	Changed   []chapterItem `json:"changed"`
+	Removed   []string      `json:"removed"`
+	Resync    bool          `json:"resync"`
+	NextSince string        `json:"nextSince"`
+}
+
+// getChapterChanges lists a manga's chapters added or updated after `since`
+// together with the ids of chapters removed since then, so clients holding
+// a cached chapter list can apply deltas. NextSince should be sent as
+// `since` on the next poll; it trails the server clock by a second, so a
+// change may be reported twice but is never missed. Resync is set when
+// `since` predates the retained removal history and the client should
+// reload the full list instead.
+func (h *mangaHandler) getChapterChanges(w http.ResponseWriter, r *http.Request) {
+	if h.db == nil {
+		writeError(w, http.StatusInternalServerError, "database not initialized")
+		return
+	}
+
+	mangaID := chi.URLParam(r, "mangaID")
+	raw := strings.TrimSpace(r.URL.Query().Get("since"))
+	if raw == "" {
+		writeError(w, http.StatusBadRequest, "since is required")
+		return
+	}
+	since, err := time.Parse(time.RFC3339, raw)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "since must be an RFC3339 timestamp")
+		return
+	}
+	since = since.UTC()
+	now := h.clock.Now().UTC().Truncate(time.Second)
+	sinceValue := since.Format("2006-01-02 15:04:05")
+
+	rows, err := h.db.QueryContext(r.Context(), `
+		SELECT id, title, chapter_number, volume, page_count, excluded_page_count, updated_at, possibly_incomplete
+		FROM chapter
+		WHERE manga_id = ? AND (updated_at > ? OR first_seen_at > ?)
+		ORDER BY chapter_number ASC, title ASC, id ASC
+	`, mangaID, sinceValue, sinceValue)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to load chapter changes")
+		return
+	}
+	defer rows.Close()
+
+	changed := make([]chapterItem, 0)
+	for rows.Next() {
+		var item chapterItem
+		if err := rows.Scan(&item.ID, &item.Title, &item.Number, &item.Volume, &item.PageCount, &item.ExcludedPages, &item.UpdatedAt, &item.PossiblyIncomplete); err != nil {
+			writeError(w, http.StatusInternalServerError, "failed to read chapter row")
+			return
+		}
+		changed = append(changed, item)
+	}
+	if err := rows.Err(); err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to iterate chapter rows")
+		return
+	}
+	rows.Close()
+
+	removedRows, err := h.db.QueryContext(r.Context(), `
+		SELECT chapter_id
+		FROM chapter_tombstone
+		WHERE manga_id = ? AND removed_at > ?
+		ORDER BY removed_at ASC, chapter_id ASC
+	`, mangaID, sinceValue)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to load removed chapters")
+		return
+	}
+	defer removedRows.Close()
+
+	removed := make([]string, 0)
+	for removedRows.Next() {
+		var id string
+		if err := removedRows.Scan(&id); err != nil {
+			writeError(w, http.StatusInternalServerError, "failed to read removed chapter row")
+			return
+		}
+		removed = append(removed, id)
+	}
+	if err := removedRows.Err(); err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to iterate removed chapter rows")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, chapterChangesResponse{
+		MangaID:   mangaID,
+		Since:     since.Format(time.RFC3339),
+		Changed:   changed,
+		Removed:   removed,
+		Resync:    since.Before(now.AddDate(0, 0, -scansvc.ChapterTombstoneRetentionDays)),
+		NextSince: now.Add(-time.Second).Format(time.RFC3339),
+	})
+}
@@ -0,0 +1,91 @@
+package api
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+// backdate sets the modification time of every file under dir to at.
+func backdate(t *testing.T, dir string, at time.Time) {
+	t.Helper()
+	err := filepath.Walk(dir, func(path string, _ os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		return os.Chtimes(path, at, at)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestChapterChanges(t *testing.T) {
+	server := newTestServer(t, "")
+	for _, chapter := range []string{"Chapter 1", "Chapter 2", "Chapter 4"} {
+		writeChapter(t, server.root, "Alpha", chapter, 1)
+	}
+	past := time.Now().Add(-2 * time.Hour)
+	backdate(t, filepath.Join(server.root, "Alpha"), past)
+	server.scan()
+	if _, err := server.db.Exec(`UPDATE chapter SET first_seen_at = ?`, past.UTC().Format("2006-01-02 15:04:05")); err != nil {
+		t.Fatal(err)
+	}
+	mangaID := server.queryString(`SELECT id FROM manga`)
+	chapterID := func(title string) string {
+		return server.queryString(`SELECT id FROM chapter WHERE title = ?`, title)
+	}
+	removedID := chapterID("Chapter 1")
+	since := time.Now().Add(-time.Hour).UTC().Format(time.RFC3339)
+
+	changes := func(since string) chapterChangesResponse {
+		t.Helper()
+		rec := server.do(http.MethodGet, "/api/manga/"+mangaID+"/chapters/changes?since="+since, "")
+		if rec.Code != http.StatusOK {
+			t.Fatalf("status = %d, body %s", rec.Code, rec.Body.String())
+		}
+		return decodeJSON[chapterChangesResponse](t, rec)
+	}
+
+	if response := changes(since); len(response.Changed) != 0 || len(response.Removed) != 0 || response.Resync {
+		t.Fatalf("changes before any edit = %+v, want none", response)
+	}
+
+	// Chapter 1 is removed, Chapter 2 gains a page and Chapter 3 is new;
+	// Chapter 4 is left alone.
+	if err := os.RemoveAll(filepath.Join(server.root, "Alpha", "Chapter 1")); err != nil {
+		t.Fatal(err)
+	}
+	writePNG(t, filepath.Join(server.root, "Alpha", "Chapter 2", "b.png"), 8, 12)
+	writeChapter(t, server.root, "Alpha", "Chapter 3", 1)
+	server.scan()
+
+	response := changes(since)
+	var changed []string
+	for _, item := range response.Changed {
+		changed = append(changed, item.Title)
+	}
+	if !slices.Equal(changed, []string{"Chapter 2", "Chapter 3"}) {
+		t.Errorf("changed = %q, want Chapter 2 and Chapter 3", changed)
+	}
+	if !slices.Equal(response.Removed, []string{removedID}) {
+		t.Errorf("removed = %q, want Chapter 1 %q", response.Removed, removedID)
+	}
+	next, err := time.Parse(time.RFC3339, response.NextSince)
+	if err != nil || time.Since(next) > time.Minute || response.Resync {
+		t.Errorf("nextSince = %q, resync %v; want a recent timestamp and no resync", response.NextSince, response.Resync)
+	}
+
+	if response := changes(time.Now().Add(time.Minute).UTC().Format(time.RFC3339)); len(response.Changed) != 0 || len(response.Removed) != 0 {
+		t.Errorf("changes since a later time = %+v, want none", response)
+	}
+	if response := changes("2000-01-01T00:00:00Z"); !response.Resync {
+		t.Errorf("changes since 2000 did not ask for a resync")
+	}
+	if rec := server.do(http.MethodGet, "/api/manga/"+mangaID+"/chapters/changes?since=yesterday", ""); rec.Code != http.StatusBadRequest {
+		t.Errorf("status for an invalid since = %d, want 400", rec.Code)
+	}
+}
@@ -0,0 +1,216 @@
+package api
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+
+	"mynewmangaui/internal/media"
+)
+
+type pageChecksumItem struct {
+	Index     int    `json:"index"`
+	Name      string `json:"name"`
+	SizeBytes int64  `json:"sizeBytes"`
+	Checksum  string `json:"checksum"`
+}
+
+type chapterChecksumsResponse struct {
+	ChapterID string             `json:"chapterId"`
+	Algorithm string             `json:"algorithm"`
+	Checksum  string             `json:"checksum"`
+	Pages     []pageChecksumItem `json:"pages"`
+}
+
+// getChapterChecksums lists the checksum of every page of a chapter so a
+// copy on another machine can be verified page by page. Checksums saved by
+// scans are used as they are; pages without one are hashed from their
+// source and the result saved. The chapter checksum hashes the ordered page
+// checksums, so it changes whenever any page changes or moves. Pages of a
+// PDF chapter share the checksum of the PDF file, since they are rendered
+// from it rather than stored.
+func (h *mangaHandler) getChapterChecksums(w http.ResponseWriter, r *http.Request) {
+	if h.db == nil {
+		writeError(w, http.StatusInternalServerError, "database not initialized")
+		return
+	}
+
+	chapterID := chi.URLParam(r, "chapterID")
+	var exists int
+	if err := h.db.QueryRowContext(r.Context(), `SELECT 1 FROM chapter WHERE id = ?`, chapterID).Scan(&exists); err != nil {
+		if err == sql.ErrNoRows {
+			writeError(w, http.StatusNotFound, "chapter not found")
+			return
+		}
+		writeError(w, http.StatusInternalServerError, "failed to load chapter")
+		return
+	}
+
+	rows, err := h.db.QueryContext(r.Context(), `
+		SELECT id, page_index, path, COALESCE(size_bytes, 0), COALESCE(checksum, '')
+		FROM page
+		WHERE chapter_id = ?
+		ORDER BY page_index ASC
+	`, chapterID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to load chapter pages")
+		return
+	}
+	defer rows.Close()
+
+	sources := make([]pageChecksumSource, 0)
+	for rows.Next() {
+		var source pageChecksumSource
+		if err := rows.Scan(&source.id, &source.index, &source.ref, &source.size, &source.checksum); err != nil {
+			writeError(w, http.StatusInternalServerError, "failed to read page row")
+			return
+		}
+		sources = append(sources, source)
+	}
+	if err := rows.Err(); err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to iterate page rows")
+		return
+	}
+	rows.Close()
+
+	pdfChecksums := make(map[string]pageChecksumItem)
+	combined := sha256.New()
+	items := make([]pageChecksumItem, 0, len(sources))
+	for _, source := range sources {
+		if err := r.Context().Err(); err != nil {
+			return
+		}
+		item, err := chapterPageChecksum(r.Context(), h.db, source, pdfChecksums)
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				writeError(w, http.StatusNotFound, "page source missing")
+				return
+			}
+			writeError(w, http.StatusInternalServerError, "failed to read page source")
+			return
+		}
+		item.Index = source.index
+		items = append(items, item)
+		combined.Write([]byte(item.Checksum + "\n"))
+	}
+
+	writeJSON(w, http.StatusOK, chapterChecksumsResponse{
+		ChapterID: chapterID,
+		Algorithm: media.ChecksumAlgorithm,
+		Checksum:  hex.EncodeToString(combined.Sum(nil)),
+		Pages:     items,
+	})
+}
+
+// pageChecksumSource is a page row as getChapterChecksums reads it.
+type pageChecksumSource struct {
+	id       string
+	index    int
+	ref      string
+	size     int64
+	checksum string
+}
+
+// chapterPageChecksum returns the checksum item of a page, from its stored
+// checksum when there is one and through storedPageChecksum otherwise. The
+// pages of a PDF share one item, kept in pdfChecksums, so the PDF is read at
+// most once even when none of its pages has a checksum yet.
+func chapterPageChecksum(ctx context.Context, db *sql.DB, source pageChecksumSource, pdfChecksums map[string]pageChecksumItem) (pageChecksumItem, error) {
+	ref, err := media.ParseRef(source.ref)
+	if err != nil {
+		return pageChecksumItem{}, err
+	}
+
+	if ref.Kind == "pdf" {
+		if item, ok := pdfChecksums[ref.Path]; ok {
+			if source.checksum == "" {
+				if err := savePageChecksum(ctx, db, source.id, item.Checksum); err != nil {
+					return pageChecksumItem{}, err
+				}
+			}
+			return item, nil
+		}
+	}
+
+	item := pageChecksumItem{Name: pageChecksumName(ref), SizeBytes: source.size, Checksum: source.checksum}
+	if item.Checksum == "" {
+		if item.Checksum, err = storedPageChecksum(ctx, db, source.id, source.ref); err != nil {
+			return pageChecksumItem{}, err
+		}
+	}
+	if ref.Kind == "pdf" {
+		info, err := media.Stat(ref.Path)
+		if err != nil {
+			return pageChecksumItem{}, err
+		}
+		item.SizeBytes = info.Size()
+		pdfChecksums[ref.Path] = item
+	}
+	return item, nil
+}
+
+// storedPageChecksum returns the checksum saved for a page, computing and
+// saving it first when scans left it empty.
+func storedPageChecksum(ctx context.Context, db *sql.DB, pageID string, pathRef string) (string, error) {
+	var checksum string
+	if err := db.QueryRowContext(ctx, `SELECT COALESCE(checksum, '') FROM page WHERE id = ?`, pageID).Scan(&checksum); err != nil {
+		return "", err
+	}
+	if checksum != "" {
+		return checksum, nil
+	}
+
+	item, err := checksumPage(pathRef, make(map[string]pageChecksumItem))
+	if err != nil {
+		return "", err
+	}
+	if err := savePageChecksum(ctx, db, pageID, item.Checksum); err != nil {
+		return "", err
+	}
+	return item.Checksum, nil
+}
+
+func savePageChecksum(ctx context.Context, db *sql.DB, pageID string, checksum string) error {
+	_, err := db.ExecContext(ctx, `UPDATE page SET checksum = ? WHERE id = ?`, checksum, pageID)
+	return err
+}
+
+func checksumPage(raw string, pdfChecksums map[string]pageChecksumItem) (pageChecksumItem, error) {
+	ref, err := media.ParseRef(raw)
+	if err != nil {
+		return pageChecksumItem{}, err
+	}
+
+	if ref.Kind == "pdf" {
+		if item, ok := pdfChecksums[ref.Path]; ok {
+			return item, nil
+		}
+	}
+	checksum, size, err := media.PageChecksum(raw)
+	if err != nil {
+		return pageChecksumItem{}, err
+	}
+	item := pageChecksumItem{Name: pageChecksumName(ref), SizeBytes: size, Checksum: checksum}
+	if ref.Kind == "pdf" {
+		pdfChecksums[ref.Path] = item
+	}
+	return item, nil
+}
+
+// pageChecksumName is the file name checksum listings show for a page: the
+// archive entry's for archive pages, the file's otherwise.
+func pageChecksumName(ref media.Ref) string {
+	if ref.EntryPath != "" && ref.Kind != "pdf" {
+		return path.Base(strings.ReplaceAll(ref.EntryPath, "\\", "/"))
+	}
+	return filepath.Base(ref.Path)
+}
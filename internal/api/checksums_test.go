@@ -0,0 +1,54 @@
+package api
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestChapterChecksumsUseStoredChecksums(t *testing.T) {
+	server := newTestServer(t, "")
+	dir := writeChapter(t, server.root, "Alpha", "Chapter 1", 2)
+	server.scan()
+	chapterID := server.queryString(`SELECT id FROM chapter`)
+	firstPage := server.queryString(`SELECT id FROM page WHERE chapter_id = ? AND page_index = 0`, chapterID)
+	if _, err := server.db.Exec(`UPDATE page SET checksum = 'stored' WHERE id = ?`, firstPage); err != nil {
+		t.Fatal(err)
+	}
+
+	rec := server.do(http.MethodGet, "/api/chapters/"+chapterID+"/checksums", "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, body %s", rec.Code, rec.Body.String())
+	}
+	response := decodeJSON[chapterChecksumsResponse](t, rec)
+	if len(response.Pages) != 2 {
+		t.Fatalf("pages = %+v", response.Pages)
+	}
+	if got := response.Pages[0].Checksum; got != "stored" {
+		t.Errorf("page 0 checksum = %q, want the stored one", got)
+	}
+
+	raw, err := os.ReadFile(filepath.Join(dir, "b.png"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	sum := sha256.Sum256(raw)
+	want := hex.EncodeToString(sum[:])
+	second := response.Pages[1]
+	if second.Checksum != want || second.Name != "b.png" || second.SizeBytes != int64(len(raw)) {
+		t.Errorf("page 1 = %+v, want checksum %s of %d bytes", second, want, len(raw))
+	}
+	if saved := server.queryString(`SELECT checksum FROM page WHERE chapter_id = ? AND page_index = 1`, chapterID); saved != want {
+		t.Errorf("saved checksum = %q, want %q", saved, want)
+	}
+}
+
+func TestChapterChecksumsMissingChapter(t *testing.T) {
+	server := newTestServer(t, "")
+	if rec := server.do(http.MethodGet, "/api/chapters/missing/checksums", ""); rec.Code != http.StatusNotFound {
+		t.Fatalf("status = %d, want 404", rec.Code)
+	}
+}
@@ -0,0 +1,399 @@
+package api
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+
+	"mynewmangaui/internal/store"
+)
+
+// userCollectionItem is a named, ordered list of manga kept by the user, as
+// opposed to the single free-form collection name stored on each manga.
+type userCollectionItem struct {
+	ID         string `json:"id"`
+	Name       string `json:"name"`
+	MangaCount int    `json:"mangaCount"`
+}
+
+type userCollectionResponse struct {
+	ID    string             `json:"id"`
+	Name  string             `json:"name"`
+	Items []libraryMangaItem `json:"items"`
+}
+
+type userCollectionRequest struct {
+	Name string `json:"name"`
+}
+
+type collectionItemRequest struct {
+	MangaID string `json:"mangaId"`
+	// Position is the 0-based place to insert the manga at, the end when
+	// omitted or past it.
+	Position *int `json:"position"`
+}
+
+type collectionOrderRequest struct {
+	MangaIDs []string `json:"mangaIds"`
+}
+
+type collectionHandler struct {
+	db    *sql.DB
+	store *store.Store
+}
+
+func newCollectionHandler(db *sql.DB, store *store.Store) *collectionHandler {
+	return &collectionHandler{db: db, store: store}
+}
+
+func loadUserCollections(ctx context.Context, db *sql.DB) ([]userCollectionItem, error) {
+	rows, err := db.QueryContext(ctx, `
+		SELECT c.id, c.name, COUNT(ci.manga_id)
+		FROM collection c
+		LEFT JOIN collection_item ci ON ci.collection_id = c.id
+		GROUP BY c.id, c.name
+		ORDER BY c.name COLLATE NOCASE ASC, c.id ASC
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := make([]userCollectionItem, 0)
+	for rows.Next() {
+		var item userCollectionItem
+		if err := rows.Scan(&item.ID, &item.Name, &item.MangaCount); err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+	return items, rows.Err()
+}
+
+func (h *collectionHandler) createCollection(w http.ResponseWriter, r *http.Request) {
+	if h.db == nil {
+		writeError(w, http.StatusInternalServerError, "database not initialized")
+		return
+	}
+
+	name, ok := decodeCollectionName(w, r)
+	if !ok {
+		return
+	}
+
+	id := uuid.NewString()
+	if _, err := h.db.ExecContext(r.Context(), `INSERT INTO collection(id, name) VALUES(?, ?)`, id, name); err != nil {
+		if strings.Contains(strings.ToLower(err.Error()), "unique") {
+			writeError(w, http.StatusConflict, "collection name already exists")
+			return
+		}
+		writeError(w, http.StatusInternalServerError, "failed to create collection")
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, userCollectionItem{ID: id, Name: name})
+}
+
+// getCollection returns a collection's manga in their arranged order, in
+// the same shape as library listings.
+func (h *collectionHandler) getCollection(w http.ResponseWriter, r *http.Request) {
+	if h.db == nil {
+		writeError(w, http.StatusInternalServerError, "database not initialized")
+		return
+	}
+
+	h.writeCollection(w, r, http.StatusOK)
+}
+
+func (h *collectionHandler) renameCollection(w http.ResponseWriter, r *http.Request) {
+	if h.db == nil {
+		writeError(w, http.StatusInternalServerError, "database not initialized")
+		return
+	}
+
+	name, ok := decodeCollectionName(w, r)
+	if !ok {
+		return
+	}
+
+	result, err := h.db.ExecContext(r.Context(), `
+		UPDATE collection SET name = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ?
+	`, name, chi.URLParam(r, "collectionID"))
+	if err != nil {
+		if strings.Contains(strings.ToLower(err.Error()), "unique") {
+			writeError(w, http.StatusConflict, "collection name already exists")
+			return
+		}
+		writeError(w, http.StatusInternalServerError, "failed to rename collection")
+		return
+	}
+	affected, _ := result.RowsAffected()
+	if affected == 0 {
+		writeError(w, http.StatusNotFound, "collection not found")
+		return
+	}
+
+	h.writeCollection(w, r, http.StatusOK)
+}
+
+// deleteCollection removes a collection; its manga stay in the library.
+func (h *collectionHandler) deleteCollection(w http.ResponseWriter, r *http.Request) {
+	if h.db == nil {
+		writeError(w, http.StatusInternalServerError, "database not initialized")
+		return
+	}
+
+	collectionID := chi.URLParam(r, "collectionID")
+	result, err := h.db.ExecContext(r.Context(), `DELETE FROM collection WHERE id = ?`, collectionID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to delete collection")
+		return
+	}
+	affected, _ := result.RowsAffected()
+	if affected == 0 {
+		writeError(w, http.StatusNotFound, "collection not found")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]any{
+		"collectionId": collectionID,
+		"deleted":      true,
+	})
+}
+
+// addCollectionItem puts a manga into a collection at the requested
+// position, or moves it there when it is already in it.
+func (h *collectionHandler) addCollectionItem(w http.ResponseWriter, r *http.Request) {
+	if h.db == nil {
+		writeError(w, http.StatusInternalServerError, "database not initialized")
+		return
+	}
+
+	var request collectionItemRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil || strings.TrimSpace(request.MangaID) == "" {
+		writeError(w, http.StatusBadRequest, "invalid collection item payload")
+		return
+	}
+	if request.Position != nil && *request.Position < 0 {
+		writeError(w, http.StatusBadRequest, "position must not be negative")
+		return
+	}
+	mangaID := strings.TrimSpace(request.MangaID)
+
+	h.updateCollectionOrder(w, r, func(ctx context.Context, tx *sql.Tx, order []string) ([]string, int, string) {
+		var exists int
+		if err := tx.QueryRowContext(ctx, `SELECT 1 FROM manga WHERE id = ?`, mangaID).Scan(&exists); err != nil {
+			return nil, http.StatusNotFound, "manga not found"
+		}
+		order = removeString(order, mangaID)
+		position := len(order)
+		if request.Position != nil && *request.Position < position {
+			position = *request.Position
+		}
+		order = append(order[:position], append([]string{mangaID}, order[position:]...)...)
+		return order, 0, ""
+	})
+}
+
+func (h *collectionHandler) removeCollectionItem(w http.ResponseWriter, r *http.Request) {
+	if h.db == nil {
+		writeError(w, http.StatusInternalServerError, "database not initialized")
+		return
+	}
+
+	mangaID := chi.URLParam(r, "mangaID")
+	h.updateCollectionOrder(w, r, func(_ context.Context, _ *sql.Tx, order []string) ([]string, int, string) {
+		remaining := removeString(order, mangaID)
+		if len(remaining) == len(order) {
+			return nil, http.StatusNotFound, "manga not in collection"
+		}
+		return remaining, 0, ""
+	})
+}
+
+// reorderCollection arranges a collection's manga in the given order, which
+// must list every member exactly once.
+func (h *collectionHandler) reorderCollection(w http.ResponseWriter, r *http.Request) {
+	if h.db == nil {
+		writeError(w, http.StatusInternalServerError, "database not initialized")
+		return
+	}
+
+	var request collectionOrderRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid collection order payload")
+		return
+	}
+
+	h.updateCollectionOrder(w, r, func(_ context.Context, _ *sql.Tx, order []string) ([]string, int, string) {
+		members := make(map[string]struct{}, len(order))
+		for _, id := range order {
+			members[id] = struct{}{}
+		}
+		if len(request.MangaIDs) != len(order) {
+			return nil, http.StatusBadRequest, "collection order must list every manga of the collection exactly once"
+		}
+		seen := make(map[string]struct{}, len(request.MangaIDs))
+		for _, id := range request.MangaIDs {
+			if _, ok := members[id]; !ok {
+				return nil, http.StatusBadRequest, "collection order contains a manga outside the collection"
+			}
+			if _, ok := seen[id]; ok {
+				return nil, http.StatusBadRequest, "collection order must list every manga of the collection exactly once"
+			}
+			seen[id] = struct{}{}
+		}
+		return request.MangaIDs, 0, ""
+	})
+}
+
+// updateCollectionOrder loads the collection's members in order, lets change
+// compute the new membership and order, or reject it with a status and
+// message, and stores the result with positions renumbered from zero.
+func (h *collectionHandler) updateCollectionOrder(w http.ResponseWriter, r *http.Request, change func(context.Context, *sql.Tx, []string) ([]string, int, string)) {
+	collectionID := chi.URLParam(r, "collectionID")
+	tx, err := h.db.BeginTx(r.Context(), nil)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to start collection update")
+		return
+	}
+	defer tx.Rollback()
+
+	var exists int
+	if err := tx.QueryRowContext(r.Context(), `SELECT 1 FROM collection WHERE id = ?`, collectionID).Scan(&exists); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			writeError(w, http.StatusNotFound, "collection not found")
+			return
+		}
+		writeError(w, http.StatusInternalServerError, "failed to load collection")
+		return
+	}
+
+	order, err := loadCollectionOrder(r.Context(), tx, collectionID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to load collection items")
+		return
+	}
+	updated, status, message := change(r.Context(), tx, order)
+	if message != "" {
+		writeError(w, status, message)
+		return
+	}
+
+	if err := setCollectionOrder(r.Context(), tx, collectionID, updated); err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to update collection items")
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to save collection items")
+		return
+	}
+
+	h.writeCollection(w, r, http.StatusOK)
+}
+
+func loadCollectionOrder(ctx context.Context, tx *sql.Tx, collectionID string) ([]string, error) {
+	rows, err := tx.QueryContext(ctx, `
+		SELECT manga_id FROM collection_item WHERE collection_id = ? ORDER BY position ASC
+	`, collectionID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	order := make([]string, 0)
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		order = append(order, id)
+	}
+	return order, rows.Err()
+}
+
+// setCollectionOrder makes mangaIDs the members of the collection, in that
+// order, dropping any others.
+func setCollectionOrder(ctx context.Context, tx *sql.Tx, collectionID string, mangaIDs []string) error {
+	// Move positions out of the way first so the (collection_id, position)
+	// uniqueness constraint holds while they are rewritten.
+	if _, err := tx.ExecContext(ctx, `UPDATE collection_item SET position = -position - 1 WHERE collection_id = ?`, collectionID); err != nil {
+		return err
+	}
+	keep := make(map[string]struct{}, len(mangaIDs))
+	for position, id := range mangaIDs {
+		keep[id] = struct{}{}
+		if _, err := tx.ExecContext(ctx, `
+			INSERT INTO collection_item(collection_id, manga_id, position)
+			VALUES(?, ?, ?)
+			ON CONFLICT(collection_id, manga_id) DO UPDATE SET position = excluded.position
+		`, collectionID, id, position); err != nil {
+			return err
+		}
+	}
+	order, err := loadCollectionOrder(ctx, tx, collectionID)
+	if err != nil {
+		return err
+	}
+	for _, id := range order {
+		if _, ok := keep[id]; ok {
+			continue
+		}
+		if _, err := tx.ExecContext(ctx, `DELETE FROM collection_item WHERE collection_id = ? AND manga_id = ?`, collectionID, id); err != nil {
+			return err
+		}
+	}
+	_, err = tx.ExecContext(ctx, `UPDATE collection SET updated_at = CURRENT_TIMESTAMP WHERE id = ?`, collectionID)
+	return err
+}
+
+func (h *collectionHandler) writeCollection(w http.ResponseWriter, r *http.Request, status int) {
+	collectionID := chi.URLParam(r, "collectionID")
+	response := userCollectionResponse{ID: collectionID}
+	if err := h.db.QueryRowContext(r.Context(), `SELECT name FROM collection WHERE id = ?`, collectionID).Scan(&response.Name); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			writeError(w, http.StatusNotFound, "collection not found")
+			return
+		}
+		writeError(w, http.StatusInternalServerError, "failed to load collection")
+		return
+	}
+
+	mangas, err := h.store.CollectionManga(r.Context(), collectionID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to load collection items")
+		return
+	}
+	response.Items = newLibraryMangaItems(mangas)
+	writeJSON(w, status, response)
+}
+
+func decodeCollectionName(w http.ResponseWriter, r *http.Request) (string, bool) {
+	var request userCollectionRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid collection payload")
+		return "", false
+	}
+	name := strings.TrimSpace(request.Name)
+	if name == "" {
+		writeError(w, http.StatusBadRequest, "collection name is required")
+		return "", false
+	}
+	return name, true
+}
+
+func removeString(values []string, value string) []string {
+	kept := make([]string, 0, len(values))
+	for _, item := range values {
+		if item != value {
+			kept = append(kept, item)
+		}
+	}
+	return kept
+}
@@ -0,0 +1,172 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"testing"
+)
+
+func TestCollectionOrdering(t *testing.T) {
+	server := newTestServer(t, `{"server":{"adminToken":"secret"}}`)
+	for _, title := range []string{"Alpha", "Beta", "Gamma", "Delta"} {
+		writeChapter(t, server.root, title, "Chapter 1", 1)
+	}
+	server.scan()
+	ids := map[string]string{}
+	for _, title := range []string{"Alpha", "Beta", "Gamma", "Delta"} {
+		ids[title] = server.queryString(`SELECT id FROM manga WHERE title = ?`, title)
+	}
+
+	rec := server.do(http.MethodPost, "/api/collections", `{"name":" To Read "}`)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("create status = %d, body %s", rec.Code, rec.Body.String())
+	}
+	collection := decodeJSON[userCollectionItem](t, rec)
+	if collection.Name != "To Read" {
+		t.Fatalf("created name = %q, want it trimmed", collection.Name)
+	}
+	target := "/api/collections/" + collection.ID
+	for body, want := range map[string]int{`{"name":"to read"}`: http.StatusConflict, `{"name":" "}`: http.StatusBadRequest, `not json`: http.StatusBadRequest} {
+		if rec := server.do(http.MethodPost, "/api/collections", body); rec.Code != want {
+			t.Errorf("create status for %s = %d, want %d", body, rec.Code, want)
+		}
+	}
+
+	// assertOrder checks a collection response and the stored positions,
+	// which stay numbered from zero without gaps.
+	assertOrder := func(rec *httptest.ResponseRecorder, want ...string) {
+		t.Helper()
+		response := decodeJSON[userCollectionResponse](t, rec)
+		titles := []string{}
+		for _, item := range response.Items {
+			titles = append(titles, item.Title)
+		}
+		if !slices.Equal(titles, want) {
+			t.Fatalf("collection = %q, want %q", titles, want)
+		}
+		positions := []string{}
+		for i, title := range want {
+			if got := server.queryString(`SELECT position FROM collection_item WHERE collection_id = ? AND manga_id = ?`, collection.ID, ids[title]); got != strconv.Itoa(i) {
+				positions = append(positions, title+"@"+got)
+			}
+		}
+		if len(positions) > 0 {
+			t.Fatalf("positions out of sequence: %v", positions)
+		}
+	}
+	add := func(body string) *httptest.ResponseRecorder {
+		t.Helper()
+		rec := server.do(http.MethodPost, target+"/items", body)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("add %s: status = %d, body %s", body, rec.Code, rec.Body.String())
+		}
+		return rec
+	}
+
+	add(`{"mangaId":"` + ids["Alpha"] + `"}`)
+	add(`{"mangaId":"` + ids["Beta"] + `"}`)
+	add(`{"mangaId":"` + ids["Gamma"] + `","position":99}`)
+	assertOrder(add(`{"mangaId":"`+ids["Delta"]+`","position":0}`), "Delta", "Alpha", "Beta", "Gamma")
+	// Adding a member again moves it.
+	assertOrder(add(`{"mangaId":"`+ids["Alpha"]+`","position":3}`), "Delta", "Beta", "Gamma", "Alpha")
+
+	for _, tt := range []struct {
+		body string
+		want int
+	}{
+		{body: `{"mangaId":"missing"}`, want: http.StatusNotFound},
+		{body: `{"mangaId":""}`, want: http.StatusBadRequest},
+		{body: `{"mangaId":"` + ids["Beta"] + `","position":-1}`, want: http.StatusBadRequest},
+	} {
+		if rec := server.do(http.MethodPost, target+"/items", tt.body); rec.Code != tt.want {
+			t.Errorf("add status for %s = %d, want %d", tt.body, rec.Code, tt.want)
+		}
+	}
+
+	orderBody := func(titles ...string) string {
+		body := `{"mangaIds":[`
+		for i, title := range titles {
+			if i > 0 {
+				body += ","
+			}
+			body += `"` + ids[title] + `"`
+		}
+		return body + `]}`
+	}
+	for _, body := range []string{
+		orderBody("Alpha", "Beta", "Gamma"),
+		orderBody("Alpha", "Beta", "Gamma", "Gamma"),
+		orderBody("Alpha", "Beta", "Gamma", "Delta", "Delta"),
+		`{"mangaIds":["missing","` + ids["Beta"] + `","` + ids["Gamma"] + `","` + ids["Delta"] + `"]}`,
+	} {
+		if rec := server.do(http.MethodPut, target+"/items", body); rec.Code != http.StatusBadRequest {
+			t.Errorf("reorder status for %s = %d, want 400", body, rec.Code)
+		}
+	}
+	rec = server.do(http.MethodPut, target+"/items", orderBody("Gamma", "Alpha", "Delta", "Beta"))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("reorder status = %d, body %s", rec.Code, rec.Body.String())
+	}
+	assertOrder(rec, "Gamma", "Alpha", "Delta", "Beta")
+
+	rec = server.do(http.MethodDelete, target+"/items/"+ids["Alpha"], "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("remove status = %d, body %s", rec.Code, rec.Body.String())
+	}
+	assertOrder(rec, "Gamma", "Delta", "Beta")
+	if rec := server.do(http.MethodDelete, target+"/items/"+ids["Alpha"], ""); rec.Code != http.StatusNotFound {
+		t.Errorf("status removing a non-member = %d, want 404", rec.Code)
+	}
+
+	// Rescans replace manga rows but keep their places in collections.
+	writeChapter(t, server.root, "Delta", "Chapter 2", 1)
+	server.scan()
+	assertOrder(server.do(http.MethodGet, target, ""), "Gamma", "Delta", "Beta")
+
+	rec = server.do(http.MethodPut, target, `{"name":"Next Up"}`)
+	if rec.Code != http.StatusOK || decodeJSON[userCollectionResponse](t, rec).Name != "Next Up" {
+		t.Fatalf("rename status = %d, body %s", rec.Code, rec.Body.String())
+	}
+
+	t.Run("cascade on manga delete", func(t *testing.T) {
+		if rec := server.do(http.MethodDelete, "/api/manga/"+ids["Delta"], "", "X-Admin-Token", "secret"); rec.Code != http.StatusOK {
+			t.Fatalf("delete status = %d, body %s", rec.Code, rec.Body.String())
+		}
+		if err := os.RemoveAll(filepath.Join(server.root, "Gamma")); err != nil {
+			t.Fatal(err)
+		}
+		server.scan()
+		if count := server.queryString(`SELECT COUNT(*) FROM collection_item WHERE manga_id IN (?, ?)`, ids["Delta"], ids["Gamma"]); count != "0" {
+			t.Fatalf("collection items left for removed manga = %s", count)
+		}
+		rec := server.do(http.MethodGet, target, "")
+		if rec.Code != http.StatusOK {
+			t.Fatalf("collection status = %d", rec.Code)
+		}
+		response := decodeJSON[userCollectionResponse](t, rec)
+		if len(response.Items) != 1 || response.Items[0].Title != "Beta" {
+			t.Fatalf("collection after deletes = %+v, want only Beta", response.Items)
+		}
+		// Positions were renumbered, so inserting at the front still works.
+		assertOrder(add(`{"mangaId":"`+ids["Alpha"]+`","position":0}`), "Alpha", "Beta")
+	})
+
+	t.Run("delete collection", func(t *testing.T) {
+		if rec := server.do(http.MethodDelete, target, ""); rec.Code != http.StatusOK {
+			t.Fatalf("delete status = %d", rec.Code)
+		}
+		if rec := server.do(http.MethodGet, target, ""); rec.Code != http.StatusNotFound {
+			t.Errorf("status after delete = %d, want 404", rec.Code)
+		}
+		if count := server.queryString(`SELECT COUNT(*) FROM collection_item`); count != "0" {
+			t.Errorf("collection items after delete = %s, want 0", count)
+		}
+		if count := server.queryString(`SELECT COUNT(*) FROM manga WHERE id IN (?, ?)`, ids["Alpha"], ids["Beta"]); count != "2" {
+			t.Errorf("manga left in the library = %s, want 2", count)
+		}
+	})
+}
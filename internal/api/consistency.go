@@ -0,0 +1,63 @@
+package api
+
+import (
+	"net/http"
+
+	"mynewmangaui/internal/store"
+)
+
+type pageIndexProblemItem struct {
+	ChapterID    string `json:"chapterId"`
+	ChapterTitle string `json:"chapterTitle"`
+	MangaID      string `json:"mangaId"`
+	MangaTitle   string `json:"mangaTitle"`
+	PageCount    int    `json:"pageCount"`
+	Pages        int    `json:"pages"`
+	FirstIndex   int    `json:"firstIndex"`
+	LastIndex    int    `json:"lastIndex"`
+	Duplicates   int    `json:"duplicates"`
+	Missing      int    `json:"missing"`
+}
+
+type consistencyResponse struct {
+	// Chapters lists the chapters whose page indexes are broken; POST
+	// /api/tasks/scan/manga/{mangaId} rescans one's manga to repair them.
+	Chapters []pageIndexProblemItem `json:"chapters"`
+}
+
+// getConsistency reports chapters whose stored page indexes are not 0 to
+// N-1 exactly once each, which happens when page files disappear without
+// a scan noticing and breaks navigation by global page index.
+func (h *mangaHandler) getConsistency(w http.ResponseWriter, r *http.Request) {
+	if h.db == nil {
+		writeError(w, http.StatusInternalServerError, "database not initialized")
+		return
+	}
+
+	problems, err := h.store.PageIndexProblems(r.Context())
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to check page indexes")
+		return
+	}
+
+	response := consistencyResponse{Chapters: make([]pageIndexProblemItem, 0, len(problems))}
+	for _, problem := range problems {
+		response.Chapters = append(response.Chapters, newPageIndexProblemItem(problem))
+	}
+	writeJSON(w, http.StatusOK, response)
+}
+
+func newPageIndexProblemItem(problem store.PageIndexProblem) pageIndexProblemItem {
+	return pageIndexProblemItem{
+		ChapterID:    problem.ChapterID,
+		ChapterTitle: problem.ChapterTitle,
+		MangaID:      problem.MangaID,
+		MangaTitle:   problem.MangaTitle,
+		PageCount:    problem.PageCount,
+		Pages:        problem.Pages,
+		FirstIndex:   problem.FirstIndex,
+		LastIndex:    problem.LastIndex,
+		Duplicates:   problem.Duplicates,
+		Missing:      problem.Missing,
+	}
+}
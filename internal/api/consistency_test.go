@@ -0,0 +1,42 @@
+package api
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestConsistencyReportsPageIndexGaps(t *testing.T) {
+	server := newTestServer(t, `{"server":{"adminToken":"secret"}}`)
+	writeChapter(t, server.root, "Alpha", "Chapter 1", 3)
+	writeChapter(t, server.root, "Alpha", "Chapter 2", 2)
+	server.scan()
+
+	rec := server.do(http.MethodGet, "/api/admin/consistency", "", "X-Admin-Token", "secret")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, body %s", rec.Code, rec.Body.String())
+	}
+	if report := decodeJSON[consistencyResponse](t, rec); len(report.Chapters) != 0 {
+		t.Fatalf("freshly scanned library reported %+v", report.Chapters)
+	}
+
+	// A page vanishing from the middle of a chapter without a rescan leaves
+	// a gap in its indexes.
+	chapterID := server.queryString(`SELECT id FROM chapter WHERE title LIKE '%1'`)
+	if _, err := server.db.Exec(`DELETE FROM page WHERE chapter_id = ? AND page_index = 1`, chapterID); err != nil {
+		t.Fatal(err)
+	}
+
+	rec = server.do(http.MethodGet, "/api/admin/consistency", "", "X-Admin-Token", "secret")
+	report := decodeJSON[consistencyResponse](t, rec)
+	if len(report.Chapters) != 1 {
+		t.Fatalf("reported %+v, want the one broken chapter", report.Chapters)
+	}
+	got := report.Chapters[0]
+	if got.ChapterID != chapterID || got.PageCount != 3 || got.Pages != 2 || got.Missing != 1 || got.LastIndex != 2 {
+		t.Errorf("report = %+v", got)
+	}
+
+	if rec := server.do(http.MethodGet, "/api/admin/consistency", ""); rec.Code != http.StatusForbidden {
+		t.Errorf("status without admin token = %d, want 403", rec.Code)
+	}
+}
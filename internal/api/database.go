@@ -0,0 +1,122 @@
+package api
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"strings"
+
+	"mynewmangaui/internal/db"
+	scansvc "mynewmangaui/internal/scan"
+)
+
+type databaseHandler struct {
+	db      *sql.DB
+	scanner *scansvc.Service
+}
+
+type databaseStatsResponse struct {
+	FileBytes int64 `json:"fileBytes"`
+	WALBytes  int64 `json:"walBytes"`
+	SHMBytes  int64 `json:"shmBytes"`
+	PageSize  int64 `json:"pageSize"`
+	PageCount int64 `json:"pageCount"`
+	FreePages int64 `json:"freePages"`
+	// FreeBytes is what a VACUUM could reclaim from the main file.
+	FreeBytes int64  `json:"freeBytes"`
+	Path      string `json:"path,omitempty"`
+}
+
+type vacuumResponse struct {
+	Mode   string                `json:"mode"`
+	Before databaseStatsResponse `json:"before"`
+	After  databaseStatsResponse `json:"after"`
+	// ReclaimedBytes is how much smaller the database files got, WAL
+	// included.
+	ReclaimedBytes int64 `json:"reclaimedBytes"`
+}
+
+func newDatabaseHandler(db *sql.DB, scanner *scansvc.Service) *databaseHandler {
+	return &databaseHandler{db: db, scanner: scanner}
+}
+
+// getStats reports the size of the database files and how many of its
+// pages are free, to judge when a VACUUM is worth running.
+func (h *databaseHandler) getStats(w http.ResponseWriter, r *http.Request) {
+	if h.db == nil {
+		writeError(w, http.StatusInternalServerError, "database not initialized")
+		return
+	}
+
+	stats, err := db.ReadStats(r.Context(), h.db)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to read database stats")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, newDatabaseStatsResponse(stats))
+}
+
+// vacuum runs a full (default) or incremental VACUUM, as picked by ?mode=.
+// It refuses to run during a scan and keeps scans from starting until it
+// is done, since a full vacuum blocks every other query.
+func (h *databaseHandler) vacuum(w http.ResponseWriter, r *http.Request) {
+	if h.db == nil {
+		writeError(w, http.StatusInternalServerError, "database not initialized")
+		return
+	}
+
+	mode := strings.TrimSpace(r.URL.Query().Get("mode"))
+	if mode == "" {
+		mode = db.VacuumFull
+	}
+	if !db.ValidVacuumMode(mode) {
+		writeError(w, http.StatusBadRequest, "mode must be full or incremental")
+		return
+	}
+
+	var result db.VacuumResult
+	run := func() error {
+		var err error
+		result, err = db.Vacuum(r.Context(), h.db, mode)
+		return err
+	}
+	var err error
+	if h.scanner != nil {
+		err = h.scanner.RunExclusive(run)
+	} else {
+		err = run()
+	}
+	switch {
+	case errors.Is(err, scansvc.ErrScanRunning):
+		writeError(w, http.StatusConflict, "a scan is running; try again when it has finished")
+		return
+	case errors.Is(err, db.ErrIncrementalVacuumOff):
+		writeError(w, http.StatusConflict, err.Error())
+		return
+	case err != nil:
+		writeError(w, http.StatusInternalServerError, "failed to vacuum database")
+		return
+	}
+
+	before, after := result.Before, result.After
+	writeJSON(w, http.StatusOK, vacuumResponse{
+		Mode:           result.Mode,
+		Before:         newDatabaseStatsResponse(before),
+		After:          newDatabaseStatsResponse(after),
+		ReclaimedBytes: before.FileBytes + before.WALBytes - after.FileBytes - after.WALBytes,
+	})
+}
+
+func newDatabaseStatsResponse(stats db.Stats) databaseStatsResponse {
+	return databaseStatsResponse{
+		FileBytes: stats.FileBytes,
+		WALBytes:  stats.WALBytes,
+		SHMBytes:  stats.SHMBytes,
+		PageSize:  stats.PageSize,
+		PageCount: stats.PageCount,
+		FreePages: stats.FreePages,
+		FreeBytes: stats.FreeBytes(),
+		Path:      stats.Path,
+	}
+}
@@ -0,0 +1,50 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+)
+
+// decodeRetryAfterSeconds is what saturated decode requests ask clients to
+// wait; decodes usually finish well within it.
+const decodeRetryAfterSeconds = 1
+
+// decodeLimiter caps how many images are decoded or rendered at once on
+// behalf of HTTP requests, so a burst of cover or PDF page requests is shed
+// with 503 instead of exhausting memory. A nil limiter allows everything.
+type decodeLimiter struct {
+	slots chan struct{}
+}
+
+func newDecodeLimiter(capacity int) *decodeLimiter {
+	if capacity <= 0 {
+		return nil
+	}
+	return &decodeLimiter{slots: make(chan struct{}, capacity)}
+}
+
+// tryAcquire takes a slot without waiting. The returned release must be
+// called once the decode is done.
+func (l *decodeLimiter) tryAcquire() (func(), bool) {
+	if l == nil {
+		return func() {}, true
+	}
+	select {
+	case l.slots <- struct{}{}:
+		return func() { <-l.slots }, true
+	default:
+		return nil, false
+	}
+}
+
+// acquireOrReject takes a slot or answers the request with 503 and a
+// Retry-After header when none is free.
+func (l *decodeLimiter) acquireOrReject(w http.ResponseWriter) (func(), bool) {
+	release, ok := l.tryAcquire()
+	if !ok {
+		w.Header().Set("Retry-After", strconv.Itoa(decodeRetryAfterSeconds))
+		writeError(w, http.StatusServiceUnavailable, "too many images are being decoded, retry shortly")
+		return nil, false
+	}
+	return release, true
+}
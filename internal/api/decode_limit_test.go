@@ -0,0 +1,97 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"mynewmangaui/internal/config"
+	imagesvc "mynewmangaui/internal/image"
+)
+
+func TestDecodeLimiterShedsPastCapacity(t *testing.T) {
+	const capacity = 2
+	const requests = capacity + 3
+
+	server := newTestServer(t, "")
+	writeChapter(t, server.root, "Alpha", "Chapter 1", 1)
+	server.scan()
+	mangaID := server.queryString(`SELECT id FROM manga`)
+
+	limiter := newDecodeLimiter(capacity)
+	images := imagesvc.NewService(server.db, server.config.Storage.CachePath, testLogger())
+	handler := newImageHandler(context.Background(), server.db, images, 1<<20, time.Hour, false, false, limiter, 0, newETagger(server.db, config.ETagWeak), testLogger())
+	router := chi.NewRouter()
+	router.Get("/api/images/covers/{mangaID}/thumb", handler.getCoverThumb)
+	getCover := func() *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/images/covers/"+mangaID+"/thumb", nil))
+		return rec
+	}
+
+	// Decodes that got a slot hold it until done is closed, so the rest
+	// find the limiter full.
+	done := make(chan struct{})
+	var held sync.WaitGroup
+	results := make(chan *httptest.ResponseRecorder, requests)
+	var wg sync.WaitGroup
+	for i := 0; i < requests; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			rec := httptest.NewRecorder()
+			release, ok := limiter.acquireOrReject(rec)
+			if ok {
+				held.Add(1)
+				go func() {
+					defer held.Done()
+					<-done
+					release()
+				}()
+			}
+			results <- rec
+		}()
+	}
+	wg.Wait()
+	close(results)
+
+	rejected := 0
+	for rec := range results {
+		if rec.Code != http.StatusServiceUnavailable {
+			continue
+		}
+		rejected++
+		if got := rec.Header().Get("Retry-After"); got != strconv.Itoa(decodeRetryAfterSeconds) {
+			t.Errorf("Retry-After = %q, want %d", got, decodeRetryAfterSeconds)
+		}
+	}
+	if rejected != requests-capacity {
+		t.Fatalf("rejected %d of %d decodes, want %d", rejected, requests, requests-capacity)
+	}
+
+	rec := getCover()
+	if rec.Code != http.StatusServiceUnavailable || rec.Header().Get("Retry-After") == "" {
+		t.Fatalf("cover while saturated = %d, Retry-After %q; want 503 with Retry-After", rec.Code, rec.Header().Get("Retry-After"))
+	}
+
+	close(done)
+	held.Wait()
+	if rec := getCover(); rec.Code != http.StatusOK {
+		t.Fatalf("cover after the decodes finished = %d, body %s", rec.Code, rec.Body.String())
+	}
+}
+
+func TestDecodeLimiterDisabled(t *testing.T) {
+	limiter := newDecodeLimiter(0)
+	for i := 0; i < 10; i++ {
+		if _, ok := limiter.tryAcquire(); !ok {
+			t.Fatalf("acquire %d failed without a limit", i)
+		}
+	}
+}
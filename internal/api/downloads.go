@@ -10,11 +10,13 @@ import (
 
 	"github.com/go-chi/chi/v5"
 
+	"mynewmangaui/internal/config"
 	downloadsvc "mynewmangaui/internal/download"
 )
 
 type downloadHandler struct {
-	service *downloadsvc.Service
+	service    *downloadsvc.Service
+	pagination config.PageLimits
 }
 
 type createDownloadJobRequest struct {
@@ -26,8 +28,8 @@ type downloadJobsResponse struct {
 	Items []any `json:"items"`
 }
 
-func newDownloadHandler(service *downloadsvc.Service) *downloadHandler {
-	return &downloadHandler{service: service}
+func newDownloadHandler(service *downloadsvc.Service, pagination config.PageLimits) *downloadHandler {
+	return &downloadHandler{service: service, pagination: pagination}
 }
 
 func (h *downloadHandler) listJobs(w http.ResponseWriter, r *http.Request) {
@@ -36,7 +38,8 @@ func (h *downloadHandler) listJobs(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	items, err := h.service.ListJobs(r.Context(), parsePositiveInt(r.URL.Query().Get("limit"), 20))
+	_, limit, _ := parsePageParams(r, h.pagination)
+	items, err := h.service.ListJobs(r.Context(), limit)
 	if err != nil {
 		writeError(w, http.StatusInternalServerError, err.Error())
 		return
@@ -0,0 +1,147 @@
+package api
+
+import (
+	"database/sql"
+	"net/http"
+	"strings"
+	"unicode"
+
+	"mynewmangaui/internal/store"
+)
+
+// Duplicate matching strategies accepted by ?by=.
+const (
+	duplicatesByTitle = "title"
+	duplicatesByCover = "cover"
+)
+
+type duplicateMangaItem struct {
+	ID            string `json:"id"`
+	Title         string `json:"title"`
+	Path          string `json:"path"`
+	BookshelfID   string `json:"bookshelfId"`
+	BookshelfName string `json:"bookshelfName"`
+	ChapterCount  int    `json:"chapterCount"`
+	PageCount     int    `json:"pageCount"`
+}
+
+type duplicateGroup struct {
+	// Key is the normalized title or the first page checksum the members
+	// share.
+	Key   string               `json:"key"`
+	Manga []duplicateMangaItem `json:"manga"`
+}
+
+type duplicatesResponse struct {
+	By     string           `json:"by"`
+	Groups []duplicateGroup `json:"groups"`
+}
+
+// getDuplicates lists groups of manga that look like the same series, for
+// example after copying a series to a second root. By default manga match
+// on their normalized title; with by=cover they match when the first page
+// of their first chapter has the same checksum.
+func (h *mangaHandler) getDuplicates(w http.ResponseWriter, r *http.Request) {
+	if h.db == nil {
+		writeError(w, http.StatusInternalServerError, "database not initialized")
+		return
+	}
+
+	by := strings.TrimSpace(r.URL.Query().Get("by"))
+	if by == "" {
+		by = duplicatesByTitle
+	}
+	if by != duplicatesByTitle && by != duplicatesByCover {
+		writeError(w, http.StatusBadRequest, "by must be title or cover")
+		return
+	}
+
+	mangaList, err := h.store.AllManga(r.Context())
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to load manga")
+		return
+	}
+
+	keys := make([]string, 0)
+	groups := make(map[string][]duplicateMangaItem)
+	for _, manga := range mangaList {
+		key := duplicateTitleKey(manga.Title)
+		if by == duplicatesByCover {
+			if key, err = h.firstPageChecksum(r, manga.ID); err != nil {
+				if r.Context().Err() != nil {
+					return
+				}
+				writeError(w, http.StatusInternalServerError, "failed to checksum first pages")
+				return
+			}
+		}
+		if key == "" {
+			continue
+		}
+		if _, ok := groups[key]; !ok {
+			keys = append(keys, key)
+		}
+		groups[key] = append(groups[key], duplicateItem(manga))
+	}
+
+	response := duplicatesResponse{By: by, Groups: make([]duplicateGroup, 0)}
+	for _, key := range keys {
+		if len(groups[key]) > 1 {
+			response.Groups = append(response.Groups, duplicateGroup{Key: key, Manga: groups[key]})
+		}
+	}
+	writeJSON(w, http.StatusOK, response)
+}
+
+// firstPageChecksum returns the checksum of the first page of a manga's
+// first chapter, or "" when the manga has no pages or the page is gone.
+func (h *mangaHandler) firstPageChecksum(r *http.Request, mangaID string) (string, error) {
+	var pageID, pathRef string
+	err := h.db.QueryRowContext(r.Context(), `
+		SELECT p.id, p.path
+		FROM page p
+		INNER JOIN chapter c ON c.id = p.chapter_id
+		WHERE c.manga_id = ?
+		ORDER BY c.chapter_number ASC, c.title ASC, c.id ASC, p.page_index ASC
+		LIMIT 1
+	`, mangaID).Scan(&pageID, &pathRef)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+
+	checksum, err := storedPageChecksum(r.Context(), h.db, pageID, pathRef)
+	if err != nil {
+		if r.Context().Err() != nil {
+			return "", err
+		}
+		return "", nil
+	}
+	return checksum, nil
+}
+
+// duplicateTitleKey folds case and drops everything but letters and digits,
+// so "One Piece", "one-piece" and "ONE PIECE!" share a key.
+func duplicateTitleKey(title string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(title) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+func duplicateItem(manga store.Manga) duplicateMangaItem {
+	return duplicateMangaItem{
+		ID:            manga.ID,
+		Title:         manga.Title,
+		Path:          manga.Path,
+		BookshelfID:   manga.BookshelfID,
+		BookshelfName: manga.BookshelfName,
+		ChapterCount:  manga.ChapterCount,
+		PageCount:     manga.PageCount,
+	}
+}
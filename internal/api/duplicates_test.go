@@ -0,0 +1,82 @@
+package api
+
+import (
+	"net/http"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	scansvc "mynewmangaui/internal/scan"
+)
+
+func TestDuplicatesAcrossBookshelves(t *testing.T) {
+	server := newTestServer(t, `{"server":{"adminToken":"secret"}}`)
+	second := t.TempDir()
+	// Index a second bookshelf next to the server's own.
+	server.scanner = scansvc.NewService(server.db, []scansvc.Bookshelf{
+		{Name: "main", Path: server.root},
+		{Name: "second", Path: second},
+	}, scansvc.Options{}, testLogger())
+
+	// One Piece is on both shelves under different spellings and covers;
+	// Naruto and Bleach share a first page.
+	writePNG(t, filepath.Join(server.root, "One Piece", "Chapter 1", "a.png"), 8, 12)
+	writePNG(t, filepath.Join(second, "ONE PIECE!", "Chapter 1", "a.png"), 30, 30)
+	writePNG(t, filepath.Join(server.root, "Naruto", "Chapter 1", "a.png"), 20, 20)
+	writePNG(t, filepath.Join(second, "Bleach", "Chapter 1", "a.png"), 20, 20)
+	server.scan()
+
+	duplicates := func(query string) duplicatesResponse {
+		t.Helper()
+		rec := server.do(http.MethodGet, "/api/admin/duplicates"+query, "", "X-Admin-Token", "secret")
+		if rec.Code != http.StatusOK {
+			t.Fatalf("status = %d, body %s", rec.Code, rec.Body.String())
+		}
+		return decodeJSON[duplicatesResponse](t, rec)
+	}
+	members := func(group duplicateGroup) (titles []string, shelves []string) {
+		for _, manga := range group.Manga {
+			titles = append(titles, manga.Title)
+			shelves = append(shelves, manga.BookshelfName)
+			if manga.Path == "" {
+				t.Errorf("%s has no path", manga.Title)
+			}
+		}
+		slices.Sort(titles)
+		slices.Sort(shelves)
+		return titles, shelves
+	}
+
+	tests := []struct {
+		query  string
+		key    string
+		titles []string
+	}{
+		{query: "", key: "onepiece", titles: []string{"ONE PIECE!", "One Piece"}},
+		{query: "?by=title", key: "onepiece", titles: []string{"ONE PIECE!", "One Piece"}},
+		{query: "?by=cover", titles: []string{"Bleach", "Naruto"}},
+	}
+	for _, tt := range tests {
+		t.Run("by"+tt.query, func(t *testing.T) {
+			response := duplicates(tt.query)
+			if len(response.Groups) != 1 {
+				t.Fatalf("groups = %+v, want one", response.Groups)
+			}
+			group := response.Groups[0]
+			titles, shelves := members(group)
+			if !slices.Equal(titles, tt.titles) || !slices.Equal(shelves, []string{"main", "second"}) {
+				t.Errorf("group = %q on %q, want %q on both shelves", titles, shelves, tt.titles)
+			}
+			if tt.key != "" && group.Key != tt.key {
+				t.Errorf("key = %q, want %q", group.Key, tt.key)
+			}
+		})
+	}
+
+	if rec := server.do(http.MethodGet, "/api/admin/duplicates?by=size", "", "X-Admin-Token", "secret"); rec.Code != http.StatusBadRequest {
+		t.Errorf("status for an unknown strategy = %d, want 400", rec.Code)
+	}
+	if rec := server.do(http.MethodGet, "/api/admin/duplicates", ""); rec.Code != http.StatusForbidden {
+		t.Errorf("status without the admin token = %d, want 403", rec.Code)
+	}
+}
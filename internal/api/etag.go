@@ -0,0 +1,160 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha1"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"hash/fnv"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+
+	"mynewmangaui/internal/config"
+)
+
+// etagger computes validators for conditional requests. Weak ETags come
+// from cheap metadata (size and modification time, or a fast hash of a JSON
+// body) and let caches revalidate without reading content, but they do not
+// satisfy If-Range, so interrupted downloads restart from the beginning.
+// Strong ETags hash the content with SHA-256: byte-exact and usable for
+// range requests, at the cost of reading every page once after each scan.
+type etagger struct {
+	db     *sql.DB
+	strong bool
+}
+
+func newETagger(db *sql.DB, strategy string) etagger {
+	return etagger{db: db, strong: strategy == config.ETagStrong}
+}
+
+// page returns the ETag of a page. Strong ETags use the page's stored
+// checksum, computing and saving it on first use since scans leave it
+// empty; weak ones derive from its size and the chapter's modification time.
+func (e etagger) page(ctx context.Context, pageID string, pathRef string, sizeBytes int64, chapterUpdatedAt string) (string, error) {
+	if !e.strong {
+		sum := sha1.Sum([]byte(pageID + "|" + pathRef + "|" + strconv.FormatInt(sizeBytes, 10) + "|" + chapterUpdatedAt))
+		return `W/"` + hex.EncodeToString(sum[:12]) + `"`, nil
+	}
+
+	checksum, err := storedPageChecksum(ctx, e.db, pageID, pathRef)
+	if err != nil {
+		return "", err
+	}
+	return checksumPageETag(pageID, checksum), nil
+}
+
+// checksumPageETag is the strong ETag of a page with a known checksum.
+// Pages of a PDF share the checksum of the file, so the page id keeps
+// their ETags apart.
+func checksumPageETag(pageID string, checksum string) string {
+	sum := sha1.Sum([]byte(pageID + "|" + checksum))
+	return `"` + hex.EncodeToString(sum[:12]) + `"`
+}
+
+// file returns the ETag of a file on disk, such as a cached thumbnail.
+func (e etagger) file(path string) (string, error) {
+	if !e.strong {
+		info, err := os.Stat(path)
+		if err != nil {
+			return "", err
+		}
+		return `W/"` + strconv.FormatInt(info.Size(), 16) + "-" + strconv.FormatInt(info.ModTime().UnixNano(), 16) + `"`, nil
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+	hash := sha256.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return "", err
+	}
+	return `"` + hex.EncodeToString(hash.Sum(nil)[:16]) + `"`, nil
+}
+
+// body returns the ETag of a rendered response body.
+func (e etagger) body(payload []byte) string {
+	if !e.strong {
+		hash := fnv.New64a()
+		hash.Write(payload)
+		return `W/"` + strconv.FormatUint(hash.Sum64(), 16) + `"`
+	}
+	sum := sha256.Sum256(payload)
+	return `"` + hex.EncodeToString(sum[:16]) + `"`
+}
+
+// json buffers successful GET responses of JSON handlers, tags them with an
+// ETag of the body and answers 304 when the client already has it.
+func (e etagger) json(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		buffered := &bufferedResponse{header: w.Header()}
+		next.ServeHTTP(buffered, r)
+		if buffered.status == 0 {
+			buffered.status = http.StatusOK
+		}
+		if buffered.status != http.StatusOK {
+			w.WriteHeader(buffered.status)
+			_, _ = w.Write(buffered.body.Bytes())
+			return
+		}
+
+		etag := e.body(buffered.body.Bytes())
+		w.Header().Set("ETag", etag)
+		w.Header().Set("Cache-Control", "no-cache")
+		if etagMatches(r.Header.Get("If-None-Match"), etag) {
+			w.Header().Del("Content-Type")
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("Content-Length", strconv.Itoa(buffered.body.Len()))
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write(buffered.body.Bytes())
+	})
+}
+
+type bufferedResponse struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func (b *bufferedResponse) Header() http.Header {
+	return b.header
+}
+
+func (b *bufferedResponse) WriteHeader(status int) {
+	if b.status == 0 {
+		b.status = status
+	}
+}
+
+func (b *bufferedResponse) Write(p []byte) (int, error) {
+	if b.status == 0 {
+		b.status = http.StatusOK
+	}
+	return b.body.Write(p)
+}
+
+// etagMatches applies the weak comparison If-None-Match calls for: the
+// W/ prefix is ignored on both sides.
+func etagMatches(header string, etag string) bool {
+	etag = strings.TrimPrefix(etag, "W/")
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,116 @@
+package api
+
+import (
+	"net/http"
+	"regexp"
+	"testing"
+
+	"mynewmangaui/internal/config"
+	scansvc "mynewmangaui/internal/scan"
+)
+
+func TestETagStrategies(t *testing.T) {
+	forms := map[string]*regexp.Regexp{
+		config.ETagWeak:   regexp.MustCompile(`^W/"[0-9a-f-]+"$`),
+		config.ETagStrong: regexp.MustCompile(`^"[0-9a-f]+"$`),
+	}
+	for strategy, form := range forms {
+		t.Run(strategy, func(t *testing.T) {
+			server := newTestServer(t, `{"server":{"etagStrategy":"`+strategy+`"}}`)
+			writeChapter(t, server.root, "Alpha", "Chapter 1", 1)
+			server.scan()
+			mangaID := server.queryString(`SELECT id FROM manga`)
+			chapterID := server.queryString(`SELECT id FROM chapter`)
+
+			targets := map[string]string{
+				"page":  "/api/images/chapters/" + chapterID + "/pages/0",
+				"cover": "/api/images/covers/" + mangaID + "/thumb",
+				"json":  "/api/manga/" + mangaID + "/progress",
+			}
+			for name, target := range targets {
+				t.Run(name, func(t *testing.T) {
+					rec := server.do(http.MethodGet, target, "")
+					etag := rec.Header().Get("ETag")
+					if rec.Code != http.StatusOK || !form.MatchString(etag) {
+						t.Fatalf("status %d, ETag %q; want 200 and an ETag matching %s", rec.Code, etag, form)
+					}
+					rec = server.do(http.MethodGet, target, "", "If-None-Match", etag)
+					if rec.Code != http.StatusNotModified || rec.Body.Len() != 0 {
+						t.Fatalf("revalidation = %d with %d bytes, want an empty 304", rec.Code, rec.Body.Len())
+					}
+					if rec := server.do(http.MethodGet, target, "", "If-None-Match", `"other"`); rec.Code != http.StatusOK {
+						t.Fatalf("status for a different ETag = %d, want 200", rec.Code)
+					}
+				})
+			}
+
+			checksum := server.queryString(`SELECT COALESCE(checksum, '') FROM page`)
+			if stored := checksum != ""; stored != (strategy == config.ETagStrong) {
+				t.Errorf("page checksum = %q; strong ETags should store one and weak ones leave it unset", checksum)
+			}
+		})
+	}
+}
+
+func TestChecksumPagesCacheImmutable(t *testing.T) {
+	weak := regexp.MustCompile(`^W/"[0-9a-f-]+"$`)
+	tests := []struct {
+		name         string
+		checksumMode string
+		// clearChecksum drops the stored checksum, as for a page the lazy
+		// pass has not reached yet.
+		clearChecksum bool
+		immutable     bool
+	}{
+		{name: "inline", checksumMode: scansvc.ChecksumInline, immutable: true},
+		{name: "lazy", checksumMode: scansvc.ChecksumLazy, immutable: true},
+		{name: "not hashed yet", checksumMode: scansvc.ChecksumLazy, clearChecksum: true},
+		{name: "off", checksumMode: scansvc.ChecksumOff},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newTestServer(t, `{"server":{"pageCacheMaxAgeSeconds":600},"storage":{"checksumMode":"`+tt.checksumMode+`"}}`)
+			writeChapter(t, server.root, "Alpha", "Chapter 1", 1)
+			// The scan hashes pages whatever the mode, so every case starts
+			// from a stored checksum.
+			scanner := scansvc.NewService(server.db, []scansvc.Bookshelf{{Name: "main", Path: server.root}}, scansvc.Options{ChecksumMode: scansvc.ChecksumInline}, testLogger())
+			if _, err := scanner.Scan(t.Context()); err != nil {
+				t.Fatal(err)
+			}
+			if tt.clearChecksum {
+				if _, err := server.db.Exec(`UPDATE page SET checksum = NULL`); err != nil {
+					t.Fatal(err)
+				}
+			}
+			pageID := server.queryString(`SELECT id FROM page`)
+			checksum := server.queryString(`SELECT COALESCE(checksum, '') FROM page`)
+			target := "/api/images/chapters/" + server.queryString(`SELECT id FROM chapter`) + "/pages/0"
+
+			rec := server.do(http.MethodGet, target, "")
+			if rec.Code != http.StatusOK {
+				t.Fatalf("page status = %d", rec.Code)
+			}
+			etag, cacheControl := rec.Header().Get("ETag"), rec.Header().Get("Cache-Control")
+			if tt.immutable {
+				if want := checksumPageETag(pageID, checksum); checksum == "" || etag != want {
+					t.Errorf("ETag = %q, want %q from checksum %q", etag, want, checksum)
+				}
+				if cacheControl != "public, max-age=600, immutable" {
+					t.Errorf("Cache-Control = %q, want it immutable", cacheControl)
+				}
+			} else {
+				if !weak.MatchString(etag) {
+					t.Errorf("ETag = %q, want the weak metadata one", etag)
+				}
+				if cacheControl != "public, max-age=600" {
+					t.Errorf("Cache-Control = %q, want it revalidated", cacheControl)
+				}
+			}
+
+			rec = server.do(http.MethodGet, target, "", "If-None-Match", etag)
+			if rec.Code != http.StatusNotModified || rec.Header().Get("Cache-Control") != cacheControl {
+				t.Errorf("revalidation = %d, Cache-Control %q; want a 304 with %q", rec.Code, rec.Header().Get("Cache-Control"), cacheControl)
+			}
+		})
+	}
+}
@@ -0,0 +1,120 @@
+package api
+
+import (
+	"database/sql"
+	"net/http"
+	"strings"
+	"time"
+
+	"mynewmangaui/internal/clock"
+)
+
+const (
+	defaultUpdatesWindow = 24 * time.Hour
+	maxUpdatesChapters   = 1000
+)
+
+type feedHandler struct {
+	db    *sql.DB
+	clock clock.Clock
+}
+
+type updateChapterItem struct {
+	chapterItem
+	FirstSeenAt string `json:"firstSeenAt"`
+}
+
+type mangaUpdateItem struct {
+	MangaID       string              `json:"mangaId"`
+	Title         string              `json:"title"`
+	CoverThumbURL string              `json:"coverThumbUrl"`
+	Chapters      []updateChapterItem `json:"chapters"`
+}
+
+type updatesResponse struct {
+	Since string            `json:"since"`
+	Items []mangaUpdateItem `json:"items"`
+}
+
+func newFeedHandler(db *sql.DB, clock clock.Clock) *feedHandler {
+	return &feedHandler{db: db, clock: clock}
+}
+
+// getUpdates lists chapters first indexed after `since` (default: the last
+// 24 hours), grouped by manga with the most recently updated manga first.
+func (h *feedHandler) getUpdates(w http.ResponseWriter, r *http.Request) {
+	if h.db == nil {
+		writeError(w, http.StatusInternalServerError, "database not initialized")
+		return
+	}
+
+	since := h.clock.Now().Add(-defaultUpdatesWindow)
+	if raw := strings.TrimSpace(r.URL.Query().Get("since")); raw != "" {
+		parsed, err := time.Parse(time.RFC3339, raw)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "since must be an RFC3339 timestamp")
+			return
+		}
+		since = parsed
+	}
+	since = since.UTC()
+
+	rows, err := h.db.QueryContext(r.Context(), `
+		SELECT m.id, m.title, c.id, c.title, c.chapter_number, c.volume, c.page_count, c.excluded_page_count, c.updated_at, c.possibly_incomplete, c.first_seen_at
+		FROM chapter c
+		JOIN manga m ON m.id = c.manga_id
+		WHERE c.first_seen_at > ?
+		ORDER BY c.first_seen_at DESC, c.chapter_number ASC, c.title ASC, c.id ASC
+		LIMIT ?
+	`, since.Format("2006-01-02 15:04:05"), maxUpdatesChapters)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to query updates")
+		return
+	}
+	defer rows.Close()
+
+	items := make([]mangaUpdateItem, 0)
+	byManga := make(map[string]int)
+	for rows.Next() {
+		var mangaID, mangaTitle string
+		var chapter updateChapterItem
+		if err := rows.Scan(
+			&mangaID,
+			&mangaTitle,
+			&chapter.ID,
+			&chapter.Title,
+			&chapter.Number,
+			&chapter.Volume,
+			&chapter.PageCount,
+			&chapter.ExcludedPages,
+			&chapter.UpdatedAt,
+			&chapter.PossiblyIncomplete,
+			&chapter.FirstSeenAt,
+		); err != nil {
+			writeError(w, http.StatusInternalServerError, "failed to read update row")
+			return
+		}
+
+		index, ok := byManga[mangaID]
+		if !ok {
+			index = len(items)
+			byManga[mangaID] = index
+			items = append(items, mangaUpdateItem{
+				MangaID:       mangaID,
+				Title:         mangaTitle,
+				CoverThumbURL: "/api/images/covers/" + mangaID + "/thumb",
+				Chapters:      make([]updateChapterItem, 0, 1),
+			})
+		}
+		items[index].Chapters = append(items[index].Chapters, chapter)
+	}
+	if err := rows.Err(); err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to iterate update rows")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, updatesResponse{
+		Since: since.Format(time.RFC3339),
+		Items: items,
+	})
+}
@@ -0,0 +1,198 @@
+package api
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"mynewmangaui/internal/config"
+	"mynewmangaui/internal/db"
+	imagesvc "mynewmangaui/internal/image"
+	scansvc "mynewmangaui/internal/scan"
+)
+
+func testLogger() *slog.Logger {
+	return slog.New(slog.NewTextHandler(io.Discard, nil))
+}
+
+// newTestDB opens a migrated database in a temporary directory.
+func newTestDB(t *testing.T) *sql.DB {
+	t.Helper()
+	database, err := db.OpenAndMigrate(context.Background(), filepath.Join(t.TempDir(), "app.db"), nil, nil, testLogger())
+	if err != nil {
+		t.Fatalf("open database: %v", err)
+	}
+	t.Cleanup(func() { database.Close() })
+	return database
+}
+
+// testConfig loads the built-in defaults with a single bookshelf at root,
+// merging overrides, a JSON config document, over them.
+func testConfig(t *testing.T, root string, overrides string) config.Config {
+	t.Helper()
+	dir := t.TempDir()
+	base := map[string]any{
+		"database": map[string]any{"path": filepath.Join(dir, "app.db")},
+		"storage": map[string]any{
+			"bookshelves": []map[string]string{{"name": "main", "path": root}},
+			"cachePath":   filepath.Join(dir, "cache"),
+			"trashPath":   filepath.Join(dir, "trash"),
+		},
+	}
+	writeJSONFile(t, filepath.Join(dir, "config.json"), base)
+
+	fragments := filepath.Join(dir, "conf.d")
+	if err := os.Mkdir(fragments, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if overrides != "" {
+		if err := os.WriteFile(filepath.Join(fragments, "overrides.json"), []byte(overrides), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cfg, err := config.LoadWithFragments(filepath.Join(dir, "config.json"), fragments)
+	if err != nil {
+		t.Fatalf("load config: %v", err)
+	}
+	return cfg
+}
+
+// testServer is the API router over a fresh database and a library rooted
+// in a temporary directory.
+type testServer struct {
+	t       *testing.T
+	db      *sql.DB
+	root    string
+	config  config.Config
+	scanner *scansvc.Service
+	handler http.Handler
+}
+
+// newTestServer starts a router with the defaults and overrides, see
+// testConfig. Manga written under server.root are indexed with scan.
+func newTestServer(t *testing.T, overrides string) *testServer {
+	t.Helper()
+	root := t.TempDir()
+	cfg := testConfig(t, root, overrides)
+	database := newTestDB(t)
+	scanner := scansvc.NewService(database, []scansvc.Bookshelf{{Name: "main", Path: root}}, scansvc.Options{}, testLogger())
+	return &testServer{
+		t:       t,
+		db:      database,
+		root:    root,
+		config:  cfg,
+		scanner: scanner,
+		handler: NewRouter(Dependencies{
+			Logger:  testLogger(),
+			Config:  cfg,
+			DB:      database,
+			Scanner: scanner,
+			Images:  imagesvc.NewService(database, cfg.Storage.CachePath, testLogger()),
+		}),
+	}
+}
+
+// scan indexes the library and fails the test on error.
+func (s *testServer) scan() {
+	s.t.Helper()
+	if _, err := s.scanner.Scan(context.Background()); err != nil {
+		s.t.Fatalf("scan: %v", err)
+	}
+}
+
+// do sends a request from a private address; header holds name, value
+// pairs.
+func (s *testServer) do(method string, target string, body string, header ...string) *httptest.ResponseRecorder {
+	s.t.Helper()
+	var reader io.Reader
+	if body != "" {
+		reader = strings.NewReader(body)
+	}
+	req := httptest.NewRequest(method, target, reader)
+	req.RemoteAddr = "127.0.0.1:5000"
+	if body != "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	for i := 0; i+1 < len(header); i += 2 {
+		req.Header.Set(header[i], header[i+1])
+	}
+	rec := httptest.NewRecorder()
+	s.handler.ServeHTTP(rec, req)
+	return rec
+}
+
+// queryString returns a single string column of the first row of query.
+func (s *testServer) queryString(query string, args ...any) string {
+	s.t.Helper()
+	var value string
+	if err := s.db.QueryRow(query, args...).Scan(&value); err != nil {
+		s.t.Fatalf("query %q: %v", query, err)
+	}
+	return value
+}
+
+// writeChapter writes a chapter directory of pages PNG pages under the
+// manga directory of the library root.
+func writeChapter(t *testing.T, root string, manga string, chapter string, pages int) string {
+	t.Helper()
+	dir := filepath.Join(root, manga, chapter)
+	for index := 0; index < pages; index++ {
+		writePNG(t, filepath.Join(dir, string(rune('a'+index))+".png"), 8+index, 12)
+	}
+	return dir
+}
+
+// writePNG writes a solid width x height PNG, creating its directory.
+func writePNG(t *testing.T, path string, width int, height int) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			img.Set(x, y, color.RGBA{R: uint8(x * 20), G: uint8(y * 20), B: 90, A: 255})
+		}
+	}
+	file, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	if err := png.Encode(file, img); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func writeJSONFile(t *testing.T, path string, value any) {
+	t.Helper()
+	raw, err := json.Marshal(value)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, raw, 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// decodeJSON decodes a recorded response body into a value of type T.
+func decodeJSON[T any](t *testing.T, rec *httptest.ResponseRecorder) T {
+	t.Helper()
+	var value T
+	if err := json.Unmarshal(rec.Body.Bytes(), &value); err != nil {
+		t.Fatalf("decode %s: %v", rec.Body.String(), err)
+	}
+	return value
+}
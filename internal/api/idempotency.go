@@ -0,0 +1,261 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-chi/chi/v5/middleware"
+
+	"mynewmangaui/internal/clock"
+)
+
+const (
+	idempotencyKeyHeader = "Idempotency-Key"
+	// idempotencyKeyTTL is how long a stored response is replayed for a
+	// retried request; clients retrying after this window re-apply it.
+	idempotencyKeyTTL         = 24 * time.Hour
+	maxIdempotencyKeyLength   = 255
+	maxIdempotentRequestBytes = 1 << 20
+	// maxIdempotentResponseBytes bounds what is stored for replay; larger
+	// responses are served normally but not remembered.
+	maxIdempotentResponseBytes = 1 << 20
+)
+
+// idempotencyStore remembers the responses of mutating requests that carry
+// an Idempotency-Key header, so a client retrying over a flaky connection
+// gets the original result back instead of re-applying a stale write.
+type idempotencyStore struct {
+	db     *sql.DB
+	logger *slog.Logger
+	clock  clock.Clock
+
+	mu       sync.Mutex
+	inFlight map[string]struct{}
+}
+
+type storedResponse struct {
+	RequestHash string
+	Status      int
+	ContentType string
+	Body        []byte
+}
+
+func newIdempotencyStore(db *sql.DB, logger *slog.Logger, clock clock.Clock) *idempotencyStore {
+	return &idempotencyStore{
+		db:       db,
+		logger:   logger,
+		clock:    clock,
+		inFlight: make(map[string]struct{}),
+	}
+}
+
+func (s *idempotencyStore) middleware(next http.Handler) http.Handler {
+	if s == nil || s.db == nil {
+		return next
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := strings.TrimSpace(r.Header.Get(idempotencyKeyHeader))
+		if key == "" || !isMutatingMethod(r.Method) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if len(key) > maxIdempotencyKeyLength {
+			writeError(w, http.StatusBadRequest, "idempotency key is too long")
+			return
+		}
+
+		body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxIdempotentRequestBytes))
+		if err != nil {
+			writeError(w, http.StatusRequestEntityTooLarge, "request body is too large for an idempotent request")
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+		identity := credentialIdentity(r)
+		requestHash := hashIdempotentRequest(identity, r.URL.RawQuery, body)
+		// Keys are scoped to the caller and the query string, so one caller
+		// reusing another's key, or the same key sent to the same path with
+		// other parameters, never gets a response meant for someone else.
+		key = idempotencyRecordKey(identity, r.URL.RawQuery, key)
+
+		slot := r.Method + " " + r.URL.Path + " " + key
+		if !s.acquire(slot) {
+			writeError(w, http.StatusConflict, "a request with this idempotency key is still in progress")
+			return
+		}
+		defer s.release(slot)
+
+		stored, err := s.lookup(r, key)
+		switch {
+		case err == nil:
+			if stored.RequestHash != requestHash {
+				writeError(w, http.StatusUnprocessableEntity, "idempotency key was already used with a different request")
+				return
+			}
+			replayResponse(w, stored)
+			return
+		case err != sql.ErrNoRows:
+			writeError(w, http.StatusInternalServerError, "failed to check idempotency key")
+			return
+		}
+
+		capture := &cappedBuffer{limit: maxIdempotentResponseBytes}
+		ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+		ww.Tee(capture)
+		next.ServeHTTP(ww, r)
+
+		status := ww.Status()
+		if status == 0 {
+			status = http.StatusOK
+		}
+		// Only successes are recorded: a request rejected for bad input, a
+		// missing token or a conflict may succeed when retried, and so may
+		// one that hit a server error.
+		if status < http.StatusOK || status >= http.StatusMultipleChoices || capture.overflow {
+			return
+		}
+		if err := s.store(r, key, storedResponse{
+			RequestHash: requestHash,
+			Status:      status,
+			ContentType: ww.Header().Get("Content-Type"),
+			Body:        capture.Bytes(),
+		}); err != nil {
+			s.logger.Warn("store idempotency key failed", "path", r.URL.Path, "error", err)
+		}
+	})
+}
+
+func (s *idempotencyStore) acquire(slot string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, busy := s.inFlight[slot]; busy {
+		return false
+	}
+	s.inFlight[slot] = struct{}{}
+	return true
+}
+
+func (s *idempotencyStore) release(slot string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.inFlight, slot)
+}
+
+func (s *idempotencyStore) lookup(r *http.Request, key string) (storedResponse, error) {
+	var stored storedResponse
+	err := s.db.QueryRowContext(r.Context(), `
+		SELECT request_hash, status, content_type, body
+		FROM idempotency_key
+		WHERE key = ? AND method = ? AND path = ? AND created_at > ?
+	`, key, r.Method, r.URL.Path, s.cutoff()).Scan(
+		&stored.RequestHash,
+		&stored.Status,
+		&stored.ContentType,
+		&stored.Body,
+	)
+	return stored, err
+}
+
+func (s *idempotencyStore) store(r *http.Request, key string, response storedResponse) error {
+	// The request context may already be cancelled once the handler has
+	// written its response, so expired keys are pruned and the new one is
+	// saved regardless.
+	ctx := context.WithoutCancel(r.Context())
+	if _, err := s.db.ExecContext(ctx, `DELETE FROM idempotency_key WHERE created_at <= ?`, s.cutoff()); err != nil {
+		return err
+	}
+	_, err := s.db.ExecContext(ctx, `
+		INSERT INTO idempotency_key(key, method, path, request_hash, status, content_type, body, created_at)
+		VALUES(?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(key, method, path) DO UPDATE SET
+			request_hash = excluded.request_hash,
+			status = excluded.status,
+			content_type = excluded.content_type,
+			body = excluded.body,
+			created_at = excluded.created_at
+	`, key, r.Method, r.URL.Path, response.RequestHash, response.Status, response.ContentType, response.Body, s.clock.Now().UTC().Format("2006-01-02 15:04:05"))
+	return err
+}
+
+func replayResponse(w http.ResponseWriter, stored storedResponse) {
+	if stored.ContentType != "" {
+		w.Header().Set("Content-Type", stored.ContentType)
+	}
+	w.Header().Set("Idempotent-Replayed", "true")
+	w.Header().Set("Content-Length", strconv.Itoa(len(stored.Body)))
+	w.WriteHeader(stored.Status)
+	_, _ = w.Write(stored.Body)
+}
+
+func (s *idempotencyStore) cutoff() string {
+	return s.clock.Now().Add(-idempotencyKeyTTL).UTC().Format("2006-01-02 15:04:05")
+}
+
+func hashIdempotentRequest(identity string, rawQuery string, body []byte) string {
+	hash := sha256.New()
+	hash.Write([]byte(identity + "\x00" + rawQuery + "\x00"))
+	hash.Write(body)
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// idempotencyRecordKey is the key a response is stored under: the client's
+// key together with who sent it and the query string it was sent with.
+func idempotencyRecordKey(identity string, rawQuery string, key string) string {
+	sum := sha256.Sum256([]byte(identity + "\x00" + rawQuery + "\x00" + key))
+	return hex.EncodeToString(sum[:])
+}
+
+// credentialIdentity identifies the credentials a request was sent with,
+// hashed so they are never stored. Requests carrying none share the empty
+// identity.
+func credentialIdentity(r *http.Request) string {
+	credentials := []string{
+		strings.TrimSpace(r.Header.Get("X-Admin-Token")),
+		strings.TrimSpace(r.Header.Get("Authorization")),
+		strings.TrimSpace(r.Header.Get("X-Access-Token")),
+	}
+	if cookie, err := r.Cookie(accessCookieName); err == nil {
+		credentials = append(credentials, cookie.Value)
+	}
+	if strings.Join(credentials, "") == "" {
+		return ""
+	}
+	sum := sha256.Sum256([]byte(strings.Join(credentials, "\x00")))
+	return hex.EncodeToString(sum[:])
+}
+
+func isMutatingMethod(method string) bool {
+	switch method {
+	case http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete:
+		return true
+	default:
+		return false
+	}
+}
+
+// cappedBuffer collects a response body up to limit bytes and notes when the
+// body was larger, without ever failing the write to the client.
+type cappedBuffer struct {
+	bytes.Buffer
+	limit    int
+	overflow bool
+}
+
+func (b *cappedBuffer) Write(p []byte) (int, error) {
+	if b.overflow || b.Len()+len(p) > b.limit {
+		b.overflow = true
+		b.Reset()
+		return len(p), nil
+	}
+	return b.Buffer.Write(p)
+}
@@ -0,0 +1,82 @@
+package api
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestIdempotencyReplaysResponse(t *testing.T) {
+	server := newTestServer(t, "")
+	tags := server.queryString(`SELECT COUNT(*) + 1 FROM tag`)
+
+	first := server.do(http.MethodPost, "/api/tags", `{"name":"Action"}`, idempotencyKeyHeader, "k1")
+	if first.Code >= 300 {
+		t.Fatalf("first status = %d, body %s", first.Code, first.Body.String())
+	}
+	second := server.do(http.MethodPost, "/api/tags", `{"name":"Action"}`, idempotencyKeyHeader, "k1")
+	if second.Code != first.Code || second.Body.String() != first.Body.String() {
+		t.Fatalf("replay = %d %s, want %d %s", second.Code, second.Body.String(), first.Code, first.Body.String())
+	}
+	if second.Header().Get("Idempotent-Replayed") != "true" {
+		t.Error("replayed response is not marked Idempotent-Replayed")
+	}
+	if count := server.queryString(`SELECT COUNT(*) FROM tag`); count != tags {
+		t.Fatalf("tags = %s, want %s: the request applied once", count, tags)
+	}
+}
+
+func TestIdempotencyRejectsDifferentBody(t *testing.T) {
+	server := newTestServer(t, "")
+	tags := server.queryString(`SELECT COUNT(*) + 1 FROM tag`)
+
+	if rec := server.do(http.MethodPost, "/api/tags", `{"name":"Action"}`, idempotencyKeyHeader, "k1"); rec.Code >= 300 {
+		t.Fatalf("first status = %d", rec.Code)
+	}
+	rec := server.do(http.MethodPost, "/api/tags", `{"name":"Drama"}`, idempotencyKeyHeader, "k1")
+	if rec.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("status = %d, want 422", rec.Code)
+	}
+	if count := server.queryString(`SELECT COUNT(*) FROM tag`); count != tags {
+		t.Fatalf("tags = %s, want %s", count, tags)
+	}
+}
+
+func TestIdempotencyKeyScope(t *testing.T) {
+	tests := []struct {
+		name   string
+		target string
+		header []string
+	}{
+		{name: "other credentials", target: "/api/tags", header: []string{"X-Access-Token", "other"}},
+		{name: "other query", target: "/api/tags?source=retry"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newTestServer(t, "")
+			if rec := server.do(http.MethodPost, "/api/tags", `{"name":"Action"}`, idempotencyKeyHeader, "k1"); rec.Code >= 300 {
+				t.Fatalf("first status = %d", rec.Code)
+			}
+			// The tag exists now, so a request that is run again rather than
+			// replayed fails with a conflict.
+			rec := server.do(http.MethodPost, tt.target, `{"name":"Action"}`, append(tt.header, idempotencyKeyHeader, "k1")...)
+			if rec.Code != http.StatusConflict || rec.Header().Get("Idempotent-Replayed") != "" {
+				t.Fatalf("status = %d, replayed %q; want the request run again", rec.Code, rec.Header().Get("Idempotent-Replayed"))
+			}
+		})
+	}
+}
+
+func TestIdempotencyStoresOnlySuccess(t *testing.T) {
+	server := newTestServer(t, "")
+
+	if rec := server.do(http.MethodPost, "/api/tags", `{"name":""}`, idempotencyKeyHeader, "k1"); rec.Code != http.StatusBadRequest {
+		t.Fatalf("invalid request status = %d, want 400", rec.Code)
+	}
+	rec := server.do(http.MethodPost, "/api/tags", `{"name":""}`, idempotencyKeyHeader, "k1")
+	if rec.Header().Get("Idempotent-Replayed") != "" {
+		t.Fatal("400 response was replayed")
+	}
+	if count := server.queryString(`SELECT COUNT(*) FROM idempotency_key`); count != "0" {
+		t.Fatalf("stored responses = %s, want 0", count)
+	}
+}
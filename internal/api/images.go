@@ -1,26 +1,87 @@
 package api
 
 import (
+	"bytes"
+	"context"
 	"database/sql"
+	"encoding/base64"
 	"errors"
 	"io"
+	"log/slog"
+	"mime"
 	"net/http"
+	"net/url"
 	"os"
+	"path/filepath"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/go-chi/chi/v5"
 
 	imagesvc "mynewmangaui/internal/image"
 	"mynewmangaui/internal/media"
+	"mynewmangaui/internal/metrics"
 )
 
 type imageHandler struct {
-	db     *sql.DB
-	images *imagesvc.Service
+	db             *sql.DB
+	images         *imagesvc.Service
+	inlineMaxBytes int64
+	pageMaxAge     time.Duration
+	// normalizeOrientation serves pages with an EXIF orientation upright.
+	normalizeOrientation bool
+	// checksumPages is set when scans hash pages, so a page with a stored
+	// checksum can be served with an ETag from it and cached as immutable.
+	checksumPages bool
+	decodes       *decodeLimiter
+	logger        *slog.Logger
+	slowThreshold time.Duration
+	etags         etagger
+	preloads      *chapterPreloader
 }
 
-func newImageHandler(db *sql.DB, images *imagesvc.Service) *imageHandler {
-	return &imageHandler{db: db, images: images}
+var pageServeSeconds = metrics.Default.NewHistogram(
+	"manga_page_serve_seconds",
+	"Time spent reading and sending chapter pages, by page source.",
+	"source",
+	[]float64{0.01, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10},
+)
+
+type pageDataResponse struct {
+	Mime       string `json:"mime"`
+	Width      int    `json:"width"`
+	Height     int    `json:"height"`
+	Animated   bool   `json:"animated"`
+	DataBase64 string `json:"dataBase64"`
+	// The chunk fields are only set when a single chunk was requested;
+	// DataBase64 then holds that chunk alone.
+	Chunk      *int  `json:"chunk,omitempty"`
+	ChunkCount int   `json:"chunkCount,omitempty"`
+	ChunkSize  int   `json:"chunkSize,omitempty"`
+	SizeBytes  int64 `json:"sizeBytes,omitempty"`
+}
+
+// defaultPageDataChunkSize is the chunk size, in page bytes, used when a
+// chunk is requested without chunkSize. Like every chunk size it is a
+// multiple of three, so each chunk encodes to base64 without padding and the
+// chunks concatenate to the encoding of the whole page.
+const defaultPageDataChunkSize = 192 << 10
+
+func newImageHandler(ctx context.Context, db *sql.DB, images *imagesvc.Service, inlineMaxBytes int64, pageMaxAge time.Duration, normalizeOrientation bool, checksumPages bool, decodes *decodeLimiter, slowThreshold time.Duration, etags etagger, logger *slog.Logger) *imageHandler {
+	return &imageHandler{
+		db:                   db,
+		images:               images,
+		inlineMaxBytes:       inlineMaxBytes,
+		pageMaxAge:           pageMaxAge,
+		normalizeOrientation: normalizeOrientation,
+		checksumPages:        checksumPages,
+		decodes:              decodes,
+		logger:               logger,
+		slowThreshold:        slowThreshold,
+		etags:                etags,
+		preloads:             newChapterPreloader(ctx, decodes),
+	}
 }
 
 func (h *imageHandler) getCoverThumb(w http.ResponseWriter, r *http.Request) {
@@ -30,15 +91,115 @@ func (h *imageHandler) getCoverThumb(w http.ResponseWriter, r *http.Request) {
 	}
 
 	mangaID := chi.URLParam(r, "mangaID")
-	cacheFile, err := h.images.EnsureMangaCoverThumb(r.Context(), mangaID)
+	var thumb imagesvc.Output
+	if cacheFile, ok := h.images.CachedMangaCoverThumb(r.Context(), mangaID); ok {
+		thumb.Path = cacheFile
+	} else {
+		release, ok := h.decodes.acquireOrReject(w)
+		if !ok {
+			return
+		}
+		defer release()
+
+		var err error
+		thumb, err = h.images.EnsureMangaCoverThumb(r.Context(), mangaID)
+		if err != nil {
+			writeError(w, http.StatusNotFound, "cover thumbnail not available")
+			return
+		}
+	}
+	h.serveThumb(w, r, thumb, "cover thumbnail not available")
+}
+
+// getChapterThumb serves the thumbnail of a chapter's representative page.
+func (h *imageHandler) getChapterThumb(w http.ResponseWriter, r *http.Request) {
+	if h.images == nil {
+		writeError(w, http.StatusInternalServerError, "image service not initialized")
+		return
+	}
+
+	chapterID := chi.URLParam(r, "chapterID")
+	var thumb imagesvc.Output
+	if cacheFile, ok := h.images.CachedChapterThumb(r.Context(), chapterID); ok {
+		thumb.Path = cacheFile
+	} else {
+		release, ok := h.decodes.acquireOrReject(w)
+		if !ok {
+			return
+		}
+		defer release()
+
+		var err error
+		thumb, err = h.images.EnsureChapterThumb(r.Context(), chapterID)
+		if errors.Is(err, imagesvc.ErrNoPages) {
+			writeError(w, http.StatusNotFound, "chapter has no pages")
+			return
+		}
+		if err != nil {
+			writeError(w, http.StatusNotFound, "chapter thumbnail not available")
+			return
+		}
+	}
+	h.serveThumb(w, r, thumb, "chapter thumbnail not available")
+}
+
+// getPageThumb serves the thumbnail of a single page.
+func (h *imageHandler) getPageThumb(w http.ResponseWriter, r *http.Request) {
+	if h.images == nil {
+		writeError(w, http.StatusInternalServerError, "image service not initialized")
+		return
+	}
+
+	chapterID := chi.URLParam(r, "chapterID")
+	pageIndex, err := strconv.Atoi(chi.URLParam(r, "pageIndex"))
+	if err != nil || pageIndex < 0 {
+		writeError(w, http.StatusBadRequest, "invalid page index")
+		return
+	}
+	var thumb imagesvc.Output
+	if cacheFile, ok := h.images.CachedPageThumb(r.Context(), chapterID, pageIndex); ok {
+		thumb.Path = cacheFile
+	} else {
+		release, ok := h.decodes.acquireOrReject(w)
+		if !ok {
+			return
+		}
+		defer release()
+
+		thumb, err = h.images.EnsurePageThumb(r.Context(), chapterID, pageIndex)
+		if errors.Is(err, sql.ErrNoRows) {
+			writeError(w, http.StatusNotFound, "page not found")
+			return
+		}
+		if err != nil {
+			writeError(w, http.StatusNotFound, "page thumbnail not available")
+			return
+		}
+	}
+	h.serveThumb(w, r, thumb, "page thumbnail not available")
+}
+
+// serveThumb sends a cached thumbnail file, or one generated in memory
+// because the cache was not writable; the latter is not cached by clients
+// either, so they come back once the cache works again.
+func (h *imageHandler) serveThumb(w http.ResponseWriter, r *http.Request, thumb imagesvc.Output, notAvailable string) {
+	if thumb.Path == "" {
+		w.Header().Set("Content-Type", thumb.Mime)
+		w.Header().Set("Cache-Control", "no-store")
+		http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(thumb.Data))
+		return
+	}
+
+	etag, err := h.etags.file(thumb.Path)
 	if err != nil {
-		writeError(w, http.StatusNotFound, "cover thumbnail not available")
+		writeError(w, http.StatusNotFound, notAvailable)
 		return
 	}
 
-	w.Header().Set("Content-Type", "image/jpeg")
+	w.Header().Set("Content-Type", mime.TypeByExtension(filepath.Ext(thumb.Path)))
 	w.Header().Set("Cache-Control", "public, max-age=3600")
-	http.ServeFile(w, r, cacheFile)
+	w.Header().Set("ETag", etag)
+	http.ServeFile(w, r, thumb.Path)
 }
 
 func (h *imageHandler) getChapterPage(w http.ResponseWriter, r *http.Request) {
@@ -49,13 +210,20 @@ func (h *imageHandler) getChapterPage(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	var pageID string
 	var pathRef string
 	var mime string
+	var sizeBytes int64
+	var orientation int
+	var animated bool
+	var chapterUpdatedAt string
+	var checksum string
 	if err := h.db.QueryRowContext(r.Context(), `
-		SELECT path, mime
-		FROM page
-		WHERE chapter_id = ? AND page_index = ?
-	`, chapterID, pageIndex).Scan(&pathRef, &mime); err != nil {
+		SELECT p.id, p.path, p.mime, COALESCE(p.size_bytes, 0), p.orientation, p.is_animated, c.updated_at, COALESCE(p.checksum, '')
+		FROM page p
+		JOIN chapter c ON c.id = p.chapter_id
+		WHERE p.chapter_id = ? AND p.page_index = ?
+	`, chapterID, pageIndex).Scan(&pageID, &pathRef, &mime, &sizeBytes, &orientation, &animated, &chapterUpdatedAt, &checksum); err != nil {
 		if err == sql.ErrNoRows {
 			writeError(w, http.StatusNotFound, "page not found")
 			return
@@ -70,14 +238,74 @@ func (h *imageHandler) getChapterPage(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	w.Header().Set("Content-Type", mime)
-	w.Header().Set("Cache-Control", "public, max-age=3600")
+	// A page hashed by the scan keeps its content for as long as it keeps
+	// its checksum, so its ETag comes from the checksum and clients need
+	// not revalidate it.
+	immutable := h.checksumPages && checksum != ""
+	etag := checksumPageETag(pageID, checksum)
+	if !immutable {
+		etag, err = h.etags.page(r.Context(), pageID, pathRef, sizeBytes, chapterUpdatedAt)
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				writeError(w, http.StatusNotFound, "page source missing")
+				return
+			}
+			writeError(w, http.StatusInternalServerError, "failed to compute page etag")
+			return
+		}
+	}
+	start := time.Now()
+	defer func() {
+		h.observePageServe(ref, sizeBytes, time.Since(start))
+	}()
+
+	// Transcoding and re-encoding upright keep only the first frame, so
+	// animated pages are always served as stored.
+	if h.images.Transcodes(mime) && !animated {
+		w.Header().Add("Vary", "Accept")
+		for _, format := range acceptedPageFormats(r.Header.Get("Accept"), h.images.PageFormats()) {
+			if h.serveTranscodedPage(w, r, pathRef, mime, orientation, etag, format) {
+				return
+			}
+		}
+	}
+
+	if h.normalizeOrientation && !animated && orientation > media.OrientationNormal && h.serveUprightPage(w, r, pathRef, etag) {
+		return
+	}
 
 	if ref.Kind == "file" {
-		http.ServeFile(w, r, ref.Path)
+		file, info, err := media.OpenFile(ref.Path)
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				writeError(w, http.StatusNotFound, "page source missing")
+				return
+			}
+			writeError(w, http.StatusInternalServerError, "failed to open page source")
+			return
+		}
+		defer file.Close()
+		// ServeContent answers If-None-Match from the ETag and range
+		// requests from the file, which seeks in the storage.
+		h.setPageCacheHeaders(w, mime, etag, immutable)
+		http.ServeContent(w, r, "", info.ModTime(), file)
 		return
 	}
 
+	if etagMatches(r.Header.Get("If-None-Match"), etag) {
+		h.setPageCacheHeaders(w, mime, etag, immutable)
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	if media.NeedsRender(pathRef) {
+		release, ok := h.decodes.acquireOrReject(w)
+		if !ok {
+			return
+		}
+		defer release()
+	}
+
 	rc, modifiedAt, err := media.Open(pathRef)
 	if err != nil {
 		if errors.Is(err, os.ErrNotExist) {
@@ -89,8 +317,400 @@ func (h *imageHandler) getChapterPage(w http.ResponseWriter, r *http.Request) {
 	}
 	defer rc.Close()
 
+	h.setPageCacheHeaders(w, mime, etag, immutable)
 	if !modifiedAt.IsZero() {
 		w.Header().Set("Last-Modified", modifiedAt.UTC().Format(http.TimeFormat))
 	}
-	_, _ = io.Copy(w, rc)
+	_, _ = io.Copy(w, media.ContextReader(r.Context(), rc))
+}
+
+// getMangaPage addresses a page by its position across the whole manga,
+// for readers that scroll through chapters continuously. It finds the
+// chapter from the cumulative page counts of the chapters in reading order
+// and redirects to that chapter's page URL, so each page keeps one cached
+// URL. The redirect itself is not cached since a rescan can move pages.
+func (h *imageHandler) getMangaPage(w http.ResponseWriter, r *http.Request) {
+	mangaID := chi.URLParam(r, "mangaID")
+	globalIndex, err := strconv.Atoi(chi.URLParam(r, "globalIndex"))
+	if err != nil || globalIndex < 0 {
+		writeError(w, http.StatusBadRequest, "invalid page index")
+		return
+	}
+
+	var chapterID string
+	var offset int
+	err = h.db.QueryRowContext(r.Context(), `
+		SELECT id, ? - (end_offset - page_count)
+		FROM (
+			SELECT id, page_count,
+				SUM(page_count) OVER (ORDER BY chapter_number ASC, title ASC, id ASC) AS end_offset
+			FROM chapter
+			WHERE manga_id = ?
+		)
+		WHERE end_offset > ?
+		ORDER BY end_offset ASC
+		LIMIT 1
+	`, globalIndex, mangaID, globalIndex).Scan(&chapterID, &offset)
+	if err == sql.ErrNoRows {
+		var exists int
+		if err := h.db.QueryRowContext(r.Context(), `SELECT 1 FROM manga WHERE id = ?`, mangaID).Scan(&exists); err == sql.ErrNoRows {
+			writeError(w, http.StatusNotFound, "manga not found")
+			return
+		}
+		writeError(w, http.StatusNotFound, "page not found")
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to locate page")
+		return
+	}
+
+	// Page indexes can have gaps, so the page is found by position.
+	var pageIndex int
+	if err := h.db.QueryRowContext(r.Context(), `
+		SELECT page_index FROM page WHERE chapter_id = ? ORDER BY page_index ASC LIMIT 1 OFFSET ?
+	`, chapterID, offset).Scan(&pageIndex); err != nil {
+		if err == sql.ErrNoRows {
+			writeError(w, http.StatusNotFound, "page not found")
+			return
+		}
+		writeError(w, http.StatusInternalServerError, "failed to locate page")
+		return
+	}
+
+	target := "/api/images/chapters/" + url.PathEscape(chapterID) + "/pages/" + strconv.Itoa(pageIndex)
+	if r.URL.RawQuery != "" {
+		target += "?" + r.URL.RawQuery
+	}
+	w.Header().Set("Cache-Control", "no-cache")
+	http.Redirect(w, r, target, http.StatusFound)
+}
+
+// serveTranscodedPage answers with the page converted to format, reusing a
+// cached conversion when there is one. It reports false, leaving the
+// response untouched, when the original should be served instead: no decode
+// slot is free or the encoder failed. A client that went away mid-transcode
+// gets nothing at all.
+//
+// The conversion is always complete before anything is sent, in the cache
+// or in memory, so the response has a known length and range requests,
+// including If-Range against the variant ETag, work as for originals.
+func (h *imageHandler) serveTranscodedPage(w http.ResponseWriter, r *http.Request, pathRef string, sourceMime string, orientation int, etag string, format string) bool {
+	mime := imagesvc.PageFormatMimes[format]
+	variantETag := strings.TrimSuffix(etag, `"`) + "-" + format + `"`
+	if etagMatches(r.Header.Get("If-None-Match"), variantETag) {
+		h.setPageCacheHeaders(w, mime, variantETag, false)
+		w.WriteHeader(http.StatusNotModified)
+		return true
+	}
+
+	var page imagesvc.Output
+	key := transcodeKey(etag, orientation)
+	if cacheFile, ok := h.images.CachedPage(key, format); ok {
+		page.Path = cacheFile
+	} else {
+		release, ok := h.decodes.tryAcquire()
+		if !ok {
+			return false
+		}
+		defer release()
+
+		var err error
+		page, err = h.images.TranscodePage(r.Context(), pathRef, sourceMime, orientation, key, format)
+		if err != nil {
+			if r.Context().Err() != nil {
+				return true
+			}
+			if h.logger != nil {
+				h.logger.Warn("page transcode failed", "path", pathRef, "format", format, "error", err)
+			}
+			return false
+		}
+	}
+
+	h.setPageCacheHeaders(w, mime, variantETag, false)
+	if page.Path == "" {
+		http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(page.Data))
+		return true
+	}
+	http.ServeFile(w, r, page.Path)
+	return true
+}
+
+// transcodeKey is the cache key of a page's transcodes.
+func transcodeKey(etag string, orientation int) string {
+	if orientation > media.OrientationNormal {
+		// Conversions made before orientation was known kept the page as
+		// stored, so upright ones are cached apart from them.
+		return etag + "|upright"
+	}
+	return etag
+}
+
+// serveUprightPage answers with the page re-encoded as a JPEG with its EXIF
+// orientation applied, reusing a cached copy when there is one. Like
+// serveTranscodedPage it reports false when the original should be served
+// instead.
+func (h *imageHandler) serveUprightPage(w http.ResponseWriter, r *http.Request, pathRef string, etag string) bool {
+	variantETag := strings.TrimSuffix(etag, `"`) + `-upright"`
+	if etagMatches(r.Header.Get("If-None-Match"), variantETag) {
+		h.setPageCacheHeaders(w, "image/jpeg", variantETag, false)
+		w.WriteHeader(http.StatusNotModified)
+		return true
+	}
+
+	var page imagesvc.Output
+	if cacheFile, ok := h.images.CachedUprightPage(etag); ok {
+		page.Path = cacheFile
+	} else {
+		release, ok := h.decodes.tryAcquire()
+		if !ok {
+			return false
+		}
+		defer release()
+
+		var err error
+		page, err = h.images.UprightPage(r.Context(), pathRef, etag)
+		if err != nil {
+			if r.Context().Err() != nil {
+				return true
+			}
+			if h.logger != nil {
+				h.logger.Warn("page orientation fix failed", "path", pathRef, "error", err)
+			}
+			return false
+		}
+	}
+
+	h.setPageCacheHeaders(w, "image/jpeg", variantETag, false)
+	if page.Path == "" {
+		http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(page.Data))
+		return true
+	}
+	http.ServeFile(w, r, page.Path)
+	return true
+}
+
+// acceptedPageFormats filters the configured formats, keeping their order,
+// down to those the Accept header lists without q=0. Wildcards are ignored:
+// browsers send image/* even when they cannot decode every format.
+func acceptedPageFormats(accept string, formats []string) []string {
+	accepted := make(map[string]bool)
+	for _, part := range strings.Split(accept, ",") {
+		fields := strings.Split(part, ";")
+		mediaType := strings.ToLower(strings.TrimSpace(fields[0]))
+		allowed := true
+		for _, param := range fields[1:] {
+			key, value, _ := strings.Cut(strings.TrimSpace(param), "=")
+			if strings.EqualFold(key, "q") {
+				if q, err := strconv.ParseFloat(value, 64); err == nil && q <= 0 {
+					allowed = false
+				}
+			}
+		}
+		if allowed {
+			accepted[mediaType] = true
+		}
+	}
+
+	matches := make([]string, 0, len(formats))
+	for _, format := range formats {
+		if accepted[imagesvc.PageFormatMimes[format]] {
+			matches = append(matches, format)
+		}
+	}
+	return matches
+}
+
+// setPageCacheHeaders marks a page response as cacheable. Pages only change
+// when a rescan finds different files, which also changes their ETag.
+// immutable is for pages served as stored under a checksum ETag; converted
+// variants are not marked, as their bytes follow the image settings too.
+func (h *imageHandler) setPageCacheHeaders(w http.ResponseWriter, mime string, etag string, immutable bool) {
+	w.Header().Set("Content-Type", mime)
+	w.Header().Set("ETag", etag)
+	if h.pageMaxAge <= 0 {
+		w.Header().Set("Cache-Control", "no-cache")
+		return
+	}
+	cacheControl := "public, max-age=" + strconv.Itoa(int(h.pageMaxAge.Seconds()))
+	if immutable {
+		cacheControl += ", immutable"
+	}
+	w.Header().Set("Cache-Control", cacheControl)
+}
+
+// getChapterPageData returns a page inline as base64 for clients that need
+// the image inside a JSON payload. Pages above the configured cap are
+// rejected since base64 grows them by a third.
+//
+// With ?chunk=K the page is delivered piecewise instead: the response holds
+// the K-th chunk of chunkSize page bytes and the chunk count, and only the
+// chunk has to fit under the cap.
+func (h *imageHandler) getChapterPageData(w http.ResponseWriter, r *http.Request) {
+	chapterID := chi.URLParam(r, "chapterID")
+	pageIndex, err := strconv.Atoi(chi.URLParam(r, "pageIndex"))
+	if err != nil || pageIndex < 0 {
+		writeError(w, http.StatusBadRequest, "invalid page index")
+		return
+	}
+	chunk, chunkSize, chunked, err := h.parsePageDataChunk(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	var pathRef string
+	var sizeBytes int64
+	var response pageDataResponse
+	if err := h.db.QueryRowContext(r.Context(), `
+		SELECT path, COALESCE(mime, ''), COALESCE(width, 0), COALESCE(height, 0), COALESCE(size_bytes, 0), is_animated
+		FROM page
+		WHERE chapter_id = ? AND page_index = ?
+	`, chapterID, pageIndex).Scan(&pathRef, &response.Mime, &response.Width, &response.Height, &sizeBytes, &response.Animated); err != nil {
+		if err == sql.ErrNoRows {
+			writeError(w, http.StatusNotFound, "page not found")
+			return
+		}
+		writeError(w, http.StatusInternalServerError, "failed to load page")
+		return
+	}
+	if !chunked && sizeBytes > h.inlineMaxBytes {
+		writeError(w, http.StatusRequestEntityTooLarge, "page exceeds inline size limit")
+		return
+	}
+	if chunked && sizeBytes > 0 {
+		chunkCount := int((sizeBytes + int64(chunkSize) - 1) / int64(chunkSize))
+		if chunk >= chunkCount {
+			writeError(w, http.StatusBadRequest, "chunk out of range")
+			return
+		}
+	}
+
+	if media.NeedsRender(pathRef) {
+		release, ok := h.decodes.acquireOrReject(w)
+		if !ok {
+			return
+		}
+		defer release()
+	}
+
+	rc, _, err := media.Open(pathRef)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			writeError(w, http.StatusNotFound, "page source missing")
+			return
+		}
+		writeError(w, http.StatusInternalServerError, "failed to open page source")
+		return
+	}
+	defer rc.Close()
+
+	if chunked {
+		h.writePageDataChunk(w, r, rc, response, sizeBytes, chunk, chunkSize)
+		return
+	}
+
+	data, err := io.ReadAll(io.LimitReader(media.ContextReader(r.Context(), rc), h.inlineMaxBytes+1))
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to read page source")
+		return
+	}
+	if int64(len(data)) > h.inlineMaxBytes {
+		writeError(w, http.StatusRequestEntityTooLarge, "page exceeds inline size limit")
+		return
+	}
+
+	response.DataBase64 = base64.StdEncoding.EncodeToString(data)
+	writeJSON(w, http.StatusOK, response)
+}
+
+// parsePageDataChunk reads the chunk and chunkSize parameters. chunkSize is
+// rounded down to a multiple of three and capped by the inline size limit.
+func (h *imageHandler) parsePageDataChunk(r *http.Request) (int, int, bool, error) {
+	query := r.URL.Query()
+	if !query.Has("chunk") {
+		if query.Has("chunkSize") {
+			return 0, 0, false, errors.New("chunkSize requires chunk")
+		}
+		return 0, 0, false, nil
+	}
+
+	chunk, err := strconv.Atoi(query.Get("chunk"))
+	if err != nil || chunk < 0 {
+		return 0, 0, false, errors.New("invalid chunk")
+	}
+	chunkSize := defaultPageDataChunkSize
+	if query.Has("chunkSize") {
+		if chunkSize, err = strconv.Atoi(query.Get("chunkSize")); err != nil || chunkSize <= 0 {
+			return 0, 0, false, errors.New("invalid chunkSize")
+		}
+	}
+	chunkSize = int(min(int64(chunkSize), h.inlineMaxBytes))
+	chunkSize -= chunkSize % 3
+	if chunkSize <= 0 {
+		return 0, 0, false, errors.New("chunkSize must be at least 3 bytes")
+	}
+	return chunk, chunkSize, true, nil
+}
+
+// writePageDataChunk answers with one chunk of the page read from rc. The
+// stored size gives the chunk count; pages without one, such as rendered
+// PDF pages, are measured by reading them to the end.
+func (h *imageHandler) writePageDataChunk(w http.ResponseWriter, r *http.Request, rc io.Reader, response pageDataResponse, sizeBytes int64, chunk int, chunkSize int) {
+	reader := media.ContextReader(r.Context(), rc)
+	if _, err := io.CopyN(io.Discard, reader, int64(chunk)*int64(chunkSize)); err != nil {
+		if errors.Is(err, io.EOF) {
+			writeError(w, http.StatusBadRequest, "chunk out of range")
+			return
+		}
+		writeError(w, http.StatusInternalServerError, "failed to read page source")
+		return
+	}
+	data, err := io.ReadAll(io.LimitReader(reader, int64(chunkSize)))
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to read page source")
+		return
+	}
+	if len(data) == 0 && chunk > 0 {
+		writeError(w, http.StatusBadRequest, "chunk out of range")
+		return
+	}
+	if sizeBytes <= 0 {
+		rest, err := io.Copy(io.Discard, reader)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, "failed to read page source")
+			return
+		}
+		sizeBytes = int64(chunk)*int64(chunkSize) + int64(len(data)) + rest
+	}
+
+	response.DataBase64 = base64.StdEncoding.EncodeToString(data)
+	response.Chunk = &chunk
+	response.ChunkCount = max(int((sizeBytes+int64(chunkSize)-1)/int64(chunkSize)), 1)
+	response.ChunkSize = chunkSize
+	response.SizeBytes = sizeBytes
+	writeJSON(w, http.StatusOK, response)
+}
+
+// observePageServe records how long a page took to serve, warning when it is
+// slower than the configured threshold so slow storage can be spotted.
+func (h *imageHandler) observePageServe(ref media.Ref, sizeBytes int64, elapsed time.Duration) {
+	pageServeSeconds.Observe(ref.Kind, elapsed.Seconds())
+	if h.logger == nil {
+		return
+	}
+
+	attrs := []any{
+		"path", ref.Path,
+		"entry", ref.EntryPath,
+		"source", ref.Kind,
+		"size_bytes", sizeBytes,
+		"duration_ms", elapsed.Milliseconds(),
+	}
+	if h.slowThreshold > 0 && elapsed >= h.slowThreshold {
+		h.logger.Warn("slow page read", attrs...)
+		return
+	}
+	h.logger.Debug("page read", attrs...)
 }
@@ -0,0 +1,613 @@
+package api
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"image"
+	"image/png"
+	"log/slog"
+	"maps"
+	"math/rand/v2"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"mynewmangaui/internal/config"
+	imagesvc "mynewmangaui/internal/image"
+	"mynewmangaui/internal/metrics"
+	scansvc "mynewmangaui/internal/scan"
+)
+
+// fakeEncoder writes an encoder script standing in for cwebp or avifenc:
+// it ignores its input and writes size bytes to the output path, the last
+// argument of both.
+func fakeEncoder(t *testing.T, size int) string {
+	t.Helper()
+	return writeEncoderScript(t, "for output; do :; done\nyes webp | head -c "+strconv.Itoa(size)+" > \"$output\"\n")
+}
+
+// failingEncoder writes an encoder script that always fails.
+func failingEncoder(t *testing.T) string {
+	t.Helper()
+	return writeEncoderScript(t, "exit 1\n")
+}
+
+func writeEncoderScript(t *testing.T, body string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "encoder")
+	if err := os.WriteFile(path, []byte("#!/bin/sh\n"+body), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// TestTranscodedPageRange checks range requests against a transcoded
+// page, both served from the transcode cache and, when the cache cannot be
+// written, from memory. Strong ETags are used since If-Range never matches
+// weak ones.
+func TestTranscodedPageRange(t *testing.T) {
+	const size = 300
+	server := newTestServer(t, "")
+	writeChapter(t, server.root, "Alpha", "Chapter 1", 1)
+	server.scan()
+	chapterID := server.queryString(`SELECT id FROM chapter`)
+	encoder := fakeEncoder(t, size)
+
+	unwritable := filepath.Join(t.TempDir(), "file")
+	if err := os.WriteFile(unwritable, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	caches := map[string]string{
+		"cached":    filepath.Join(t.TempDir(), "cache"),
+		"in memory": filepath.Join(unwritable, "cache"),
+	}
+	for name, cachePath := range caches {
+		t.Run(name, func(t *testing.T) {
+			images := imagesvc.NewService(server.db, cachePath, testLogger())
+			images.ConfigurePageFormats([]string{"webp"}, map[string]string{"webp": encoder})
+			handler := newImageHandler(context.Background(), server.db, images, 1<<20, time.Hour, false, false, newDecodeLimiter(2), 0, newETagger(server.db, config.ETagStrong), testLogger())
+			router := chi.NewRouter()
+			router.Get("/api/images/chapters/{chapterID}/pages/{pageIndex}", handler.getChapterPage)
+			getPage := func(header ...string) *httptest.ResponseRecorder {
+				req := httptest.NewRequest(http.MethodGet, "/api/images/chapters/"+chapterID+"/pages/0", nil)
+				req.Header.Set("Accept", "image/webp,image/*")
+				for i := 0; i+1 < len(header); i += 2 {
+					req.Header.Set(header[i], header[i+1])
+				}
+				rec := httptest.NewRecorder()
+				router.ServeHTTP(rec, req)
+				return rec
+			}
+
+			// A range as the very first request, before anything is cached.
+			rec := getPage("Range", "bytes=0-99")
+			if rec.Code != http.StatusPartialContent || rec.Header().Get("Content-Range") != "bytes 0-99/300" || rec.Body.Len() != 100 {
+				t.Fatalf("first range = %d, Content-Range %q, %d bytes", rec.Code, rec.Header().Get("Content-Range"), rec.Body.Len())
+			}
+
+			full := getPage()
+			if full.Code != http.StatusOK || full.Header().Get("Content-Type") != "image/webp" || full.Body.Len() != size {
+				t.Fatalf("full page = %d %q, %d bytes", full.Code, full.Header().Get("Content-Type"), full.Body.Len())
+			}
+			etag := full.Header().Get("ETag")
+			page := full.Body.Bytes()
+
+			tests := []struct {
+				name         string
+				header       []string
+				status       int
+				body         []byte
+				contentRange string
+			}{
+				{name: "range", header: []string{"Range", "bytes=0-99"}, status: http.StatusPartialContent, body: page[:100], contentRange: "bytes 0-99/300"},
+				{name: "suffix range", header: []string{"Range", "bytes=-50"}, status: http.StatusPartialContent, body: page[size-50:], contentRange: "bytes 250-299/300"},
+				{name: "if-range match", header: []string{"Range", "bytes=0-99", "If-Range", etag}, status: http.StatusPartialContent, body: page[:100], contentRange: "bytes 0-99/300"},
+				{name: "if-range stale", header: []string{"Range", "bytes=0-99", "If-Range", `"stale"`}, status: http.StatusOK, body: page},
+				{name: "unsatisfiable", header: []string{"Range", "bytes=500-"}, status: http.StatusRequestedRangeNotSatisfiable, contentRange: "bytes */300"},
+			}
+			for _, tt := range tests {
+				t.Run(tt.name, func(t *testing.T) {
+					rec := getPage(tt.header...)
+					if rec.Code != tt.status {
+						t.Fatalf("status = %d, want %d", rec.Code, tt.status)
+					}
+					if got := rec.Header().Get("Content-Range"); got != tt.contentRange {
+						t.Errorf("Content-Range = %q, want %q", got, tt.contentRange)
+					}
+					if tt.body != nil && !bytes.Equal(rec.Body.Bytes(), tt.body) {
+						t.Errorf("body = %d bytes, want %d", rec.Body.Len(), len(tt.body))
+					}
+				})
+			}
+
+			cached, _ := filepath.Glob(filepath.Join(cachePath, "pages", "*.webp"))
+			want := 0
+			if name == "cached" {
+				want = 1
+			}
+			if len(cached) != want {
+				t.Errorf("cached transcodes = %v, want %d", cached, want)
+			}
+		})
+	}
+}
+
+// writeCBZ writes an archive holding a PNG page per name.
+func writeCBZ(t *testing.T, path string, names ...string) {
+	t.Helper()
+	file, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	archive := zip.NewWriter(file)
+	for i, name := range names {
+		entry, err := archive.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		page := filepath.Join(t.TempDir(), "page.png")
+		writePNG(t, page, 8+i, 12)
+		raw, err := os.ReadFile(page)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := entry.Write(raw); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := archive.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// writeNoisePNG writes a PNG of random pixels, which compresses poorly, so
+// its size in bytes is close to width * height * 4.
+func writeNoisePNG(t *testing.T, path string, width int, height int) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	img := image.NewNRGBA(image.Rect(0, 0, width, height))
+	random := rand.New(rand.NewPCG(1, 2))
+	for i := range img.Pix {
+		img.Pix[i] = uint8(random.Uint32())
+	}
+	file, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	if err := png.Encode(file, img); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestChapterPageData(t *testing.T) {
+	const limit = 1024
+	server := newTestServer(t, `{"server":{"inlinePageMaxBytes":`+strconv.Itoa(limit)+`}}`)
+	small := writeChapter(t, server.root, "Alpha", "Chapter 1", 1)
+	writeNoisePNG(t, filepath.Join(server.root, "Alpha", "Chapter 2", "a.png"), 64, 64)
+	writeCBZ(t, filepath.Join(server.root, "Beta.cbz"), "01.png", "02.png")
+	server.scan()
+
+	chapterID := func(manga string, chapter string) string {
+		return server.queryString(`
+			SELECT c.id FROM chapter c JOIN manga m ON m.id = c.manga_id
+			WHERE m.title = ? AND c.title = ?
+		`, manga, chapter)
+	}
+
+	t.Run("small page", func(t *testing.T) {
+		rec := server.do(http.MethodGet, "/api/chapters/"+chapterID("Alpha", "Chapter 1")+"/pages/0/data", "")
+		if rec.Code != http.StatusOK {
+			t.Fatalf("status = %d, body %s", rec.Code, rec.Body.String())
+		}
+		page := decodeJSON[pageDataResponse](t, rec)
+		want, err := os.ReadFile(filepath.Join(small, "a.png"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, err := base64.StdEncoding.DecodeString(page.DataBase64)
+		if err != nil || !bytes.Equal(data, want) {
+			t.Fatalf("decoded page = %d bytes, %v; want the %d stored bytes", len(data), err, len(want))
+		}
+		if page.Mime != "image/png" || page.Width != 8 || page.Height != 12 {
+			t.Errorf("page = %s %dx%d, want image/png 8x12", page.Mime, page.Width, page.Height)
+		}
+	})
+
+	t.Run("archive page", func(t *testing.T) {
+		rec := server.do(http.MethodGet, "/api/chapters/"+chapterID("Beta", "Beta")+"/pages/1/data", "")
+		if rec.Code != http.StatusOK {
+			t.Fatalf("status = %d, body %s", rec.Code, rec.Body.String())
+		}
+		page := decodeJSON[pageDataResponse](t, rec)
+		data, err := base64.StdEncoding.DecodeString(page.DataBase64)
+		if err != nil {
+			t.Fatal(err)
+		}
+		img, err := png.Decode(bytes.NewReader(data))
+		if err != nil || img.Bounds().Dx() != 9 {
+			t.Fatalf("archive page = %v, %v; want the second, 9 pixel wide page", img, err)
+		}
+	})
+
+	t.Run("oversize page", func(t *testing.T) {
+		var size int64
+		if err := server.db.QueryRow(`SELECT size_bytes FROM page WHERE chapter_id = ?`, chapterID("Alpha", "Chapter 2")).Scan(&size); err != nil || size <= limit {
+			t.Fatalf("large page is %d bytes, %v; want more than %d", size, err, limit)
+		}
+		rec := server.do(http.MethodGet, "/api/chapters/"+chapterID("Alpha", "Chapter 2")+"/pages/0/data", "")
+		if rec.Code != http.StatusRequestEntityTooLarge {
+			t.Fatalf("status = %d, want 413", rec.Code)
+		}
+	})
+
+	t.Run("missing page", func(t *testing.T) {
+		if rec := server.do(http.MethodGet, "/api/chapters/"+chapterID("Alpha", "Chapter 1")+"/pages/5/data", ""); rec.Code != http.StatusNotFound {
+			t.Fatalf("status = %d, want 404", rec.Code)
+		}
+	})
+}
+
+// metricCount returns the value of an integer metric series, such as
+// name{label="value"}, from the metrics endpoint; zero when it is not
+// rendered yet.
+func metricCount(t *testing.T, series string) int {
+	t.Helper()
+	var rendered bytes.Buffer
+	metrics.Default.Render(&rendered)
+	for _, line := range strings.Split(rendered.String(), "\n") {
+		if count, ok := strings.CutPrefix(line, series+" "); ok {
+			n, err := strconv.Atoi(count)
+			if err != nil {
+				t.Fatalf("metric line %q: %v", line, err)
+			}
+			return n
+		}
+	}
+	return 0
+}
+
+func TestSlowPageReadWarning(t *testing.T) {
+	server := newTestServer(t, "")
+	writeCBZ(t, filepath.Join(server.root, "Alpha.cbz"), "01.png")
+	server.scan()
+	chapterID := server.queryString(`SELECT id FROM chapter`)
+
+	tests := []struct {
+		name      string
+		threshold time.Duration
+		level     string
+		message   string
+	}{
+		{name: "above threshold", threshold: time.Nanosecond, level: "WARN", message: "slow page read"},
+		{name: "below threshold", threshold: time.Hour, level: "DEBUG", message: "page read"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var logs bytes.Buffer
+			logger := slog.New(slog.NewJSONHandler(&logs, &slog.HandlerOptions{Level: slog.LevelDebug}))
+			images := imagesvc.NewService(server.db, server.config.Storage.CachePath, testLogger())
+			handler := newImageHandler(context.Background(), server.db, images, 1<<20, time.Hour, false, false, newDecodeLimiter(2), tt.threshold, newETagger(server.db, config.ETagWeak), logger)
+			router := chi.NewRouter()
+			router.Get("/api/images/chapters/{chapterID}/pages/{pageIndex}", handler.getChapterPage)
+
+			served := metricCount(t, `manga_page_serve_seconds_count{source="zip"}`)
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/images/chapters/"+chapterID+"/pages/0", nil))
+			if rec.Code != http.StatusOK {
+				t.Fatalf("status = %d, body %s", rec.Code, rec.Body.String())
+			}
+
+			var record struct {
+				Level     string `json:"level"`
+				Msg       string `json:"msg"`
+				Source    string `json:"source"`
+				Entry     string `json:"entry"`
+				SizeBytes int64  `json:"size_bytes"`
+			}
+			if err := json.Unmarshal(logs.Bytes(), &record); err != nil {
+				t.Fatalf("log %q: %v", logs.String(), err)
+			}
+			if record.Level != tt.level || record.Msg != tt.message {
+				t.Errorf("logged %s %q, want %s %q", record.Level, record.Msg, tt.level, tt.message)
+			}
+			if record.Source != "zip" || record.Entry != "01.png" || record.SizeBytes != int64(rec.Body.Len()) {
+				t.Errorf("log attributes = %+v, want the zip entry 01.png of %d bytes", record, rec.Body.Len())
+			}
+			if got := metricCount(t, `manga_page_serve_seconds_count{source="zip"}`); got != served+1 {
+				t.Errorf("zip page serves = %d, want %d", got, served+1)
+			}
+		})
+	}
+}
+
+func TestPageFormatNegotiation(t *testing.T) {
+	server := newTestServer(t, "")
+	writeChapter(t, server.root, "Alpha", "Chapter 1", 1)
+	server.scan()
+	chapterID := server.queryString(`SELECT id FROM chapter`)
+	working, failing := fakeEncoder(t, 300), failingEncoder(t)
+
+	tests := []struct {
+		name     string
+		formats  []string
+		encoders map[string]string
+		accept   string
+		want     string
+	}{
+		{name: "avif preferred", formats: []string{"avif", "webp"}, accept: "image/avif,image/webp,*/*", want: "image/avif"},
+		{name: "webp only", formats: []string{"avif", "webp"}, accept: "image/webp,*/*", want: "image/webp"},
+		{name: "avif refused", formats: []string{"avif", "webp"}, accept: "image/avif;q=0,image/webp", want: "image/webp"},
+		{name: "configured order", formats: []string{"webp", "avif"}, accept: "image/avif,image/webp", want: "image/webp"},
+		{name: "no modern format", formats: []string{"avif", "webp"}, accept: "image/*", want: "image/png"},
+		{name: "no accept header", formats: []string{"avif", "webp"}, want: "image/png"},
+		{name: "avif fails", formats: []string{"avif", "webp"}, encoders: map[string]string{"avif": failing}, accept: "image/avif,image/webp", want: "image/webp"},
+		{name: "every encoder fails", formats: []string{"avif", "webp"}, encoders: map[string]string{"avif": failing, "webp": failing}, accept: "image/avif,image/webp", want: "image/png"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			encoders := map[string]string{"avif": working, "webp": working}
+			maps.Copy(encoders, tt.encoders)
+			images := imagesvc.NewService(server.db, t.TempDir(), testLogger())
+			images.ConfigurePageFormats(tt.formats, encoders)
+			handler := newImageHandler(context.Background(), server.db, images, 1<<20, time.Hour, false, false, newDecodeLimiter(2), 0, newETagger(server.db, config.ETagWeak), testLogger())
+			router := chi.NewRouter()
+			router.Get("/api/images/chapters/{chapterID}/pages/{pageIndex}", handler.getChapterPage)
+
+			req := httptest.NewRequest(http.MethodGet, "/api/images/chapters/"+chapterID+"/pages/0", nil)
+			if tt.accept != "" {
+				req.Header.Set("Accept", tt.accept)
+			}
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, req)
+			if rec.Code != http.StatusOK || rec.Header().Get("Content-Type") != tt.want {
+				t.Fatalf("page = %d %q, want %q", rec.Code, rec.Header().Get("Content-Type"), tt.want)
+			}
+			if vary := rec.Header().Get("Vary"); vary != "Accept" {
+				t.Errorf("Vary = %q, want Accept", vary)
+			}
+		})
+	}
+}
+
+// writeGrayPNG writes a width x height grayscale PNG.
+func writeGrayPNG(t *testing.T, path string, width int, height int) {
+	t.Helper()
+	img := image.NewGray(image.Rect(0, 0, width, height))
+	for i := range img.Pix {
+		img.Pix[i] = uint8(i * 7)
+	}
+	file, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	if err := png.Encode(file, img); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestChapterThumbPicksRepresentativePage(t *testing.T) {
+	server := newTestServer(t, "")
+	// Pages are 8, 9, 10, 11 and 12 pixels wide; the first two are gray.
+	chapter := writeChapter(t, server.root, "Alpha", "Chapter 1", 5)
+	writeGrayPNG(t, filepath.Join(chapter, "a.png"), 8, 12)
+	writeGrayPNG(t, filepath.Join(chapter, "b.png"), 9, 12)
+	server.scan()
+	chapterID := server.queryString(`SELECT id FROM chapter`)
+
+	tests := []struct {
+		strategy string
+		width    int
+	}{
+		{strategy: imagesvc.ChapterThumbFirst, width: 8},
+		{strategy: imagesvc.ChapterThumbMiddle, width: 10},
+		{strategy: imagesvc.ChapterThumbFirstColor, width: 10},
+	}
+	for _, tt := range tests {
+		t.Run(tt.strategy, func(t *testing.T) {
+			cachePath := t.TempDir()
+			images := imagesvc.NewService(server.db, cachePath, testLogger())
+			images.ConfigureThumbnails(imagesvc.ThumbnailOptions{Format: "jpeg", Quality: 90, MaxDimension: 512, ChapterPage: tt.strategy})
+			handler := newImageHandler(context.Background(), server.db, images, 1<<20, time.Hour, false, false, newDecodeLimiter(2), 0, newETagger(server.db, config.ETagWeak), testLogger())
+			router := chi.NewRouter()
+			router.Get("/api/chapters/{chapterID}/thumb", handler.getChapterThumb)
+			getThumb := func() *httptest.ResponseRecorder {
+				rec := httptest.NewRecorder()
+				router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/chapters/"+chapterID+"/thumb", nil))
+				return rec
+			}
+
+			rec := getThumb()
+			if rec.Code != http.StatusOK {
+				t.Fatalf("status = %d, body %s", rec.Code, rec.Body.String())
+			}
+			thumb, _, err := image.Decode(bytes.NewReader(rec.Body.Bytes()))
+			if err != nil || thumb.Bounds().Dx() != tt.width {
+				t.Fatalf("thumbnail = %v, %v; want the %d pixel wide page", thumb, err, tt.width)
+			}
+
+			cached, ok := images.CachedChapterThumb(context.Background(), chapterID)
+			if !ok {
+				t.Fatal("thumbnail not cached")
+			}
+			if again := getThumb(); again.Header().Get("ETag") != rec.Header().Get("ETag") || !bytes.Equal(again.Body.Bytes(), rec.Body.Bytes()) {
+				t.Errorf("second request served a different thumbnail")
+			}
+			if files, _ := filepath.Glob(filepath.Join(cachePath, "chapters", "*")); len(files) != 1 || files[0] != cached {
+				t.Errorf("cached thumbnails = %v, want only %s", files, cached)
+			}
+		})
+	}
+}
+
+func TestThumbnailsServedWhenCacheUnwritable(t *testing.T) {
+	server := newTestServer(t, "")
+	writeChapter(t, server.root, "Alpha", "Chapter 1", 2)
+	server.scan()
+	mangaID := server.queryString(`SELECT id FROM manga`)
+	chapterID := server.queryString(`SELECT id FROM chapter`)
+
+	// A regular file where the cache directory should be makes every cache
+	// write fail, even for root.
+	blocker := filepath.Join(t.TempDir(), "cache")
+	if err := os.WriteFile(blocker, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	cachePath := filepath.Join(blocker, "images")
+	var logs bytes.Buffer
+	images := imagesvc.NewService(server.db, cachePath, slog.New(slog.NewTextHandler(&logs, nil)))
+	handler := newImageHandler(context.Background(), server.db, images, 1<<20, time.Hour, false, false, newDecodeLimiter(2), 0, newETagger(server.db, config.ETagWeak), testLogger())
+	router := chi.NewRouter()
+	router.Get("/api/images/covers/{mangaID}/thumb", handler.getCoverThumb)
+	router.Get("/api/chapters/{chapterID}/thumb", handler.getChapterThumb)
+	get := func(target string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, target, nil))
+		return rec
+	}
+	failures := `manga_cache_write_failures_total{kind="thumbnail"}`
+	failed := metricCount(t, failures)
+
+	for _, target := range []string{
+		"/api/images/covers/" + mangaID + "/thumb",
+		"/api/images/covers/" + mangaID + "/thumb",
+		"/api/chapters/" + chapterID + "/thumb",
+	} {
+		rec := get(target)
+		if rec.Code != http.StatusOK || rec.Header().Get("Cache-Control") != "no-store" {
+			t.Fatalf("%s = %d, Cache-Control %q; want an uncached 200", target, rec.Code, rec.Header().Get("Cache-Control"))
+		}
+		if _, _, err := image.Decode(bytes.NewReader(rec.Body.Bytes())); err != nil {
+			t.Fatalf("%s served %d bytes that do not decode: %v", target, rec.Body.Len(), err)
+		}
+	}
+	if got := metricCount(t, failures); got != failed+3 {
+		t.Errorf("cache write failures = %d, want %d", got, failed+3)
+	}
+	if warnings := strings.Count(logs.String(), "cache path not writable"); warnings != 1 {
+		t.Errorf("logged %d cache warnings, want 1:\n%s", warnings, logs.String())
+	}
+
+	// Once the cache can be written again, thumbnails are cached.
+	if err := os.Remove(blocker); err != nil {
+		t.Fatal(err)
+	}
+	rec := get("/api/images/covers/" + mangaID + "/thumb")
+	if rec.Code != http.StatusOK || rec.Header().Get("ETag") == "" {
+		t.Fatalf("cover after recovery = %d, ETag %q; want a cached 200", rec.Code, rec.Header().Get("ETag"))
+	}
+	if !strings.Contains(logs.String(), "cache path writable again") {
+		t.Errorf("recovery not logged:\n%s", logs.String())
+	}
+	if got := metricCount(t, failures); got != failed+3 {
+		t.Errorf("cache write failures after recovery = %d, want %d", got, failed+3)
+	}
+}
+
+func TestChapterPageDataChunks(t *testing.T) {
+	const limit = 1024
+	server := newTestServer(t, `{"server":{"inlinePageMaxBytes":`+strconv.Itoa(limit)+`}}`)
+	writeNoisePNG(t, filepath.Join(server.root, "Alpha", "Chapter 1", "a.png"), 32, 32)
+	writeCBZ(t, filepath.Join(server.root, "Beta.cbz"), "01.png")
+	server.scan()
+	chapterID := func(manga string) string {
+		return server.queryString(`SELECT c.id FROM chapter c JOIN manga m ON m.id = c.manga_id WHERE m.title = ?`, manga)
+	}
+	pageData := func(chapterID string, query string) *httptest.ResponseRecorder {
+		return server.do(http.MethodGet, "/api/chapters/"+chapterID+"/pages/0/data?"+query, "")
+	}
+
+	tests := []struct {
+		name      string
+		manga     string
+		path      string
+		chunkSize string
+		wantSize  int
+	}{
+		{name: "uneven size", manga: "Alpha", path: filepath.Join(server.root, "Alpha", "Chapter 1", "a.png"), chunkSize: "1000", wantSize: 999},
+		{name: "capped by the inline limit", manga: "Alpha", path: filepath.Join(server.root, "Alpha", "Chapter 1", "a.png"), chunkSize: "100000", wantSize: 1023},
+		{name: "archive page", manga: "Beta", chunkSize: "30", wantSize: 30},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var want []byte
+			if tt.path != "" {
+				var err error
+				if want, err = os.ReadFile(tt.path); err != nil {
+					t.Fatal(err)
+				}
+			}
+			var page []byte
+			chunkCount := 1
+			for chunk := 0; chunk < chunkCount; chunk++ {
+				rec := pageData(chapterID(tt.manga), "chunk="+strconv.Itoa(chunk)+"&chunkSize="+tt.chunkSize)
+				if rec.Code != http.StatusOK {
+					t.Fatalf("chunk %d: status = %d, body %s", chunk, rec.Code, rec.Body.String())
+				}
+				response := decodeJSON[pageDataResponse](t, rec)
+				if response.Chunk == nil || *response.Chunk != chunk || response.ChunkSize != tt.wantSize {
+					t.Fatalf("chunk %d = %v of size %d, want size %d", chunk, response.Chunk, response.ChunkSize, tt.wantSize)
+				}
+				// Each chunk decodes on its own, so boundaries fall on
+				// base64-safe offsets.
+				data, err := base64.StdEncoding.DecodeString(response.DataBase64)
+				if err != nil {
+					t.Fatalf("chunk %d: %v", chunk, err)
+				}
+				page = append(page, data...)
+				chunkCount = response.ChunkCount
+			}
+			if tt.path != "" && !bytes.Equal(page, want) {
+				t.Fatalf("reassembled %d bytes, want the %d stored bytes", len(page), len(want))
+			}
+			if _, err := png.Decode(bytes.NewReader(page)); err != nil {
+				t.Fatalf("reassembled page does not decode: %v", err)
+			}
+
+			if rec := pageData(chapterID(tt.manga), "chunk="+strconv.Itoa(chunkCount)+"&chunkSize="+tt.chunkSize); rec.Code != http.StatusBadRequest {
+				t.Errorf("status for chunk %d of %d = %d, want 400", chunkCount, chunkCount, rec.Code)
+			}
+		})
+	}
+
+	for _, query := range []string{"chunk=-1", "chunk=x", "chunk=0&chunkSize=0", "chunk=0&chunkSize=2", "chunkSize=300"} {
+		if rec := pageData(chapterID("Alpha"), query); rec.Code != http.StatusBadRequest {
+			t.Errorf("status for %s = %d, want 400", query, rec.Code)
+		}
+	}
+}
+
+func TestMislabeledPageContentType(t *testing.T) {
+	for sniff, want := range map[bool]string{true: "image/png", false: "image/jpeg"} {
+		t.Run(strconv.FormatBool(sniff), func(t *testing.T) {
+			server := newTestServer(t, "")
+			// A PNG saved with a .jpg extension.
+			writePNG(t, filepath.Join(server.root, "Alpha", "Chapter 1", "01.jpg"), 8, 12)
+			scanner := scansvc.NewService(server.db, []scansvc.Bookshelf{{Name: "main", Path: server.root}}, scansvc.Options{SniffMime: sniff}, testLogger())
+			if _, err := scanner.Scan(t.Context()); err != nil {
+				t.Fatal(err)
+			}
+			if got := server.queryString(`SELECT mime FROM page`); got != want {
+				t.Errorf("stored mime = %q, want %q", got, want)
+			}
+			rec := server.do(http.MethodGet, "/api/images/chapters/"+server.queryString(`SELECT id FROM chapter`)+"/pages/0", "")
+			if rec.Code != http.StatusOK || rec.Header().Get("Content-Type") != want {
+				t.Errorf("page = %d, Content-Type %q; want 200 %q", rec.Code, rec.Header().Get("Content-Type"), want)
+			}
+		})
+	}
+}
@@ -5,50 +5,93 @@ import (
 	"database/sql"
 	"encoding/hex"
 	"encoding/json"
-	"fmt"
+	"errors"
 	"net/http"
 	"path/filepath"
 	"strconv"
 	"strings"
 
+	"github.com/go-chi/chi/v5"
+
 	"mynewmangaui/internal/config"
+	"mynewmangaui/internal/store"
 )
 
-const (
-	defaultLibraryPage  = 1
-	defaultLibraryLimit = 60
-	maxLibraryLimit     = 200
-)
+const defaultPage = 1
 
 type libraryHandler struct {
-	db          *sql.DB
-	bookshelves []config.BookshelfConfig
+	db            *sql.DB
+	bookshelves   []config.BookshelfConfig
+	downloadsPath string
+	pagination    config.PageLimits
+	store         *store.Store
 }
 
 type libraryMangaItem struct {
-	ID            string `json:"id"`
-	BookshelfID   string `json:"bookshelfId"`
-	Title         string `json:"title"`
-	ChapterCount  int    `json:"chapterCount"`
-	PageCount     int    `json:"pageCount"`
-	UpdatedAt     string `json:"updatedAt"`
-	CoverThumbURL string `json:"coverThumbUrl"`
+	ID           string `json:"id"`
+	BookshelfID  string `json:"bookshelfId"`
+	Title        string `json:"title"`
+	ChapterCount int    `json:"chapterCount"`
+	PageCount    int    `json:"pageCount"`
+	UpdatedAt    string `json:"updatedAt"`
+	// ContentUpdatedAt is when chapters were last added or removed; the
+	// updated sort orders by it.
+	ContentUpdatedAt string `json:"contentUpdatedAt"`
+	CoverThumbURL    string `json:"coverThumbUrl"`
+	CoverBlurhash    string `json:"coverBlurhash"`
+	Favorite         bool   `json:"favorite"`
+	SortName         string `json:"sortName"`
+	Collection       string `json:"collection"`
+}
+
+// libraryFields maps the libraryMangaItem fields a client can ask for with
+// ?fields= to the store field each is read from and its value.
+var libraryFields = map[string]struct {
+	column string
+	value  func(store.Manga) any
+}{
+	"id":               {"ID", func(m store.Manga) any { return m.ID }},
+	"bookshelfId":      {"BookshelfID", func(m store.Manga) any { return m.BookshelfID }},
+	"title":            {"Title", func(m store.Manga) any { return m.Title }},
+	"chapterCount":     {"ChapterCount", func(m store.Manga) any { return m.ChapterCount }},
+	"pageCount":        {"PageCount", func(m store.Manga) any { return m.PageCount }},
+	"updatedAt":        {"UpdatedAt", func(m store.Manga) any { return m.UpdatedAt }},
+	"contentUpdatedAt": {"ContentUpdatedAt", func(m store.Manga) any { return m.ContentUpdatedAt }},
+	"coverThumbUrl":    {"ID", func(m store.Manga) any { return "/api/images/covers/" + m.ID + "/thumb" }},
+	"coverBlurhash":    {"CoverBlurhash", func(m store.Manga) any { return m.CoverBlurhash }},
+	"favorite":         {"Favorite", func(m store.Manga) any { return m.Favorite }},
+	"sortName":         {"SortName", func(m store.Manga) any { return m.SortName }},
+	"collection":       {"Collection", func(m store.Manga) any { return m.Collection }},
 }
 
 type libraryResponse struct {
 	Items       []libraryMangaItem `json:"items"`
+	Fields      []string           `json:"fields,omitempty"`
 	BookshelfID string             `json:"bookshelfId,omitempty"`
 	TagIDs      []string           `json:"tagIds,omitempty"`
+	Query       string             `json:"query,omitempty"`
+	Favorite    bool               `json:"favorite,omitempty"`
+	States      []string           `json:"states,omitempty"`
+	Collection  string             `json:"collection,omitempty"`
+	Sort        string             `json:"sort"`
 	Page        int                `json:"page"`
 	Limit       int                `json:"limit"`
 	Total       int                `json:"total"`
 	HasMore     bool               `json:"hasMore"`
 }
 
+// projectedLibraryResponse replaces the items of a library response with
+// ones holding only the requested fields.
+type projectedLibraryResponse struct {
+	libraryResponse
+	Items []map[string]any `json:"items"`
+}
+
 type bookshelfItem struct {
 	ID         string `json:"id"`
 	Name       string `json:"name"`
-	RootPath   string `json:"rootPath"`
+	RootPath   string `json:"rootPath,omitempty"`
+	Online     bool   `json:"online"`
 	MangaCount int    `json:"mangaCount"`
 	PageCount  int    `json:"pageCount"`
 	UpdatedAt  string `json:"updatedAt"`
@@ -58,136 +101,209 @@ type bookshelvesResponse struct {
 	Items []bookshelfItem `json:"items"`
 }
 
-func newLibraryHandler(db *sql.DB, bookshelves []config.BookshelfConfig) *libraryHandler {
-	return &libraryHandler{db: db, bookshelves: bookshelves}
+type collectionItem struct {
+	Name       string `json:"name"`
+	MangaCount int    `json:"mangaCount"`
+}
+
+type collectionsResponse struct {
+	Items []collectionItem `json:"items"`
+	// Lists are the user's named, ordered collections.
+	Lists []userCollectionItem `json:"lists"`
+}
+
+func newLibraryHandler(db *sql.DB, bookshelves []config.BookshelfConfig, downloadsPath string, pagination config.PageLimits, store *store.Store) *libraryHandler {
+	return &libraryHandler{db: db, bookshelves: bookshelves, downloadsPath: downloadsPath, pagination: pagination, store: store}
 }
 
 func (h *libraryHandler) getLibrary(w http.ResponseWriter, r *http.Request) {
+	favoriteOnly, _ := strconv.ParseBool(r.URL.Query().Get("favorite"))
+	h.listLibrary(w, r, favoriteOnly)
+}
+
+func (h *libraryHandler) getFavorites(w http.ResponseWriter, r *http.Request) {
+	h.listLibrary(w, r, true)
+}
+
+func (h *libraryHandler) listLibrary(w http.ResponseWriter, r *http.Request, favoriteOnly bool) {
 	if h.db == nil {
 		writeError(w, http.StatusInternalServerError, "database not initialized")
 		return
 	}
 
-	page := parsePositiveInt(r.URL.Query().Get("page"), defaultLibraryPage)
-	limit := parsePositiveInt(r.URL.Query().Get("limit"), defaultLibraryLimit)
-	if limit > maxLibraryLimit {
-		limit = maxLibraryLimit
+	page, limit, offset := parsePageParams(r, h.pagination)
+	filter, sortBy, message := parseLibraryQuery(r, favoriteOnly)
+	if message != "" {
+		writeError(w, http.StatusBadRequest, message)
+		return
 	}
-	offset := (page - 1) * limit
-	bookshelfID := strings.TrimSpace(r.URL.Query().Get("bookshelfId"))
-	tagIDs := normalizeTagIDs(splitQueryValues(r.URL.Query()["tagIds"]))
-
-	countQuery, countArgs := buildLibraryCountQuery(bookshelfID, tagIDs)
 
-	var total int
-	if err := h.db.QueryRowContext(r.Context(), countQuery, countArgs...).Scan(&total); err != nil {
-		writeError(w, http.StatusInternalServerError, "failed to count library")
+	fields, columns, unknown := parseLibraryFields(r.URL.Query()["fields"])
+	if unknown != "" {
+		writeError(w, http.StatusBadRequest, "unknown library field "+strconv.Quote(unknown))
 		return
 	}
 
-	listQuery, listArgs := buildLibraryListQuery(bookshelfID, tagIDs, limit, offset)
-
-	rows, err := h.db.QueryContext(r.Context(), listQuery, listArgs...)
+	mangas, total, err := h.store.ListManga(r.Context(), store.ListMangaOptions{
+		Filter: filter,
+		Sort:   sortBy,
+		Limit:  limit,
+		Offset: offset,
+		Fields: columns,
+	})
 	if err != nil {
 		writeError(w, http.StatusInternalServerError, "failed to query library")
 		return
 	}
-	defer rows.Close()
-
-	items := make([]libraryMangaItem, 0, limit)
-	for rows.Next() {
-		var item libraryMangaItem
-		if err := rows.Scan(&item.ID, &item.BookshelfID, &item.Title, &item.ChapterCount, &item.PageCount, &item.UpdatedAt); err != nil {
-			writeError(w, http.StatusInternalServerError, "failed to read library row")
-			return
-		}
-		item.CoverThumbURL = "/api/images/covers/" + item.ID + "/thumb"
-		items = append(items, item)
-	}
-	if err := rows.Err(); err != nil {
-		writeError(w, http.StatusInternalServerError, "failed to iterate library rows")
-		return
-	}
 
 	response := libraryResponse{
-		Items:       items,
-		BookshelfID: bookshelfID,
-		TagIDs:      tagIDs,
+		Fields:      fields,
+		BookshelfID: filter.BookshelfID,
+		TagIDs:      filter.TagIDs,
+		Query:       filter.Query,
+		Favorite:    filter.Favorite,
+		States:      filter.States,
+		Collection:  filter.Collection,
+		Sort:        sortBy,
 		Page:        page,
 		Limit:       limit,
 		Total:       total,
-		HasMore:     offset+len(items) < total,
+		HasMore:     offset+len(mangas) < total,
 	}
+	if len(fields) > 0 {
+		projected := projectedLibraryResponse{libraryResponse: response, Items: make([]map[string]any, 0, len(mangas))}
+		for _, manga := range mangas {
+			item := make(map[string]any, len(fields))
+			for _, name := range fields {
+				item[name] = libraryFields[name].value(manga)
+			}
+			projected.Items = append(projected.Items, item)
+		}
+		writeJSON(w, http.StatusOK, projected)
+		return
+	}
+
+	response.Items = newLibraryMangaItems(mangas)
 	writeJSON(w, http.StatusOK, response)
 }
 
-func buildLibraryCountQuery(bookshelfID string, tagIDs []string) (string, []any) {
-	var builder strings.Builder
-	builder.WriteString(`
-		SELECT COUNT(*)
-		FROM manga m
-	`)
+func newLibraryMangaItems(mangas []store.Manga) []libraryMangaItem {
+	items := make([]libraryMangaItem, 0, len(mangas))
+	for _, manga := range mangas {
+		items = append(items, libraryMangaItem{
+			ID:               manga.ID,
+			BookshelfID:      manga.BookshelfID,
+			Title:            manga.Title,
+			ChapterCount:     manga.ChapterCount,
+			PageCount:        manga.PageCount,
+			UpdatedAt:        manga.UpdatedAt,
+			ContentUpdatedAt: manga.ContentUpdatedAt,
+			CoverThumbURL:    "/api/images/covers/" + manga.ID + "/thumb",
+			CoverBlurhash:    manga.CoverBlurhash,
+			Favorite:         manga.Favorite,
+			SortName:         manga.SortName,
+			Collection:       manga.Collection,
+		})
+	}
+	return items
+}
 
-	clauses, args := buildLibraryFilters(bookshelfID, tagIDs)
-	builder.WriteString(" WHERE ")
-	builder.WriteString(strings.Join(clauses, " AND "))
-	return builder.String(), args
+type mangaSiblingsResponse struct {
+	MangaID string  `json:"mangaId"`
+	Sort    string  `json:"sort"`
+	Prev    *string `json:"prev"`
+	Next    *string `json:"next"`
 }
 
-func buildLibraryListQuery(bookshelfID string, tagIDs []string, limit, offset int) (string, []any) {
-	var builder strings.Builder
-	builder.WriteString(`
-		SELECT
-			m.id,
-			m.bookshelf_id,
-			m.title,
-			COUNT(c.id) AS chapter_count,
-			m.page_count,
-			m.updated_at
-		FROM manga m
-		LEFT JOIN chapter c ON c.manga_id = m.id
-	`)
+// getSiblings returns the manga before and after the given one in the
+// library listing, taking the same filter and sort parameters, so clients
+// can step through series without loading every page of the listing.
+func (h *libraryHandler) getSiblings(w http.ResponseWriter, r *http.Request) {
+	if h.db == nil {
+		writeError(w, http.StatusInternalServerError, "database not initialized")
+		return
+	}
 
-	clauses, args := buildLibraryFilters(bookshelfID, tagIDs)
-	builder.WriteString(" WHERE ")
-	builder.WriteString(strings.Join(clauses, " AND "))
-	builder.WriteString(`
-		GROUP BY m.id, m.bookshelf_id, m.title, m.page_count, m.updated_at
-		ORDER BY m.updated_at DESC, m.title ASC
-		LIMIT ? OFFSET ?
-	`)
-	args = append(args, limit, offset)
-	return builder.String(), args
-}
+	mangaID := chi.URLParam(r, "mangaID")
+	favoriteOnly, _ := strconv.ParseBool(r.URL.Query().Get("favorite"))
+	filter, sortBy, message := parseLibraryQuery(r, favoriteOnly)
+	if message != "" {
+		writeError(w, http.StatusBadRequest, message)
+		return
+	}
 
-func buildLibraryFilters(bookshelfID string, tagIDs []string) ([]string, []any) {
-	clauses := make([]string, 0, 2)
-	args := make([]any, 0, len(tagIDs)+3)
+	prev, next, err := h.store.MangaSiblings(r.Context(), mangaID, filter, sortBy)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			writeError(w, http.StatusNotFound, "manga not found in listing")
+			return
+		}
+		writeError(w, http.StatusInternalServerError, "failed to load sibling manga")
+		return
+	}
 
-	if bookshelfID != "" {
-		clauses = append(clauses, "m.bookshelf_id = ?")
-		args = append(args, bookshelfID)
+	response := mangaSiblingsResponse{MangaID: mangaID, Sort: sortBy}
+	if prev != "" {
+		response.Prev = &prev
+	}
+	if next != "" {
+		response.Next = &next
 	}
+	writeJSON(w, http.StatusOK, response)
+}
 
-	if len(tagIDs) > 0 {
-		clauses = append(clauses, fmt.Sprintf(`
-			m.id IN (
-				SELECT mt.manga_id
-				FROM manga_tag mt
-				WHERE mt.tag_id IN (%s)
-				GROUP BY mt.manga_id
-				HAVING COUNT(DISTINCT mt.tag_id) = ?
-			)
-		`, placeholders(len(tagIDs))))
-		args = append(args, toAnySlice(tagIDs)...)
-		args = append(args, len(tagIDs))
+// parseLibraryQuery reads the library filter and sort from the query
+// string, or returns a message describing the first invalid value.
+func parseLibraryQuery(r *http.Request, favoriteOnly bool) (store.MangaFilter, string, string) {
+	states := splitQueryValues(r.URL.Query()["state"])
+	for _, state := range states {
+		if !store.ValidReadState(state) {
+			return store.MangaFilter{}, "", "state must be unread, reading or completed"
+		}
 	}
 
-	if len(clauses) == 0 {
-		clauses = append(clauses, "1 = 1")
+	sortBy := strings.TrimSpace(r.URL.Query().Get("sort"))
+	if sortBy == "" {
+		sortBy = store.SortUpdated
+	}
+	if !store.ValidSort(sortBy) {
+		return store.MangaFilter{}, "", "sort must be updated, name or collection"
 	}
 
-	return clauses, args
+	return store.MangaFilter{
+		BookshelfID: strings.TrimSpace(r.URL.Query().Get("bookshelfId")),
+		TagIDs:      normalizeTagIDs(splitQueryValues(r.URL.Query()["tagIds"])),
+		Query:       strings.TrimSpace(r.URL.Query().Get("q")),
+		Favorite:    favoriteOnly,
+		States:      states,
+		Collection:  strings.TrimSpace(r.URL.Query().Get("collection")),
+	}, sortBy, ""
+}
+
+// parseLibraryFields reads the comma-separated ?fields= list, returning the
+// requested item fields without duplicates and the store fields they need,
+// or the first unknown field.
+func parseLibraryFields(values []string) ([]string, []string, string) {
+	fields := make([]string, 0)
+	columns := make([]string, 0)
+	seenFields := make(map[string]bool)
+	seenColumns := make(map[string]bool)
+	for _, name := range splitQueryValues(values) {
+		field, ok := libraryFields[name]
+		if !ok {
+			return nil, nil, name
+		}
+		if seenFields[name] {
+			continue
+		}
+		seenFields[name] = true
+		fields = append(fields, name)
+		if !seenColumns[field.column] {
+			seenColumns[field.column] = true
+			columns = append(columns, field.column)
+		}
+	}
+	return fields, columns, ""
 }
 
 func splitQueryValues(values []string) []string {
@@ -204,6 +320,50 @@ func splitQueryValues(values []string) []string {
 	return items
 }
 
+// getCollections lists the collections manga have been grouped into, with
+// how many manga each holds.
+func (h *libraryHandler) getCollections(w http.ResponseWriter, r *http.Request) {
+	if h.db == nil {
+		writeError(w, http.StatusInternalServerError, "database not initialized")
+		return
+	}
+
+	rows, err := h.db.QueryContext(r.Context(), `
+		SELECT collection, COUNT(*)
+		FROM manga
+		WHERE collection <> ''
+		GROUP BY collection
+		ORDER BY collection COLLATE NOCASE ASC
+	`)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to query collections")
+		return
+	}
+	defer rows.Close()
+
+	items := make([]collectionItem, 0)
+	for rows.Next() {
+		var item collectionItem
+		if err := rows.Scan(&item.Name, &item.MangaCount); err != nil {
+			writeError(w, http.StatusInternalServerError, "failed to read collection row")
+			return
+		}
+		items = append(items, item)
+	}
+	if err := rows.Err(); err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to iterate collection rows")
+		return
+	}
+
+	lists, err := loadUserCollections(r.Context(), h.db)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to load collection lists")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, collectionsResponse{Items: items, Lists: lists})
+}
+
 func (h *libraryHandler) getBookshelves(w http.ResponseWriter, r *http.Request) {
 	if h.db == nil {
 		writeError(w, http.StatusInternalServerError, "database not initialized")
@@ -273,6 +433,14 @@ func (h *libraryHandler) getBookshelves(w http.ResponseWriter, r *http.Request)
 		merged = append(merged, item)
 	}
 
+	admin := isAdminRequest(r)
+	for index := range merged {
+		merged[index].Online = h.isDownloadsShelf(merged[index].RootPath)
+		if !admin {
+			merged[index].RootPath = ""
+		}
+	}
+
 	writeJSON(w, http.StatusOK, bookshelvesResponse{Items: merged})
 }
 
@@ -284,6 +452,22 @@ func normalizeBookshelfPath(path string) string {
 	return strings.ToLower(strings.ReplaceAll(filepath.Clean(path), "\\", "/"))
 }
 
+func (h *libraryHandler) isDownloadsShelf(rootPath string) bool {
+	if strings.TrimSpace(h.downloadsPath) == "" || strings.TrimSpace(rootPath) == "" {
+		return false
+	}
+	root := normalizeBookshelfPath(absolutePath(rootPath))
+	downloads := normalizeBookshelfPath(absolutePath(h.downloadsPath))
+	return root == downloads || strings.HasPrefix(root, downloads+"/")
+}
+
+func absolutePath(path string) string {
+	if abs, err := filepath.Abs(path); err == nil {
+		return abs
+	}
+	return path
+}
+
 func bookshelfConfigID(path string) string {
 	normalized := normalizeBookshelfPath(path)
 	sum := sha1.Sum([]byte(normalized))
@@ -301,6 +485,15 @@ func parsePositiveInt(raw string, fallback int) int {
 	return value
 }
 
+func parsePageParams(r *http.Request, limits config.PageLimits) (int, int, int) {
+	page := parsePositiveInt(r.URL.Query().Get("page"), defaultPage)
+	limit := parsePositiveInt(r.URL.Query().Get("limit"), limits.DefaultLimit)
+	if limit > limits.MaxLimit {
+		limit = limits.MaxLimit
+	}
+	return page, limit, (page - 1) * limit
+}
+
 func writeError(w http.ResponseWriter, status int, message string) {
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(status)
@@ -0,0 +1,89 @@
+package api
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sync"
+	"sync/atomic"
+)
+
+// LibraryCountCache remembers library count results until the library
+// changes. Changes are detected through version counters: the cache's own
+// counter is bumped by API write endpoints via Invalidate, and any extra
+// sources (such as the scanner's version) are consulted on every lookup.
+type LibraryCountCache struct {
+	sources []func() uint64
+	local   atomic.Uint64
+
+	mu      sync.Mutex
+	version uint64
+	entries map[string]int
+}
+
+func NewLibraryCountCache(sources ...func() uint64) *LibraryCountCache {
+	return &LibraryCountCache{
+		sources: sources,
+		entries: make(map[string]int),
+	}
+}
+
+// Invalidate marks every cached count as stale.
+func (c *LibraryCountCache) Invalidate() {
+	if c == nil {
+		return
+	}
+	c.local.Add(1)
+}
+
+// Version returns the combined version the cached counts are checked against.
+func (c *LibraryCountCache) Version() uint64 {
+	version := c.local.Load()
+	for _, source := range c.sources {
+		version += source()
+	}
+	return version
+}
+
+// Count returns the cached result of the count query, running it when the
+// cache is cold or the library changed since it was stored.
+func (c *LibraryCountCache) Count(ctx context.Context, db *sql.DB, query string, args ...any) (int, error) {
+	if c == nil {
+		return queryCount(ctx, db, query, args...)
+	}
+
+	// Sprint would run adjacent string arguments together, letting two
+	// filters share a cached total; %q keeps each argument apart.
+	key := query + "\x00" + fmt.Sprintf("%q", args)
+	version := c.Version()
+
+	c.mu.Lock()
+	if c.version != version {
+		c.version = version
+		c.entries = make(map[string]int)
+	}
+	total, ok := c.entries[key]
+	c.mu.Unlock()
+	if ok {
+		return total, nil
+	}
+
+	total, err := queryCount(ctx, db, query, args...)
+	if err != nil {
+		return 0, err
+	}
+
+	c.mu.Lock()
+	if c.version == version {
+		c.entries[key] = total
+	}
+	c.mu.Unlock()
+
+	return total, nil
+}
+
+func queryCount(ctx context.Context, db *sql.DB, query string, args ...any) (int, error) {
+	var total int
+	err := db.QueryRowContext(ctx, query, args...).Scan(&total)
+	return total, err
+}
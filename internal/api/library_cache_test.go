@@ -0,0 +1,155 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"sync/atomic"
+	"testing"
+)
+
+func TestLibraryCountCacheInvalidate(t *testing.T) {
+	server := newTestServer(t, "")
+	writeChapter(t, server.root, "Alpha", "Chapter 1", 1)
+	server.scan()
+
+	var scans atomic.Uint64
+	cache := NewLibraryCountCache(scans.Load)
+	count := func() int {
+		t.Helper()
+		total, err := cache.Count(context.Background(), server.db, `SELECT COUNT(*) FROM manga`)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return total
+	}
+
+	if got := count(); got != 1 {
+		t.Fatalf("count = %d, want 1", got)
+	}
+	if _, err := server.db.Exec(`INSERT INTO manga(id, title, path) VALUES ('m2', 'Beta', '/Beta')`); err != nil {
+		t.Fatal(err)
+	}
+	if got := count(); got != 1 {
+		t.Fatalf("count before invalidation = %d, want the cached 1", got)
+	}
+	cache.Invalidate()
+	if got := count(); got != 2 {
+		t.Fatalf("count after Invalidate = %d, want 2", got)
+	}
+
+	if _, err := server.db.Exec(`DELETE FROM manga WHERE id = 'm2'`); err != nil {
+		t.Fatal(err)
+	}
+	scans.Add(1)
+	if got := count(); got != 1 {
+		t.Fatalf("count after a source version change = %d, want 1", got)
+	}
+}
+
+func TestLibraryTotalsRefreshAfterWrites(t *testing.T) {
+	server := newTestServer(t, "")
+	writeChapter(t, server.root, "Alpha", "Chapter 1", 2)
+	writeChapter(t, server.root, "Beta", "Chapter 1", 3)
+	server.scan()
+	alpha := server.queryString(`SELECT id FROM manga WHERE title = 'Alpha'`)
+	beta := server.queryString(`SELECT id FROM manga WHERE title = 'Beta'`)
+	betaChapter := server.queryString(`SELECT id FROM chapter WHERE manga_id = ?`, beta)
+
+	total := func(target string) int {
+		t.Helper()
+		rec := server.do(http.MethodGet, target, "")
+		if rec.Code != http.StatusOK {
+			t.Fatalf("GET %s = %d, body %s", target, rec.Code, rec.Body.String())
+		}
+		return decodeJSON[libraryResponse](t, rec).Total
+	}
+
+	t.Run("favorite toggle", func(t *testing.T) {
+		if got := total("/api/favorites"); got != 0 {
+			t.Fatalf("favorites = %d, want 0", got)
+		}
+		// Written behind the API's back, the change is not seen yet: the
+		// total comes from the cache.
+		if _, err := server.db.Exec(`UPDATE manga SET favorite = 1 WHERE id = ?`, beta); err != nil {
+			t.Fatal(err)
+		}
+		if got := total("/api/favorites"); got != 0 {
+			t.Fatalf("favorites before any API write = %d, want the cached 0", got)
+		}
+		if rec := server.do(http.MethodPut, "/api/manga/"+alpha+"/favorite", `{"favorite":true}`); rec.Code != http.StatusOK {
+			t.Fatalf("favorite = %d, body %s", rec.Code, rec.Body.String())
+		}
+		if got := total("/api/favorites"); got != 2 {
+			t.Fatalf("favorites after toggling = %d, want 2", got)
+		}
+	})
+
+	t.Run("progress write", func(t *testing.T) {
+		if got := total("/api/library?state=reading"); got != 0 {
+			t.Fatalf("reading = %d, want 0", got)
+		}
+		if rec := server.do(http.MethodPut, "/api/chapters/"+betaChapter+"/progress", `{"pageIndex":1}`); rec.Code != http.StatusOK {
+			t.Fatalf("progress = %d, body %s", rec.Code, rec.Body.String())
+		}
+		if got := total("/api/library?state=reading"); got != 1 {
+			t.Fatalf("reading after a progress write = %d, want 1", got)
+		}
+	})
+
+	t.Run("scan", func(t *testing.T) {
+		if got := total("/api/library"); got != 2 {
+			t.Fatalf("library = %d, want 2", got)
+		}
+		writeChapter(t, server.root, "Gamma", "Chapter 1", 1)
+		server.scan()
+		if got := total("/api/library"); got != 3 {
+			t.Fatalf("library after a scan = %d, want 3", got)
+		}
+	})
+}
+
+func TestLibraryTotalsKeptApartPerFilter(t *testing.T) {
+	server := newTestServer(t, "")
+	// Both filters bind the strings "a", "b" and "c" in the same order;
+	// only the boundaries between the arguments differ.
+	if _, err := server.db.Exec(`INSERT INTO manga(id, title, path, bookshelf_id, collection) VALUES
+		('m1', 'One', '/one', 'ab', 'c'),
+		('m2', 'Two', '/two', 'a', 'bc'),
+		('m3', 'Three', '/three', 'a', 'bc')`); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		target string
+		want   int
+	}{
+		{"/api/library?bookshelfId=ab&collection=c", 1},
+		{"/api/library?bookshelfId=a&collection=bc", 2},
+		{"/api/library?bookshelfId=ab&collection=c", 1},
+	}
+	for _, tt := range tests {
+		rec := server.do(http.MethodGet, tt.target, "")
+		if got := decodeJSON[libraryResponse](t, rec).Total; got != tt.want {
+			t.Errorf("GET %s total = %d, want %d", tt.target, got, tt.want)
+		}
+	}
+}
+
+func TestLibraryTotalMatchesFilter(t *testing.T) {
+	server := newTestServer(t, "")
+	for _, title := range []string{"Alpha", "Alpine", "Beta", "Gamma"} {
+		writeChapter(t, server.root, title, "Chapter 1", 1)
+	}
+	server.scan()
+
+	rec := server.do(http.MethodGet, "/api/library?q=alp&limit=1", "")
+	response := decodeJSON[libraryResponse](t, rec)
+	if response.Total != 2 || len(response.Items) != 1 || !response.HasMore {
+		t.Fatalf("filtered page = total %d, %d items, hasMore %v; want 2, 1, true", response.Total, len(response.Items), response.HasMore)
+	}
+	rec = server.do(http.MethodGet, "/api/library?q=alp&limit=1&page=2", "")
+	response = decodeJSON[libraryResponse](t, rec)
+	if response.Total != 2 || len(response.Items) != 1 || response.HasMore {
+		t.Fatalf("last filtered page = total %d, %d items, hasMore %v; want 2, 1, false", response.Total, len(response.Items), response.HasMore)
+	}
+}
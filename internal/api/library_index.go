@@ -0,0 +1,145 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+	"unicode"
+	"unicode/utf8"
+)
+
+// otherIndexBucket holds titles starting with anything but a letter, and
+// with letters outside Latin and the scripts named in indexScripts.
+const otherIndexBucket = "#"
+
+// latinExtendedFolds holds the base letter, A to Z, of each rune from
+// U+00C0 to U+024F: Latin-1 Supplement and Latin Extended-A and B. Runes
+// with no base letter, such as Ə or ×, are '#'.
+const latinExtendedFolds = "" +
+	"AAAAAAACEEEEIIIIDNOOOOO#OUUUUYTSAAAAAAACEEEEIIIIDNOOOOO#OUUUUYTY" +
+	"AAAAAACCCCCCCCDDDDEEEEEEEEEEGGGGGGGGHHHHIIIIIIIIIIIIJJKKKLLLLLLL" +
+	"LLLNNNNNNNNNOOOOOOOORRRRRRSSSSSSSSTTTTTTUUUUUUUUUUUUWWYYYZZZZZZS" +
+	"BBBB##OCCDDDD#E#EFFG###IKKL##NNOOO##PP#####TTTTUU#VYYZZ#########" +
+	"####DDDLLLNNNAAIIOOUUUUUUUUUUEAAAAAAGGGGKKOOOO##JDDDGG##NNAAAAOO" +
+	"AAAAEEEEIIIIOOOORRRRUUUUSSTT##HHND##ZZAAEEOOOOOOOOYYLNT###ACCLTS" +
+	"Z##BU#EEJJ#QRRYY"
+
+// latinAdditionalFolds does the same for Latin Extended Additional, U+1E00
+// to U+1EFF, which holds most Vietnamese letters.
+const latinAdditionalFolds = "" +
+	"AABBBBBBCCDDDDDDDDDDEEEEEEEEEEFFGGHHHHHHHHHHIIIIKKKKKKLLLLLLLLMM" +
+	"MMMMNNNNNNNNOOOOOOOOPPPPRRRRRRRRSSSSSSSSSSTTTTTTTTUUUUUUUUUUVVVV" +
+	"WWWWWWWWWWXXXXYYZZZZZZHTWYASSSS#AAAAAAAAAAAAAAAAAAAAAAAAEEEEEEEE" +
+	"EEEEEEEEIIIIOOOOOOOOOOOOOOOOOOOOOOOOUUUUUUUUUUUUUUYYYYYYYY####YY"
+
+// indexScripts are the scripts whose titles get an index bucket of their
+// own, named after the script, instead of falling under otherIndexBucket.
+var indexScripts = []struct {
+	name  string
+	table *unicode.RangeTable
+}{
+	{"Han", unicode.Han},
+	{"Hiragana", unicode.Hiragana},
+	{"Katakana", unicode.Katakana},
+	{"Hangul", unicode.Hangul},
+	{"Cyrillic", unicode.Cyrillic},
+	{"Greek", unicode.Greek},
+	{"Arabic", unicode.Arabic},
+	{"Hebrew", unicode.Hebrew},
+	{"Thai", unicode.Thai},
+}
+
+type libraryIndexBucket struct {
+	Key   string `json:"key"`
+	Count int    `json:"count"`
+	// Offset is the position of the bucket's first manga in the library
+	// listed with sort=name and the same filters.
+	Offset int `json:"offset"`
+}
+
+type libraryIndexResponse struct {
+	Total   int                  `json:"total"`
+	Buckets []libraryIndexBucket `json:"buckets"`
+}
+
+// getLibraryIndex counts the library by the first letter of each manga's
+// sort name, for an A-Z jump bar. Latin letters are bucketed A to Z with
+// their diacritics dropped, the scripts of indexScripts by script name and
+// everything else under #. Buckets come in the order sort=name lists their
+// first manga and honour the same filters as the library listing.
+//
+// A bucket's offset is that of its first manga, and its manga are only
+// listed together when its initials sort together. sort=name compares
+// sort names case-insensitively but byte by byte otherwise, so titles
+// starting with an accented letter, such as É or Ł, are listed after Z
+// while counted under E or L, and # counts both the digits and punctuation
+// listed before A and the symbols listed after the letters.
+func (h *libraryHandler) getLibraryIndex(w http.ResponseWriter, r *http.Request) {
+	if h.db == nil {
+		writeError(w, http.StatusInternalServerError, "database not initialized")
+		return
+	}
+
+	favoriteOnly, _ := strconv.ParseBool(r.URL.Query().Get("favorite"))
+	filter, _, message := parseLibraryQuery(r, favoriteOnly)
+	if message != "" {
+		writeError(w, http.StatusBadRequest, message)
+		return
+	}
+
+	initials, err := h.store.MangaInitials(r.Context(), filter)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to query library index")
+		return
+	}
+
+	response := libraryIndexResponse{Buckets: make([]libraryIndexBucket, 0)}
+	byKey := make(map[string]int)
+	for _, initial := range initials {
+		key := indexBucket(initial.Initial)
+		index, ok := byKey[key]
+		if !ok {
+			index = len(response.Buckets)
+			byKey[key] = index
+			response.Buckets = append(response.Buckets, libraryIndexBucket{Key: key, Offset: response.Total})
+		}
+		response.Buckets[index].Count += initial.Count
+		response.Total += initial.Count
+	}
+	writeJSON(w, http.StatusOK, response)
+}
+
+// indexBucket names the index bucket of a title starting with initial.
+func indexBucket(initial string) string {
+	r, _ := utf8.DecodeRuneInString(initial)
+	if r < utf8.RuneSelf {
+		if unicode.IsLetter(r) {
+			return string(unicode.ToUpper(r))
+		}
+		return otherIndexBucket
+	}
+	if unicode.Is(unicode.Latin, r) {
+		return string(latinBaseLetter(r))
+	}
+	for _, script := range indexScripts {
+		if unicode.Is(script.table, r) {
+			return script.name
+		}
+	}
+	return otherIndexBucket
+}
+
+// latinBaseLetter returns the letter, A to Z, a non-ASCII Latin letter is
+// written with, so É is E and Ł is L, or '#' when it has none.
+func latinBaseLetter(r rune) byte {
+	switch {
+	case r >= 0xC0 && r <= 0x24F:
+		return latinExtendedFolds[r-0xC0]
+	case r >= 0x1E00 && r <= 0x1EFF:
+		return latinAdditionalFolds[r-0x1E00]
+	case r >= 'Ａ' && r <= 'Ｚ':
+		return byte('A' + r - 'Ａ')
+	case r >= 'ａ' && r <= 'ｚ':
+		return byte('A' + r - 'ａ')
+	}
+	return otherIndexBucket[0]
+}
@@ -0,0 +1,94 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+	"testing"
+)
+
+func TestIndexBucket(t *testing.T) {
+	tests := []struct {
+		initial string
+		want    string
+	}{
+		{"a", "A"},
+		{"Z", "Z"},
+		{"É", "E"},
+		{"é", "E"},
+		{"Ö", "O"},
+		{"Ł", "L"},
+		{"ß", "S"},
+		{"Æ", "A"},
+		{"Ư", "U"},
+		{"Ẩ", "A"},
+		{"Ｍ", "M"},
+		{"ə", "#"},
+		{"1", "#"},
+		{"~", "#"},
+		{"", "#"},
+		{"日", "Han"},
+		{"の", "Hiragana"},
+		{"Я", "Cyrillic"},
+		{"Ω", "Greek"},
+		{"★", "#"},
+	}
+	for _, tt := range tests {
+		if got := indexBucket(tt.initial); got != tt.want {
+			t.Errorf("indexBucket(%q) = %q, want %q", tt.initial, got, tt.want)
+		}
+	}
+}
+
+func TestLibraryIndexOffsets(t *testing.T) {
+	server := newTestServer(t, "")
+	for _, title := range []string{"1999", "alpha", "Apple", "Beta", "bravo", "Echo", "Zulu", "Élan", "日本"} {
+		writeChapter(t, server.root, title, "Chapter 1", 1)
+	}
+	server.scan()
+
+	rec := server.do(http.MethodGet, "/api/library/index", "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, body %s", rec.Code, rec.Body.String())
+	}
+	index := decodeJSON[libraryIndexResponse](t, rec)
+	want := []libraryIndexBucket{
+		{Key: "#", Count: 1, Offset: 0},
+		{Key: "A", Count: 2, Offset: 1},
+		{Key: "B", Count: 2, Offset: 3},
+		{Key: "E", Count: 2, Offset: 5},
+		{Key: "Z", Count: 1, Offset: 6},
+		{Key: "Han", Count: 1, Offset: 8},
+	}
+	if index.Total != 9 || len(index.Buckets) != len(want) {
+		t.Fatalf("index = %+v, want total 9 and buckets %+v", index, want)
+	}
+	for i, bucket := range index.Buckets {
+		if bucket != want[i] {
+			t.Errorf("bucket %d = %+v, want %+v", i, bucket, want[i])
+		}
+	}
+
+	// The page holding a bucket's offset starts with its first manga.
+	listing := decodeJSON[libraryResponse](t, server.do(http.MethodGet, "/api/library?sort=name&limit=100", ""))
+	for _, bucket := range index.Buckets {
+		page := decodeJSON[libraryResponse](t, server.do(http.MethodGet, "/api/library?sort=name&limit=1&page="+strconv.Itoa(bucket.Offset+1), ""))
+		if len(page.Items) != 1 || page.Items[0].ID != listing.Items[bucket.Offset].ID {
+			t.Fatalf("page at offset %d = %+v", bucket.Offset, page.Items)
+		}
+		if got := indexBucket(page.Items[0].Title); got != bucket.Key {
+			t.Errorf("manga at offset %d of bucket %s is %q, in bucket %s", bucket.Offset, bucket.Key, page.Items[0].Title, got)
+		}
+	}
+	// Élan is counted under E but listed after Zulu, as documented.
+	if title := listing.Items[7].Title; title != "Élan" {
+		t.Errorf("manga listed after Zulu = %q, want Élan", title)
+	}
+
+	if _, err := server.db.Exec(`UPDATE manga SET favorite = 1 WHERE title IN ('Beta', 'Élan')`); err != nil {
+		t.Fatal(err)
+	}
+	favorites := decodeJSON[libraryIndexResponse](t, server.do(http.MethodGet, "/api/library/index?favorite=true", ""))
+	if favorites.Total != 2 || len(favorites.Buckets) != 2 || favorites.Buckets[0] != (libraryIndexBucket{Key: "B", Count: 1}) || favorites.Buckets[1] != (libraryIndexBucket{Key: "E", Count: 1, Offset: 1}) {
+		t.Errorf("favorites index = %+v", favorites)
+	}
+}
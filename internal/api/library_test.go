@@ -0,0 +1,225 @@
+package api
+
+import (
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"mynewmangaui/internal/media"
+	scansvc "mynewmangaui/internal/scan"
+)
+
+func TestLibraryListsCoverOnlyManga(t *testing.T) {
+	server := newTestServer(t, "")
+	// The router's scanner is only used for scan endpoints; index with one
+	// that keeps cover-only folders.
+	server.scanner = scansvc.NewService(server.db, []scansvc.Bookshelf{{Name: "main", Path: server.root}}, scansvc.Options{IndexCoverOnly: true}, testLogger())
+	writePNG(t, filepath.Join(server.root, "Upcoming", "cover.png"), 20, 30)
+	writeChapter(t, server.root, "Alpha", "Chapter 1", 1)
+	server.scan()
+
+	rec := server.do(http.MethodGet, "/api/library?q=Upcoming", "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, body %s", rec.Code, rec.Body.String())
+	}
+	items := decodeJSON[libraryResponse](t, rec).Items
+	if len(items) != 1 || items[0].ChapterCount != 0 || items[0].PageCount != 0 {
+		t.Fatalf("library = %+v, want Upcoming with no chapters", items)
+	}
+	if path := server.queryString(`SELECT cover_path FROM manga WHERE id = ?`, items[0].ID); filepath.Base(path) != "cover.png" {
+		t.Fatalf("cover path = %q, want the root cover.png", path)
+	}
+
+	rec = server.do(http.MethodGet, items[0].CoverThumbURL, "")
+	if rec.Code != http.StatusOK || !strings.HasPrefix(rec.Header().Get("Content-Type"), "image/") {
+		t.Fatalf("cover = %d %q, body %s", rec.Code, rec.Header().Get("Content-Type"), rec.Body.String())
+	}
+}
+
+func TestLibrarySearchMatchesLiterally(t *testing.T) {
+	server := newTestServer(t, "")
+	for _, title := range []string{"100% Perfect", "100 Percent", "snake-case", "snakeXcase", `Bob's "Quote"`, "Re:Zero", "Über Alles", "進撃の巨人", `back\slash`} {
+		writeChapter(t, server.root, title, "Chapter 1", 1)
+	}
+	server.scan()
+	// Folder names read underscores as spaces, so set this title directly.
+	if _, err := server.db.Exec(`UPDATE manga SET title = 'snake_case', title_sort = 'snake_case' WHERE title = 'snake-case'`); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		{query: "100%", want: []string{"100% Perfect"}},
+		{query: "%", want: []string{"100% Perfect"}},
+		{query: "e_c", want: []string{"snake_case"}},
+		{query: "_", want: []string{"snake_case"}},
+		{query: "'s", want: []string{`Bob's "Quote"`}},
+		{query: `"quote"`, want: []string{`Bob's "Quote"`}},
+		{query: "re:zero", want: []string{"Re:Zero"}},
+		{query: "über", want: []string{"Über Alles"}},
+		{query: "巨人", want: []string{"進撃の巨人"}},
+		{query: `k\s`, want: []string{`back\slash`}},
+		{query: `'; DROP TABLE manga; --`, want: []string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			rec := server.do(http.MethodGet, "/api/library?q="+url.QueryEscape(tt.query), "")
+			if rec.Code != http.StatusOK {
+				t.Fatalf("status = %d, body %s", rec.Code, rec.Body.String())
+			}
+			response := decodeJSON[libraryResponse](t, rec)
+			titles := make([]string, 0, len(response.Items))
+			for _, item := range response.Items {
+				titles = append(titles, item.Title)
+			}
+			if !slices.Equal(titles, tt.want) || response.Query != tt.query {
+				t.Fatalf("titles = %q for query %q, want %q", titles, response.Query, tt.want)
+			}
+		})
+	}
+	if count := server.queryString(`SELECT COUNT(*) FROM manga`); count != "9" {
+		t.Fatalf("manga rows = %s, want 9", count)
+	}
+}
+
+func TestMangaSiblings(t *testing.T) {
+	server := newTestServer(t, "")
+	for _, title := range []string{"Alpha", "Beta", "Gamma", "Delta"} {
+		writeChapter(t, server.root, title, "Chapter 1", 1)
+	}
+	server.scan()
+	ids := map[string]string{}
+	for _, manga := range []struct {
+		title      string
+		updated    string
+		collection string
+		favorite   bool
+	}{
+		{title: "Alpha", updated: "2026-01-01 00:00:00"},
+		{title: "Beta", updated: "2026-01-03 00:00:00", collection: "Saga", favorite: true},
+		{title: "Gamma", updated: "2026-01-02 00:00:00", collection: "arc", favorite: true},
+		{title: "Delta", updated: "2026-01-04 00:00:00", collection: "Saga"},
+	} {
+		if _, err := server.db.Exec(`UPDATE manga SET content_updated_at = ?, collection = ?, favorite = ? WHERE title = ?`, manga.updated, manga.collection, manga.favorite, manga.title); err != nil {
+			t.Fatal(err)
+		}
+		ids[manga.title] = server.queryString(`SELECT id FROM manga WHERE title = ?`, manga.title)
+	}
+
+	tests := []struct {
+		name  string
+		query string
+		want  []string
+	}{
+		{name: "default", query: "", want: []string{"Delta", "Beta", "Gamma", "Alpha"}},
+		{name: "updated", query: "sort=updated", want: []string{"Delta", "Beta", "Gamma", "Alpha"}},
+		{name: "name", query: "sort=name", want: []string{"Alpha", "Beta", "Delta", "Gamma"}},
+		{name: "collection", query: "sort=collection", want: []string{"Gamma", "Beta", "Delta", "Alpha"}},
+		{name: "filtered", query: "sort=name&favorite=true", want: []string{"Beta", "Gamma"}},
+		{name: "single", query: "sort=name&q=Delta", want: []string{"Delta"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// The siblings follow the library listing for the same query.
+			rec := server.do(http.MethodGet, "/api/library?"+tt.query, "")
+			if rec.Code != http.StatusOK {
+				t.Fatalf("library status = %d, body %s", rec.Code, rec.Body.String())
+			}
+			listed := []string{}
+			for _, item := range decodeJSON[libraryResponse](t, rec).Items {
+				listed = append(listed, item.Title)
+			}
+			if !slices.Equal(listed, tt.want) {
+				t.Fatalf("library = %q, want %q", listed, tt.want)
+			}
+
+			for i, title := range tt.want {
+				rec := server.do(http.MethodGet, "/api/manga/"+ids[title]+"/siblings?"+tt.query, "")
+				if rec.Code != http.StatusOK {
+					t.Fatalf("%s: status = %d, body %s", title, rec.Code, rec.Body.String())
+				}
+				siblings := decodeJSON[mangaSiblingsResponse](t, rec)
+				// Edges are null rather than empty ids.
+				var wantPrev, wantNext *string
+				if i > 0 {
+					prev := ids[tt.want[i-1]]
+					wantPrev = &prev
+				}
+				if i < len(tt.want)-1 {
+					next := ids[tt.want[i+1]]
+					wantNext = &next
+				}
+				if deref(siblings.Prev) != deref(wantPrev) {
+					t.Errorf("%s: prev = %v, want %v", title, deref(siblings.Prev), deref(wantPrev))
+				}
+				if deref(siblings.Next) != deref(wantNext) {
+					t.Errorf("%s: next = %v, want %v", title, deref(siblings.Next), deref(wantNext))
+				}
+			}
+		})
+	}
+
+	if rec := server.do(http.MethodGet, "/api/manga/"+ids["Alpha"]+"/siblings?favorite=true", ""); rec.Code != http.StatusNotFound {
+		t.Errorf("status for a manga outside the listing = %d, want 404", rec.Code)
+	}
+	if rec := server.do(http.MethodGet, "/api/manga/missing/siblings", ""); rec.Code != http.StatusNotFound {
+		t.Errorf("status for a missing manga = %d, want 404", rec.Code)
+	}
+	if rec := server.do(http.MethodGet, "/api/manga/"+ids["Alpha"]+"/siblings?sort=random", ""); rec.Code != http.StatusBadRequest {
+		t.Errorf("status for an unknown sort = %d, want 400", rec.Code)
+	}
+}
+
+func TestCoverBlurhash(t *testing.T) {
+	server := newTestServer(t, "")
+	writeChapter(t, server.root, "Alpha", "Chapter 1", 2)
+	server.scan()
+	mangaID := server.queryString(`SELECT id FROM manga`)
+
+	blurhashes := func() (string, string) {
+		t.Helper()
+		rec := server.do(http.MethodGet, "/api/library", "")
+		if rec.Code != http.StatusOK {
+			t.Fatalf("library status = %d", rec.Code)
+		}
+		items := decodeJSON[libraryResponse](t, rec).Items
+		rec = server.do(http.MethodGet, "/api/manga/"+mangaID, "")
+		if rec.Code != http.StatusOK || len(items) != 1 {
+			t.Fatalf("detail status = %d with %d library items", rec.Code, len(items))
+		}
+		return items[0].CoverBlurhash, decodeJSON[mangaDetailResponse](t, rec).CoverBlurhash
+	}
+	wantHash := func(path string) string {
+		t.Helper()
+		hash, err := media.Blurhash(media.FileRef(path))
+		if err != nil || hash == "" {
+			t.Fatalf("blurhash of %s = %q, %v", path, hash, err)
+		}
+		return hash
+	}
+
+	firstPage := filepath.Join(server.root, "Alpha", "Chapter 1", "a.png")
+	if library, detail := blurhashes(); library != wantHash(firstPage) || detail != library {
+		t.Fatalf("blurhash = %q in the library and %q in the detail, want %q of the first page", library, detail, wantHash(firstPage))
+	}
+	server.scan()
+	if library, _ := blurhashes(); library != wantHash(firstPage) {
+		t.Fatalf("blurhash after rescan = %q, want it unchanged", library)
+	}
+
+	// A cover image replacing the first page gets its own hash.
+	cover := filepath.Join(server.root, "Alpha", "cover.png")
+	writeNoisePNG(t, cover, 30, 40)
+	server.scan()
+	if got := server.queryString(`SELECT cover_path FROM manga`); got != media.FileRef(cover) {
+		t.Fatalf("cover path = %q, want %q", got, media.FileRef(cover))
+	}
+	if library, detail := blurhashes(); library != wantHash(cover) || detail != library || library == wantHash(firstPage) {
+		t.Fatalf("blurhash after the cover changed = %q and %q, want %q", library, detail, wantHash(cover))
+	}
+}
@@ -0,0 +1,134 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync/atomic"
+
+	scansvc "mynewmangaui/internal/scan"
+	"mynewmangaui/internal/settings"
+)
+
+// maintenanceErrorCode tells clients a 503 is maintenance rather than an
+// outage.
+const maintenanceErrorCode = "maintenance"
+
+type maintenanceRequest struct {
+	Enabled *bool `json:"enabled"`
+}
+
+type maintenanceResponse struct {
+	Enabled bool `json:"enabled"`
+	// ScanQueued is set when turning maintenance off queued a library scan
+	// to pick up what changed meanwhile.
+	ScanQueued bool `json:"scanQueued,omitempty"`
+}
+
+type maintenanceErrorResponse struct {
+	Error string `json:"error"`
+	Code  string `json:"code"`
+}
+
+type readinessResponse struct {
+	Status      string `json:"status"`
+	Maintenance bool   `json:"maintenance"`
+}
+
+// maintenanceHandler holds the maintenance mode, during which scanning is
+// paused and the API, apart from admin routes, answers 503. The flag lives
+// in the settings table so it survives a restart.
+type maintenanceHandler struct {
+	settings *settings.Store
+	scanner  *scansvc.Service
+	logger   *slog.Logger
+	enabled  atomic.Bool
+}
+
+// newMaintenanceHandler restores the persisted maintenance flag, pausing
+// the scanner if it is set. A flag that cannot be read is logged and
+// treated as off.
+func newMaintenanceHandler(store *settings.Store, scanner *scansvc.Service, logger *slog.Logger) *maintenanceHandler {
+	h := &maintenanceHandler{settings: store, scanner: scanner, logger: logger}
+	enabled, err := settings.Maintenance.Get(context.Background(), store)
+	if err != nil && logger != nil {
+		logger.Warn("failed to load maintenance mode, leaving it off", "error", err)
+	}
+	h.setEnabled(enabled)
+	if enabled && logger != nil {
+		logger.Info("maintenance mode is on; scans are paused")
+	}
+	return h
+}
+
+func (h *maintenanceHandler) setEnabled(enabled bool) {
+	h.enabled.Store(enabled)
+	if h.scanner != nil {
+		h.scanner.SetPaused(enabled)
+	}
+}
+
+// middleware answers API requests with a 503 while maintenance mode is on.
+// Admin routes stay reachable so the mode can be turned off again, and
+// /health and /readyz sit outside /api.
+func (h *maintenanceHandler) middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if h.enabled.Load() && strings.HasPrefix(r.URL.Path, "/api/") && !strings.HasPrefix(r.URL.Path, "/api/admin/") {
+			writeJSON(w, http.StatusServiceUnavailable, maintenanceErrorResponse{
+				Error: "server is in maintenance mode",
+				Code:  maintenanceErrorCode,
+			})
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (h *maintenanceHandler) getMaintenance(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, maintenanceResponse{Enabled: h.enabled.Load()})
+}
+
+// updateMaintenance turns maintenance mode on or off. Turning it off
+// queues a library scan, since scans were skipped while it was on.
+func (h *maintenanceHandler) updateMaintenance(w http.ResponseWriter, r *http.Request) {
+	var request maintenanceRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil || request.Enabled == nil {
+		writeError(w, http.StatusBadRequest, "invalid maintenance payload")
+		return
+	}
+	enabled := *request.Enabled
+
+	if err := settings.Maintenance.Set(r.Context(), h.settings, enabled); err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to save maintenance mode")
+		return
+	}
+	wasEnabled := h.enabled.Load()
+	h.setEnabled(enabled)
+	if h.logger != nil && wasEnabled != enabled {
+		h.logger.Info("maintenance mode changed", "enabled", enabled)
+	}
+
+	response := maintenanceResponse{Enabled: enabled}
+	if wasEnabled && !enabled && h.scanner != nil {
+		if _, _, err := h.scanner.QueueScan(); err != nil {
+			if h.logger != nil {
+				h.logger.Warn("failed to queue scan after maintenance", "error", err)
+			}
+		} else {
+			response.ScanQueued = true
+		}
+	}
+	writeJSON(w, http.StatusOK, response)
+}
+
+// readyz reports whether the server should receive traffic: 503 while in
+// maintenance mode, unlike /health, which only says the process is up.
+func (h *maintenanceHandler) readyz(w http.ResponseWriter, r *http.Request) {
+	if h.enabled.Load() {
+		writeJSON(w, http.StatusServiceUnavailable, readinessResponse{Status: "maintenance", Maintenance: true})
+		return
+	}
+	writeJSON(w, http.StatusOK, readinessResponse{Status: "ready"})
+}
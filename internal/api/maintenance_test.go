@@ -0,0 +1,119 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	imagesvc "mynewmangaui/internal/image"
+	scansvc "mynewmangaui/internal/scan"
+)
+
+func TestMaintenanceMode(t *testing.T) {
+	server := newTestServer(t, `{"server":{"adminToken":"secret"}}`)
+	writeChapter(t, server.root, "Alpha", "Chapter 1", 1)
+	server.scan()
+	toggle := func(server *testServer, body string) maintenanceResponse {
+		t.Helper()
+		rec := server.do(http.MethodPost, "/api/admin/maintenance", body, "X-Admin-Token", "secret")
+		if rec.Code != http.StatusOK {
+			t.Fatalf("maintenance status = %d, body %s", rec.Code, rec.Body.String())
+		}
+		return decodeJSON[maintenanceResponse](t, rec)
+	}
+	assertReady := func(server *testServer, want int) {
+		t.Helper()
+		if rec := server.do(http.MethodGet, "/readyz", ""); rec.Code != want {
+			t.Fatalf("readyz = %d, want %d", rec.Code, want)
+		}
+	}
+
+	if rec := server.do(http.MethodPost, "/api/admin/maintenance", `{}`, "X-Admin-Token", "secret"); rec.Code != http.StatusBadRequest {
+		t.Errorf("toggle without enabled = %d, want 400", rec.Code)
+	}
+	if rec := server.do(http.MethodPost, "/api/admin/maintenance", `{"enabled":true}`); rec.Code != http.StatusUnauthorized && rec.Code != http.StatusForbidden {
+		t.Errorf("toggle without the admin token = %d, want it refused", rec.Code)
+	}
+	assertReady(server, http.StatusOK)
+
+	if got := toggle(server, `{"enabled":true}`); !got.Enabled {
+		t.Fatalf("maintenance response = %+v, want enabled", got)
+	}
+	assertReady(server, http.StatusServiceUnavailable)
+	rec := server.do(http.MethodGet, "/api/library", "")
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Fatalf("library during maintenance = %d, want 503", rec.Code)
+	}
+	if got := decodeJSON[maintenanceErrorResponse](t, rec); got.Code != maintenanceErrorCode {
+		t.Errorf("maintenance error = %+v, want code %q", got, maintenanceErrorCode)
+	}
+	if rec := server.do(http.MethodGet, "/api/admin/maintenance", "", "X-Admin-Token", "secret"); rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"enabled":true`) {
+		t.Errorf("admin route during maintenance = %d %s, want it reachable", rec.Code, rec.Body.String())
+	}
+	if rec := server.do(http.MethodGet, "/health", ""); rec.Code != http.StatusOK {
+		t.Errorf("health during maintenance = %d, want 200", rec.Code)
+	}
+
+	// Scans are refused, and queued ones wait for maintenance to end.
+	writeChapter(t, server.root, "Beta", "Chapter 1", 1)
+	if _, err := server.scanner.Scan(t.Context()); !errors.Is(err, scansvc.ErrPaused) {
+		t.Fatalf("scan during maintenance: %v, want ErrPaused", err)
+	}
+	queued, _, err := server.scanner.QueueScan()
+	if err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(100 * time.Millisecond)
+	if got := server.queryString(`SELECT COUNT(*) FROM manga`); got != "1" {
+		t.Fatalf("manga during maintenance = %s, want the scan held back", got)
+	}
+
+	// The flag outlives the router, as it would a restart.
+	scanner := scansvc.NewService(server.db, []scansvc.Bookshelf{{Name: "main", Path: server.root}}, scansvc.Options{}, testLogger())
+	restarted := &testServer{t: t, db: server.db, root: server.root, config: server.config, scanner: scanner, handler: NewRouter(Dependencies{
+		Logger:  testLogger(),
+		Config:  server.config,
+		DB:      server.db,
+		Scanner: scanner,
+		Images:  imagesvc.NewService(server.db, server.config.Storage.CachePath, testLogger()),
+	})}
+	assertReady(restarted, http.StatusServiceUnavailable)
+	if !scanner.Paused() {
+		t.Fatal("scanner after restart is not paused")
+	}
+	if got := toggle(restarted, `{"enabled":false}`); got.Enabled || !got.ScanQueued {
+		t.Fatalf("maintenance response = %+v, want disabled with a scan queued", got)
+	}
+	assertReady(restarted, http.StatusOK)
+	if rec := restarted.do(http.MethodGet, "/api/library", ""); rec.Code != http.StatusOK {
+		t.Fatalf("library after maintenance = %d, want 200", rec.Code)
+	}
+
+	// waitFor polls until done reports true.
+	waitFor := func(what string, done func() bool) {
+		t.Helper()
+		deadline := time.Now().Add(10 * time.Second)
+		for !done() {
+			if time.Now().After(deadline) {
+				t.Fatalf("%s did not happen", what)
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+	waitFor("scan queued on leaving maintenance", func() bool {
+		status := scanner.Status()
+		return server.queryString(`SELECT COUNT(*) FROM manga`) == "2" && !status.Running && status.QueueDepth == 0
+	})
+
+	// Resuming the original scanner lets its held scan run.
+	toggle(server, `{"enabled":false}`)
+	waitFor("held scan", func() bool {
+		run, ok := server.scanner.RunLog(queued.RunID)
+		return ok && !run.Running
+	})
+	if run, _ := server.scanner.RunLog(queued.RunID); run.Error != "" {
+		t.Fatalf("held scan failed: %s", run.Error)
+	}
+}
@@ -1,39 +1,93 @@
 package api
 
 import (
+	"context"
 	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
 	"net/http"
+	"os"
+	"path/filepath"
 	"strconv"
+	"strings"
+	"syscall"
+	"time"
 
 	"github.com/go-chi/chi/v5"
+
+	"mynewmangaui/internal/clock"
+	"mynewmangaui/internal/config"
+	"mynewmangaui/internal/media"
+	"mynewmangaui/internal/store"
 )
 
 type mangaHandler struct {
-	db *sql.DB
+	db         *sql.DB
+	store      *store.Store
+	storage    config.StorageConfig
+	pagination config.PageLimits
+	counts     *LibraryCountCache
+	clock      clock.Clock
 }
 
 type mangaDetailResponse struct {
-	ID            string    `json:"id"`
-	BookshelfID   string    `json:"bookshelfId"`
-	BookshelfName string    `json:"bookshelfName"`
-	Title         string    `json:"title"`
-	ChapterCount  int       `json:"chapterCount"`
-	PageCount     int       `json:"pageCount"`
-	UpdatedAt     string    `json:"updatedAt"`
-	CoverThumbURL string    `json:"coverThumbUrl"`
-	Tags          []tagItem `json:"tags"`
+	ID            string `json:"id"`
+	BookshelfID   string `json:"bookshelfId"`
+	BookshelfName string `json:"bookshelfName"`
+	Title         string `json:"title"`
+	ChapterCount  int    `json:"chapterCount"`
+	PageCount     int    `json:"pageCount"`
+	UpdatedAt     string `json:"updatedAt"`
+	CoverThumbURL string `json:"coverThumbUrl"`
+	CoverBlurhash string `json:"coverBlurhash"`
+	Favorite      bool   `json:"favorite"`
+	SortName      string `json:"sortName"`
+	Collection    string `json:"collection"`
+	// ReadingDirection is ltr, rtl or vertical.
+	ReadingDirection string `json:"readingDirection"`
+	// ReadingMode is the reader's default mode: paged, continuous or double.
+	ReadingMode string `json:"readingMode"`
+	// FolderName is the folder or archive name the title was derived from.
+	FolderName string    `json:"folderName"`
+	Tags       []tagItem `json:"tags"`
+	Path       string    `json:"path,omitempty"`
+}
+
+type mangaSettingsRequest struct {
+	SortName   *string `json:"sortName"`
+	Collection *string `json:"collection"`
+}
+
+type readingModeRequest struct {
+	ReadingMode *string `json:"readingMode"`
+}
+
+type favoriteUpdateRequest struct {
+	Favorite *bool `json:"favorite"`
 }
 
 type chapterItem struct {
 	ID        string   `json:"id"`
 	Title     string   `json:"title"`
 	Number    *float64 `json:"number,omitempty"`
+	Volume    *int     `json:"volume,omitempty"`
 	PageCount int      `json:"pageCount"`
-	UpdatedAt string   `json:"updatedAt"`
+	// ExcludedPages counts the pages left out by the page exclusion
+	// patterns; they are not part of PageCount or the page indexes.
+	ExcludedPages int    `json:"excludedPages"`
+	UpdatedAt     string `json:"updatedAt"`
+	// PossiblyIncomplete marks chapters that were still being written when
+	// last scanned.
+	PossiblyIncomplete bool `json:"possiblyIncomplete"`
 }
 
 type chaptersResponse struct {
 	Items   []chapterItem `json:"items"`
+	Query   string        `json:"query,omitempty"`
+	Page    int           `json:"page"`
+	Limit   int           `json:"limit"`
 	Total   int           `json:"total"`
 	HasMore bool          `json:"hasMore"`
 }
@@ -45,16 +99,62 @@ type chapterPageItem struct {
 	Height    int    `json:"height"`
 	Mime      string `json:"mime"`
 	SizeBytes int64  `json:"sizeBytes"`
-	ImageURL  string `json:"imageUrl"`
+	// Animated marks animated WebP, GIF and APNG pages, which are served
+	// as stored so the reader can play them.
+	Animated bool   `json:"animated"`
+	ImageURL string `json:"imageUrl"`
+}
+
+// maxPageWindowRadius caps how many pages on each side of the current one a
+// page window returns.
+const maxPageWindowRadius = 20
+
+// defaultPageWindowRadius is the radius of a page window that names none.
+const defaultPageWindowRadius = 3
+
+type chapterWindowItem struct {
+	chapterPageItem
+	ThumbURL string `json:"thumbUrl"`
+}
+
+type chapterWindowResponse struct {
+	ChapterID string `json:"chapterId"`
+	Center    int    `json:"center"`
+	Radius    int    `json:"radius"`
+	// Start and End are the positions of the first and last page returned,
+	// the window clamped to the chapter.
+	Start     int                 `json:"start"`
+	End       int                 `json:"end"`
+	PageCount int                 `json:"pageCount"`
+	Pages     []chapterWindowItem `json:"pages"`
 }
 
 type chapterPagesResponse struct {
 	ChapterID string            `json:"chapterId"`
 	Pages     []chapterPageItem `json:"pages"`
+	// PageCount and TotalBytes cover the whole chapter, also when only one
+	// page of the listing is returned.
+	PageCount  int   `json:"pageCount"`
+	TotalBytes int64 `json:"totalBytes"`
+	Page       int   `json:"page,omitempty"`
+	Limit      int   `json:"limit,omitempty"`
+	HasMore    bool  `json:"hasMore"`
 }
 
-func newMangaHandler(db *sql.DB) *mangaHandler {
-	return &mangaHandler{db: db}
+type mangaDeleteResponse struct {
+	Deleted      bool   `json:"deleted"`
+	MangaID      string `json:"mangaId"`
+	Title        string `json:"title"`
+	ChapterCount int    `json:"chapterCount"`
+	PageCount    int    `json:"pageCount"`
+	FilesTrashed bool   `json:"filesTrashed"`
+	// TrashError says why the files could not be moved to the trash after
+	// the manga was deleted; they are still where they were.
+	TrashError string `json:"trashError,omitempty"`
+}
+
+func newMangaHandler(db *sql.DB, store *store.Store, storage config.StorageConfig, pagination config.PageLimits, counts *LibraryCountCache, clock clock.Clock) *mangaHandler {
+	return &mangaHandler{db: db, store: store, storage: storage, pagination: pagination, counts: counts, clock: clock}
 }
 
 func (h *mangaHandler) getManga(w http.ResponseWriter, r *http.Request) {
@@ -62,30 +162,7 @@ func (h *mangaHandler) getManga(w http.ResponseWriter, r *http.Request) {
 	response := mangaDetailResponse{
 		CoverThumbURL: "/api/images/covers/" + id + "/thumb",
 	}
-
-	err := h.db.QueryRowContext(r.Context(), `
-		SELECT
-			m.id,
-			b.id,
-			b.name,
-			m.title,
-			COUNT(c.id) AS chapter_count,
-			m.page_count,
-			m.updated_at
-		FROM manga m
-		LEFT JOIN bookshelf b ON b.id = m.bookshelf_id
-		LEFT JOIN chapter c ON c.manga_id = m.id
-		WHERE m.id = ?
-		GROUP BY m.id, b.id, b.name, m.title, m.page_count, m.updated_at
-	`, id).Scan(
-		&response.ID,
-		&response.BookshelfID,
-		&response.BookshelfName,
-		&response.Title,
-		&response.ChapterCount,
-		&response.PageCount,
-		&response.UpdatedAt,
-	)
+	manga, err := h.store.GetManga(r.Context(), id)
 	if err == sql.ErrNoRows {
 		writeError(w, http.StatusNotFound, "manga not found")
 		return
@@ -95,24 +172,196 @@ func (h *mangaHandler) getManga(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	response.ID = manga.ID
+	response.BookshelfID = manga.BookshelfID
+	response.BookshelfName = manga.BookshelfName
+	response.Title = manga.Title
+	response.ChapterCount = manga.ChapterCount
+	response.PageCount = manga.PageCount
+	response.UpdatedAt = manga.UpdatedAt
+	response.Favorite = manga.Favorite
+	response.SortName = manga.SortName
+	response.Collection = manga.Collection
+	response.ReadingDirection = manga.ReadingDirection
+	response.FolderName = manga.FolderName
+	response.CoverBlurhash = manga.CoverBlurhash
+	response.ReadingMode = manga.ReadingMode
+
 	tags, err := loadMangaTags(r.Context(), h.db, id)
 	if err != nil {
 		writeError(w, http.StatusInternalServerError, "failed to load manga tags")
 		return
 	}
 	response.Tags = tags
+	if isAdminRequest(r) {
+		response.Path = manga.Path
+	}
 
 	writeJSON(w, http.StatusOK, response)
 }
 
+func (h *mangaHandler) updateFavorite(w http.ResponseWriter, r *http.Request) {
+	if h.db == nil {
+		writeError(w, http.StatusInternalServerError, "database not initialized")
+		return
+	}
+
+	mangaID := chi.URLParam(r, "mangaID")
+	var request favoriteUpdateRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil || request.Favorite == nil {
+		writeError(w, http.StatusBadRequest, "invalid favorite payload")
+		return
+	}
+
+	result, err := h.db.ExecContext(r.Context(), `UPDATE manga SET favorite = ? WHERE id = ?`, boolToInt(*request.Favorite), mangaID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to update favorite")
+		return
+	}
+	affected, _ := result.RowsAffected()
+	if affected == 0 {
+		writeError(w, http.StatusNotFound, "manga not found")
+		return
+	}
+	h.counts.Invalidate()
+
+	writeJSON(w, http.StatusOK, map[string]any{
+		"mangaId":  mangaID,
+		"favorite": *request.Favorite,
+	})
+}
+
+// updateSettings changes a manga's sort name and collection. Both survive
+// rescans; an empty sort name hands it back to the scanner, which keeps it
+// equal to the title, and an empty collection removes the manga from its
+// collection. Omitted fields are left as they are.
+func (h *mangaHandler) updateSettings(w http.ResponseWriter, r *http.Request) {
+	if h.db == nil {
+		writeError(w, http.StatusInternalServerError, "database not initialized")
+		return
+	}
+
+	mangaID := chi.URLParam(r, "mangaID")
+	var request mangaSettingsRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil || (request.SortName == nil && request.Collection == nil) {
+		writeError(w, http.StatusBadRequest, "invalid settings payload")
+		return
+	}
+
+	assignments := make([]string, 0, 3)
+	args := make([]any, 0, 4)
+	if request.SortName != nil {
+		sortName := strings.TrimSpace(*request.SortName)
+		if sortName == "" {
+			assignments = append(assignments, "sort_name = title", "sort_name_locked = 0")
+		} else {
+			assignments = append(assignments, "sort_name = ?", "sort_name_locked = 1")
+			args = append(args, sortName)
+		}
+	}
+	if request.Collection != nil {
+		assignments = append(assignments, "collection = ?")
+		args = append(args, strings.TrimSpace(*request.Collection))
+	}
+	args = append(args, mangaID)
+
+	result, err := h.db.ExecContext(r.Context(), `UPDATE manga SET `+strings.Join(assignments, ", ")+` WHERE id = ?`, args...)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to update manga settings")
+		return
+	}
+	affected, _ := result.RowsAffected()
+	if affected == 0 {
+		writeError(w, http.StatusNotFound, "manga not found")
+		return
+	}
+	h.counts.Invalidate()
+
+	var sortName, collection string
+	var locked bool
+	if err := h.db.QueryRowContext(r.Context(), `SELECT sort_name, sort_name_locked, collection FROM manga WHERE id = ?`, mangaID).Scan(&sortName, &locked, &collection); err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to load manga settings")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]any{
+		"mangaId":        mangaID,
+		"sortName":       sortName,
+		"sortNameLocked": locked,
+		"collection":     collection,
+	})
+}
+
+// updateReadingMode sets the mode the reader opens a manga in. The choice
+// survives rescans; an empty mode hands it back to the scanner, which
+// infers it from the reading direction.
+func (h *mangaHandler) updateReadingMode(w http.ResponseWriter, r *http.Request) {
+	if h.db == nil {
+		writeError(w, http.StatusInternalServerError, "database not initialized")
+		return
+	}
+
+	mangaID := chi.URLParam(r, "mangaID")
+	var request readingModeRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil || request.ReadingMode == nil {
+		writeError(w, http.StatusBadRequest, "invalid reading mode payload")
+		return
+	}
+	mode := strings.TrimSpace(*request.ReadingMode)
+	if mode != "" && !media.ValidReadingMode(mode) {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("unknown reading mode %q", mode))
+		return
+	}
+
+	query := `UPDATE manga SET reading_mode = ?, reading_mode_locked = 1 WHERE id = ?`
+	args := []any{mode, mangaID}
+	if mode == "" {
+		query = `UPDATE manga SET reading_mode = CASE WHEN reading_direction = ? THEN ? ELSE ? END, reading_mode_locked = 0 WHERE id = ?`
+		args = []any{media.ReadingVertical, media.ReadingModeContinuous, media.ReadingModePaged, mangaID}
+	}
+	result, err := h.db.ExecContext(r.Context(), query, args...)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to update reading mode")
+		return
+	}
+	affected, _ := result.RowsAffected()
+	if affected == 0 {
+		writeError(w, http.StatusNotFound, "manga not found")
+		return
+	}
+
+	var locked bool
+	if err := h.db.QueryRowContext(r.Context(), `SELECT reading_mode, reading_mode_locked FROM manga WHERE id = ?`, mangaID).Scan(&mode, &locked); err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to load reading mode")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]any{
+		"mangaId":           mangaID,
+		"readingMode":       mode,
+		"readingModeLocked": locked,
+	})
+}
+
 func (h *mangaHandler) getChapters(w http.ResponseWriter, r *http.Request) {
 	id := chi.URLParam(r, "mangaID")
+	page, limit, offset := parsePageParams(r, h.pagination)
+	query := strings.TrimSpace(r.URL.Query().Get("q"))
+	filter, filterArgs := buildChapterFilter(id, query)
+
+	var total int
+	if err := h.db.QueryRowContext(r.Context(), `SELECT COUNT(*) FROM chapter WHERE `+filter, filterArgs...).Scan(&total); err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to count chapters")
+		return
+	}
+
 	rows, err := h.db.QueryContext(r.Context(), `
-		SELECT id, title, chapter_number, page_count, updated_at
+		SELECT id, title, chapter_number, volume, page_count, excluded_page_count, updated_at, possibly_incomplete
 		FROM chapter
-		WHERE manga_id = ?
+		WHERE `+filter+`
 		ORDER BY chapter_number ASC, title ASC, id ASC
-	`, id)
+		LIMIT ? OFFSET ?
+	`, append(filterArgs, limit, offset)...)
 	if err != nil {
 		writeError(w, http.StatusInternalServerError, "failed to load chapters")
 		return
@@ -122,7 +371,7 @@ func (h *mangaHandler) getChapters(w http.ResponseWriter, r *http.Request) {
 	items := make([]chapterItem, 0)
 	for rows.Next() {
 		var item chapterItem
-		if err := rows.Scan(&item.ID, &item.Title, &item.Number, &item.PageCount, &item.UpdatedAt); err != nil {
+		if err := rows.Scan(&item.ID, &item.Title, &item.Number, &item.Volume, &item.PageCount, &item.ExcludedPages, &item.UpdatedAt, &item.PossiblyIncomplete); err != nil {
 			writeError(w, http.StatusInternalServerError, "failed to read chapter row")
 			return
 		}
@@ -135,19 +384,60 @@ func (h *mangaHandler) getChapters(w http.ResponseWriter, r *http.Request) {
 
 	writeJSON(w, http.StatusOK, chaptersResponse{
 		Items:   items,
-		Total:   len(items),
-		HasMore: false,
+		Query:   query,
+		Page:    page,
+		Limit:   limit,
+		Total:   total,
+		HasMore: offset+len(items) < total,
 	})
 }
 
+// buildChapterFilter restricts chapters to the manga and, when a query is
+// given, to matching chapters. Numeric queries match by chapter number, so
+// "105" finds chapter 105 and 105.5 while "105.5" only finds 105.5; other
+// queries match a title substring.
+func buildChapterFilter(mangaID string, query string) (string, []any) {
+	if query == "" {
+		return "manga_id = ?", []any{mangaID}
+	}
+
+	if number, err := strconv.ParseFloat(query, 64); err == nil {
+		if strings.Contains(query, ".") {
+			return "manga_id = ? AND chapter_number = ?", []any{mangaID, number}
+		}
+		return "manga_id = ? AND chapter_number >= ? AND chapter_number < ?", []any{mangaID, number, number + 1}
+	}
+
+	return `manga_id = ? AND title LIKE ? ESCAPE '\'`, []any{mangaID, "%" + store.EscapeLike(query) + "%"}
+}
+
+// getChapterPages lists a chapter's pages with their sizes and the size of
+// the whole chapter. The listing is complete unless page or limit is given,
+// in which case it is paginated like the chapter list.
 func (h *mangaHandler) getChapterPages(w http.ResponseWriter, r *http.Request) {
 	chapterID := chi.URLParam(r, "chapterID")
+	response := chapterPagesResponse{ChapterID: chapterID}
+	if err := h.db.QueryRowContext(r.Context(), `
+		SELECT COUNT(*), COALESCE(SUM(size_bytes), 0)
+		FROM page
+		WHERE chapter_id = ?
+	`, chapterID).Scan(&response.PageCount, &response.TotalBytes); err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to load chapter pages")
+		return
+	}
+
+	limit, offset := -1, 0
+	if r.URL.Query().Has("page") || r.URL.Query().Has("limit") {
+		response.Page, response.Limit, offset = parsePageParams(r, h.pagination)
+		limit = response.Limit
+	}
 	rows, err := h.db.QueryContext(r.Context(), `
-		SELECT id, page_index, width, height, mime, size_bytes
+		SELECT id, page_index, width, height, mime, size_bytes, is_animated
 		FROM page
 		WHERE chapter_id = ?
 		ORDER BY page_index ASC
-	`, chapterID)
+		LIMIT ? OFFSET ?
+	`, chapterID, limit, offset)
 	if err != nil {
 		writeError(w, http.StatusInternalServerError, "failed to load chapter pages")
 		return
@@ -157,7 +447,7 @@ func (h *mangaHandler) getChapterPages(w http.ResponseWriter, r *http.Request) {
 	items := make([]chapterPageItem, 0)
 	for rows.Next() {
 		var item chapterPageItem
-		if err := rows.Scan(&item.ID, &item.Index, &item.Width, &item.Height, &item.Mime, &item.SizeBytes); err != nil {
+		if err := rows.Scan(&item.ID, &item.Index, &item.Width, &item.Height, &item.Mime, &item.SizeBytes, &item.Animated); err != nil {
 			writeError(w, http.StatusInternalServerError, "failed to read page row")
 			return
 		}
@@ -169,8 +459,270 @@ func (h *mangaHandler) getChapterPages(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	writeJSON(w, http.StatusOK, chapterPagesResponse{
+	response.Pages = items
+	response.HasMore = offset+len(items) < response.PageCount
+	writeJSON(w, http.StatusOK, response)
+}
+
+// getChapterWindow returns the pages around a position in a chapter, so a
+// reader can warm its cache for the next and previous pages in one request.
+// center is the 0-based position of the current page in reading order.
+func (h *mangaHandler) getChapterWindow(w http.ResponseWriter, r *http.Request) {
+	chapterID := chi.URLParam(r, "chapterID")
+	query := r.URL.Query()
+	center, err := strconv.Atoi(query.Get("center"))
+	if err != nil || center < 0 {
+		writeError(w, http.StatusBadRequest, "center must be a non-negative page position")
+		return
+	}
+	radius := defaultPageWindowRadius
+	if raw := query.Get("radius"); raw != "" {
+		radius, err = strconv.Atoi(raw)
+		if err != nil || radius < 0 {
+			writeError(w, http.StatusBadRequest, "radius must be a non-negative number of pages")
+			return
+		}
+	}
+	radius = min(radius, maxPageWindowRadius)
+
+	var pageCount int
+	if err := h.db.QueryRowContext(r.Context(), `SELECT page_count FROM chapter WHERE id = ?`, chapterID).Scan(&pageCount); err != nil {
+		if err == sql.ErrNoRows {
+			writeError(w, http.StatusNotFound, "chapter not found")
+			return
+		}
+		writeError(w, http.StatusInternalServerError, "failed to load chapter")
+		return
+	}
+	if center >= pageCount {
+		writeError(w, http.StatusBadRequest, "center is past the last page")
+		return
+	}
+
+	response := chapterWindowResponse{
 		ChapterID: chapterID,
-		Pages:     items,
-	})
+		Center:    center,
+		Radius:    radius,
+		Start:     max(center-radius, 0),
+		End:       min(center+radius, pageCount-1),
+		PageCount: pageCount,
+	}
+	rows, err := h.db.QueryContext(r.Context(), `
+		SELECT id, page_index, width, height, mime, size_bytes, is_animated
+		FROM page
+		WHERE chapter_id = ?
+		ORDER BY page_index ASC
+		LIMIT ? OFFSET ?
+	`, chapterID, response.End-response.Start+1, response.Start)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to load chapter pages")
+		return
+	}
+	defer rows.Close()
+
+	response.Pages = make([]chapterWindowItem, 0, response.End-response.Start+1)
+	for rows.Next() {
+		var item chapterWindowItem
+		if err := rows.Scan(&item.ID, &item.Index, &item.Width, &item.Height, &item.Mime, &item.SizeBytes, &item.Animated); err != nil {
+			writeError(w, http.StatusInternalServerError, "failed to read page row")
+			return
+		}
+		item.ImageURL = "/api/images/chapters/" + chapterID + "/pages/" + strconv.Itoa(item.Index)
+		item.ThumbURL = item.ImageURL + "/thumb"
+		response.Pages = append(response.Pages, item)
+	}
+	if err := rows.Err(); err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to iterate page rows")
+		return
+	}
+	writeJSON(w, http.StatusOK, response)
+}
+
+func (h *mangaHandler) deleteManga(w http.ResponseWriter, r *http.Request) {
+	if h.db == nil {
+		writeError(w, http.StatusInternalServerError, "database not initialized")
+		return
+	}
+
+	mangaID := strings.TrimSpace(chi.URLParam(r, "mangaID"))
+	if mangaID == "" {
+		writeError(w, http.StatusBadRequest, "manga id is required")
+		return
+	}
+
+	deleteFiles := r.URL.Query().Get("deleteFiles") == "true"
+	if deleteFiles && !h.storage.AllowFileDeletion {
+		writeError(w, http.StatusForbidden, "file deletion is disabled")
+		return
+	}
+
+	var title string
+	var mangaPath string
+	err := h.db.QueryRowContext(r.Context(), `SELECT title, path FROM manga WHERE id = ?`, mangaID).Scan(&title, &mangaPath)
+	if err == sql.ErrNoRows {
+		writeError(w, http.StatusNotFound, "manga not found")
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to load manga")
+		return
+	}
+
+	if deleteFiles && !pathWithinBookshelves(mangaPath, h.storage.Bookshelves) {
+		writeError(w, http.StatusBadRequest, "manga path is outside the configured bookshelves")
+		return
+	}
+
+	tx, err := h.db.BeginTx(r.Context(), nil)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to start manga deletion")
+		return
+	}
+
+	now := h.clock.Now()
+	response := mangaDeleteResponse{MangaID: mangaID, Title: title}
+	response.ChapterCount, response.PageCount, err = deleteMangaRows(r.Context(), tx, mangaID, now)
+	if err != nil {
+		tx.Rollback()
+		writeError(w, http.StatusInternalServerError, "failed to delete manga")
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to save manga deletion")
+		return
+	}
+	h.counts.Invalidate()
+	response.Deleted = true
+
+	// The files move only once the rows are gone, so a failed commit never
+	// leaves a manga whose files are in the trash. A failed move is reported
+	// but keeps the deletion: the tombstones stop the next scan from
+	// restoring the manga's chapters under new ids.
+	if deleteFiles {
+		if err := moveToTrash(mangaPath, h.storage.TrashPath, now); err != nil {
+			response.TrashError = err.Error()
+		} else {
+			response.FilesTrashed = true
+		}
+	}
+
+	writeJSON(w, http.StatusOK, response)
+}
+
+func deleteMangaRows(ctx context.Context, tx *sql.Tx, mangaID string, now time.Time) (int, int, error) {
+	var chapterCount int
+	var pageCount int
+	if err := tx.QueryRowContext(ctx, `
+		SELECT
+			(SELECT COUNT(*) FROM chapter WHERE manga_id = ?),
+			(SELECT COUNT(*) FROM page p JOIN chapter c ON c.id = p.chapter_id WHERE c.manga_id = ?)
+	`, mangaID, mangaID).Scan(&chapterCount, &pageCount); err != nil {
+		return 0, 0, err
+	}
+
+	if _, err := tx.ExecContext(ctx, `
+		INSERT OR REPLACE INTO chapter_tombstone(chapter_id, manga_id, removed_at)
+		SELECT id, manga_id, ? FROM chapter WHERE manga_id = ?
+	`, now.UTC().Format("2006-01-02 15:04:05"), mangaID); err != nil {
+		return 0, 0, err
+	}
+	for _, query := range []string{
+		`DELETE FROM page WHERE chapter_id IN (SELECT id FROM chapter WHERE manga_id = ?)`,
+		`DELETE FROM chapter WHERE manga_id = ?`,
+		`DELETE FROM manga_tag WHERE manga_id = ?`,
+		`DELETE FROM reading_progress WHERE manga_id = ?`,
+		`DELETE FROM bookmark WHERE manga_id = ?`,
+		`DELETE FROM manga WHERE id = ?`,
+	} {
+		if _, err := tx.ExecContext(ctx, query, mangaID); err != nil {
+			return 0, 0, err
+		}
+	}
+	return chapterCount, pageCount, nil
+}
+
+func pathWithinBookshelves(path string, bookshelves []config.BookshelfConfig) bool {
+	target, err := filepath.Abs(path)
+	if err != nil {
+		return false
+	}
+	for _, shelf := range bookshelves {
+		root, err := filepath.Abs(strings.TrimSpace(shelf.Path))
+		if err != nil || strings.TrimSpace(shelf.Path) == "" {
+			continue
+		}
+		rel, err := filepath.Rel(root, target)
+		if err != nil || rel == "." {
+			continue
+		}
+		if rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return true
+		}
+	}
+	return false
+}
+
+// moveToTrash moves path into trashPath under a name prefixed with now, so
+// manga deleted under the same name do not collide.
+func moveToTrash(path string, trashPath string, now time.Time) error {
+	if _, err := os.Stat(path); err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	if err := os.MkdirAll(trashPath, 0o755); err != nil {
+		return fmt.Errorf("create trash dir: %w", err)
+	}
+
+	// Archives kept open for page serving would block the move on Windows.
+	media.ForgetArchives(path)
+	target := filepath.Join(trashPath, now.UTC().Format("20060102-150405")+"_"+filepath.Base(path))
+	err := os.Rename(path, target)
+	if errors.Is(err, syscall.EXDEV) {
+		err = moveAcrossDevices(path, target)
+	}
+	if err != nil {
+		return fmt.Errorf("move %q to trash: %w", path, err)
+	}
+	return nil
+}
+
+// moveAcrossDevices moves path to target by copying and then removing it,
+// for a trash on another filesystem than the library, where rename fails.
+// A partial copy is removed again, leaving path untouched.
+func moveAcrossDevices(path string, target string) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	if info.IsDir() {
+		err = os.CopyFS(target, os.DirFS(path))
+	} else {
+		err = copyFile(path, target, info.Mode().Perm())
+	}
+	if err != nil {
+		os.RemoveAll(target)
+		return err
+	}
+	return os.RemoveAll(path)
+}
+
+func copyFile(path string, target string, perm os.FileMode) error {
+	in, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(target, os.O_WRONLY|os.O_CREATE|os.O_EXCL, perm)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
 }
@@ -0,0 +1,465 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"mynewmangaui/internal/media"
+)
+
+func TestDeleteMangaRequiresAdmin(t *testing.T) {
+	server := newTestServer(t, `{"server":{"adminToken":"secret"}}`)
+	writeChapter(t, server.root, "Alpha", "Chapter 1", 1)
+	server.scan()
+	mangaID := server.queryString(`SELECT id FROM manga`)
+
+	if rec := server.do(http.MethodDelete, "/api/manga/"+mangaID, ""); rec.Code != http.StatusForbidden {
+		t.Fatalf("status without admin token = %d, want 403", rec.Code)
+	}
+	if rec := server.do(http.MethodDelete, "/api/manga/"+mangaID, "", "X-Admin-Token", "secret"); rec.Code != http.StatusOK {
+		t.Fatalf("status with admin token = %d, body %s", rec.Code, rec.Body.String())
+	}
+}
+
+func TestDeleteMangaRows(t *testing.T) {
+	server := newTestServer(t, `{"server":{"adminToken":"secret"}}`)
+	writeChapter(t, server.root, "Alpha", "Chapter 1", 2)
+	writeChapter(t, server.root, "Alpha", "Chapter 2", 2)
+	writeChapter(t, server.root, "Beta", "Chapter 1", 2)
+	server.scan()
+	alpha := server.queryString(`SELECT id FROM manga WHERE title = 'Alpha'`)
+	beta := server.queryString(`SELECT id FROM manga WHERE title = 'Beta'`)
+	if rec := server.do(http.MethodPost, "/api/tags", `{"name":"Action"}`); rec.Code >= 300 {
+		t.Fatalf("create tag status = %d, body %s", rec.Code, rec.Body.String())
+	}
+	tagID := server.queryString(`SELECT id FROM tag WHERE name = 'Action'`)
+	for _, mangaID := range []string{alpha, beta} {
+		if rec := server.do(http.MethodPut, "/api/manga/"+mangaID+"/tags", `{"tagIds":["`+tagID+`"]}`); rec.Code != http.StatusOK {
+			t.Fatalf("tag status = %d, body %s", rec.Code, rec.Body.String())
+		}
+		chapterID := server.queryString(`SELECT id FROM chapter WHERE manga_id = ? AND title = 'Chapter 1'`, mangaID)
+		if rec := server.do(http.MethodPut, "/api/chapters/"+chapterID+"/progress", `{"pageIndex":1}`); rec.Code != http.StatusOK {
+			t.Fatalf("progress status = %d, body %s", rec.Code, rec.Body.String())
+		}
+		if rec := server.do(http.MethodPost, "/api/chapters/"+chapterID+"/bookmarks", `{"pageIndex":0}`); rec.Code != http.StatusCreated {
+			t.Fatalf("bookmark status = %d, body %s", rec.Code, rec.Body.String())
+		}
+	}
+
+	rec := server.do(http.MethodDelete, "/api/manga/"+alpha, "", "X-Admin-Token", "secret")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("delete status = %d, body %s", rec.Code, rec.Body.String())
+	}
+	if response := decodeJSON[mangaDeleteResponse](t, rec); !response.Deleted || response.ChapterCount != 2 || response.PageCount != 4 {
+		t.Fatalf("response = %+v, want 2 chapters and 4 pages deleted", response)
+	}
+
+	// Every row of the deleted manga is gone; Beta keeps all of its own.
+	for _, query := range []string{
+		`SELECT COUNT(*) FROM manga WHERE id = ?`,
+		`SELECT COUNT(*) FROM chapter WHERE manga_id = ?`,
+		`SELECT COUNT(*) FROM page p JOIN chapter c ON c.id = p.chapter_id WHERE c.manga_id = ?`,
+		`SELECT COUNT(*) FROM manga_tag WHERE manga_id = ?`,
+		`SELECT COUNT(*) FROM reading_progress WHERE manga_id = ?`,
+		`SELECT COUNT(*) FROM bookmark WHERE manga_id = ?`,
+	} {
+		if got := server.queryString(query, alpha); got != "0" {
+			t.Errorf("%s for the deleted manga = %s, want 0", query, got)
+		}
+		if got := server.queryString(query, beta); got == "0" {
+			t.Errorf("%s for the kept manga = 0", query)
+		}
+	}
+	if got := server.queryString(`SELECT COUNT(*) FROM page`); got != "2" {
+		t.Errorf("pages left = %s, want Beta's 2", got)
+	}
+	if got := server.queryString(`SELECT COUNT(*) FROM tag WHERE id = ?`, tagID); got != "1" {
+		t.Errorf("tag rows = %s, want the tag itself kept", got)
+	}
+	if rec := server.do(http.MethodDelete, "/api/manga/"+alpha, "", "X-Admin-Token", "secret"); rec.Code != http.StatusNotFound {
+		t.Errorf("second delete = %d, want 404", rec.Code)
+	}
+}
+
+func TestDeleteMangaFiles(t *testing.T) {
+	t.Run("file deletion disabled", func(t *testing.T) {
+		server := newTestServer(t, "")
+		writeChapter(t, server.root, "Alpha", "Chapter 1", 1)
+		server.scan()
+		mangaID := server.queryString(`SELECT id FROM manga`)
+
+		rec := server.do(http.MethodDelete, "/api/manga/"+mangaID+"?deleteFiles=true", "")
+		if rec.Code != http.StatusForbidden {
+			t.Fatalf("status = %d, want 403", rec.Code)
+		}
+		if _, err := os.Stat(filepath.Join(server.root, "Alpha")); err != nil {
+			t.Fatalf("manga files: %v", err)
+		}
+		if count := server.queryString(`SELECT COUNT(*) FROM manga`); count != "1" {
+			t.Fatalf("manga rows = %s, want 1", count)
+		}
+	})
+
+	t.Run("manga outside the bookshelves", func(t *testing.T) {
+		server := newTestServer(t, `{"storage":{"allowFileDeletion":true}}`)
+		writeChapter(t, server.root, "Alpha", "Chapter 1", 1)
+		server.scan()
+		mangaID := server.queryString(`SELECT id FROM manga`)
+		outside := filepath.Join(t.TempDir(), "Alpha")
+		writeChapter(t, filepath.Dir(outside), "Alpha", "Chapter 1", 1)
+		if _, err := server.db.Exec(`UPDATE manga SET path = ? WHERE id = ?`, outside, mangaID); err != nil {
+			t.Fatal(err)
+		}
+
+		rec := server.do(http.MethodDelete, "/api/manga/"+mangaID+"?deleteFiles=true", "")
+		if rec.Code != http.StatusBadRequest || !strings.Contains(rec.Body.String(), "outside the configured bookshelves") {
+			t.Fatalf("status = %d %s, want 400", rec.Code, rec.Body.String())
+		}
+		if _, err := os.Stat(outside); err != nil {
+			t.Fatalf("files outside the bookshelves: %v", err)
+		}
+		if count := server.queryString(`SELECT COUNT(*) FROM manga`); count != "1" {
+			t.Fatalf("manga rows = %s, want 1", count)
+		}
+	})
+
+	t.Run("file deletion enabled", func(t *testing.T) {
+		server := newTestServer(t, `{"storage":{"allowFileDeletion":true}}`)
+		writeChapter(t, server.root, "Alpha", "Chapter 1", 2)
+		server.scan()
+		mangaID := server.queryString(`SELECT id FROM manga`)
+
+		rec := server.do(http.MethodDelete, "/api/manga/"+mangaID+"?deleteFiles=true", "")
+		if rec.Code != http.StatusOK {
+			t.Fatalf("status = %d, body %s", rec.Code, rec.Body.String())
+		}
+		response := decodeJSON[mangaDeleteResponse](t, rec)
+		if !response.Deleted || !response.FilesTrashed || response.TrashError != "" {
+			t.Fatalf("response = %+v", response)
+		}
+
+		if _, err := os.Stat(filepath.Join(server.root, "Alpha")); !os.IsNotExist(err) {
+			t.Fatalf("manga directory still in the library: %v", err)
+		}
+		trashed, err := filepath.Glob(filepath.Join(server.config.Storage.TrashPath, "*_Alpha", "Chapter 1", "*.png"))
+		if err != nil || len(trashed) != 2 {
+			t.Fatalf("pages in trash = %v, %v", trashed, err)
+		}
+		if count := server.queryString(`SELECT COUNT(*) FROM manga`); count != "0" {
+			t.Fatalf("manga rows = %s, want 0", count)
+		}
+		if count := server.queryString(`SELECT COUNT(*) FROM chapter_tombstone WHERE manga_id = ?`, mangaID); count != "1" {
+			t.Fatalf("chapter tombstones = %s, want 1", count)
+		}
+	})
+}
+
+func TestMoveToTrash(t *testing.T) {
+	source := t.TempDir()
+	writePNG(t, filepath.Join(source, "Alpha", "Chapter 1", "01.png"), 4, 4)
+	trash := filepath.Join(t.TempDir(), "trash")
+
+	now := time.Date(2026, 5, 1, 10, 0, 0, 0, time.FixedZone("CEST", 2*60*60))
+	if err := moveToTrash(filepath.Join(source, "Alpha"), trash, now); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(trash, "20260501-080000_Alpha", "Chapter 1", "01.png")); err != nil {
+		t.Fatalf("trashed page: %v", err)
+	}
+	// A path already gone has nothing to move.
+	if err := moveToTrash(filepath.Join(source, "Alpha"), trash, now); err != nil {
+		t.Errorf("move of a missing path: %v", err)
+	}
+}
+
+func TestMoveAcrossDevices(t *testing.T) {
+	source := t.TempDir()
+	writePNG(t, filepath.Join(source, "Alpha", "Chapter 1", "01.png"), 4, 4)
+	archive := filepath.Join(source, "Beta.cbz")
+	if err := os.WriteFile(archive, []byte("archive"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	trash := t.TempDir()
+
+	if err := moveAcrossDevices(filepath.Join(source, "Alpha"), filepath.Join(trash, "Alpha")); err != nil {
+		t.Fatalf("move directory: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(trash, "Alpha", "Chapter 1", "01.png")); err != nil {
+		t.Fatalf("copied page: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(source, "Alpha")); !os.IsNotExist(err) {
+		t.Fatalf("source directory not removed: %v", err)
+	}
+
+	if err := moveAcrossDevices(archive, filepath.Join(trash, "Beta.cbz")); err != nil {
+		t.Fatalf("move file: %v", err)
+	}
+	if raw, err := os.ReadFile(filepath.Join(trash, "Beta.cbz")); err != nil || string(raw) != "archive" {
+		t.Fatalf("copied archive = %q, %v", raw, err)
+	}
+	if _, err := os.Stat(archive); !os.IsNotExist(err) {
+		t.Fatalf("source archive not removed: %v", err)
+	}
+}
+
+func TestSearchChapters(t *testing.T) {
+	server := newTestServer(t, "")
+	for _, chapter := range []string{"Chapter 10", "Chapter 105", "Chapter 105_5", "Chapter 1050", "Extra Story"} {
+		writeChapter(t, server.root, "Alpha", chapter, 1)
+	}
+	server.scan()
+	// Folder names lose a trailing ".5" as an extension, so give the
+	// half chapter its number directly.
+	if _, err := server.db.Exec(`UPDATE chapter SET title = 'Chapter 105.5', chapter_number = 105.5 WHERE title = 'Chapter 105 5'`); err != nil {
+		t.Fatal(err)
+	}
+	mangaID := server.queryString(`SELECT id FROM manga`)
+
+	search := func(query string) chaptersResponse {
+		t.Helper()
+		rec := server.do(http.MethodGet, "/api/manga/"+mangaID+"/chapters?"+query, "")
+		if rec.Code != http.StatusOK {
+			t.Fatalf("status = %d, body %s", rec.Code, rec.Body.String())
+		}
+		return decodeJSON[chaptersResponse](t, rec)
+	}
+	titles := func(response chaptersResponse) []string {
+		titles := make([]string, 0, len(response.Items))
+		for _, item := range response.Items {
+			titles = append(titles, item.Title)
+		}
+		return titles
+	}
+
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		{query: "q=105", want: []string{"Chapter 105", "Chapter 105.5"}},
+		{query: "q=105.5", want: []string{"Chapter 105.5"}},
+		{query: "q=10", want: []string{"Chapter 10"}},
+		{query: "q=7", want: []string{}},
+		{query: "q=extra", want: []string{"Extra Story"}},
+		{query: "q=Chapter+10", want: []string{"Chapter 10", "Chapter 105", "Chapter 105.5", "Chapter 1050"}},
+		{query: "q=+story+", want: []string{"Extra Story"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			response := search(tt.query)
+			if got := titles(response); !slices.Equal(got, tt.want) || response.Total != len(tt.want) {
+				t.Fatalf("chapters = %q (total %d), want %q", got, response.Total, tt.want)
+			}
+		})
+	}
+
+	t.Run("paging", func(t *testing.T) {
+		first := search("q=Chapter&limit=3")
+		second := search("q=Chapter&limit=3&page=2")
+		if first.Total != 4 || !first.HasMore || len(first.Items) != 3 {
+			t.Fatalf("first page = %q, total %d, hasMore %v", titles(first), first.Total, first.HasMore)
+		}
+		if got := titles(second); !slices.Equal(got, []string{"Chapter 1050"}) || second.HasMore || second.Query != "Chapter" {
+			t.Fatalf("second page = %q, hasMore %v, query %q", got, second.HasMore, second.Query)
+		}
+	})
+}
+
+func TestFavoritesSurviveRescan(t *testing.T) {
+	server := newTestServer(t, "")
+	writeChapter(t, server.root, "Alpha", "Chapter 1", 1)
+	writeChapter(t, server.root, "Beta", "Chapter 1", 1)
+	server.scan()
+	alphaID := server.queryString(`SELECT id FROM manga WHERE title = 'Alpha'`)
+
+	favorites := func(target string) []string {
+		t.Helper()
+		rec := server.do(http.MethodGet, target, "")
+		if rec.Code != http.StatusOK {
+			t.Fatalf("%s: status = %d, body %s", target, rec.Code, rec.Body.String())
+		}
+		titles := []string{}
+		for _, item := range decodeJSON[libraryResponse](t, rec).Items {
+			titles = append(titles, item.Title)
+		}
+		return titles
+	}
+	setFavorite := func(mangaID string, body string) int {
+		t.Helper()
+		return server.do(http.MethodPut, "/api/manga/"+mangaID+"/favorite", body).Code
+	}
+	assertFavorites := func(want ...string) {
+		t.Helper()
+		for _, target := range []string{"/api/favorites", "/api/library?favorite=true"} {
+			if got := favorites(target); !slices.Equal(got, want) {
+				t.Fatalf("%s = %q, want %q", target, got, want)
+			}
+		}
+	}
+
+	assertFavorites()
+	if code := setFavorite(alphaID, `{"favorite":true}`); code != http.StatusOK {
+		t.Fatalf("favorite status = %d", code)
+	}
+	assertFavorites("Alpha")
+
+	writeChapter(t, server.root, "Alpha", "Chapter 2", 1)
+	server.scan()
+	if count := server.queryString(`SELECT COUNT(*) FROM chapter WHERE manga_id = ?`, alphaID); count != "2" {
+		t.Fatalf("Alpha chapters after rescan = %s, want 2", count)
+	}
+	assertFavorites("Alpha")
+
+	if code := setFavorite(alphaID, `{"favorite":false}`); code != http.StatusOK {
+		t.Fatalf("unfavorite status = %d", code)
+	}
+	assertFavorites()
+
+	if code := setFavorite(alphaID, `{}`); code != http.StatusBadRequest {
+		t.Errorf("status without a flag = %d, want 400", code)
+	}
+	if code := setFavorite("missing", `{"favorite":true}`); code != http.StatusNotFound {
+		t.Errorf("status for a missing manga = %d, want 404", code)
+	}
+}
+
+func TestReadingModeSurvivesRescan(t *testing.T) {
+	server := newTestServer(t, "")
+	writeChapter(t, server.root, "Alpha", "Chapter 1", 2)
+	for _, name := range []string{"a.png", "b.png"} {
+		writePNG(t, filepath.Join(server.root, "Strips", "Chapter 1", name), 8, 40)
+	}
+	server.scan()
+	alphaID := server.queryString(`SELECT id FROM manga WHERE title = 'Alpha'`)
+	stripsID := server.queryString(`SELECT id FROM manga WHERE title = 'Strips'`)
+
+	readingMode := func(mangaID string) string {
+		t.Helper()
+		rec := server.do(http.MethodGet, "/api/manga/"+mangaID, "")
+		if rec.Code != http.StatusOK {
+			t.Fatalf("detail status = %d, body %s", rec.Code, rec.Body.String())
+		}
+		return decodeJSON[mangaDetailResponse](t, rec).ReadingMode
+	}
+	setReadingMode := func(mangaID string, body string) *httptest.ResponseRecorder {
+		t.Helper()
+		return server.do(http.MethodPut, "/api/manga/"+mangaID+"/reading-mode", body)
+	}
+	assertModes := func(alpha, strips string) {
+		t.Helper()
+		if got := readingMode(alphaID); got != alpha {
+			t.Errorf("Alpha reading mode = %q, want %q", got, alpha)
+		}
+		if got := readingMode(stripsID); got != strips {
+			t.Errorf("Strips reading mode = %q, want %q", got, strips)
+		}
+	}
+
+	assertModes(media.ReadingModePaged, media.ReadingModeContinuous)
+
+	for _, body := range []string{`{"readingMode":"sideways"}`, `{}`, `{"readingMode":3}`, `not json`} {
+		if rec := setReadingMode(alphaID, body); rec.Code != http.StatusBadRequest {
+			t.Errorf("status for %s = %d, want 400", body, rec.Code)
+		}
+	}
+	if rec := setReadingMode("missing", `{"readingMode":"paged"}`); rec.Code != http.StatusNotFound {
+		t.Errorf("status for a missing manga = %d, want 404", rec.Code)
+	}
+	assertModes(media.ReadingModePaged, media.ReadingModeContinuous)
+
+	if rec := setReadingMode(alphaID, `{"readingMode":" double "}`); rec.Code != http.StatusOK {
+		t.Fatalf("set status = %d, body %s", rec.Code, rec.Body.String())
+	}
+	if rec := setReadingMode(stripsID, `{"readingMode":"paged"}`); rec.Code != http.StatusOK {
+		t.Fatalf("set status = %d, body %s", rec.Code, rec.Body.String())
+	}
+	writeChapter(t, server.root, "Alpha", "Chapter 2", 1)
+	writePNG(t, filepath.Join(server.root, "Strips", "Chapter 2", "a.png"), 8, 40)
+	server.scan()
+	assertModes(media.ReadingModeDouble, media.ReadingModePaged)
+
+	// Clearing the mode hands it back to the scanner's inference.
+	rec := setReadingMode(stripsID, `{"readingMode":""}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("clear status = %d, body %s", rec.Code, rec.Body.String())
+	}
+	cleared := decodeJSON[map[string]any](t, rec)
+	if cleared["readingMode"] != media.ReadingModeContinuous || cleared["readingModeLocked"] != false {
+		t.Fatalf("cleared response = %v, want an unlocked continuous mode", cleared)
+	}
+	server.scan()
+	assertModes(media.ReadingModeDouble, media.ReadingModeContinuous)
+}
+
+func TestChapterWindowClamps(t *testing.T) {
+	const pages = 50
+	server := newTestServer(t, "")
+	// Each page is one pixel wider than the last, to tell them apart.
+	for index := range pages {
+		writePNG(t, filepath.Join(server.root, "Alpha", "Chapter 1", fmt.Sprintf("%02d.png", index)), 8+index, 12)
+	}
+	server.scan()
+	chapterID := server.queryString(`SELECT id FROM chapter`)
+
+	tests := []struct {
+		name       string
+		query      string
+		wantRadius int
+		wantStart  int
+		wantEnd    int
+	}{
+		{name: "middle", query: "center=10&radius=2", wantRadius: 2, wantStart: 8, wantEnd: 12},
+		{name: "start", query: "center=1&radius=4", wantRadius: 4, wantStart: 0, wantEnd: 5},
+		{name: "first page", query: "center=0&radius=2", wantRadius: 2, wantStart: 0, wantEnd: 2},
+		{name: "end", query: "center=47&radius=5", wantRadius: 5, wantStart: 42, wantEnd: 49},
+		{name: "last page", query: "center=49&radius=2", wantRadius: 2, wantStart: 47, wantEnd: 49},
+		{name: "default radius", query: "center=20", wantRadius: defaultPageWindowRadius, wantStart: 20 - defaultPageWindowRadius, wantEnd: 20 + defaultPageWindowRadius},
+		{name: "zero radius", query: "center=7&radius=0", wantRadius: 0, wantStart: 7, wantEnd: 7},
+		{name: "capped radius", query: "center=25&radius=1000", wantRadius: maxPageWindowRadius, wantStart: 25 - maxPageWindowRadius, wantEnd: 25 + maxPageWindowRadius},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := server.do(http.MethodGet, "/api/chapters/"+chapterID+"/window?"+tt.query, "")
+			if rec.Code != http.StatusOK {
+				t.Fatalf("status = %d, body %s", rec.Code, rec.Body.String())
+			}
+			window := decodeJSON[chapterWindowResponse](t, rec)
+			if window.Radius != tt.wantRadius || window.Start != tt.wantStart || window.End != tt.wantEnd || window.PageCount != pages {
+				t.Fatalf("window = radius %d, %d..%d of %d; want radius %d, %d..%d of %d", window.Radius, window.Start, window.End, window.PageCount, tt.wantRadius, tt.wantStart, tt.wantEnd, pages)
+			}
+			if len(window.Pages) != tt.wantEnd-tt.wantStart+1 {
+				t.Fatalf("pages = %d, want %d", len(window.Pages), tt.wantEnd-tt.wantStart+1)
+			}
+			for i, page := range window.Pages {
+				if index := tt.wantStart + i; page.Index != index || page.Width != 8+index || page.Height != 12 {
+					t.Fatalf("page %d = index %d at %dx%d, want index %d at %dx12", i, page.Index, page.Width, page.Height, index, 8+index)
+				}
+				if want := "/api/images/chapters/" + chapterID + "/pages/" + strconv.Itoa(page.Index) + "/thumb"; page.ThumbURL != want {
+					t.Fatalf("page %d thumb URL = %q, want %q", page.Index, page.ThumbURL, want)
+				}
+			}
+		})
+	}
+
+	// The thumb URLs serve the page thumbnails.
+	thumb := "/api/images/chapters/" + chapterID + "/pages/49/thumb"
+	if rec := server.do(http.MethodGet, thumb, ""); rec.Code != http.StatusOK || !strings.HasPrefix(rec.Header().Get("Content-Type"), "image/") {
+		t.Errorf("thumb %s = %d %q", thumb, rec.Code, rec.Header().Get("Content-Type"))
+	}
+
+	for _, query := range []string{"", "center=x", "center=-1", "center=50", "center=3&radius=-1", "center=3&radius=x"} {
+		if rec := server.do(http.MethodGet, "/api/chapters/"+chapterID+"/window?"+query, ""); rec.Code != http.StatusBadRequest {
+			t.Errorf("status for %q = %d, want 400", query, rec.Code)
+		}
+	}
+	if rec := server.do(http.MethodGet, "/api/chapters/missing/window?center=0", ""); rec.Code != http.StatusNotFound {
+		t.Errorf("status for a missing chapter = %d, want 404", rec.Code)
+	}
+}
@@ -821,10 +821,10 @@ func enrichOnlineMangaBookmark(ctx context.Context, db *sql.DB, item onlinesvc.M
 
 func loadOnlineBookmarks(ctx context.Context, db *sql.DB, sourceID string, kind string) ([]onlinesvc.Manga, error) {
 	condition := "b.favorite_at IS NOT NULL"
-	order := "b.favorite_at DESC, om.last_seen_at DESC"
+	order := "b.favorite_at DESC, om.last_seen_at DESC, b.external_id ASC"
 	if kind == "follow" {
 		condition = "b.followed_at IS NOT NULL"
-		order = "b.has_update DESC, b.updated_at DESC, om.last_seen_at DESC"
+		order = "b.has_update DESC, b.updated_at DESC, om.last_seen_at DESC, b.external_id ASC"
 	}
 
 	rows, err := db.QueryContext(ctx, `
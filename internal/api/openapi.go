@@ -0,0 +1,449 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+
+	onlinesvc "mynewmangaui/internal/online"
+	scansvc "mynewmangaui/internal/scan"
+)
+
+// openAPIOperation describes one route in the OpenAPI document. Request and
+// Response hold zero values of the types the handler decodes and encodes;
+// their schemas are derived from the struct fields and json tags, so the
+// document follows the types as they change. A nil Response documents a
+// free-form object. Routes added to NewRouter should be listed here too.
+type openAPIOperation struct {
+	Method   string
+	Path     string
+	Tag      string
+	Summary  string
+	Query    []openAPIParam
+	Request  any
+	Response any
+	// Status is the success status, 200 when zero.
+	Status int
+	// Content is the success media type when the response is not JSON.
+	Content string
+	Admin   bool
+}
+
+type openAPIParam struct {
+	Name        string
+	Type        string
+	Description string
+	Required    bool
+}
+
+var pagingParams = []openAPIParam{
+	{Name: "page", Type: "integer", Description: "1-based page number"},
+	{Name: "limit", Type: "integer", Description: "Page size, capped by server.pagination"},
+}
+
+var libraryFilterParams = []openAPIParam{
+	{Name: "bookshelfId", Type: "string"},
+	{Name: "tagIds", Type: "string", Description: "Comma-separated tag ids; manga must carry all of them"},
+	{Name: "q", Type: "string", Description: "Title search; quote phrases, backslash escapes"},
+	{Name: "state", Type: "string", Description: "Comma-separated reading states: unread, reading, completed"},
+	{Name: "collection", Type: "string"},
+	{Name: "sort", Type: "string", Description: "updated (default, by when chapters were last added or removed), name or collection"},
+}
+
+var libraryParams = append(append(append([]openAPIParam{}, libraryFilterParams...),
+	openAPIParam{Name: "fields", Type: "string", Description: "Comma-separated item fields to return, such as id,title; all when omitted"}),
+	pagingParams...)
+
+var openAPIOperations = []openAPIOperation{
+	{Method: "GET", Path: "/health", Tag: "system", Summary: "Liveness check"},
+	{Method: "GET", Path: "/readyz", Tag: "system", Summary: "Readiness check, 503 in maintenance mode", Response: readinessResponse{}},
+	{Method: "GET", Path: "/metrics", Tag: "system", Summary: "Prometheus metrics", Content: "text/plain"},
+	{Method: "GET", Path: "/api/openapi.json", Tag: "system", Summary: "This document"},
+
+	{Method: "GET", Path: "/api/bookshelves", Tag: "library", Summary: "List bookshelves", Response: bookshelvesResponse{}},
+	{Method: "GET", Path: "/api/library", Tag: "library", Summary: "List manga", Response: libraryResponse{},
+		Query: append([]openAPIParam{{Name: "favorite", Type: "boolean"}}, libraryParams...)},
+	{Method: "GET", Path: "/api/library/index", Tag: "library", Summary: "Manga counts by first letter of the sort name, with offsets into the sort=name listing", Response: libraryIndexResponse{},
+		Query: append([]openAPIParam{{Name: "favorite", Type: "boolean"}}, libraryFilterParams...)},
+	{Method: "GET", Path: "/api/favorites", Tag: "library", Summary: "List favorite manga", Response: libraryResponse{}, Query: libraryParams},
+	{Method: "GET", Path: "/api/collections", Tag: "library", Summary: "List collections", Response: collectionsResponse{}},
+	{Method: "POST", Path: "/api/collections", Tag: "library", Summary: "Create a named collection", Request: userCollectionRequest{}, Response: userCollectionItem{}, Status: http.StatusCreated},
+	{Method: "GET", Path: "/api/collections/{collectionID}", Tag: "library", Summary: "Get a collection's manga in order", Response: userCollectionResponse{}},
+	{Method: "PUT", Path: "/api/collections/{collectionID}", Tag: "library", Summary: "Rename a collection", Request: userCollectionRequest{}, Response: userCollectionResponse{}},
+	{Method: "DELETE", Path: "/api/collections/{collectionID}", Tag: "library", Summary: "Delete a collection, keeping its manga"},
+	{Method: "POST", Path: "/api/collections/{collectionID}/items", Tag: "library", Summary: "Add or move a manga in a collection", Request: collectionItemRequest{}, Response: userCollectionResponse{}},
+	{Method: "PUT", Path: "/api/collections/{collectionID}/items", Tag: "library", Summary: "Reorder a collection", Request: collectionOrderRequest{}, Response: userCollectionResponse{}},
+	{Method: "DELETE", Path: "/api/collections/{collectionID}/items/{mangaID}", Tag: "library", Summary: "Remove a manga from a collection", Response: userCollectionResponse{}},
+	{Method: "GET", Path: "/api/feed/updates", Tag: "library", Summary: "Chapters first indexed since a time, grouped by manga", Response: updatesResponse{},
+		Query: []openAPIParam{{Name: "since", Type: "string", Description: "RFC 3339 time, the last 24 hours by default"}}},
+
+	{Method: "GET", Path: "/api/tags", Tag: "tags", Summary: "List tags", Response: tagsResponse{}},
+	{Method: "POST", Path: "/api/tags", Tag: "tags", Summary: "Create a tag", Request: tagUpsertRequest{}, Response: tagsResponse{}, Status: http.StatusCreated},
+	{Method: "PUT", Path: "/api/tags/reorder", Tag: "tags", Summary: "Reorder tags", Request: tagReorderRequest{}, Response: tagsResponse{}},
+	{Method: "PUT", Path: "/api/tags/{tagID}", Tag: "tags", Summary: "Update a tag", Request: tagUpsertRequest{}, Response: tagsResponse{}},
+	{Method: "DELETE", Path: "/api/tags/{tagID}", Tag: "tags", Summary: "Delete a tag", Response: tagsResponse{}},
+	{Method: "PUT", Path: "/api/manga/{mangaID}/tags", Tag: "tags", Summary: "Replace a manga's tags", Request: mangaTagsUpdateRequest{}, Response: tagsResponse{}},
+
+	{Method: "GET", Path: "/api/manga/{mangaID}", Tag: "manga", Summary: "Get a manga", Response: mangaDetailResponse{}},
+	{Method: "GET", Path: "/api/manga/{mangaID}/siblings", Tag: "manga", Summary: "Previous and next manga in the library listing", Response: mangaSiblingsResponse{},
+		Query: append([]openAPIParam{{Name: "favorite", Type: "boolean"}}, libraryFilterParams...)},
+	{Method: "DELETE", Path: "/api/manga/{mangaID}", Tag: "manga", Summary: "Delete a manga", Response: mangaDeleteResponse{}, Admin: true,
+		Query: []openAPIParam{{Name: "deleteFiles", Type: "boolean", Description: "Also move its files to the trash"}}},
+	{Method: "PUT", Path: "/api/manga/{mangaID}/favorite", Tag: "manga", Summary: "Set the favorite flag", Request: favoriteUpdateRequest{}},
+	{Method: "PUT", Path: "/api/manga/{mangaID}/settings", Tag: "manga", Summary: "Set sort name and collection", Request: mangaSettingsRequest{}},
+	{Method: "PUT", Path: "/api/manga/{mangaID}/reading-mode", Tag: "manga", Summary: "Set the default reading mode", Request: readingModeRequest{}},
+	{Method: "GET", Path: "/api/manga/{mangaID}/chapters", Tag: "manga", Summary: "List chapters", Response: chaptersResponse{},
+		Query: append([]openAPIParam{{Name: "q", Type: "string", Description: "Chapter number or title substring"}}, pagingParams...)},
+	{Method: "GET", Path: "/api/manga/{mangaID}/chapters/changes", Tag: "manga", Summary: "Chapters changed or removed since a time", Response: chapterChangesResponse{},
+		Query: []openAPIParam{{Name: "since", Type: "string", Description: "RFC 3339 time", Required: true}}},
+	{Method: "GET", Path: "/api/manga/{mangaID}/volumes", Tag: "manga", Summary: "Chapters grouped by volume", Response: volumesResponse{}},
+	{Method: "GET", Path: "/api/chapters/{chapterID}/pages", Tag: "manga", Summary: "List a chapter's pages with their sizes", Response: chapterPagesResponse{},
+		Query: pagingParams},
+	{Method: "GET", Path: "/api/chapters/{chapterID}/checksums", Tag: "manga", Summary: "Page checksum manifest", Response: chapterChecksumsResponse{}},
+	{Method: "PUT", Path: "/api/chapters/{chapterID}/volume", Tag: "manga", Summary: "Override a chapter's volume; null restores the detected one on the next scan", Request: chapterVolumeUpdateRequest{}},
+	{Method: "PUT", Path: "/api/chapters/{chapterID}/page-order", Tag: "manga", Summary: "Pin a chapter's page order", Request: pageOrderRequest{}, Response: chapterPagesResponse{}},
+	{Method: "POST", Path: "/api/resolve", Tag: "manga", Summary: "Resolve a filesystem path to its manga, chapter or page", Request: resolveRequest{}, Response: resolveResponse{}},
+	{Method: "GET", Path: "/api/settings", Tag: "settings", Summary: "Get the user settings, with defaults for unset ones", Response: settingsResponse{}},
+	{Method: "PUT", Path: "/api/settings", Tag: "settings", Summary: "Change some settings; null resets one to its default", Request: map[string]json.RawMessage{}, Response: settingsResponse{}},
+
+	{Method: "PUT", Path: "/api/chapters/{chapterID}/progress", Tag: "progress", Summary: "Record reading progress", Request: progressUpdateRequest{}},
+	{Method: "GET", Path: "/api/manga/{mangaID}/progress", Tag: "progress", Summary: "Reading progress of a manga", Response: mangaProgressResponse{}},
+	{Method: "POST", Path: "/api/manga/{mangaID}/catch-up", Tag: "progress", Summary: "Mark earlier chapters as read", Request: catchUpRequest{}},
+	{Method: "GET", Path: "/api/manga/{mangaID}/reading-stats", Tag: "progress", Summary: "Chapters and pages read of a manga", Response: mangaReadingStatsResponse{}},
+	{Method: "GET", Path: "/api/up-next", Tag: "progress", Summary: "The next chapter to read of each manga being read or marked favorite", Response: upNextResponse{},
+		Query: []openAPIParam{
+			{Name: "sort", Type: "string", Description: "recent (last read first, the default) or updated (content updated last first)"},
+			{Name: "limit", Type: "integer", Description: "Number of manga, 20 by default and at most 100"},
+		}},
+	{Method: "POST", Path: "/api/chapters/{chapterID}/bookmarks", Tag: "progress", Summary: "Bookmark a page, with an optional note", Request: bookmarkRequest{}, Response: bookmarkItem{}, Status: http.StatusCreated},
+	{Method: "GET", Path: "/api/manga/{mangaID}/bookmarks", Tag: "progress", Summary: "List a manga's bookmarks in reading order", Response: mangaBookmarksResponse{}},
+	{Method: "DELETE", Path: "/api/bookmarks/{bookmarkID}", Tag: "progress", Summary: "Delete a bookmark"},
+
+	{Method: "GET", Path: "/api/images/covers/{mangaID}/thumb", Tag: "images", Summary: "Cover thumbnail", Content: "image/*"},
+	{Method: "GET", Path: "/api/chapters/{chapterID}/window", Tag: "manga", Summary: "Pages around a position, for prefetching", Response: chapterWindowResponse{},
+		Query: []openAPIParam{
+			{Name: "center", Type: "integer", Required: true, Description: "0-based position of the current page"},
+			{Name: "radius", Type: "integer", Description: "Pages on each side, 3 by default and at most 20"},
+		}},
+	{Method: "GET", Path: "/api/chapters/{chapterID}/thumb", Tag: "images", Summary: "Thumbnail of a chapter's representative page", Content: "image/*"},
+	{Method: "POST", Path: "/api/chapters/{chapterID}/preload", Tag: "images", Summary: "Warm the thumbnail and page caches of a chapter in the background", Response: chapterPreloadResponse{}, Status: http.StatusAccepted},
+	{Method: "GET", Path: "/api/images/chapters/{chapterID}/pages/{pageIndex}", Tag: "images", Summary: "Page image", Content: "image/*"},
+	{Method: "GET", Path: "/api/images/chapters/{chapterID}/pages/{pageIndex}/thumb", Tag: "images", Summary: "Thumbnail of a page", Content: "image/*"},
+	{Method: "GET", Path: "/api/manga/{mangaID}/pages/{globalIndex}", Tag: "images", Summary: "Redirect to a page by its 0-based position across all chapters", Status: http.StatusFound},
+	{Method: "GET", Path: "/api/chapters/{chapterID}/pages/{pageIndex}/data", Tag: "images", Summary: "Page image as base64, whole or in chunks", Response: pageDataResponse{},
+		Query: []openAPIParam{
+			{Name: "chunk", Type: "integer", Description: "0-based chunk to return instead of the whole page"},
+			{Name: "chunkSize", Type: "integer", Description: "Page bytes per chunk, rounded down to a multiple of 3"},
+		}},
+
+	{Method: "GET", Path: "/api/tasks/scan/status", Tag: "scan", Summary: "Scan status"},
+	{Method: "POST", Path: "/api/tasks/scan", Tag: "scan", Summary: "Queue a library scan, coalescing with one already waiting", Status: http.StatusAccepted},
+	{Method: "POST", Path: "/api/scan", Tag: "scan", Summary: "Queue a scan of one bookshelf root", Request: rootScanRequest{}, Status: http.StatusAccepted},
+	{Method: "GET", Path: "/api/scan/{jobID}/log", Tag: "scan", Summary: "Per-manga log of a recent scan run", Response: scansvc.RunLog{}},
+	{Method: "POST", Path: "/api/tasks/scan/bookshelf/{bookshelfID}", Tag: "scan", Summary: "Scan a bookshelf"},
+	{Method: "POST", Path: "/api/tasks/scan/manga/{mangaID}", Tag: "scan", Summary: "Scan a manga, or queue the scan while another runs"},
+	{Method: "POST", Path: "/api/tasks/scan/tag/{tagID}", Tag: "scan", Summary: "Scan the manga carrying a tag"},
+
+	{Method: "GET", Path: "/api/admin/export", Tag: "admin", Summary: "Export reading progress and settings", Response: libraryBackup{}, Admin: true},
+	{Method: "POST", Path: "/api/admin/import", Tag: "admin", Summary: "Import reading progress and settings", Request: libraryBackup{}, Response: backupImportResponse{}, Admin: true},
+	{Method: "GET", Path: "/api/admin/progress/export", Tag: "admin", Summary: "Export reading progress keyed by chapter path", Response: progressBackup{}, Admin: true},
+	{Method: "POST", Path: "/api/admin/progress/import", Tag: "admin", Summary: "Import reading progress, matching chapters by path; newer progress wins", Request: progressBackup{}, Response: progressImportResponse{}, Admin: true},
+	{Method: "GET", Path: "/api/admin/parse-preview", Tag: "admin", Summary: "Preview chapter name parsing", Response: scansvc.LabelPreview{}, Admin: true,
+		Query: []openAPIParam{{Name: "name", Type: "string", Required: true}, {Name: "manga", Type: "string", Description: "Manga title stripped from the name"}}},
+	{Method: "GET", Path: "/api/admin/duplicates", Tag: "admin", Summary: "Groups of manga that look like the same series", Response: duplicatesResponse{}, Admin: true,
+		Query: []openAPIParam{{Name: "by", Type: "string", Description: "title (default) or cover, the first page checksum"}}},
+	{Method: "GET", Path: "/api/admin/consistency", Tag: "admin", Summary: "Chapters whose page indexes have gaps or duplicates", Response: consistencyResponse{}, Admin: true},
+	{Method: "GET", Path: "/api/admin/stats/db", Tag: "admin", Summary: "Database file sizes and free pages", Response: databaseStatsResponse{}, Admin: true},
+	{Method: "POST", Path: "/api/admin/vacuum", Tag: "admin", Summary: "Vacuum the database, blocking other queries while it runs", Response: vacuumResponse{}, Admin: true,
+		Query: []openAPIParam{{Name: "mode", Type: "string", Description: "full (default) or incremental; incremental needs a full vacuum first"}}},
+	{Method: "GET", Path: "/api/admin/maintenance", Tag: "admin", Summary: "Whether maintenance mode is on", Response: maintenanceResponse{}, Admin: true},
+	{Method: "POST", Path: "/api/admin/maintenance", Tag: "admin", Summary: "Turn maintenance mode on or off, pausing scans and the API", Request: maintenanceRequest{}, Response: maintenanceResponse{}, Admin: true},
+
+	{Method: "GET", Path: "/api/online/sources", Tag: "online", Summary: "List online sources", Response: onlineSourcesResponse{}},
+	{Method: "GET", Path: "/api/online/settings", Tag: "online", Summary: "List online source settings", Response: onlineSettingsResponse{}},
+	{Method: "GET", Path: "/api/online/{sourceID}/default", Tag: "online", Summary: "Default feed of a source", Response: onlineDefaultResponse{}, Query: pagingParams},
+	{Method: "POST", Path: "/api/online/{sourceID}/default/refresh", Tag: "online", Summary: "Refresh the default feed", Response: onlineDefaultResponse{}},
+	{Method: "GET", Path: "/api/online/{sourceID}/search", Tag: "online", Summary: "Search a source", Response: onlineSearchResponse{},
+		Query: append([]openAPIParam{{Name: "q", Type: "string", Required: true}}, pagingParams...)},
+	{Method: "PUT", Path: "/api/online/{sourceID}/settings", Tag: "online", Summary: "Update source settings", Request: onlineSettingsUpdateRequest{}, Response: onlineSourceSettings{}},
+	{Method: "GET", Path: "/api/online/{sourceID}/bookmarks", Tag: "online", Summary: "List bookmarked manga", Response: onlineBookmarksResponse{}},
+	{Method: "GET", Path: "/api/online/{sourceID}/manga/{mangaID}", Tag: "online", Summary: "Get an online manga", Response: onlinesvc.Manga{}},
+	{Method: "PUT", Path: "/api/online/{sourceID}/manga/{mangaID}/bookmark", Tag: "online", Summary: "Favorite or follow an online manga", Request: onlineBookmarkUpdateRequest{}},
+	{Method: "POST", Path: "/api/online/{sourceID}/manga/{mangaID}/block", Tag: "online", Summary: "Hide an online manga", Response: onlineBlockResponse{}},
+	{Method: "GET", Path: "/api/online/{sourceID}/manga/{mangaID}/chapters", Tag: "online", Summary: "List online chapters", Response: onlineChaptersResponse{}},
+	{Method: "GET", Path: "/api/online/{sourceID}/chapters/{chapterID}/pages", Tag: "online", Summary: "List online pages", Response: onlinePagesResponse{}},
+	{Method: "GET", Path: "/api/online/{sourceID}/image", Tag: "online", Summary: "Proxy an online image", Content: "image/*",
+		Query: []openAPIParam{{Name: "target", Type: "string", Required: true, Description: "Encoded image URL as returned in page listings"}}},
+
+	{Method: "GET", Path: "/api/online/downloads", Tag: "downloads", Summary: "List download jobs", Query: pagingParams},
+	{Method: "GET", Path: "/api/online/downloads/{jobID}", Tag: "downloads", Summary: "Get a download job", Response: onlinesvc.DownloadJobDetail{}},
+	{Method: "POST", Path: "/api/online/{sourceID}/manga/{mangaID}/download", Tag: "downloads", Summary: "Queue a download", Request: createDownloadJobRequest{}, Response: onlinesvc.DownloadJobDetail{}, Status: http.StatusCreated},
+	{Method: "POST", Path: "/api/online/downloads/{jobID}/pause", Tag: "downloads", Summary: "Pause a download", Response: onlinesvc.DownloadJobDetail{}},
+	{Method: "POST", Path: "/api/online/downloads/{jobID}/resume", Tag: "downloads", Summary: "Resume a download", Response: onlinesvc.DownloadJobDetail{}},
+	{Method: "POST", Path: "/api/online/downloads/{jobID}/cancel", Tag: "downloads", Summary: "Cancel a download", Response: onlinesvc.DownloadJobDetail{}},
+	{Method: "POST", Path: "/api/online/downloads/{jobID}/retry", Tag: "downloads", Summary: "Retry failed pages", Response: onlinesvc.DownloadJobDetail{}},
+	{Method: "POST", Path: "/api/online/downloads/{jobID}/redownload", Tag: "downloads", Summary: "Download a job again from scratch", Response: onlinesvc.DownloadJobDetail{}},
+	{Method: "DELETE", Path: "/api/online/downloads/{jobID}", Tag: "downloads", Summary: "Delete a job record"},
+	{Method: "DELETE", Path: "/api/online/downloads/{jobID}/files", Tag: "downloads", Summary: "Delete a job and its files"},
+}
+
+var openAPIPathParam = regexp.MustCompile(`\{([^}]+)\}`)
+
+// openAPIDocument is built on first request and reused; it only depends on
+// the operation table and the Go types.
+var openAPIDocument = sync.OnceValue(func() map[string]any {
+	return buildOpenAPIDocument(openAPIOperations)
+})
+
+func getOpenAPI(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, openAPIDocument())
+}
+
+func buildOpenAPIDocument(operations []openAPIOperation) map[string]any {
+	schemas := newOpenAPISchemas()
+	errorSchema := map[string]any{
+		"type":       "object",
+		"required":   []string{"error"},
+		"properties": map[string]any{"error": map[string]any{"type": "string"}},
+	}
+	schemas.components["Error"] = errorSchema
+
+	paths := make(map[string]any)
+	for _, op := range operations {
+		item, _ := paths[op.Path].(map[string]any)
+		if item == nil {
+			item = make(map[string]any)
+			paths[op.Path] = item
+		}
+		item[strings.ToLower(op.Method)] = schemas.operation(op)
+	}
+
+	return map[string]any{
+		"openapi": "3.0.3",
+		"info": map[string]any{
+			"title":   "myNewMangaUI API",
+			"version": "1",
+		},
+		"components": map[string]any{
+			"schemas": schemas.components,
+			"securitySchemes": map[string]any{
+				"accessToken": map[string]any{"type": "http", "scheme": "bearer"},
+				"adminToken":  map[string]any{"type": "apiKey", "in": "header", "name": "X-Admin-Token"},
+			},
+		},
+		"security": []any{map[string]any{"accessToken": []string{}}},
+		"paths":    paths,
+	}
+}
+
+type openAPISchemas struct {
+	components map[string]any
+	apiPkg     string
+}
+
+func newOpenAPISchemas() *openAPISchemas {
+	return &openAPISchemas{
+		components: make(map[string]any),
+		apiPkg:     reflect.TypeOf(openAPIOperation{}).PkgPath(),
+	}
+}
+
+func (s *openAPISchemas) operation(op openAPIOperation) map[string]any {
+	parameters := make([]any, 0)
+	for _, match := range openAPIPathParam.FindAllStringSubmatch(op.Path, -1) {
+		parameters = append(parameters, map[string]any{
+			"name":     match[1],
+			"in":       "path",
+			"required": true,
+			"schema":   map[string]any{"type": "string"},
+		})
+	}
+	for _, param := range op.Query {
+		entry := map[string]any{
+			"name":   param.Name,
+			"in":     "query",
+			"schema": map[string]any{"type": param.Type},
+		}
+		if param.Required {
+			entry["required"] = true
+		}
+		if param.Description != "" {
+			entry["description"] = param.Description
+		}
+		parameters = append(parameters, entry)
+	}
+
+	success := map[string]any{"description": "OK"}
+	switch {
+	case op.Content != "":
+		success["content"] = map[string]any{op.Content: map[string]any{"schema": map[string]any{"type": "string", "format": "binary"}}}
+	case op.Response != nil:
+		success["content"] = jsonContent(s.schemaFor(reflect.TypeOf(op.Response)))
+	default:
+		success["content"] = jsonContent(map[string]any{"type": "object"})
+	}
+	status := op.Status
+	if status == 0 {
+		status = http.StatusOK
+	}
+
+	operation := map[string]any{
+		"tags":        []string{op.Tag},
+		"summary":     op.Summary,
+		"operationId": openAPIOperationID(op),
+		"parameters":  parameters,
+		"responses": map[string]any{
+			strconv.Itoa(status): success,
+			"default": map[string]any{
+				"description": "Error",
+				"content":     jsonContent(map[string]any{"$ref": "#/components/schemas/Error"}),
+			},
+		},
+	}
+	if op.Request != nil {
+		operation["requestBody"] = map[string]any{
+			"content": jsonContent(s.schemaFor(reflect.TypeOf(op.Request))),
+		}
+	}
+	if op.Admin {
+		operation["security"] = []any{map[string]any{"adminToken": []string{}}}
+	}
+	return operation
+}
+
+var jsonMarshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+
+// schemaFor describes t the way encoding/json renders it. Structs become
+// shared components named after the type; types with their own MarshalJSON
+// are documented as free-form objects.
+func (s *openAPISchemas) schemaFor(t reflect.Type) map[string]any {
+	if t.Implements(jsonMarshalerType) || reflect.PointerTo(t).Implements(jsonMarshalerType) {
+		return map[string]any{"type": "object"}
+	}
+
+	switch t.Kind() {
+	case reflect.Pointer:
+		inner := s.schemaFor(t.Elem())
+		if _, ok := inner["$ref"]; ok {
+			return map[string]any{"allOf": []any{inner}, "nullable": true}
+		}
+		inner["nullable"] = true
+		return inner
+	case reflect.String:
+		return map[string]any{"type": "string"}
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return map[string]any{"type": "integer"}
+	case reflect.Int64, reflect.Uint64:
+		return map[string]any{"type": "integer", "format": "int64"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]any{"type": "number"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return map[string]any{"type": "string", "format": "byte"}
+		}
+		return map[string]any{"type": "array", "items": s.schemaFor(t.Elem())}
+	case reflect.Map:
+		return map[string]any{"type": "object", "additionalProperties": s.schemaFor(t.Elem())}
+	case reflect.Struct:
+		name := s.componentName(t)
+		if _, ok := s.components[name]; !ok {
+			// Reserve the name first so self-referencing types terminate.
+			s.components[name] = map[string]any{}
+			s.components[name] = s.structSchema(t)
+		}
+		return map[string]any{"$ref": "#/components/schemas/" + name}
+	default:
+		return map[string]any{}
+	}
+}
+
+func (s *openAPISchemas) structSchema(t reflect.Type) map[string]any {
+	properties := make(map[string]any)
+	required := make([]string, 0)
+	s.collectFields(t, properties, &required)
+
+	schema := map[string]any{"type": "object", "properties": properties}
+	if len(required) > 0 {
+		schema["required"] = required
+	}
+	return schema
+}
+
+// collectFields adds the JSON fields of t, flattening untagged embedded
+// structs as encoding/json does. Fields without omitempty are required.
+func (s *openAPISchemas) collectFields(t reflect.Type, properties map[string]any, required *[]string) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := field.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, options, _ := strings.Cut(tag, ",")
+		if field.Anonymous && name == "" {
+			embedded := field.Type
+			if embedded.Kind() == reflect.Pointer {
+				embedded = embedded.Elem()
+			}
+			if embedded.Kind() == reflect.Struct {
+				s.collectFields(embedded, properties, required)
+				continue
+			}
+		}
+		if !field.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		properties[name] = s.schemaFor(field.Type)
+		if !strings.Contains(options, "omitempty") {
+			*required = append(*required, name)
+		}
+	}
+}
+
+// componentName exports API type names as they are and prefixes types from
+// other packages with their package, e.g. online.Manga becomes OnlineManga.
+func (s *openAPISchemas) componentName(t reflect.Type) string {
+	name := upperFirst(t.Name())
+	if t.PkgPath() == s.apiPkg {
+		return name
+	}
+	pkg := t.PkgPath()
+	if slash := strings.LastIndex(pkg, "/"); slash >= 0 {
+		pkg = pkg[slash+1:]
+	}
+	return upperFirst(pkg) + name
+}
+
+func openAPIOperationID(op openAPIOperation) string {
+	var id strings.Builder
+	id.WriteString(strings.ToLower(op.Method))
+	for _, segment := range strings.Split(op.Path, "/") {
+		segment = strings.Trim(segment, "{}")
+		if segment == "" || segment == "api" {
+			continue
+		}
+		for _, word := range strings.FieldsFunc(segment, func(r rune) bool { return r == '-' || r == '.' }) {
+			id.WriteString(upperFirst(word))
+		}
+	}
+	return id.String()
+}
+
+func jsonContent(schema map[string]any) map[string]any {
+	return map[string]any{"application/json": map[string]any{"schema": schema}}
+}
+
+func upperFirst(value string) string {
+	if value == "" {
+		return value
+	}
+	return strings.ToUpper(value[:1]) + value[1:]
+}
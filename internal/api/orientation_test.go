@@ -0,0 +1,142 @@
+package api
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	_ "image/png"
+)
+
+// writeRotatedJPEG writes a 40x20 JPEG whose left half is red and right
+// half blue, tagged with the given EXIF orientation. Under orientation 6
+// it displays as a 20x40 page with red on top.
+func writeRotatedJPEG(t *testing.T, path string, orientation uint16) {
+	t.Helper()
+	img := image.NewRGBA(image.Rect(0, 0, 40, 20))
+	for y := 0; y < 20; y++ {
+		for x := 0; x < 40; x++ {
+			c := color.RGBA{R: 255, A: 255}
+			if x >= 20 {
+				c = color.RGBA{B: 255, A: 255}
+			}
+			img.Set(x, y, c)
+		}
+	}
+	var encoded bytes.Buffer
+	if err := jpeg.Encode(&encoded, img, &jpeg.Options{Quality: 95}); err != nil {
+		t.Fatal(err)
+	}
+
+	// A little-endian TIFF header with one IFD holding the orientation.
+	tiff := []byte("II*\x00\x08\x00\x00\x00")
+	tiff = binary.LittleEndian.AppendUint16(tiff, 1)
+	tiff = binary.LittleEndian.AppendUint16(tiff, 0x0112)
+	tiff = binary.LittleEndian.AppendUint16(tiff, 3)
+	tiff = binary.LittleEndian.AppendUint32(tiff, 1)
+	tiff = binary.LittleEndian.AppendUint16(tiff, orientation)
+	tiff = append(tiff, 0, 0, 0, 0, 0, 0)
+	segment := append([]byte("Exif\x00\x00"), tiff...)
+
+	var out bytes.Buffer
+	out.Write(encoded.Bytes()[:2])
+	out.Write([]byte{0xFF, 0xE1})
+	_ = binary.Write(&out, binary.BigEndian, uint16(len(segment)+2))
+	out.Write(segment)
+	out.Write(encoded.Bytes()[2:])
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, out.Bytes(), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// assertUpright decodes body and checks it is taller than wide with red
+// over blue, as writeRotatedJPEG's page displays.
+func assertUpright(t *testing.T, body []byte) {
+	t.Helper()
+	img, _, err := image.Decode(bytes.NewReader(body))
+	if err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	bounds := img.Bounds()
+	if bounds.Dx() >= bounds.Dy() {
+		t.Fatalf("size = %dx%d, want a portrait image", bounds.Dx(), bounds.Dy())
+	}
+	isRed := func(c color.Color) bool {
+		r, _, b, _ := c.RGBA()
+		return r > 0xC000 && b < 0x4000
+	}
+	isBlue := func(c color.Color) bool {
+		r, _, b, _ := c.RGBA()
+		return b > 0xC000 && r < 0x4000
+	}
+	top := img.At(bounds.Min.X+bounds.Dx()/2, bounds.Min.Y+bounds.Dy()/8)
+	bottom := img.At(bounds.Min.X+bounds.Dx()/2, bounds.Max.Y-1-bounds.Dy()/8)
+	if !isRed(top) || !isBlue(bottom) {
+		t.Fatalf("top = %v, bottom = %v; want red over blue", top, bottom)
+	}
+}
+
+func TestRotatedPagesServedUpright(t *testing.T) {
+	server := newTestServer(t, `{"storage":{"normalizeOrientation":true}}`)
+	writeRotatedJPEG(t, filepath.Join(server.root, "Alpha", "Chapter 1", "a.jpg"), 6)
+	server.scan()
+	chapterID := server.queryString(`SELECT id FROM chapter`)
+
+	if got := server.queryString(`SELECT orientation || ' ' || width || 'x' || height FROM page`); got != "6 20x40" {
+		t.Fatalf("stored page = %q, want orientation 6 at its upright 20x40 size", got)
+	}
+
+	rec := server.do(http.MethodGet, "/api/images/chapters/"+chapterID+"/pages/0/thumb", "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("thumb status = %d, body %s", rec.Code, rec.Body.String())
+	}
+	assertUpright(t, rec.Body.Bytes())
+	// The second request is served from the cache.
+	rec = server.do(http.MethodGet, "/api/images/chapters/"+chapterID+"/pages/0/thumb", "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("cached thumb status = %d", rec.Code)
+	}
+	assertUpright(t, rec.Body.Bytes())
+
+	rec = server.do(http.MethodGet, "/api/images/chapters/"+chapterID+"/pages/0", "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("page status = %d, body %s", rec.Code, rec.Body.String())
+	}
+	if got := rec.Header().Get("Content-Type"); got != "image/jpeg" {
+		t.Errorf("page content type = %q, want image/jpeg", got)
+	}
+	assertUpright(t, rec.Body.Bytes())
+
+	t.Run("raw page kept without normalization", func(t *testing.T) {
+		server := newTestServer(t, "")
+		path := filepath.Join(server.root, "Alpha", "Chapter 1", "a.jpg")
+		writeRotatedJPEG(t, path, 6)
+		server.scan()
+		chapterID := server.queryString(`SELECT id FROM chapter`)
+		want, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		rec := server.do(http.MethodGet, "/api/images/chapters/"+chapterID+"/pages/0", "")
+		if rec.Code != http.StatusOK || !bytes.Equal(rec.Body.Bytes(), want) {
+			t.Fatalf("page status = %d with %d bytes, want the %d stored bytes", rec.Code, rec.Body.Len(), len(want))
+		}
+		// Thumbnails are upright either way.
+		rec = server.do(http.MethodGet, "/api/images/chapters/"+chapterID+"/pages/0/thumb", "")
+		if rec.Code != http.StatusOK {
+			t.Fatalf("thumb status = %d", rec.Code)
+		}
+		assertUpright(t, rec.Body.Bytes())
+	})
+}
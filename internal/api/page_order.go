@@ -0,0 +1,115 @@
+package api
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+)
+
+type pageOrderRequest struct {
+	PageIDs []string `json:"pageIds"`
+}
+
+// updatePageOrder pins the page order of a chapter. The new order replaces
+// the page indexes directly, so listing and serving follow it, and the
+// chapter is flagged so rescans keep it.
+func (h *mangaHandler) updatePageOrder(w http.ResponseWriter, r *http.Request) {
+	if h.db == nil {
+		writeError(w, http.StatusInternalServerError, "database not initialized")
+		return
+	}
+
+	chapterID := strings.TrimSpace(chi.URLParam(r, "chapterID"))
+	if chapterID == "" {
+		writeError(w, http.StatusBadRequest, "chapter id is required")
+		return
+	}
+
+	var request pageOrderRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid page order payload")
+		return
+	}
+
+	tx, err := h.db.BeginTx(r.Context(), nil)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to start page order update")
+		return
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.QueryContext(r.Context(), `SELECT id FROM page WHERE chapter_id = ?`, chapterID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to load chapter pages")
+		return
+	}
+	existing := make(map[string]struct{})
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			writeError(w, http.StatusInternalServerError, "failed to read page row")
+			return
+		}
+		existing[id] = struct{}{}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to iterate page rows")
+		return
+	}
+
+	if len(existing) == 0 {
+		writeError(w, http.StatusNotFound, "chapter not found")
+		return
+	}
+	if len(request.PageIDs) != len(existing) {
+		writeError(w, http.StatusBadRequest, "page order must list every page of the chapter exactly once")
+		return
+	}
+	seen := make(map[string]struct{}, len(request.PageIDs))
+	for _, id := range request.PageIDs {
+		if _, ok := existing[id]; !ok {
+			writeError(w, http.StatusBadRequest, "page order contains a page outside the chapter")
+			return
+		}
+		if _, ok := seen[id]; ok {
+			writeError(w, http.StatusBadRequest, "page order must list every page of the chapter exactly once")
+			return
+		}
+		seen[id] = struct{}{}
+	}
+
+	if err := setPageOrder(r.Context(), tx, chapterID, request.PageIDs); err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to update page order")
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to save page order")
+		return
+	}
+
+	h.getChapterPages(w, r)
+}
+
+// setPageOrder rewrites page indexes to follow pageIDs, which must list every
+// page of the chapter once, and pins the order against rescans.
+func setPageOrder(ctx context.Context, tx *sql.Tx, chapterID string, pageIDs []string) error {
+	// Move indexes out of the way first so the (chapter_id, page_index)
+	// uniqueness constraint holds while they are rewritten.
+	if _, err := tx.ExecContext(ctx, `UPDATE page SET page_index = -page_index - 1 WHERE chapter_id = ?`, chapterID); err != nil {
+		return err
+	}
+	for index, id := range pageIDs {
+		if _, err := tx.ExecContext(ctx, `UPDATE page SET page_index = ? WHERE id = ?`, index, id); err != nil {
+			return err
+		}
+	}
+	_, err := tx.ExecContext(ctx, `UPDATE chapter SET page_order_locked = 1 WHERE id = ?`, chapterID)
+	return err
+}
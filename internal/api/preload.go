@@ -0,0 +1,267 @@
+package api
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"mynewmangaui/internal/media"
+)
+
+// preloadDecodeSlots is how many pages chapter preloads decode at once,
+// across all chapters, so warming caches in the background never takes
+// more than a couple of the decode limiter's slots from the reader.
+const preloadDecodeSlots = 2
+
+// preloadDecodeRetry is how long a preload waits before trying again for a
+// decode slot when requests hold all of them.
+const preloadDecodeRetry = 50 * time.Millisecond
+
+// maxPendingPreloads caps how many chapters may be preloading or waiting to.
+const maxPendingPreloads = 8
+
+type chapterPreloadResponse struct {
+	Status    string `json:"status"`
+	ChapterID string `json:"chapterId"`
+	PageCount int    `json:"pageCount"`
+	// Coalesced is set when a preload of the chapter was already running
+	// and absorbed the request.
+	Coalesced bool `json:"coalesced"`
+}
+
+// chapterPreloader runs chapter preloads in the background, at most one
+// per chapter. Their decodes take slots of the decode limiter that guards
+// requests, at most preloadDecodeSlots of them, and stop with ctx, which
+// lasts as long as the server.
+type chapterPreloader struct {
+	ctx     context.Context
+	decodes *decodeLimiter
+	slots   chan struct{}
+	mu      sync.Mutex
+	running map[string]struct{}
+}
+
+func newChapterPreloader(ctx context.Context, decodes *decodeLimiter) *chapterPreloader {
+	return &chapterPreloader{
+		ctx:     ctx,
+		decodes: decodes,
+		slots:   make(chan struct{}, preloadDecodeSlots),
+		running: make(map[string]struct{}),
+	}
+}
+
+// start runs fn for chapterID in the background unless a preload of that
+// chapter is already running. It reports whether the request was absorbed
+// by a running preload, and false for started when too many are pending.
+func (p *chapterPreloader) start(chapterID string, fn func(ctx context.Context)) (started bool, coalesced bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if _, ok := p.running[chapterID]; ok {
+		return false, true
+	}
+	if len(p.running) >= maxPendingPreloads {
+		return false, false
+	}
+	p.running[chapterID] = struct{}{}
+	go func() {
+		defer func() {
+			p.mu.Lock()
+			delete(p.running, chapterID)
+			p.mu.Unlock()
+		}()
+		fn(p.ctx)
+	}()
+	return true, false
+}
+
+// acquire waits for one of the preload slots, then for a slot of the
+// decode limiter, and returns a release for both. Requests never wait for
+// a slot, so a preload polls for one rather than queueing ahead of them.
+// It fails only once ctx is done.
+func (p *chapterPreloader) acquire(ctx context.Context) (func(), error) {
+	select {
+	case p.slots <- struct{}{}:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	for {
+		if release, ok := p.decodes.tryAcquire(); ok {
+			return func() {
+				release()
+				<-p.slots
+			}, nil
+		}
+		select {
+		case <-ctx.Done():
+			<-p.slots
+			return nil, ctx.Err()
+		case <-time.After(preloadDecodeRetry):
+		}
+	}
+}
+
+// preloadPage is what warming one page's caches needs to know about it.
+type preloadPage struct {
+	id               string
+	index            int
+	pathRef          string
+	mime             string
+	sizeBytes        int64
+	orientation      int
+	animated         bool
+	chapterUpdatedAt string
+}
+
+// preloadChapter warms the caches a reader is about to hit for a chapter:
+// each page's thumbnail and the variant of the page itself that
+// getChapterPage would serve to this client, a transcode in the first
+// format its Accept header takes or the page turned upright. It answers
+// 202 right away and does the work in the background.
+func (h *imageHandler) preloadChapter(w http.ResponseWriter, r *http.Request) {
+	if h.images == nil {
+		writeError(w, http.StatusInternalServerError, "image service not initialized")
+		return
+	}
+
+	chapterID := chi.URLParam(r, "chapterID")
+	pages, err := h.preloadPages(r.Context(), chapterID)
+	if err == sql.ErrNoRows {
+		writeError(w, http.StatusNotFound, "chapter not found")
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to load chapter pages")
+		return
+	}
+
+	var format string
+	if formats := acceptedPageFormats(r.Header.Get("Accept"), h.images.PageFormats()); len(formats) > 0 {
+		format = formats[0]
+	}
+	started, coalesced := h.preloads.start(chapterID, func(ctx context.Context) {
+		h.warmChapter(ctx, chapterID, pages, format)
+	})
+	if !started && !coalesced {
+		writeError(w, http.StatusTooManyRequests, "too many chapter preloads are running")
+		return
+	}
+
+	writeJSON(w, http.StatusAccepted, chapterPreloadResponse{
+		Status:    "accepted",
+		ChapterID: chapterID,
+		PageCount: len(pages),
+		Coalesced: coalesced,
+	})
+}
+
+// preloadPages lists a chapter's pages, or returns sql.ErrNoRows when the
+// chapter does not exist.
+func (h *imageHandler) preloadPages(ctx context.Context, chapterID string) ([]preloadPage, error) {
+	var chapterUpdatedAt string
+	if err := h.db.QueryRowContext(ctx, `SELECT updated_at FROM chapter WHERE id = ?`, chapterID).Scan(&chapterUpdatedAt); err != nil {
+		return nil, err
+	}
+	rows, err := h.db.QueryContext(ctx, `
+		SELECT id, page_index, path, mime, COALESCE(size_bytes, 0), orientation, is_animated
+		FROM page
+		WHERE chapter_id = ?
+		ORDER BY page_index ASC
+	`, chapterID)
+	if err != nil {
+		return nil, fmt.Errorf("load chapter pages: %w", err)
+	}
+	defer rows.Close()
+
+	pages := make([]preloadPage, 0)
+	for rows.Next() {
+		page := preloadPage{chapterUpdatedAt: chapterUpdatedAt}
+		if err := rows.Scan(&page.id, &page.index, &page.pathRef, &page.mime, &page.sizeBytes, &page.orientation, &page.animated); err != nil {
+			return nil, fmt.Errorf("scan chapter page: %w", err)
+		}
+		pages = append(pages, page)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate chapter pages: %w", err)
+	}
+	return pages, nil
+}
+
+func (h *imageHandler) warmChapter(ctx context.Context, chapterID string, pages []preloadPage, format string) {
+	warmed := 0
+	for _, page := range pages {
+		if ctx.Err() != nil {
+			return
+		}
+		if err := h.warmPage(ctx, chapterID, page, format); err != nil {
+			if h.logger != nil {
+				h.logger.Warn("chapter preload failed for page", "chapter_id", chapterID, "page_index", page.index, "error", err)
+			}
+			continue
+		}
+		warmed++
+	}
+	if h.logger != nil {
+		h.logger.Debug("chapter preload complete", "chapter_id", chapterID, "pages", len(pages), "warmed", warmed)
+	}
+}
+
+// warmPage fills the thumbnail and page caches of one page, skipping the
+// ones already current.
+func (h *imageHandler) warmPage(ctx context.Context, chapterID string, page preloadPage, format string) error {
+	if _, ok := h.images.CachedPageThumb(ctx, chapterID, page.index); !ok {
+		release, err := h.preloads.acquire(ctx)
+		if err != nil {
+			return err
+		}
+		_, err = h.images.EnsurePageThumb(ctx, chapterID, page.index)
+		release()
+		if err != nil {
+			return fmt.Errorf("thumbnail: %w", err)
+		}
+	}
+	if page.animated {
+		return nil
+	}
+
+	transcode := format != "" && h.images.Transcodes(page.mime)
+	upright := h.normalizeOrientation && page.orientation > media.OrientationNormal
+	if !transcode && !upright {
+		return nil
+	}
+	etag, err := h.etags.page(ctx, page.id, page.pathRef, page.sizeBytes, page.chapterUpdatedAt)
+	if err != nil {
+		return fmt.Errorf("page etag: %w", err)
+	}
+	if transcode {
+		key := transcodeKey(etag, page.orientation)
+		if _, ok := h.images.CachedPage(key, format); ok {
+			return nil
+		}
+		release, err := h.preloads.acquire(ctx)
+		if err != nil {
+			return err
+		}
+		defer release()
+		if _, err := h.images.TranscodePage(ctx, page.pathRef, page.mime, page.orientation, key, format); err != nil {
+			return fmt.Errorf("transcode: %w", err)
+		}
+		return nil
+	}
+	if _, ok := h.images.CachedUprightPage(etag); ok {
+		return nil
+	}
+	release, err := h.preloads.acquire(ctx)
+	if err != nil {
+		return err
+	}
+	defer release()
+	if _, err := h.images.UprightPage(ctx, page.pathRef, etag); err != nil {
+		return fmt.Errorf("upright: %w", err)
+	}
+	return nil
+}
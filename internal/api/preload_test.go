@@ -0,0 +1,93 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestChapterPreloaderHoldsDecodeSlot(t *testing.T) {
+	limiter := newDecodeLimiter(1)
+	preloads := newChapterPreloader(context.Background(), limiter)
+
+	release, err := preloads.acquire(context.Background())
+	if err != nil {
+		t.Fatalf("acquire: %v", err)
+	}
+	if _, ok := limiter.tryAcquire(); ok {
+		t.Fatal("request got a decode slot while a preload held the only one")
+	}
+	release()
+	requestRelease, ok := limiter.tryAcquire()
+	if !ok {
+		t.Fatal("decode slot not returned by the preload")
+	}
+	requestRelease()
+}
+
+func TestChapterPreloaderWaitsForRequests(t *testing.T) {
+	limiter := newDecodeLimiter(1)
+	preloads := newChapterPreloader(context.Background(), limiter)
+	requestRelease, _ := limiter.tryAcquire()
+
+	acquired := make(chan func(), 1)
+	go func() {
+		release, err := preloads.acquire(context.Background())
+		if err == nil {
+			acquired <- release
+		}
+	}()
+
+	select {
+	case <-acquired:
+		t.Fatal("preload decoded while a request held every slot")
+	case <-time.After(3 * preloadDecodeRetry):
+	}
+	requestRelease()
+	select {
+	case release := <-acquired:
+		release()
+	case <-time.After(time.Second):
+		t.Fatal("preload did not take the slot the request freed")
+	}
+}
+
+func TestChapterPreloaderStopsWithServer(t *testing.T) {
+	limiter := newDecodeLimiter(1)
+	server, shutdown := context.WithCancel(context.Background())
+	preloads := newChapterPreloader(server, limiter)
+	requestRelease, _ := limiter.tryAcquire()
+	defer requestRelease()
+
+	result := make(chan error, 1)
+	started, _ := preloads.start("c1", func(ctx context.Context) {
+		_, err := preloads.acquire(ctx)
+		result <- err
+	})
+	if !started {
+		t.Fatal("preload not started")
+	}
+	shutdown()
+	select {
+	case err := <-result:
+		if !errors.Is(err, context.Canceled) {
+			t.Fatalf("acquire after shutdown = %v, want context.Canceled", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("preload still waiting after shutdown")
+	}
+}
+
+func TestChapterPreloaderCoalesces(t *testing.T) {
+	preloads := newChapterPreloader(context.Background(), nil)
+	block := make(chan struct{})
+	defer close(block)
+
+	if started, _ := preloads.start("c1", func(context.Context) { <-block }); !started {
+		t.Fatal("first preload not started")
+	}
+	if started, coalesced := preloads.start("c1", func(context.Context) {}); started || !coalesced {
+		t.Fatalf("second preload of the chapter: started %v, coalesced %v", started, coalesced)
+	}
+}
@@ -0,0 +1,336 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"math"
+	"net/http"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+
+	"mynewmangaui/internal/clock"
+	"mynewmangaui/internal/store"
+)
+
+type progressHandler struct {
+	db     *sql.DB
+	store  *store.Store
+	counts *LibraryCountCache
+	clock  clock.Clock
+}
+
+type progressUpdateRequest struct {
+	PageIndex *int  `json:"pageIndex"`
+	Completed *bool `json:"completed"`
+}
+
+type chapterProgressItem struct {
+	ChapterID string `json:"chapterId"`
+	PageIndex int    `json:"pageIndex"`
+	Completed bool   `json:"completed"`
+	UpdatedAt string `json:"updatedAt"`
+}
+
+type mangaProgressResponse struct {
+	MangaID string                `json:"mangaId"`
+	Items   []chapterProgressItem `json:"items"`
+}
+
+func newProgressHandler(db *sql.DB, store *store.Store, counts *LibraryCountCache, clock clock.Clock) *progressHandler {
+	return &progressHandler{db: db, store: store, counts: counts, clock: clock}
+}
+
+// updateChapterProgress records the page a chapter was read up to. A chapter
+// counts as completed once its last page is reached unless the client says
+// otherwise.
+func (h *progressHandler) updateChapterProgress(w http.ResponseWriter, r *http.Request) {
+	if h.db == nil {
+		writeError(w, http.StatusInternalServerError, "database not initialized")
+		return
+	}
+
+	chapterID := strings.TrimSpace(chi.URLParam(r, "chapterID"))
+	var request progressUpdateRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil || request.PageIndex == nil {
+		writeError(w, http.StatusBadRequest, "invalid progress payload")
+		return
+	}
+	if *request.PageIndex < 0 {
+		writeError(w, http.StatusBadRequest, "page index must not be negative")
+		return
+	}
+
+	chapter, err := h.store.GetChapter(r.Context(), chapterID)
+	if err == sql.ErrNoRows {
+		writeError(w, http.StatusNotFound, "chapter not found")
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to load chapter")
+		return
+	}
+
+	completed := chapter.PageCount > 0 && *request.PageIndex >= chapter.PageCount-1
+	if request.Completed != nil {
+		completed = *request.Completed
+	}
+
+	if _, err := h.db.ExecContext(r.Context(), `
+		INSERT INTO reading_progress(chapter_id, manga_id, page_index, completed, updated_at)
+		VALUES(?, ?, ?, ?, ?)
+		ON CONFLICT(chapter_id) DO UPDATE SET
+			manga_id = excluded.manga_id,
+			page_index = excluded.page_index,
+			completed = excluded.completed,
+			updated_at = excluded.updated_at
+	`, chapterID, chapter.MangaID, *request.PageIndex, boolToInt(completed), h.sqliteNow()); err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to save progress")
+		return
+	}
+	h.counts.Invalidate()
+
+	writeJSON(w, http.StatusOK, map[string]any{
+		"chapterId": chapterID,
+		"mangaId":   chapter.MangaID,
+		"pageIndex": *request.PageIndex,
+		"completed": completed,
+	})
+}
+
+type catchUpRequest struct {
+	ChapterID     string   `json:"chapterId"`
+	ChapterNumber *float64 `json:"chapterNumber"`
+}
+
+// catchUp marks every chapter ordered before the target as read, leaving
+// the target and later chapters untouched. The target is a chapter id or a
+// chapter number, in which case all chapters numbered below it count as
+// before it. Chapters follow the same order as the chapter list.
+func (h *progressHandler) catchUp(w http.ResponseWriter, r *http.Request) {
+	if h.db == nil {
+		writeError(w, http.StatusInternalServerError, "database not initialized")
+		return
+	}
+
+	mangaID := chi.URLParam(r, "mangaID")
+	var request catchUpRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid catch-up payload")
+		return
+	}
+	request.ChapterID = strings.TrimSpace(request.ChapterID)
+	if request.ChapterID == "" && request.ChapterNumber == nil {
+		writeError(w, http.StatusBadRequest, "chapterId or chapterNumber is required")
+		return
+	}
+
+	tx, err := h.db.BeginTx(r.Context(), nil)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to start transaction")
+		return
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.QueryContext(r.Context(), `
+		SELECT id, chapter_number, page_count
+		FROM chapter
+		WHERE manga_id = ?
+		ORDER BY chapter_number ASC, title ASC, id ASC
+	`, mangaID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to load chapters")
+		return
+	}
+	defer rows.Close()
+
+	type catchUpChapter struct {
+		id        string
+		number    float64
+		pageCount int
+	}
+	chapters := make([]catchUpChapter, 0)
+	for rows.Next() {
+		var chapter catchUpChapter
+		if err := rows.Scan(&chapter.id, &chapter.number, &chapter.pageCount); err != nil {
+			writeError(w, http.StatusInternalServerError, "failed to read chapter row")
+			return
+		}
+		chapters = append(chapters, chapter)
+	}
+	if err := rows.Err(); err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to iterate chapter rows")
+		return
+	}
+	rows.Close()
+
+	if len(chapters) == 0 {
+		writeError(w, http.StatusNotFound, "manga not found")
+		return
+	}
+
+	cutoff := -1
+	for index, chapter := range chapters {
+		if request.ChapterID != "" && chapter.id == request.ChapterID {
+			cutoff = index
+			break
+		}
+		if request.ChapterID == "" && chapter.number >= *request.ChapterNumber {
+			cutoff = index
+			break
+		}
+	}
+	if cutoff < 0 {
+		if request.ChapterID != "" {
+			writeError(w, http.StatusNotFound, "chapter not found in manga")
+			return
+		}
+		cutoff = len(chapters)
+	}
+
+	now := h.sqliteNow()
+	for _, chapter := range chapters[:cutoff] {
+		if _, err := tx.ExecContext(r.Context(), `
+			INSERT INTO reading_progress(chapter_id, manga_id, page_index, completed, updated_at)
+			VALUES(?, ?, ?, 1, ?)
+			ON CONFLICT(chapter_id) DO UPDATE SET
+				manga_id = excluded.manga_id,
+				page_index = excluded.page_index,
+				completed = 1,
+				updated_at = excluded.updated_at
+		`, chapter.id, mangaID, max(chapter.pageCount-1, 0), now); err != nil {
+			writeError(w, http.StatusInternalServerError, "failed to save progress")
+			return
+		}
+	}
+
+	var completed int
+	if err := tx.QueryRowContext(r.Context(), `
+		SELECT COUNT(*)
+		FROM reading_progress p
+		JOIN chapter c ON c.id = p.chapter_id
+		WHERE c.manga_id = ? AND p.completed = 1
+	`, mangaID).Scan(&completed); err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to count completed chapters")
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to commit progress")
+		return
+	}
+	h.counts.Invalidate()
+
+	writeJSON(w, http.StatusOK, map[string]any{
+		"mangaId":           mangaID,
+		"markedChapters":    cutoff,
+		"completedChapters": completed,
+		"totalChapters":     len(chapters),
+	})
+}
+
+func (h *progressHandler) sqliteNow() string {
+	return h.clock.Now().UTC().Format("2006-01-02 15:04:05")
+}
+
+func (h *progressHandler) getMangaProgress(w http.ResponseWriter, r *http.Request) {
+	if h.db == nil {
+		writeError(w, http.StatusInternalServerError, "database not initialized")
+		return
+	}
+
+	mangaID := chi.URLParam(r, "mangaID")
+	rows, err := h.db.QueryContext(r.Context(), `
+		SELECT p.chapter_id, p.page_index, p.completed, p.updated_at
+		FROM reading_progress p
+		JOIN chapter c ON c.id = p.chapter_id
+		WHERE p.manga_id = ?
+		ORDER BY c.chapter_number ASC, c.title ASC, c.id ASC
+	`, mangaID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to load progress")
+		return
+	}
+	defer rows.Close()
+
+	items := make([]chapterProgressItem, 0)
+	for rows.Next() {
+		var item chapterProgressItem
+		if err := rows.Scan(&item.ChapterID, &item.PageIndex, &item.Completed, &item.UpdatedAt); err != nil {
+			writeError(w, http.StatusInternalServerError, "failed to read progress row")
+			return
+		}
+		items = append(items, item)
+	}
+	if err := rows.Err(); err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to iterate progress rows")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, mangaProgressResponse{MangaID: mangaID, Items: items})
+}
+
+type mangaReadingStatsResponse struct {
+	MangaID       string `json:"mangaId"`
+	ChaptersTotal int    `json:"chaptersTotal"`
+	// ChaptersStarted counts the chapters with any progress, completed ones
+	// included.
+	ChaptersStarted   int `json:"chaptersStarted"`
+	ChaptersCompleted int `json:"chaptersCompleted"`
+	PagesTotal        int `json:"pagesTotal"`
+	PagesRead         int `json:"pagesRead"`
+	// PercentComplete is PagesRead over PagesTotal, to one decimal.
+	PercentComplete float64 `json:"percentComplete"`
+}
+
+// getMangaReadingStats sums up how far a manga has been read. A completed
+// chapter counts all of its pages as read and a started one the pages up
+// to the one reached; a manga without progress has zeros.
+func (h *progressHandler) getMangaReadingStats(w http.ResponseWriter, r *http.Request) {
+	if h.db == nil {
+		writeError(w, http.StatusInternalServerError, "database not initialized")
+		return
+	}
+
+	mangaID := chi.URLParam(r, "mangaID")
+	var exists bool
+	if err := h.db.QueryRowContext(r.Context(), `SELECT EXISTS(SELECT 1 FROM manga WHERE id = ?)`, mangaID).Scan(&exists); err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to load manga")
+		return
+	}
+	if !exists {
+		writeError(w, http.StatusNotFound, "manga not found")
+		return
+	}
+
+	response := mangaReadingStatsResponse{MangaID: mangaID}
+	if err := h.db.QueryRowContext(r.Context(), `
+		SELECT
+			COUNT(*),
+			COUNT(p.chapter_id),
+			COALESCE(SUM(p.completed = 1), 0),
+			COALESCE(SUM(c.page_count), 0),
+			COALESCE(SUM(CASE
+				WHEN p.completed = 1 THEN c.page_count
+				WHEN p.chapter_id IS NOT NULL THEN MIN(p.page_index + 1, c.page_count)
+				ELSE 0
+			END), 0)
+		FROM chapter c
+		LEFT JOIN reading_progress p ON p.chapter_id = c.id
+		WHERE c.manga_id = ?
+	`, mangaID).Scan(
+		&response.ChaptersTotal,
+		&response.ChaptersStarted,
+		&response.ChaptersCompleted,
+		&response.PagesTotal,
+		&response.PagesRead,
+	); err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to load reading stats")
+		return
+	}
+	if response.PagesTotal > 0 {
+		response.PercentComplete = math.Round(float64(response.PagesRead)*1000/float64(response.PagesTotal)) / 10
+	}
+
+	writeJSON(w, http.StatusOK, response)
+}
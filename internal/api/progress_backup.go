@@ -0,0 +1,212 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const progressBackupFormatVersion = 1
+
+// progressBackup is the reading progress export. Chapters are keyed by
+// their path relative to their bookshelf rather than by id, so progress
+// can be restored into a library scanned from scratch, even one whose
+// bookshelves live somewhere else.
+type progressBackup struct {
+	Version    int                   `json:"version"`
+	ExportedAt string                `json:"exportedAt"`
+	Entries    []progressBackupEntry `json:"entries"`
+}
+
+type progressBackupEntry struct {
+	// Bookshelf is the name of the chapter's bookshelf; it only decides
+	// between chapters with the same relative path on several bookshelves.
+	Bookshelf string `json:"bookshelf,omitempty"`
+	// Path is the chapter's path relative to its bookshelf, with forward
+	// slashes, or its full path for chapters outside any bookshelf.
+	Path string `json:"path"`
+	backupProgress
+}
+
+type progressImportResponse struct {
+	Matched int `json:"matched"`
+	// Applied counts the matched entries that replaced older local
+	// progress; the others were older than what is stored.
+	Applied   int      `json:"applied"`
+	Unmatched []string `json:"unmatched"`
+}
+
+// backupChapterRef is a local chapter as progress backups refer to it.
+type backupChapterRef struct {
+	id        string
+	mangaID   string
+	bookshelf string
+	path      string
+}
+
+// exportProgress writes all reading progress keyed by chapter path.
+func (h *backupHandler) exportProgress(w http.ResponseWriter, r *http.Request) {
+	if h.db == nil {
+		writeError(w, http.StatusInternalServerError, "database not initialized")
+		return
+	}
+
+	rows, err := h.db.QueryContext(r.Context(), `
+		SELECT
+			COALESCE(b.name, ''), COALESCE(b.root_path, ''), c.path,
+			p.page_index, p.completed, strftime('%Y-%m-%dT%H:%M:%SZ', p.updated_at)
+		FROM reading_progress p
+		JOIN chapter c ON c.id = p.chapter_id
+		JOIN manga m ON m.id = c.manga_id
+		LEFT JOIN bookshelf b ON b.id = m.bookshelf_id
+		ORDER BY b.name ASC, c.path ASC
+	`)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to export progress")
+		return
+	}
+	defer rows.Close()
+
+	backup := progressBackup{
+		Version:    progressBackupFormatVersion,
+		ExportedAt: h.clock.Now().UTC().Format(time.RFC3339),
+		Entries:    make([]progressBackupEntry, 0),
+	}
+	for rows.Next() {
+		var entry progressBackupEntry
+		var root, path string
+		if err := rows.Scan(&entry.Bookshelf, &root, &path, &entry.PageIndex, &entry.Completed, &entry.UpdatedAt); err != nil {
+			writeError(w, http.StatusInternalServerError, "failed to read progress row")
+			return
+		}
+		entry.Path = chapterBackupPath(root, path)
+		backup.Entries = append(backup.Entries, entry)
+	}
+	if err := rows.Err(); err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to iterate progress rows")
+		return
+	}
+
+	w.Header().Set("Content-Disposition", `attachment; filename="progress-backup.json"`)
+	writeJSON(w, http.StatusOK, backup)
+}
+
+// importProgressBackup restores an exported progress backup, matching
+// chapters by path. Each matched entry replaces the local progress only if
+// it is more recent; entries matching no chapter are reported back.
+func (h *backupHandler) importProgressBackup(w http.ResponseWriter, r *http.Request) {
+	if h.db == nil {
+		writeError(w, http.StatusInternalServerError, "database not initialized")
+		return
+	}
+
+	var backup progressBackup
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBackupImportBytes)).Decode(&backup); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid progress backup payload")
+		return
+	}
+	if backup.Version != progressBackupFormatVersion {
+		writeError(w, http.StatusBadRequest, "unsupported progress backup version")
+		return
+	}
+
+	tx, err := h.db.BeginTx(r.Context(), nil)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to start transaction")
+		return
+	}
+	defer tx.Rollback()
+
+	chapters, err := backupChapterRefs(r.Context(), tx)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to load chapters")
+		return
+	}
+
+	response := progressImportResponse{Unmatched: make([]string, 0)}
+	now := h.clock.Now()
+	for _, entry := range backup.Entries {
+		chapter, ok := matchBackupChapter(chapters[strings.TrimSpace(entry.Path)], entry.Bookshelf)
+		if !ok {
+			response.Unmatched = append(response.Unmatched, entry.Path)
+			continue
+		}
+		response.Matched++
+		applied, err := importProgress(r.Context(), tx, chapter.id, chapter.mangaID, entry.backupProgress, now)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, "failed to import progress")
+			return
+		}
+		if applied {
+			response.Applied++
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to commit import")
+		return
+	}
+	h.counts.Invalidate()
+
+	writeJSON(w, http.StatusOK, response)
+}
+
+// backupChapterRefs loads every chapter by the path progress backups key
+// it by.
+func backupChapterRefs(ctx context.Context, q rowQuerier) (map[string][]backupChapterRef, error) {
+	rows, err := q.QueryContext(ctx, `
+		SELECT c.id, c.manga_id, c.path, COALESCE(b.name, ''), COALESCE(b.root_path, '')
+		FROM chapter c
+		JOIN manga m ON m.id = c.manga_id
+		LEFT JOIN bookshelf b ON b.id = m.bookshelf_id
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	chapters := make(map[string][]backupChapterRef)
+	for rows.Next() {
+		var chapter backupChapterRef
+		var path, root string
+		if err := rows.Scan(&chapter.id, &chapter.mangaID, &path, &chapter.bookshelf, &root); err != nil {
+			return nil, err
+		}
+		chapter.path = chapterBackupPath(root, path)
+		chapters[chapter.path] = append(chapters[chapter.path], chapter)
+	}
+	return chapters, rows.Err()
+}
+
+// matchBackupChapter picks the chapter a backup entry refers to among the
+// local chapters sharing its path, using the bookshelf name when there are
+// several. It fails when that still leaves more than one.
+func matchBackupChapter(candidates []backupChapterRef, bookshelf string) (backupChapterRef, bool) {
+	if len(candidates) == 1 {
+		return candidates[0], true
+	}
+	var match backupChapterRef
+	found := 0
+	for _, chapter := range candidates {
+		if chapter.bookshelf == bookshelf {
+			match = chapter
+			found++
+		}
+	}
+	return match, found == 1
+}
+
+// chapterBackupPath returns path relative to the bookshelf root, with
+// forward slashes, or all of it when it is not inside root.
+func chapterBackupPath(root, path string) string {
+	if root != "" {
+		rel, err := filepath.Rel(root, path)
+		if err == nil && rel != "." && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return filepath.ToSlash(rel)
+		}
+	}
+	return filepath.ToSlash(path)
+}
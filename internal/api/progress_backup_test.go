@@ -0,0 +1,146 @@
+package api
+
+import (
+	"maps"
+	"net/http"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestProgressBackupRoundTrip(t *testing.T) {
+	const admin = `{"server":{"adminToken":"secret"}}`
+	source := newTestServer(t, admin)
+	writeChapter(t, source.root, "Alpha", "Chapter 1", 3)
+	writeChapter(t, source.root, "Alpha", "Chapter 2", 3)
+	writeChapter(t, source.root, "Beta", "Chapter 1", 3)
+	writeChapter(t, source.root, "Gone", "Chapter 1", 3)
+	source.scan()
+	// read records progress on a server, dated at so the last-write-wins
+	// comparisons do not hang on the clock.
+	read := func(server *testServer, manga string, chapter string, body string, at string) {
+		t.Helper()
+		id := server.queryString(`
+			SELECT c.id FROM chapter c JOIN manga m ON m.id = c.manga_id
+			WHERE m.title = ? AND c.title = ?
+		`, manga, chapter)
+		if rec := server.do(http.MethodPut, "/api/chapters/"+id+"/progress", body); rec.Code != http.StatusOK {
+			t.Fatalf("progress status = %d, body %s", rec.Code, rec.Body.String())
+		}
+		if _, err := server.db.Exec(`UPDATE reading_progress SET updated_at = ? WHERE chapter_id = ?`, at, id); err != nil {
+			t.Fatal(err)
+		}
+	}
+	read(source, "Alpha", "Chapter 1", `{"pageIndex":2}`, "2026-05-01 10:00:00")
+	read(source, "Alpha", "Chapter 2", `{"pageIndex":1}`, "2026-05-01 11:00:00")
+	read(source, "Beta", "Chapter 1", `{"pageIndex":1}`, "2026-05-01 09:00:00")
+	read(source, "Gone", "Chapter 1", `{"pageIndex":0}`, "2026-05-01 09:00:00")
+
+	if rec := source.do(http.MethodGet, "/api/admin/progress/export", ""); rec.Code != http.StatusUnauthorized && rec.Code != http.StatusForbidden {
+		t.Errorf("export without the admin token = %d, want it refused", rec.Code)
+	}
+	rec := source.do(http.MethodGet, "/api/admin/progress/export", "", "X-Admin-Token", "secret")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("export status = %d, body %s", rec.Code, rec.Body.String())
+	}
+	exported := rec.Body.String()
+	backup := decodeJSON[progressBackup](t, rec)
+	paths := []string{}
+	for _, entry := range backup.Entries {
+		paths = append(paths, entry.Path)
+	}
+	if want := []string{"Alpha/Chapter 1", "Alpha/Chapter 2", "Beta/Chapter 1", "Gone/Chapter 1"}; backup.Version != progressBackupFormatVersion || !slices.Equal(paths, want) {
+		t.Fatalf("export = version %d, paths %q; want %q relative to the bookshelf", backup.Version, paths, want)
+	}
+	if entry := backup.Entries[0]; entry.Bookshelf != "main" || entry.PageIndex != 2 || !entry.Completed || entry.UpdatedAt != "2026-05-01T10:00:00Z" {
+		t.Errorf("exported entry = %+v", entry)
+	}
+
+	// The target library lives elsewhere, so every chapter id differs, and
+	// lacks Gone. Its newer Beta progress wins over the backup's.
+	target := newTestServer(t, admin)
+	writeChapter(t, target.root, "Alpha", "Chapter 1", 3)
+	writeChapter(t, target.root, "Alpha", "Chapter 2", 3)
+	writeChapter(t, target.root, "Beta", "Chapter 1", 3)
+	target.scan()
+	read(target, "Beta", "Chapter 1", `{"pageIndex":2}`, "2026-05-02 09:00:00")
+
+	importBackup := func(body string) progressImportResponse {
+		t.Helper()
+		rec := target.do(http.MethodPost, "/api/admin/progress/import", body, "X-Admin-Token", "secret")
+		if rec.Code != http.StatusOK {
+			t.Fatalf("import status = %d, body %s", rec.Code, rec.Body.String())
+		}
+		return decodeJSON[progressImportResponse](t, rec)
+	}
+	result := importBackup(exported)
+	if result.Matched != 3 || result.Applied != 2 || !slices.Equal(result.Unmatched, []string{"Gone/Chapter 1"}) {
+		t.Fatalf("import = %+v, want 3 matched, 2 applied and Gone unmatched", result)
+	}
+
+	progress := func(server *testServer) map[string]string {
+		t.Helper()
+		rows, err := server.db.Query(`
+			SELECT m.title || '/' || c.title, p.page_index || ' ' || p.completed || ' ' || p.updated_at
+			FROM reading_progress p
+			JOIN chapter c ON c.id = p.chapter_id
+			JOIN manga m ON m.id = c.manga_id
+		`)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer rows.Close()
+		got := map[string]string{}
+		for rows.Next() {
+			var chapter, state string
+			if err := rows.Scan(&chapter, &state); err != nil {
+				t.Fatal(err)
+			}
+			got[chapter] = state
+		}
+		if err := rows.Err(); err != nil {
+			t.Fatal(err)
+		}
+		return got
+	}
+	want := map[string]string{
+		"Alpha/Chapter 1": "2 1 2026-05-01 10:00:00",
+		"Alpha/Chapter 2": "1 0 2026-05-01 11:00:00",
+		"Beta/Chapter 1":  "2 1 2026-05-02 09:00:00",
+	}
+	if got := progress(target); !maps.Equal(got, want) {
+		t.Fatalf("progress after import = %v, want %v", got, want)
+	}
+
+	// Importing again changes nothing.
+	if result := importBackup(exported); result.Matched != 3 || result.Applied != 0 {
+		t.Errorf("second import = %+v, want nothing applied", result)
+	}
+	if got := progress(target); !maps.Equal(got, want) {
+		t.Fatalf("progress after second import = %v, want %v", got, want)
+	}
+
+	t.Run("unmatched paths", func(t *testing.T) {
+		result := importBackup(`{"version":1,"entries":[
+			{"path":"Alpha/Chapter 9","pageIndex":0,"updatedAt":"2026-06-01T00:00:00Z"},
+			{"path":"alpha/chapter 1","pageIndex":0,"updatedAt":"2026-06-01T00:00:00Z"},
+			{"path":"Alpha","pageIndex":0,"updatedAt":"2026-06-01T00:00:00Z"}
+		]}`)
+		if result.Matched != 0 || result.Applied != 0 || len(result.Unmatched) != 3 {
+			t.Errorf("import = %+v, want all three unmatched", result)
+		}
+		if got := progress(target); !maps.Equal(got, want) {
+			t.Errorf("progress after unmatched import = %v, want it unchanged", got)
+		}
+	})
+
+	for body, message := range map[string]string{
+		`{"version":2,"entries":[]}`: "unsupported progress backup version",
+		`not json`:                   "invalid progress backup payload",
+	} {
+		rec := target.do(http.MethodPost, "/api/admin/progress/import", body, "X-Admin-Token", "secret")
+		if rec.Code != http.StatusBadRequest || !strings.Contains(rec.Body.String(), message) {
+			t.Errorf("import of %s = %d %s, want 400 %q", body, rec.Code, rec.Body.String(), message)
+		}
+	}
+}
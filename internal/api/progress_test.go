@@ -0,0 +1,137 @@
+package api
+
+import (
+	"maps"
+	"net/http"
+	"testing"
+)
+
+// mangaProgress returns the completion of each chapter of the manga with
+// progress, keyed by chapter title.
+func (s *testServer) mangaProgress(mangaID string) map[string]bool {
+	s.t.Helper()
+	rec := s.do(http.MethodGet, "/api/manga/"+mangaID+"/progress", "")
+	if rec.Code != http.StatusOK {
+		s.t.Fatalf("progress status = %d, body %s", rec.Code, rec.Body.String())
+	}
+	progress := make(map[string]bool)
+	for _, item := range decodeJSON[mangaProgressResponse](s.t, rec).Items {
+		progress[s.queryString(`SELECT title FROM chapter WHERE id = ?`, item.ChapterID)] = item.Completed
+	}
+	return progress
+}
+
+func TestCatchUpMarksEarlierChapters(t *testing.T) {
+	server := newTestServer(t, "")
+	for _, chapter := range []string{"Chapter 1", "Chapter 2", "Chapter 3", "Chapter 4", "Chapter 10"} {
+		writeChapter(t, server.root, "Alpha", chapter, 2)
+	}
+	writeChapter(t, server.root, "Beta", "Chapter 1", 2)
+	server.scan()
+	mangaID := server.queryString(`SELECT id FROM manga WHERE title = 'Alpha'`)
+	chapterID := func(manga string, chapter string) string {
+		return server.queryString(`
+			SELECT c.id FROM chapter c JOIN manga m ON m.id = c.manga_id
+			WHERE m.title = ? AND c.title = ?
+		`, manga, chapter)
+	}
+	if rec := server.do(http.MethodPut, "/api/chapters/"+chapterID("Alpha", "Chapter 4")+"/progress", `{"pageIndex":0}`); rec.Code != http.StatusOK {
+		t.Fatalf("progress status = %d, body %s", rec.Code, rec.Body.String())
+	}
+
+	rec := server.do(http.MethodPost, "/api/manga/"+mangaID+"/catch-up", `{"chapterId":"`+chapterID("Alpha", "Chapter 3")+`"}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("catch-up status = %d, body %s", rec.Code, rec.Body.String())
+	}
+	counts := decodeJSON[map[string]any](t, rec)
+	if counts["markedChapters"] != 2.0 || counts["completedChapters"] != 2.0 || counts["totalChapters"] != 5.0 {
+		t.Fatalf("catch-up response = %v", counts)
+	}
+	want := map[string]bool{"Chapter 1": true, "Chapter 2": true, "Chapter 4": false}
+	if got := server.mangaProgress(mangaID); !maps.Equal(got, want) {
+		t.Fatalf("progress = %v, want %v", got, want)
+	}
+	if got := server.mangaProgress(server.queryString(`SELECT id FROM manga WHERE title = 'Beta'`)); len(got) != 0 {
+		t.Fatalf("Beta progress = %v, want none", got)
+	}
+
+	// By number, chapters numbered below the target count as before it,
+	// and natural order puts Chapter 10 after Chapter 4.
+	rec = server.do(http.MethodPost, "/api/manga/"+mangaID+"/catch-up", `{"chapterNumber":5}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("catch-up by number status = %d, body %s", rec.Code, rec.Body.String())
+	}
+	want = map[string]bool{"Chapter 1": true, "Chapter 2": true, "Chapter 3": true, "Chapter 4": true}
+	if got := server.mangaProgress(mangaID); !maps.Equal(got, want) {
+		t.Fatalf("progress after catch-up by number = %v, want %v", got, want)
+	}
+
+	tests := []struct {
+		name    string
+		mangaID string
+		body    string
+		status  int
+	}{
+		{name: "no target", mangaID: mangaID, body: `{}`, status: http.StatusBadRequest},
+		{name: "chapter of another manga", mangaID: mangaID, body: `{"chapterId":"` + chapterID("Beta", "Chapter 1") + `"}`, status: http.StatusNotFound},
+		{name: "missing manga", mangaID: "missing", body: `{"chapterNumber":1}`, status: http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if rec := server.do(http.MethodPost, "/api/manga/"+tt.mangaID+"/catch-up", tt.body); rec.Code != tt.status {
+				t.Fatalf("status = %d, want %d", rec.Code, tt.status)
+			}
+		})
+	}
+}
+
+func TestMangaReadingStats(t *testing.T) {
+	server := newTestServer(t, "")
+	writeChapter(t, server.root, "Alpha", "Chapter 1", 4)
+	writeChapter(t, server.root, "Alpha", "Chapter 2", 4)
+	writeChapter(t, server.root, "Alpha", "Chapter 3", 2)
+	server.scan()
+	mangaID := server.queryString(`SELECT id FROM manga WHERE title = 'Alpha'`)
+	chapterID := func(chapter string) string {
+		return server.queryString(`SELECT id FROM chapter WHERE manga_id = ? AND title = ?`, mangaID, chapter)
+	}
+	read := func(chapter string, body string) {
+		t.Helper()
+		if rec := server.do(http.MethodPut, "/api/chapters/"+chapterID(chapter)+"/progress", body); rec.Code != http.StatusOK {
+			t.Fatalf("progress status = %d, body %s", rec.Code, rec.Body.String())
+		}
+	}
+	assertStats := func(want mangaReadingStatsResponse) {
+		t.Helper()
+		rec := server.do(http.MethodGet, "/api/manga/"+mangaID+"/reading-stats", "")
+		if rec.Code != http.StatusOK {
+			t.Fatalf("reading stats status = %d, body %s", rec.Code, rec.Body.String())
+		}
+		want.MangaID = mangaID
+		want.ChaptersTotal = 3
+		want.PagesTotal = 10
+		if got := decodeJSON[mangaReadingStatsResponse](t, rec); got != want {
+			t.Fatalf("reading stats = %+v, want %+v", got, want)
+		}
+	}
+
+	assertStats(mangaReadingStatsResponse{})
+
+	// A started chapter counts the pages up to the one reached.
+	read("Chapter 1", `{"pageIndex":1}`)
+	assertStats(mangaReadingStatsResponse{ChaptersStarted: 1, PagesRead: 2, PercentComplete: 20})
+
+	// A completed chapter counts all its pages whatever page was reached,
+	// and a page past the end counts no more than the chapter has.
+	read("Chapter 2", `{"pageIndex":0,"completed":true}`)
+	read("Chapter 3", `{"pageIndex":7,"completed":false}`)
+	assertStats(mangaReadingStatsResponse{ChaptersStarted: 3, ChaptersCompleted: 1, PagesRead: 8, PercentComplete: 80})
+
+	read("Chapter 1", `{"pageIndex":3}`)
+	read("Chapter 3", `{"pageIndex":1}`)
+	assertStats(mangaReadingStatsResponse{ChaptersStarted: 3, ChaptersCompleted: 3, PagesRead: 10, PercentComplete: 100})
+
+	if rec := server.do(http.MethodGet, "/api/manga/missing/reading-stats", ""); rec.Code != http.StatusNotFound {
+		t.Errorf("reading stats of a missing manga = %d, want 404", rec.Code)
+	}
+}
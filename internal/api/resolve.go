@@ -0,0 +1,90 @@
+package api
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"path/filepath"
+	"strings"
+
+	"mynewmangaui/internal/media"
+)
+
+type resolveRequest struct {
+	Path string `json:"path"`
+}
+
+type resolveResponse struct {
+	MangaID   string `json:"mangaId"`
+	ChapterID string `json:"chapterId,omitempty"`
+	PageID    string `json:"pageId,omitempty"`
+	PageIndex *int   `json:"pageIndex,omitempty"`
+}
+
+// resolvePath maps a filesystem path inside a bookshelf to the page, chapter
+// or manga stored for it, so external tools can deep-link into the reader.
+func (h *mangaHandler) resolvePath(w http.ResponseWriter, r *http.Request) {
+	if h.db == nil {
+		writeError(w, http.StatusInternalServerError, "database not initialized")
+		return
+	}
+
+	var request resolveRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid resolve payload")
+		return
+	}
+	if strings.TrimSpace(request.Path) == "" {
+		writeError(w, http.StatusBadRequest, "path is required")
+		return
+	}
+
+	path, err := filepath.Abs(filepath.Clean(strings.TrimSpace(request.Path)))
+	if err != nil || !pathWithinBookshelves(path, h.storage.Bookshelves) {
+		writeError(w, http.StatusBadRequest, "path is outside the library roots")
+		return
+	}
+
+	response, err := resolveLibraryPath(r.Context(), h.db, path)
+	if err == sql.ErrNoRows {
+		writeError(w, http.StatusNotFound, "path is not in the library")
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to resolve path")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, response)
+}
+
+func resolveLibraryPath(ctx context.Context, db *sql.DB, path string) (resolveResponse, error) {
+	var response resolveResponse
+	var pageIndex int
+	err := db.QueryRowContext(ctx, `
+		SELECT c.manga_id, c.id, p.id, p.page_index
+		FROM page p
+		JOIN chapter c ON c.id = p.chapter_id
+		WHERE p.path = ?
+	`, media.FileRef(path)).Scan(&response.MangaID, &response.ChapterID, &response.PageID, &pageIndex)
+	if err == nil {
+		response.PageIndex = &pageIndex
+		return response, nil
+	}
+	if err != sql.ErrNoRows {
+		return resolveResponse{}, err
+	}
+
+	err = db.QueryRowContext(ctx, `
+		SELECT manga_id, id
+		FROM chapter
+		WHERE path = ?
+	`, path).Scan(&response.MangaID, &response.ChapterID)
+	if err != sql.ErrNoRows {
+		return response, err
+	}
+
+	err = db.QueryRowContext(ctx, `SELECT id FROM manga WHERE path = ?`, path).Scan(&response.MangaID)
+	return response, err
+}
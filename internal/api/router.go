@@ -1,22 +1,28 @@
 package api
 
 import (
+	"context"
 	"database/sql"
 	"embed"
 	"encoding/json"
-	"io/fs"
+	"fmt"
 	"log/slog"
 	"net/http"
+	"runtime/debug"
 	"time"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
 
+	"mynewmangaui/internal/clock"
 	"mynewmangaui/internal/config"
 	downloadsvc "mynewmangaui/internal/download"
 	imagesvc "mynewmangaui/internal/image"
+	"mynewmangaui/internal/metrics"
 	onlinesvc "mynewmangaui/internal/online"
 	scansvc "mynewmangaui/internal/scan"
+	"mynewmangaui/internal/settings"
+	"mynewmangaui/internal/store"
 )
 
 //go:embed static/*
@@ -31,46 +37,135 @@ type Dependencies struct {
 	Online      *onlinesvc.Service
 	OnlineCache *onlinesvc.CacheService
 	Downloads   *downloadsvc.Service
+	// LibraryCounts caches library totals; one tracking the scanner is
+	// created when left nil.
+	LibraryCounts *LibraryCountCache
+	// Clock supplies the current time to handlers; nil uses the system
+	// clock.
+	Clock clock.Clock
+	// Context bounds work handlers leave running in the background, such
+	// as chapter preloads; it should be cancelled when the server shuts
+	// down. nil never cancels.
+	Context context.Context
 }
 
 func NewRouter(deps Dependencies) http.Handler {
 	r := chi.NewRouter()
-	library := newLibraryHandler(deps.DB, deps.Config.Storage.Bookshelves)
-	manga := newMangaHandler(deps.DB)
-	tags := newTagHandler(deps.DB)
-	images := newImageHandler(deps.DB, deps.Images)
+	pagination := deps.Config.Server.Pagination
+	now := clock.OrSystem(deps.Clock)
+	background := deps.Context
+	if background == nil {
+		background = context.Background()
+	}
+	counts := deps.LibraryCounts
+	if counts == nil {
+		counts = newDefaultLibraryCountCache(deps.Scanner)
+	}
+	data := store.New(deps.DB, counts)
+	library := newLibraryHandler(deps.DB, deps.Config.Storage.Bookshelves, onlineDownloadsPath(deps.Config.Online), pagination.For("library"), data)
+	manga := newMangaHandler(deps.DB, data, deps.Config.Storage, pagination.For("chapters"), counts, now)
+	tags := newTagHandler(deps.DB, counts)
+	feed := newFeedHandler(deps.DB, now)
+	progress := newProgressHandler(deps.DB, data, counts, now)
+	backup := newBackupHandler(deps.DB, counts, now)
+	database := newDatabaseHandler(deps.DB, deps.Scanner)
+	collections := newCollectionHandler(deps.DB, data)
+	bookmarks := newBookmarkHandler(deps.DB, data, now)
+	settingsStore := settings.New(deps.DB)
+	maintenance := newMaintenanceHandler(settingsStore, deps.Scanner, deps.Logger)
+	preferences := newSettingsHandler(settingsStore)
+	etags := newETagger(deps.DB, deps.Config.Server.ETagStrategy)
+	images := newImageHandler(
+		background,
+		deps.DB,
+		deps.Images,
+		deps.Config.Server.InlinePageMaxBytes,
+		time.Duration(deps.Config.Server.PageCacheMaxAgeSeconds)*time.Second,
+		deps.Config.Storage.NormalizeOrientation,
+		deps.Config.Storage.ChecksumMode == scansvc.ChecksumInline || deps.Config.Storage.ChecksumMode == scansvc.ChecksumLazy,
+		newDecodeLimiter(deps.Config.Server.MaxConcurrentDecodes),
+		time.Duration(deps.Config.Server.SlowPageThresholdMs)*time.Millisecond,
+		etags,
+		deps.Logger,
+	)
 	scan := newScanHandler(deps.Scanner)
 	online := newOnlineHandler(deps.DB, deps.Online, deps.OnlineCache)
-	downloads := newDownloadHandler(deps.Downloads)
-	staticFS := mustStaticFS()
+	downloads := newDownloadHandler(deps.Downloads, pagination.For("downloads"))
+	idempotency := newIdempotencyStore(deps.DB, deps.Logger, now)
+	ui := newSPAHandler(staticFS(deps.Config.Server.StaticDir))
 	access, err := newAccessControl(deps.Config.Server)
 	if err != nil {
 		panic(err)
 	}
 
 	r.Use(middleware.RequestID)
-	r.Use(middleware.Recoverer)
+	r.Use(securityHeaders(deps.Config.Server.SecurityHeaders))
 	r.Use(middleware.Timeout(60 * time.Second))
 	r.Use(requestLogger(deps.Logger))
+	r.Use(recoverPanics(deps.Logger))
 	r.Use(access.middleware)
+	r.Use(access.adminMiddleware)
+	r.Use(maintenance.middleware)
+	r.Use(idempotency.middleware)
 
 	r.Get("/auth/login", access.loginPage)
 	r.Post("/auth/login", access.loginSubmit)
 	r.Post("/auth/logout", access.logout)
 	r.Get("/health", healthHandler)
-	r.Get("/api/bookshelves", library.getBookshelves)
-	r.Get("/api/library", library.getLibrary)
-	r.Get("/api/tags", tags.getTags)
+	r.Get("/readyz", maintenance.readyz)
+	r.Handle("/metrics", metrics.Default.Handler())
+	r.With(etags.json).Get("/api/openapi.json", getOpenAPI)
+	r.With(etags.json).Get("/api/bookshelves", library.getBookshelves)
+	r.With(etags.json).Get("/api/library", library.getLibrary)
+	r.With(etags.json).Get("/api/library/index", library.getLibraryIndex)
+	r.With(etags.json).Get("/api/favorites", library.getFavorites)
+	r.With(etags.json).Get("/api/collections", library.getCollections)
+	r.Post("/api/collections", collections.createCollection)
+	r.With(etags.json).Get("/api/collections/{collectionID}", collections.getCollection)
+	r.Put("/api/collections/{collectionID}", collections.renameCollection)
+	r.Delete("/api/collections/{collectionID}", collections.deleteCollection)
+	r.Post("/api/collections/{collectionID}/items", collections.addCollectionItem)
+	r.Put("/api/collections/{collectionID}/items", collections.reorderCollection)
+	r.Delete("/api/collections/{collectionID}/items/{mangaID}", collections.removeCollectionItem)
+	r.Get("/api/feed/updates", feed.getUpdates)
+	r.With(etags.json).Get("/api/tags", tags.getTags)
 	r.Post("/api/tags", tags.createTag)
 	r.Put("/api/tags/reorder", tags.reorderTags)
 	r.Put("/api/tags/{tagID}", tags.updateTag)
 	r.Delete("/api/tags/{tagID}", tags.deleteTag)
-	r.Get("/api/manga/{mangaID}", manga.getManga)
+	r.With(etags.json).Get("/api/manga/{mangaID}", manga.getManga)
+	r.With(access.requireAdmin).Delete("/api/manga/{mangaID}", manga.deleteManga)
+	r.Get("/api/manga/{mangaID}/siblings", library.getSiblings)
 	r.Put("/api/manga/{mangaID}/tags", tags.updateMangaTags)
-	r.Get("/api/manga/{mangaID}/chapters", manga.getChapters)
-	r.Get("/api/chapters/{chapterID}/pages", manga.getChapterPages)
+	r.Put("/api/manga/{mangaID}/favorite", manga.updateFavorite)
+	r.Put("/api/manga/{mangaID}/settings", manga.updateSettings)
+	r.Put("/api/manga/{mangaID}/reading-mode", manga.updateReadingMode)
+	r.With(etags.json).Get("/api/manga/{mangaID}/chapters", manga.getChapters)
+	r.Get("/api/manga/{mangaID}/chapters/changes", manga.getChapterChanges)
+	r.With(etags.json).Get("/api/manga/{mangaID}/volumes", manga.getVolumes)
+	r.With(etags.json).Get("/api/chapters/{chapterID}/pages", manga.getChapterPages)
+	r.Get("/api/chapters/{chapterID}/checksums", manga.getChapterChecksums)
+	r.Get("/api/chapters/{chapterID}/thumb", images.getChapterThumb)
+	r.Post("/api/chapters/{chapterID}/preload", images.preloadChapter)
+	r.With(etags.json).Get("/api/chapters/{chapterID}/window", manga.getChapterWindow)
+	r.Put("/api/chapters/{chapterID}/volume", manga.updateChapterVolume)
+	r.Put("/api/chapters/{chapterID}/progress", progress.updateChapterProgress)
+	r.With(etags.json).Get("/api/manga/{mangaID}/progress", progress.getMangaProgress)
+	r.Post("/api/manga/{mangaID}/catch-up", progress.catchUp)
+	r.With(etags.json).Get("/api/manga/{mangaID}/reading-stats", progress.getMangaReadingStats)
+	r.Get("/api/up-next", progress.getUpNext)
+	r.Post("/api/chapters/{chapterID}/bookmarks", bookmarks.createBookmark)
+	r.With(etags.json).Get("/api/manga/{mangaID}/bookmarks", bookmarks.getMangaBookmarks)
+	r.Delete("/api/bookmarks/{bookmarkID}", bookmarks.deleteBookmark)
+	r.Put("/api/chapters/{chapterID}/page-order", manga.updatePageOrder)
+	r.Post("/api/resolve", manga.resolvePath)
+	r.Get("/api/settings", preferences.getSettings)
+	r.Put("/api/settings", preferences.updateSettings)
 	r.Get("/api/images/covers/{mangaID}/thumb", images.getCoverThumb)
 	r.Get("/api/images/chapters/{chapterID}/pages/{pageIndex}", images.getChapterPage)
+	r.Get("/api/images/chapters/{chapterID}/pages/{pageIndex}/thumb", images.getPageThumb)
+	r.Get("/api/manga/{mangaID}/pages/{globalIndex}", images.getMangaPage)
+	r.Get("/api/chapters/{chapterID}/pages/{pageIndex}/data", images.getChapterPageData)
 	r.Get("/api/online/sources", online.listSources)
 	r.Get("/api/online/settings", online.listSettings)
 	r.Get("/api/online/{sourceID}/default", online.defaultFeed)
@@ -96,27 +191,39 @@ func NewRouter(deps Dependencies) http.Handler {
 	r.Delete("/api/online/downloads/{jobID}/files", downloads.deleteJobAndFiles)
 	r.Get("/api/tasks/scan/status", scan.getScanStatus)
 	r.Post("/api/tasks/scan", scan.triggerScan)
+	r.Post("/api/scan", scan.scanRoot)
+	r.Get("/api/scan/{jobID}/log", scan.getScanLog)
 	r.Post("/api/tasks/scan/bookshelf/{bookshelfID}", scan.triggerBookshelfScan)
 	r.Post("/api/tasks/scan/manga/{mangaID}", scan.triggerMangaScan)
 	r.Post("/api/tasks/scan/tag/{tagID}", scan.triggerTagScan)
-	r.Handle("/*", noStoreStatic(http.FileServer(http.FS(staticFS))))
+	r.With(access.requireAdmin).Get("/api/admin/export", backup.exportLibrary)
+	r.With(access.requireAdmin).Get("/api/admin/parse-preview", scan.parsePreview)
+	r.With(access.requireAdmin).Get("/api/admin/duplicates", manga.getDuplicates)
+	r.With(access.requireAdmin).Get("/api/admin/consistency", manga.getConsistency)
+	r.With(access.requireAdmin).Get("/api/admin/stats/db", database.getStats)
+	r.With(access.requireAdmin).Post("/api/admin/vacuum", database.vacuum)
+	r.With(access.requireAdmin).Post("/api/admin/import", backup.importLibrary)
+	r.With(access.requireAdmin).Get("/api/admin/progress/export", backup.exportProgress)
+	r.With(access.requireAdmin).Post("/api/admin/progress/import", backup.importProgressBackup)
+	r.With(access.requireAdmin).Get("/api/admin/maintenance", maintenance.getMaintenance)
+	r.With(access.requireAdmin).Post("/api/admin/maintenance", maintenance.updateMaintenance)
+	r.Handle("/*", ui)
 
 	return r
 }
 
-func noStoreStatic(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Set("Cache-Control", "no-store")
-		next.ServeHTTP(w, r)
-	})
+func newDefaultLibraryCountCache(scanner *scansvc.Service) *LibraryCountCache {
+	if scanner == nil {
+		return NewLibraryCountCache()
+	}
+	return NewLibraryCountCache(scanner.Version)
 }
 
-func mustStaticFS() fs.FS {
-	sub, err := fs.Sub(staticFiles, "static")
-	if err != nil {
-		panic(err)
+func onlineDownloadsPath(cfg config.OnlineConfig) string {
+	if !cfg.Enabled {
+		return ""
 	}
-	return sub
+	return cfg.DownloadsPath
 }
 
 func healthHandler(w http.ResponseWriter, r *http.Request) {
@@ -143,6 +250,39 @@ func requestLogger(logger *slog.Logger) func(http.Handler) http.Handler {
 	}
 }
 
+// recoverPanics turns a panicking handler into a JSON 500 and logs the
+// panic with its stack and request id. It sits inside requestLogger so the
+// request is still logged, with its 500. When the handler had already
+// started its response only the log is written. http.ErrAbortHandler is
+// passed on, since it asks net/http to drop the connection.
+func recoverPanics(logger *slog.Logger) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+			defer func() {
+				recovered := recover()
+				if recovered == nil {
+					return
+				}
+				if recovered == http.ErrAbortHandler {
+					panic(recovered)
+				}
+				logger.Error("handler panicked",
+					"request_id", middleware.GetReqID(r.Context()),
+					"method", r.Method,
+					"path", r.URL.Path,
+					"panic", fmt.Sprint(recovered),
+					"stack", string(debug.Stack()),
+				)
+				if ww.Status() == 0 {
+					writeError(ww, http.StatusInternalServerError, "internal server error")
+				}
+			}()
+			next.ServeHTTP(ww, r)
+		})
+	}
+}
+
 func writeJSON(w http.ResponseWriter, status int, payload any) {
 	w.Header().Set("Content-Type", "application/json; charset=utf-8")
 	w.WriteHeader(status)
@@ -1,8 +1,11 @@
 package api
 
 import (
-	"context"
+	"encoding/json"
+	"errors"
+	"io"
 	"net/http"
+	"strings"
 
 	"github.com/go-chi/chi/v5"
 
@@ -17,28 +20,74 @@ func newScanHandler(scanner *scansvc.Service) *scanHandler {
 	return &scanHandler{scanner: scanner}
 }
 
+// triggerScan queues a full library scan. A library scan still waiting in
+// the queue absorbs the request, and its job id is returned instead.
 func (h *scanHandler) triggerScan(w http.ResponseWriter, r *http.Request) {
 	if h.scanner == nil {
 		writeError(w, http.StatusInternalServerError, "scan service not initialized")
 		return
 	}
-	if h.scanner.Status().Running {
-		writeJSON(w, http.StatusAccepted, map[string]any{
-			"status": "running",
-			"scan":   h.scanner.Status(),
-		})
+	queued, coalesced, err := h.scanner.QueueScan()
+	h.writeQueued(w, queued, coalesced, err)
+}
+
+// writeQueued answers a request that queued a scan with its job id and the
+// current queue depth.
+func (h *scanHandler) writeQueued(w http.ResponseWriter, queued scansvc.QueuedScan, coalesced bool, err error) {
+	switch {
+	case errors.Is(err, scansvc.ErrQueueFull):
+		writeError(w, http.StatusTooManyRequests, "scan queue is full")
+		return
+	case errors.Is(err, scansvc.ErrStopped):
+		writeError(w, http.StatusServiceUnavailable, "scanner is shutting down")
+		return
+	case err != nil:
+		writeError(w, http.StatusInternalServerError, "queue scan failed")
 		return
 	}
 
-	go func() {
-		_, _ = h.scanner.Scan(context.Background())
-	}()
-
 	writeJSON(w, http.StatusAccepted, map[string]any{
-		"status": "accepted",
+		"status":     "queued",
+		"jobId":      queued.RunID,
+		"scope":      queued.Scope,
+		"coalesced":  coalesced,
+		"queueDepth": h.scanner.Status().QueueDepth,
 	})
 }
 
+type rootScanRequest struct {
+	Root string `json:"root"`
+}
+
+// scanRoot queues a scan of one configured bookshelf, given by name or
+// path, which also covers roots skipped by the startup scan. Without a root
+// it queues a full library scan like triggerScan.
+func (h *scanHandler) scanRoot(w http.ResponseWriter, r *http.Request) {
+	if h.scanner == nil {
+		writeError(w, http.StatusInternalServerError, "scan service not initialized")
+		return
+	}
+
+	var request rootScanRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil && !errors.Is(err, io.EOF) {
+		writeError(w, http.StatusBadRequest, "invalid scan payload")
+		return
+	}
+	root := strings.TrimSpace(request.Root)
+	if root == "" {
+		h.triggerScan(w, r)
+		return
+	}
+
+	shelf, ok := h.scanner.FindRoot(root)
+	if !ok {
+		writeError(w, http.StatusNotFound, "bookshelf root not found")
+		return
+	}
+	queued, coalesced, err := h.scanner.QueueRoot(shelf.Path)
+	h.writeQueued(w, queued, coalesced, err)
+}
+
 func (h *scanHandler) getScanStatus(w http.ResponseWriter, r *http.Request) {
 	if h.scanner == nil {
 		writeError(w, http.StatusInternalServerError, "scan service not initialized")
@@ -51,6 +100,8 @@ func (h *scanHandler) getScanStatus(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
+// triggerMangaScan rescans one manga and returns its summary, or queues the
+// rescan when another scan is running.
 func (h *scanHandler) triggerMangaScan(w http.ResponseWriter, r *http.Request) {
 	if h.scanner == nil {
 		writeError(w, http.StatusInternalServerError, "scan service not initialized")
@@ -58,7 +109,13 @@ func (h *scanHandler) triggerMangaScan(w http.ResponseWriter, r *http.Request) {
 	}
 
 	mangaID := chi.URLParam(r, "mangaID")
-	summary, err := h.scanner.ScanManga(r.Context(), mangaID)
+	runID := scansvc.NewRunID()
+	summary, err := h.scanner.ScanManga(scansvc.WithRunID(r.Context(), runID), mangaID)
+	if errors.Is(err, scansvc.ErrScanRunning) {
+		queued, coalesced, err := h.scanner.QueueManga(mangaID)
+		h.writeQueued(w, queued, coalesced, err)
+		return
+	}
 	if err != nil {
 		writeError(w, http.StatusInternalServerError, "manga scan failed")
 		return
@@ -66,6 +123,7 @@ func (h *scanHandler) triggerMangaScan(w http.ResponseWriter, r *http.Request) {
 
 	writeJSON(w, http.StatusOK, map[string]any{
 		"status":  "ok",
+		"jobId":   runID,
 		"summary": summary,
 	})
 }
@@ -77,7 +135,8 @@ func (h *scanHandler) triggerBookshelfScan(w http.ResponseWriter, r *http.Reques
 	}
 
 	bookshelfID := chi.URLParam(r, "bookshelfID")
-	summary, err := h.scanner.ScanBookshelf(r.Context(), bookshelfID)
+	runID := scansvc.NewRunID()
+	summary, err := h.scanner.ScanBookshelf(scansvc.WithRunID(r.Context(), runID), bookshelfID)
 	if err != nil {
 		writeError(w, http.StatusInternalServerError, "bookshelf scan failed")
 		return
@@ -85,6 +144,7 @@ func (h *scanHandler) triggerBookshelfScan(w http.ResponseWriter, r *http.Reques
 
 	writeJSON(w, http.StatusOK, map[string]any{
 		"status":  "ok",
+		"jobId":   runID,
 		"summary": summary,
 	})
 }
@@ -96,7 +156,8 @@ func (h *scanHandler) triggerTagScan(w http.ResponseWriter, r *http.Request) {
 	}
 
 	tagID := chi.URLParam(r, "tagID")
-	summary, err := h.scanner.ScanTag(r.Context(), tagID)
+	runID := scansvc.NewRunID()
+	summary, err := h.scanner.ScanTag(scansvc.WithRunID(r.Context(), runID), tagID)
 	if err != nil {
 		writeError(w, http.StatusInternalServerError, "tag scan failed")
 		return
@@ -104,6 +165,45 @@ func (h *scanHandler) triggerTagScan(w http.ResponseWriter, r *http.Request) {
 
 	writeJSON(w, http.StatusOK, map[string]any{
 		"status":  "ok",
+		"jobId":   runID,
 		"summary": summary,
 	})
 }
+
+// getScanLog returns the per-manga log of a recent scan run, identified by
+// the job id its trigger returned. Only the last few runs are kept, and
+// manga paths are left out for callers without the admin token.
+func (h *scanHandler) getScanLog(w http.ResponseWriter, r *http.Request) {
+	if h.scanner == nil {
+		writeError(w, http.StatusInternalServerError, "scan service not initialized")
+		return
+	}
+
+	log, ok := h.scanner.RunLog(chi.URLParam(r, "jobID"))
+	if !ok {
+		writeError(w, http.StatusNotFound, "scan log not found")
+		return
+	}
+	if !isAdminRequest(r) {
+		for index := range log.Entries {
+			log.Entries[index].Path = ""
+		}
+	}
+	writeJSON(w, http.StatusOK, log)
+}
+
+// parsePreview shows how the scanner reads a chapter name, to explain why
+// chapters end up numbered or sorted the way they are.
+func (h *scanHandler) parsePreview(w http.ResponseWriter, r *http.Request) {
+	if h.scanner == nil {
+		writeError(w, http.StatusInternalServerError, "scan service not initialized")
+		return
+	}
+
+	name := strings.TrimSpace(r.URL.Query().Get("name"))
+	if name == "" {
+		writeError(w, http.StatusBadRequest, "name is required")
+		return
+	}
+	writeJSON(w, http.StatusOK, h.scanner.PreviewLabel(name, strings.TrimSpace(r.URL.Query().Get("manga"))))
+}
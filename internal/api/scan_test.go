@@ -0,0 +1,190 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"reflect"
+	"slices"
+	"testing"
+	"time"
+
+	scansvc "mynewmangaui/internal/scan"
+)
+
+func TestScanLogHidesPathsFromNonAdmin(t *testing.T) {
+	server := newTestServer(t, `{"server":{"adminToken":"secret"}}`)
+	writeChapter(t, server.root, "Alpha", "Chapter 1", 2)
+
+	runID := scansvc.NewRunID()
+	if _, err := server.scanner.Scan(scansvc.WithRunID(context.Background(), runID)); err != nil {
+		t.Fatalf("scan: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		header   []string
+		wantPath bool
+	}{
+		{name: "anonymous", wantPath: false},
+		{name: "admin", header: []string{"X-Admin-Token", "secret"}, wantPath: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := server.do(http.MethodGet, "/api/scan/"+runID+"/log", "", tt.header...)
+			if rec.Code != http.StatusOK {
+				t.Fatalf("status = %d, body %s", rec.Code, rec.Body.String())
+			}
+			log := decodeJSON[scansvc.RunLog](t, rec)
+			if len(log.Entries) == 0 {
+				t.Fatal("log has no entries")
+			}
+			for _, entry := range log.Entries {
+				if (entry.Path != "") != tt.wantPath {
+					t.Errorf("entry %s path = %q, want path shown %v", entry.MangaID, entry.Path, tt.wantPath)
+				}
+			}
+		})
+	}
+}
+
+func TestScanLogUnknownRun(t *testing.T) {
+	server := newTestServer(t, "")
+	if rec := server.do(http.MethodGet, "/api/scan/missing/log", ""); rec.Code != http.StatusNotFound {
+		t.Fatalf("status = %d, want 404", rec.Code)
+	}
+}
+
+func TestParsePreview(t *testing.T) {
+	server := newTestServer(t, `{"server":{"adminToken":"secret"}}`)
+	number := func(value float64) *float64 { return &value }
+	volume := func(value int) *int { return &value }
+
+	tests := []struct {
+		name     string
+		title    string
+		number   *float64
+		volume   *int
+		rejected []float64
+		sortKey  []string
+	}{
+		{name: "Chapter 12", title: "Chapter 12", number: number(12), sortKey: []string{"chapter ", "12"}},
+		{name: "Vol 3 Chapter 0010", title: "Vol 3 Chapter 0010", number: number(10), volume: volume(3), sortKey: []string{"vol ", "3", " chapter ", "0010"}},
+		{name: "第5话", title: "第5话", number: number(5), sortKey: []string{"第", "5", "话"}},
+		{name: "Berserk 372", title: "372", number: number(372), sortKey: []string{"berserk ", "372"}},
+		{name: "Chapter 2 (2019)", title: "Chapter 2 (2019)", number: number(2), sortKey: []string{"chapter ", "2", " (", "2019", ")"}},
+		{name: "Chapter 99999999", title: "Chapter 99999999", rejected: []float64{99999999}, sortKey: []string{"chapter ", "99999999"}},
+		{name: "Extra", title: "Extra", sortKey: []string{"extra"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := server.do(http.MethodGet, "/api/admin/parse-preview?manga=Berserk&name="+url.QueryEscape(tt.name), "", "X-Admin-Token", "secret")
+			if rec.Code != http.StatusOK {
+				t.Fatalf("status = %d, body %s", rec.Code, rec.Body.String())
+			}
+			preview := decodeJSON[scansvc.LabelPreview](t, rec)
+			if preview.Title != tt.title || !reflect.DeepEqual(preview.Number, tt.number) || !reflect.DeepEqual(preview.Volume, tt.volume) {
+				t.Errorf("preview = %q, number %v, volume %v; want %q, %v, %v", preview.Title, deref(preview.Number), deref(preview.Volume), tt.title, deref(tt.number), deref(tt.volume))
+			}
+			if !slices.Equal(preview.Rejected, tt.rejected) {
+				t.Errorf("rejected numbers = %v, want %v", preview.Rejected, tt.rejected)
+			}
+			if !slices.Equal(preview.SortKey, tt.sortKey) {
+				t.Errorf("sort key = %q, want %q", preview.SortKey, tt.sortKey)
+			}
+		})
+	}
+
+	if rec := server.do(http.MethodGet, "/api/admin/parse-preview", "", "X-Admin-Token", "secret"); rec.Code != http.StatusBadRequest {
+		t.Errorf("status without a name = %d, want 400", rec.Code)
+	}
+	if rec := server.do(http.MethodGet, "/api/admin/parse-preview?name=Chapter+1", ""); rec.Code != http.StatusForbidden {
+		t.Errorf("status without the admin token = %d, want 403", rec.Code)
+	}
+}
+
+// deref formats an optional value for test messages.
+func deref[T any](value *T) any {
+	if value == nil {
+		return nil
+	}
+	return *value
+}
+
+func TestOverlappingScanRequestsQueue(t *testing.T) {
+	server := newTestServer(t, "")
+	writeChapter(t, server.root, "Alpha", "Chapter 1", 1)
+	server.scan()
+	mangaID := server.queryString(`SELECT id FROM manga`)
+
+	// Hold the scanner as a running scan would.
+	held := make(chan struct{})
+	release := make(chan struct{})
+	done := make(chan error, 1)
+	go func() {
+		done <- server.scanner.RunExclusive(func() error {
+			close(held)
+			<-release
+			return nil
+		})
+	}()
+	<-held
+
+	type queuedResponse struct {
+		Status     string `json:"status"`
+		JobID      string `json:"jobId"`
+		Scope      string `json:"scope"`
+		Coalesced  bool   `json:"coalesced"`
+		QueueDepth int    `json:"queueDepth"`
+	}
+	queue := func(target string, body string) queuedResponse {
+		t.Helper()
+		rec := server.do(http.MethodPost, target, body)
+		if rec.Code != http.StatusAccepted {
+			t.Fatalf("%s: status = %d, body %s", target, rec.Code, rec.Body.String())
+		}
+		return decodeJSON[queuedResponse](t, rec)
+	}
+
+	first := queue("/api/tasks/scan", "")
+	if first.Status != "queued" || first.Coalesced || first.QueueDepth != 1 {
+		t.Fatalf("first scan = %+v, want a new queued job", first)
+	}
+	for _, request := range []struct{ target, body string }{
+		{target: "/api/tasks/scan"},
+		{target: "/api/scan", body: `{}`},
+	} {
+		if again := queue(request.target, request.body); !again.Coalesced || again.JobID != first.JobID || again.QueueDepth != 1 {
+			t.Fatalf("%s = %+v, want it absorbed by job %s", request.target, again, first.JobID)
+		}
+	}
+	manga := queue("/api/tasks/scan/manga/"+mangaID, "")
+	if manga.Scope != scansvc.QueueScopeManga || manga.Coalesced || manga.QueueDepth != 2 {
+		t.Fatalf("manga rescan = %+v, want a second queued job", manga)
+	}
+
+	rec := server.do(http.MethodGet, "/api/tasks/scan/status", "")
+	status := decodeJSON[struct {
+		Scan scansvc.Status `json:"scan"`
+	}](t, rec).Scan
+	if status.QueueDepth != 2 || len(status.Queue) != 2 || status.Queue[0].RunID != first.JobID || status.Queue[1].RunID != manga.JobID {
+		t.Fatalf("status queue = %+v (depth %d), want the library then the manga job", status.Queue, status.QueueDepth)
+	}
+
+	close(release)
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Now().Add(10 * time.Second)
+	for _, jobID := range []string{first.JobID, manga.JobID} {
+		for {
+			if run, ok := server.scanner.RunLog(jobID); ok && !run.Running {
+				break
+			}
+			if time.Now().After(deadline) {
+				t.Fatalf("job %s did not run", jobID)
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+}
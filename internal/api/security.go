@@ -1,6 +1,7 @@
 package api
 
 import (
+	"context"
 	"crypto/subtle"
 	"fmt"
 	"html"
@@ -13,9 +14,12 @@ import (
 
 const accessCookieName = "manga_access_token"
 
+type adminContextKey struct{}
+
 type accessControl struct {
 	allowPrivateNetworks bool
 	publicAccessToken    string
+	adminToken           string
 	trustedProxyNets     []*net.IPNet
 }
 
@@ -23,6 +27,7 @@ func newAccessControl(cfg config.ServerConfig) (*accessControl, error) {
 	ac := &accessControl{
 		allowPrivateNetworks: cfg.AllowPrivateNetworks,
 		publicAccessToken:    strings.TrimSpace(cfg.PublicAccessToken),
+		adminToken:           strings.TrimSpace(cfg.AdminToken),
 	}
 
 	for _, raw := range cfg.TrustedProxyCIDRs {
@@ -87,6 +92,63 @@ func (ac *accessControl) middleware(next http.Handler) http.Handler {
 	})
 }
 
+// adminMiddleware marks requests carrying the configured admin token so
+// handlers can include details that are hidden from regular clients.
+func (ac *accessControl) adminMiddleware(next http.Handler) http.Handler {
+	if ac == nil || ac.adminToken == "" {
+		return next
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if ac.isAdmin(r) {
+			r = r.WithContext(context.WithValue(r.Context(), adminContextKey{}, true))
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// requireAdmin rejects requests without the admin token. When no admin
+// token is configured every request is treated as trusted, matching how the
+// rest of the server behaves without one.
+func (ac *accessControl) requireAdmin(next http.Handler) http.Handler {
+	if ac == nil || ac.adminToken == "" {
+		return next
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !isAdminRequest(r) {
+			writeError(w, http.StatusForbidden, "admin token required")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (ac *accessControl) isAdmin(r *http.Request) bool {
+	if token := strings.TrimSpace(r.Header.Get("X-Admin-Token")); token != "" {
+		return ac.matchAdminToken(token)
+	}
+
+	authHeader := strings.TrimSpace(r.Header.Get("Authorization"))
+	if strings.HasPrefix(strings.ToLower(authHeader), "bearer ") {
+		return ac.matchAdminToken(strings.TrimSpace(authHeader[7:]))
+	}
+
+	return false
+}
+
+func (ac *accessControl) matchAdminToken(token string) bool {
+	if ac.adminToken == "" {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(token), []byte(ac.adminToken)) == 1
+}
+
+func isAdminRequest(r *http.Request) bool {
+	admin, _ := r.Context().Value(adminContextKey{}).(bool)
+	return admin
+}
+
 func (ac *accessControl) isAuthRoute(path string) bool {
 	return path == "/auth/login" || path == "/auth/logout"
 }
@@ -105,7 +167,7 @@ func (ac *accessControl) authorized(r *http.Request) bool {
 		return ac.matchToken(strings.TrimSpace(authHeader[7:]))
 	}
 
-	return false
+	return ac.isAdmin(r)
 }
 
 func (ac *accessControl) matchToken(token string) bool {
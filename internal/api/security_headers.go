@@ -0,0 +1,37 @@
+package api
+
+import (
+	"net/http"
+
+	"mynewmangaui/internal/config"
+)
+
+// securityHeaders adds the configured security headers to every response,
+// leaving out those configured empty.
+func securityHeaders(cfg config.SecurityHeadersConfig) func(http.Handler) http.Handler {
+	headers := make(map[string]string, 4)
+	if cfg.Enabled {
+		for name, value := range map[string]string{
+			"X-Content-Type-Options":  cfg.ContentTypeOptions,
+			"X-Frame-Options":         cfg.FrameOptions,
+			"Content-Security-Policy": cfg.ContentSecurityPolicy,
+			"Referrer-Policy":         cfg.ReferrerPolicy,
+		} {
+			if value != "" {
+				headers[name] = value
+			}
+		}
+	}
+
+	return func(next http.Handler) http.Handler {
+		if len(headers) == 0 {
+			return next
+		}
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			for name, value := range headers {
+				w.Header().Set(name, value)
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,53 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"mynewmangaui/internal/settings"
+)
+
+// settingsResponse holds every public setting by name, with defaults for
+// the ones never set.
+type settingsResponse struct {
+	Settings map[string]json.RawMessage `json:"settings"`
+}
+
+type settingsHandler struct {
+	settings *settings.Store
+}
+
+func newSettingsHandler(store *settings.Store) *settingsHandler {
+	return &settingsHandler{settings: store}
+}
+
+func (h *settingsHandler) getSettings(w http.ResponseWriter, r *http.Request) {
+	values, err := h.settings.PublicValues(r.Context())
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to load settings")
+		return
+	}
+	writeJSON(w, http.StatusOK, settingsResponse{Settings: values})
+}
+
+// updateSettings changes the settings named in the body, a JSON object of
+// setting values, all or nothing. A null value resets a setting to its
+// default.
+func (h *settingsHandler) updateSettings(w http.ResponseWriter, r *http.Request) {
+	var request map[string]json.RawMessage
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil || len(request) == 0 {
+		writeError(w, http.StatusBadRequest, "invalid settings payload")
+		return
+	}
+
+	var invalid *settings.ValidationError
+	if err := h.settings.UpdatePublic(r.Context(), request); errors.As(err, &invalid) {
+		writeError(w, http.StatusBadRequest, invalid.Error())
+		return
+	} else if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to save settings")
+		return
+	}
+	h.getSettings(w, r)
+}
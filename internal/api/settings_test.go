@@ -0,0 +1,63 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"mynewmangaui/internal/settings"
+)
+
+func TestSettings(t *testing.T) {
+	server := newTestServer(t, "")
+	assertSettings := func(rec *httptest.ResponseRecorder, want string) {
+		t.Helper()
+		if rec.Code != http.StatusOK {
+			t.Fatalf("settings status = %d, body %s", rec.Code, rec.Body.String())
+		}
+		if got := strings.TrimSpace(rec.Body.String()); got != want {
+			t.Fatalf("settings = %s, want %s", got, want)
+		}
+	}
+
+	// Settings never set read as their defaults.
+	assertSettings(server.do(http.MethodGet, "/api/settings", ""), `{"settings":{"defaultReadingMode":"","scanIntervalMinutes":0}}`)
+
+	rec := server.do(http.MethodPut, "/api/settings", `{"defaultReadingMode":"continuous","scanIntervalMinutes":30}`)
+	assertSettings(rec, `{"settings":{"defaultReadingMode":"continuous","scanIntervalMinutes":30}}`)
+	assertSettings(server.do(http.MethodGet, "/api/settings", ""), `{"settings":{"defaultReadingMode":"continuous","scanIntervalMinutes":30}}`)
+	store := settings.New(server.db)
+	if mode, err := settings.DefaultReadingMode.Get(context.Background(), store); err != nil || mode != "continuous" {
+		t.Fatalf("typed reading mode = %q, %v", mode, err)
+	}
+	if minutes, err := settings.ScanIntervalMinutes.Get(context.Background(), store); err != nil || minutes != 30 {
+		t.Fatalf("typed scan interval = %d, %v", minutes, err)
+	}
+
+	// A rejected update changes nothing, even the keys that were valid.
+	for body, want := range map[string]string{
+		`{"defaultReadingMode":"sideways"}`:                           `setting \"defaultReadingMode\": must be empty, paged, continuous or double`,
+		`{"defaultReadingMode":3}`:                                    `setting \"defaultReadingMode\": must be a string`,
+		`{"scanIntervalMinutes":-1}`:                                  `setting \"scanIntervalMinutes\": must be between 0 and 10080`,
+		`{"scanIntervalMinutes":"soon"}`:                              `setting \"scanIntervalMinutes\": must be a number`,
+		`{"scanIntervalMinutes":60,"maintenance":false}`:              `setting \"maintenance\": unknown setting`,
+		`{"defaultReadingMode":"paged","scanIntervalMinutes":100000}`: `must be between 0 and 10080`,
+		`{}`:       "invalid settings payload",
+		`not json`: "invalid settings payload",
+	} {
+		rec := server.do(http.MethodPut, "/api/settings", body)
+		if rec.Code != http.StatusBadRequest || !strings.Contains(rec.Body.String(), want) {
+			t.Errorf("update with %s = %d %s, want 400 %s", body, rec.Code, rec.Body.String(), want)
+		}
+	}
+	assertSettings(server.do(http.MethodGet, "/api/settings", ""), `{"settings":{"defaultReadingMode":"continuous","scanIntervalMinutes":30}}`)
+
+	// Null resets a setting to its default.
+	rec = server.do(http.MethodPut, "/api/settings", `{"defaultReadingMode":null}`)
+	assertSettings(rec, `{"settings":{"defaultReadingMode":"","scanIntervalMinutes":30}}`)
+	if got := server.queryString(`SELECT COUNT(*) FROM settings WHERE key = 'defaultReadingMode'`); got != "0" {
+		t.Errorf("reset setting rows = %s, want it removed", got)
+	}
+}
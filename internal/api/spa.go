@@ -0,0 +1,80 @@
+package api
+
+import (
+	"io/fs"
+	"net/http"
+	"os"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// hashedAssetPattern matches build output names carrying a content hash,
+// such as app.3f9a2c1b.js or index-BXk3Lq9a.css. The hash has to contain a
+// digit so plain names like app-settings.js are not taken for hashed ones.
+var hashedAssetPattern = regexp.MustCompile(`[.-]([A-Za-z0-9_]{8,64})\.[A-Za-z0-9]+$`)
+
+// spaHandler serves the web UI. Paths without a file extension that match
+// no file get index.html so client-side routes survive a reload, while
+// unknown API paths and missing assets stay 404s.
+type spaHandler struct {
+	files      fs.FS
+	fileServer http.Handler
+}
+
+func newSPAHandler(files fs.FS) *spaHandler {
+	return &spaHandler{files: files, fileServer: http.FileServer(http.FS(files))}
+}
+
+func (h *spaHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path == "/api" || strings.HasPrefix(r.URL.Path, "/api/") {
+		writeError(w, http.StatusNotFound, "not found")
+		return
+	}
+
+	name := strings.TrimPrefix(path.Clean("/"+r.URL.Path), "/")
+	if name == "" {
+		name = "index.html"
+	}
+	if info, err := fs.Stat(h.files, name); err == nil && !info.IsDir() {
+		setStaticCacheHeaders(w, name)
+		h.fileServer.ServeHTTP(w, r)
+		return
+	}
+	if path.Ext(name) != "" || (r.Method != http.MethodGet && r.Method != http.MethodHead) {
+		http.NotFound(w, r)
+		return
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	http.ServeFileFS(w, r, h.files, "index.html")
+}
+
+// setStaticCacheHeaders lets browsers keep hashed assets for good, since a
+// new build changes their names; everything else is fetched fresh so a
+// deploy shows up on the next load.
+func setStaticCacheHeaders(w http.ResponseWriter, name string) {
+	if isHashedAsset(path.Base(name)) {
+		w.Header().Set("Cache-Control", "public, max-age=31536000, immutable")
+		return
+	}
+	w.Header().Set("Cache-Control", "no-store")
+}
+
+func isHashedAsset(name string) bool {
+	match := hashedAssetPattern.FindStringSubmatch(name)
+	return match != nil && strings.ContainsAny(match[1], "0123456789")
+}
+
+// staticFS returns the UI files to serve: dir when configured, otherwise
+// the copy embedded in the binary.
+func staticFS(dir string) fs.FS {
+	if strings.TrimSpace(dir) != "" {
+		return os.DirFS(dir)
+	}
+	sub, err := fs.Sub(staticFiles, "static")
+	if err != nil {
+		panic(err)
+	}
+	return sub
+}
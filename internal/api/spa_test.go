@@ -0,0 +1,73 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSPAFallback(t *testing.T) {
+	static := t.TempDir()
+	files := map[string]string{
+		"index.html":                 "<html>ui</html>",
+		"app.3f9a2c1b.js":            "hashed",
+		"app-settings.js":            "plain",
+		"assets/logo.1a2b3c4d5e.svg": "<svg/>",
+	}
+	for name, content := range files {
+		target := filepath.Join(static, name)
+		if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(target, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	document, err := json.Marshal(map[string]any{"server": map[string]any{"staticDir": static}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := newTestServer(t, string(document))
+
+	tests := []struct {
+		path         string
+		status       int
+		body         string
+		cacheControl string
+		json         bool
+	}{
+		{path: "/", status: http.StatusOK, body: "<html>ui</html>", cacheControl: "no-store"},
+		{path: "/manga/abc/chapters", status: http.StatusOK, body: "<html>ui</html>", cacheControl: "no-store"},
+		{path: "/app.3f9a2c1b.js", status: http.StatusOK, body: "hashed", cacheControl: "public, max-age=31536000, immutable"},
+		{path: "/assets/logo.1a2b3c4d5e.svg", status: http.StatusOK, body: "<svg/>", cacheControl: "public, max-age=31536000, immutable"},
+		{path: "/app-settings.js", status: http.StatusOK, body: "plain", cacheControl: "no-store"},
+		{path: "/missing.js", status: http.StatusNotFound},
+		{path: "/api/unknown", status: http.StatusNotFound, json: true},
+		{path: "/api/manga/abc/unknown", status: http.StatusNotFound, json: true},
+		{path: "/health", status: http.StatusOK},
+		{path: "/metrics", status: http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			rec := server.do(http.MethodGet, tt.path, "")
+			if rec.Code != tt.status {
+				t.Fatalf("status = %d, want %d", rec.Code, tt.status)
+			}
+			if tt.body != "" && rec.Body.String() != tt.body {
+				t.Errorf("body = %q, want %q", rec.Body.String(), tt.body)
+			}
+			if tt.cacheControl != "" && rec.Header().Get("Cache-Control") != tt.cacheControl {
+				t.Errorf("Cache-Control = %q, want %q", rec.Header().Get("Cache-Control"), tt.cacheControl)
+			}
+			if tt.json && !strings.HasPrefix(rec.Header().Get("Content-Type"), "application/json") {
+				t.Errorf("Content-Type = %q, want JSON", rec.Header().Get("Content-Type"))
+			}
+			if tt.status == http.StatusOK && tt.body == "" && strings.Contains(rec.Body.String(), "<html>ui</html>") {
+				t.Errorf("%s served the UI", tt.path)
+			}
+		})
+	}
+}
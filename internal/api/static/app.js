@@ -2687,8 +2687,7 @@ function renderLibraryView() {
   refreshButton.dataset.mode = "refresh";
 
   const currentShelf = getCurrentBookshelfMeta();
-  const currentShelfRoot = String(currentShelf?.rootPath || "").replaceAll("\\", "/");
-  const isOnlineDownloadShelf = currentShelfRoot.includes("data/downloads");
+  const isOnlineDownloadShelf = Boolean(currentShelf?.online);
   const items = state.library?.items ?? [];
   const sortedItems = getSortedLibraryItems(items);
   const filteredItems = getFilteredLibraryItems(sortedItems);
@@ -2837,7 +2836,7 @@ function renderLibraryView() {
                         <h3>${escapeHTML(item.title)}</h3>
                         <p>${formatUpdatedAt(item.updatedAt)}</p>
                         <div class="manga-metrics">
-                          <span>${item.chapterCount} 话</span>
+                          <span>${item.chapterCount ? `${item.chapterCount} 话` : "暂无章节"}</span>
                           <span>${item.pageCount} 页</span>
                         </div>
                       </div>
@@ -2889,7 +2888,9 @@ function renderMangaView(manga, chapters) {
   showFeedback(
     isOnline
       ? `已加载 ${onlineSourceName} 的章节目录，共 ${manga.chapterCount} 话，可以直接在线阅读或加入下载。`
-      : `当前漫画共 ${manga.chapterCount} 话，选择一话进入阅读页。`,
+      : manga.chapterCount
+        ? `当前漫画共 ${manga.chapterCount} 话，选择一话进入阅读页。`
+        : "这部漫画还没有章节，放入章节后重新扫描即可阅读。",
   );
   const ordered = getOrderedChapters(manga.id, chapters);
   const firstChapter = ordered.items[0];
@@ -3044,7 +3045,7 @@ function renderMangaView(manga, chapters) {
                         isOnline
                           ? (chapter.updatedAt ? formatUpdatedAt(chapter.updatedAt) : `章节 ID ${chapter.id}`)
                           : formatUpdatedAt(chapter.updatedAt),
-                      )}</p>
+                      )}${chapter.possiblyIncomplete ? " · 可能尚未下载完成" : ""}</p>
                     </div>
                   </div>
                   <span class="chapter-row__meta">${chapter.pageCount ? `${chapter.pageCount} 页` : (isOnline ? "在线读取" : "0 页")}</span>
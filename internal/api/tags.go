@@ -12,6 +12,8 @@ import (
 	"strings"
 
 	"github.com/go-chi/chi/v5"
+
+	"mynewmangaui/internal/store"
 )
 
 type tagItem struct {
@@ -46,11 +48,12 @@ type tagReorderRequest struct {
 }
 
 type tagHandler struct {
-	db *sql.DB
+	db     *sql.DB
+	counts *LibraryCountCache
 }
 
-func newTagHandler(db *sql.DB) *tagHandler {
-	return &tagHandler{db: db}
+func newTagHandler(db *sql.DB, counts *LibraryCountCache) *tagHandler {
+	return &tagHandler{db: db, counts: counts}
 }
 
 func (h *tagHandler) getTags(w http.ResponseWriter, r *http.Request) {
@@ -161,6 +164,7 @@ func (h *tagHandler) updateTag(w http.ResponseWriter, r *http.Request) {
 		writeError(w, http.StatusNotFound, "tag not found")
 		return
 	}
+	h.counts.Invalidate()
 
 	items, err := loadTags(r.Context(), h.db)
 	if err != nil {
@@ -305,6 +309,7 @@ func (h *tagHandler) updateMangaTags(w http.ResponseWriter, r *http.Request) {
 		writeError(w, http.StatusInternalServerError, "failed to update manga tags")
 		return
 	}
+	h.counts.Invalidate()
 
 	items, err := loadMangaTags(r.Context(), h.db, mangaID)
 	if err != nil {
@@ -424,8 +429,8 @@ func validTagIDs(ctx context.Context, db *sql.DB, tagIDs []string) (map[string]s
 	rows, err := db.QueryContext(ctx, `
 		SELECT id
 		FROM tag
-		WHERE id IN (`+placeholders(len(tagIDs))+`)
-	`, toAnySlice(tagIDs)...)
+		WHERE id IN (`+store.Placeholders(len(tagIDs))+`)
+	`, store.Args(tagIDs)...)
 	if err != nil {
 		return nil, err
 	}
@@ -523,22 +528,3 @@ func boolToInt(value bool) int {
 	}
 	return 0
 }
-
-func placeholders(count int) string {
-	if count <= 0 {
-		return ""
-	}
-	items := make([]string, count)
-	for i := range items {
-		items[i] = "?"
-	}
-	return strings.Join(items, ",")
-}
-
-func toAnySlice(values []string) []any {
-	items := make([]any, 0, len(values))
-	for _, value := range values {
-		items = append(items, value)
-	}
-	return items
-}
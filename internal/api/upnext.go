@@ -0,0 +1,190 @@
+package api
+
+import (
+	"net/http"
+	"sort"
+	"strings"
+)
+
+const (
+	// upNextSortRecent puts the most recently read manga first, favorites
+	// not started yet after them.
+	upNextSortRecent = "recent"
+	// upNextSortUpdated puts the manga whose content changed last first.
+	upNextSortUpdated = "updated"
+
+	defaultUpNextLimit = 20
+	maxUpNextLimit     = 100
+)
+
+type upNextItem struct {
+	MangaID       string `json:"mangaId"`
+	Title         string `json:"title"`
+	CoverThumbURL string `json:"coverThumbUrl"`
+	Favorite      bool   `json:"favorite"`
+	// Chapter is the chapter to read next, and PageIndex the page to open
+	// it at: the page reached when the chapter was left unfinished, 0
+	// otherwise.
+	Chapter   chapterItem `json:"chapter"`
+	PageIndex int         `json:"pageIndex"`
+	// LastReadAt is when progress was last recorded for the manga, empty for
+	// favorites not started yet.
+	LastReadAt       string `json:"lastReadAt,omitempty"`
+	ContentUpdatedAt string `json:"contentUpdatedAt"`
+}
+
+type upNextResponse struct {
+	Sort  string       `json:"sort"`
+	Items []upNextItem `json:"items"`
+}
+
+// upNextChapter is a chapter of a manga being considered for up next, with
+// its reading progress if any.
+type upNextChapter struct {
+	item        chapterItem
+	hasProgress bool
+	pageIndex   int
+	completed   bool
+	readAt      string
+}
+
+// getUpNext lists, for every manga being read or marked favorite, the
+// chapter to read next, one per manga. That is the chapter read last when
+// it was left unfinished, or else the first unfinished chapter after it;
+// favorites not started yet begin at their first chapter. Manga with
+// nothing left to read after the chapter read last are left out.
+func (h *progressHandler) getUpNext(w http.ResponseWriter, r *http.Request) {
+	if h.db == nil {
+		writeError(w, http.StatusInternalServerError, "database not initialized")
+		return
+	}
+
+	sortBy := strings.TrimSpace(r.URL.Query().Get("sort"))
+	if sortBy == "" {
+		sortBy = upNextSortRecent
+	}
+	if sortBy != upNextSortRecent && sortBy != upNextSortUpdated {
+		writeError(w, http.StatusBadRequest, "sort must be recent or updated")
+		return
+	}
+	limit := min(parsePositiveInt(r.URL.Query().Get("limit"), defaultUpNextLimit), maxUpNextLimit)
+
+	rows, err := h.db.QueryContext(r.Context(), `
+		WITH candidate AS (
+			SELECT m.id, m.title, m.favorite, COALESCE(m.content_updated_at, m.updated_at) AS content_updated_at,
+				(SELECT MAX(p.updated_at) FROM reading_progress p WHERE p.manga_id = m.id) AS last_read_at
+			FROM manga m
+			WHERE m.favorite = 1 OR EXISTS (SELECT 1 FROM reading_progress p WHERE p.manga_id = m.id)
+		)
+		SELECT m.id, m.title, m.favorite, m.content_updated_at, COALESCE(m.last_read_at, ''),
+			c.id, c.title, c.chapter_number, c.volume, c.page_count, c.excluded_page_count, c.updated_at, c.possibly_incomplete,
+			p.chapter_id IS NOT NULL, COALESCE(p.page_index, 0), COALESCE(p.completed, 0), COALESCE(p.updated_at, '')
+		FROM candidate m
+		JOIN chapter c ON c.manga_id = m.id
+		LEFT JOIN reading_progress p ON p.chapter_id = c.id
+		ORDER BY m.id ASC, c.chapter_number ASC, c.title ASC, c.id ASC
+	`)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to query up next")
+		return
+	}
+	defer rows.Close()
+
+	items := make([]upNextItem, 0)
+	var current upNextItem
+	var chapters []upNextChapter
+	flush := func() {
+		if current.MangaID == "" {
+			return
+		}
+		if next, ok := nextChapter(chapters); ok {
+			current.Chapter = next.item
+			if next.hasProgress && !next.completed {
+				current.PageIndex = next.pageIndex
+			}
+			items = append(items, current)
+		}
+		chapters = chapters[:0]
+	}
+	for rows.Next() {
+		var manga upNextItem
+		var chapter upNextChapter
+		if err := rows.Scan(
+			&manga.MangaID,
+			&manga.Title,
+			&manga.Favorite,
+			&manga.ContentUpdatedAt,
+			&manga.LastReadAt,
+			&chapter.item.ID,
+			&chapter.item.Title,
+			&chapter.item.Number,
+			&chapter.item.Volume,
+			&chapter.item.PageCount,
+			&chapter.item.ExcludedPages,
+			&chapter.item.UpdatedAt,
+			&chapter.item.PossiblyIncomplete,
+			&chapter.hasProgress,
+			&chapter.pageIndex,
+			&chapter.completed,
+			&chapter.readAt,
+		); err != nil {
+			writeError(w, http.StatusInternalServerError, "failed to read up next row")
+			return
+		}
+		if manga.MangaID != current.MangaID {
+			flush()
+			current = manga
+			current.CoverThumbURL = "/api/images/covers/" + manga.MangaID + "/thumb"
+		}
+		chapters = append(chapters, chapter)
+	}
+	if err := rows.Err(); err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to iterate up next rows")
+		return
+	}
+	flush()
+
+	sortUpNext(items, sortBy)
+	if len(items) > limit {
+		items = items[:limit]
+	}
+	writeJSON(w, http.StatusOK, upNextResponse{Sort: sortBy, Items: items})
+}
+
+// nextChapter picks the chapter to read next from a manga's chapters in
+// reading order. The chapter read last is the one with the latest progress,
+// the later one in reading order when several were recorded together, as
+// catch-up does.
+func nextChapter(chapters []upNextChapter) (upNextChapter, bool) {
+	last := -1
+	for index, chapter := range chapters {
+		if chapter.hasProgress && (last < 0 || chapter.readAt >= chapters[last].readAt) {
+			last = index
+		}
+	}
+	if last >= 0 && !chapters[last].completed {
+		return chapters[last], true
+	}
+	for _, chapter := range chapters[last+1:] {
+		if !chapter.completed {
+			return chapter, true
+		}
+	}
+	return upNextChapter{}, false
+}
+
+func sortUpNext(items []upNextItem, sortBy string) {
+	sort.SliceStable(items, func(i, j int) bool {
+		a, b := items[i], items[j]
+		if sortBy == upNextSortRecent && a.LastReadAt != b.LastReadAt {
+			return a.LastReadAt > b.LastReadAt
+		}
+		if a.ContentUpdatedAt != b.ContentUpdatedAt {
+			return a.ContentUpdatedAt > b.ContentUpdatedAt
+		}
+		if a.Title != b.Title {
+			return a.Title < b.Title
+		}
+		return a.MangaID < b.MangaID
+	})
+}
@@ -0,0 +1,96 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+	"testing"
+)
+
+func TestUpNext(t *testing.T) {
+	server := newTestServer(t, "")
+	for title, chapters := range map[string]int{"Alpha": 3, "Beta": 3, "Gamma": 2, "Delta": 2, "Epsilon": 1, "Zeta": 2} {
+		for i := range chapters {
+			writeChapter(t, server.root, title, fmt.Sprintf("Chapter %d", i+1), 3)
+		}
+	}
+	server.scan()
+	mangaID := func(title string) string {
+		return server.queryString(`SELECT id FROM manga WHERE title = ?`, title)
+	}
+	chapterID := func(manga string, chapter string) string {
+		return server.queryString(`SELECT id FROM chapter WHERE manga_id = ? AND title = ?`, mangaID(manga), chapter)
+	}
+	// read records progress through the API, then backdates it so the
+	// order of reads does not hang on the clock.
+	read := func(manga string, chapter string, body string, at string) {
+		t.Helper()
+		id := chapterID(manga, chapter)
+		if rec := server.do(http.MethodPut, "/api/chapters/"+id+"/progress", body); rec.Code != http.StatusOK {
+			t.Fatalf("progress status = %d, body %s", rec.Code, rec.Body.String())
+		}
+		if _, err := server.db.Exec(`UPDATE reading_progress SET updated_at = ? WHERE chapter_id = ?`, at, id); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	read("Alpha", "Chapter 1", `{"pageIndex":2}`, "2026-05-01 10:00:00")
+	read("Beta", "Chapter 1", `{"pageIndex":2}`, "2026-05-01 11:00:00")
+	read("Beta", "Chapter 2", `{"pageIndex":1}`, "2026-05-01 12:00:00")
+	read("Gamma", "Chapter 1", `{"pageIndex":2}`, "2026-05-01 11:00:00")
+	read("Gamma", "Chapter 2", `{"pageIndex":2}`, "2026-05-01 11:30:00")
+	// Zeta's last read finished its last chapter, so the unfinished first
+	// chapter read before it does not bring it back.
+	read("Zeta", "Chapter 1", `{"pageIndex":0}`, "2026-05-01 09:00:00")
+	read("Zeta", "Chapter 2", `{"pageIndex":2}`, "2026-05-01 09:30:00")
+	for title, updated := range map[string]string{"Alpha": "2026-02-01 00:00:00", "Beta": "2026-01-01 00:00:00", "Delta": "2026-03-01 00:00:00"} {
+		if _, err := server.db.Exec(`UPDATE manga SET content_updated_at = ? WHERE id = ?`, updated, mangaID(title)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := server.db.Exec(`UPDATE manga SET favorite = 1 WHERE id = ?`, mangaID("Delta")); err != nil {
+		t.Fatal(err)
+	}
+
+	upNext := func(query string) []upNextItem {
+		t.Helper()
+		rec := server.do(http.MethodGet, "/api/up-next"+query, "")
+		if rec.Code != http.StatusOK {
+			t.Fatalf("up next status = %d, body %s", rec.Code, rec.Body.String())
+		}
+		return decodeJSON[upNextResponse](t, rec).Items
+	}
+	type entry struct {
+		title, chapter string
+		pageIndex      int
+	}
+	entries := func(items []upNextItem) []entry {
+		got := []entry{}
+		for _, item := range items {
+			got = append(got, entry{item.Title, item.Chapter.Title, item.PageIndex})
+		}
+		return got
+	}
+
+	// Gamma is finished, Zeta has nothing after its last read and Epsilon
+	// was never started.
+	items := upNext("")
+	want := []entry{{"Beta", "Chapter 2", 1}, {"Alpha", "Chapter 2", 0}, {"Delta", "Chapter 1", 0}}
+	if got := entries(items); !slices.Equal(got, want) {
+		t.Fatalf("up next by recent = %+v, want %+v", got, want)
+	}
+	if items[0].LastReadAt != "2026-05-01 12:00:00" || items[2].LastReadAt != "" || !items[2].Favorite {
+		t.Errorf("up next items = %+v, want Beta read last and Delta an unstarted favorite", items)
+	}
+
+	want = []entry{{"Delta", "Chapter 1", 0}, {"Alpha", "Chapter 2", 0}, {"Beta", "Chapter 2", 1}}
+	if got := entries(upNext("?sort=updated")); !slices.Equal(got, want) {
+		t.Fatalf("up next by update = %+v, want %+v", got, want)
+	}
+	if got := entries(upNext("?limit=2")); len(got) != 2 || got[0].title != "Beta" {
+		t.Errorf("up next limited to 2 = %+v", got)
+	}
+	if rec := server.do(http.MethodGet, "/api/up-next?sort=alphabetical", ""); rec.Code != http.StatusBadRequest {
+		t.Errorf("unknown sort = %d, want 400", rec.Code)
+	}
+}
@@ -0,0 +1,165 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+)
+
+const uncategorizedVolumeTitle = "Uncategorized"
+
+type volumeGroup struct {
+	Volume       *int          `json:"volume"`
+	Title        string        `json:"title"`
+	ChapterCount int           `json:"chapterCount"`
+	Chapters     []chapterItem `json:"chapters"`
+}
+
+type volumesResponse struct {
+	MangaID string        `json:"mangaId"`
+	Items   []volumeGroup `json:"items"`
+}
+
+type chapterVolumeUpdateRequest struct {
+	Volume *int `json:"volume"`
+}
+
+func (h *mangaHandler) getVolumes(w http.ResponseWriter, r *http.Request) {
+	if h.db == nil {
+		writeError(w, http.StatusInternalServerError, "database not initialized")
+		return
+	}
+
+	mangaID := chi.URLParam(r, "mangaID")
+	exists, err := mangaExists(r.Context(), h.db, mangaID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to load manga")
+		return
+	}
+	if !exists {
+		writeError(w, http.StatusNotFound, "manga not found")
+		return
+	}
+
+	rows, err := h.db.QueryContext(r.Context(), `
+		SELECT id, title, chapter_number, volume, page_count, excluded_page_count, updated_at, possibly_incomplete
+		FROM chapter
+		WHERE manga_id = ?
+		ORDER BY volume IS NULL ASC, volume ASC, chapter_number ASC, title ASC, id ASC
+	`, mangaID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to load chapters")
+		return
+	}
+	defer rows.Close()
+
+	groups := make([]volumeGroup, 0)
+	for rows.Next() {
+		var item chapterItem
+		if err := rows.Scan(&item.ID, &item.Title, &item.Number, &item.Volume, &item.PageCount, &item.ExcludedPages, &item.UpdatedAt, &item.PossiblyIncomplete); err != nil {
+			writeError(w, http.StatusInternalServerError, "failed to read chapter row")
+			return
+		}
+		if len(groups) == 0 || !sameVolume(groups[len(groups)-1].Volume, item.Volume) {
+			groups = append(groups, volumeGroup{
+				Volume:   item.Volume,
+				Title:    volumeTitle(item.Volume),
+				Chapters: make([]chapterItem, 0),
+			})
+		}
+		group := &groups[len(groups)-1]
+		group.Chapters = append(group.Chapters, item)
+		group.ChapterCount++
+	}
+	if err := rows.Err(); err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to iterate chapter rows")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, volumesResponse{MangaID: mangaID, Items: groups})
+}
+
+// updateChapterVolume overrides the volume a scan detected for a chapter,
+// locking it against later scans. A null volume removes the override: the
+// chapter is uncategorized until the next scan of its manga, which is made
+// to revisit it even if its files are unchanged, detects the volume again.
+func (h *mangaHandler) updateChapterVolume(w http.ResponseWriter, r *http.Request) {
+	if h.db == nil {
+		writeError(w, http.StatusInternalServerError, "database not initialized")
+		return
+	}
+
+	chapterID := strings.TrimSpace(chi.URLParam(r, "chapterID"))
+	if chapterID == "" {
+		writeError(w, http.StatusBadRequest, "chapter id is required")
+		return
+	}
+
+	var request chapterVolumeUpdateRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid volume payload")
+		return
+	}
+	if request.Volume != nil && *request.Volume < 0 {
+		writeError(w, http.StatusBadRequest, "volume must not be negative")
+		return
+	}
+
+	tx, err := h.db.BeginTx(r.Context(), nil)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to start transaction")
+		return
+	}
+	defer tx.Rollback()
+
+	result, err := tx.ExecContext(r.Context(), `
+		UPDATE chapter
+		SET volume = ?, volume_locked = ?
+		WHERE id = ?
+	`, request.Volume, boolToInt(request.Volume != nil), chapterID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to update chapter volume")
+		return
+	}
+
+	affected, _ := result.RowsAffected()
+	if affected == 0 {
+		writeError(w, http.StatusNotFound, "chapter not found")
+		return
+	}
+	if request.Volume == nil {
+		if _, err := tx.ExecContext(r.Context(), `
+			UPDATE manga SET source_fingerprint = NULL
+			WHERE id = (SELECT manga_id FROM chapter WHERE id = ?)
+		`, chapterID); err != nil {
+			writeError(w, http.StatusInternalServerError, "failed to update chapter volume")
+			return
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to commit chapter volume")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]any{
+		"chapterId": chapterID,
+		"volume":    request.Volume,
+	})
+}
+
+func sameVolume(left *int, right *int) bool {
+	if left == nil || right == nil {
+		return left == nil && right == nil
+	}
+	return *left == *right
+}
+
+func volumeTitle(volume *int) string {
+	if volume == nil {
+		return uncategorizedVolumeTitle
+	}
+	return "Vol. " + strconv.Itoa(*volume)
+}
@@ -0,0 +1,100 @@
+package api
+
+import (
+	"net/http"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestVolumes(t *testing.T) {
+	server := newTestServer(t, "")
+	for _, chapter := range []string{"Vol 2 Chapter 3", "Vol 1 Chapter 2", "Vol 1 Chapter 1", "Chapter 4"} {
+		writeChapter(t, server.root, "Alpha", chapter, 1)
+	}
+	server.scan()
+	mangaID := server.queryString(`SELECT id FROM manga`)
+	chapterID := func(title string) string {
+		return server.queryString(`SELECT id FROM chapter WHERE title = ?`, title)
+	}
+
+	type group struct {
+		title    string
+		count    int
+		chapters string
+	}
+	volumes := func() []group {
+		t.Helper()
+		rec := server.do(http.MethodGet, "/api/manga/"+mangaID+"/volumes", "")
+		if rec.Code != http.StatusOK {
+			t.Fatalf("volumes status = %d, body %s", rec.Code, rec.Body.String())
+		}
+		groups := []group{}
+		for _, item := range decodeJSON[volumesResponse](t, rec).Items {
+			titles := []string{}
+			for _, chapter := range item.Chapters {
+				titles = append(titles, chapter.Title)
+			}
+			if (item.Volume == nil) != (item.Title == uncategorizedVolumeTitle) {
+				t.Errorf("volume %v is titled %q", item.Volume, item.Title)
+			}
+			groups = append(groups, group{item.Title, item.ChapterCount, strings.Join(titles, "|")})
+		}
+		return groups
+	}
+	assertVolumes := func(want ...group) {
+		t.Helper()
+		if got := volumes(); !slices.Equal(got, want) {
+			t.Fatalf("volumes = %+v, want %+v", got, want)
+		}
+	}
+	setVolume := func(title string, body string) {
+		t.Helper()
+		if rec := server.do(http.MethodPut, "/api/chapters/"+chapterID(title)+"/volume", body); rec.Code != http.StatusOK {
+			t.Fatalf("volume update status = %d, body %s", rec.Code, rec.Body.String())
+		}
+	}
+
+	// Volumes in order, chapters in order within them, and the chapter
+	// without a detectable volume last.
+	detected := []group{
+		{"Vol. 1", 2, "Vol 1 Chapter 1|Vol 1 Chapter 2"},
+		{"Vol. 2", 1, "Vol 2 Chapter 3"},
+		{uncategorizedVolumeTitle, 1, "Chapter 4"},
+	}
+	assertVolumes(detected...)
+
+	// An override moves the chapter and survives rescans.
+	setVolume("Chapter 4", `{"volume":2}`)
+	setVolume("Vol 1 Chapter 2", `{"volume":3}`)
+	overridden := []group{
+		{"Vol. 1", 1, "Vol 1 Chapter 1"},
+		{"Vol. 2", 2, "Vol 2 Chapter 3|Chapter 4"},
+		{"Vol. 3", 1, "Vol 1 Chapter 2"},
+	}
+	assertVolumes(overridden...)
+	server.scan()
+	assertVolumes(overridden...)
+
+	// Clearing the overrides hands the volumes back to the scan, even
+	// though no file changed.
+	setVolume("Chapter 4", `{"volume":null}`)
+	setVolume("Vol 1 Chapter 2", `{"volume":null}`)
+	if got := server.queryString(`SELECT COUNT(*) FROM chapter WHERE volume_locked = 1`); got != "0" {
+		t.Fatalf("locked chapters after clearing = %s, want 0", got)
+	}
+	server.scan()
+	assertVolumes(detected...)
+
+	for body, want := range map[string]int{`{"volume":-1}`: http.StatusBadRequest, `not json`: http.StatusBadRequest} {
+		if rec := server.do(http.MethodPut, "/api/chapters/"+chapterID("Chapter 4")+"/volume", body); rec.Code != want {
+			t.Errorf("volume update with %s = %d, want %d", body, rec.Code, want)
+		}
+	}
+	if rec := server.do(http.MethodPut, "/api/chapters/missing/volume", `{"volume":1}`); rec.Code != http.StatusNotFound {
+		t.Errorf("volume update of a missing chapter = %d, want 404", rec.Code)
+	}
+	if rec := server.do(http.MethodGet, "/api/manga/missing/volumes", ""); rec.Code != http.StatusNotFound {
+		t.Errorf("volumes of a missing manga = %d, want 404", rec.Code)
+	}
+}
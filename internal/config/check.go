@@ -0,0 +1,105 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"mynewmangaui/internal/media"
+)
+
+// PathReport is what CheckPaths found: directories EnsurePaths would create
+// and problems that would keep the server from working.
+type PathReport struct {
+	WouldCreate []string
+	Problems    []string
+}
+
+// OK reports whether no problems were found.
+func (r PathReport) OK() bool {
+	return len(r.Problems) == 0
+}
+
+// CheckPaths inspects the paths of cfg without changing anything: the
+// directories EnsurePaths would create, the bookshelf roots and the TLS
+// files. A directory that does not exist yet is only a problem when none of
+// its existing ancestors is a directory it could be created in.
+func CheckPaths(cfg Config) PathReport {
+	var report PathReport
+
+	dirs := []struct {
+		name string
+		path string
+	}{
+		{"database.path directory", filepath.Dir(cfg.Database.Path)},
+		{"storage.cachePath", cfg.Storage.CachePath},
+		{"online.cachePath", cfg.Online.CachePath},
+		{"online.downloadsPath", cfg.Online.DownloadsPath},
+	}
+	for _, dir := range dirs {
+		if strings.TrimSpace(dir.path) == "" {
+			continue
+		}
+		info, err := os.Stat(dir.path)
+		switch {
+		case err == nil && !info.IsDir():
+			report.Problems = append(report.Problems, fmt.Sprintf("%s %q is not a directory", dir.name, dir.path))
+		case err == nil:
+		case errors.Is(err, fs.ErrNotExist):
+			if problem := creatableDir(dir.path); problem != "" {
+				report.Problems = append(report.Problems, fmt.Sprintf("%s %q cannot be created: %s", dir.name, dir.path, problem))
+				continue
+			}
+			report.WouldCreate = append(report.WouldCreate, dir.path)
+		default:
+			report.Problems = append(report.Problems, fmt.Sprintf("%s %q: %v", dir.name, dir.path, err))
+		}
+	}
+
+	for i, shelf := range cfg.Storage.Bookshelves {
+		info, err := os.Stat(shelf.Path)
+		switch {
+		case err != nil:
+			report.Problems = append(report.Problems, fmt.Sprintf("storage.bookshelves[%d] %q root %q: %v", i, shelf.Name, shelf.Path, err))
+		case !info.IsDir() && !(cfg.Storage.ArchiveRoots && media.IsArchiveFile(shelf.Path)):
+			report.Problems = append(report.Problems, fmt.Sprintf("storage.bookshelves[%d] %q root %q is not a directory", i, shelf.Name, shelf.Path))
+		}
+	}
+
+	if cfg.Server.TLS.Enabled() {
+		if _, err := os.Stat(cfg.Server.TLS.CertFile); err != nil {
+			report.Problems = append(report.Problems, fmt.Sprintf("server.tls.certFile %q: %v", cfg.Server.TLS.CertFile, err))
+		}
+		if _, err := os.Stat(cfg.Server.TLS.KeyFile); err != nil {
+			report.Problems = append(report.Problems, fmt.Sprintf("server.tls.keyFile %q: %v", cfg.Server.TLS.KeyFile, err))
+		}
+	}
+	return report
+}
+
+// creatableDir walks up from path to the first ancestor that exists and
+// returns why path could not be created under it, or "" when it could.
+func creatableDir(path string) string {
+	dir := filepath.Clean(path)
+	for {
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return ""
+		}
+		info, err := os.Stat(parent)
+		if errors.Is(err, fs.ErrNotExist) {
+			dir = parent
+			continue
+		}
+		if err != nil {
+			return err.Error()
+		}
+		if !info.IsDir() {
+			return fmt.Sprintf("%q is not a directory", parent)
+		}
+		return ""
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
 )
 
@@ -17,20 +18,198 @@ type Config struct {
 }
 
 type ServerConfig struct {
-	Address              string   `json:"address"`
-	AllowPrivateNetworks bool     `json:"allowPrivateNetworks"`
-	PublicAccessToken    string   `json:"publicAccessToken"`
-	TrustedProxyCIDRs    []string `json:"trustedProxyCIDRs"`
+	Address              string           `json:"address"`
+	AllowPrivateNetworks bool             `json:"allowPrivateNetworks"`
+	PublicAccessToken    string           `json:"publicAccessToken"`
+	AdminToken           string           `json:"adminToken"`
+	TrustedProxyCIDRs    []string         `json:"trustedProxyCIDRs"`
+	Pagination           PaginationConfig `json:"pagination"`
+	TLS                  TLSConfig        `json:"tls"`
+	InlinePageMaxBytes   int64            `json:"inlinePageMaxBytes"`
+	SlowPageThresholdMs  int              `json:"slowPageThresholdMs"`
+	// PageCacheMaxAgeSeconds is the browser cache lifetime of page images;
+	// zero makes clients revalidate every time.
+	PageCacheMaxAgeSeconds int `json:"pageCacheMaxAgeSeconds"`
+	// MaxConcurrentDecodes caps images decoded or rendered at once while
+	// answering requests; extra requests get 503. Zero removes the cap.
+	MaxConcurrentDecodes int `json:"maxConcurrentDecodes"`
+	// StaticDir serves the web UI from this directory instead of the copy
+	// built into the binary.
+	StaticDir string `json:"staticDir"`
+	// ETagStrategy picks how pages, cover thumbnails and JSON responses are
+	// validated: "weak" (default) derives ETags from size and modification
+	// time, "strong" from SHA-256 content hashes. Strong ETags cost a full
+	// read of each page once per scan but allow resumed range requests.
+	ETagStrategy string `json:"etagStrategy"`
+	// ScanShutdownGraceSeconds is how long shutdown waits for a running
+	// scan to commit the manga it is on before cancelling it, within the
+	// shutdown timeout; zero cancels right away.
+	ScanShutdownGraceSeconds int `json:"scanShutdownGraceSeconds"`
+	// ShutdownTimeoutSeconds bounds the whole shutdown: in-flight requests
+	// such as chapter downloads are drained while the scan grace runs, and
+	// connections still open when it elapses are closed.
+	ShutdownTimeoutSeconds int `json:"shutdownTimeoutSeconds"`
+	// SecurityHeaders are added to every response.
+	SecurityHeaders SecurityHeadersConfig `json:"securityHeaders"`
+}
+
+// SecurityHeadersConfig lists the security headers the server sends. A
+// header whose value is empty is left out, and Enabled turns them all off.
+// The defaults suit the bundled web UI, which uses inline scripts and
+// styles and loads everything else from the server itself.
+type SecurityHeadersConfig struct {
+	Enabled bool `json:"enabled"`
+	// ContentTypeOptions is X-Content-Type-Options; "nosniff" keeps
+	// browsers from treating served library files as anything but their
+	// declared type.
+	ContentTypeOptions string `json:"contentTypeOptions"`
+	// FrameOptions is X-Frame-Options, DENY or SAMEORIGIN. Browsers that
+	// know CSP frame-ancestors prefer it when the policy sets one.
+	FrameOptions          string `json:"frameOptions"`
+	ContentSecurityPolicy string `json:"contentSecurityPolicy"`
+	ReferrerPolicy        string `json:"referrerPolicy"`
+}
+
+const (
+	ETagWeak   = "weak"
+	ETagStrong = "strong"
+)
+
+// TLSConfig enables HTTPS (and with it HTTP/2) when both files are set.
+type TLSConfig struct {
+	CertFile string `json:"certFile"`
+	KeyFile  string `json:"keyFile"`
+}
+
+func (t TLSConfig) Enabled() bool {
+	return t.CertFile != "" && t.KeyFile != ""
+}
+
+type PaginationConfig struct {
+	DefaultLimit int                   `json:"defaultLimit"`
+	MaxLimit     int                   `json:"maxLimit"`
+	Resources    map[string]PageLimits `json:"resources"`
+}
+
+type PageLimits struct {
+	DefaultLimit int `json:"defaultLimit"`
+	MaxLimit     int `json:"maxLimit"`
 }
 
 type DatabaseConfig struct {
 	Path string `json:"path"`
+	// AutoMigrate applies pending migrations at startup. When false the
+	// server refuses to start until they are applied with -migrate.
+	AutoMigrate bool `json:"autoMigrate"`
+	// Pragmas are merged over the built-in SQLite pragmas. Only tuning
+	// pragmas such as busy_timeout, cache_size and mmap_size are accepted.
+	Pragmas map[string]string `json:"pragmas"`
+	// LogQueries logs the SQL, argument count and duration of every
+	// statement at debug level, and warns about those taking
+	// SlowQueryThresholdMs or longer. It costs a little on every query, so
+	// it is meant for diagnosing slow endpoints.
+	LogQueries           bool `json:"logQueries"`
+	SlowQueryThresholdMs int  `json:"slowQueryThresholdMs"`
+
+	// The pool holds a single connection, so these decide how long one
+	// SQLite connection lives. Closing it releases its page cache and any
+	// WAL snapshot it still holds; the last connection to close also
+	// checkpoints the WAL. ConnMaxLifetimeSeconds replaces the connection
+	// after that long and ConnMaxIdleSeconds closes it once unused for that
+	// long, so an idle server does not keep one around; zero keeps it.
+	ConnMaxLifetimeSeconds int `json:"connMaxLifetimeSeconds"`
+	ConnMaxIdleSeconds     int `json:"connMaxIdleSeconds"`
+	// CheckpointIntervalSeconds runs a PASSIVE WAL checkpoint this often,
+	// so the WAL is copied back into the database between bursts of writes
+	// rather than growing until the next automatic checkpoint. Zero leaves
+	// checkpoints to SQLite.
+	CheckpointIntervalSeconds int `json:"checkpointIntervalSeconds"`
 }
 
 type StorageConfig struct {
-	LibraryRoots []string          `json:"libraryRoots"`
-	Bookshelves  []BookshelfConfig `json:"bookshelves"`
-	CachePath    string            `json:"cachePath"`
+	LibraryRoots      []string          `json:"libraryRoots"`
+	Bookshelves       []BookshelfConfig `json:"bookshelves"`
+	CachePath         string            `json:"cachePath"`
+	AllowFileDeletion bool              `json:"allowFileDeletion"`
+	TrashPath         string            `json:"trashPath"`
+	SniffMime         bool              `json:"sniffMime"`
+	MaxChapterNumber  float64           `json:"maxChapterNumber"`
+	EnablePDF         bool              `json:"enablePDF"`
+	IndexCoverOnly    bool              `json:"indexCoverOnly"`
+	PDFRenderer       string            `json:"pdfRenderer"`
+	// IncompleteChapterWindowSeconds flags chapters modified within this
+	// many seconds as possibly incomplete; zero turns the check off.
+	IncompleteChapterWindowSeconds int  `json:"incompleteChapterWindowSeconds"`
+	DeferIncompleteChapters        bool `json:"deferIncompleteChapters"`
+	// PreserveFilenameNumbers numbers pages after the digits in their file
+	// names, leaving gaps where files are missing, instead of 0..N-1.
+	PreserveFilenameNumbers bool `json:"preserveFilenameNumbers"`
+	// ArchiveRoots lets a bookshelf path point at a single archive, which is
+	// then indexed as that shelf's only manga.
+	ArchiveRoots bool `json:"archiveRoots"`
+	// TitleSource picks where manga titles come from: "folder" names,
+	// "comicinfo" for the Series most chapters' ComicInfo.xml agree on, or
+	// "prefer-comicinfo" to use it only when all chapters agree. A title in
+	// metadata.json always wins.
+	TitleSource string `json:"titleSource"`
+	// MaxQueuedScans caps how many scan requests wait for the running scan
+	// to finish; further requests are refused until the queue drains.
+	MaxQueuedScans int `json:"maxQueuedScans"`
+	// SkipHiddenFiles leaves out files and folders whose names start with a
+	// dot, such as .DS_Store and ._ resource forks, and __MACOSX folders.
+	// Defaults to true.
+	SkipHiddenFiles bool `json:"skipHiddenFiles"`
+	// CaseInsensitivePaths treats paths differing only in case as the same
+	// manga or chapter, for libraries on case-insensitive filesystems.
+	// Changing it changes every manga and chapter id, dropping reading
+	// progress, so it is best set before the first scan.
+	CaseInsensitivePaths bool `json:"caseInsensitivePaths"`
+	// ChecksumMode picks when page checksums are computed: "inline" while
+	// scanning, "lazy" in a background pass after each scan, or "off" (the
+	// default) only when something needs one, such as strong ETags. With
+	// inline or lazy, hashed pages are served with an ETag from their
+	// checksum and cached as immutable.
+	ChecksumMode string `json:"checksumMode"`
+	// ExcludePagePatterns leaves out of chapters the pages whose file names
+	// match, such as credits or ad pages: globs like "zzz*credits*.png",
+	// matched case-insensitively, or regular expressions prefixed with
+	// "re:". A manga folder's metadata.json may replace them with its own
+	// "excludePages" list.
+	ExcludePagePatterns []string `json:"excludePagePatterns"`
+	// CacheMinFreeBytes stops thumbnails and transcoded pages from being
+	// written to CachePath while its volume has less free space than this;
+	// they are served uncached instead. Zero turns the check off.
+	CacheMinFreeBytes int64 `json:"cacheMinFreeBytes"`
+	// NormalizeOrientation serves JPEG pages carrying an EXIF orientation
+	// re-encoded upright, for clients that ignore the tag. Thumbnails and
+	// transcoded pages are always upright.
+	NormalizeOrientation bool `json:"normalizeOrientation"`
+	// PageFormats lists the formats ("avif", "webp") JPEG and PNG pages are
+	// transcoded to for clients that accept them, most preferred first.
+	PageFormats []string `json:"pageFormats"`
+	// PageEncoders overrides the encoder command per format, avifenc and
+	// cwebp by default.
+	PageEncoders map[string]string `json:"pageEncoders"`
+	Thumbnail    ThumbnailConfig   `json:"thumbnail"`
+	// ScanOnStartup defaults to true. When false the server never scans on
+	// boot and serves what the database already holds; manual scans still
+	// work whenever the roots are reachable.
+	ScanOnStartup *bool `json:"scanOnStartup,omitempty"`
+}
+
+func (s StorageConfig) StartupScan() bool {
+	return s.ScanOnStartup == nil || *s.ScanOnStartup
+}
+
+// ThumbnailConfig controls how cover and chapter thumbnails are encoded.
+// WebP uses the storage.pageEncoders webp command (cwebp by default).
+type ThumbnailConfig struct {
+	Format       string `json:"format"`
+	Quality      int    `json:"quality"`
+	MaxDimension int    `json:"maxDimension"`
+	// ChapterPage picks the page chapter thumbnails show: first, middle or
+	// firstColor, the first page that is not grayscale.
+	ChapterPage string `json:"chapterPage"`
 }
 
 type OnlineConfig struct {
@@ -68,17 +247,69 @@ type OnlineSourceDefaultDisplayConfig struct {
 type BookshelfConfig struct {
 	Name string `json:"name"`
 	Path string `json:"path"`
+	// ScanOnStartup defaults to true; slow roots can opt out and be scanned
+	// on demand instead.
+	ScanOnStartup *bool `json:"scanOnStartup,omitempty"`
+	// TitlePattern derives manga titles from folder names: a regexp with a
+	// named "title" group, or a template such as "{author} - {title} ({year})".
+	// Names it does not match keep their full folder name.
+	TitlePattern string `json:"titlePattern,omitempty"`
+}
+
+func (b BookshelfConfig) StartupScan() bool {
+	return b.ScanOnStartup == nil || *b.ScanOnStartup
 }
 
+// Load reads the config file at path. When path is a directory, its *.json
+// fragments are merged over the built-in defaults instead.
 func Load(path string) (Config, error) {
-	raw, err := os.ReadFile(path)
+	info, err := os.Stat(path)
+	if err == nil && info.IsDir() {
+		return LoadWithFragments("", path)
+	}
+	return LoadWithFragments(path, "")
+}
+
+// LoadWithFragments reads the base config file and deep-merges every *.json
+// file in fragmentDir over it in lexical order. Objects merge key by key,
+// later files win and any other value, arrays included, is replaced whole.
+// Either argument may be empty; the merged result is validated once.
+func LoadWithFragments(path string, fragmentDir string) (Config, error) {
+	merged := map[string]any{}
+	if path != "" {
+		document, err := readConfigDocument(path)
+		if err != nil {
+			return Config{}, err
+		}
+		merged = document
+	}
+
+	if fragmentDir != "" {
+		if _, err := os.Stat(fragmentDir); err != nil {
+			return Config{}, fmt.Errorf("read config dir %q: %w", fragmentDir, err)
+		}
+		fragments, err := filepath.Glob(filepath.Join(fragmentDir, "*.json"))
+		if err != nil {
+			return Config{}, fmt.Errorf("list config fragments in %q: %w", fragmentDir, err)
+		}
+		sort.Strings(fragments)
+		for _, fragment := range fragments {
+			document, err := readConfigDocument(fragment)
+			if err != nil {
+				return Config{}, err
+			}
+			mergeConfigDocuments(merged, document)
+		}
+	}
+
+	raw, err := json.Marshal(merged)
 	if err != nil {
-		return Config{}, fmt.Errorf("read config %q: %w", path, err)
+		return Config{}, fmt.Errorf("encode merged config: %w", err)
 	}
 
 	cfg := defaultConfig()
 	if err := json.Unmarshal(raw, &cfg); err != nil {
-		return Config{}, fmt.Errorf("unmarshal config %q: %w", path, err)
+		return Config{}, fmt.Errorf("unmarshal merged config: %w", err)
 	}
 
 	if err := cfg.validate(); err != nil {
@@ -88,21 +319,86 @@ func Load(path string) (Config, error) {
 	return cfg, nil
 }
 
+func readConfigDocument(path string) (map[string]any, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read config %q: %w", path, err)
+	}
+
+	document := map[string]any{}
+	if err := json.Unmarshal(raw, &document); err != nil {
+		return nil, fmt.Errorf("unmarshal config %q: %w", path, err)
+	}
+	return document, nil
+}
+
+func mergeConfigDocuments(dst map[string]any, src map[string]any) {
+	for key, value := range src {
+		child, ok := value.(map[string]any)
+		existing, existingOK := dst[key].(map[string]any)
+		if ok && existingOK {
+			mergeConfigDocuments(existing, child)
+			continue
+		}
+		dst[key] = value
+	}
+}
+
 func defaultConfig() Config {
 	return Config{
 		Server: ServerConfig{
-			Address:              ":8080",
-			AllowPrivateNetworks: true,
+			Address:                  ":8080",
+			AllowPrivateNetworks:     true,
+			InlinePageMaxBytes:       2 << 20,
+			SlowPageThresholdMs:      1000,
+			PageCacheMaxAgeSeconds:   7 * 24 * 60 * 60,
+			MaxConcurrentDecodes:     4,
+			ETagStrategy:             ETagWeak,
+			ScanShutdownGraceSeconds: 10,
+			ShutdownTimeoutSeconds:   30,
+			SecurityHeaders: SecurityHeadersConfig{
+				Enabled:               true,
+				ContentTypeOptions:    "nosniff",
+				FrameOptions:          "SAMEORIGIN",
+				ContentSecurityPolicy: "default-src 'self'; script-src 'self' 'unsafe-inline'; style-src 'self' 'unsafe-inline'; img-src 'self' data: blob:; object-src 'none'; base-uri 'self'; frame-ancestors 'self'",
+				ReferrerPolicy:        "same-origin",
+			},
+			Pagination: PaginationConfig{
+				DefaultLimit: 60,
+				MaxLimit:     200,
+				Resources: map[string]PageLimits{
+					"chapters":  {DefaultLimit: 1000, MaxLimit: 5000},
+					"downloads": {DefaultLimit: 20, MaxLimit: 200},
+				},
+			},
 		},
 		Database: DatabaseConfig{
-			Path: "./data/app.db",
+			Path:                      "./data/app.db",
+			AutoMigrate:               true,
+			SlowQueryThresholdMs:      200,
+			ConnMaxLifetimeSeconds:    30 * 60,
+			ConnMaxIdleSeconds:        5 * 60,
+			CheckpointIntervalSeconds: 5 * 60,
 		},
 		Storage: StorageConfig{
 			LibraryRoots: []string{"./local"},
 			Bookshelves: []BookshelfConfig{
 				{Name: "榛樿涔︽灦", Path: "./local"},
 			},
-			CachePath: "./cache/thumbs",
+			CachePath:        "./cache/thumbs",
+			TrashPath:        "./data/trash",
+			MaxChapterNumber: 10000,
+			PDFRenderer:      "pdftoppm",
+			TitleSource:      "folder",
+			SkipHiddenFiles:  true,
+			MaxQueuedScans:   32,
+			ChecksumMode:     "off",
+			Thumbnail: ThumbnailConfig{
+				Format:       "jpeg",
+				Quality:      82,
+				MaxDimension: 512,
+				ChapterPage:  "middle",
+			},
 		},
 		Online: OnlineConfig{
 			Enabled:               false,
@@ -165,7 +461,24 @@ func defaultConfig() Config {
 	}
 }
 
+// For returns the limits for a resource, falling back to the global limits
+// for any value the resource does not override.
+func (p PaginationConfig) For(resource string) PageLimits {
+	limits := PageLimits{DefaultLimit: p.DefaultLimit, MaxLimit: p.MaxLimit}
+	if override, ok := p.Resources[resource]; ok {
+		if override.DefaultLimit > 0 {
+			limits.DefaultLimit = override.DefaultLimit
+		}
+		if override.MaxLimit > 0 {
+			limits.MaxLimit = override.MaxLimit
+		}
+	}
+	return limits
+}
+
 func (c *Config) normalize() {
+	c.Server.TLS.CertFile = strings.TrimSpace(c.Server.TLS.CertFile)
+	c.Server.TLS.KeyFile = strings.TrimSpace(c.Server.TLS.KeyFile)
 	if len(c.Storage.Bookshelves) == 0 {
 		for _, root := range c.Storage.LibraryRoots {
 			root = strings.TrimSpace(root)
@@ -190,12 +503,108 @@ func (c *Config) validate() error {
 	if strings.TrimSpace(c.Server.Address) == "" {
 		return fmt.Errorf("server.address is required")
 	}
+	if err := c.Server.Pagination.validate(); err != nil {
+		return err
+	}
+	if c.Server.SlowPageThresholdMs < 0 {
+		return fmt.Errorf("server.slowPageThresholdMs must not be negative")
+	}
+	if c.Server.PageCacheMaxAgeSeconds < 0 {
+		return fmt.Errorf("server.pageCacheMaxAgeSeconds must not be negative")
+	}
+	if c.Server.MaxConcurrentDecodes < 0 {
+		return fmt.Errorf("server.maxConcurrentDecodes must not be negative")
+	}
+	if c.Server.InlinePageMaxBytes <= 0 {
+		return fmt.Errorf("server.inlinePageMaxBytes must be positive")
+	}
+	if dir := strings.TrimSpace(c.Server.StaticDir); dir != "" {
+		if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+			return fmt.Errorf("server.staticDir %q is not a directory", dir)
+		}
+	}
+	if c.Server.ScanShutdownGraceSeconds < 0 {
+		return fmt.Errorf("server.scanShutdownGraceSeconds must not be negative")
+	}
+	if c.Server.ShutdownTimeoutSeconds <= 0 {
+		return fmt.Errorf("server.shutdownTimeoutSeconds must be positive")
+	}
+	if c.Server.ETagStrategy != ETagWeak && c.Server.ETagStrategy != ETagStrong {
+		return fmt.Errorf("server.etagStrategy must be weak or strong")
+	}
+	if err := c.Server.SecurityHeaders.validate(); err != nil {
+		return err
+	}
+	if (c.Server.TLS.CertFile == "") != (c.Server.TLS.KeyFile == "") {
+		return fmt.Errorf("server.tls.certFile and server.tls.keyFile must be set together")
+	}
 	if strings.TrimSpace(c.Database.Path) == "" {
 		return fmt.Errorf("database.path is required")
 	}
+	if c.Database.SlowQueryThresholdMs < 0 {
+		return fmt.Errorf("database.slowQueryThresholdMs must not be negative")
+	}
+	if c.Database.ConnMaxLifetimeSeconds < 0 {
+		return fmt.Errorf("database.connMaxLifetimeSeconds must not be negative")
+	}
+	if c.Database.ConnMaxIdleSeconds < 0 {
+		return fmt.Errorf("database.connMaxIdleSeconds must not be negative")
+	}
+	if c.Database.CheckpointIntervalSeconds < 0 {
+		return fmt.Errorf("database.checkpointIntervalSeconds must not be negative")
+	}
 	if strings.TrimSpace(c.Storage.CachePath) == "" {
 		return fmt.Errorf("storage.cachePath is required")
 	}
+	if c.Storage.IncompleteChapterWindowSeconds < 0 {
+		return fmt.Errorf("storage.incompleteChapterWindowSeconds must not be negative")
+	}
+	seenFormats := make(map[string]bool, len(c.Storage.PageFormats))
+	for _, format := range c.Storage.PageFormats {
+		if format != "avif" && format != "webp" {
+			return fmt.Errorf("storage.pageFormats contains unsupported format %q", format)
+		}
+		if seenFormats[format] {
+			return fmt.Errorf("storage.pageFormats lists %q twice", format)
+		}
+		seenFormats[format] = true
+	}
+	if c.Storage.Thumbnail.Format != "jpeg" && c.Storage.Thumbnail.Format != "webp" {
+		return fmt.Errorf("storage.thumbnail.format must be jpeg or webp")
+	}
+	if c.Storage.Thumbnail.Quality < 1 || c.Storage.Thumbnail.Quality > 100 {
+		return fmt.Errorf("storage.thumbnail.quality must be between 1 and 100")
+	}
+	if c.Storage.Thumbnail.MaxDimension < 16 || c.Storage.Thumbnail.MaxDimension > 4096 {
+		return fmt.Errorf("storage.thumbnail.maxDimension must be between 16 and 4096")
+	}
+	switch c.Storage.Thumbnail.ChapterPage {
+	case "first", "middle", "firstColor":
+	default:
+		return fmt.Errorf("storage.thumbnail.chapterPage must be first, middle or firstColor")
+	}
+	switch c.Storage.TitleSource {
+	case "folder", "comicinfo", "prefer-comicinfo":
+	default:
+		return fmt.Errorf("storage.titleSource must be folder, comicinfo or prefer-comicinfo")
+	}
+	switch c.Storage.ChecksumMode {
+	case "inline", "lazy", "off":
+	default:
+		return fmt.Errorf("storage.checksumMode must be inline, lazy or off")
+	}
+	if c.Storage.CacheMinFreeBytes < 0 {
+		return fmt.Errorf("storage.cacheMinFreeBytes must not be negative")
+	}
+	if c.Storage.MaxQueuedScans < 1 {
+		return fmt.Errorf("storage.maxQueuedScans must be positive")
+	}
+	if c.Storage.MaxChapterNumber <= 0 {
+		return fmt.Errorf("storage.maxChapterNumber must be positive")
+	}
+	if c.Storage.AllowFileDeletion && strings.TrimSpace(c.Storage.TrashPath) == "" {
+		return fmt.Errorf("storage.trashPath is required when storage.allowFileDeletion is enabled")
+	}
 	if c.Online.Enabled {
 		if strings.TrimSpace(c.Online.CachePath) == "" {
 			return fmt.Errorf("online.cachePath is required when online is enabled")
@@ -229,6 +638,45 @@ func (c *Config) validate() error {
 	return nil
 }
 
+func (p PaginationConfig) validate() error {
+	if p.DefaultLimit <= 0 || p.MaxLimit <= 0 {
+		return fmt.Errorf("server.pagination limits must be positive")
+	}
+	if p.DefaultLimit > p.MaxLimit {
+		return fmt.Errorf("server.pagination.defaultLimit must not exceed maxLimit")
+	}
+	for name, override := range p.Resources {
+		if override.DefaultLimit < 0 || override.MaxLimit < 0 {
+			return fmt.Errorf("server.pagination.resources.%s limits must not be negative", name)
+		}
+		limits := p.For(name)
+		if limits.DefaultLimit > limits.MaxLimit {
+			return fmt.Errorf("server.pagination.resources.%s.defaultLimit must not exceed maxLimit", name)
+		}
+	}
+	return nil
+}
+
+func (h SecurityHeadersConfig) validate() error {
+	if value := h.ContentTypeOptions; value != "" && !strings.EqualFold(value, "nosniff") {
+		return fmt.Errorf("server.securityHeaders.contentTypeOptions must be nosniff or empty")
+	}
+	switch strings.ToUpper(h.FrameOptions) {
+	case "", "DENY", "SAMEORIGIN":
+	default:
+		return fmt.Errorf("server.securityHeaders.frameOptions must be DENY, SAMEORIGIN or empty")
+	}
+	for name, value := range map[string]string{
+		"contentSecurityPolicy": h.ContentSecurityPolicy,
+		"referrerPolicy":        h.ReferrerPolicy,
+	} {
+		if strings.ContainsAny(value, "\r\n") {
+			return fmt.Errorf("server.securityHeaders.%s must be a single line", name)
+		}
+	}
+	return nil
+}
+
 func EnsurePaths(cfg Config) error {
 	if err := os.MkdirAll(filepath.Dir(cfg.Database.Path), 0o755); err != nil {
 		return fmt.Errorf("create database dir: %w", err)
@@ -0,0 +1,131 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// loadDocument loads a config file holding document.
+func loadDocument(t *testing.T, document string) (Config, error) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.json")
+	if err := os.WriteFile(path, []byte(document), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return Load(path)
+}
+
+func TestTLSRequiresCertAndKey(t *testing.T) {
+	tests := []struct {
+		name     string
+		document string
+		wantErr  bool
+		enabled  bool
+	}{
+		{name: "plaintext by default", document: `{}`},
+		{name: "both files", document: `{"server":{"tls":{"certFile":"cert.pem","keyFile":"key.pem"}}}`, enabled: true},
+		{name: "cert only", document: `{"server":{"tls":{"certFile":"cert.pem"}}}`, wantErr: true},
+		{name: "key only", document: `{"server":{"tls":{"keyFile":" key.pem "}}}`, wantErr: true},
+		{name: "blank cert", document: `{"server":{"tls":{"certFile":"  ","keyFile":"key.pem"}}}`, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg, err := loadDocument(t, tt.document)
+			if tt.wantErr {
+				if err == nil || !strings.Contains(err.Error(), "server.tls") {
+					t.Fatalf("error = %v, want a server.tls error", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("load: %v", err)
+			}
+			if cfg.Server.TLS.Enabled() != tt.enabled {
+				t.Errorf("tls enabled = %v, want %v", cfg.Server.TLS.Enabled(), tt.enabled)
+			}
+		})
+	}
+}
+
+// writeFragments writes a config directory holding a file per name.
+func writeFragments(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, document := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(document), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestLoadWithFragmentsMergePrecedence(t *testing.T) {
+	base := filepath.Join(t.TempDir(), "config.json")
+	if err := os.WriteFile(base, []byte(`{
+		"server": {"address": ":9000", "adminToken": "base", "inlinePageMaxBytes": 100},
+		"storage": {
+			"bookshelves": [{"name": "a", "path": "/a"}, {"name": "b", "path": "/b"}],
+			"pageFormats": ["webp", "avif"]
+		}
+	}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	fragments := writeFragments(t, map[string]string{
+		"20-token.json":   `{"server": {"adminToken": "second"}}`,
+		"10-token.json":   `{"server": {"adminToken": "first", "inlinePageMaxBytes": 200}}`,
+		"30-shelves.json": `{"storage": {"bookshelves": [{"name": "c", "path": "/c"}], "pageFormats": []}}`,
+		"notes.txt":       `not json`,
+	})
+
+	cfg, err := LoadWithFragments(base, fragments)
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if cfg.Server.Address != ":9000" {
+		t.Errorf("address = %q, want the base :9000", cfg.Server.Address)
+	}
+	if cfg.Server.AdminToken != "second" {
+		t.Errorf("admin token = %q, want the later fragment's", cfg.Server.AdminToken)
+	}
+	if cfg.Server.InlinePageMaxBytes != 200 {
+		t.Errorf("inline page max = %d, want 200", cfg.Server.InlinePageMaxBytes)
+	}
+	if len(cfg.Storage.Bookshelves) != 1 || cfg.Storage.Bookshelves[0].Name != "c" {
+		t.Errorf("bookshelves = %+v, want only c", cfg.Storage.Bookshelves)
+	}
+	if len(cfg.Storage.PageFormats) != 0 {
+		t.Errorf("page formats = %q, want the fragment's empty list", cfg.Storage.PageFormats)
+	}
+}
+
+func TestLoadConfigDirectory(t *testing.T) {
+	dir := writeFragments(t, map[string]string{
+		"10-base.json":  `{"server": {"adminToken": "dir"}}`,
+		"20-shelf.json": `{"storage": {"bookshelves": [{"name": "c", "path": "/c"}]}}`,
+	})
+	cfg, err := Load(dir)
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if cfg.Server.AdminToken != "dir" || len(cfg.Storage.Bookshelves) != 1 {
+		t.Fatalf("config = token %q, bookshelves %+v", cfg.Server.AdminToken, cfg.Storage.Bookshelves)
+	}
+}
+
+func TestLoadWithFragmentsValidatesMergedConfig(t *testing.T) {
+	base := filepath.Join(t.TempDir(), "config.json")
+	if err := os.WriteFile(base, []byte(`{"server": {"tls": {"certFile": "cert.pem", "keyFile": "key.pem"}}}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	fragments := writeFragments(t, map[string]string{"10-tls.json": `{"server": {"tls": {"keyFile": ""}}}`})
+	if _, err := LoadWithFragments(base, fragments); err == nil || !strings.Contains(err.Error(), "server.tls") {
+		t.Fatalf("error = %v, want the merged config rejected", err)
+	}
+
+	broken := writeFragments(t, map[string]string{"10-broken.json": `{"server": `})
+	if _, err := LoadWithFragments(base, broken); err == nil || !strings.Contains(err.Error(), "10-broken.json") {
+		t.Fatalf("error = %v, want the broken fragment named", err)
+	}
+}
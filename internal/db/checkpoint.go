@@ -0,0 +1,55 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"time"
+)
+
+// CheckpointResult is what PRAGMA wal_checkpoint reports.
+type CheckpointResult struct {
+	// Busy is set when the checkpoint could not finish because another
+	// connection, such as one in a different process, was reading or
+	// writing.
+	Busy bool
+	// WALFrames is the size of the WAL in frames and Checkpointed how many
+	// of them are now in the database; both are -1 outside WAL mode.
+	WALFrames    int
+	Checkpointed int
+}
+
+// Checkpoint copies what it can of the WAL into the database without
+// waiting on other connections, so later writes can reuse the WAL from
+// its start instead of growing it.
+func Checkpoint(ctx context.Context, db *sql.DB) (CheckpointResult, error) {
+	var result CheckpointResult
+	if err := db.QueryRowContext(ctx, `PRAGMA wal_checkpoint(PASSIVE)`).Scan(&result.Busy, &result.WALFrames, &result.Checkpointed); err != nil {
+		return result, fmt.Errorf("checkpoint wal: %w", err)
+	}
+	return result, nil
+}
+
+// RunCheckpoints checkpoints the WAL every interval until ctx is done. With
+// the single-connection pool each checkpoint waits for the connection like
+// any query, so it never overlaps a request's reads in this process.
+func RunCheckpoints(ctx context.Context, db *sql.DB, interval time.Duration, logger *slog.Logger) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		result, err := Checkpoint(ctx, db)
+		if err != nil {
+			if ctx.Err() == nil {
+				logger.Warn("wal checkpoint failed", "error", err)
+			}
+			continue
+		}
+		logger.Debug("wal checkpoint", "busy", result.Busy, "wal_frames", result.WALFrames, "checkpointed", result.Checkpointed)
+	}
+}
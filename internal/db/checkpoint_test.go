@@ -0,0 +1,102 @@
+package db
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// syncBuffer is a log destination safe to read while RunCheckpoints writes.
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+func TestCheckpointsBoundWAL(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "app.db")
+	// With automatic checkpoints off, only Checkpoint keeps the WAL from
+	// growing with every write.
+	database, err := Open(ctx, path, map[string]string{"wal_autocheckpoint": "0"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer database.Close()
+	if _, err := database.Exec(`CREATE TABLE filler (data BLOB)`); err != nil {
+		t.Fatal(err)
+	}
+	write := func() int64 {
+		t.Helper()
+		for range 50 {
+			if _, err := database.Exec(`INSERT INTO filler(data) VALUES(randomblob(4096))`); err != nil {
+				t.Fatal(err)
+			}
+		}
+		info, err := os.Stat(path + "-wal")
+		if err != nil {
+			t.Fatal(err)
+		}
+		return info.Size()
+	}
+
+	first := write()
+	if grown := write(); grown <= first {
+		t.Fatalf("wal without checkpoints = %d bytes after %d, want it growing", grown, first)
+	}
+	result, err := Checkpoint(ctx, database)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Busy || result.WALFrames == 0 || result.Checkpointed != result.WALFrames {
+		t.Fatalf("checkpoint = %+v, want every frame copied", result)
+	}
+	settled := write()
+
+	// The periodic checkpoints keep it there however much is written.
+	var logs syncBuffer
+	logger := slog.New(slog.NewTextHandler(&logs, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	runCtx, stop := context.WithCancel(ctx)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		RunCheckpoints(runCtx, database, 10*time.Millisecond, logger)
+	}()
+	defer func() {
+		stop()
+		<-done
+	}()
+	for range 5 {
+		checkpoints := strings.Count(logs.String(), "msg=\"wal checkpoint\"")
+		deadline := time.Now().Add(5 * time.Second)
+		for strings.Count(logs.String(), "msg=\"wal checkpoint\"") == checkpoints {
+			if time.Now().After(deadline) {
+				t.Fatalf("no checkpoint ran:\n%s", logs.String())
+			}
+			time.Sleep(5 * time.Millisecond)
+		}
+		if size := write(); size > settled {
+			t.Fatalf("wal = %d bytes with periodic checkpoints, want at most %d", size, settled)
+		}
+	}
+	if strings.Contains(logs.String(), "wal checkpoint failed") {
+		t.Errorf("checkpoint failed:\n%s", logs.String())
+	}
+}
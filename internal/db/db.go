@@ -6,6 +6,7 @@ import (
 	"embed"
 	"fmt"
 	"log/slog"
+	"net/url"
 	"sort"
 	"strings"
 	"time"
@@ -16,46 +17,209 @@ import (
 //go:embed migrations/*.sql
 var migrationFS embed.FS
 
-func OpenAndMigrate(ctx context.Context, dsn string, logger *slog.Logger) (*sql.DB, error) {
-	db, err := sql.Open("sqlite", dsn)
-	if err != nil {
-		return nil, fmt.Errorf("open sqlite: %w", err)
+// defaultPragmas are applied to every connection unless overridden.
+var defaultPragmas = map[string]string{
+	"journal_mode": "WAL",
+	"synchronous":  "NORMAL",
+	"foreign_keys": "ON",
+	"temp_store":   "MEMORY",
+	"busy_timeout": "5000",
+}
+
+// tunablePragmas are the pragmas config may override. Anything that could
+// corrupt the database or weaken its integrity checks is left out.
+var tunablePragmas = map[string]bool{
+	"busy_timeout":       true,
+	"cache_size":         true,
+	"mmap_size":          true,
+	"synchronous":        true,
+	"temp_store":         true,
+	"journal_mode":       true,
+	"journal_size_limit": true,
+	"wal_autocheckpoint": true,
+}
+
+// EffectivePragmas merges overrides over the default pragmas. Keys must be
+// tunable pragmas and values plain words or integers.
+func EffectivePragmas(overrides map[string]string) (map[string]string, error) {
+	pragmas := make(map[string]string, len(defaultPragmas)+len(overrides))
+	for name, value := range defaultPragmas {
+		pragmas[name] = value
 	}
+	for name, value := range overrides {
+		name = strings.ToLower(strings.TrimSpace(name))
+		value = strings.TrimSpace(value)
+		if !tunablePragmas[name] {
+			return nil, fmt.Errorf("pragma %q cannot be configured", name)
+		}
+		if !validPragmaValue(value) {
+			return nil, fmt.Errorf("pragma %s has invalid value %q", name, value)
+		}
+		pragmas[name] = value
+	}
+	return pragmas, nil
+}
 
-	db.SetMaxOpenConns(1)
-	db.SetConnMaxLifetime(30 * time.Minute)
+func validPragmaValue(value string) bool {
+	if value == "" {
+		return false
+	}
+	for i, r := range value {
+		switch {
+		case r >= '0' && r <= '9', r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r == '_':
+		case r == '-' && i == 0:
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+func OpenAndMigrate(ctx context.Context, dsn string, pragmas map[string]string, queryLog *QueryLog, logger *slog.Logger) (*sql.DB, error) {
+	db, err := Open(ctx, dsn, pragmas, queryLog)
+	if err != nil {
+		return nil, err
+	}
 
-	if err := applyPragmas(ctx, db); err != nil {
+	if err := runMigrations(ctx, db, logger); err != nil {
 		db.Close()
 		return nil, err
 	}
 
+	return db, nil
+}
+
+// Open connects to the database without touching the schema, for
+// deployments that apply migrations as a separate step. pragmas override
+// the defaults as described by EffectivePragmas. A non-nil queryLog logs
+// the timing of every statement.
+//
+// The pool holds a single connection, replaced after 30 minutes unless
+// SetConnMaxLifetime says otherwise. The pragmas go into the DSN so that
+// each replacement connection is set up the same way.
+func Open(ctx context.Context, dsn string, pragmas map[string]string, queryLog *QueryLog) (*sql.DB, error) {
+	effective, err := EffectivePragmas(pragmas)
+	if err != nil {
+		return nil, err
+	}
+
+	db, err := openSQLite(pragmaDSN(dsn, effective), queryLog)
+	if err != nil {
+		return nil, fmt.Errorf("open sqlite: %w", err)
+	}
+
+	db.SetMaxOpenConns(1)
+	db.SetConnMaxLifetime(30 * time.Minute)
+
 	if err := db.PingContext(ctx); err != nil {
 		db.Close()
 		return nil, fmt.Errorf("ping sqlite: %w", err)
 	}
 
-	if err := runMigrations(ctx, db, logger); err != nil {
+	return db, nil
+}
+
+// OpenMigrated opens a database whose schema must already be up to date,
+// for servers started with auto-migration off. It refuses one with pending
+// migrations, naming them and telling the operator to run the migrate step
+// first.
+func OpenMigrated(ctx context.Context, dsn string, pragmas map[string]string, queryLog *QueryLog) (*sql.DB, error) {
+	db, err := Open(ctx, dsn, pragmas, queryLog)
+	if err != nil {
+		return nil, err
+	}
+	pending, err := PendingMigrations(ctx, db)
+	if err != nil {
 		db.Close()
 		return nil, err
 	}
-
+	if len(pending) > 0 {
+		db.Close()
+		return nil, fmt.Errorf("database has %d pending migrations (%s); run the server with -migrate before starting it", len(pending), strings.Join(pending, ", "))
+	}
 	return db, nil
 }
 
-func applyPragmas(ctx context.Context, db *sql.DB) error {
-	pragmas := []string{
-		"PRAGMA journal_mode = WAL;",
-		"PRAGMA synchronous = NORMAL;",
-		"PRAGMA foreign_keys = ON;",
-		"PRAGMA temp_store = MEMORY;",
+// PendingMigrations lists the embedded migrations not yet applied to db,
+// without creating or changing anything.
+func PendingMigrations(ctx context.Context, db *sql.DB) ([]string, error) {
+	versions, err := migrationVersions()
+	if err != nil {
+		return nil, err
+	}
+
+	var tables int
+	if err := db.QueryRowContext(ctx, `
+		SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = 'schema_migrations'
+	`).Scan(&tables); err != nil {
+		return nil, fmt.Errorf("inspect schema_migrations: %w", err)
+	}
+	if tables == 0 {
+		return versions, nil
+	}
+
+	pending := make([]string, 0)
+	for _, version := range versions {
+		applied, err := isApplied(ctx, db, version)
+		if err != nil {
+			return nil, err
+		}
+		if !applied {
+			pending = append(pending, version)
+		}
+	}
+	return pending, nil
+}
+
+// AppliedMigration is a migration recorded in schema_migrations.
+type AppliedMigration struct {
+	Version   string
+	AppliedAt string
+}
+
+// AppliedMigrations lists the migrations applied to db, oldest first.
+func AppliedMigrations(ctx context.Context, db *sql.DB) ([]AppliedMigration, error) {
+	rows, err := db.QueryContext(ctx, `SELECT version, applied_at FROM schema_migrations ORDER BY version ASC`)
+	if err != nil {
+		return nil, fmt.Errorf("list applied migrations: %w", err)
 	}
-	for _, stmt := range pragmas {
-		if _, err := db.ExecContext(ctx, stmt); err != nil {
-			return fmt.Errorf("apply pragma %q: %w", stmt, err)
+	defer rows.Close()
+
+	applied := make([]AppliedMigration, 0)
+	for rows.Next() {
+		var migration AppliedMigration
+		if err := rows.Scan(&migration.Version, &migration.AppliedAt); err != nil {
+			return nil, fmt.Errorf("scan applied migration: %w", err)
 		}
+		applied = append(applied, migration)
 	}
-	return nil
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate applied migrations: %w", err)
+	}
+	return applied, nil
+}
+
+// pragmaDSN adds pragmas to dsn as _pragma parameters, which the driver
+// runs on every connection it opens, in name order.
+func pragmaDSN(dsn string, pragmas map[string]string) string {
+	names := make([]string, 0, len(pragmas))
+	for name := range pragmas {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	params := make([]string, 0, len(names))
+	for _, name := range names {
+		params = append(params, "_pragma="+url.QueryEscape(fmt.Sprintf("%s(%s)", name, pragmas[name])))
+	}
+	if len(params) == 0 {
+		return dsn
+	}
+	separator := "?"
+	if strings.Contains(dsn, "?") {
+		separator = "&"
+	}
+	return dsn + separator + strings.Join(params, "&")
 }
 
 func runMigrations(ctx context.Context, db *sql.DB, logger *slog.Logger) error {
@@ -68,19 +232,10 @@ func runMigrations(ctx context.Context, db *sql.DB, logger *slog.Logger) error {
 		return fmt.Errorf("create schema_migrations: %w", err)
 	}
 
-	entries, err := migrationFS.ReadDir("migrations")
+	versions, err := migrationVersions()
 	if err != nil {
-		return fmt.Errorf("read migration directory: %w", err)
-	}
-
-	versions := make([]string, 0, len(entries))
-	for _, e := range entries {
-		if e.IsDir() || !strings.HasSuffix(e.Name(), ".sql") {
-			continue
-		}
-		versions = append(versions, e.Name())
+		return err
 	}
-	sort.Strings(versions)
 
 	for _, version := range versions {
 		applied, err := isApplied(ctx, db, version)
@@ -120,6 +275,23 @@ func runMigrations(ctx context.Context, db *sql.DB, logger *slog.Logger) error {
 	return nil
 }
 
+func migrationVersions() ([]string, error) {
+	entries, err := migrationFS.ReadDir("migrations")
+	if err != nil {
+		return nil, fmt.Errorf("read migration directory: %w", err)
+	}
+
+	versions := make([]string, 0, len(entries))
+	for _, e := range entries {
+		if e.IsDir() || !strings.HasSuffix(e.Name(), ".sql") {
+			continue
+		}
+		versions = append(versions, e.Name())
+	}
+	sort.Strings(versions)
+	return versions, nil
+}
+
 func isApplied(ctx context.Context, db *sql.DB, version string) (bool, error) {
 	var found string
 	err := db.QueryRowContext(ctx, `SELECT version FROM schema_migrations WHERE version = ?`, version).Scan(&found)
@@ -0,0 +1,93 @@
+package db
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func testLogger() *slog.Logger {
+	return slog.New(slog.NewTextHandler(io.Discard, nil))
+}
+
+func TestOpenMigratedRefusesPendingMigrations(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "app.db")
+	versions, err := migrationVersions()
+	if err != nil {
+		t.Fatal(err)
+	}
+	latest := versions[len(versions)-1]
+
+	// Apply every migration but the latest, as an older release would have.
+	database, err := Open(ctx, path, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := database.Exec(`CREATE TABLE schema_migrations (version TEXT PRIMARY KEY, applied_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP)`); err != nil {
+		t.Fatal(err)
+	}
+	for _, version := range versions[:len(versions)-1] {
+		body, err := migrationFS.ReadFile("migrations/" + version)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := database.Exec(string(body)); err != nil {
+			t.Fatalf("apply %s: %v", version, err)
+		}
+		if _, err := database.Exec(`INSERT INTO schema_migrations(version) VALUES(?)`, version); err != nil {
+			t.Fatal(err)
+		}
+	}
+	database.Close()
+
+	_, err = OpenMigrated(ctx, path, nil, nil)
+	if err == nil {
+		t.Fatal("OpenMigrated accepted a database with a pending migration")
+	}
+	for _, want := range []string{"1 pending migrations", latest, "-migrate"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error %q does not mention %q", err, want)
+		}
+	}
+
+	database, err = OpenAndMigrate(ctx, path, nil, nil, testLogger())
+	if err != nil {
+		t.Fatalf("migrate step: %v", err)
+	}
+	database.Close()
+
+	database, err = OpenMigrated(ctx, path, nil, nil)
+	if err != nil {
+		t.Fatalf("OpenMigrated after the migrate step: %v", err)
+	}
+	database.Close()
+}
+
+func TestPendingMigrationsOnEmptyDatabase(t *testing.T) {
+	ctx := context.Background()
+	database, err := Open(ctx, filepath.Join(t.TempDir(), "app.db"), nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer database.Close()
+
+	pending, err := PendingMigrations(ctx, database)
+	if err != nil {
+		t.Fatalf("PendingMigrations: %v", err)
+	}
+	versions, _ := migrationVersions()
+	if len(pending) != len(versions) {
+		t.Fatalf("pending = %d migrations, want all %d", len(pending), len(versions))
+	}
+	var tables int
+	if err := database.QueryRow(`SELECT COUNT(*) FROM sqlite_master WHERE type = 'table'`).Scan(&tables); err != nil {
+		t.Fatal(err)
+	}
+	if tables != 0 {
+		t.Fatalf("PendingMigrations created %d tables", tables)
+	}
+}
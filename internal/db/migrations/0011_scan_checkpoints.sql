@@ -0,0 +1,13 @@
+CREATE TABLE IF NOT EXISTS scan_cycle (
+    id TEXT PRIMARY KEY,
+    started_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    finished_at DATETIME
+);
+
+CREATE TABLE IF NOT EXISTS scan_checkpoint (
+    cycle_id TEXT NOT NULL,
+    manga_id TEXT NOT NULL,
+    completed_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (cycle_id, manga_id),
+    FOREIGN KEY (cycle_id) REFERENCES scan_cycle(id) ON DELETE CASCADE
+);
@@ -0,0 +1,5 @@
+ALTER TABLE chapter ADD COLUMN volume INTEGER;
+ALTER TABLE chapter ADD COLUMN volume_locked INTEGER NOT NULL DEFAULT 0;
+
+CREATE INDEX IF NOT EXISTS idx_chapter_manga_volume
+ON chapter(manga_id, volume ASC, chapter_number ASC, title ASC, id ASC);
@@ -0,0 +1,6 @@
+ALTER TABLE chapter ADD COLUMN first_seen_at DATETIME;
+
+UPDATE chapter SET first_seen_at = created_at WHERE first_seen_at IS NULL;
+
+CREATE INDEX IF NOT EXISTS idx_chapter_first_seen
+ON chapter(first_seen_at DESC);
@@ -0,0 +1 @@
+ALTER TABLE chapter ADD COLUMN page_order_locked INTEGER NOT NULL DEFAULT 0;
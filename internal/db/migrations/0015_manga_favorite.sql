@@ -0,0 +1,4 @@
+ALTER TABLE manga ADD COLUMN favorite INTEGER NOT NULL DEFAULT 0;
+
+CREATE INDEX IF NOT EXISTS idx_manga_favorite
+ON manga(favorite, updated_at DESC);
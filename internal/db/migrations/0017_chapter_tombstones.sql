@@ -0,0 +1,8 @@
+CREATE TABLE IF NOT EXISTS chapter_tombstone (
+    chapter_id TEXT PRIMARY KEY,
+    manga_id TEXT NOT NULL,
+    removed_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_chapter_tombstone_manga_removed
+ON chapter_tombstone(manga_id, removed_at);
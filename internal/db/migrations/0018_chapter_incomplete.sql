@@ -0,0 +1 @@
+ALTER TABLE chapter ADD COLUMN possibly_incomplete INTEGER NOT NULL DEFAULT 0;
@@ -0,0 +1,10 @@
+CREATE TABLE IF NOT EXISTS reading_progress (
+    chapter_id TEXT PRIMARY KEY,
+    manga_id TEXT NOT NULL,
+    page_index INTEGER NOT NULL DEFAULT 0,
+    completed INTEGER NOT NULL DEFAULT 0,
+    updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_reading_progress_manga
+ON reading_progress(manga_id, completed);
@@ -0,0 +1,11 @@
+ALTER TABLE manga ADD COLUMN sort_name TEXT NOT NULL DEFAULT '';
+ALTER TABLE manga ADD COLUMN sort_name_locked INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE manga ADD COLUMN collection TEXT NOT NULL DEFAULT '';
+
+UPDATE manga SET sort_name = title WHERE sort_name = '';
+
+CREATE INDEX IF NOT EXISTS idx_manga_sort_name
+ON manga(sort_name COLLATE NOCASE, id);
+
+CREATE INDEX IF NOT EXISTS idx_manga_collection
+ON manga(collection, sort_name COLLATE NOCASE);
@@ -0,0 +1 @@
+ALTER TABLE page ADD COLUMN checksum TEXT;
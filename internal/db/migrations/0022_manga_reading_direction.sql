@@ -0,0 +1 @@
+ALTER TABLE manga ADD COLUMN reading_direction TEXT NOT NULL DEFAULT 'ltr';
@@ -0,0 +1 @@
+ALTER TABLE manga ADD COLUMN folder_name TEXT NOT NULL DEFAULT '';
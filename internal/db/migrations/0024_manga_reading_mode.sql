@@ -0,0 +1,3 @@
+ALTER TABLE manga ADD COLUMN reading_mode TEXT NOT NULL DEFAULT 'paged';
+ALTER TABLE manga ADD COLUMN reading_mode_locked INTEGER NOT NULL DEFAULT 0;
+UPDATE manga SET reading_mode = 'continuous' WHERE reading_direction = 'vertical';
@@ -0,0 +1 @@
+ALTER TABLE page ADD COLUMN orientation INTEGER NOT NULL DEFAULT 1;
@@ -0,0 +1,4 @@
+ALTER TABLE manga ADD COLUMN content_updated_at DATETIME;
+UPDATE manga SET content_updated_at = updated_at;
+CREATE INDEX IF NOT EXISTS idx_manga_content_order
+ON manga(content_updated_at DESC, title ASC, id ASC);
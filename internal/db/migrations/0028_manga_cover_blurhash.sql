@@ -0,0 +1 @@
+ALTER TABLE manga ADD COLUMN cover_blurhash TEXT;
@@ -0,0 +1,11 @@
+CREATE TABLE IF NOT EXISTS bookmark (
+    id TEXT PRIMARY KEY,
+    chapter_id TEXT NOT NULL,
+    manga_id TEXT NOT NULL,
+    page_index INTEGER NOT NULL,
+    note TEXT NOT NULL DEFAULT '',
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_bookmark_manga
+ON bookmark(manga_id, chapter_id, page_index);
@@ -0,0 +1 @@
+ALTER TABLE chapter ADD COLUMN excluded_page_count INTEGER NOT NULL DEFAULT 0;
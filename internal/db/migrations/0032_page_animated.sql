@@ -0,0 +1 @@
+ALTER TABLE page ADD COLUMN is_animated INTEGER NOT NULL DEFAULT 0;
@@ -0,0 +1 @@
+ALTER TABLE manga ADD COLUMN source_fingerprint TEXT;
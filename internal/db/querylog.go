@@ -0,0 +1,156 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"log/slog"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// maxLoggedQueryLength is how much of a statement's SQL is logged.
+const maxLoggedQueryLength = 200
+
+// QueryLog turns on timing logs for every statement run through the
+// database: SQL, argument count and duration at Debug, and a warning for
+// those taking SlowThreshold or longer. A zero threshold never warns.
+// Queries are timed until their rows are closed, so the time a handler
+// spends iterating counts too.
+type QueryLog struct {
+	Logger        *slog.Logger
+	SlowThreshold time.Duration
+}
+
+// openSQLite opens the sqlite database at dsn, logging its statements when
+// queryLog is set.
+func openSQLite(dsn string, queryLog *QueryLog) (*sql.DB, error) {
+	db, err := sql.Open("sqlite", dsn)
+	if err != nil || queryLog == nil {
+		return db, err
+	}
+	connector := &queryLogConnector{dsn: dsn, driver: db.Driver(), log: *queryLog}
+	db.Close()
+	return sql.OpenDB(connector), nil
+}
+
+// queryLogConnector opens sqlite connections that report to log.
+type queryLogConnector struct {
+	dsn    string
+	driver driver.Driver
+	log    QueryLog
+}
+
+func (c *queryLogConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	conn, err := c.driver.Open(c.dsn)
+	if err != nil {
+		return nil, err
+	}
+	return &queryLogConn{Conn: conn, log: c.log}, nil
+}
+
+func (c *queryLogConnector) Driver() driver.Driver {
+	return c.driver
+}
+
+// queryLogConn times the statements run on a connection. database/sql runs
+// plain and transaction statements through ExecContext and QueryContext,
+// so only statements prepared explicitly go untimed.
+type queryLogConn struct {
+	driver.Conn
+	log QueryLog
+}
+
+func (c *queryLogConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	execer, ok := c.Conn.(driver.ExecerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+	start := time.Now()
+	result, err := execer.ExecContext(ctx, query, args)
+	c.log.observe(query, len(args), time.Since(start), err)
+	return result, err
+}
+
+func (c *queryLogConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	queryer, ok := c.Conn.(driver.QueryerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+	start := time.Now()
+	rows, err := queryer.QueryContext(ctx, query, args)
+	if err != nil {
+		c.log.observe(query, len(args), time.Since(start), err)
+		return nil, err
+	}
+	return &queryLogRows{Rows: rows, log: c.log, query: query, args: len(args), start: start}, nil
+}
+
+func (c *queryLogConn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	if preparer, ok := c.Conn.(driver.ConnPrepareContext); ok {
+		return preparer.PrepareContext(ctx, query)
+	}
+	return c.Conn.Prepare(query)
+}
+
+func (c *queryLogConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	if beginner, ok := c.Conn.(driver.ConnBeginTx); ok {
+		return beginner.BeginTx(ctx, opts)
+	}
+	return c.Conn.Begin()
+}
+
+func (c *queryLogConn) Ping(ctx context.Context) error {
+	if pinger, ok := c.Conn.(driver.Pinger); ok {
+		return pinger.Ping(ctx)
+	}
+	return nil
+}
+
+type queryLogRows struct {
+	driver.Rows
+	log   QueryLog
+	query string
+	args  int
+	start time.Time
+}
+
+func (r *queryLogRows) Close() error {
+	err := r.Rows.Close()
+	r.log.observe(r.query, r.args, time.Since(r.start), err)
+	return err
+}
+
+func (l QueryLog) observe(query string, args int, elapsed time.Duration, err error) {
+	if l.Logger == nil {
+		return
+	}
+	attrs := []any{
+		"sql", loggedQuery(query),
+		"args", args,
+		"duration_ms", elapsed.Milliseconds(),
+	}
+	if err != nil {
+		attrs = append(attrs, "error", err)
+	}
+	if l.SlowThreshold > 0 && elapsed >= l.SlowThreshold {
+		l.Logger.Warn("slow sql query", attrs...)
+		return
+	}
+	l.Logger.Debug("sql query", attrs...)
+}
+
+// loggedQuery collapses the whitespace of a statement and cuts it to
+// maxLoggedQueryLength, so multi-line SQL fits on one log line.
+func loggedQuery(query string) string {
+	query = strings.Join(strings.Fields(query), " ")
+	if len(query) <= maxLoggedQueryLength {
+		return query
+	}
+	cut := maxLoggedQueryLength
+	for cut > 0 && !utf8.RuneStart(query[cut]) {
+		cut--
+	}
+	return query[:cut] + "…"
+}
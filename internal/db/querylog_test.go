@@ -0,0 +1,77 @@
+package db
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestQueryLogWarnsOnSlowQueries(t *testing.T) {
+	var logs bytes.Buffer
+	queryLog := &QueryLog{
+		Logger:        slog.New(slog.NewTextHandler(&logs, &slog.HandlerOptions{Level: slog.LevelDebug})),
+		SlowThreshold: 50 * time.Millisecond,
+	}
+	database, err := Open(context.Background(), filepath.Join(t.TempDir(), "app.db"), nil, queryLog)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer database.Close()
+
+	lines := func() []string {
+		defer logs.Reset()
+		return strings.Split(strings.TrimSpace(logs.String()), "\n")
+	}
+	// lineFor returns the log line of the statement mentioning marker.
+	lineFor := func(lines []string, marker string) string {
+		t.Helper()
+		for _, line := range lines {
+			if strings.Contains(line, marker) {
+				return line
+			}
+		}
+		t.Fatalf("no log line for %q in:\n%s", marker, strings.Join(lines, "\n"))
+		return ""
+	}
+
+	logs.Reset()
+	if _, err := database.Exec(`CREATE TABLE fast_marker (id INTEGER)`); err != nil {
+		t.Fatal(err)
+	}
+	line := lineFor(lines(), "fast_marker")
+	if !strings.Contains(line, "level=DEBUG") || !strings.Contains(line, `msg="sql query"`) || !strings.Contains(line, "args=0") {
+		t.Errorf("fast statement logged as %s, want a debug line", line)
+	}
+
+	// A query is timed until its rows are closed, so holding them open
+	// past the threshold makes it slow.
+	rows, err := database.Query(`SELECT id FROM fast_marker WHERE id > ? AND 'slow_marker' != ''`, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(2 * queryLog.SlowThreshold)
+	rows.Close()
+	line = lineFor(lines(), "slow_marker")
+	if !strings.Contains(line, "level=WARN") || !strings.Contains(line, `msg="slow sql query"`) || !strings.Contains(line, "args=1") {
+		t.Errorf("slow query logged as %s, want a warning", line)
+	}
+
+	if _, err := database.Exec(`INSERT INTO missing_marker VALUES (1)`); err == nil {
+		t.Fatal("insert into a missing table succeeded")
+	}
+	if line := lineFor(lines(), "missing_marker"); !strings.Contains(line, "error=") {
+		t.Errorf("failed statement logged as %s, want its error", line)
+	}
+
+	t.Run("long statements are cut", func(t *testing.T) {
+		query := "SELECT\n\t" + strings.Repeat("'é', ", maxLoggedQueryLength)
+		got := loggedQuery(query)
+		if !strings.HasPrefix(got, "SELECT 'é'") || !strings.HasSuffix(got, "…") || len(got) > maxLoggedQueryLength+len("…") {
+			t.Errorf("loggedQuery = %q, want collapsed whitespace cut at %d bytes", got, maxLoggedQueryLength)
+		}
+	})
+}
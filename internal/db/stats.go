@@ -0,0 +1,112 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"time"
+
+	"mynewmangaui/internal/metrics"
+)
+
+// Stats describes the size of the database on disk.
+type Stats struct {
+	// Path is the main database file, empty for in-memory databases.
+	Path      string
+	FileBytes int64
+	WALBytes  int64
+	SHMBytes  int64
+	PageSize  int64
+	PageCount int64
+	// FreePages are pages on the freelist, which a VACUUM gives back.
+	FreePages int64
+}
+
+// FreeBytes is the space a VACUUM could reclaim from the main file.
+func (s Stats) FreeBytes() int64 {
+	return s.FreePages * s.PageSize
+}
+
+// ReadStats gathers the database file sizes and page counts.
+func ReadStats(ctx context.Context, db *sql.DB) (Stats, error) {
+	var stats Stats
+	rows, err := db.QueryContext(ctx, `PRAGMA database_list`)
+	if err != nil {
+		return stats, fmt.Errorf("list databases: %w", err)
+	}
+	for rows.Next() {
+		var seq int
+		var name, file string
+		if err := rows.Scan(&seq, &name, &file); err != nil {
+			rows.Close()
+			return stats, fmt.Errorf("read database list: %w", err)
+		}
+		if name == "main" {
+			stats.Path = file
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return stats, fmt.Errorf("read database list: %w", err)
+	}
+
+	for pragma, dest := range map[string]*int64{
+		"page_size":      &stats.PageSize,
+		"page_count":     &stats.PageCount,
+		"freelist_count": &stats.FreePages,
+	} {
+		if err := db.QueryRowContext(ctx, `PRAGMA `+pragma).Scan(dest); err != nil {
+			return stats, fmt.Errorf("read %s: %w", pragma, err)
+		}
+	}
+
+	if stats.Path != "" {
+		stats.FileBytes = fileSize(stats.Path)
+		stats.WALBytes = fileSize(stats.Path + "-wal")
+		stats.SHMBytes = fileSize(stats.Path + "-shm")
+	}
+	return stats, nil
+}
+
+// fileSize is the size of path, zero when it does not exist: the WAL and
+// shared-memory files come and go with checkpoints and connections.
+func fileSize(path string) int64 {
+	info, err := os.Stat(path)
+	if err != nil {
+		return 0
+	}
+	return info.Size()
+}
+
+// RegisterMetrics exposes ReadStats on the registry as gauges of the file
+// sizes and page counts.
+func RegisterMetrics(registry *metrics.Registry, db *sql.DB) {
+	read := func() (Stats, bool) {
+		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+		defer cancel()
+		stats, err := ReadStats(ctx, db)
+		return stats, err == nil
+	}
+	registry.NewGaugeFunc("manga_db_file_bytes", "Size of the SQLite database files, by file.", "file", func() map[string]float64 {
+		stats, ok := read()
+		if !ok {
+			return nil
+		}
+		return map[string]float64{
+			"main": float64(stats.FileBytes),
+			"wal":  float64(stats.WALBytes),
+			"shm":  float64(stats.SHMBytes),
+		}
+	})
+	registry.NewGaugeFunc("manga_db_pages", "SQLite database pages, in use and on the freelist.", "kind", func() map[string]float64 {
+		stats, ok := read()
+		if !ok {
+			return nil
+		}
+		return map[string]float64{
+			"total": float64(stats.PageCount),
+			"free":  float64(stats.FreePages),
+		}
+	})
+}
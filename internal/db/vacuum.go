@@ -0,0 +1,95 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+)
+
+// Vacuum modes accepted by Vacuum.
+const (
+	VacuumFull        = "full"
+	VacuumIncremental = "incremental"
+)
+
+// ErrIncrementalVacuumOff is returned for incremental vacuums of databases
+// not in auto_vacuum=INCREMENTAL mode; a full vacuum switches them over.
+var ErrIncrementalVacuumOff = errors.New("incremental vacuum needs auto_vacuum=incremental; run a full vacuum first")
+
+// VacuumResult holds the database stats around a vacuum.
+type VacuumResult struct {
+	Mode   string
+	Before Stats
+	After  Stats
+}
+
+// ValidVacuumMode reports whether mode is one of the Vacuum constants.
+func ValidVacuumMode(mode string) bool {
+	return mode == VacuumFull || mode == VacuumIncremental
+}
+
+// Vacuum gives the database's free pages back to the file system.
+//
+// A full vacuum rewrites the whole database: it needs free disk space for a
+// copy of it and holds the only connection until done, so every other query
+// waits. It also switches the database to auto_vacuum=INCREMENTAL, which
+// only takes effect through a full vacuum, so later incremental ones work.
+// An incremental vacuum just truncates the freelist and is cheap.
+//
+// In WAL mode the rewritten pages first land in the WAL, so it is
+// checkpointed and truncated afterwards for the file sizes to drop.
+func Vacuum(ctx context.Context, db *sql.DB, mode string) (VacuumResult, error) {
+	result := VacuumResult{Mode: mode}
+	if !ValidVacuumMode(mode) {
+		return result, fmt.Errorf("unsupported vacuum mode %q", mode)
+	}
+
+	before, err := ReadStats(ctx, db)
+	if err != nil {
+		return result, err
+	}
+	result.Before = before
+
+	switch mode {
+	case VacuumFull:
+		if _, err := db.ExecContext(ctx, `PRAGMA auto_vacuum = INCREMENTAL`); err != nil {
+			return result, fmt.Errorf("set auto_vacuum: %w", err)
+		}
+		if _, err := db.ExecContext(ctx, `VACUUM`); err != nil {
+			return result, fmt.Errorf("vacuum: %w", err)
+		}
+	case VacuumIncremental:
+		var autoVacuum int
+		if err := db.QueryRowContext(ctx, `PRAGMA auto_vacuum`).Scan(&autoVacuum); err != nil {
+			return result, fmt.Errorf("read auto_vacuum: %w", err)
+		}
+		// 2 is INCREMENTAL; in the other modes the pragma does nothing.
+		if autoVacuum != 2 {
+			return result, ErrIncrementalVacuumOff
+		}
+		// The pragma frees one page per result row stepped through, so the
+		// rows are drained rather than executed once.
+		rows, err := db.QueryContext(ctx, `PRAGMA incremental_vacuum`)
+		if err != nil {
+			return result, fmt.Errorf("incremental vacuum: %w", err)
+		}
+		for rows.Next() {
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return result, fmt.Errorf("incremental vacuum: %w", err)
+		}
+	}
+
+	if _, err := db.ExecContext(ctx, `PRAGMA wal_checkpoint(TRUNCATE)`); err != nil {
+		return result, fmt.Errorf("checkpoint wal: %w", err)
+	}
+
+	after, err := ReadStats(ctx, db)
+	if err != nil {
+		return result, err
+	}
+	result.After = after
+	return result, nil
+}
@@ -514,7 +514,7 @@ func (s *Service) resumePendingJobs(ctx context.Context) {
 		SELECT id
 		FROM download_job
 		WHERE status = ?
-		ORDER BY created_at ASC
+		ORDER BY created_at ASC, id ASC
 	`, onlinesvc.DownloadJobQueued)
 	if err != nil {
 		if s.logger != nil {
@@ -0,0 +1,107 @@
+package image
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"os"
+	"path/filepath"
+
+	"mynewmangaui/internal/metrics"
+)
+
+var cacheWriteFailures = metrics.Default.NewCounter(
+	"manga_cache_write_failures_total",
+	"Thumbnails and transcoded pages that could not be written to the cache, by kind.",
+	"kind",
+)
+
+// errCacheWrite marks failures to write into the cache directory, as
+// opposed to failures producing the image itself.
+var errCacheWrite = errors.New("cache write failed")
+
+// Output is a thumbnail or transcoded page: the cache file holding it or,
+// when the cache could not be written, the encoded image itself.
+type Output struct {
+	Path string
+	Mime string
+	Data []byte
+}
+
+// cacheWriteFailed counts a failed cache write and logs the first one of a
+// run of failures; later ones stay quiet until a write succeeds again.
+// Writes skipped for lack of free space are logged by checkCacheSpace
+// instead.
+func (s *Service) cacheWriteFailed(kind string, err error) {
+	if errors.Is(err, errCacheLowSpace) {
+		return
+	}
+	cacheWriteFailures.Inc(kind)
+
+	s.cacheMu.Lock()
+	first := !s.cacheFailing
+	s.cacheFailing = true
+	s.cacheMu.Unlock()
+
+	if first && s.logger != nil {
+		s.logger.Warn("cache path not writable, serving images uncached", "path", s.cachePath, "error", err)
+	}
+}
+
+func (s *Service) cacheWriteSucceeded() {
+	s.cacheMu.Lock()
+	recovered := s.cacheFailing
+	s.cacheFailing = false
+	s.cacheMu.Unlock()
+
+	if recovered && s.logger != nil {
+		s.logger.Info("cache path writable again", "path", s.cachePath)
+	}
+}
+
+// cacheWorkDir creates a scratch directory next to target, so finished
+// files can be renamed into place. It fails with errCacheLowSpace when the
+// cache volume is short of free space.
+func (s *Service) cacheWorkDir(target string, pattern string) (string, error) {
+	if err := s.checkCacheSpace(); err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+		return "", fmt.Errorf("%w: %v", errCacheWrite, err)
+	}
+	dir, err := os.MkdirTemp(filepath.Dir(target), pattern)
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", errCacheWrite, err)
+	}
+	return dir, nil
+}
+
+// storeThumbnail caches img at target, falling back to a JPEG encoded in
+// memory when the cache cannot be written.
+func (s *Service) storeThumbnail(ctx context.Context, target string, img image.Image) (Output, error) {
+	err := s.writeThumbnail(ctx, target, img)
+	if err == nil {
+		s.cacheWriteSucceeded()
+		return Output{Path: target, Mime: s.thumbnailMime()}, nil
+	}
+	if ctx.Err() != nil || !errors.Is(err, errCacheWrite) {
+		return Output{}, err
+	}
+	s.cacheWriteFailed("thumbnail", err)
+
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: s.thumbnail.Quality}); err != nil {
+		return Output{}, err
+	}
+	return Output{Mime: "image/jpeg", Data: buf.Bytes()}, nil
+}
+
+func (s *Service) thumbnailMime() string {
+	if s.thumbnail.Format == "webp" {
+		return "image/webp"
+	}
+	return "image/jpeg"
+}
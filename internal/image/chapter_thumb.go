@@ -0,0 +1,242 @@
+package image
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"image"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"mynewmangaui/internal/media"
+)
+
+// Chapter thumbnail strategies pick the page a chapter thumbnail shows.
+const (
+	ChapterThumbFirst  = "first"
+	ChapterThumbMiddle = "middle"
+	// ChapterThumbFirstColor skips leading grayscale pages such as credits,
+	// falling back to the first page when none of the probed pages is in
+	// color.
+	ChapterThumbFirstColor = "firstColor"
+)
+
+// maxColorProbePages bounds how many pages the firstColor strategy decodes
+// before giving up on a chapter.
+const maxColorProbePages = 8
+
+// colorfulChannelSpread is how far apart the channels of a sampled pixel
+// must be, on the 8-bit scale, for the pixel to count as colored; scans of
+// black and white pages are rarely perfectly neutral.
+const colorfulChannelSpread = 24
+
+// ErrNoPages is returned for chapter thumbnails of chapters without pages.
+var ErrNoPages = errors.New("chapter has no pages")
+
+// ValidChapterThumbStrategy reports whether strategy names a known strategy.
+func ValidChapterThumbStrategy(strategy string) bool {
+	switch strategy {
+	case ChapterThumbFirst, ChapterThumbMiddle, ChapterThumbFirstColor:
+		return true
+	}
+	return false
+}
+
+type chapterPageSource struct {
+	paths     []string
+	cacheFile string
+}
+
+// CachedChapterThumb returns the cached chapter thumbnail when it is still
+// current, letting callers skip decoding work entirely.
+func (s *Service) CachedChapterThumb(ctx context.Context, chapterID string) (string, bool) {
+	source, err := s.chapterThumbSource(ctx, chapterID)
+	if err != nil {
+		return "", false
+	}
+	ref, err := media.ParseRef(source.paths[0])
+	if err != nil {
+		return "", false
+	}
+	if ok, err := cacheUpToDate(source.cacheFile, ref.Path); err != nil || !ok {
+		return "", false
+	}
+	return source.cacheFile, true
+}
+
+// EnsureChapterThumb builds the thumbnail of a chapter's representative
+// page, as picked by the configured strategy, unless it is already cached.
+func (s *Service) EnsureChapterThumb(ctx context.Context, chapterID string) (Output, error) {
+	source, err := s.chapterThumbSource(ctx, chapterID)
+	if err != nil {
+		return Output{}, err
+	}
+
+	ref, err := media.ParseRef(source.paths[0])
+	if err != nil {
+		return Output{}, err
+	}
+	if ok, err := cacheUpToDate(source.cacheFile, ref.Path); err == nil && ok {
+		return Output{Path: source.cacheFile, Mime: s.thumbnailMime()}, nil
+	}
+
+	img, orientation, err := s.representativePage(ctx, source.paths)
+	if err != nil {
+		return Output{}, err
+	}
+
+	thumb := media.Orient(resizeToFit(img, s.thumbnail.MaxDimension), orientation)
+	output, err := s.storeThumbnail(ctx, source.cacheFile, thumb)
+	if err == nil && output.Path != "" {
+		s.removeStaleChapterThumbs(chapterID, output.Path)
+	}
+	return output, err
+}
+
+// removeStaleChapterThumbs deletes the chapter's thumbnails other than
+// current, left behind when its pages, their checksums or the thumbnail
+// settings changed.
+func (s *Service) removeStaleChapterThumbs(chapterID string, current string) {
+	prefix := sanitizeFilename(chapterID) + "-"
+	entries, err := os.ReadDir(filepath.Dir(current))
+	if err != nil {
+		return
+	}
+	for _, entry := range entries {
+		name := entry.Name()
+		if !strings.HasPrefix(name, prefix) || name == filepath.Base(current) {
+			continue
+		}
+		if err := os.Remove(filepath.Join(filepath.Dir(current), name)); err != nil && s.logger != nil {
+			s.logger.Warn("remove stale chapter thumbnail failed", "chapter_id", chapterID, "error", err)
+		}
+	}
+}
+
+// chapterThumbSource lists the candidate pages of a chapter thumbnail in
+// order of preference and where the thumbnail is cached. The page count is
+// part of the cache name so adding or removing pages picks a new page, and
+// so is the checksum of the preferred page once one has been computed, so
+// replacing the page regenerates the thumbnail even if its mtime is older.
+func (s *Service) chapterThumbSource(ctx context.Context, chapterID string) (chapterPageSource, error) {
+	if s == nil || s.db == nil {
+		return chapterPageSource{}, fmt.Errorf("image service not initialized")
+	}
+
+	var pageCount int
+	if err := s.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM page WHERE chapter_id = ?`, chapterID).Scan(&pageCount); err != nil {
+		return chapterPageSource{}, err
+	}
+	if pageCount == 0 {
+		return chapterPageSource{}, fmt.Errorf("chapter %q: %w", chapterID, ErrNoPages)
+	}
+
+	strategy := s.chapterThumbStrategy()
+	offset, limit := 0, 1
+	switch strategy {
+	case ChapterThumbMiddle:
+		offset = pageCount / 2
+	case ChapterThumbFirstColor:
+		limit = maxColorProbePages
+	}
+
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT path, COALESCE(checksum, '')
+		FROM page
+		WHERE chapter_id = ?
+		ORDER BY page_index ASC
+		LIMIT ? OFFSET ?
+	`, chapterID, limit, offset)
+	if err != nil {
+		return chapterPageSource{}, err
+	}
+	defer rows.Close()
+
+	var paths []string
+	var checksum string
+	for rows.Next() {
+		var path, pageChecksum string
+		if err := rows.Scan(&path, &pageChecksum); err != nil {
+			return chapterPageSource{}, err
+		}
+		if len(paths) == 0 {
+			checksum = pageChecksum
+		}
+		paths = append(paths, path)
+	}
+	if err := rows.Err(); err != nil {
+		return chapterPageSource{}, err
+	}
+	if len(paths) == 0 {
+		return chapterPageSource{}, sql.ErrNoRows
+	}
+
+	key := fmt.Sprintf("%s-%s-%d", chapterID, strategy, pageCount)
+	if checksum != "" {
+		key += "-" + checksum[:min(len(checksum), 16)]
+	}
+	return chapterPageSource{
+		paths:     paths,
+		cacheFile: filepath.Join(s.cachePath, "chapters", s.thumbnailFilename(key)),
+	}, nil
+}
+
+func (s *Service) chapterThumbStrategy() string {
+	if ValidChapterThumbStrategy(s.thumbnail.ChapterPage) {
+		return s.thumbnail.ChapterPage
+	}
+	return ChapterThumbMiddle
+}
+
+// representativePage decodes the first candidate, or with several
+// candidates the first one in color, falling back to the first. The EXIF
+// orientation of the page is returned alongside it.
+func (s *Service) representativePage(ctx context.Context, paths []string) (image.Image, int, error) {
+	var first image.Image
+	var firstOrientation int
+	for i, path := range paths {
+		img, orientation, err := media.DecodeOriented(path)
+		if err != nil {
+			if i == 0 {
+				return nil, 0, err
+			}
+			continue
+		}
+		if err := ctx.Err(); err != nil {
+			return nil, 0, err
+		}
+		if len(paths) == 1 || !isGrayscale(img) {
+			return img, orientation, nil
+		}
+		if first == nil {
+			first, firstOrientation = img, orientation
+		}
+	}
+	return first, firstOrientation, nil
+}
+
+// isGrayscale samples a grid of pixels and treats the image as grayscale
+// unless more than one in fifty of them is clearly colored.
+func isGrayscale(img image.Image) bool {
+	const grid = 32
+	bounds := img.Bounds()
+	if bounds.Empty() {
+		return true
+	}
+
+	colored := 0
+	for gy := 0; gy < grid; gy++ {
+		y := bounds.Min.Y + gy*bounds.Dy()/grid
+		for gx := 0; gx < grid; gx++ {
+			x := bounds.Min.X + gx*bounds.Dx()/grid
+			r, g, b, _ := img.At(x, y).RGBA()
+			r, g, b = r>>8, g>>8, b>>8
+			if max(r, g, b)-min(r, g, b) > colorfulChannelSpread {
+				colored++
+			}
+		}
+	}
+	return colored*50 <= grid*grid
+}
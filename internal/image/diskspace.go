@@ -0,0 +1,64 @@
+package image
+
+import (
+	"fmt"
+)
+
+// errCacheLowSpace marks cache writes skipped because the cache volume is
+// below its minimum free space.
+var errCacheLowSpace = fmt.Errorf("%w: free disk space below minimum", errCacheWrite)
+
+// ConfigureCacheMinFree makes the service stop writing to the cache while
+// the volume holding it has fewer than minFree bytes available. Zero turns
+// the check off.
+func (s *Service) ConfigureCacheMinFree(minFree int64) {
+	if s == nil {
+		return
+	}
+	s.cacheMinFree = minFree
+}
+
+// checkCacheSpace returns errCacheLowSpace when the cache volume is below
+// the configured minimum free space. Free space that cannot be determined,
+// on platforms without a check or when the query fails, lets writes go
+// ahead.
+func (s *Service) checkCacheSpace() error {
+	if s.cacheMinFree <= 0 {
+		return nil
+	}
+	free, ok := diskFreeBytes(s.cachePath)
+	if !ok {
+		return nil
+	}
+	if free < uint64(s.cacheMinFree) {
+		s.cacheSpaceLow(free)
+		return errCacheLowSpace
+	}
+	s.cacheSpaceRecovered()
+	return nil
+}
+
+// cacheSpaceLow logs the first write skipped for lack of space; later ones
+// stay quiet until there is room again.
+func (s *Service) cacheSpaceLow(free uint64) {
+	s.cacheMu.Lock()
+	first := !s.cacheLowSpace
+	s.cacheLowSpace = true
+	s.cacheMu.Unlock()
+
+	if first && s.logger != nil {
+		s.logger.Warn("cache volume low on free space, serving images uncached",
+			"path", s.cachePath, "free_bytes", free, "min_free_bytes", s.cacheMinFree)
+	}
+}
+
+func (s *Service) cacheSpaceRecovered() {
+	s.cacheMu.Lock()
+	recovered := s.cacheLowSpace
+	s.cacheLowSpace = false
+	s.cacheMu.Unlock()
+
+	if recovered && s.logger != nil {
+		s.logger.Info("cache volume has free space again, caching resumed", "path", s.cachePath)
+	}
+}
@@ -0,0 +1,15 @@
+//go:build unix
+
+package image
+
+import "syscall"
+
+// diskFreeBytes reports the space available to unprivileged users on the
+// volume holding path.
+func diskFreeBytes(path string) (uint64, bool) {
+	var stat syscall.Statfs_t
+	if err := syscall.Statfs(path, &stat); err != nil {
+		return 0, false
+	}
+	return uint64(stat.Bavail) * uint64(stat.Bsize), true
+}
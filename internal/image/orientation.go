@@ -0,0 +1,92 @@
+package image
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"image/jpeg"
+	"io"
+	"os"
+	"path/filepath"
+
+	"mynewmangaui/internal/media"
+)
+
+// uprightPageQuality is the JPEG quality of pages re-encoded upright; it is
+// high because the result replaces the original page, not a thumbnail.
+const uprightPageQuality = 92
+
+// CachedUprightPage returns the upright copy of a page if one was already
+// made. key must change whenever the page content does.
+func (s *Service) CachedUprightPage(key string) (string, bool) {
+	if s == nil {
+		return "", false
+	}
+	target := s.uprightTarget(key)
+	if _, err := os.Stat(target); err != nil {
+		return "", false
+	}
+	return target, true
+}
+
+// UprightPage re-encodes the page at pathRef as a JPEG with its EXIF
+// orientation applied, for clients that do not honor the tag, and caches
+// the result under key. When the cache cannot be written the result is
+// returned in memory instead.
+func (s *Service) UprightPage(ctx context.Context, pathRef string, key string) (Output, error) {
+	if s == nil {
+		return Output{}, fmt.Errorf("image service not initialized")
+	}
+	target := s.uprightTarget(key)
+	if _, err := os.Stat(target); err == nil {
+		return Output{Path: target, Mime: "image/jpeg"}, nil
+	}
+
+	img, orientation, err := media.DecodeOriented(pathRef)
+	if err != nil {
+		return Output{}, err
+	}
+	if err := ctx.Err(); err != nil {
+		return Output{}, err
+	}
+	upright := media.Orient(img, orientation)
+
+	err = s.writeUprightPage(target, func(w io.Writer) error {
+		return jpeg.Encode(w, upright, &jpeg.Options{Quality: uprightPageQuality})
+	})
+	if err == nil {
+		s.cacheWriteSucceeded()
+		return Output{Path: target, Mime: "image/jpeg"}, nil
+	}
+	s.cacheWriteFailed("page", err)
+
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, upright, &jpeg.Options{Quality: uprightPageQuality}); err != nil {
+		return Output{}, err
+	}
+	return Output{Mime: "image/jpeg", Data: buf.Bytes()}, nil
+}
+
+func (s *Service) writeUprightPage(target string, encode func(io.Writer) error) error {
+	tempDir, err := s.cacheWorkDir(target, "upright-*")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tempDir)
+
+	output := filepath.Join(tempDir, "page.jpg")
+	if err := encodeFile(output, encode); err != nil {
+		return fmt.Errorf("%w: %v", errCacheWrite, err)
+	}
+	if err := os.Rename(output, target); err != nil {
+		return fmt.Errorf("%w: %v", errCacheWrite, err)
+	}
+	return nil
+}
+
+func (s *Service) uprightTarget(key string) string {
+	sum := sha1.Sum([]byte(key + "|upright"))
+	return filepath.Join(s.cachePath, "pages", hex.EncodeToString(sum[:])+".jpg")
+}
@@ -0,0 +1,77 @@
+package image
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+
+	"mynewmangaui/internal/media"
+)
+
+// pageThumbSource resolves a page and where its thumbnail is cached. The
+// page size is part of the cache name, so a replaced page whose mtime is
+// older than the cached thumbnail still gets a new one.
+func (s *Service) pageThumbSource(ctx context.Context, chapterID string, pageIndex int) (string, string, error) {
+	if s == nil || s.db == nil {
+		return "", "", fmt.Errorf("image service not initialized")
+	}
+
+	var pageID, path string
+	var sizeBytes int64
+	if err := s.db.QueryRowContext(ctx, `
+		SELECT id, path, COALESCE(size_bytes, 0)
+		FROM page
+		WHERE chapter_id = ? AND page_index = ?
+	`, chapterID, pageIndex).Scan(&pageID, &path, &sizeBytes); err != nil {
+		return "", "", err
+	}
+
+	key := fmt.Sprintf("%s-%d", pageID, sizeBytes)
+	return path, filepath.Join(s.cachePath, "page-thumbs", s.thumbnailFilename(key)), nil
+}
+
+// CachedPageThumb returns the cached thumbnail of a page when it is still
+// current.
+func (s *Service) CachedPageThumb(ctx context.Context, chapterID string, pageIndex int) (string, bool) {
+	path, cacheFile, err := s.pageThumbSource(ctx, chapterID, pageIndex)
+	if err != nil {
+		return "", false
+	}
+	ref, err := media.ParseRef(path)
+	if err != nil {
+		return "", false
+	}
+	if ok, err := cacheUpToDate(cacheFile, ref.Path); err != nil || !ok {
+		return "", false
+	}
+	return cacheFile, true
+}
+
+// EnsurePageThumb builds the thumbnail of a single page, for page strips and
+// prefetching, unless it is already cached. A missing page is returned as
+// sql.ErrNoRows.
+func (s *Service) EnsurePageThumb(ctx context.Context, chapterID string, pageIndex int) (Output, error) {
+	path, cacheFile, err := s.pageThumbSource(ctx, chapterID, pageIndex)
+	if err != nil {
+		return Output{}, err
+	}
+
+	ref, err := media.ParseRef(path)
+	if err != nil {
+		return Output{}, err
+	}
+	if ok, err := cacheUpToDate(cacheFile, ref.Path); err == nil && ok {
+		return Output{Path: cacheFile, Mime: s.thumbnailMime()}, nil
+	}
+
+	img, orientation, err := media.DecodeOriented(path)
+	if err != nil {
+		return Output{}, err
+	}
+	if err := ctx.Err(); err != nil {
+		return Output{}, err
+	}
+
+	thumb := media.Orient(resizeToFit(img, s.thumbnail.MaxDimension), orientation)
+	return s.storeThumbnail(ctx, cacheFile, thumb)
+}
@@ -7,10 +7,15 @@ import (
 	"image"
 	"image/color"
 	"image/jpeg"
+	"image/png"
+	"io"
 	"log/slog"
 	"os"
+	"os/exec"
 	"path/filepath"
+	"strconv"
 	"strings"
+	"sync"
 
 	xdraw "golang.org/x/image/draw"
 
@@ -18,9 +23,31 @@ import (
 )
 
 type Service struct {
-	db        *sql.DB
-	cachePath string
-	logger    *slog.Logger
+	db          *sql.DB
+	cachePath   string
+	logger      *slog.Logger
+	pageFormats []pageFormat
+	thumbnail   ThumbnailOptions
+
+	// cacheMinFree is the free space, in bytes, the cache volume must keep
+	// for cache writes to go ahead.
+	cacheMinFree int64
+
+	cacheMu       sync.Mutex
+	cacheFailing  bool
+	cacheLowSpace bool
+}
+
+// ThumbnailOptions controls how cover and chapter thumbnails are encoded.
+type ThumbnailOptions struct {
+	// Format is "jpeg" or "webp"; webp is produced with Encoder.
+	Format       string
+	Quality      int
+	MaxDimension int
+	Encoder      string
+	// ChapterPage is the strategy picking the page of chapter thumbnails,
+	// one of the ChapterThumb constants.
+	ChapterPage string
 }
 
 func NewService(db *sql.DB, cachePath string, logger *slog.Logger) *Service {
@@ -28,9 +55,19 @@ func NewService(db *sql.DB, cachePath string, logger *slog.Logger) *Service {
 		db:        db,
 		cachePath: cachePath,
 		logger:    logger,
+		thumbnail: ThumbnailOptions{Format: "jpeg", Quality: 82, MaxDimension: 512},
 	}
 }
 
+// ConfigureThumbnails replaces the thumbnail encoding settings. They are
+// part of the cache file name, so changing them regenerates thumbnails.
+func (s *Service) ConfigureThumbnails(options ThumbnailOptions) {
+	if s == nil {
+		return
+	}
+	s.thumbnail = options
+}
+
 func (s *Service) WarmCache(ctx context.Context) error {
 	if s == nil || s.db == nil {
 		return nil
@@ -54,22 +91,113 @@ func (s *Service) WarmCache(ctx context.Context) error {
 	return rows.Err()
 }
 
-func (s *Service) EnsureMangaCoverThumb(ctx context.Context, mangaID string) (string, error) {
+// CachedMangaCoverThumb returns the cached cover thumbnail when it is still
+// current, letting callers skip decoding work entirely.
+func (s *Service) CachedMangaCoverThumb(ctx context.Context, mangaID string) (string, bool) {
+	coverPath, cacheFile, err := s.coverThumbSource(ctx, mangaID)
+	if err != nil {
+		return "", false
+	}
+	ref, err := media.ParseRef(coverPath)
+	if err != nil {
+		return "", false
+	}
+	if ok, err := cacheUpToDate(cacheFile, ref.Path); err != nil || !ok {
+		return "", false
+	}
+	return cacheFile, true
+}
+
+func (s *Service) EnsureMangaCoverThumb(ctx context.Context, mangaID string) (Output, error) {
+	coverPath, cacheFile, err := s.coverThumbSource(ctx, mangaID)
+	if err != nil {
+		return Output{}, err
+	}
+
+	ref, err := media.ParseRef(coverPath)
+	if err != nil {
+		return Output{}, err
+	}
+
+	if ok, err := cacheUpToDate(cacheFile, ref.Path); err == nil && ok {
+		return Output{Path: cacheFile, Mime: s.thumbnailMime()}, nil
+	}
+
+	img, orientation, err := media.DecodeOriented(coverPath)
+	if err != nil {
+		return Output{}, err
+	}
+	if err := ctx.Err(); err != nil {
+		return Output{}, err
+	}
+
+	thumb := media.Orient(resizeToFit(img, s.thumbnail.MaxDimension), orientation)
+	return s.storeThumbnail(ctx, cacheFile, thumb)
+}
+
+// writeThumbnail encodes img to a temporary file next to target and moves
+// it into place, so readers never see a partially written thumbnail.
+// Failures to write the cache wrap errCacheWrite.
+func (s *Service) writeThumbnail(ctx context.Context, target string, img image.Image) error {
+	tempDir, err := s.cacheWorkDir(target, "thumb-*")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tempDir)
+
+	output := filepath.Join(tempDir, "thumb")
+	if s.thumbnail.Format == "webp" {
+		// There is no WebP encoder in the standard library, so the image
+		// goes through a lossless PNG handed to the external encoder.
+		input := filepath.Join(tempDir, "thumb.png")
+		if err := encodeFile(input, func(w io.Writer) error { return png.Encode(w, img) }); err != nil {
+			return fmt.Errorf("%w: %v", errCacheWrite, err)
+		}
+		quality := strconv.Itoa(s.thumbnail.Quality)
+		if out, err := exec.CommandContext(ctx, s.thumbnail.Encoder, "-quiet", "-q", quality, input, "-o", output).CombinedOutput(); err != nil {
+			return fmt.Errorf("encode webp thumbnail: %w: %s", err, strings.TrimSpace(string(out)))
+		}
+	} else if err := encodeFile(output, func(w io.Writer) error {
+		return jpeg.Encode(w, img, &jpeg.Options{Quality: s.thumbnail.Quality})
+	}); err != nil {
+		return fmt.Errorf("%w: %v", errCacheWrite, err)
+	}
+	if err := os.Rename(output, target); err != nil {
+		return fmt.Errorf("%w: %v", errCacheWrite, err)
+	}
+	return nil
+}
+
+func encodeFile(path string, encode func(io.Writer) error) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := encode(file); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// coverThumbSource resolves the image a manga's cover thumbnail is built
+// from, falling back to its first page, and where the thumbnail is cached.
+func (s *Service) coverThumbSource(ctx context.Context, mangaID string) (string, string, error) {
 	if s == nil || s.db == nil {
-		return "", fmt.Errorf("image service not initialized")
+		return "", "", fmt.Errorf("image service not initialized")
 	}
 
 	var coverPath string
 	var pageCount int
 	if err := s.db.QueryRowContext(ctx, `SELECT cover_path, page_count FROM manga WHERE id = ?`, mangaID).Scan(&coverPath, &pageCount); err != nil {
 		if err == sql.ErrNoRows {
-			return "", fmt.Errorf("manga %q not found", mangaID)
+			return "", "", fmt.Errorf("manga %q not found", mangaID)
 		}
-		return "", err
+		return "", "", err
 	}
 
 	if coverPath == "" && pageCount == 0 {
-		return "", fmt.Errorf("manga %q has no cover source", mangaID)
+		return "", "", fmt.Errorf("manga %q has no cover source", mangaID)
 	}
 	if coverPath == "" {
 		if err := s.db.QueryRowContext(ctx, `
@@ -77,43 +205,24 @@ func (s *Service) EnsureMangaCoverThumb(ctx context.Context, mangaID string) (st
 			FROM page p
 			INNER JOIN chapter c ON c.id = p.chapter_id
 			WHERE c.manga_id = ?
-			ORDER BY c.chapter_number ASC, c.title ASC, p.page_index ASC
+			ORDER BY c.chapter_number ASC, c.title ASC, c.id ASC, p.page_index ASC
 			LIMIT 1
 		`, mangaID).Scan(&coverPath); err != nil {
-			return "", err
+			return "", "", err
 		}
 	}
 
-	ref, err := media.ParseRef(coverPath)
-	if err != nil {
-		return "", err
-	}
-
-	cacheFile := filepath.Join(s.cachePath, "covers", sanitizeFilename(mangaID)+".jpg")
-	if ok, err := cacheUpToDate(cacheFile, ref.Path); err == nil && ok {
-		return cacheFile, nil
-	}
-
-	if err := os.MkdirAll(filepath.Dir(cacheFile), 0o755); err != nil {
-		return "", err
-	}
-
-	img, err := media.Decode(coverPath)
-	if err != nil {
-		return "", err
-	}
-
-	thumb := resizeToWidth(img, 360)
-	file, err := os.Create(cacheFile)
-	if err != nil {
-		return "", err
-	}
-	defer file.Close()
+	return coverPath, filepath.Join(s.cachePath, "covers", s.thumbnailFilename(mangaID)), nil
+}
 
-	if err := jpeg.Encode(file, thumb, &jpeg.Options{Quality: 82}); err != nil {
-		return "", err
+// thumbnailFilename keys a cached thumbnail by the encoding settings as well
+// as the manga, so a settings change never serves stale thumbnails.
+func (s *Service) thumbnailFilename(mangaID string) string {
+	ext := ".jpg"
+	if s.thumbnail.Format == "webp" {
+		ext = ".webp"
 	}
-	return cacheFile, nil
+	return fmt.Sprintf("%s-%s-q%d-%d%s", sanitizeFilename(mangaID), s.thumbnail.Format, s.thumbnail.Quality, s.thumbnail.MaxDimension, ext)
 }
 
 func cacheUpToDate(cacheFile string, sourceFile string) (bool, error) {
@@ -125,20 +234,25 @@ func cacheUpToDate(cacheFile string, sourceFile string) (bool, error) {
 		return false, err
 	}
 
-	sourceInfo, err := os.Stat(sourceFile)
+	sourceInfo, err := media.Stat(sourceFile)
 	if err != nil {
 		return false, err
 	}
 	return !cacheInfo.ModTime().Before(sourceInfo.ModTime()), nil
 }
 
-func resizeToWidth(src image.Image, width int) image.Image {
+// resizeToFit scales src down so neither side exceeds maxDimension.
+func resizeToFit(src image.Image, maxDimension int) image.Image {
 	bounds := src.Bounds()
-	if bounds.Dx() <= width {
+	if bounds.Dx() <= maxDimension && bounds.Dy() <= maxDimension {
 		return src
 	}
 
-	height := bounds.Dy() * width / bounds.Dx()
+	width, height := maxDimension, bounds.Dy()*maxDimension/bounds.Dx()
+	if bounds.Dy() > bounds.Dx() {
+		width, height = bounds.Dx()*maxDimension/bounds.Dy(), maxDimension
+	}
+	width, height = max(width, 1), max(height, 1)
 	dst := image.NewRGBA(image.Rect(0, 0, width, height))
 	fillBackground(dst)
 	xdraw.CatmullRom.Scale(dst, dst.Bounds(), src, bounds, xdraw.Over, nil)
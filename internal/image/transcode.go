@@ -0,0 +1,220 @@
+package image
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"image/png"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"mynewmangaui/internal/media"
+)
+
+// pageFormat describes a modern format pages can be transcoded to with an
+// external encoder.
+type pageFormat struct {
+	name    string
+	command string
+	args    func(input string, output string) []string
+}
+
+// PageFormatMimes lists the formats pages can be transcoded to, keyed by
+// the name used in the configuration.
+var PageFormatMimes = map[string]string{
+	"avif": "image/avif",
+	"webp": "image/webp",
+}
+
+var defaultPageEncoders = map[string]string{
+	"avif": "avifenc",
+	"webp": "cwebp",
+}
+
+// PageEncoder returns the command producing the named format, preferring
+// an override from encoders.
+func PageEncoder(name string, encoders map[string]string) string {
+	if command := encoders[name]; command != "" {
+		return command
+	}
+	return defaultPageEncoders[name]
+}
+
+// ConfigurePageFormats enables transcoding JPEG and PNG pages into the given
+// formats, in order of preference, using the commands from PageEncoder.
+func (s *Service) ConfigurePageFormats(formats []string, encoders map[string]string) {
+	if s == nil {
+		return
+	}
+	s.pageFormats = s.pageFormats[:0]
+	for _, name := range formats {
+		format := pageFormat{name: name, command: PageEncoder(name, encoders)}
+		switch name {
+		case "avif":
+			format.args = func(input string, output string) []string {
+				return []string{"--speed", "8", input, output}
+			}
+		case "webp":
+			format.args = func(input string, output string) []string {
+				return []string{"-quiet", "-q", "80", input, "-o", output}
+			}
+		default:
+			continue
+		}
+		s.pageFormats = append(s.pageFormats, format)
+	}
+}
+
+// PageFormats returns the configured transcoding targets in order of
+// preference.
+func (s *Service) PageFormats() []string {
+	if s == nil {
+		return nil
+	}
+	names := make([]string, 0, len(s.pageFormats))
+	for _, format := range s.pageFormats {
+		names = append(names, format.name)
+	}
+	return names
+}
+
+// Transcodes reports whether pages of the given mime type are transcoded
+// for clients that accept a configured format.
+func (s *Service) Transcodes(mime string) bool {
+	if s == nil || len(s.pageFormats) == 0 {
+		return false
+	}
+	return mime == "image/jpeg" || mime == "image/png"
+}
+
+// CachedPage returns the transcoded copy of a page if one was already made.
+// key must change whenever the page content does.
+func (s *Service) CachedPage(key string, name string) (string, bool) {
+	format, ok := s.pageFormat(name)
+	if !ok {
+		return "", false
+	}
+	target := s.transcodeTarget(key, format)
+	if _, err := os.Stat(target); err != nil {
+		return "", false
+	}
+	return target, true
+}
+
+// TranscodePage converts the page at pathRef into the named format and
+// caches the result under key. Pages with an EXIF orientation are turned
+// upright first, since encoders drop or ignore the tag. The encoder is
+// killed if ctx is cancelled. When the cache cannot be written the encoder
+// works in the system temp directory and the result is returned in memory
+// instead.
+func (s *Service) TranscodePage(ctx context.Context, pathRef string, sourceMime string, orientation int, key string, name string) (Output, error) {
+	format, ok := s.pageFormat(name)
+	if !ok {
+		return Output{}, fmt.Errorf("page format %q is not enabled", name)
+	}
+	mime := PageFormatMimes[format.name]
+
+	target := s.transcodeTarget(key, format)
+	if _, err := os.Stat(target); err == nil {
+		return Output{Path: target, Mime: mime}, nil
+	}
+
+	tempDir, cacheErr := s.cacheWorkDir(target, "transcode-*")
+	if cacheErr != nil {
+		s.cacheWriteFailed("page", cacheErr)
+		var err error
+		if tempDir, err = os.MkdirTemp("", "transcode-*"); err != nil {
+			return Output{}, fmt.Errorf("create transcode dir: %w", err)
+		}
+	}
+	defer os.RemoveAll(tempDir)
+
+	// Encoders pick the decoder from the extension, and pages inside
+	// archives have no file of their own, so the source is copied out.
+	input := filepath.Join(tempDir, "source.jpg")
+	if sourceMime == "image/png" {
+		input = filepath.Join(tempDir, "source.png")
+	}
+	if orientation > media.OrientationNormal {
+		input = filepath.Join(tempDir, "source.png")
+		if err := writeUprightSource(ctx, pathRef, input); err != nil {
+			return Output{}, err
+		}
+	} else if err := copyPageSource(ctx, pathRef, input); err != nil {
+		return Output{}, err
+	}
+
+	output := filepath.Join(tempDir, "page."+format.name)
+	if out, err := exec.CommandContext(ctx, format.command, format.args(input, output)...).CombinedOutput(); err != nil {
+		return Output{}, fmt.Errorf("transcode page to %s: %w: %s", format.name, err, strings.TrimSpace(string(out)))
+	}
+	if info, err := os.Stat(output); err != nil || info.Size() == 0 {
+		return Output{}, fmt.Errorf("transcode page to %s: encoder produced no output", format.name)
+	}
+	if cacheErr == nil {
+		err := os.Rename(output, target)
+		if err == nil {
+			s.cacheWriteSucceeded()
+			return Output{Path: target, Mime: mime}, nil
+		}
+		s.cacheWriteFailed("page", err)
+	}
+
+	data, err := os.ReadFile(output)
+	if err != nil {
+		return Output{}, fmt.Errorf("read transcoded page: %w", err)
+	}
+	return Output{Mime: mime, Data: data}, nil
+}
+
+func (s *Service) pageFormat(name string) (pageFormat, bool) {
+	if s == nil {
+		return pageFormat{}, false
+	}
+	for _, format := range s.pageFormats {
+		if format.name == name {
+			return format, true
+		}
+	}
+	return pageFormat{}, false
+}
+
+func (s *Service) transcodeTarget(key string, format pageFormat) string {
+	sum := sha1.Sum([]byte(key + "|" + format.name))
+	return filepath.Join(s.cachePath, "pages", hex.EncodeToString(sum[:])+"."+format.name)
+}
+
+// writeUprightSource decodes the page at pathRef, applies its EXIF
+// orientation and writes the result as a lossless PNG.
+func writeUprightSource(ctx context.Context, pathRef string, target string) error {
+	img, orientation, err := media.DecodeOriented(pathRef)
+	if err != nil {
+		return err
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return encodeFile(target, func(w io.Writer) error { return png.Encode(w, media.Orient(img, orientation)) })
+}
+
+func copyPageSource(ctx context.Context, pathRef string, target string) error {
+	rc, _, err := media.Open(pathRef)
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+
+	file, err := os.Create(target)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(file, media.ContextReader(ctx, rc)); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
@@ -0,0 +1,258 @@
+package media
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"image"
+	"image/draw"
+	"io"
+
+	"golang.org/x/image/webp"
+)
+
+var errInvalidAnimatedWebP = errors.New("invalid animated webp")
+
+// PageInfo is what a scan records about a page image.
+type PageInfo struct {
+	// Width and Height are those of the page displayed upright; for
+	// animated images they are the canvas size.
+	Width  int
+	Height int
+	// Orientation is the page's EXIF orientation.
+	Orientation int
+	// Animated is set for animated WebP, GIF and APNG pages.
+	Animated bool
+}
+
+// DecodePageInfo reads the dimensions, EXIF orientation and whether the
+// image at raw is animated, without decoding its pixels.
+func DecodePageInfo(raw string) (PageInfo, error) {
+	rc, _, err := Open(raw)
+	if err != nil {
+		return PageInfo{}, err
+	}
+	defer rc.Close()
+
+	br := bufio.NewReaderSize(rc, exifPeekSize)
+	head, _ := br.Peek(exifPeekSize)
+	orientation := ExifOrientation(bytes.NewReader(head))
+	cfg, _, err := image.DecodeConfig(br)
+	if err != nil {
+		return PageInfo{}, err
+	}
+	if swapsAxes(orientation) {
+		cfg.Width, cfg.Height = cfg.Height, cfg.Width
+	}
+	return PageInfo{
+		Width:       cfg.Width,
+		Height:      cfg.Height,
+		Orientation: orientation,
+		Animated:    isAnimated(head),
+	}, nil
+}
+
+// isAnimated reports whether the leading bytes of an image, as much of it
+// as was peeked, show it to be animated: a WebP with the animation flag, a
+// PNG with an acTL chunk for more than one frame, or a GIF with a looping
+// extension or a second frame.
+func isAnimated(head []byte) bool {
+	switch {
+	case isWebP(head):
+		return webPAnimated(head)
+	case bytes.HasPrefix(head, []byte("\x89PNG\r\n\x1a\n")):
+		return pngAnimated(head[8:])
+	case bytes.HasPrefix(head, []byte("GIF87a")), bytes.HasPrefix(head, []byte("GIF89a")):
+		return gifAnimated(head)
+	}
+	return false
+}
+
+func isWebP(head []byte) bool {
+	return len(head) >= 16 && string(head[:4]) == "RIFF" && string(head[8:12]) == "WEBP"
+}
+
+// webPAnimated checks the animation flag of the VP8X chunk, which comes
+// first in extended WebP files.
+func webPAnimated(head []byte) bool {
+	return isWebP(head) && len(head) >= 21 && string(head[12:16]) == "VP8X" && head[20]&0x02 != 0
+}
+
+// pngAnimated looks for the acTL chunk APNG files carry before their first
+// IDAT chunk.
+func pngAnimated(chunks []byte) bool {
+	for len(chunks) >= 12 {
+		length := int(binary.BigEndian.Uint32(chunks))
+		kind := string(chunks[4:8])
+		switch kind {
+		case "acTL":
+			return length >= 8 && len(chunks) >= 16 && binary.BigEndian.Uint32(chunks[8:]) > 1
+		case "IDAT", "IEND":
+			return false
+		}
+		if len(chunks) < 12+length {
+			return false
+		}
+		chunks = chunks[12+length:]
+	}
+	return false
+}
+
+// gifAnimated walks the GIF blocks that were peeked, stopping at the first
+// looping (NETSCAPE2.0) extension or second image.
+func gifAnimated(head []byte) bool {
+	if len(head) < 13 {
+		return false
+	}
+	pos := 13
+	if flags := head[10]; flags&0x80 != 0 {
+		pos += 3 << (flags&0x07 + 1)
+	}
+	frames := 0
+	for pos < len(head) {
+		switch head[pos] {
+		case 0x21:
+			if pos+2 >= len(head) {
+				return false
+			}
+			if head[pos+1] == 0xFF && pos+14 <= len(head) && string(head[pos+3:pos+14]) == "NETSCAPE2.0" {
+				return true
+			}
+			pos = skipGIFSubBlocks(head, pos+2)
+		case 0x2C:
+			frames++
+			if frames > 1 {
+				return true
+			}
+			if pos+10 > len(head) {
+				return false
+			}
+			flags := head[pos+9]
+			pos += 10
+			if flags&0x80 != 0 {
+				pos += 3 << (flags&0x07 + 1)
+			}
+			// The LZW minimum code size precedes the image data.
+			pos = skipGIFSubBlocks(head, pos+1)
+		default:
+			return false
+		}
+		if pos < 0 {
+			return false
+		}
+	}
+	return false
+}
+
+// skipGIFSubBlocks returns the position after the data sub-blocks starting
+// at pos, or -1 when they run past the peeked bytes.
+func skipGIFSubBlocks(head []byte, pos int) int {
+	for pos < len(head) {
+		size := int(head[pos])
+		pos++
+		if size == 0 {
+			return pos
+		}
+		pos += size
+	}
+	return -1
+}
+
+// decodeImage decodes the image br holds. The standard decoders only
+// return the first frame of an animation, as wanted for thumbnails, but
+// golang.org/x/image/webp cannot decode animated WebP at all, and a GIF's
+// first frame may cover only part of its canvas; both come back as the
+// first frame drawn onto a transparent canvas of the full size.
+func decodeImage(br *bufio.Reader) (image.Image, error) {
+	head, _ := br.Peek(exifPeekSize)
+	if webPAnimated(head) {
+		data, err := io.ReadAll(br)
+		if err != nil {
+			return nil, err
+		}
+		return decodeAnimatedWebPFrame(data)
+	}
+	img, _, err := image.Decode(br)
+	if err != nil {
+		return nil, err
+	}
+	if len(head) >= 10 && bytes.HasPrefix(head, []byte("GIF")) {
+		width := int(binary.LittleEndian.Uint16(head[6:]))
+		height := int(binary.LittleEndian.Uint16(head[8:]))
+		if canvas := image.Rect(0, 0, width, height); !canvas.Empty() && img.Bounds() != canvas {
+			return drawOnCanvas(canvas, img, image.Point{}), nil
+		}
+	}
+	return img, nil
+}
+
+// decodeAnimatedWebPFrame decodes the first frame of an animated WebP. The
+// frame's own chunks are rewrapped as a still WebP for the decoder, then
+// placed at the frame's offset on the canvas.
+func decodeAnimatedWebPFrame(data []byte) (image.Image, error) {
+	if !isWebP(data) {
+		return nil, errInvalidAnimatedWebP
+	}
+	var canvas image.Rectangle
+	chunks := data[12:]
+	for len(chunks) >= 8 {
+		kind := string(chunks[:4])
+		size := int(binary.LittleEndian.Uint32(chunks[4:]))
+		if size < 0 || len(chunks) < 8+size {
+			return nil, errInvalidAnimatedWebP
+		}
+		payload := chunks[8 : 8+size]
+		switch kind {
+		case "VP8X":
+			if size < 10 {
+				return nil, errInvalidAnimatedWebP
+			}
+			canvas = image.Rect(0, 0, int(uint24(payload[4:]))+1, int(uint24(payload[7:]))+1)
+		case "ANMF":
+			if size < 16 || canvas.Empty() {
+				return nil, errInvalidAnimatedWebP
+			}
+			offset := image.Pt(int(uint24(payload))*2, int(uint24(payload[3:]))*2)
+			frame, err := webp.Decode(bytes.NewReader(stillWebP(payload[16:], payload[6:12])))
+			if err != nil {
+				return nil, err
+			}
+			return drawOnCanvas(canvas, frame, frame.Bounds().Min.Sub(offset)), nil
+		}
+		chunks = chunks[8+size+size%2:]
+	}
+	return nil, errInvalidAnimatedWebP
+}
+
+// stillWebP wraps the chunks of one animation frame as a WebP file of its
+// own. Frames with an ALPH chunk need a VP8X header announcing the alpha
+// channel, with the frame's size taken from the ANMF header.
+func stillWebP(frame []byte, size []byte) []byte {
+	var body bytes.Buffer
+	body.WriteString("WEBP")
+	if bytes.HasPrefix(frame, []byte("ALPH")) {
+		body.WriteString("VP8X")
+		body.Write([]byte{10, 0, 0, 0, 0x10, 0, 0, 0})
+		body.Write(size)
+	}
+	body.Write(frame)
+
+	var file bytes.Buffer
+	file.WriteString("RIFF")
+	binary.Write(&file, binary.LittleEndian, uint32(body.Len()))
+	file.Write(body.Bytes())
+	return file.Bytes()
+}
+
+func uint24(b []byte) uint32 {
+	return uint32(b[0]) | uint32(b[1])<<8 | uint32(b[2])<<16
+}
+
+// drawOnCanvas draws img onto a transparent canvas, with the point sp of
+// img at the canvas origin.
+func drawOnCanvas(canvas image.Rectangle, img image.Image, sp image.Point) image.Image {
+	dst := image.NewRGBA(canvas)
+	draw.Draw(dst, canvas, img, sp, draw.Src)
+	return dst
+}
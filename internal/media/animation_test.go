@@ -0,0 +1,156 @@
+package media
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// bitWriter packs values least significant bit first, as VP8L reads them.
+type bitWriter struct {
+	buf   []byte
+	nbits uint
+}
+
+func (w *bitWriter) write(value uint32, n uint) {
+	for i := range n {
+		if w.nbits%8 == 0 {
+			w.buf = append(w.buf, 0)
+		}
+		w.buf[len(w.buf)-1] |= byte(value>>i&1) << (w.nbits % 8)
+		w.nbits++
+	}
+}
+
+// vp8lSolid encodes a lossless WebP bitstream of one colour: every prefix
+// code holds a single symbol, so the pixels themselves take no bits.
+func vp8lSolid(width int, height int, c color.NRGBA) []byte {
+	var w bitWriter
+	w.write(0x2f, 8)
+	w.write(uint32(width-1), 14)
+	w.write(uint32(height-1), 14)
+	w.write(1, 1) // alpha is used
+	w.write(0, 3) // version
+	w.write(0, 1) // no transforms
+	w.write(0, 1) // no color cache
+	w.write(0, 1) // no meta prefix codes
+	for _, v := range []uint8{c.G, c.R, c.B, c.A} {
+		w.write(1, 1) // simple code
+		w.write(0, 1) // of one symbol
+		w.write(1, 1) // eight bits wide
+		w.write(uint32(v), 8)
+	}
+	w.write(0b0001, 4) // distance code: simple, one one-bit symbol, 0
+	return w.buf
+}
+
+// webPChunk frames a RIFF chunk, padded to an even length.
+func webPChunk(kind string, payload []byte) []byte {
+	chunk := binary.LittleEndian.AppendUint32([]byte(kind), uint32(len(payload)))
+	chunk = append(chunk, payload...)
+	if len(payload)%2 == 1 {
+		chunk = append(chunk, 0)
+	}
+	return chunk
+}
+
+func webPFile(chunks ...[]byte) []byte {
+	body := []byte("WEBP")
+	for _, chunk := range chunks {
+		body = append(body, chunk...)
+	}
+	return append(binary.LittleEndian.AppendUint32([]byte("RIFF"), uint32(len(body))), body...)
+}
+
+func appendUint24(b []byte, v int) []byte {
+	return append(b, byte(v), byte(v>>8), byte(v>>16))
+}
+
+// webPFrame is one frame of an animated WebP: a solid rectangle at an
+// even offset on the canvas.
+type webPFrame struct {
+	rect  image.Rectangle
+	color color.NRGBA
+}
+
+func animatedWebP(width int, height int, frames ...webPFrame) []byte {
+	header := []byte{0x02 | 0x10, 0, 0, 0} // animation and alpha
+	header = appendUint24(appendUint24(header, width-1), height-1)
+	chunks := [][]byte{webPChunk("VP8X", header), webPChunk("ANIM", make([]byte, 6))}
+	for _, frame := range frames {
+		payload := appendUint24(appendUint24(nil, frame.rect.Min.X/2), frame.rect.Min.Y/2)
+		payload = appendUint24(appendUint24(payload, frame.rect.Dx()-1), frame.rect.Dy()-1)
+		payload = appendUint24(payload, 100) // duration in milliseconds
+		payload = append(payload, 0)
+		payload = append(payload, webPChunk("VP8L", vp8lSolid(frame.rect.Dx(), frame.rect.Dy(), frame.color))...)
+		chunks = append(chunks, webPChunk("ANMF", payload))
+	}
+	return webPFile(chunks...)
+}
+
+func TestAnimatedWebP(t *testing.T) {
+	red := color.NRGBA{R: 0xff, A: 0xff}
+	blue := color.NRGBA{B: 0xff, A: 0xff}
+	dir := t.TempDir()
+	animated := filepath.Join(dir, "animated.webp")
+	data := animatedWebP(40, 30,
+		webPFrame{rect: image.Rect(10, 10, 30, 20), color: red},
+		webPFrame{rect: image.Rect(0, 0, 40, 30), color: blue},
+	)
+	if err := os.WriteFile(animated, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	still := filepath.Join(dir, "still.webp")
+	if err := os.WriteFile(still, webPFile(webPChunk("VP8L", vp8lSolid(12, 16, blue))), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	for path, want := range map[string]PageInfo{
+		animated: {Width: 40, Height: 30, Orientation: OrientationNormal, Animated: true},
+		still:    {Width: 12, Height: 16, Orientation: OrientationNormal},
+	} {
+		got, err := DecodePageInfo(FileRef(path))
+		if err != nil {
+			t.Fatalf("%s: %v", filepath.Base(path), err)
+		}
+		if got != want {
+			t.Errorf("%s page info = %+v, want %+v", filepath.Base(path), got, want)
+		}
+	}
+
+	// The first frame is drawn where it sits on the canvas, the same way
+	// every time.
+	var previous image.Image
+	for range 2 {
+		img, err := Decode(FileRef(animated))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if img.Bounds() != image.Rect(0, 0, 40, 30) {
+			t.Fatalf("decoded bounds = %v, want the canvas", img.Bounds())
+		}
+		for point, want := range map[image.Point]color.NRGBA{
+			{15, 15}: red,
+			{29, 19}: red,
+			{5, 5}:   {},
+			{35, 25}: {},
+		} {
+			if got := color.NRGBAModel.Convert(img.At(point.X, point.Y)); got != want {
+				t.Errorf("pixel %v = %v, want %v from the first frame", point, got, want)
+			}
+		}
+		if previous != nil && !bytes.Equal(previous.(*image.RGBA).Pix, img.(*image.RGBA).Pix) {
+			t.Error("decoding the animation twice gave different pixels")
+		}
+		previous = img
+	}
+
+	// Cut inside the first frame.
+	if _, err := decodeAnimatedWebPFrame(data[:60]); err == nil {
+		t.Error("decoded a truncated animation")
+	}
+}
@@ -0,0 +1,136 @@
+package media
+
+import (
+	"image"
+	"math"
+	"strings"
+
+	"golang.org/x/image/draw"
+)
+
+// blurhashSampleSize is the longest side covers are shrunk to before
+// hashing; a blurhash keeps only a handful of low frequencies, so more
+// pixels would only cost time.
+const blurhashSampleSize = 32
+
+const base83Chars = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz#$%*+,-.:;=?@[]^_{|}~"
+
+// Blurhash decodes the image at raw, upright, and returns its BlurHash
+// (https://blurha.sh), a short string clients render as a blurred
+// placeholder while the real image loads. Portrait images get 3x4
+// components and landscape ones 4x3.
+func Blurhash(raw string) (string, error) {
+	img, orientation, err := DecodeOriented(raw)
+	if err != nil {
+		return "", err
+	}
+	return EncodeBlurhash(Orient(img, orientation)), nil
+}
+
+// EncodeBlurhash returns the BlurHash of img.
+func EncodeBlurhash(img image.Image) string {
+	bounds := img.Bounds()
+	if bounds.Empty() {
+		return ""
+	}
+	xComponents, yComponents := 4, 3
+	if bounds.Dy() > bounds.Dx() {
+		xComponents, yComponents = 3, 4
+	}
+
+	width, height := bounds.Dx(), bounds.Dy()
+	if longest := max(width, height); longest > blurhashSampleSize {
+		width = max(1, width*blurhashSampleSize/longest)
+		height = max(1, height*blurhashSampleSize/longest)
+	}
+	sample := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.ApproxBiLinear.Scale(sample, sample.Bounds(), img, bounds, draw.Src, nil)
+
+	linear := make([][3]float64, width*height)
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			offset := sample.PixOffset(x, y)
+			linear[y*width+x] = [3]float64{
+				srgbToLinear(sample.Pix[offset]),
+				srgbToLinear(sample.Pix[offset+1]),
+				srgbToLinear(sample.Pix[offset+2]),
+			}
+		}
+	}
+
+	factors := make([][3]float64, 0, xComponents*yComponents)
+	for j := 0; j < yComponents; j++ {
+		for i := 0; i < xComponents; i++ {
+			normalisation := 2.0
+			if i == 0 && j == 0 {
+				normalisation = 1
+			}
+			var factor [3]float64
+			for y := 0; y < height; y++ {
+				for x := 0; x < width; x++ {
+					basis := math.Cos(math.Pi*float64(i)*float64(x)/float64(width)) *
+						math.Cos(math.Pi*float64(j)*float64(y)/float64(height))
+					pixel := linear[y*width+x]
+					factor[0] += basis * pixel[0]
+					factor[1] += basis * pixel[1]
+					factor[2] += basis * pixel[2]
+				}
+			}
+			scale := normalisation / float64(width*height)
+			factors = append(factors, [3]float64{factor[0] * scale, factor[1] * scale, factor[2] * scale})
+		}
+	}
+
+	var hash strings.Builder
+	writeBase83(&hash, (xComponents-1)+(yComponents-1)*9, 1)
+
+	maximum := 1.0
+	if ac := factors[1:]; len(ac) > 0 {
+		actualMaximum := 0.0
+		for _, factor := range ac {
+			actualMaximum = max(actualMaximum, math.Abs(factor[0]), math.Abs(factor[1]), math.Abs(factor[2]))
+		}
+		quantised := int(max(0, min(82, math.Floor(actualMaximum*166-0.5))))
+		maximum = float64(quantised+1) / 166
+		writeBase83(&hash, quantised, 1)
+	} else {
+		writeBase83(&hash, 0, 1)
+	}
+
+	dc := factors[0]
+	writeBase83(&hash, linearToSRGB(dc[0])<<16+linearToSRGB(dc[1])<<8+linearToSRGB(dc[2]), 4)
+	for _, factor := range factors[1:] {
+		quantise := func(value float64) int {
+			return int(max(0, min(18, math.Floor(signPow(value/maximum, 0.5)*9+9.5))))
+		}
+		writeBase83(&hash, quantise(factor[0])*19*19+quantise(factor[1])*19+quantise(factor[2]), 2)
+	}
+	return hash.String()
+}
+
+func writeBase83(hash *strings.Builder, value int, length int) {
+	for i := 1; i <= length; i++ {
+		digit := value / int(math.Pow(83, float64(length-i))) % 83
+		hash.WriteByte(base83Chars[digit])
+	}
+}
+
+func srgbToLinear(value uint8) float64 {
+	v := float64(value) / 255
+	if v <= 0.04045 {
+		return v / 12.92
+	}
+	return math.Pow((v+0.055)/1.055, 2.4)
+}
+
+func linearToSRGB(value float64) int {
+	v := max(0, min(1, value))
+	if v <= 0.0031308 {
+		return int(v*12.92*255 + 0.5)
+	}
+	return int((1.055*math.Pow(v, 1/2.4)-0.055)*255 + 0.5)
+}
+
+func signPow(value float64, exp float64) float64 {
+	return math.Copysign(math.Pow(math.Abs(value), exp), value)
+}
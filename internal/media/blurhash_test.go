@@ -0,0 +1,94 @@
+package media
+
+import (
+	"image"
+	"image/color"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func solidImage(width int, height int, c color.Color) image.Image {
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			img.Set(x, y, c)
+		}
+	}
+	return img
+}
+
+func TestEncodeBlurhash(t *testing.T) {
+	// The expected hashes come from a port of the reference encoder at
+	// https://github.com/woltapp/blurhash; covers larger than
+	// blurhashSampleSize are hashed at that size.
+	gradient := image.NewRGBA(image.Rect(0, 0, 20, 28))
+	for y := 0; y < 28; y++ {
+		for x := 0; x < 20; x++ {
+			gradient.Set(x, y, color.RGBA{R: uint8(x * 255 / 19), G: uint8(y * 255 / 27), B: 96, A: 255})
+		}
+	}
+	tests := []struct {
+		name string
+		img  image.Image
+		want string
+	}{
+		{name: "landscape", img: solidImage(20, 15, color.RGBA{R: 255, A: 255}), want: "LLTI:j]9fQ]9|co1fQo1fQfQfQfQ"},
+		{name: "landscape sampled", img: solidImage(40, 30, color.RGBA{R: 255, A: 255}), want: "LDTI:j]9fQ]9|co1fQo1fQfQfQfQ"},
+		{name: "portrait sampled", img: solidImage(30, 40, color.RGBA{B: 255, A: 255}), want: "TD0036fZfQfYfSfQfQfQfQfYfSfQ"},
+		{name: "square sampled", img: solidImage(64, 64, color.RGBA{R: 128, G: 128, B: 128, A: 255}), want: "L1Eyb[~qfQ~q~qoffQoffQfQfQfQ"},
+		{name: "gradient", img: gradient, want: "T$HoEP2?wxl|WDjtgJfjfQnlWpjt"},
+		{name: "empty", img: image.NewRGBA(image.Rect(0, 0, 0, 0)), want: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := EncodeBlurhash(tt.img); got != tt.want {
+				t.Fatalf("EncodeBlurhash = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBlurhashIsStable(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 90, 120))
+	for y := 0; y < 120; y++ {
+		for x := 0; x < 90; x++ {
+			img.Set(x, y, color.RGBA{R: uint8(x * 255 / 89), G: uint8(y * 255 / 119), B: 96, A: 255})
+		}
+	}
+	path := filepath.Join(t.TempDir(), "cover.png")
+	file, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := png.Encode(file, img); err != nil {
+		t.Fatal(err)
+	}
+	if err := file.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	first, err := Blurhash(FileRef(path))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(first) != 28 || first[0] != 'T' {
+		t.Fatalf("Blurhash = %q, want 28 characters for 3x4 components", first)
+	}
+	for range 3 {
+		if again, err := Blurhash(FileRef(path)); err != nil || again != first {
+			t.Fatalf("Blurhash again = %q, %v; want %q", again, err, first)
+		}
+	}
+	if encoded := EncodeBlurhash(img); encoded != first {
+		t.Fatalf("EncodeBlurhash of the decoded image = %q, want %q", encoded, first)
+	}
+	if solid := EncodeBlurhash(solidImage(90, 120, color.RGBA{B: 96, A: 255})); solid == first {
+		t.Fatalf("a gradient and a solid image share the hash %q", first)
+	}
+
+	if _, err := Blurhash(FileRef(filepath.Join(t.TempDir(), "missing.png"))); err == nil {
+		t.Fatal("Blurhash of a missing file succeeded")
+	}
+}
@@ -0,0 +1,38 @@
+package media
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+)
+
+// ChecksumAlgorithm names the hash page checksums are made with.
+const ChecksumAlgorithm = "sha256"
+
+// PageChecksum hashes the content of the page at raw, returning the hex
+// digest and the number of bytes read. Pages of a PDF hash the whole file,
+// since they are rendered from it rather than stored.
+func PageChecksum(raw string) (string, int64, error) {
+	ref, err := ParseRef(raw)
+	if err != nil {
+		return "", 0, err
+	}
+
+	var rc io.ReadCloser
+	if ref.Kind == refKindPDF {
+		rc, _, err = OpenFile(ref.Path)
+	} else {
+		rc, _, err = Open(raw)
+	}
+	if err != nil {
+		return "", 0, err
+	}
+	defer rc.Close()
+
+	hash := sha256.New()
+	size, err := io.Copy(hash, rc)
+	if err != nil {
+		return "", 0, err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), size, nil
+}
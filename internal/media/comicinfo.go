@@ -0,0 +1,149 @@
+package media
+
+import (
+	"archive/zip"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+
+	"github.com/nwaples/rardecode/v2"
+)
+
+// ComicInfoFileName is the metadata file written by ComicRack-compatible
+// taggers next to the pages of a chapter.
+const ComicInfoFileName = "ComicInfo.xml"
+
+// ComicInfo holds the ComicInfo.xml fields the library uses. Number and
+// Volume are kept as written since taggers put anything in them; Volume is
+// -1 or missing when unknown.
+type ComicInfo struct {
+	Series    string `xml:"Series"`
+	Title     string `xml:"Title"`
+	Number    string `xml:"Number"`
+	Volume    string `xml:"Volume"`
+	PageCount int    `xml:"PageCount"`
+	Manga     string `xml:"Manga"`
+}
+
+// ParseComicInfo decodes a ComicInfo.xml document.
+func ParseComicInfo(r io.Reader) (ComicInfo, error) {
+	var info ComicInfo
+	if err := xml.NewDecoder(r).Decode(&info); err != nil {
+		return ComicInfo{}, fmt.Errorf("parse comicinfo: %w", err)
+	}
+	return info, nil
+}
+
+// ReadComicInfo parses the ComicInfo.xml in dir. The file name is matched
+// case-insensitively since taggers disagree on its spelling.
+func ReadComicInfo(dir string) (ComicInfo, bool, error) {
+	entries, err := ReadDir(dir)
+	if err != nil {
+		return ComicInfo{}, false, err
+	}
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.EqualFold(entry.Name(), ComicInfoFileName) {
+			continue
+		}
+		file, _, err := OpenFile(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			return ComicInfo{}, false, err
+		}
+		defer file.Close()
+		info, err := ParseComicInfo(file)
+		if err != nil {
+			return ComicInfo{}, false, err
+		}
+		return info, true, nil
+	}
+	return ComicInfo{}, false, nil
+}
+
+// ReadArchiveComicInfo parses the ComicInfo.xml inside a zip or rar archive.
+// An entry at the archive root is preferred over ones in sub folders.
+func ReadArchiveComicInfo(path string) (ComicInfo, bool, error) {
+	switch ArchiveKind(path) {
+	case refKindZip:
+		return readZIPComicInfo(path)
+	case refKindRAR:
+		return readRARComicInfo(path)
+	default:
+		return ComicInfo{}, false, fmt.Errorf("unsupported archive type for %q", path)
+	}
+}
+
+func readZIPComicInfo(path string) (ComicInfo, bool, error) {
+	reader, closer, _, err := openZIPReader(path)
+	if err != nil {
+		return ComicInfo{}, false, err
+	}
+	defer closer.Close()
+
+	var found *zip.File
+	for _, file := range reader.File {
+		name := filepath.ToSlash(file.Name)
+		if file.FileInfo().IsDir() || !strings.EqualFold(filepath.Base(name), ComicInfoFileName) {
+			continue
+		}
+		if found == nil || strings.Count(name, "/") < strings.Count(filepath.ToSlash(found.Name), "/") {
+			found = file
+		}
+	}
+	if found == nil {
+		return ComicInfo{}, false, nil
+	}
+	rc, err := found.Open()
+	if err != nil {
+		return ComicInfo{}, false, err
+	}
+	defer rc.Close()
+	info, err := ParseComicInfo(rc)
+	if err != nil {
+		return ComicInfo{}, false, err
+	}
+	return info, true, nil
+}
+
+// readRARComicInfo takes the first ComicInfo.xml in the archive, since rar
+// entries can only be read in order.
+func readRARComicInfo(path string) (ComicInfo, bool, error) {
+	reader, err := rardecode.OpenReader(path, rardecode.FileSystem(storageFS{}))
+	if err != nil {
+		return ComicInfo{}, false, err
+	}
+	defer reader.Close()
+
+	for {
+		header, err := reader.Next()
+		if err == io.EOF {
+			return ComicInfo{}, false, nil
+		}
+		if err != nil {
+			return ComicInfo{}, false, err
+		}
+		if header.IsDir || !strings.EqualFold(filepath.Base(filepath.ToSlash(header.Name)), ComicInfoFileName) {
+			continue
+		}
+		info, err := ParseComicInfo(reader)
+		if err != nil {
+			return ComicInfo{}, false, err
+		}
+		return info, true, nil
+	}
+}
+
+// ReadingDirection maps the Manga field to a reading direction. Yes and
+// YesAndRightToLeft both mean right-to-left, No means left-to-right and
+// anything else, Unknown included, gives no answer.
+func (c ComicInfo) ReadingDirection() string {
+	switch strings.ToLower(strings.TrimSpace(c.Manga)) {
+	case "yes", "yesandrighttoleft":
+		return ReadingRightToLeft
+	case "no":
+		return ReadingLeftToRight
+	default:
+		return ""
+	}
+}
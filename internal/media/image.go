@@ -1,6 +1,7 @@
 package media
 
 import (
+	"bufio"
 	"image"
 	_ "image/gif"
 	_ "image/jpeg"
@@ -27,6 +28,5 @@ func Decode(raw string) (image.Image, error) {
 	}
 	defer rc.Close()
 
-	img, _, err := image.Decode(rc)
-	return img, err
+	return decodeImage(bufio.NewReaderSize(rc, exifPeekSize))
 }
@@ -0,0 +1,160 @@
+package media
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/draw"
+	"io"
+)
+
+// OrientationNormal is the EXIF orientation of images stored upright. The
+// other values, 2 to 8, mirror and rotate the stored pixels for display.
+const OrientationNormal = 1
+
+// exifPeekSize is how much of an image is inspected for EXIF data; the
+// APP1 segment holding it is limited to 64 KiB and comes first.
+const exifPeekSize = 128 << 10
+
+// DecodeOriented decodes the image at raw along with its EXIF orientation,
+// leaving the pixels as stored; see Orient.
+func DecodeOriented(raw string) (image.Image, int, error) {
+	rc, _, err := Open(raw)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rc.Close()
+
+	br := bufio.NewReaderSize(rc, exifPeekSize)
+	orientation := peekOrientation(br)
+	img, err := decodeImage(br)
+	return img, orientation, err
+}
+
+func peekOrientation(br *bufio.Reader) int {
+	head, _ := br.Peek(exifPeekSize)
+	return ExifOrientation(bytes.NewReader(head))
+}
+
+// ExifOrientation returns the orientation tag of a JPEG stream, or
+// OrientationNormal when there is none or the data is not a JPEG.
+func ExifOrientation(r io.Reader) int {
+	var marker [2]byte
+	if _, err := io.ReadFull(r, marker[:]); err != nil || marker != [2]byte{0xFF, 0xD8} {
+		return OrientationNormal
+	}
+	for {
+		var header [4]byte
+		if _, err := io.ReadFull(r, header[:]); err != nil || header[0] != 0xFF {
+			return OrientationNormal
+		}
+		kind := header[1]
+		// Image data follows the start of scan, with no metadata after it.
+		if kind == 0xDA || kind == 0xD9 {
+			return OrientationNormal
+		}
+		length := int(binary.BigEndian.Uint16(header[2:]))
+		if length < 2 {
+			return OrientationNormal
+		}
+		segment := make([]byte, length-2)
+		if _, err := io.ReadFull(r, segment); err != nil {
+			return OrientationNormal
+		}
+		if kind == 0xE1 && bytes.HasPrefix(segment, []byte("Exif\x00\x00")) {
+			return tiffOrientation(segment[6:])
+		}
+	}
+}
+
+// tiffOrientation finds the orientation tag in the first IFD of the TIFF
+// structure EXIF data is stored as.
+func tiffOrientation(data []byte) int {
+	if len(data) < 8 {
+		return OrientationNormal
+	}
+	var order binary.ByteOrder
+	switch string(data[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return OrientationNormal
+	}
+	offset := int(order.Uint32(data[4:]))
+	if offset < 8 || offset+2 > len(data) {
+		return OrientationNormal
+	}
+	count := int(order.Uint16(data[offset:]))
+	for i := 0; i < count; i++ {
+		entry := offset + 2 + i*12
+		if entry+12 > len(data) {
+			break
+		}
+		if order.Uint16(data[entry:]) != 0x0112 {
+			continue
+		}
+		value := int(order.Uint16(data[entry+8:]))
+		if value >= 1 && value <= 8 {
+			return value
+		}
+		break
+	}
+	return OrientationNormal
+}
+
+// swapsAxes reports whether displaying an image with the orientation
+// swaps its width and height.
+func swapsAxes(orientation int) bool {
+	return orientation >= 5 && orientation <= 8
+}
+
+// Orient returns img transformed to display upright under the given EXIF
+// orientation; img itself is returned for OrientationNormal.
+func Orient(img image.Image, orientation int) image.Image {
+	if orientation <= OrientationNormal || orientation > 8 {
+		return img
+	}
+
+	bounds := img.Bounds()
+	w, h := bounds.Dx(), bounds.Dy()
+	src, ok := img.(*image.RGBA)
+	if !ok {
+		src = image.NewRGBA(image.Rect(0, 0, w, h))
+		draw.Draw(src, src.Bounds(), img, bounds.Min, draw.Src)
+	}
+	origin := src.Bounds().Min
+
+	dw, dh := w, h
+	if swapsAxes(orientation) {
+		dw, dh = h, w
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, dw, dh))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			var dx, dy int
+			switch orientation {
+			case 2:
+				dx, dy = w-1-x, y
+			case 3:
+				dx, dy = w-1-x, h-1-y
+			case 4:
+				dx, dy = x, h-1-y
+			case 5:
+				dx, dy = y, x
+			case 6:
+				dx, dy = h-1-y, x
+			case 7:
+				dx, dy = h-1-y, w-1-x
+			case 8:
+				dx, dy = y, w-1-x
+			}
+			si := src.PixOffset(origin.X+x, origin.Y+y)
+			di := dst.PixOffset(dx, dy)
+			copy(dst.Pix[di:di+4], src.Pix[si:si+4])
+		}
+	}
+	return dst
+}
@@ -0,0 +1,304 @@
+package media
+
+import (
+	"bytes"
+	"compress/zlib"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	refKindPDF = "pdf"
+
+	// pdfRenderDPI is the resolution pages are rasterized at; 150 dpi keeps
+	// text legible on tablets without producing oversized images.
+	pdfRenderDPI = 150
+	// maxObjectStreamBytes bounds how much of a compressed object stream is
+	// inflated while looking for the page tree.
+	maxObjectStreamBytes = 32 << 20
+)
+
+var (
+	pdfPagesTypePattern = regexp.MustCompile(`/Type\s*/Pages\b`)
+	pdfCountPattern     = regexp.MustCompile(`/Count\s+(\d+)`)
+	pdfObjStmPattern    = regexp.MustCompile(`/Type\s*/ObjStm\b`)
+)
+
+var pdfRenderer struct {
+	mu       sync.RWMutex
+	cacheDir string
+	command  string
+}
+
+// ConfigurePDF enables opening PDF page refs. Pages are rasterized with the
+// poppler pdftoppm command (or the given replacement) and cached as PNG
+// files under cacheDir.
+func ConfigurePDF(cacheDir string, command string) {
+	if strings.TrimSpace(command) == "" {
+		command = "pdftoppm"
+	}
+	pdfRenderer.mu.Lock()
+	defer pdfRenderer.mu.Unlock()
+	pdfRenderer.cacheDir = cacheDir
+	pdfRenderer.command = command
+}
+
+func IsPDFFile(name string) bool {
+	return strings.EqualFold(filepath.Ext(name), ".pdf")
+}
+
+func PDFRef(path string, pageIndex int) string {
+	return refKindPDF + "|" + filepath.Clean(path) + "|" + strconv.Itoa(pageIndex)
+}
+
+// PDFPageCount reads the page count from the PDF page tree without
+// rendering anything. The root /Pages node carries the total, so the largest
+// /Count among /Pages dictionaries is used, including those packed into
+// compressed object streams.
+func PDFPageCount(path string) (int, error) {
+	data, err := ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+
+	count := pdfPagesCount(data)
+	for _, loc := range pdfObjStmPattern.FindAllIndex(data, -1) {
+		inflated, err := inflatePDFStream(data, loc[1])
+		if err != nil {
+			continue
+		}
+		count = max(count, pdfPagesCount(inflated))
+	}
+
+	if count == 0 {
+		return 0, fmt.Errorf("pdf page tree not found in %q", path)
+	}
+	return count, nil
+}
+
+func pdfPagesCount(data []byte) int {
+	count := 0
+	for _, loc := range pdfPagesTypePattern.FindAllIndex(data, -1) {
+		dict := enclosingPDFDict(data, loc[0])
+		if dict == nil {
+			continue
+		}
+		matches := pdfCountPattern.FindSubmatch(dict)
+		if len(matches) < 2 {
+			continue
+		}
+		value, err := strconv.Atoi(string(matches[1]))
+		if err != nil {
+			continue
+		}
+		count = max(count, value)
+	}
+	return count
+}
+
+// enclosingPDFDict returns the "<< ... >>" dictionary surrounding pos.
+func enclosingPDFDict(data []byte, pos int) []byte {
+	start := -1
+	depth := 0
+	for i := pos - 1; i > 0; i-- {
+		switch {
+		case data[i-1] == '>' && data[i] == '>':
+			depth++
+			i--
+		case data[i-1] == '<' && data[i] == '<':
+			if depth == 0 {
+				start = i - 1
+			} else {
+				depth--
+			}
+			i--
+		}
+		if start >= 0 {
+			break
+		}
+	}
+	if start < 0 {
+		return nil
+	}
+
+	depth = 0
+	for i := pos; i+1 < len(data); i++ {
+		switch {
+		case data[i] == '<' && data[i+1] == '<':
+			depth++
+			i++
+		case data[i] == '>' && data[i+1] == '>':
+			if depth == 0 {
+				return data[start : i+2]
+			}
+			depth--
+			i++
+		}
+	}
+	return nil
+}
+
+func inflatePDFStream(data []byte, from int) ([]byte, error) {
+	offset := bytes.Index(data[from:], []byte("stream"))
+	if offset < 0 {
+		return nil, errors.New("stream keyword not found")
+	}
+	start := from + offset + len("stream")
+	if start < len(data) && data[start] == '\r' {
+		start++
+	}
+	if start < len(data) && data[start] == '\n' {
+		start++
+	}
+
+	reader, err := zlib.NewReader(bytes.NewReader(data[start:]))
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+
+	inflated, err := io.ReadAll(io.LimitReader(reader, maxObjectStreamBytes))
+	if err != nil && len(inflated) == 0 {
+		return nil, err
+	}
+	return inflated, nil
+}
+
+// NeedsRender reports whether opening the asset ref has to rasterize a PDF
+// page because no rendered copy is cached yet.
+func NeedsRender(raw string) bool {
+	ref, err := ParseRef(raw)
+	if err != nil || ref.Kind != refKindPDF {
+		return false
+	}
+	pageIndex, err := strconv.Atoi(ref.EntryPath)
+	if err != nil {
+		return false
+	}
+	info, err := Stat(ref.Path)
+	if err != nil {
+		return false
+	}
+
+	pdfRenderer.mu.RLock()
+	cacheDir := pdfRenderer.cacheDir
+	pdfRenderer.mu.RUnlock()
+	if cacheDir == "" {
+		return false
+	}
+	_, err = os.Stat(pdfRenderTarget(cacheDir, ref.Path, info, pageIndex))
+	return err != nil
+}
+
+func openPDFPage(ref Ref) (io.ReadCloser, time.Time, error) {
+	pageIndex, err := strconv.Atoi(ref.EntryPath)
+	if err != nil || pageIndex < 0 {
+		return nil, time.Time{}, fmt.Errorf("invalid pdf page %q", ref.EntryPath)
+	}
+
+	info, err := Stat(ref.Path)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+
+	rendered, err := renderPDFPage(ref.Path, info, pageIndex)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+
+	file, err := os.Open(rendered)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	return file, info.ModTime(), nil
+}
+
+func renderPDFPage(path string, info os.FileInfo, pageIndex int) (string, error) {
+	pdfRenderer.mu.RLock()
+	cacheDir := pdfRenderer.cacheDir
+	command := pdfRenderer.command
+	pdfRenderer.mu.RUnlock()
+	if cacheDir == "" {
+		return "", errors.New("pdf support is disabled")
+	}
+
+	target := pdfRenderTarget(cacheDir, path, info, pageIndex)
+	if _, err := os.Stat(target); err == nil {
+		return target, nil
+	}
+
+	if err := os.MkdirAll(cacheDir, 0o755); err != nil {
+		return "", fmt.Errorf("create pdf cache dir: %w", err)
+	}
+
+	tempDir, err := os.MkdirTemp(cacheDir, "render-*")
+	if err != nil {
+		return "", fmt.Errorf("create pdf render dir: %w", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	// The renderer reads from local disk, so PDFs kept in another storage
+	// are copied next to its output first.
+	source := path
+	if _, local := currentStorage().(LocalStorage); !local {
+		source = filepath.Join(tempDir, "source.pdf")
+		if err := copyToLocal(path, source); err != nil {
+			return "", fmt.Errorf("fetch pdf %q: %w", path, err)
+		}
+	}
+
+	pageNumber := strconv.Itoa(pageIndex + 1)
+	prefix := filepath.Join(tempDir, "page")
+	output, err := exec.Command(command,
+		"-f", pageNumber,
+		"-l", pageNumber,
+		"-r", strconv.Itoa(pdfRenderDPI),
+		"-png",
+		"-singlefile",
+		source,
+		prefix,
+	).CombinedOutput()
+	if err != nil {
+		return "", fmt.Errorf("render pdf page %d of %q: %w: %s", pageIndex, path, err, strings.TrimSpace(string(output)))
+	}
+
+	if err := os.Rename(prefix+".png", target); err != nil {
+		return "", fmt.Errorf("store rendered pdf page: %w", err)
+	}
+	return target, nil
+}
+
+func pdfRenderTarget(cacheDir string, path string, info os.FileInfo, pageIndex int) string {
+	key := fmt.Sprintf("%s|%d|%d|%d", filepath.Clean(path), info.Size(), info.ModTime().UnixNano(), pageIndex)
+	sum := sha1.Sum([]byte(key))
+	return filepath.Join(cacheDir, hex.EncodeToString(sum[:])+".png")
+}
+
+func copyToLocal(path string, target string) error {
+	file, _, err := OpenFile(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	out, err := os.Create(target)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, file); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
@@ -0,0 +1,156 @@
+package media
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"image"
+	"image/png"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// testPDF builds a PDF whose page tree holds pages leaf pages, enough for
+// PDFPageCount; no renderer is ever given one.
+func testPDF(pages int) []byte {
+	var doc bytes.Buffer
+	doc.WriteString("%PDF-1.4\n1 0 obj << /Type /Catalog /Pages 2 0 R >> endobj\n")
+	kids := make([]string, pages)
+	for i := range kids {
+		kids[i] = fmt.Sprintf("%d 0 R", i+3)
+	}
+	fmt.Fprintf(&doc, "2 0 obj << /Type /Pages /Kids [%s] /Count %d >> endobj\n", strings.Join(kids, " "), pages)
+	for i := range kids {
+		fmt.Fprintf(&doc, "%d 0 obj << /Type /Page /Parent 2 0 R /MediaBox [0 0 10 10] >> endobj\n", i+3)
+	}
+	doc.WriteString("trailer << /Root 1 0 R >>\n%%EOF\n")
+	return doc.Bytes()
+}
+
+func writeFile(t *testing.T, path string, data []byte) string {
+	t.Helper()
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestPDFPageCount(t *testing.T) {
+	dir := t.TempDir()
+
+	// The page tree packed into a compressed object stream, as PDF 1.5
+	// writers do.
+	var packed bytes.Buffer
+	compressor := zlib.NewWriter(&packed)
+	compressor.Write([]byte("2 0 << /Type /Pages /Kids [3 0 R] /Count 4 >>"))
+	compressor.Close()
+	var objectStream bytes.Buffer
+	fmt.Fprintf(&objectStream, "%%PDF-1.5\n5 0 obj << /Type /ObjStm /N 1 /First 4 /Filter /FlateDecode /Length %d >>\nstream\n", packed.Len())
+	objectStream.Write(packed.Bytes())
+	objectStream.WriteString("\nendstream\nendobj\n%%EOF\n")
+
+	tests := []struct {
+		name    string
+		data    []byte
+		want    int
+		wantErr bool
+	}{
+		{name: "flat page tree", data: testPDF(3), want: 3},
+		{name: "nested page tree", data: []byte("%PDF-1.4\n2 0 obj << /Type /Pages /Kids [6 0 R 7 0 R] /Count 5 >> endobj\n6 0 obj << /Type /Pages /Parent 2 0 R /Count 2 >> endobj\n7 0 obj << /Type /Pages /Parent 2 0 R /Count 3 >> endobj\n"), want: 5},
+		{name: "object stream", data: objectStream.Bytes(), want: 4},
+		{name: "no page tree", data: []byte("%PDF-1.4\n%%EOF\n"), wantErr: true},
+	}
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := writeFile(t, filepath.Join(dir, fmt.Sprintf("%d.pdf", i)), tt.data)
+			count, err := PDFPageCount(path)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("count = %d, want an error", count)
+				}
+				return
+			}
+			if err != nil || count != tt.want {
+				t.Fatalf("count = %d, %v; want %d", count, err, tt.want)
+			}
+		})
+	}
+}
+
+// fakePDFRenderer writes a stand-in for pdftoppm that copies a PNG to the
+// output prefix and appends the page it was asked for to a log.
+func fakePDFRenderer(t *testing.T) (string, string) {
+	t.Helper()
+	dir := t.TempDir()
+	page := filepath.Join(dir, "page.png")
+	file, err := os.Create(page)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := png.Encode(file, image.NewGray(image.Rect(0, 0, 4, 6))); err != nil {
+		t.Fatal(err)
+	}
+	file.Close()
+
+	log := filepath.Join(dir, "renders.log")
+	script := fmt.Sprintf("#!/bin/sh\necho \"$2\" >> %q\nfor prefix; do :; done\ncp %q \"$prefix.png\"\n", log, page)
+	command := writeFile(t, filepath.Join(dir, "pdftoppm"), []byte(script))
+	if err := os.Chmod(command, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	return command, log
+}
+
+func TestOpenPDFPageRendersOnce(t *testing.T) {
+	command, log := fakePDFRenderer(t)
+	cacheDir := filepath.Join(t.TempDir(), "pdf")
+	ConfigurePDF(cacheDir, command)
+	t.Cleanup(func() { ConfigurePDF("", "") })
+
+	path := writeFile(t, filepath.Join(t.TempDir(), "Chapter 1.pdf"), testPDF(3))
+	ref := PDFRef(path, 1)
+	if !NeedsRender(ref) {
+		t.Fatal("uncached page does not need rendering")
+	}
+
+	for i := 0; i < 2; i++ {
+		rc, _, err := Open(ref)
+		if err != nil {
+			t.Fatalf("open page: %v", err)
+		}
+		img, err := png.Decode(rc)
+		rc.Close()
+		if err != nil || img.Bounds().Dx() != 4 {
+			t.Fatalf("rendered page = %v, %v", img, err)
+		}
+	}
+
+	if NeedsRender(ref) {
+		t.Error("rendered page still needs rendering")
+	}
+	renders, err := os.ReadFile(log)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// pdftoppm numbers pages from 1.
+	if string(renders) != "2\n" {
+		t.Errorf("renderer calls = %q, want one for page 2", renders)
+	}
+	if cached, _ := filepath.Glob(filepath.Join(cacheDir, "*.png")); len(cached) != 1 {
+		t.Errorf("cached renders = %v, want 1", cached)
+	}
+}
+
+func TestOpenPDFPageDisabled(t *testing.T) {
+	ConfigurePDF("", "")
+	path := writeFile(t, filepath.Join(t.TempDir(), "Chapter 1.pdf"), testPDF(1))
+	rc, _, err := Open(PDFRef(path, 0))
+	if err == nil {
+		io.Copy(io.Discard, rc)
+		rc.Close()
+		t.Fatal("opened a pdf page with pdf support disabled")
+	}
+}
@@ -0,0 +1,46 @@
+package media
+
+// Reading directions, as stored on manga and returned by the API.
+const (
+	ReadingLeftToRight = "ltr"
+	ReadingRightToLeft = "rtl"
+	ReadingVertical    = "vertical"
+)
+
+// Reading modes the reader offers. A manga's default mode is inferred from
+// its reading direction until a user picks one.
+const (
+	ReadingModePaged      = "paged"
+	ReadingModeContinuous = "continuous"
+	ReadingModeDouble     = "double"
+)
+
+// ValidReadingDirection reports whether direction is one of the Reading
+// constants.
+func ValidReadingDirection(direction string) bool {
+	switch direction {
+	case ReadingLeftToRight, ReadingRightToLeft, ReadingVertical:
+		return true
+	default:
+		return false
+	}
+}
+
+// ValidReadingMode reports whether mode is one of the ReadingMode constants.
+func ValidReadingMode(mode string) bool {
+	switch mode {
+	case ReadingModePaged, ReadingModeContinuous, ReadingModeDouble:
+		return true
+	default:
+		return false
+	}
+}
+
+// InferredReadingMode is the default mode for a reading direction: vertical
+// strips scroll continuously, everything else is read page by page.
+func InferredReadingMode(direction string) string {
+	if direction == ReadingVertical {
+		return ReadingModeContinuous
+	}
+	return ReadingModePaged
+}
@@ -1,10 +1,11 @@
 package media
 
 import (
-	"archive/zip"
+	"context"
+	"encoding/binary"
 	"fmt"
 	"io"
-	"os"
+	"net/http"
 	"path/filepath"
 	"strings"
 	"time"
@@ -77,6 +78,61 @@ func GuessMime(name string) string {
 	}
 }
 
+// oleMagic starts OLE compound files, such as the Thumbs.db Windows writes.
+const oleMagic = "\xD0\xCF\x11\xE0\xA1\xB1\x1A\xE1"
+
+// SniffMime detects the content type of an asset from its first 512 bytes.
+// AVIF, which http.DetectContentType does not know, is recognised by its
+// ftyp brands, and OLE compound files are reported as such rather than as
+// application/octet-stream, the type of content that was not recognised.
+func SniffMime(raw string) (string, error) {
+	rc, _, err := Open(raw)
+	if err != nil {
+		return "", err
+	}
+	defer rc.Close()
+
+	header := make([]byte, 512)
+	n, err := io.ReadFull(rc, header)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return "", err
+	}
+	header = header[:n]
+	if isAVIF(header) {
+		return "image/avif", nil
+	}
+	if strings.HasPrefix(string(header), oleMagic) {
+		return "application/x-ole-storage", nil
+	}
+	return http.DetectContentType(header), nil
+}
+
+// isAVIF looks for an AVIF brand among the major and compatible brands of
+// the leading ftyp box; files from some encoders only list it as
+// compatible, behind a generic brand such as mif1.
+func isAVIF(header []byte) bool {
+	if len(header) < 16 || string(header[4:8]) != "ftyp" {
+		return false
+	}
+	size := min(int(binary.BigEndian.Uint32(header)), len(header))
+	for pos := 8; pos+4 <= size; pos += 4 {
+		if pos == 12 {
+			continue // minor version
+		}
+		if brand := string(header[pos : pos+4]); brand == "avif" || brand == "avis" {
+			return true
+		}
+	}
+	return false
+}
+
+// IsHiddenName reports whether a file or folder name is one the scanner
+// skips as hidden: dot files such as .DS_Store and the ._ resource forks
+// macOS leaves on foreign filesystems, and __MACOSX folders in archives.
+func IsHiddenName(name string) bool {
+	return strings.HasPrefix(name, ".") || name == "__MACOSX"
+}
+
 func FileRef(path string) string {
 	return refKindFile + "|" + filepath.Clean(path)
 }
@@ -94,7 +150,7 @@ func ParseRef(raw string) (Ref, error) {
 		}
 		return Ref{Kind: parts[0], Path: parts[1]}, nil
 	case 3:
-		if parts[0] != refKindZip && parts[0] != refKindRAR {
+		if parts[0] != refKindZip && parts[0] != refKindRAR && parts[0] != refKindPDF {
 			return Ref{}, fmt.Errorf("unsupported asset ref kind %q", parts[0])
 		}
 		return Ref{Kind: parts[0], Path: parts[1], EntryPath: filepath.ToSlash(parts[2])}, nil
@@ -111,20 +167,17 @@ func Open(raw string) (io.ReadCloser, time.Time, error) {
 
 	switch ref.Kind {
 	case refKindFile:
-		file, err := os.Open(ref.Path)
+		file, info, err := OpenFile(ref.Path)
 		if err != nil {
 			return nil, time.Time{}, err
 		}
-		info, err := file.Stat()
-		if err != nil {
-			file.Close()
-			return nil, time.Time{}, err
-		}
 		return file, info.ModTime(), nil
 	case refKindZip:
 		return openZIPEntry(ref.Path, ref.EntryPath)
 	case refKindRAR:
 		return openRAREntry(ref.Path, ref.EntryPath)
+	case refKindPDF:
+		return openPDFPage(ref)
 	default:
 		return nil, time.Time{}, fmt.Errorf("unsupported asset ref kind %q", ref.Kind)
 	}
@@ -154,12 +207,31 @@ func (m *multiCloser) Close() error {
 	return m.close()
 }
 
+// ContextReader wraps r so reads fail with the context's error once ctx is
+// done, letting a copy loop stop as soon as the client goes away instead of
+// decompressing the rest of an archive entry.
+func ContextReader(ctx context.Context, r io.Reader) io.Reader {
+	return &contextReader{ctx: ctx, reader: r}
+}
+
+type contextReader struct {
+	ctx    context.Context
+	reader io.Reader
+}
+
+func (c *contextReader) Read(p []byte) (int, error) {
+	if err := c.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return c.reader.Read(p)
+}
+
 func listZIPImages(path string) ([]ArchiveEntry, error) {
-	reader, err := zip.OpenReader(path)
+	reader, closer, _, err := openZIPReader(path)
 	if err != nil {
 		return nil, err
 	}
-	defer reader.Close()
+	defer closer.Close()
 
 	items := make([]ArchiveEntry, 0)
 	for _, file := range reader.File {
@@ -176,7 +248,7 @@ func listZIPImages(path string) ([]ArchiveEntry, error) {
 }
 
 func listRARImages(path string) ([]ArchiveEntry, error) {
-	reader, err := rardecode.OpenReader(path)
+	reader, err := rardecode.OpenReader(path, rardecode.FileSystem(storageFS{}))
 	if err != nil {
 		return nil, err
 	}
@@ -202,34 +274,8 @@ func listRARImages(path string) ([]ArchiveEntry, error) {
 	}
 }
 
-func openZIPEntry(path string, entryPath string) (io.ReadCloser, time.Time, error) {
-	reader, err := zip.OpenReader(path)
-	if err != nil {
-		return nil, time.Time{}, err
-	}
-	for _, file := range reader.File {
-		if filepath.ToSlash(file.Name) != entryPath {
-			continue
-		}
-		rc, err := file.Open()
-		if err != nil {
-			reader.Close()
-			return nil, time.Time{}, err
-		}
-		return &multiCloser{
-			reader: rc,
-			close: func() error {
-				rc.Close()
-				return reader.Close()
-			},
-		}, file.Modified, nil
-	}
-	reader.Close()
-	return nil, time.Time{}, fmt.Errorf("zip entry not found: %s", entryPath)
-}
-
 func openRAREntry(path string, entryPath string) (io.ReadCloser, time.Time, error) {
-	reader, err := rardecode.OpenReader(path)
+	reader, err := rardecode.OpenReader(path, rardecode.FileSystem(storageFS{}))
 	if err != nil {
 		return nil, time.Time{}, err
 	}
@@ -0,0 +1,236 @@
+package media
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// Storage is where library files are read from. Paths are the ones stored
+// in the database and asset refs, so a backend decides how they map onto
+// its objects. Everything that reads bookshelves, from scanning to serving
+// pages, goes through the configured Storage; caches and the database stay
+// on local disk. ReadDir returns entries sorted by name, as os.ReadDir
+// does.
+type Storage interface {
+	Open(path string) (io.ReadSeekCloser, fs.FileInfo, error)
+	ReadDir(path string) ([]fs.DirEntry, error)
+	Stat(path string) (fs.FileInfo, error)
+}
+
+// DirStream lists a directory a batch at a time, like os.File.ReadDir.
+type DirStream interface {
+	ReadDir(n int) ([]fs.DirEntry, error)
+	Close() error
+}
+
+// dirOpener is implemented by storages that can list huge directories
+// without loading the whole listing.
+type dirOpener interface {
+	OpenDir(path string) (DirStream, error)
+}
+
+// LocalStorage reads from the local filesystem. It is the default.
+type LocalStorage struct{}
+
+func (LocalStorage) Open(path string) (io.ReadSeekCloser, fs.FileInfo, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, nil, err
+	}
+	return file, info, nil
+}
+
+func (LocalStorage) ReadDir(path string) ([]fs.DirEntry, error) {
+	return os.ReadDir(path)
+}
+
+func (LocalStorage) Stat(path string) (fs.FileInfo, error) {
+	return os.Stat(path)
+}
+
+func (LocalStorage) OpenDir(path string) (DirStream, error) {
+	return os.Open(path)
+}
+
+var storage struct {
+	mu      sync.RWMutex
+	backend Storage
+}
+
+// SetStorage makes backend the storage library files are read from. A nil
+// backend restores LocalStorage. It should be called before the first scan.
+func SetStorage(backend Storage) {
+	storage.mu.Lock()
+	defer storage.mu.Unlock()
+	storage.backend = backend
+}
+
+func currentStorage() Storage {
+	storage.mu.RLock()
+	defer storage.mu.RUnlock()
+	if storage.backend == nil {
+		return LocalStorage{}
+	}
+	return storage.backend
+}
+
+// OpenFile opens a library file.
+func OpenFile(path string) (io.ReadSeekCloser, fs.FileInfo, error) {
+	return currentStorage().Open(path)
+}
+
+// Stat describes a library file or directory.
+func Stat(path string) (fs.FileInfo, error) {
+	return currentStorage().Stat(path)
+}
+
+// ReadDir lists a library directory.
+func ReadDir(path string) ([]fs.DirEntry, error) {
+	return currentStorage().ReadDir(path)
+}
+
+// ReadFile reads a whole library file.
+func ReadFile(path string) ([]byte, error) {
+	file, _, err := OpenFile(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return io.ReadAll(file)
+}
+
+// OpenDir lists a library directory in batches. Storages that cannot
+// stream a listing have it read whole and handed out in batches.
+func OpenDir(path string) (DirStream, error) {
+	backend := currentStorage()
+	if opener, ok := backend.(dirOpener); ok {
+		return opener.OpenDir(path)
+	}
+	entries, err := backend.ReadDir(path)
+	if err != nil {
+		return nil, err
+	}
+	return &listedDir{entries: entries}, nil
+}
+
+type listedDir struct {
+	entries []fs.DirEntry
+}
+
+func (d *listedDir) ReadDir(n int) ([]fs.DirEntry, error) {
+	if n <= 0 {
+		entries := d.entries
+		d.entries = nil
+		return entries, nil
+	}
+	if len(d.entries) == 0 {
+		return nil, io.EOF
+	}
+	n = min(n, len(d.entries))
+	batch := d.entries[:n]
+	d.entries = d.entries[n:]
+	return batch, nil
+}
+
+func (d *listedDir) Close() error {
+	return nil
+}
+
+// WalkDir walks the library tree at root like filepath.WalkDir.
+func WalkDir(root string, fn fs.WalkDirFunc) error {
+	info, err := Stat(root)
+	if err != nil {
+		err = fn(root, nil, err)
+	} else {
+		err = walkDir(root, fs.FileInfoToDirEntry(info), fn)
+	}
+	if errors.Is(err, filepath.SkipDir) || errors.Is(err, filepath.SkipAll) {
+		return nil
+	}
+	return err
+}
+
+func walkDir(path string, entry fs.DirEntry, fn fs.WalkDirFunc) error {
+	if err := fn(path, entry, nil); err != nil || !entry.IsDir() {
+		if errors.Is(err, filepath.SkipDir) && entry.IsDir() {
+			err = nil
+		}
+		return err
+	}
+
+	entries, err := ReadDir(path)
+	if err != nil {
+		if err = fn(path, entry, err); err != nil {
+			if errors.Is(err, filepath.SkipDir) {
+				err = nil
+			}
+			return err
+		}
+	}
+
+	for _, child := range entries {
+		if err := walkDir(filepath.Join(path, child.Name()), child, fn); err != nil {
+			if errors.Is(err, filepath.SkipDir) {
+				break
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+// openZIPReader opens the zip archive at path. Backends whose files cannot
+// be read at an offset have the archive read into memory.
+func openZIPReader(path string) (*zip.Reader, io.Closer, fs.FileInfo, error) {
+	file, info, err := OpenFile(path)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	readerAt, ok := file.(io.ReaderAt)
+	if !ok {
+		data, err := io.ReadAll(file)
+		if err != nil {
+			file.Close()
+			return nil, nil, nil, err
+		}
+		readerAt = bytes.NewReader(data)
+	}
+	reader, err := zip.NewReader(readerAt, info.Size())
+	if err != nil {
+		file.Close()
+		return nil, nil, nil, err
+	}
+	return reader, file, info, nil
+}
+
+// storageFS lets rardecode open archive volumes through the storage. The
+// names it is handed are full paths rather than fs.FS paths.
+type storageFS struct{}
+
+func (storageFS) Open(name string) (fs.File, error) {
+	file, info, err := OpenFile(name)
+	if err != nil {
+		return nil, err
+	}
+	return &storageFile{ReadSeekCloser: file, info: info}, nil
+}
+
+type storageFile struct {
+	io.ReadSeekCloser
+	info fs.FileInfo
+}
+
+func (f *storageFile) Stat() (fs.FileInfo, error) {
+	return f.info, nil
+}
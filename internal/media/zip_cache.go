@@ -0,0 +1,157 @@
+package media
+
+import (
+	"archive/zip"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// zipReaderCacheSize is how many zip archives are kept open, so paging
+// through a chapter parses its central directory once instead of per page.
+const zipReaderCacheSize = 16
+
+// openZIP is an archive kept open by the zip reader cache. It is closed
+// once evicted and no longer read from.
+type openZIP struct {
+	path    string
+	modTime time.Time
+	size    int64
+	closer  io.Closer
+	files   map[string]*zip.File
+	// refs counts entries being read; guarded by zipReaders.mu.
+	refs    int
+	evicted bool
+}
+
+// zipReaders holds the open archives, least recently used first.
+var zipReaders struct {
+	mu      sync.Mutex
+	entries []*openZIP
+}
+
+// acquireZIP returns the open archive at path, opening it when it is not
+// cached or its mtime or size changed since it was. The caller must hand
+// it back with releaseZIP.
+func acquireZIP(path string) (*openZIP, error) {
+	info, err := Stat(path)
+	if err != nil {
+		return nil, err
+	}
+
+	zipReaders.mu.Lock()
+	for i, archive := range zipReaders.entries {
+		if archive.path != path {
+			continue
+		}
+		zipReaders.entries = append(zipReaders.entries[:i], zipReaders.entries[i+1:]...)
+		if archive.modTime.Equal(info.ModTime()) && archive.size == info.Size() {
+			zipReaders.entries = append(zipReaders.entries, archive)
+			archive.refs++
+			zipReaders.mu.Unlock()
+			return archive, nil
+		}
+		evictZIP(archive)
+		break
+	}
+	zipReaders.mu.Unlock()
+
+	reader, closer, info, err := openZIPReader(path)
+	if err != nil {
+		return nil, err
+	}
+	archive := &openZIP{
+		path:    path,
+		modTime: info.ModTime(),
+		size:    info.Size(),
+		closer:  closer,
+		files:   make(map[string]*zip.File, len(reader.File)),
+		refs:    1,
+	}
+	for _, file := range reader.File {
+		archive.files[filepath.ToSlash(file.Name)] = file
+	}
+
+	zipReaders.mu.Lock()
+	defer zipReaders.mu.Unlock()
+	// Another request may have opened the same archive meanwhile; the newer
+	// reader replaces it.
+	for i, cached := range zipReaders.entries {
+		if cached.path == path {
+			zipReaders.entries = append(zipReaders.entries[:i], zipReaders.entries[i+1:]...)
+			evictZIP(cached)
+			break
+		}
+	}
+	zipReaders.entries = append(zipReaders.entries, archive)
+	for len(zipReaders.entries) > zipReaderCacheSize {
+		evictZIP(zipReaders.entries[0])
+		zipReaders.entries = zipReaders.entries[1:]
+	}
+	return archive, nil
+}
+
+func releaseZIP(archive *openZIP) {
+	zipReaders.mu.Lock()
+	defer zipReaders.mu.Unlock()
+	archive.refs--
+	if archive.evicted && archive.refs == 0 {
+		archive.closer.Close()
+	}
+}
+
+// evictZIP marks an archive removed from the cache, closing it unless an
+// entry is still being read. zipReaders.mu must be held.
+func evictZIP(archive *openZIP) {
+	archive.evicted = true
+	if archive.refs == 0 {
+		archive.closer.Close()
+	}
+}
+
+// ForgetArchives closes the cached zip readers of archives at or under path,
+// so the files can be moved or deleted on systems that lock open files.
+func ForgetArchives(path string) {
+	path = filepath.Clean(path)
+	prefix := path + string(filepath.Separator)
+	zipReaders.mu.Lock()
+	defer zipReaders.mu.Unlock()
+	kept := zipReaders.entries[:0]
+	for _, archive := range zipReaders.entries {
+		if archive.path == path || strings.HasPrefix(archive.path, prefix) {
+			evictZIP(archive)
+			continue
+		}
+		kept = append(kept, archive)
+	}
+	zipReaders.entries = kept
+}
+
+func openZIPEntry(path string, entryPath string) (io.ReadCloser, time.Time, error) {
+	archive, err := acquireZIP(path)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	file, ok := archive.files[entryPath]
+	if !ok {
+		releaseZIP(archive)
+		return nil, time.Time{}, fmt.Errorf("zip entry not found: %s", entryPath)
+	}
+	rc, err := file.Open()
+	if err != nil {
+		releaseZIP(archive)
+		return nil, time.Time{}, err
+	}
+	var release sync.Once
+	return &multiCloser{
+		reader: rc,
+		close: func() error {
+			err := rc.Close()
+			release.Do(func() { releaseZIP(archive) })
+			return err
+		},
+	}, file.Modified, nil
+}
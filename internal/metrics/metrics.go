@@ -0,0 +1,213 @@
+// Package metrics keeps a small set of in-process metrics and renders them in
+// the Prometheus text exposition format.
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+)
+
+type collector interface {
+	writeTo(w io.Writer)
+}
+
+type Registry struct {
+	mu         sync.Mutex
+	collectors []collector
+}
+
+// Default is the registry served by the /metrics endpoint.
+var Default = NewRegistry()
+
+func NewRegistry() *Registry {
+	return &Registry{}
+}
+
+func (r *Registry) register(c collector) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.collectors = append(r.collectors, c)
+}
+
+func (r *Registry) Render(w io.Writer) {
+	r.mu.Lock()
+	collectors := append([]collector(nil), r.collectors...)
+	r.mu.Unlock()
+
+	for _, c := range collectors {
+		c.writeTo(w)
+	}
+}
+
+func (r *Registry) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		r.Render(w)
+	})
+}
+
+// Histogram tracks observations per value of a single label.
+type Histogram struct {
+	name    string
+	help    string
+	label   string
+	buckets []float64
+
+	mu     sync.Mutex
+	series map[string]*histogramSeries
+}
+
+type histogramSeries struct {
+	counts []uint64
+	count  uint64
+	sum    float64
+}
+
+func (r *Registry) NewHistogram(name string, help string, label string, buckets []float64) *Histogram {
+	sorted := append([]float64(nil), buckets...)
+	sort.Float64s(sorted)
+	h := &Histogram{
+		name:    name,
+		help:    help,
+		label:   label,
+		buckets: sorted,
+		series:  make(map[string]*histogramSeries),
+	}
+	r.register(h)
+	return h
+}
+
+func (h *Histogram) Observe(labelValue string, value float64) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	series, ok := h.series[labelValue]
+	if !ok {
+		series = &histogramSeries{counts: make([]uint64, len(h.buckets))}
+		h.series[labelValue] = series
+	}
+	for i, bound := range h.buckets {
+		if value <= bound {
+			series.counts[i]++
+		}
+	}
+	series.count++
+	series.sum += value
+}
+
+func (h *Histogram) writeTo(w io.Writer) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	fmt.Fprintf(w, "# HELP %s %s\n", h.name, h.help)
+	fmt.Fprintf(w, "# TYPE %s histogram\n", h.name)
+
+	values := make([]string, 0, len(h.series))
+	for value := range h.series {
+		values = append(values, value)
+	}
+	sort.Strings(values)
+
+	for _, value := range values {
+		series := h.series[value]
+		label := fmt.Sprintf("%s=%q", h.label, value)
+		for i, bound := range h.buckets {
+			fmt.Fprintf(w, "%s_bucket{%s,le=%q} %d\n", h.name, label, formatFloat(bound), series.counts[i])
+		}
+		fmt.Fprintf(w, "%s_bucket{%s,le=\"+Inf\"} %d\n", h.name, label, series.count)
+		fmt.Fprintf(w, "%s_sum{%s} %s\n", h.name, label, formatFloat(series.sum))
+		fmt.Fprintf(w, "%s_count{%s} %d\n", h.name, label, series.count)
+	}
+}
+
+// Counter counts events per value of a single label.
+type Counter struct {
+	name  string
+	help  string
+	label string
+
+	mu     sync.Mutex
+	series map[string]uint64
+}
+
+func (r *Registry) NewCounter(name string, help string, label string) *Counter {
+	c := &Counter{
+		name:   name,
+		help:   help,
+		label:  label,
+		series: make(map[string]uint64),
+	}
+	r.register(c)
+	return c
+}
+
+func (c *Counter) Inc(labelValue string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.series[labelValue]++
+}
+
+func (c *Counter) writeTo(w io.Writer) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	fmt.Fprintf(w, "# HELP %s %s\n", c.name, c.help)
+	fmt.Fprintf(w, "# TYPE %s counter\n", c.name)
+
+	values := make([]string, 0, len(c.series))
+	for value := range c.series {
+		values = append(values, value)
+	}
+	sort.Strings(values)
+
+	for _, value := range values {
+		fmt.Fprintf(w, "%s{%s=%q} %d\n", c.name, c.label, value, c.series[value])
+	}
+}
+
+// GaugeFunc reports values computed when the metrics are rendered, per
+// value of a single label.
+type GaugeFunc struct {
+	name  string
+	help  string
+	label string
+	read  func() map[string]float64
+}
+
+// NewGaugeFunc registers a gauge whose values come from read at render
+// time; read returning nil leaves the gauge without series.
+func (r *Registry) NewGaugeFunc(name string, help string, label string, read func() map[string]float64) *GaugeFunc {
+	g := &GaugeFunc{
+		name:  name,
+		help:  help,
+		label: label,
+		read:  read,
+	}
+	r.register(g)
+	return g
+}
+
+func (g *GaugeFunc) writeTo(w io.Writer) {
+	series := g.read()
+
+	fmt.Fprintf(w, "# HELP %s %s\n", g.name, g.help)
+	fmt.Fprintf(w, "# TYPE %s gauge\n", g.name)
+
+	values := make([]string, 0, len(series))
+	for value := range series {
+		values = append(values, value)
+	}
+	sort.Strings(values)
+
+	for _, value := range values {
+		fmt.Fprintf(w, "%s{%s=%q} %s\n", g.name, g.label, value, formatFloat(series[value]))
+	}
+}
+
+func formatFloat(value float64) string {
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
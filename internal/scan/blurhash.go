@@ -0,0 +1,47 @@
+package scan
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"mynewmangaui/internal/media"
+)
+
+// fillCoverBlurhash sets the blurhash of the manga's cover, keeping the
+// stored one while the cover path and the manga's modification time are
+// unchanged, so a rescan only decodes covers that may have changed. A
+// cover that cannot be decoded leaves the blurhash empty.
+func (s *Service) fillCoverBlurhash(ctx context.Context, mangaID string, record *mangaRecord) error {
+	if record.CoverPath == "" {
+		return nil
+	}
+
+	var coverPath, blurhash string
+	var updatedAt sql.NullTime
+	err := s.db.QueryRowContext(ctx, `
+		SELECT cover_path, updated_at, COALESCE(cover_blurhash, '')
+		FROM manga
+		WHERE id = ?
+	`, mangaID).Scan(&coverPath, &updatedAt, &blurhash)
+	if err != nil && err != sql.ErrNoRows {
+		return fmt.Errorf("load cover blurhash: %w", err)
+	}
+	if blurhash != "" && coverPath == record.CoverPath && updatedAt.Time.UTC().Equal(record.UpdatedAt.UTC().Truncate(time.Second)) {
+		record.CoverBlurhash = blurhash
+		return nil
+	}
+
+	start := s.now()
+	blurhash, err = media.Blurhash(record.CoverPath)
+	record.coverDecodeTime = s.now().Sub(start)
+	if err != nil {
+		if s.logger != nil {
+			s.logger.Warn("failed to compute cover blurhash", "path", record.CoverPath, "error", err)
+		}
+		return nil
+	}
+	record.CoverBlurhash = blurhash
+	return nil
+}
@@ -0,0 +1,72 @@
+package scan
+
+import (
+	"strings"
+)
+
+// pathID derives the id of a manga or chapter from its path. With
+// CaseInsensitivePaths the path is case-folded first, so a folder renamed
+// only in case, or a bookshelf root spelled differently, keeps its ids and
+// with them the reading progress attached to them. Page ids are left alone
+// since nothing the user owns hangs off them.
+func (s *Service) pathID(prefix string, path string) string {
+	return makeID(prefix, s.pathKey(path))
+}
+
+func (s *Service) pathKey(path string) string {
+	if s.options.CaseInsensitivePaths {
+		return strings.ToLower(path)
+	}
+	return path
+}
+
+// claimMangaPath records fullPath as seen under mangaID and reports whether
+// it should be scanned. Two paths that differ only in case share an id with
+// CaseInsensitivePaths; the one that sorts first byte-wise wins whatever
+// order the directory lists them in, so scans never flip between them.
+func (s *Service) claimMangaPath(seen map[string]string, mangaID string, fullPath string) bool {
+	claimed, ok := seen[mangaID]
+	if !ok {
+		seen[mangaID] = fullPath
+		return true
+	}
+	kept, dropped := claimed, fullPath
+	if fullPath < claimed {
+		kept, dropped = fullPath, claimed
+	}
+	if s.logger != nil {
+		s.logger.Warn("manga paths differ only in case, indexing one of them", "kept", kept, "ignored", dropped)
+	}
+	seen[mangaID] = kept
+	return kept == fullPath
+}
+
+// dropCaseDuplicateSources removes chapter sources whose paths differ only
+// in case from an earlier one, keeping the path that sorts first byte-wise.
+// It is a no-op without CaseInsensitivePaths, where such paths are distinct
+// chapters.
+func (s *Service) dropCaseDuplicateSources(sources []chapterSource) []chapterSource {
+	if !s.options.CaseInsensitivePaths {
+		return sources
+	}
+	kept := make(map[string]int, len(sources))
+	result := sources[:0]
+	for _, source := range sources {
+		key := s.pathKey(source.Path)
+		i, ok := kept[key]
+		if !ok {
+			kept[key] = len(result)
+			result = append(result, source)
+			continue
+		}
+		dropped := source.Path
+		if source.Path < result[i].Path {
+			dropped = result[i].Path
+			result[i] = source
+		}
+		if s.logger != nil {
+			s.logger.Warn("chapter paths differ only in case, indexing one of them", "kept", result[i].Path, "ignored", dropped)
+		}
+	}
+	return result
+}
@@ -0,0 +1,108 @@
+package scan
+
+import (
+	"bytes"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCaseDuplicatePathsStayStable(t *testing.T) {
+	s, root := newTestService(t, Options{CaseInsensitivePaths: true})
+	var logs bytes.Buffer
+	s.logger = slog.New(slog.NewTextHandler(&logs, nil))
+	writeChapter(t, root, "alpha", "Chapter 1", 3)
+	writeChapter(t, root, "Alpha", "Chapter 1", 2)
+	writeChapter(t, root, "Alpha", "chapter 1", 4)
+	writeChapter(t, root, "Beta", "Chapter 1", 1)
+
+	type state struct {
+		id, path, chapterPath string
+		pages                 int
+	}
+	load := func() state {
+		t.Helper()
+		if got := countRows(t, s.db, `SELECT COUNT(*) FROM manga WHERE title_sort = 'alpha'`); got != 1 {
+			t.Fatalf("alpha manga = %d, want 1", got)
+		}
+		var st state
+		if err := s.db.QueryRow(`
+			SELECT m.id, m.path, c.path, c.page_count
+			FROM manga m JOIN chapter c ON c.manga_id = m.id
+			WHERE m.title_sort = 'alpha'
+		`).Scan(&st.id, &st.path, &st.chapterPath, &st.pages); err != nil {
+			t.Fatal(err)
+		}
+		return st
+	}
+
+	mustScan(t, s)
+	first := load()
+	want := state{id: first.id, path: filepath.Join(root, "Alpha"), chapterPath: filepath.Join(root, "Alpha", "Chapter 1"), pages: 2}
+	if first != want {
+		t.Fatalf("first scan = %+v, want the byte-wise first paths %+v", first, want)
+	}
+	for _, message := range []string{"manga paths differ only in case", "chapter paths differ only in case"} {
+		if !strings.Contains(logs.String(), message) {
+			t.Errorf("log is missing %q:\n%s", message, logs.String())
+		}
+	}
+
+	mustScan(t, s)
+	if second := load(); second != first {
+		t.Fatalf("second scan = %+v, want it unchanged from %+v", second, first)
+	}
+	if got := countRows(t, s.db, `SELECT COUNT(*) FROM manga`); got != 2 {
+		t.Fatalf("manga = %d, want 2", got)
+	}
+
+	// A folder renamed only in case keeps its id, and with it everything
+	// attached to the manga.
+	betaID := s.pathID("m", filepath.Join(root, "Beta"))
+	if err := os.Rename(filepath.Join(root, "Beta"), filepath.Join(root, "BETA")); err != nil {
+		t.Fatal(err)
+	}
+	mustScan(t, s)
+	if got := countRows(t, s.db, `SELECT COUNT(*) FROM manga WHERE id = ?`, betaID); got != 1 {
+		t.Fatalf("manga with the id from before the rename = %d, want 1", got)
+	}
+	if got := countRows(t, s.db, `SELECT COUNT(*) FROM manga`); got != 2 {
+		t.Fatalf("manga after the rename = %d, want 2", got)
+	}
+}
+
+func TestCaseDuplicateWinnerIgnoresListingOrder(t *testing.T) {
+	s, _ := newTestService(t, Options{CaseInsensitivePaths: true})
+	for _, order := range [][]string{{"/lib/Alpha", "/lib/alpha"}, {"/lib/alpha", "/lib/Alpha"}} {
+		seen := map[string]string{}
+		id := s.pathID("m", order[0])
+		kept := []bool{s.claimMangaPath(seen, id, order[0]), s.claimMangaPath(seen, id, order[1])}
+		if seen[id] != "/lib/Alpha" {
+			t.Errorf("order %q kept %q, want /lib/Alpha", order, seen[id])
+		}
+		if order[0] == "/lib/alpha" && !kept[1] {
+			t.Errorf("order %q did not scan the winner when it came second", order)
+		}
+
+		sources := s.dropCaseDuplicateSources([]chapterSource{{Path: order[0] + "/Ch 1"}, {Path: order[1] + "/Ch 1"}})
+		if len(sources) != 1 || sources[0].Path != "/lib/Alpha/Ch 1" {
+			t.Errorf("order %q kept chapter sources %+v, want /lib/Alpha/Ch 1", order, sources)
+		}
+	}
+
+	t.Run("case-sensitive paths stay distinct", func(t *testing.T) {
+		s, root := newTestService(t, Options{})
+		writeChapter(t, root, "alpha", "Chapter 1", 1)
+		writeChapter(t, root, "Alpha", "Chapter 1", 1)
+		writeChapter(t, root, "Alpha", "chapter 1", 1)
+		mustScan(t, s)
+		if got := countRows(t, s.db, `SELECT COUNT(*) FROM manga`); got != 2 {
+			t.Errorf("manga = %d, want 2", got)
+		}
+		if got := countRows(t, s.db, `SELECT COUNT(*) FROM chapter`); got != 3 {
+			t.Errorf("chapters = %d, want 3", got)
+		}
+	})
+}
@@ -0,0 +1,240 @@
+package scan
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"mynewmangaui/internal/media"
+)
+
+// Checksum modes pick when page checksums are computed. ChecksumInline
+// hashes new or changed pages during the scan, ChecksumLazy leaves them to
+// a background pass after it, and ChecksumOff leaves them to be computed
+// on demand by whoever needs one. Checksums of unchanged pages are kept
+// across rescans in every mode.
+const (
+	ChecksumInline = "inline"
+	ChecksumLazy   = "lazy"
+	ChecksumOff    = "off"
+)
+
+// checksumBackfillBatch is how many pages the background pass loads at a
+// time.
+const checksumBackfillBatch = 200
+
+// checksumBackfillPause is how often the background pass checks whether
+// the scan it yields to has finished, or scanning has resumed.
+const checksumBackfillPause = time.Second
+
+// storedChecksum is the checksum a page had before a rescan, kept while the
+// page's size and its chapter's modification time are unchanged.
+type storedChecksum struct {
+	SizeBytes        int64
+	ChapterUpdatedAt time.Time
+	Checksum         string
+}
+
+// fillChecksums copies the stored checksums of unchanged pages into record
+// and, in ChecksumInline mode, hashes the others. Hashing time counts as
+// decode time.
+func (s *Service) fillChecksums(ctx context.Context, mangaID string, record *mangaRecord) error {
+	stored, err := s.loadStoredChecksums(ctx, mangaID)
+	if err != nil {
+		return err
+	}
+
+	pdfChecksums := make(map[string]string)
+	for i := range record.Chapters {
+		chapter := &record.Chapters[i]
+		updatedAt := chapter.UpdatedAt.UTC().Truncate(time.Second)
+		for j := range chapter.Pages {
+			page := &chapter.Pages[j]
+			if previous, ok := stored[page.ID]; ok && previous.SizeBytes == page.SizeBytes && previous.ChapterUpdatedAt.Equal(updatedAt) {
+				page.Checksum = previous.Checksum
+				continue
+			}
+			if s.options.ChecksumMode != ChecksumInline {
+				continue
+			}
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			start := s.now()
+			checksum, err := s.pageChecksum(page.Path, pdfChecksums)
+			page.decodeTime += s.now().Sub(start)
+			if err != nil {
+				if s.logger != nil {
+					s.logger.Warn("failed to checksum page", "path", page.Path, "error", err)
+				}
+				continue
+			}
+			page.Checksum = checksum
+		}
+	}
+	return nil
+}
+
+func (s *Service) loadStoredChecksums(ctx context.Context, mangaID string) (map[string]storedChecksum, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT p.id, p.size_bytes, c.updated_at, p.checksum
+		FROM page p
+		JOIN chapter c ON c.id = p.chapter_id
+		WHERE c.manga_id = ? AND p.checksum IS NOT NULL
+	`, mangaID)
+	if err != nil {
+		return nil, fmt.Errorf("load page checksums: %w", err)
+	}
+	defer rows.Close()
+
+	stored := make(map[string]storedChecksum)
+	for rows.Next() {
+		var id string
+		var checksum storedChecksum
+		var updatedAt sql.NullTime
+		if err := rows.Scan(&id, &checksum.SizeBytes, &updatedAt, &checksum.Checksum); err != nil {
+			return nil, fmt.Errorf("scan page checksum: %w", err)
+		}
+		checksum.ChapterUpdatedAt = updatedAt.Time.UTC()
+		stored[id] = checksum
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate page checksums: %w", err)
+	}
+	return stored, nil
+}
+
+// pageChecksum hashes a page. PDFs are looked up in and added to
+// pdfChecksums, since all of their pages share the file's checksum.
+func (s *Service) pageChecksum(pathRef string, pdfChecksums map[string]string) (string, error) {
+	ref, err := media.ParseRef(pathRef)
+	if err != nil {
+		return "", err
+	}
+	if checksum, ok := pdfChecksums[ref.Path]; ok && ref.Kind == "pdf" {
+		return checksum, nil
+	}
+	checksum, _, err := media.PageChecksum(pathRef)
+	if err != nil {
+		return "", err
+	}
+	if ref.Kind == "pdf" {
+		pdfChecksums[ref.Path] = checksum
+	}
+	return checksum, nil
+}
+
+// RunChecksumBackfill fills in the checksums scans left empty when the
+// checksum mode is ChecksumLazy, until ctx is done. It makes a pass at
+// start, which picks up where an interrupted one stopped, and after every
+// successful scan. Pages are hashed one at a time and the pass pauses while
+// a scan runs, so it never competes with one for disk or database.
+func (s *Service) RunChecksumBackfill(ctx context.Context) {
+	if s.options.ChecksumMode != ChecksumLazy {
+		return
+	}
+	for {
+		if err := s.BackfillChecksums(ctx); err != nil && ctx.Err() == nil && s.logger != nil {
+			s.logger.Warn("checksum backfill failed", "error", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-s.checksumWake:
+		}
+	}
+}
+
+// BackfillChecksums makes one pass over the pages without a checksum. Pages
+// that cannot be read are skipped until the next pass.
+func (s *Service) BackfillChecksums(ctx context.Context) error {
+	if s.db == nil {
+		return fmt.Errorf("database not initialized")
+	}
+
+	filled := 0
+	after := ""
+	pdfChecksums := make(map[string]string)
+	for {
+		batch, err := s.pagesWithoutChecksum(ctx, after)
+		if err != nil {
+			return err
+		}
+		if len(batch) == 0 {
+			break
+		}
+		for _, page := range batch {
+			after = page.ID
+			if err := s.waitForIdleScanner(ctx); err != nil {
+				return err
+			}
+			checksum, err := s.pageChecksum(page.Path, pdfChecksums)
+			if err != nil {
+				if s.logger != nil {
+					s.logger.Debug("failed to checksum page", "path", page.Path, "error", err)
+				}
+				continue
+			}
+			// The path guards against the page having been replaced by a
+			// rescan while it was hashed.
+			if _, err := s.db.ExecContext(ctx, `
+				UPDATE page SET checksum = ? WHERE id = ? AND path = ? AND checksum IS NULL
+			`, checksum, page.ID, page.Path); err != nil {
+				return fmt.Errorf("save page checksum: %w", err)
+			}
+			filled++
+		}
+	}
+	if filled > 0 && s.logger != nil {
+		s.logger.Info("checksum backfill complete", "pages", filled)
+	}
+	return nil
+}
+
+func (s *Service) pagesWithoutChecksum(ctx context.Context, after string) ([]pageRecord, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT id, path
+		FROM page
+		WHERE checksum IS NULL AND id > ?
+		ORDER BY id ASC
+		LIMIT ?
+	`, after, checksumBackfillBatch)
+	if err != nil {
+		return nil, fmt.Errorf("load pages without checksum: %w", err)
+	}
+	defer rows.Close()
+
+	pages := make([]pageRecord, 0, checksumBackfillBatch)
+	for rows.Next() {
+		var page pageRecord
+		if err := rows.Scan(&page.ID, &page.Path); err != nil {
+			return nil, fmt.Errorf("scan page without checksum: %w", err)
+		}
+		pages = append(pages, page)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate pages without checksum: %w", err)
+	}
+	return pages, nil
+}
+
+func (s *Service) waitForIdleScanner(ctx context.Context) error {
+	for s.Status().Running || s.paused.Load() {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(checksumBackfillPause):
+		}
+	}
+	return ctx.Err()
+}
+
+// wakeChecksumBackfill starts another backfill pass once the current one,
+// if any, is done.
+func (s *Service) wakeChecksumBackfill() {
+	select {
+	case s.checksumWake <- struct{}{}:
+	default:
+	}
+}
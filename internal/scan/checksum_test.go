@@ -0,0 +1,54 @@
+package scan
+
+import (
+	"context"
+	"testing"
+)
+
+func TestLazyChecksumBackfill(t *testing.T) {
+	s, root := newTestService(t, Options{ChecksumMode: ChecksumLazy})
+	writeChapter(t, root, "Alpha", "Chapter 1", 3)
+	writeChapter(t, root, "Beta", "Chapter 1", 2)
+	mustScan(t, s)
+
+	if missing := countRows(t, s.db, `SELECT COUNT(*) FROM page WHERE checksum IS NULL`); missing != 5 {
+		t.Fatalf("pages without checksum after a lazy scan = %d, want 5", missing)
+	}
+
+	if err := s.BackfillChecksums(context.Background()); err != nil {
+		t.Fatalf("backfill: %v", err)
+	}
+	if missing := countRows(t, s.db, `SELECT COUNT(*) FROM page WHERE checksum IS NULL OR checksum = ''`); missing != 0 {
+		t.Fatalf("pages without checksum after backfill = %d, want 0", missing)
+	}
+}
+
+func TestLazyChecksumBackfillResumes(t *testing.T) {
+	s, root := newTestService(t, Options{ChecksumMode: ChecksumLazy})
+	writeChapter(t, root, "Alpha", "Chapter 1", 4)
+	mustScan(t, s)
+
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := s.BackfillChecksums(cancelled); err == nil {
+		t.Fatal("cancelled backfill returned no error")
+	}
+	if missing := countRows(t, s.db, `SELECT COUNT(*) FROM page WHERE checksum IS NULL`); missing != 4 {
+		t.Fatalf("pages without checksum after a cancelled pass = %d, want 4", missing)
+	}
+
+	// A pass that stopped part way leaves the pages it reached filled in;
+	// the next one only hashes the rest.
+	if _, err := s.db.Exec(`UPDATE page SET checksum = 'kept' WHERE page_index < 2`); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.BackfillChecksums(context.Background()); err != nil {
+		t.Fatalf("backfill: %v", err)
+	}
+	if kept := countRows(t, s.db, `SELECT COUNT(*) FROM page WHERE checksum = 'kept'`); kept != 2 {
+		t.Errorf("checksums kept from the interrupted pass = %d, want 2", kept)
+	}
+	if missing := countRows(t, s.db, `SELECT COUNT(*) FROM page WHERE checksum IS NULL`); missing != 0 {
+		t.Errorf("pages without checksum after resuming = %d, want 0", missing)
+	}
+}
@@ -0,0 +1,40 @@
+package scan
+
+import (
+	"strconv"
+	"strings"
+
+	"mynewmangaui/internal/media"
+)
+
+// readComicInfo reads the ComicInfo.xml of a chapter, which sits inside the
+// chapter when it is an archive and next to its pages otherwise.
+func readComicInfo(path string) (media.ComicInfo, bool, error) {
+	if media.IsArchiveFile(path) {
+		return media.ReadArchiveComicInfo(path)
+	}
+	return media.ReadComicInfo(path)
+}
+
+// applyComicInfo overrides the title, number and volume a chapter got from
+// its file name with the ones in its ComicInfo.xml. Values that are missing
+// or do not parse leave the file name ones in place. A PageCount that
+// disagrees with the pages found is only reported.
+func (s *Service) applyComicInfo(chapter *chapterRecord, info media.ComicInfo) {
+	if title := cleanDisplayTitle(info.Title); title != "" {
+		chapter.Title = title
+	}
+	if raw := strings.TrimSpace(info.Number); raw != "" {
+		if number, err := strconv.ParseFloat(raw, 64); err == nil && number >= 0 && number <= s.maxChapterNumber() {
+			chapter.Number = &number
+		}
+	}
+	if raw := strings.TrimSpace(info.Volume); raw != "" {
+		if volume, err := strconv.Atoi(raw); err == nil && volume >= 0 {
+			chapter.Volume = &volume
+		}
+	}
+	if info.PageCount > 0 && info.PageCount != chapter.PageCount && s.logger != nil {
+		s.logger.Warn("comicinfo page count differs from pages found", "path", chapter.Path, "comicinfo", info.PageCount, "found", chapter.PageCount)
+	}
+}
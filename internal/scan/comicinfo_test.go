@@ -0,0 +1,142 @@
+package scan
+
+import (
+	"bytes"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"mynewmangaui/internal/media"
+)
+
+func TestArchiveComicInfo(t *testing.T) {
+	comicInfo := func(fields string) string {
+		return "<?xml version=\"1.0\"?>\n<ComicInfo>" + fields + "</ComicInfo>"
+	}
+	type chapter struct {
+		title  string
+		number string
+		volume string
+	}
+	chapters := func(t *testing.T, s *Service) map[string]chapter {
+		t.Helper()
+		rows, err := s.db.Query(`SELECT path, title, COALESCE(chapter_number, ''), COALESCE(volume, '') FROM chapter`)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer rows.Close()
+		got := map[string]chapter{}
+		for rows.Next() {
+			var path string
+			var c chapter
+			if err := rows.Scan(&path, &c.title, &c.number, &c.volume); err != nil {
+				t.Fatal(err)
+			}
+			got[filepath.Base(path)] = c
+		}
+		if err := rows.Err(); err != nil {
+			t.Fatal(err)
+		}
+		return got
+	}
+
+	t.Run("chapter archives", func(t *testing.T) {
+		s, root := newTestService(t, Options{TitleSource: TitleFromComicInfo})
+		var logs bytes.Buffer
+		s.logger = slog.New(slog.NewTextHandler(&logs, nil))
+		manga := filepath.Join(root, "alpha raw")
+		if err := os.MkdirAll(manga, 0o755); err != nil {
+			t.Fatal(err)
+		}
+		writeCBZ(t, filepath.Join(manga, "c001.cbz"), map[string]string{
+			"01.png":                "",
+			"02.png":                "",
+			media.ComicInfoFileName: comicInfo("<Series>Alpha</Series><Title>The Beginning</Title><Number>12.5</Number><Volume>3</Volume><PageCount>2</PageCount>"),
+		})
+		writeCBZ(t, filepath.Join(manga, "c002.cbz"), map[string]string{
+			"01.png":                "",
+			media.ComicInfoFileName: comicInfo("<Series>Alpha</Series><Number>abc</Number><Volume>-1</Volume><PageCount>5</PageCount>"),
+		})
+		writeCBZ(t, filepath.Join(manga, "Chapter 7.cbz"), map[string]string{"01.png": ""})
+		mustScan(t, s)
+
+		var title string
+		if err := s.db.QueryRow(`SELECT title FROM manga`).Scan(&title); err != nil {
+			t.Fatal(err)
+		}
+		if title != "Alpha" {
+			t.Errorf("manga title = %q, want the ComicInfo series", title)
+		}
+
+		got := chapters(t, s)
+		if want := (chapter{title: "The Beginning", number: "12.5", volume: "3"}); got["c001.cbz"] != want {
+			t.Errorf("c001.cbz = %+v, want %+v", got["c001.cbz"], want)
+		}
+		// Values that do not parse leave the file name ones in place, as
+		// does an archive without a ComicInfo.xml.
+		for name, number := range map[string]string{"c002.cbz": "2", "Chapter 7.cbz": "7"} {
+			if c := got[name]; c.number != number || c.volume != "" || c.title == "" {
+				t.Errorf("%s = %+v, want number %s from the file name and no volume", name, c, number)
+			}
+		}
+
+		// Only the archive whose page count disagrees is reported.
+		if n := strings.Count(logs.String(), "comicinfo page count differs"); n != 1 || !strings.Contains(logs.String(), "c002.cbz") {
+			t.Errorf("page count warnings = %d in:\n%s", n, logs.String())
+		}
+	})
+
+	t.Run("single archive manga", func(t *testing.T) {
+		s, root := newTestService(t, Options{TitleSource: TitleFromComicInfo})
+		writeCBZ(t, filepath.Join(root, "beta_v01.cbz"), map[string]string{
+			"01.png":                "",
+			media.ComicInfoFileName: comicInfo("<Series>Beta Saga</Series>"),
+		})
+		writeCBZ(t, filepath.Join(root, "Gamma.cbz"), map[string]string{"01.png": ""})
+		mustScan(t, s)
+
+		rows, err := s.db.Query(`SELECT title FROM manga ORDER BY title`)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer rows.Close()
+		titles := []string{}
+		for rows.Next() {
+			var title string
+			if err := rows.Scan(&title); err != nil {
+				t.Fatal(err)
+			}
+			titles = append(titles, title)
+		}
+		if strings.Join(titles, "|") != "Beta Saga|Gamma" {
+			t.Fatalf("titles = %q, want the ComicInfo series and the archive name", titles)
+		}
+	})
+
+	t.Run("folder title source", func(t *testing.T) {
+		s, root := newTestService(t, Options{})
+		manga := filepath.Join(root, "Delta")
+		if err := os.MkdirAll(manga, 0o755); err != nil {
+			t.Fatal(err)
+		}
+		writeCBZ(t, filepath.Join(manga, "c001.cbz"), map[string]string{
+			"01.png":                "",
+			media.ComicInfoFileName: comicInfo("<Series>Other</Series><Title>Opening</Title><Number>4</Number>"),
+		})
+		mustScan(t, s)
+
+		var title string
+		if err := s.db.QueryRow(`SELECT title FROM manga`).Scan(&title); err != nil {
+			t.Fatal(err)
+		}
+		if title != "Delta" {
+			t.Errorf("manga title = %q, want the folder name", title)
+		}
+		// Chapter metadata applies whatever the title source.
+		if got := chapters(t, s)["c001.cbz"]; got.title != "Opening" || got.number != "4" {
+			t.Errorf("chapter = %+v, want the ComicInfo title and number", got)
+		}
+	})
+}
@@ -0,0 +1,121 @@
+package scan
+
+import (
+	"fmt"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"mynewmangaui/internal/media"
+)
+
+// regexpPagePatternPrefix marks an exclusion pattern as a regular
+// expression rather than a glob.
+const regexpPagePatternPrefix = "re:"
+
+// PageFilter leaves out pages whose file names match any of its patterns,
+// such as the credits or ad page a scanlation group appends to every
+// chapter. The zero value keeps every page.
+type PageFilter struct {
+	globs   []string
+	regexps []*regexp.Regexp
+}
+
+// CompilePageFilter compiles page exclusion patterns. A pattern is a glob
+// matched against the page's file name, case-insensitively, unless it
+// starts with "re:", in which case the rest is a regular expression
+// searched for in the file name.
+func CompilePageFilter(patterns []string) (PageFilter, error) {
+	var filter PageFilter
+	for _, pattern := range patterns {
+		pattern = strings.TrimSpace(pattern)
+		if pattern == "" {
+			continue
+		}
+		if expr, ok := strings.CutPrefix(pattern, regexpPagePatternPrefix); ok {
+			re, err := regexp.Compile(expr)
+			if err != nil {
+				return PageFilter{}, fmt.Errorf("page pattern %q: %w", pattern, err)
+			}
+			filter.regexps = append(filter.regexps, re)
+			continue
+		}
+		glob := strings.ToLower(pattern)
+		if _, err := path.Match(glob, ""); err != nil {
+			return PageFilter{}, fmt.Errorf("page pattern %q: %w", pattern, err)
+		}
+		filter.globs = append(filter.globs, glob)
+	}
+	return filter, nil
+}
+
+// Excludes reports whether the page with the given file name, or archive
+// entry name, is left out.
+func (f PageFilter) Excludes(name string) bool {
+	name = path.Base(filepath.ToSlash(name))
+	lower := strings.ToLower(name)
+	for _, glob := range f.globs {
+		if ok, _ := path.Match(glob, lower); ok {
+			return true
+		}
+	}
+	for _, re := range f.regexps {
+		if re.MatchString(name) {
+			return true
+		}
+	}
+	return false
+}
+
+func (f PageFilter) empty() bool {
+	return len(f.globs) == 0 && len(f.regexps) == 0
+}
+
+// keptPaths drops the excluded image paths and returns how many it dropped.
+func (f PageFilter) keptPaths(paths []string) ([]string, int) {
+	if f.empty() {
+		return paths, 0
+	}
+	kept := make([]string, 0, len(paths))
+	for _, p := range paths {
+		if !f.Excludes(p) {
+			kept = append(kept, p)
+		}
+	}
+	return kept, len(paths) - len(kept)
+}
+
+// keptEntries drops the excluded archive entries and returns how many it
+// dropped.
+func (f PageFilter) keptEntries(entries []media.ArchiveEntry) ([]media.ArchiveEntry, int) {
+	if f.empty() {
+		return entries, 0
+	}
+	kept := make([]media.ArchiveEntry, 0, len(entries))
+	for _, entry := range entries {
+		if !f.Excludes(entry.Name) {
+			kept = append(kept, entry)
+		}
+	}
+	return kept, len(entries) - len(kept)
+}
+
+// mangaPageFilter returns the filter for a manga folder: the configured
+// one, unless the folder's metadata.json lists its own excludePages, which
+// replace the configured patterns, an empty list turning exclusion off.
+// Patterns that do not compile are reported and the configured filter is
+// kept.
+func (s *Service) mangaPageFilter(path string, metadata directoryMetadata) PageFilter {
+	if metadata.ExcludePages == nil {
+		return s.pageFilter
+	}
+	filter, err := CompilePageFilter(*metadata.ExcludePages)
+	if err != nil {
+		if s.logger != nil {
+			s.logger.Warn("ignoring invalid excludePages in metadata.json", "path", path, "error", err)
+		}
+		return s.pageFilter
+	}
+	return filter
+}
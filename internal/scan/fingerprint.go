@@ -0,0 +1,64 @@
+package scan
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"time"
+
+	"mynewmangaui/internal/media"
+)
+
+// mangaFingerprint summarizes a manga's source without opening any file:
+// the newest modification time and the total size of everything in its
+// folder, or of its archive, and the scan options that shape what a scan
+// makes of them. Library scans skip manga whose fingerprint matches the
+// one stored when they were last indexed.
+//
+// It returns "", which matches nothing stored, when the source cannot be
+// read or changed within the incomplete window: chapters still being
+// written may be deferred or flagged, so the manga is read again until
+// they settle.
+func (s *Service) mangaFingerprint(bookshelfID string, path string) string {
+	var modTime time.Time
+	var size int64
+	err := media.WalkDir(path, func(_ string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+		// Directory times catch files removed or renamed inside them.
+		modTime = maxTime(modTime, info.ModTime())
+		if !entry.IsDir() {
+			size += info.Size()
+		}
+		return nil
+	})
+	if err != nil || s.stillChanging(modTime) {
+		return ""
+	}
+	return fmt.Sprintf("%d-%d-%s", modTime.UnixNano(), size, s.discoveryOptionsKey(bookshelfID))
+}
+
+// discoveryOptionsKey changes whenever an option that shapes how a manga
+// is read from disk does, so such a change rescans everything once.
+func (s *Service) discoveryOptionsKey(bookshelfID string) string {
+	options := s.options
+	options.Clock = nil
+	options.MaxQueuedScans = 0
+	var titlePattern string
+	if re := s.titlePatterns[bookshelfID]; re != nil {
+		titlePattern = re.String()
+	}
+	raw, _ := json.Marshal(struct {
+		Options
+		TitlePattern string
+	}{options, titlePattern})
+	sum := sha1.Sum(raw)
+	return hex.EncodeToString(sum[:8])
+}
@@ -0,0 +1,202 @@
+package scan
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"mynewmangaui/internal/clock"
+)
+
+// scanLog runs scan under a fresh run id and returns what it did per manga
+// folder name.
+func scanLog(t *testing.T, s *Service, scan func(context.Context) (Summary, error)) (map[string]RunLogEntry, Summary, error) {
+	t.Helper()
+	runID := NewRunID()
+	summary, err := scan(WithRunID(context.Background(), runID))
+	log, ok := s.RunLog(runID)
+	if !ok {
+		t.Fatalf("no run log for %s", runID)
+	}
+	entries := make(map[string]RunLogEntry, len(log.Entries))
+	for _, entry := range log.Entries {
+		entries[filepath.Base(entry.Path)] = entry
+	}
+	return entries, summary, err
+}
+
+func TestScanSkipsUnchangedManga(t *testing.T) {
+	s, root := newTestService(t, Options{})
+	writeChapter(t, root, "Alpha", "Chapter 1", 2)
+	writeChapter(t, root, "Beta", "Chapter 1", 2)
+	mustScan(t, s)
+
+	// Rows a rescan would replace keep their marker only if left alone.
+	if _, err := s.db.Exec(`UPDATE chapter SET title = 'kept'`); err != nil {
+		t.Fatal(err)
+	}
+	writePNG(t, filepath.Join(root, "Beta", "Chapter 1", "c.png"), 10, 12)
+
+	entries, summary, err := scanLog(t, s, s.Scan)
+	if err != nil {
+		t.Fatalf("scan: %v", err)
+	}
+	if entry := entries["Alpha"]; entry.Action != RunActionSkipped || entry.Pages != 2 {
+		t.Errorf("unchanged manga = %+v, want skipped with 2 pages", entry)
+	}
+	if entry := entries["Beta"]; entry.Action != RunActionUpdated || entry.Pages != 3 {
+		t.Errorf("changed manga = %+v, want updated with 3 pages", entry)
+	}
+	if summary.MangaCount != 2 || summary.PageCount != 5 {
+		t.Errorf("summary = %+v, want 2 manga and 5 pages", summary)
+	}
+	if kept := countRows(t, s.db, `SELECT COUNT(*) FROM chapter WHERE title = 'kept'`); kept != 1 {
+		t.Errorf("chapters left untouched = %d, want 1", kept)
+	}
+}
+
+func TestScanRereadsMangaWhenOptionsChange(t *testing.T) {
+	s, root := newTestService(t, Options{})
+	writeChapter(t, root, "Alpha", "Chapter 1", 2)
+	writePNG(t, filepath.Join(root, "Alpha", "Chapter 1", ".hidden.png"), 8, 12)
+	mustScan(t, s)
+	if pages := countRows(t, s.db, `SELECT COUNT(*) FROM page`); pages != 3 {
+		t.Fatalf("pages = %d, want 3", pages)
+	}
+
+	reconfigured := NewService(s.db, s.bookshelves, Options{SkipHiddenFiles: true}, testLogger())
+	entries, _, err := scanLog(t, reconfigured, reconfigured.Scan)
+	if err != nil {
+		t.Fatalf("scan: %v", err)
+	}
+	if entry := entries["Alpha"]; entry.Action != RunActionUpdated {
+		t.Errorf("manga after an option change = %+v, want updated", entry)
+	}
+	if pages := countRows(t, s.db, `SELECT COUNT(*) FROM page`); pages != 2 {
+		t.Errorf("pages = %d, want 2", pages)
+	}
+}
+
+func TestScanRereadsMangaWithinIncompleteWindow(t *testing.T) {
+	now := clock.NewFake(time.Now())
+	s, root := newTestService(t, Options{IncompleteChapterWindow: time.Hour, DeferIncompleteChapters: true, Clock: now})
+	settled := writeChapter(t, root, "Alpha", "Chapter 1", 2)
+	old := now.Now().Add(-2 * time.Hour)
+	for _, path := range []string{filepath.Join(settled, "a.png"), filepath.Join(settled, "b.png"), settled} {
+		if err := os.Chtimes(path, old, old); err != nil {
+			t.Fatal(err)
+		}
+	}
+	writeChapter(t, root, "Alpha", "Chapter 2", 2)
+	mustScan(t, s)
+	if chapters := countRows(t, s.db, `SELECT COUNT(*) FROM chapter`); chapters != 1 {
+		t.Fatalf("chapters indexed while one is still changing = %d, want 1", chapters)
+	}
+
+	// Nothing changes on disk, but once the files are older than the
+	// window the deferred chapter must be picked up.
+	now.Advance(2 * time.Hour)
+	mustScan(t, s)
+	if chapters := countRows(t, s.db, `SELECT COUNT(*) FROM chapter`); chapters != 2 {
+		t.Errorf("chapters once settled = %d, want 2", chapters)
+	}
+}
+
+func TestResumeInterruptedScan(t *testing.T) {
+	s, root := newTestService(t, Options{})
+	writeChapter(t, root, "Alpha", "Chapter 1", 2)
+	writeChapter(t, root, "Beta", "Chapter 1", 2)
+	// The broken archive sorts last and fails the scan after Alpha and
+	// Beta were committed, as a crash there would.
+	broken := filepath.Join(root, "Gamma.cbz")
+	if err := os.WriteFile(broken, []byte("not a zip"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Scan(context.Background()); err == nil {
+		t.Fatal("scan over a broken archive returned no error")
+	}
+	if pending, err := s.HasPendingScan(context.Background()); err != nil || !pending {
+		t.Fatalf("pending scan = %v, %v; want true", pending, err)
+	}
+
+	// Beta changes while the server is down; Gamma is fixed.
+	writePNG(t, filepath.Join(root, "Beta", "Chapter 1", "c.png"), 10, 12)
+	if err := os.Remove(broken); err != nil {
+		t.Fatal(err)
+	}
+	writeChapter(t, root, "Gamma", "Chapter 1", 1)
+
+	entries, summary, err := scanLog(t, s, s.Resume)
+	if err != nil {
+		t.Fatalf("resume: %v", err)
+	}
+	if entry := entries["Alpha"]; entry.Action != RunActionSkipped || entry.Detail != "already scanned before the run was interrupted" {
+		t.Errorf("Alpha = %+v, want skipped as already scanned", entry)
+	}
+	if entry := entries["Beta"]; entry.Action != RunActionUpdated || entry.Pages != 3 {
+		t.Errorf("Beta = %+v, want updated with 3 pages", entry)
+	}
+	if entry := entries["Gamma"]; entry.Action != RunActionAdded {
+		t.Errorf("Gamma = %+v, want added", entry)
+	}
+	if summary.MangaCount != 3 || summary.PageCount != 6 {
+		t.Errorf("summary = %+v, want 3 manga and 6 pages", summary)
+	}
+	if pending, err := s.HasPendingScan(context.Background()); err != nil || pending {
+		t.Errorf("pending scan after resuming = %v, %v; want false", pending, err)
+	}
+}
+
+// setModTime sets the modification time of paths to at.
+func setModTime(t *testing.T, at time.Time, paths ...string) {
+	t.Helper()
+	for _, path := range paths {
+		if err := os.Chtimes(path, at, at); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestScanFollowsClock(t *testing.T) {
+	start := time.Date(2030, 1, 1, 12, 0, 0, 0, time.UTC)
+	now := clock.NewFake(start)
+	s, root := newTestService(t, Options{IncompleteChapterWindow: 10 * time.Minute, Clock: now})
+	chapter := func(title string) (firstSeen time.Time, incomplete bool) {
+		t.Helper()
+		if err := s.db.QueryRow(`SELECT first_seen_at, possibly_incomplete FROM chapter WHERE title = ?`, title).Scan(&firstSeen, &incomplete); err != nil {
+			t.Fatalf("chapter %s: %v", title, err)
+		}
+		return firstSeen, incomplete
+	}
+
+	first := writeChapter(t, root, "Alpha", "Chapter 1", 1)
+	setModTime(t, start.Add(-5*time.Minute), filepath.Join(first, "a.png"), first, filepath.Join(root, "Alpha"))
+	mustScan(t, s)
+	if firstSeen, incomplete := chapter("Chapter 1"); !firstSeen.Equal(start) || !incomplete {
+		t.Fatalf("Chapter 1 = first seen %v, incomplete %v; want 12:00 and incomplete", firstSeen, incomplete)
+	}
+
+	// An hour later Chapter 1 has settled and Chapter 2 has appeared.
+	now.Advance(time.Hour)
+	second := writeChapter(t, root, "Alpha", "Chapter 2", 1)
+	setModTime(t, start.Add(30*time.Minute), filepath.Join(second, "a.png"), second, filepath.Join(root, "Alpha"))
+	mustScan(t, s)
+	if firstSeen, incomplete := chapter("Chapter 1"); !firstSeen.Equal(start) || incomplete {
+		t.Errorf("Chapter 1 = first seen %v, incomplete %v; want 12:00 and settled", firstSeen, incomplete)
+	}
+	if firstSeen, incomplete := chapter("Chapter 2"); !firstSeen.Equal(start.Add(time.Hour)) || incomplete {
+		t.Errorf("Chapter 2 = first seen %v, incomplete %v; want 13:00 and settled", firstSeen, incomplete)
+	}
+
+	now.Advance(time.Hour)
+	if err := os.RemoveAll(second); err != nil {
+		t.Fatal(err)
+	}
+	mustScan(t, s)
+	var removedAt time.Time
+	if err := s.db.QueryRow(`SELECT removed_at FROM chapter_tombstone`).Scan(&removedAt); err != nil || !removedAt.Equal(start.Add(2*time.Hour)) {
+		t.Fatalf("tombstone removed at %v, %v; want 14:00", removedAt, err)
+	}
+}
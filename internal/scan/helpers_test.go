@@ -0,0 +1,120 @@
+package scan
+
+import (
+	"archive/zip"
+	"context"
+	"database/sql"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"log/slog"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"mynewmangaui/internal/db"
+)
+
+func testLogger() *slog.Logger {
+	return slog.New(slog.NewTextHandler(io.Discard, nil))
+}
+
+// newTestService returns a scanner over a fresh database with one
+// bookshelf, named main, at a temporary root.
+func newTestService(t testing.TB, options Options) (*Service, string) {
+	t.Helper()
+	database, err := db.OpenAndMigrate(context.Background(), filepath.Join(t.TempDir(), "app.db"), nil, nil, testLogger())
+	if err != nil {
+		t.Fatalf("open database: %v", err)
+	}
+	t.Cleanup(func() { database.Close() })
+	root := t.TempDir()
+	return NewService(database, []Bookshelf{{Name: "main", Path: root}}, options, testLogger()), root
+}
+
+func mustScan(t *testing.T, s *Service) Summary {
+	t.Helper()
+	summary, err := s.Scan(context.Background())
+	if err != nil {
+		t.Fatalf("scan: %v", err)
+	}
+	return summary
+}
+
+// countRows returns the single integer query selects.
+func countRows(t *testing.T, database *sql.DB, query string, args ...any) int {
+	t.Helper()
+	var count int
+	if err := database.QueryRow(query, args...).Scan(&count); err != nil {
+		t.Fatalf("query %q: %v", query, err)
+	}
+	return count
+}
+
+// writeChapter writes a chapter directory of pages PNG pages under the
+// manga directory of root.
+func writeChapter(t testing.TB, root string, manga string, chapter string, pages int) string {
+	t.Helper()
+	dir := filepath.Join(root, manga, chapter)
+	for index := 0; index < pages; index++ {
+		writePNG(t, filepath.Join(dir, string(rune('a'+index))+".png"), 8+index, 12)
+	}
+	return dir
+}
+
+// writePNG writes a width x height PNG, creating its directory.
+func writePNG(t testing.TB, path string, width int, height int) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			img.Set(x, y, color.RGBA{R: uint8(x * 20), G: uint8(y * 20), B: 90, A: 255})
+		}
+	}
+	file, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	if err := png.Encode(file, img); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// writeCBZ writes an archive holding the given entries; entries named
+// *.png without content get a small PNG page.
+func writeCBZ(t testing.TB, path string, entries map[string]string) {
+	t.Helper()
+	file, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	archive := zip.NewWriter(file)
+	for _, name := range slices.Sorted(maps.Keys(entries)) {
+		content := []byte(entries[name])
+		if len(content) == 0 && filepath.Ext(name) == ".png" {
+			page := filepath.Join(t.TempDir(), "page.png")
+			writePNG(t, page, 8, 12)
+			if content, err = os.ReadFile(page); err != nil {
+				t.Fatal(err)
+			}
+		}
+		entry, err := archive.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := entry.Write(content); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := archive.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
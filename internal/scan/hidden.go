@@ -0,0 +1,31 @@
+package scan
+
+import (
+	"strings"
+
+	"mynewmangaui/internal/media"
+)
+
+// visibleArchiveEntries drops archive entries that are hidden or sit in a
+// hidden folder when SkipHiddenFiles is set.
+func (s *Service) visibleArchiveEntries(entries []media.ArchiveEntry) []media.ArchiveEntry {
+	if !s.options.SkipHiddenFiles {
+		return entries
+	}
+	visible := entries[:0]
+	for _, entry := range entries {
+		if !hiddenEntry(entry.Name) {
+			visible = append(visible, entry)
+		}
+	}
+	return visible
+}
+
+func hiddenEntry(name string) bool {
+	for _, part := range strings.Split(name, "/") {
+		if media.IsHiddenName(part) {
+			return true
+		}
+	}
+	return false
+}
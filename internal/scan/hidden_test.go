@@ -0,0 +1,117 @@
+package scan
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"mynewmangaui/internal/media"
+)
+
+// thumbsDB starts like the OLE compound file Windows writes as Thumbs.db.
+const thumbsDB = "\xD0\xCF\x11\xE0\xA1\xB1\x1A\xE1\x00\x00\x00\x00\x00\x00\x00\x00"
+
+// avifHeader starts an AVIF file whose major brand is the generic mif1,
+// as some encoders write them, with avif only among the compatible brands.
+const avifHeader = "\x00\x00\x00\x1cftypmif1\x00\x00\x00\x00mif1avifmiaf\x00\x00\x00\x08free"
+
+// writeSystemFileFixture lays out a library with the files macOS and
+// Windows leave behind next to two real manga, whose pages include AVIF
+// images the MIME sniffer only knows by their brands.
+func writeSystemFileFixture(t *testing.T, root string) {
+	t.Helper()
+	chapter := writeChapter(t, root, "Alpha", "Chapter 1", 2)
+	writeChapter(t, root, ".hidden", "Chapter 1", 1)
+	writeChapter(t, root, filepath.Join("Alpha", ".trash"), "Chapter 9", 1)
+	writePNG(t, filepath.Join(chapter, ".thumbs", "a.png"), 4, 4)
+	for name, content := range map[string]string{
+		".DS_Store":   "\x00\x00\x00\x01Bud1",
+		"._a.png":     "\x00\x05\x16\x07\x00\x02\x00\x00Mac OS X",
+		"Thumbs.db":   thumbsDB,
+		"renamed.jpg": thumbsDB,
+		"notes.png":   "chapter notes, not a page\n",
+		"c.avif":      avifHeader,
+	} {
+		if err := os.WriteFile(filepath.Join(chapter, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	writeCBZ(t, filepath.Join(root, "Beta.cbz"), map[string]string{
+		"01.png":                "",
+		"02.jpg":                thumbsDB,
+		".DS_Store":             "Bud1",
+		"__MACOSX/._01.png":     "Mac OS X",
+		".hidden/03.png":        "",
+		"04.avif":               avifHeader,
+		media.ComicInfoFileName: "<ComicInfo/>",
+	})
+}
+
+func TestSkipSystemFiles(t *testing.T) {
+	pages := func(t *testing.T, s *Service, title string) []string {
+		t.Helper()
+		rows, err := s.db.Query(`
+			SELECT p.path FROM page p
+			JOIN chapter c ON c.id = p.chapter_id
+			JOIN manga m ON m.id = c.manga_id
+			WHERE m.title = ?
+			ORDER BY c.path, p.page_index
+		`, title)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer rows.Close()
+		names := []string{}
+		for rows.Next() {
+			var path string
+			if err := rows.Scan(&path); err != nil {
+				t.Fatal(err)
+			}
+			names = append(names, filepath.Base(path))
+		}
+		if err := rows.Err(); err != nil {
+			t.Fatal(err)
+		}
+		return names
+	}
+
+	s, root := newTestService(t, Options{SkipHiddenFiles: true, SniffMime: true})
+	writeSystemFileFixture(t, root)
+	mustScan(t, s)
+
+	if got := countRows(t, s.db, `SELECT COUNT(*) FROM manga`); got != 2 {
+		t.Fatalf("manga = %d, want Alpha and Beta only", got)
+	}
+	if got := countRows(t, s.db, `SELECT COUNT(*) FROM chapter`); got != 2 {
+		t.Fatalf("chapters = %d, want one each", got)
+	}
+	if got := pages(t, s, "Alpha"); !slices.Equal(got, []string{"a.png", "b.png", "c.avif"}) {
+		t.Errorf("Alpha pages = %q, want the three real images", got)
+	}
+	if got := pages(t, s, "Beta"); len(got) != 2 {
+		t.Errorf("Beta pages = %q, want only 01.png and 04.avif", got)
+	}
+	if got := countRows(t, s.db, `SELECT COUNT(*) FROM page WHERE mime = 'image/avif'`); got != 2 {
+		t.Errorf("AVIF pages = %d, want both kept as image/avif", got)
+	}
+	// Pages left out do not leave gaps in the numbering.
+	if got := countRows(t, s.db, `SELECT COUNT(*) FROM page p JOIN chapter c ON c.id = p.chapter_id WHERE p.page_index >= c.page_count`); got != 0 {
+		t.Errorf("pages numbered past the kept ones = %d", got)
+	}
+	if got := countRows(t, s.db, `SELECT COUNT(*) FROM chapter WHERE page_count != (SELECT COUNT(*) FROM page WHERE chapter_id = chapter.id)`); got != 0 {
+		t.Errorf("chapters whose page count disagrees with their pages = %d", got)
+	}
+
+	t.Run("without skipping", func(t *testing.T) {
+		s, root := newTestService(t, Options{})
+		writeSystemFileFixture(t, root)
+		mustScan(t, s)
+		if got := countRows(t, s.db, `SELECT COUNT(*) FROM manga`); got != 3 {
+			t.Errorf("manga = %d, want the hidden folder indexed too", got)
+		}
+		if got := pages(t, s, "Alpha"); !slices.Contains(got, "renamed.jpg") || !slices.Contains(got, "._a.png") {
+			t.Errorf("Alpha pages = %q, want the unsniffed system files included", got)
+		}
+	})
+}
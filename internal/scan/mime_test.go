@@ -0,0 +1,87 @@
+package scan
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"mynewmangaui/internal/media"
+)
+
+func TestMislabeledPageMime(t *testing.T) {
+	// writeFixture writes a folder chapter and an archive chapter, each
+	// holding a PNG named as a JPEG next to a correctly named one.
+	writeFixture := func(t *testing.T, root string) {
+		t.Helper()
+		chapter := writeChapter(t, root, "Alpha", "Chapter 1", 1)
+		writePNG(t, filepath.Join(chapter, "b.jpg"), 8, 12)
+		png, err := os.ReadFile(filepath.Join(chapter, "a.png"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		writeCBZ(t, filepath.Join(root, "Alpha", "Chapter 2.cbz"), map[string]string{
+			"01.png": "",
+			"02.jpg": string(png),
+		})
+	}
+	mimes := func(t *testing.T, s *Service) map[string]string {
+		t.Helper()
+		rows, err := s.db.Query(`SELECT path, mime FROM page`)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer rows.Close()
+		got := map[string]string{}
+		for rows.Next() {
+			var path, mime string
+			if err := rows.Scan(&path, &mime); err != nil {
+				t.Fatal(err)
+			}
+			ref, err := media.ParseRef(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			name := ref.EntryPath
+			if name == "" {
+				name = ref.Path
+			}
+			got[filepath.Base(name)] = mime
+		}
+		if err := rows.Err(); err != nil {
+			t.Fatal(err)
+		}
+		return got
+	}
+
+	tests := []struct {
+		name  string
+		sniff bool
+		want  map[string]string
+	}{
+		{
+			name:  "sniffed",
+			sniff: true,
+			want:  map[string]string{"a.png": "image/png", "b.jpg": "image/png", "01.png": "image/png", "02.jpg": "image/png"},
+		},
+		{
+			name: "by extension",
+			want: map[string]string{"a.png": "image/png", "b.jpg": "image/jpeg", "01.png": "image/png", "02.jpg": "image/jpeg"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, root := newTestService(t, Options{SniffMime: tt.sniff})
+			writeFixture(t, root)
+			mustScan(t, s)
+			got := mimes(t, s)
+			if len(got) != len(tt.want) {
+				t.Fatalf("page mimes = %v, want %v", got, tt.want)
+			}
+			for name, want := range tt.want {
+				if got[name] != want {
+					t.Errorf("%s mime = %q, want %q", name, got[name], want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,47 @@
+package scan
+
+import (
+	"context"
+	"time"
+)
+
+// periodicScanPoll is how often RunPeriodicScans checks whether a scan is
+// due, and so how soon a changed interval takes effect.
+const periodicScanPoll = time.Minute
+
+// RunPeriodicScans queues a library scan every interval until ctx is done.
+// interval is read again at every check, so it can change at runtime; zero
+// turns periodic scans off, and the first scan after turning them on comes
+// a full interval later. Queued scans wait while scanning is paused.
+func (s *Service) RunPeriodicScans(ctx context.Context, interval func(context.Context) time.Duration) {
+	last := s.now()
+	ticker := time.NewTicker(periodicScanPoll)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		every := interval(ctx)
+		now := s.now()
+		if every <= 0 {
+			last = now
+			continue
+		}
+		if now.Sub(last) < every {
+			continue
+		}
+		last = now
+		queued, _, err := s.QueueScan()
+		if s.logger == nil {
+			continue
+		}
+		if err != nil {
+			s.logger.Warn("failed to queue periodic scan", "error", err)
+			continue
+		}
+		s.logger.Info("periodic library scan queued", "job_id", queued.RunID, "interval", every.String())
+	}
+}
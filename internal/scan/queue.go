@@ -0,0 +1,155 @@
+package scan
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// defaultMaxQueuedScans is how many scans may wait in the queue when
+// Options.MaxQueuedScans is not set.
+const defaultMaxQueuedScans = 32
+
+// queueRetryInterval is how often the queue worker checks whether a scan
+// started outside the queue, or a RunExclusive task, has finished.
+const queueRetryInterval = 250 * time.Millisecond
+
+// Scopes of queued scans.
+const (
+	QueueScopeLibrary   = "library"
+	QueueScopeBookshelf = "bookshelf"
+	QueueScopeManga     = "manga"
+)
+
+// ErrQueueFull is returned when a scan is requested while the queue already
+// holds Options.MaxQueuedScans scans.
+var ErrQueueFull = errors.New("scan queue is full")
+
+// QueuedScan is a scan waiting for the queue worker. Target is the bookshelf
+// root path or manga id, empty for library scans.
+type QueuedScan struct {
+	RunID    string `json:"jobId"`
+	Scope    string `json:"scope"`
+	Target   string `json:"target,omitempty"`
+	QueuedAt string `json:"queuedAt"`
+}
+
+// QueueScan queues a library scan. A library scan already waiting absorbs
+// the request, in which case that scan is returned with coalesced set.
+func (s *Service) QueueScan() (QueuedScan, bool, error) {
+	return s.enqueue(QueueScopeLibrary, "")
+}
+
+// QueueRoot queues a scan of the configured bookshelf given by name or root
+// path, coalescing with a scan of the same bookshelf already waiting.
+func (s *Service) QueueRoot(root string) (QueuedScan, bool, error) {
+	shelf, ok := s.FindRoot(root)
+	if !ok {
+		return QueuedScan{}, false, errors.New("bookshelf root is not configured")
+	}
+	return s.enqueue(QueueScopeBookshelf, shelf.Path)
+}
+
+// QueueManga queues a rescan of one manga, coalescing with a rescan of the
+// same manga already waiting.
+func (s *Service) QueueManga(mangaID string) (QueuedScan, bool, error) {
+	return s.enqueue(QueueScopeManga, mangaID)
+}
+
+func (s *Service) enqueue(scope string, target string) (QueuedScan, bool, error) {
+	s.statusMu.Lock()
+	defer s.statusMu.Unlock()
+	if s.stopping.Load() {
+		return QueuedScan{}, false, ErrStopped
+	}
+	for _, queued := range s.queue {
+		if queued.Scope == scope && queued.Target == target {
+			return queued, true, nil
+		}
+	}
+	if len(s.queue) >= s.maxQueuedScans() {
+		return QueuedScan{}, false, ErrQueueFull
+	}
+
+	queued := QueuedScan{
+		RunID:    NewRunID(),
+		Scope:    scope,
+		Target:   target,
+		QueuedAt: s.now().UTC().Format(time.RFC3339),
+	}
+	s.queue = append(s.queue, queued)
+	if !s.queueWorker {
+		s.queueWorker = true
+		go s.runQueue()
+	}
+	return queued, false, nil
+}
+
+func (s *Service) maxQueuedScans() int {
+	if s.options.MaxQueuedScans > 0 {
+		return s.options.MaxQueuedScans
+	}
+	return defaultMaxQueuedScans
+}
+
+// runQueue runs queued scans one at a time until the queue is empty. A scan
+// that cannot start because another one is running, or scanning was paused,
+// goes back to the front and is retried once it can.
+func (s *Service) runQueue() {
+	for {
+		queued, ok := s.nextQueuedScan()
+		if !ok {
+			return
+		}
+		err := s.runQueuedScan(queued)
+		if (errors.Is(err, ErrScanRunning) || errors.Is(err, ErrPaused)) && !s.stopping.Load() {
+			s.statusMu.Lock()
+			s.queue = append([]QueuedScan{queued}, s.queue...)
+			s.statusMu.Unlock()
+			time.Sleep(queueRetryInterval)
+			continue
+		}
+		if err != nil && s.logger != nil {
+			s.logger.Warn("queued scan failed", "job_id", queued.RunID, "scope", queued.Scope, "target", queued.Target, "error", err)
+		}
+	}
+}
+
+// nextQueuedScan pops the oldest queued scan once no scan is running and
+// scanning is not paused. It clears the queue and stops the worker when the
+// service is stopping.
+func (s *Service) nextQueuedScan() (QueuedScan, bool) {
+	for {
+		s.statusMu.Lock()
+		if s.stopping.Load() {
+			s.queue = nil
+		}
+		if len(s.queue) == 0 {
+			s.queueWorker = false
+			s.statusMu.Unlock()
+			return QueuedScan{}, false
+		}
+		if !s.status.Running && !s.exclusive && !s.paused.Load() {
+			queued := s.queue[0]
+			s.queue = s.queue[1:]
+			s.statusMu.Unlock()
+			return queued, true
+		}
+		s.statusMu.Unlock()
+		time.Sleep(queueRetryInterval)
+	}
+}
+
+func (s *Service) runQueuedScan(queued QueuedScan) error {
+	ctx := WithRunID(context.Background(), queued.RunID)
+	var err error
+	switch queued.Scope {
+	case QueueScopeLibrary:
+		_, err = s.Scan(ctx)
+	case QueueScopeBookshelf:
+		_, err = s.ScanRoot(ctx, queued.Target)
+	case QueueScopeManga:
+		_, err = s.ScanManga(ctx, queued.Target)
+	}
+	return err
+}
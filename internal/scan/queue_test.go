@@ -0,0 +1,142 @@
+package scan
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+// holdScans keeps scans from starting, as a running scan would, until the
+// returned function is called.
+func holdScans(t *testing.T, s *Service) func() {
+	t.Helper()
+	held := make(chan struct{})
+	release := make(chan struct{})
+	done := make(chan error, 1)
+	go func() {
+		done <- s.RunExclusive(func() error {
+			close(held)
+			<-release
+			return nil
+		})
+	}()
+	select {
+	case <-held:
+	case err := <-done:
+		t.Fatalf("hold scans: %v", err)
+	}
+	var released bool
+	unhold := func() {
+		if released {
+			return
+		}
+		released = true
+		close(release)
+		if err := <-done; err != nil {
+			t.Fatalf("hold scans: %v", err)
+		}
+	}
+	t.Cleanup(unhold)
+	return unhold
+}
+
+// waitForQueue waits until the queue worker has run every queued scan.
+func waitForQueue(t *testing.T, s *Service) {
+	t.Helper()
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		status := s.Status()
+		if status.QueueDepth == 0 && !status.Running {
+			s.statusMu.Lock()
+			idle := !s.queueWorker
+			s.statusMu.Unlock()
+			if idle {
+				return
+			}
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("queue did not drain: %+v", status.Queue)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestOverlappingScansCoalesce(t *testing.T) {
+	s, root := newTestService(t, Options{MaxQueuedScans: 3})
+	writeChapter(t, root, "Alpha", "Chapter 1", 1)
+	mustScan(t, s)
+	var mangaID string
+	if err := s.db.QueryRow(`SELECT id FROM manga`).Scan(&mangaID); err != nil {
+		t.Fatal(err)
+	}
+
+	release := holdScans(t, s)
+	if _, err := s.Scan(t.Context()); !errors.Is(err, ErrScanRunning) {
+		t.Fatalf("direct scan while held: %v, want ErrScanRunning", err)
+	}
+
+	library, coalesced, err := s.QueueScan()
+	if err != nil || coalesced {
+		t.Fatalf("first library scan: coalesced %v, %v", coalesced, err)
+	}
+	for range 3 {
+		again, coalesced, err := s.QueueScan()
+		if err != nil || !coalesced || again.RunID != library.RunID {
+			t.Fatalf("repeated library scan = %+v, coalesced %v, %v; want it absorbed by %s", again, coalesced, err, library.RunID)
+		}
+	}
+	manga, coalesced, err := s.QueueManga(mangaID)
+	if err != nil || coalesced || manga.Scope != QueueScopeManga {
+		t.Fatalf("manga rescan = %+v, coalesced %v, %v", manga, coalesced, err)
+	}
+	if again, coalesced, err := s.QueueManga(mangaID); err != nil || !coalesced || again.RunID != manga.RunID {
+		t.Fatalf("repeated manga rescan = %+v, coalesced %v, %v", again, coalesced, err)
+	}
+	if _, _, err := s.QueueRoot("main"); err != nil {
+		t.Fatalf("bookshelf scan: %v", err)
+	}
+	if _, _, err := s.QueueRoot("elsewhere"); err == nil {
+		t.Fatal("queued a scan of an unknown bookshelf")
+	}
+	if _, _, err := s.QueueManga("other"); !errors.Is(err, ErrQueueFull) {
+		t.Fatalf("scan past the queue cap: %v, want ErrQueueFull", err)
+	}
+
+	status := s.Status()
+	if status.QueueDepth != 3 || len(status.Queue) != 3 {
+		t.Fatalf("queue depth = %d with %d queued, want 3", status.QueueDepth, len(status.Queue))
+	}
+	scopes := []string{status.Queue[0].Scope, status.Queue[1].Scope, status.Queue[2].Scope}
+	if scopes[0] != QueueScopeLibrary || scopes[1] != QueueScopeManga || scopes[2] != QueueScopeBookshelf {
+		t.Fatalf("queued scopes = %v, want library, manga, bookshelf in request order", scopes)
+	}
+
+	// Nothing runs while the hold lasts, across a few worker retries.
+	writeChapter(t, root, "Beta", "Chapter 1", 1)
+	time.Sleep(2 * queueRetryInterval)
+	if status := s.Status(); status.QueueDepth != 3 || status.Running {
+		t.Fatalf("queue depth while held = %d, running %v; want 3 waiting", status.QueueDepth, status.Running)
+	}
+	if got := countRows(t, s.db, `SELECT COUNT(*) FROM manga`); got != 1 {
+		t.Fatalf("manga while held = %d, want 1", got)
+	}
+
+	// The queued scans run once the hold is released.
+	release()
+	waitForQueue(t, s)
+	if got := countRows(t, s.db, `SELECT COUNT(*) FROM manga`); got != 2 {
+		t.Fatalf("manga after queued scans = %d, want 2", got)
+	}
+	for _, queued := range []QueuedScan{library, manga} {
+		if run, ok := s.RunLog(queued.RunID); !ok || run.Running || run.Error != "" {
+			t.Fatalf("run log of queued %s scan = %+v, %v; want a finished run", queued.Scope, run, ok)
+		}
+	}
+
+	// With the queue drained, a new request starts a new job.
+	next, coalesced, err := s.QueueScan()
+	if err != nil || coalesced || next.RunID == library.RunID {
+		t.Fatalf("scan after drain = %+v, coalesced %v, %v; want a new job", next, coalesced, err)
+	}
+	waitForQueue(t, s)
+}
@@ -0,0 +1,134 @@
+package scan
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// maxRunLogs is how many scan runs keep their log in memory; older runs
+// are evicted first.
+const maxRunLogs = 8
+
+// maxRunLogEntries bounds the log of a single run. Later entries are only
+// counted, so a scan of a huge library cannot grow the log without limit.
+const maxRunLogEntries = 5000
+
+// Run log actions recorded per manga.
+const (
+	RunActionAdded   = "added"
+	RunActionUpdated = "updated"
+	RunActionRemoved = "removed"
+	RunActionSkipped = "skipped"
+)
+
+// RunLog is the record of one scan run: what happened to each manga it
+// visited, in order.
+type RunLog struct {
+	ID         string        `json:"id"`
+	Scope      string        `json:"scope"`
+	StartedAt  string        `json:"startedAt"`
+	FinishedAt string        `json:"finishedAt,omitempty"`
+	Running    bool          `json:"running"`
+	Error      string        `json:"error,omitempty"`
+	Entries    []RunLogEntry `json:"entries"`
+	// Dropped counts entries left out once the log reached its limit.
+	Dropped int `json:"dropped"`
+}
+
+type RunLogEntry struct {
+	Time     string `json:"time"`
+	Action   string `json:"action"`
+	MangaID  string `json:"mangaId"`
+	Path     string `json:"path,omitempty"`
+	Title    string `json:"title,omitempty"`
+	Chapters int    `json:"chapters"`
+	Pages    int    `json:"pages"`
+	Detail   string `json:"detail,omitempty"`
+}
+
+type runIDKey struct{}
+
+// NewRunID returns an id for a scan run that is about to be started.
+func NewRunID() string {
+	return uuid.NewString()
+}
+
+// WithRunID makes the scan started with ctx record its log under id, so a
+// caller can hand the id out before the scan begins in the background.
+func WithRunID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, runIDKey{}, id)
+}
+
+func runIDFromContext(ctx context.Context) string {
+	if id, ok := ctx.Value(runIDKey{}).(string); ok && id != "" {
+		return id
+	}
+	return NewRunID()
+}
+
+// RunLog returns a copy of the log of a recent scan run.
+func (s *Service) RunLog(id string) (RunLog, bool) {
+	s.statusMu.Lock()
+	defer s.statusMu.Unlock()
+	for _, run := range s.runs {
+		if run.ID == id {
+			copied := *run
+			copied.Entries = append([]RunLogEntry(nil), run.Entries...)
+			return copied, true
+		}
+	}
+	return RunLog{}, false
+}
+
+// startRunLog opens the log of a new run, evicting the oldest run beyond
+// maxRunLogs. Callers hold statusMu.
+func (s *Service) startRunLog(id string, scope string, startedAt string) {
+	s.runs = append(s.runs, &RunLog{
+		ID:        id,
+		Scope:     scope,
+		StartedAt: startedAt,
+		Running:   true,
+		Entries:   []RunLogEntry{},
+	})
+	if len(s.runs) > maxRunLogs {
+		s.runs = append([]*RunLog(nil), s.runs[len(s.runs)-maxRunLogs:]...)
+	}
+}
+
+// finishRunLog closes the log of the current run. Callers hold statusMu.
+func (s *Service) finishRunLog(finishedAt string, err error) {
+	run := s.currentRun()
+	if run == nil {
+		return
+	}
+	run.Running = false
+	run.FinishedAt = finishedAt
+	if err != nil {
+		run.Error = err.Error()
+	}
+}
+
+// currentRun returns the log of the running scan. Callers hold statusMu.
+func (s *Service) currentRun() *RunLog {
+	if len(s.runs) == 0 || !s.runs[len(s.runs)-1].Running {
+		return nil
+	}
+	return s.runs[len(s.runs)-1]
+}
+
+func (s *Service) logRun(entry RunLogEntry) {
+	s.statusMu.Lock()
+	defer s.statusMu.Unlock()
+	run := s.currentRun()
+	if run == nil {
+		return
+	}
+	if len(run.Entries) >= maxRunLogEntries {
+		run.Dropped++
+		return
+	}
+	entry.Time = s.now().UTC().Format(time.RFC3339)
+	run.Entries = append(run.Entries, entry)
+}
@@ -0,0 +1,38 @@
+package scan
+
+import (
+	"context"
+	"testing"
+)
+
+func TestRunLogsEvictOldestRuns(t *testing.T) {
+	s, root := newTestService(t, Options{})
+	writeChapter(t, root, "Alpha", "Chapter 1", 1)
+
+	ids := make([]string, maxRunLogs+2)
+	for i := range ids {
+		ids[i] = NewRunID()
+		if _, err := s.Scan(WithRunID(context.Background(), ids[i])); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for i, id := range ids {
+		run, ok := s.RunLog(id)
+		if evicted := i < len(ids)-maxRunLogs; evicted {
+			if ok {
+				t.Errorf("run %d of %d kept, want it evicted", i+1, len(ids))
+			}
+			continue
+		}
+		if !ok || run.ID != id || run.Running || run.FinishedAt == "" {
+			t.Errorf("run %d of %d = %+v, %v; want its finished log", i+1, len(ids), run, ok)
+		}
+	}
+	if run, _ := s.RunLog(ids[len(ids)-1]); len(run.Entries) != 1 {
+		t.Errorf("latest run entries = %+v, want one for Alpha", run.Entries)
+	}
+	if _, ok := s.RunLog("unknown"); ok {
+		t.Error("found a log for a run that never happened")
+	}
+}
@@ -0,0 +1,77 @@
+package scan
+
+import "strings"
+
+// Title sources pick where manga titles come from. TitleFromFolder uses the
+// folder name, through the bookshelf title pattern if any. TitleFromComicInfo
+// uses the Series most chapters' ComicInfo.xml agree on whenever there is
+// one. TitlePreferComicInfo uses it only when every chapter that names a
+// series names the same one, and keeps the folder title otherwise.
+const (
+	TitleFromFolder      = "folder"
+	TitleFromComicInfo   = "comicinfo"
+	TitlePreferComicInfo = "prefer-comicinfo"
+)
+
+// comicInfoSeries returns the ComicInfo series title for a manga under the
+// configured title source, or "" when the folder title stands.
+// Each chapter directory or archive gets one vote; the manga directory's own
+// ComicInfo.xml, or the one in a single-archive manga, is only consulted
+// when no chapter has one. Ties go to the series named by the earliest
+// chapter.
+func (s *Service) comicInfoSeries(record mangaRecord) string {
+	source := s.options.TitleSource
+	if source != TitleFromComicInfo && source != TitlePreferComicInfo {
+		return ""
+	}
+
+	counts := make(map[string]int)
+	order := make([]string, 0, 1)
+	vote := func(dir string) {
+		info, found, err := readComicInfo(dir)
+		if err != nil || !found {
+			return
+		}
+		series := cleanDisplayTitle(info.Series)
+		if series == "" {
+			return
+		}
+		// Taggers disagree on case, so votes are counted case-insensitively
+		// under the spelling seen first.
+		for _, seen := range order {
+			if strings.EqualFold(seen, series) {
+				counts[seen]++
+				return
+			}
+		}
+		order = append(order, series)
+		counts[series] = 1
+	}
+	for _, chapter := range record.Chapters {
+		if chapter.Path != record.Path {
+			vote(chapter.Path)
+		}
+	}
+	if len(order) == 0 {
+		vote(record.Path)
+	}
+	if len(order) == 0 {
+		return ""
+	}
+
+	if len(order) > 1 {
+		if s.logger != nil {
+			s.logger.Debug("chapters disagree on comicinfo series", "path", record.Path, "series", order)
+		}
+		if source == TitlePreferComicInfo {
+			return ""
+		}
+	}
+	best := order[0]
+	for _, series := range order[1:] {
+		if counts[series] > counts[best] {
+			best = series
+		}
+	}
+	return best
+}
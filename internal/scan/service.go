@@ -6,18 +6,21 @@ import (
 	"database/sql"
 	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"io/fs"
 	"log/slog"
-	"os"
 	"path/filepath"
 	"regexp"
 	"sort"
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
+	"mynewmangaui/internal/clock"
 	"mynewmangaui/internal/media"
 )
 
@@ -25,20 +28,91 @@ type Service struct {
 	db          *sql.DB
 	logger      *slog.Logger
 	bookshelves []Bookshelf
-	scanMu      sync.Mutex
-	statusMu    sync.Mutex
-	status      Status
-}
+	// titlePatterns holds the compiled title patterns by bookshelf id.
+	titlePatterns map[string]*regexp.Regexp
+	// pageFilter holds the compiled ExcludePagePatterns.
+	pageFilter PageFilter
+	options    Options
+	scanMu     sync.Mutex
+	statusMu   sync.Mutex
+	status     Status
+	version    atomic.Uint64
+	clock      clock.Clock
+	// stopping asks running scans to stop after the manga in progress.
+	stopping atomic.Bool
+	// paused keeps scans from starting, and stops running ones like
+	// stopping, until it is cleared again.
+	paused atomic.Bool
+	// runs holds the logs of recent scans, oldest first; guarded by
+	// statusMu.
+	runs []*RunLog
+	// exclusive keeps scans from starting while RunExclusive runs; guarded
+	// by statusMu.
+	exclusive bool
+	// queue holds the scans waiting for the queue worker, oldest first, and
+	// queueWorker is set while the worker runs; both guarded by statusMu.
+	queue       []QueuedScan
+	queueWorker bool
+	// checksumWake asks RunChecksumBackfill for another pass.
+	checksumWake chan struct{}
+}
+
+// ErrStopped is returned by a scan that stopped early because Stop was
+// called. Manga committed before that are kept, and a library scan can be
+// picked up again with Resume.
+var ErrStopped = errors.New("scan stopped")
+
+// ErrScanRunning is returned when a scan cannot start because another one,
+// or a RunExclusive task, is in progress, and by RunExclusive while a scan
+// is running.
+var ErrScanRunning = errors.New("scan already running")
+
+// ErrPaused is returned by scans requested, or stopped early, while
+// scanning is paused with SetPaused.
+var ErrPaused = errors.New("scanning is paused")
+
+// errNotAnImage marks a file with an image extension whose content is not
+// an image; it is left out of its chapter.
+var errNotAnImage = errors.New("not an image")
 
 type Summary struct {
-	BookshelfCount int `json:"bookshelfCount"`
-	MangaCount     int `json:"mangaCount"`
-	ChapterCount   int `json:"chapterCount"`
-	PageCount      int `json:"pageCount"`
+	BookshelfCount int          `json:"bookshelfCount"`
+	MangaCount     int          `json:"mangaCount"`
+	ChapterCount   int          `json:"chapterCount"`
+	PageCount      int          `json:"pageCount"`
+	Timings        StageTimings `json:"timings"`
+}
+
+// StageTimings is the cumulative time a scan spent per stage: walking
+// directories and archive listings, decoding image headers, and writing to
+// the database.
+type StageTimings struct {
+	Walk     time.Duration
+	Decode   time.Duration
+	Database time.Duration
+}
+
+func (t StageTimings) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		WalkMs     int64 `json:"walkMs"`
+		DecodeMs   int64 `json:"decodeMs"`
+		DatabaseMs int64 `json:"databaseMs"`
+	}{
+		WalkMs:     t.Walk.Milliseconds(),
+		DecodeMs:   t.Decode.Milliseconds(),
+		DatabaseMs: t.Database.Milliseconds(),
+	})
+}
+
+func (t *StageTimings) add(other StageTimings) {
+	t.Walk += other.Walk
+	t.Decode += other.Decode
+	t.Database += other.Database
 }
 
 type Status struct {
 	Running              bool    `json:"running"`
+	RunID                string  `json:"jobId,omitempty"`
 	Scope                string  `json:"scope"`
 	CurrentBookshelf     string  `json:"currentBookshelf,omitempty"`
 	CompletedBookshelves int     `json:"completedBookshelves"`
@@ -48,19 +122,110 @@ type Status struct {
 	LastSuccessAt        string  `json:"lastSuccessAt,omitempty"`
 	LastError            string  `json:"lastError,omitempty"`
 	LastSummary          Summary `json:"lastSummary"`
-}
+	// Queue lists the scans waiting to run after the current one.
+	Queue      []QueuedScan `json:"queue"`
+	QueueDepth int          `json:"queueDepth"`
+	// Paused is set while scanning is paused with SetPaused.
+	Paused bool `json:"paused"`
+}
+
+// Options tunes how the scanner inspects files on disk.
+type Options struct {
+	// SniffMime verifies each page's content type from its leading bytes
+	// instead of trusting the file extension alone.
+	SniffMime bool
+	// MaxChapterNumber is the largest chapter number accepted from a title;
+	// larger matches (dates, IDs) are treated as bogus. Zero uses the default.
+	MaxChapterNumber float64
+	// EnablePDF indexes .pdf files inside manga directories as chapters.
+	EnablePDF bool
+	// IndexCoverOnly keeps manga folders that only hold a cover image, so
+	// upcoming series show up with no chapters yet.
+	IndexCoverOnly bool
+	// IncompleteChapterWindow flags chapters modified more recently than
+	// this as possibly incomplete. Zero disables the check.
+	IncompleteChapterWindow time.Duration
+	// DeferIncompleteChapters leaves possibly incomplete chapters out of the
+	// index until a later scan finds them unchanged for the whole window.
+	DeferIncompleteChapters bool
+	// PreserveFilenameNumbers takes page indexes from the number in each
+	// page's file name, so a missing file leaves a gap, instead of numbering
+	// pages 0..N-1 in sorted order.
+	PreserveFilenameNumbers bool
+	// ArchiveRoots accepts a bookshelf root that is itself an archive and
+	// indexes it as the shelf's only manga.
+	ArchiveRoots bool
+	// TitleSource is TitleFromFolder, TitleFromComicInfo or
+	// TitlePreferComicInfo; empty means TitleFromFolder.
+	TitleSource string
+	// MaxQueuedScans caps how many scans may wait in the queue. Zero uses
+	// the default.
+	MaxQueuedScans int
+	// SkipHiddenFiles ignores files and folders whose names start with a
+	// dot, and macOS __MACOSX folders, on disk and inside archives.
+	SkipHiddenFiles bool
+	// CaseInsensitivePaths derives manga and chapter ids from case-folded
+	// paths and indexes only one of several paths differing only in case.
+	CaseInsensitivePaths bool
+	// ChecksumMode is ChecksumInline, ChecksumLazy or ChecksumOff; empty
+	// means ChecksumOff.
+	ChecksumMode string
+	// ExcludePagePatterns leave out of chapters the pages whose file names
+	// match, see CompilePageFilter. A manga folder's metadata.json may
+	// replace them with its own excludePages.
+	ExcludePagePatterns []string
+	// Clock supplies the current time for scan bookkeeping and mtime
+	// windows; nil uses the system clock.
+	Clock clock.Clock
+}
+
+const defaultMaxChapterNumber = 10000
+
+// ChapterTombstoneRetentionDays is how long removed chapter ids are kept for
+// delta sync; clients polling less often must reload the full chapter list.
+const ChapterTombstoneRetentionDays = 30
+
+// verticalStripRatio is the height to width ratio from which a page counts
+// as a vertical strip; manga made mostly of such pages read top to bottom.
+const verticalStripRatio = 2.5
+
+// looseImagesChapterTitle names the implicit chapter made from images lying
+// directly in a manga folder without chapter subfolders, as one-shots do.
+const looseImagesChapterTitle = "Chapter 1"
+
+// rootReadBatchSize is how many bookshelf root entries are listed at a time.
+const rootReadBatchSize = 1024
+
+// maxPageNumberGap is the largest gap a file name number may open in
+// PreserveFilenameNumbers mode. Larger numbers, such as dates or IDs in file
+// names, are ignored and the page takes the next free index instead.
+const maxPageNumberGap = 100
+
+const (
+	pageInsertColumns   = 11
+	pageInsertBatchSize = 999 / pageInsertColumns
+)
 
 type Bookshelf struct {
 	Name string
 	Path string
+	// SkipStartupScan leaves the bookshelf out of the scan run at startup;
+	// it is still scanned by manual library and root scans.
+	SkipStartupScan bool
+	// TitlePattern derives manga titles from folder names on this shelf,
+	// see CompileTitlePattern.
+	TitlePattern string
 }
 
 type bookshelfRecord struct {
-	ID        string
-	Name      string
-	RootPath  string
-	SortOrder int
-	UpdatedAt time.Time
+	ID              string
+	Name            string
+	RootPath        string
+	SortOrder       int
+	UpdatedAt       time.Time
+	SkipStartupScan bool
+	// ArchiveRoot marks a root that is an archive holding a single manga.
+	ArchiveRoot bool
 }
 
 type mangaRecord struct {
@@ -68,22 +233,57 @@ type mangaRecord struct {
 	ID          string
 	Title       string
 	TitleSort   string
-	Path        string
-	CoverPath   string
-	UpdatedAt   time.Time
-	PageCount   int
-	Chapters    []chapterRecord
+	// FolderName is the manga's folder or archive name as found on disk,
+	// before any title pattern or metadata renames it.
+	FolderName string
+	Path       string
+	CoverPath  string
+	// CoverBlurhash is the BlurHash placeholder of the cover.
+	CoverBlurhash string
+	// coverDecodeTime is how long computing CoverBlurhash took.
+	coverDecodeTime time.Duration
+	UpdatedAt       time.Time
+	// ContentUpdatedAt orders the manga by recent activity. Rescans keep
+	// it unless chapters were added or removed, so touching files without
+	// changing the chapter set does not bring a manga back to the top.
+	ContentUpdatedAt time.Time
+	PageCount        int
+	Favorite         bool
+	// SortName orders the manga in the library. It follows the title
+	// unless SortNameLocked says the user set it.
+	SortName       string
+	SortNameLocked bool
+	Collection     string
+	// ReadingDirection is one of the media.Reading constants.
+	ReadingDirection string
+	// ReadingMode is one of the media.ReadingMode constants, inferred from
+	// the reading direction unless ReadingModeLocked says the user set it.
+	ReadingMode       string
+	ReadingModeLocked bool
+	// SourceFingerprint is the mangaFingerprint taken before the manga was
+	// read, so changes made while it was being read show up next scan.
+	SourceFingerprint string
+	Chapters          []chapterRecord
 }
 
 type chapterRecord struct {
-	ID        string
-	MangaID   string
-	Title     string
-	Number    *float64
-	Path      string
-	UpdatedAt time.Time
-	PageCount int
-	Pages     []pageRecord
+	ID              string
+	MangaID         string
+	Title           string
+	Number          *float64
+	Volume          *int
+	VolumeLocked    bool
+	PageOrderLocked bool
+	Path            string
+	UpdatedAt       time.Time
+	FirstSeenAt     time.Time
+	PageCount       int
+	// ExcludedPages counts the pages left out by the page filter.
+	ExcludedPages int
+	Pages         []pageRecord
+	// PossiblyIncomplete is set when the chapter changed within the
+	// configured incomplete window, e.g. while a download is still running.
+	PossiblyIncomplete bool
 }
 
 type pageRecord struct {
@@ -95,6 +295,16 @@ type pageRecord struct {
 	Width     int
 	Height    int
 	SizeBytes int64
+	// Orientation is the page's EXIF orientation; Width and Height are
+	// those of the page displayed upright.
+	Orientation int
+	// Animated marks animated WebP, GIF and APNG pages, whose Width and
+	// Height are the canvas size.
+	Animated bool
+	// Checksum is empty until the page is hashed; see the Checksum modes.
+	Checksum string
+
+	decodeTime time.Duration
 }
 
 type archiveChapter struct {
@@ -106,12 +316,18 @@ type chapterSource struct {
 	Path      string
 	SortName  string
 	IsArchive bool
+	IsPDF     bool
 }
 
 type directoryMetadata struct {
 	Title    string                     `json:"title"`
 	Cover    string                     `json:"cover"`
 	Chapters []directoryMetadataChapter `json:"chapters"`
+	// ReadingDirection overrides the direction detected for the manga.
+	ReadingDirection string `json:"readingDirection"`
+	// ExcludePages replaces the configured page exclusion patterns for the
+	// manga; an empty list keeps every page.
+	ExcludePages *[]string `json:"excludePages"`
 }
 
 type directoryMetadataChapter struct {
@@ -119,16 +335,123 @@ type directoryMetadataChapter struct {
 	Directory string `json:"directory"`
 }
 
+var volumePattern = regexp.MustCompile("(?i)(?:\\bvol(?:ume)?\\.?\\s*|\\bv|\u7b2c\\s*)0*(\\d+)(?:\\s*\u5377)?")
 var chapterNumberPattern = regexp.MustCompile("(?i)(?:\u7b2c\\s*)?0*(\\d+(?:\\.\\d+)?)\\s*(?:\u8bdd|\u8a71|\u7ae0|\u5377|ch|chapter)?")
+var pageNumberPattern = regexp.MustCompile(`(\d+)\D*$`)
 var duplicateChapterPattern = regexp.MustCompile("^((?:\u7b2c\\s*)?0*(\\d+(?:\\.\\d+)?)\\s*(?:\u8bdd|\u8a71|\u7ae0|\u5377))(?:\\s+(?:\u7b2c\\s*)?0*(\\d+(?:\\.\\d+)?)\\s*(?:\u8bdd|\u8a71|\u7ae0|\u5377))+$")
 
-func NewService(db *sql.DB, bookshelves []Bookshelf, logger *slog.Logger) *Service {
-	return &Service{db: db, bookshelves: bookshelves, logger: logger}
+func NewService(db *sql.DB, bookshelves []Bookshelf, options Options, logger *slog.Logger) *Service {
+	// Invalid patterns were already reported when the configuration was
+	// loaded.
+	pageFilter, _ := CompilePageFilter(options.ExcludePagePatterns)
+	return &Service{
+		db:            db,
+		bookshelves:   bookshelves,
+		titlePatterns: compileTitlePatterns(bookshelves),
+		pageFilter:    pageFilter,
+		options:       options,
+		logger:        logger,
+		clock:         clock.OrSystem(options.Clock),
+		checksumWake:  make(chan struct{}, 1),
+	}
+}
+
+func (s *Service) now() time.Time {
+	return s.clock.Now()
+}
+
+// Version returns a counter that increases every time the scanner commits
+// changes to the library tables, so callers can invalidate derived caches.
+func (s *Service) Version() uint64 {
+	return s.version.Load()
+}
+
+func (s *Service) commit(tx *sql.Tx) error {
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	s.version.Add(1)
+	return nil
 }
 
 func (s *Service) Scan(ctx context.Context) (Summary, error) {
-	if !s.beginScan("library") {
-		return Summary{}, fmt.Errorf("scan already running")
+	return s.scanLibrary(ctx, false, false)
+}
+
+// Resume continues the most recent unfinished library scan, skipping manga
+// that were already committed during that scan. It starts a fresh scan when
+// no unfinished scan is recorded.
+func (s *Service) Resume(ctx context.Context) (Summary, error) {
+	return s.scanLibrary(ctx, true, false)
+}
+
+// ScanStartup runs the library scan done when the server boots, which skips
+// bookshelves marked SkipStartupScan and keeps their indexed manga as is.
+func (s *Service) ScanStartup(ctx context.Context, resume bool) (Summary, error) {
+	return s.scanLibrary(ctx, resume, true)
+}
+
+// FindRoot looks up a configured bookshelf by name or root path.
+func (s *Service) FindRoot(root string) (Bookshelf, bool) {
+	root = strings.TrimSpace(root)
+	if root == "" {
+		return Bookshelf{}, false
+	}
+	target := normalizeScanPath(root)
+	for _, shelf := range s.bookshelves {
+		if strings.TrimSpace(shelf.Name) == root || normalizeScanPath(shelf.Path) == target {
+			return shelf, true
+		}
+	}
+	return Bookshelf{}, false
+}
+
+// ScanRoot scans a single configured bookshelf given by name or root path,
+// including ones excluded from the startup scan.
+func (s *Service) ScanRoot(ctx context.Context, root string) (Summary, error) {
+	shelf, ok := s.FindRoot(root)
+	if !ok {
+		return Summary{}, fmt.Errorf("bookshelf root %q is not configured", root)
+	}
+	if err := s.beginScan(ctx, "bookshelf"); err != nil {
+		return Summary{}, err
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			s.finishScan(Summary{}, fmt.Errorf("scan panicked"))
+			panic(r)
+		}
+	}()
+	s.scanMu.Lock()
+	defer s.scanMu.Unlock()
+
+	s.setScanBookshelfProgress(shelf.Name, 0, 1, Summary{})
+	summary, err := s.syncBookshelf(ctx, shelf.Path)
+	if err != nil {
+		s.finishScan(Summary{}, err)
+		return Summary{}, err
+	}
+	s.setScanBookshelfProgress(shelf.Name, 1, 1, summary)
+	s.finishScan(summary, nil)
+	return summary, nil
+}
+
+// HasPendingScan reports whether a library scan was interrupted before it
+// finished.
+func (s *Service) HasPendingScan(ctx context.Context) (bool, error) {
+	if s.db == nil {
+		return false, fmt.Errorf("database not initialized")
+	}
+	var count int
+	if err := s.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM scan_cycle WHERE finished_at IS NULL`).Scan(&count); err != nil {
+		return false, fmt.Errorf("load pending scan cycle: %w", err)
+	}
+	return count > 0, nil
+}
+
+func (s *Service) scanLibrary(ctx context.Context, resume bool, startup bool) (Summary, error) {
+	if err := s.beginScan(ctx, "library"); err != nil {
+		return Summary{}, err
 	}
 	defer func() {
 		if r := recover(); r != nil {
@@ -145,7 +468,7 @@ func (s *Service) Scan(ctx context.Context) (Summary, error) {
 		return Summary{}, err
 	}
 
-	bookshelves, err := resolveBookshelves(s.bookshelves)
+	bookshelves, err := s.resolveBookshelves()
 	if err != nil {
 		s.finishScan(Summary{}, err)
 		return Summary{}, err
@@ -164,23 +487,33 @@ func (s *Service) Scan(ctx context.Context) (Summary, error) {
 		return Summary{}, err
 	}
 
+	cycleID, completed, err := s.beginScanCycle(ctx, resume)
+	if err != nil {
+		s.finishScan(Summary{}, err)
+		return Summary{}, err
+	}
+	if len(completed) > 0 && s.logger != nil {
+		s.logger.Info("resuming library scan", "cycle_id", cycleID, "completed_manga", len(completed))
+	}
+
 	for index, shelf := range scanBookshelves {
+		if startup && shelf.SkipStartupScan {
+			if s.logger != nil {
+				s.logger.Info("bookshelf skipped during startup scan", "bookshelf", shelf.Name)
+			}
+			continue
+		}
 		s.setScanBookshelfProgress(shelf.Name, index, len(scanBookshelves), summary)
 
-		manga, err := s.discoverBookshelfManga(shelf)
+		shelfSummary, err := s.scanBookshelfManga(ctx, shelf, cycleID, completed, func(partial Summary) {
+			partial.add(summary)
+			s.setScanSummaryProgress(partial)
+		})
 		if err != nil {
 			s.finishScan(Summary{}, err)
 			return Summary{}, err
 		}
-		if err := s.replaceBookshelfManga(ctx, shelf, manga); err != nil {
-			s.finishScan(Summary{}, err)
-			return Summary{}, err
-		}
-		for _, record := range manga {
-			summary.MangaCount++
-			summary.ChapterCount += len(record.Chapters)
-			summary.PageCount += record.PageCount
-		}
+		summary.add(shelfSummary)
 		s.setScanBookshelfProgress(shelf.Name, index+1, len(scanBookshelves), summary)
 	}
 
@@ -188,6 +521,10 @@ func (s *Service) Scan(ctx context.Context) (Summary, error) {
 		s.finishScan(Summary{}, err)
 		return Summary{}, err
 	}
+	if err := s.finishScanCycle(ctx, cycleID); err != nil {
+		s.finishScan(Summary{}, err)
+		return Summary{}, err
+	}
 
 	if s.logger != nil {
 		s.logger.Info("library scan complete",
@@ -195,6 +532,9 @@ func (s *Service) Scan(ctx context.Context) (Summary, error) {
 			"chapters", summary.ChapterCount,
 			"pages", summary.PageCount,
 			"bookshelves", summary.BookshelfCount,
+			"walk_ms", summary.Timings.Walk.Milliseconds(),
+			"decode_ms", summary.Timings.Decode.Milliseconds(),
+			"db_ms", summary.Timings.Database.Milliseconds(),
 		)
 	}
 
@@ -203,8 +543,8 @@ func (s *Service) Scan(ctx context.Context) (Summary, error) {
 }
 
 func (s *Service) ScanManga(ctx context.Context, mangaID string) (Summary, error) {
-	if !s.beginScan("manga") {
-		return Summary{}, fmt.Errorf("scan already running")
+	if err := s.beginScan(ctx, "manga"); err != nil {
+		return Summary{}, err
 	}
 	defer func() {
 		if r := recover(); r != nil {
@@ -232,8 +572,8 @@ func (s *Service) ScanManga(ctx context.Context, mangaID string) (Summary, error
 }
 
 func (s *Service) ScanTag(ctx context.Context, tagID string) (Summary, error) {
-	if !s.beginScan("tag") {
-		return Summary{}, fmt.Errorf("scan already running")
+	if err := s.beginScan(ctx, "tag"); err != nil {
+		return Summary{}, err
 	}
 	defer func() {
 		if r := recover(); r != nil {
@@ -266,14 +606,16 @@ func (s *Service) ScanTag(ctx context.Context, tagID string) (Summary, error) {
 	summary := Summary{}
 	s.setScanBookshelfProgress("", 0, len(mangaIDs), summary)
 	for index, mangaID := range mangaIDs {
+		if err := s.haltErr(); err != nil {
+			s.finishScan(Summary{}, err)
+			return Summary{}, err
+		}
 		itemSummary, err := s.scanMangaByID(ctx, mangaID)
 		if err != nil {
 			s.finishScan(Summary{}, err)
 			return Summary{}, err
 		}
-		summary.MangaCount += itemSummary.MangaCount
-		summary.ChapterCount += itemSummary.ChapterCount
-		summary.PageCount += itemSummary.PageCount
+		summary.add(itemSummary)
 		s.setScanBookshelfProgress("", index+1, len(mangaIDs), summary)
 	}
 
@@ -295,52 +637,21 @@ func (s *Service) scanMangaByID(ctx context.Context, mangaID string) (Summary, e
 		return Summary{}, fmt.Errorf("load manga path: %w", err)
 	}
 
-	record, found, err := s.discoverMangaByPath(existingBookshelfID, existingPath)
+	fingerprint := s.mangaFingerprint(existingBookshelfID, existingPath)
+	summary, found, err := s.rescanManga(ctx, existingBookshelfID, mangaID, existingPath, "", fingerprint)
 	if err != nil {
 		return Summary{}, err
 	}
 
-	tx, err := s.db.BeginTx(ctx, nil)
-	if err != nil {
-		return Summary{}, fmt.Errorf("begin manga scan transaction: %w", err)
-	}
-
-	tagIDs, err := loadMangaTagIDs(ctx, tx, mangaID)
-	if err != nil {
-		tx.Rollback()
-		return Summary{}, err
-	}
-
-	if _, err := tx.ExecContext(ctx, `DELETE FROM manga WHERE id = ?`, mangaID); err != nil {
-		tx.Rollback()
-		return Summary{}, fmt.Errorf("delete existing manga: %w", err)
-	}
-
-	summary := Summary{}
-	if found && len(record.Chapters) > 0 {
-		if err := insertManga(ctx, tx, record); err != nil {
-			tx.Rollback()
-			return Summary{}, err
-		}
-		if err := restoreMangaTags(ctx, tx, record.ID, tagIDs); err != nil {
-			tx.Rollback()
-			return Summary{}, err
-		}
-		summary.MangaCount = 1
-		summary.ChapterCount = len(record.Chapters)
-		summary.PageCount = record.PageCount
-	}
-
-	if err := tx.Commit(); err != nil {
-		return Summary{}, fmt.Errorf("commit manga scan transaction: %w", err)
-	}
-
 	if s.logger != nil {
 		s.logger.Info("manga scan complete",
 			"manga_id", mangaID,
 			"found", found,
 			"chapters", summary.ChapterCount,
 			"pages", summary.PageCount,
+			"walk_ms", summary.Timings.Walk.Milliseconds(),
+			"decode_ms", summary.Timings.Decode.Milliseconds(),
+			"db_ms", summary.Timings.Database.Milliseconds(),
 		)
 	}
 
@@ -350,16 +661,23 @@ func (s *Service) scanMangaByID(ctx context.Context, mangaID string) (Summary, e
 func (s *Service) Status() Status {
 	s.statusMu.Lock()
 	defer s.statusMu.Unlock()
-	return s.status
+	status := s.status
+	status.Queue = append([]QueuedScan{}, s.queue...)
+	status.QueueDepth = len(s.queue)
+	status.Paused = s.paused.Load()
+	return status
 }
 
 func (s *Service) SyncBookshelf(ctx context.Context, rootPath string) (Summary, error) {
+	if s.paused.Load() {
+		return Summary{}, ErrPaused
+	}
 	return s.syncBookshelf(ctx, rootPath)
 }
 
 func (s *Service) ScanBookshelf(ctx context.Context, bookshelfID string) (Summary, error) {
-	if !s.beginScan("bookshelf") {
-		return Summary{}, fmt.Errorf("scan already running")
+	if err := s.beginScan(ctx, "bookshelf"); err != nil {
+		return Summary{}, err
 	}
 	defer func() {
 		if r := recover(); r != nil {
@@ -409,7 +727,7 @@ func (s *Service) syncBookshelf(ctx context.Context, rootPath string) (Summary,
 		return Summary{}, fmt.Errorf("bookshelf path is required")
 	}
 
-	bookshelves, err := resolveBookshelves(s.bookshelves)
+	bookshelves, err := s.resolveBookshelves()
 	if err != nil {
 		return Summary{}, err
 	}
@@ -444,24 +762,15 @@ func (s *Service) syncBookshelf(ctx context.Context, rootPath string) (Summary,
 		tx.Rollback()
 		return Summary{}, err
 	}
-	if err := tx.Commit(); err != nil {
+	if err := s.commit(tx); err != nil {
 		return Summary{}, fmt.Errorf("commit bookshelf sync bootstrap: %w", err)
 	}
 
-	manga, err := s.discoverBookshelfManga(shelf)
+	summary, err := s.scanBookshelfManga(ctx, shelf, "", nil, s.setScanSummaryProgress)
 	if err != nil {
 		return Summary{}, err
 	}
-	if err := s.replaceBookshelfManga(ctx, shelf, manga); err != nil {
-		return Summary{}, err
-	}
-
-	summary := Summary{BookshelfCount: 1}
-	for _, record := range manga {
-		summary.MangaCount++
-		summary.ChapterCount += len(record.Chapters)
-		summary.PageCount += record.PageCount
-	}
+	summary.BookshelfCount = 1
 
 	if s.logger != nil {
 		s.logger.Info("bookshelf sync complete",
@@ -486,7 +795,7 @@ func (s *Service) bookshelfRootPath(ctx context.Context, bookshelfID string) (st
 		return "", fmt.Errorf("load bookshelf path: %w", err)
 	}
 
-	bookshelves, resolveErr := resolveBookshelves(s.bookshelves)
+	bookshelves, resolveErr := s.resolveBookshelves()
 	if resolveErr != nil {
 		return "", resolveErr
 	}
@@ -514,7 +823,7 @@ func (s *Service) mangaIDsForTag(ctx context.Context, tagID string) ([]string, e
 		FROM manga m
 		JOIN manga_tag mt ON mt.manga_id = m.id
 		WHERE mt.tag_id = ?
-		ORDER BY m.updated_at DESC, m.title_sort ASC, m.id ASC
+		ORDER BY m.content_updated_at DESC, m.title_sort ASC, m.id ASC
 	`, tagID)
 	if err != nil {
 		return nil, fmt.Errorf("load tagged manga: %w", err)
@@ -535,21 +844,95 @@ func (s *Service) mangaIDsForTag(ctx context.Context, tagID string) ([]string, e
 	return ids, nil
 }
 
-func (s *Service) beginScan(scope string) bool {
+// Stop asks the running scan to finish the manga it is on, committing it,
+// and then return ErrStopped, and waits until it has. It returns ctx's
+// error if the scan is still running when ctx is done; the caller is then
+// expected to cancel the scan's context. No new scan starts after Stop and
+// queued scans are dropped.
+func (s *Service) Stop(ctx context.Context) error {
+	s.stopping.Store(true)
+	s.statusMu.Lock()
+	s.queue = nil
+	s.statusMu.Unlock()
+	ticker := time.NewTicker(50 * time.Millisecond)
+	defer ticker.Stop()
+	for s.Status().Running {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+	return nil
+}
+
+// RunExclusive runs fn while no scan is running, for maintenance that would
+// otherwise block on or stall a scan. Scans requested meanwhile are refused
+// as if one were running. It returns ErrScanRunning without calling fn when
+// a scan is in progress.
+func (s *Service) RunExclusive(fn func() error) error {
+	s.statusMu.Lock()
+	if s.status.Running || s.exclusive {
+		s.statusMu.Unlock()
+		return ErrScanRunning
+	}
+	s.exclusive = true
+	s.statusMu.Unlock()
+
+	defer func() {
+		s.statusMu.Lock()
+		s.exclusive = false
+		s.statusMu.Unlock()
+	}()
+	return fn()
+}
+
+// SetPaused pauses or resumes scanning. While paused, scans are refused
+// with ErrPaused, a running scan stops after the manga in progress, and
+// queued scans wait in the queue until scanning resumes.
+func (s *Service) SetPaused(paused bool) {
+	s.paused.Store(paused)
+}
+
+// Paused reports whether scanning is paused.
+func (s *Service) Paused() bool {
+	return s.paused.Load()
+}
+
+// haltErr returns the error a running scan should stop with: ErrStopped
+// after Stop, ErrPaused while paused, nil otherwise.
+func (s *Service) haltErr() error {
+	switch {
+	case s.stopping.Load():
+		return ErrStopped
+	case s.paused.Load():
+		return ErrPaused
+	}
+	return nil
+}
+
+// beginScan marks a scan as running and opens its run log, under the id
+// carried by ctx when the caller picked one with WithRunID.
+func (s *Service) beginScan(ctx context.Context, scope string) error {
 	s.statusMu.Lock()
 	defer s.statusMu.Unlock()
-	if s.status.Running {
-		return false
+	if s.status.Running || s.exclusive || s.stopping.Load() {
+		return ErrScanRunning
+	}
+	if s.paused.Load() {
+		return ErrPaused
 	}
 	s.status.Running = true
+	s.status.RunID = runIDFromContext(ctx)
 	s.status.Scope = scope
 	s.status.CurrentBookshelf = ""
 	s.status.CompletedBookshelves = 0
 	s.status.TotalBookshelves = 0
-	s.status.StartedAt = time.Now().UTC().Format(time.RFC3339)
+	s.status.StartedAt = s.now().UTC().Format(time.RFC3339)
 	s.status.FinishedAt = ""
 	s.status.LastError = ""
-	return true
+	s.startRunLog(s.status.RunID, scope, s.status.StartedAt)
+	return nil
 }
 
 func (s *Service) finishScan(summary Summary, err error) {
@@ -557,7 +940,8 @@ func (s *Service) finishScan(summary Summary, err error) {
 	defer s.statusMu.Unlock()
 	s.status.Running = false
 	s.status.CurrentBookshelf = ""
-	s.status.FinishedAt = time.Now().UTC().Format(time.RFC3339)
+	s.status.FinishedAt = s.now().UTC().Format(time.RFC3339)
+	s.finishRunLog(s.status.FinishedAt, err)
 	if err != nil {
 		s.status.LastError = err.Error()
 		return
@@ -565,66 +949,253 @@ func (s *Service) finishScan(summary Summary, err error) {
 	s.status.LastError = ""
 	s.status.LastSummary = summary
 	s.status.LastSuccessAt = s.status.FinishedAt
-}
+	s.wakeChecksumBackfill()
+}
+
+// scanBookshelfManga rescans every manga directly under a bookshelf root.
+// The root is read in batches of rootReadBatchSize entries so huge flat
+// roots never have their whole listing in memory; progress reports the
+// running summary after each batch. Only each batch is in natural name
+// order, so across batches manga are scanned in whatever order the
+// directory lists them. What is stored does not depend on it: manga ids
+// come from their paths and claimMangaPath settles case duplicates by
+// path, not by which is scanned first.
+// An ArchiveRoot shelf is scanned as its single manga.
+func (s *Service) scanBookshelfManga(ctx context.Context, shelf bookshelfRecord, cycleID string, completed map[string]struct{}, progress func(Summary)) (Summary, error) {
+	summary := Summary{}
+	seen := make(map[string]string)
+	if shelf.ArchiveRoot {
+		if err := s.scanRootEntry(ctx, shelf, shelf.RootPath, cycleID, completed, seen, &summary); err != nil {
+			return Summary{}, err
+		}
+		if progress != nil {
+			progress(summary)
+		}
+		if err := s.removeStaleBookshelfManga(ctx, shelf, seen); err != nil {
+			return Summary{}, err
+		}
+		return summary, nil
+	}
 
-func (s *Service) discoverBookshelfManga(shelf bookshelfRecord) ([]mangaRecord, error) {
-	items := make([]mangaRecord, 0)
-	entries, err := os.ReadDir(shelf.RootPath)
+	root, err := media.OpenDir(shelf.RootPath)
 	if err != nil {
-		return nil, fmt.Errorf("read bookshelf root %q: %w", shelf.RootPath, err)
+		return Summary{}, fmt.Errorf("read bookshelf root %q: %w", shelf.RootPath, err)
 	}
+	defer root.Close()
 
-	sort.Slice(entries, func(i, j int) bool {
-		return naturalLess(entries[i].Name(), entries[j].Name())
-	})
+	for {
+		entries, readErr := root.ReadDir(rootReadBatchSize)
+		if readErr != nil && readErr != io.EOF {
+			return Summary{}, fmt.Errorf("read bookshelf root %q: %w", shelf.RootPath, readErr)
+		}
+		sort.Slice(entries, func(i, j int) bool {
+			return naturalLess(entries[i].Name(), entries[j].Name())
+		})
 
-	for _, entry := range entries {
-		fullPath := filepath.Join(shelf.RootPath, entry.Name())
-		switch {
-		case entry.IsDir():
-			record, err := discoverDirectoryManga(shelf.ID, fullPath)
-			if err != nil {
-				return nil, err
+		for _, entry := range entries {
+			if err := ctx.Err(); err != nil {
+				return Summary{}, err
 			}
-			if len(record.Chapters) > 0 {
-				items = append(items, record)
+			if err := s.haltErr(); err != nil {
+				return Summary{}, err
 			}
-		case media.IsArchiveFile(entry.Name()):
-			record, err := discoverArchiveManga(shelf.ID, fullPath)
-			if err != nil {
-				return nil, err
+			if !entry.IsDir() && !media.IsArchiveFile(entry.Name()) {
+				continue
+			}
+			if s.options.SkipHiddenFiles && media.IsHiddenName(entry.Name()) {
+				continue
 			}
-			if len(record.Chapters) > 0 {
-				items = append(items, record)
+
+			fullPath := filepath.Join(shelf.RootPath, entry.Name())
+			if err := s.scanRootEntry(ctx, shelf, fullPath, cycleID, completed, seen, &summary); err != nil {
+				return Summary{}, err
 			}
 		}
+
+		if progress != nil {
+			progress(summary)
+		}
+		if readErr == io.EOF || len(entries) == 0 {
+			break
+		}
 	}
 
-	sort.Slice(items, func(i, j int) bool {
-		return naturalLess(items[i].Title, items[j].Title)
-	})
-	return items, nil
+	if err := s.removeStaleBookshelfManga(ctx, shelf, seen); err != nil {
+		return Summary{}, err
+	}
+	return summary, nil
+}
+
+// scanRootEntry rescans the manga at fullPath, or only counts it when its
+// source is unchanged since it was indexed, including when the interrupted
+// run being resumed already indexed it.
+func (s *Service) scanRootEntry(ctx context.Context, shelf bookshelfRecord, fullPath string, cycleID string, completed map[string]struct{}, seen map[string]string, summary *Summary) error {
+	mangaID := s.pathID("m", fullPath)
+	if !s.claimMangaPath(seen, mangaID, fullPath) {
+		return nil
+	}
+
+	fingerprint := s.mangaFingerprint(shelf.ID, fullPath)
+	stored, storedFingerprint, err := s.storedMangaSummary(ctx, mangaID)
+	if err != nil {
+		return err
+	}
+	_, resumed := completed[mangaID]
+	if fingerprint != "" && fingerprint == storedFingerprint {
+		if cycleID != "" {
+			if err := recordScanCheckpoint(ctx, s.db, cycleID, mangaID); err != nil {
+				return err
+			}
+		}
+		summary.add(stored)
+		detail := "unchanged since the last scan"
+		if resumed {
+			detail = "already scanned before the run was interrupted"
+		}
+		s.logRun(RunLogEntry{
+			Action:   RunActionSkipped,
+			MangaID:  mangaID,
+			Path:     fullPath,
+			Chapters: stored.ChapterCount,
+			Pages:    stored.PageCount,
+			Detail:   detail,
+		})
+		return nil
+	}
+
+	mangaSummary, _, err := s.rescanManga(ctx, shelf.ID, mangaID, fullPath, cycleID, fingerprint)
+	if err != nil {
+		return err
+	}
+	summary.add(mangaSummary)
+	return nil
+}
+
+// storedMangaSummary counts what the index holds for a manga and returns
+// the source fingerprint it was indexed with, or "" when it is not indexed.
+func (s *Service) storedMangaSummary(ctx context.Context, mangaID string) (Summary, string, error) {
+	var summary Summary
+	var fingerprint sql.NullString
+	err := s.db.QueryRowContext(ctx, `
+		SELECT
+			1,
+			(SELECT COUNT(*) FROM chapter WHERE manga_id = m.id),
+			m.page_count,
+			m.source_fingerprint
+		FROM manga m
+		WHERE m.id = ?
+	`, mangaID).Scan(&summary.MangaCount, &summary.ChapterCount, &summary.PageCount, &fingerprint)
+	if err == sql.ErrNoRows {
+		return Summary{}, "", nil
+	}
+	if err != nil {
+		return Summary{}, "", fmt.Errorf("load stored manga summary: %w", err)
+	}
+	return summary, fingerprint.String, nil
+}
+
+func recordSummary(record mangaRecord) Summary {
+	return Summary{
+		MangaCount:   1,
+		ChapterCount: len(record.Chapters),
+		PageCount:    record.PageCount,
+	}
+}
+
+func (s *Summary) add(other Summary) {
+	s.MangaCount += other.MangaCount
+	s.ChapterCount += other.ChapterCount
+	s.PageCount += other.PageCount
+	s.Timings.add(other.Timings)
+}
+
+// rescanManga discovers a manga from disk and replaces its stored rows,
+// timing each stage. The summary only counts the manga when it was found.
+func (s *Service) rescanManga(ctx context.Context, bookshelfID string, mangaID string, path string, cycleID string, fingerprint string) (Summary, bool, error) {
+	start := s.now()
+	record, found, err := s.discoverMangaByPath(bookshelfID, path)
+	if err != nil {
+		return Summary{}, false, err
+	}
+	record.SourceFingerprint = fingerprint
+	if found {
+		if err := s.fillChecksums(ctx, mangaID, &record); err != nil {
+			return Summary{}, false, err
+		}
+		if err := s.fillCoverBlurhash(ctx, mangaID, &record); err != nil {
+			return Summary{}, false, err
+		}
+	}
+	discovered := s.now()
+
+	existed, err := s.replaceManga(ctx, mangaID, record, found, cycleID)
+	if err != nil {
+		return Summary{}, false, err
+	}
+
+	summary := Summary{}
+	if found {
+		summary = recordSummary(record)
+	}
+	s.logRun(rescanLogEntry(mangaID, path, record, found, existed))
+	summary.Timings.Decode = recordDecodeTime(record)
+	summary.Timings.Walk = max(discovered.Sub(start)-summary.Timings.Decode, 0)
+	summary.Timings.Database = s.now().Sub(discovered)
+	return summary, found, nil
+}
+
+// rescanLogEntry describes what a rescan did to a manga for the run log.
+func rescanLogEntry(mangaID string, path string, record mangaRecord, found bool, existed bool) RunLogEntry {
+	entry := RunLogEntry{MangaID: mangaID, Path: path}
+	switch {
+	case found:
+		entry.Action = RunActionUpdated
+		if !existed {
+			entry.Action = RunActionAdded
+		}
+		entry.Title = record.Title
+		entry.Chapters = len(record.Chapters)
+		entry.Pages = record.PageCount
+	case existed:
+		entry.Action = RunActionRemoved
+		entry.Detail = "no longer holds any chapters"
+	default:
+		entry.Action = RunActionSkipped
+		entry.Detail = "no chapters found"
+	}
+	return entry
+}
+
+func recordDecodeTime(record mangaRecord) time.Duration {
+	total := record.coverDecodeTime
+	for _, chapter := range record.Chapters {
+		for _, page := range chapter.Pages {
+			total += page.decodeTime
+		}
+	}
+	return total
 }
 
 func (s *Service) discoverMangaByPath(bookshelfID string, path string) (mangaRecord, bool, error) {
-	info, err := os.Stat(path)
+	info, err := media.Stat(path)
 	if err != nil {
-		if os.IsNotExist(err) {
+		if errors.Is(err, fs.ErrNotExist) {
 			return mangaRecord{}, false, nil
 		}
 		return mangaRecord{}, false, fmt.Errorf("stat manga path %q: %w", path, err)
 	}
 
 	if info.IsDir() {
-		record, err := discoverDirectoryManga(bookshelfID, path)
+		record, err := s.discoverDirectoryManga(bookshelfID, path)
 		if err != nil {
 			return mangaRecord{}, false, err
 		}
-		return record, len(record.Chapters) > 0, nil
+		coverOnly := s.options.IndexCoverOnly && record.CoverPath != ""
+		return record, len(record.Chapters) > 0 || coverOnly, nil
 	}
 
 	if media.IsArchiveFile(path) {
-		record, err := discoverArchiveManga(bookshelfID, path)
+		record, err := s.discoverArchiveManga(bookshelfID, path)
 		if err != nil {
 			return mangaRecord{}, false, err
 		}
@@ -634,28 +1205,30 @@ func (s *Service) discoverMangaByPath(bookshelfID string, path string) (mangaRec
 	return mangaRecord{}, false, nil
 }
 
-func discoverDirectoryManga(bookshelfID string, path string) (mangaRecord, error) {
-	info, err := os.Stat(path)
+func (s *Service) discoverDirectoryManga(bookshelfID string, path string) (mangaRecord, error) {
+	info, err := media.Stat(path)
 	if err != nil {
 		return mangaRecord{}, fmt.Errorf("stat manga dir %q: %w", path, err)
 	}
 
 	metadata, _ := loadDirectoryMetadata(path)
-	title := cleanDisplayTitle(filepath.Base(path))
+	pageFilter := s.mangaPageFilter(path, metadata)
+	title := s.mangaTitle(bookshelfID, filepath.Base(path))
 	if metadata.Title != "" {
 		title = cleanDisplayTitle(metadata.Title)
 	}
 
 	record := mangaRecord{
 		BookshelfID: bookshelfID,
-		ID:          makeID("m", path),
+		ID:          s.pathID("m", path),
 		Title:       title,
 		TitleSort:   normalizeTitle(title),
+		FolderName:  filepath.Base(path),
 		Path:        path,
 		UpdatedAt:   info.ModTime(),
 	}
 
-	entries, err := os.ReadDir(path)
+	entries, err := media.ReadDir(path)
 	if err != nil {
 		return mangaRecord{}, fmt.Errorf("read manga dir %q: %w", path, err)
 	}
@@ -663,6 +1236,9 @@ func discoverDirectoryManga(bookshelfID string, path string) (mangaRecord, error
 	chapterSources := make([]chapterSource, 0)
 	rootImages := make([]string, 0)
 	for _, entry := range entries {
+		if s.options.SkipHiddenFiles && media.IsHiddenName(entry.Name()) {
+			continue
+		}
 		fullPath := filepath.Join(path, entry.Name())
 		switch {
 		case entry.IsDir():
@@ -676,11 +1252,18 @@ func discoverDirectoryManga(bookshelfID string, path string) (mangaRecord, error
 				SortName:  strings.TrimSuffix(entry.Name(), filepath.Ext(entry.Name())),
 				IsArchive: true,
 			})
+		case s.options.EnablePDF && media.IsPDFFile(entry.Name()):
+			chapterSources = append(chapterSources, chapterSource{
+				Path:     fullPath,
+				SortName: strings.TrimSuffix(entry.Name(), filepath.Ext(entry.Name())),
+				IsPDF:    true,
+			})
 		case media.IsImageFile(entry.Name()):
 			rootImages = append(rootImages, fullPath)
 		}
 	}
 
+	chapterSources = s.dropCaseDuplicateSources(chapterSources)
 	sort.Slice(chapterSources, func(i, j int) bool {
 		return naturalLess(chapterSources[i].SortName, chapterSources[j].SortName)
 	})
@@ -691,7 +1274,7 @@ func discoverDirectoryManga(bookshelfID string, path string) (mangaRecord, error
 	record.CoverPath = detectCover(rootImages)
 	if metadata.Cover != "" {
 		coverPath := filepath.Join(path, metadata.Cover)
-		if _, err := os.Stat(coverPath); err == nil {
+		if _, err := media.Stat(coverPath); err == nil {
 			record.CoverPath = media.FileRef(coverPath)
 		}
 	}
@@ -711,21 +1294,31 @@ func discoverDirectoryManga(bookshelfID string, path string) (mangaRecord, error
 			chapter chapterRecord
 			err     error
 		)
-		if source.IsArchive {
-			chapter, err = discoverArchiveChapter(record.ID, record.Title, source.Path)
-		} else {
-			chapter, err = discoverDirectoryChapter(record.ID, record.Title, source.Path)
+		switch {
+		case source.IsArchive:
+			chapter, err = s.discoverArchiveChapter(record.ID, record.Title, source.Path, pageFilter)
+		case source.IsPDF:
+			chapter, err = s.discoverPDFChapter(record.ID, record.Title, source.Path)
+		default:
+			chapter, err = s.discoverDirectoryChapter(record.ID, record.Title, source.Path, pageFilter)
 		}
 		if err != nil {
 			return mangaRecord{}, err
 		}
 		if title := chapterTitles[filepath.Base(source.Path)]; title != "" {
 			chapter.Title = title
-			chapter.Number = parseChapterNumber(title)
+			chapter.Number, chapter.Volume = s.parseChapterLabel(title, source.Path)
 		}
 		if len(chapter.Pages) == 0 {
 			continue
 		}
+		if s.chapterStillChanging(source.Path, chapter.UpdatedAt) {
+			if s.options.DeferIncompleteChapters {
+				s.logger.Info("deferring possibly incomplete chapter", "path", source.Path)
+				continue
+			}
+			chapter.PossiblyIncomplete = true
+		}
 		record.Chapters = append(record.Chapters, chapter)
 		record.PageCount += chapter.PageCount
 		if chapter.UpdatedAt.After(record.UpdatedAt) {
@@ -733,11 +1326,17 @@ func discoverDirectoryManga(bookshelfID string, path string) (mangaRecord, error
 		}
 	}
 
+	if len(record.Chapters) == 0 && s.options.IndexCoverOnly && onlyCoverImages(rootImages) {
+		return record, nil
+	}
+
 	if len(record.Chapters) == 0 {
-		chapter, err := buildPagesChapter(record.ID, record.Title, path, rootImages)
+		pages, excluded := pageFilter.keptPaths(rootImages)
+		chapter, err := s.buildPagesChapter(record.ID, looseImagesChapterTitle, path, pages)
 		if err != nil {
 			return mangaRecord{}, err
 		}
+		chapter.ExcludedPages = excluded
 		if len(chapter.Pages) > 0 {
 			record.Chapters = append(record.Chapters, chapter)
 			record.PageCount = chapter.PageCount
@@ -749,41 +1348,135 @@ func discoverDirectoryManga(bookshelfID string, path string) (mangaRecord, error
 		record.CoverPath = record.Chapters[0].Pages[0].Path
 	}
 
-	return record, nil
-}
-
-func loadDirectoryMetadata(path string) (directoryMetadata, error) {
-	payload, err := os.ReadFile(filepath.Join(path, "metadata.json"))
-	if err != nil {
-		return directoryMetadata{}, err
+	// An explicit metadata.json title wins over ComicInfo, which in turn
+	// replaces the folder title only when the title source asks for it.
+	if metadata.Title == "" {
+		if series := s.comicInfoSeries(record); series != "" {
+			record.Title = series
+			record.TitleSort = normalizeTitle(series)
+		}
 	}
 
-	var metadata directoryMetadata
-	if err := json.Unmarshal(payload, &metadata); err != nil {
-		return directoryMetadata{}, fmt.Errorf("parse metadata %q: %w", path, err)
+	comicInfoDirs := []string{path}
+	if len(record.Chapters) > 0 && record.Chapters[0].Path != path {
+		comicInfoDirs = append(comicInfoDirs, record.Chapters[0].Path)
 	}
+	record.ReadingDirection = s.readingDirection(metadata.ReadingDirection, comicInfoDirs, record)
+	record.ReadingMode = media.InferredReadingMode(record.ReadingDirection)
 
-	return metadata, nil
+	return record, nil
 }
 
-func discoverDirectoryChapter(mangaID string, mangaTitle string, path string) (chapterRecord, error) {
-	images, err := collectImages(path)
-	if err != nil {
-		return chapterRecord{}, err
+// readingDirection picks a manga's reading direction. A valid override from
+// metadata.json wins, then the Manga flag of the first ComicInfo.xml found
+// in dirs or chapter archives, then the page shape heuristic, and left-to-right otherwise.
+func (s *Service) readingDirection(override string, dirs []string, record mangaRecord) string {
+	override = strings.ToLower(strings.TrimSpace(override))
+	if media.ValidReadingDirection(override) {
+		return override
+	}
+	if override != "" && s.logger != nil {
+		s.logger.Warn("ignoring unknown reading direction override", "path", record.Path, "readingDirection", override)
 	}
-	return buildPagesChapter(mangaID, normalizeChapterDisplayTitle(filepath.Base(path), mangaTitle), path, images)
-}
 
-func discoverArchiveChapter(mangaID string, mangaTitle string, path string) (chapterRecord, error) {
-	info, err := os.Stat(path)
-	if err != nil {
-		return chapterRecord{}, fmt.Errorf("stat chapter archive %q: %w", path, err)
+	for _, dir := range dirs {
+		info, found, err := readComicInfo(dir)
+		if err != nil {
+			if s.logger != nil {
+				s.logger.Debug("unreadable comicinfo", "path", dir, "error", err)
+			}
+			continue
+		}
+		if !found {
+			continue
+		}
+		if direction := info.ReadingDirection(); direction != "" {
+			return direction
+		}
+		break
 	}
 
-	entries, err := media.ListArchiveImages(path)
-	if err != nil {
+	if mostlyVerticalStrips(record) {
+		return media.ReadingVertical
+	}
+	return media.ReadingLeftToRight
+}
+
+// mostlyVerticalStrips reports whether most pages with known dimensions are
+// tall strips, as in webtoons.
+func mostlyVerticalStrips(record mangaRecord) bool {
+	measured, strips := 0, 0
+	for _, chapter := range record.Chapters {
+		for _, page := range chapter.Pages {
+			if page.Width <= 0 || page.Height <= 0 {
+				continue
+			}
+			measured++
+			if float64(page.Height) >= verticalStripRatio*float64(page.Width) {
+				strips++
+			}
+		}
+	}
+	return measured > 0 && strips*2 > measured
+}
+
+// chapterStillChanging reports whether a chapter source or any of its pages
+// was modified within the incomplete window, which usually means files are
+// still being copied or downloaded into it.
+func (s *Service) chapterStillChanging(path string, updatedAt time.Time) bool {
+	if s.options.IncompleteChapterWindow <= 0 {
+		return false
+	}
+	if info, err := media.Stat(path); err == nil {
+		updatedAt = maxTime(updatedAt, info.ModTime())
+	}
+	return s.stillChanging(updatedAt)
+}
+
+// stillChanging reports whether modTime falls within the incomplete
+// window.
+func (s *Service) stillChanging(modTime time.Time) bool {
+	window := s.options.IncompleteChapterWindow
+	return window > 0 && s.now().Sub(modTime) < window
+}
+
+func loadDirectoryMetadata(path string) (directoryMetadata, error) {
+	payload, err := media.ReadFile(filepath.Join(path, "metadata.json"))
+	if err != nil {
+		return directoryMetadata{}, err
+	}
+
+	var metadata directoryMetadata
+	if err := json.Unmarshal(payload, &metadata); err != nil {
+		return directoryMetadata{}, fmt.Errorf("parse metadata %q: %w", path, err)
+	}
+
+	return metadata, nil
+}
+
+func (s *Service) discoverDirectoryChapter(mangaID string, mangaTitle string, path string, pageFilter PageFilter) (chapterRecord, error) {
+	images, err := collectImages(path, s.options.SkipHiddenFiles)
+	if err != nil {
+		return chapterRecord{}, err
+	}
+	images, excluded := pageFilter.keptPaths(images)
+	record, err := s.buildPagesChapter(mangaID, normalizeChapterDisplayTitle(filepath.Base(path), mangaTitle), path, images)
+	record.ExcludedPages = excluded
+	return record, err
+}
+
+func (s *Service) discoverArchiveChapter(mangaID string, mangaTitle string, path string, pageFilter PageFilter) (chapterRecord, error) {
+	info, err := media.Stat(path)
+	if err != nil {
+		return chapterRecord{}, fmt.Errorf("stat chapter archive %q: %w", path, err)
+	}
+
+	entries, err := media.ListArchiveImages(path)
+	if err != nil {
 		return chapterRecord{}, fmt.Errorf("read chapter archive %q: %w", path, err)
 	}
+	entries = s.visibleArchiveEntries(entries)
+	entries, excluded := pageFilter.keptEntries(entries)
 
 	sort.Slice(entries, func(i, j int) bool {
 		return naturalLess(entries[i].Name, entries[j].Name)
@@ -791,17 +1484,21 @@ func discoverArchiveChapter(mangaID string, mangaTitle string, path string) (cha
 
 	title := normalizeChapterDisplayTitle(filepath.Base(path), mangaTitle)
 	record := chapterRecord{
-		ID:        makeID("c", path),
-		MangaID:   mangaID,
-		Title:     title,
-		Number:    parseChapterNumber(title),
-		Path:      path,
-		UpdatedAt: info.ModTime(),
+		ID:            s.pathID("c", path),
+		MangaID:       mangaID,
+		Title:         title,
+		Path:          path,
+		UpdatedAt:     info.ModTime(),
+		ExcludedPages: excluded,
 	}
+	record.Number, record.Volume = s.parseChapterLabel(title, path)
 
 	archiveKind := media.ArchiveKind(path)
-	for index, entry := range entries {
-		page, updatedAt, err := buildArchivePage(record.ID, index, archiveKind, path, entry)
+	for _, entry := range entries {
+		page, updatedAt, err := s.buildArchivePage(record.ID, len(record.Pages), archiveKind, path, entry)
+		if errors.Is(err, errNotAnImage) {
+			continue
+		}
 		if err != nil {
 			return chapterRecord{}, err
 		}
@@ -809,22 +1506,35 @@ func discoverArchiveChapter(mangaID string, mangaTitle string, path string) (cha
 		record.PageCount++
 		record.UpdatedAt = maxTime(record.UpdatedAt, updatedAt)
 	}
+	s.numberPages(record.Pages)
+
+	comicInfo, found, err := media.ReadArchiveComicInfo(path)
+	if err != nil && s.logger != nil {
+		s.logger.Debug("unreadable comicinfo", "path", path, "error", err)
+	}
+	if found {
+		s.applyComicInfo(&record, comicInfo)
+	}
 
 	return record, nil
 }
 
-func buildPagesChapter(mangaID string, title string, logicalPath string, imagePaths []string) (chapterRecord, error) {
-	number := parseChapterNumber(title)
+func (s *Service) buildPagesChapter(mangaID string, title string, logicalPath string, imagePaths []string) (chapterRecord, error) {
+	number, volume := s.parseChapterLabel(title, logicalPath)
 	record := chapterRecord{
-		ID:      makeID("c", logicalPath),
+		ID:      s.pathID("c", logicalPath),
 		MangaID: mangaID,
 		Title:   title,
 		Number:  number,
+		Volume:  volume,
 		Path:    logicalPath,
 	}
 
-	for index, imagePath := range imagePaths {
-		page, updatedAt, err := buildFilePage(record.ID, index, imagePath)
+	for _, imagePath := range imagePaths {
+		page, updatedAt, err := s.buildFilePage(record.ID, len(record.Pages), imagePath)
+		if errors.Is(err, errNotAnImage) {
+			continue
+		}
 		if err != nil {
 			return chapterRecord{}, err
 		}
@@ -832,20 +1542,113 @@ func buildPagesChapter(mangaID string, title string, logicalPath string, imagePa
 		record.PageCount++
 		record.UpdatedAt = maxTime(record.UpdatedAt, updatedAt)
 	}
+	s.numberPages(record.Pages)
+	return record, nil
+}
+
+// numberPages replaces the sequential page indexes with the numbers in the
+// page file names when PreserveFilenameNumbers is set. Names are 1-based
+// unless a page is numbered 0. Pages without a number, or whose number
+// collides with or comes before the previous page's, take the next free
+// index, so indexes stay unique and follow the sorted order.
+func (s *Service) numberPages(pages []pageRecord) {
+	if !s.options.PreserveFilenameNumbers || len(pages) == 0 {
+		return
+	}
+
+	numbers := make([]int, len(pages))
+	base := 1
+	for i, page := range pages {
+		numbers[i] = pageFileNumber(page.Path)
+		if numbers[i] == 0 {
+			base = 0
+		}
+	}
+
+	next := 0
+	for i := range pages {
+		index := numbers[i] - base
+		if numbers[i] < 0 || index < next || index-next > maxPageNumberGap {
+			index = next
+		}
+		pages[i].Index = index
+		next = index + 1
+	}
+}
+
+// pageFileNumber returns the last number in a page's file name, ignoring
+// the extension, or -1 when it has none.
+func pageFileNumber(ref string) int {
+	name := ref
+	if parsed, err := media.ParseRef(ref); err == nil {
+		name = parsed.Path
+		if parsed.EntryPath != "" {
+			name = parsed.EntryPath
+		}
+	}
+	name = filepath.Base(filepath.FromSlash(name))
+	matches := pageNumberPattern.FindStringSubmatch(strings.TrimSuffix(name, filepath.Ext(name)))
+	if matches == nil {
+		return -1
+	}
+	number, err := strconv.Atoi(matches[1])
+	if err != nil {
+		return -1
+	}
+	return number
+}
+
+// discoverPDFChapter indexes a PDF as one chapter. Only the page tree is
+// read here; pages are rasterized on demand when first requested.
+func (s *Service) discoverPDFChapter(mangaID string, mangaTitle string, path string) (chapterRecord, error) {
+	info, err := media.Stat(path)
+	if err != nil {
+		return chapterRecord{}, fmt.Errorf("stat chapter pdf %q: %w", path, err)
+	}
+
+	title := normalizeChapterDisplayTitle(filepath.Base(path), mangaTitle)
+	record := chapterRecord{
+		ID:        s.pathID("c", path),
+		MangaID:   mangaID,
+		Title:     title,
+		Path:      path,
+		UpdatedAt: info.ModTime(),
+	}
+	record.Number, record.Volume = s.parseChapterLabel(title, path)
+
+	pageCount, err := media.PDFPageCount(path)
+	if err != nil {
+		s.logger.Warn("skipping unreadable pdf chapter", "path", path, "error", err)
+		return record, nil
+	}
+
+	for index := 0; index < pageCount; index++ {
+		record.Pages = append(record.Pages, pageRecord{
+			ID:          makeID("p", path+"|"+strconv.Itoa(index)),
+			ChapterID:   record.ID,
+			Index:       index,
+			Path:        media.PDFRef(path, index),
+			Mime:        "image/png",
+			Orientation: media.OrientationNormal,
+		})
+	}
+	record.PageCount = pageCount
 	return record, nil
 }
 
-func discoverArchiveManga(bookshelfID string, path string) (mangaRecord, error) {
-	info, err := os.Stat(path)
+func (s *Service) discoverArchiveManga(bookshelfID string, path string) (mangaRecord, error) {
+	info, err := media.Stat(path)
 	if err != nil {
 		return mangaRecord{}, fmt.Errorf("stat archive %q: %w", path, err)
 	}
 
+	title := s.mangaTitle(bookshelfID, strings.TrimSuffix(filepath.Base(path), filepath.Ext(path)))
 	record := mangaRecord{
 		BookshelfID: bookshelfID,
-		ID:          makeID("m", path),
-		Title:       cleanDisplayTitle(strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))),
-		TitleSort:   normalizeTitle(cleanDisplayTitle(strings.TrimSuffix(filepath.Base(path), filepath.Ext(path)))),
+		ID:          s.pathID("m", path),
+		Title:       title,
+		TitleSort:   normalizeTitle(title),
+		FolderName:  filepath.Base(path),
 		Path:        path,
 		UpdatedAt:   info.ModTime(),
 	}
@@ -854,14 +1657,23 @@ func discoverArchiveManga(bookshelfID string, path string) (mangaRecord, error)
 	if err != nil {
 		return mangaRecord{}, fmt.Errorf("read archive %q: %w", path, err)
 	}
+	entries = s.visibleArchiveEntries(entries)
 
 	chapterMap := make(map[string]*archiveChapter)
 	order := make([]string, 0)
 	defaultTitle := record.Title
 	archiveKind := media.ArchiveKind(path)
 
+	// Folders differing only in case are one chapter with
+	// CaseInsensitivePaths, named as first seen.
+	foldedKeys := make(map[string]string)
 	for _, entry := range entries {
 		key, title := archiveChapterKey(entry.Name, defaultTitle)
+		if first, ok := foldedKeys[s.pathKey(key)]; ok {
+			key = first
+		} else {
+			foldedKeys[s.pathKey(key)] = key
+		}
 		if _, ok := chapterMap[key]; !ok {
 			chapterMap[key] = &archiveChapter{Title: title}
 			order = append(order, key)
@@ -875,20 +1687,26 @@ func discoverArchiveManga(bookshelfID string, path string) (mangaRecord, error)
 
 	for _, key := range order {
 		chapterData := chapterMap[key]
+		pages, excluded := s.pageFilter.keptEntries(chapterData.Pages)
+		chapterData.Pages = pages
 		sort.Slice(chapterData.Pages, func(i, j int) bool {
 			return naturalLess(chapterData.Pages[i].Name, chapterData.Pages[j].Name)
 		})
 
 		chapter := chapterRecord{
-			ID:      makeID("c", path+"|"+key),
-			MangaID: record.ID,
-			Title:   normalizeChapterDisplayTitle(chapterData.Title, record.Title),
-			Number:  parseChapterNumber(normalizeChapterDisplayTitle(chapterData.Title, record.Title)),
-			Path:    path + "|" + key,
+			ID:            s.pathID("c", path+"|"+key),
+			MangaID:       record.ID,
+			Title:         normalizeChapterDisplayTitle(chapterData.Title, record.Title),
+			Path:          path + "|" + key,
+			ExcludedPages: excluded,
 		}
+		chapter.Number, chapter.Volume = s.parseChapterLabel(chapter.Title, chapter.Path)
 
-		for index, pageEntry := range chapterData.Pages {
-			page, updatedAt, err := buildArchivePage(chapter.ID, index, archiveKind, path, pageEntry)
+		for _, pageEntry := range chapterData.Pages {
+			page, updatedAt, err := s.buildArchivePage(chapter.ID, len(chapter.Pages), archiveKind, path, pageEntry)
+			if errors.Is(err, errNotAnImage) {
+				continue
+			}
 			if err != nil {
 				return mangaRecord{}, err
 			}
@@ -896,6 +1714,7 @@ func discoverArchiveManga(bookshelfID string, path string) (mangaRecord, error)
 			chapter.PageCount++
 			chapter.UpdatedAt = maxTime(chapter.UpdatedAt, updatedAt)
 		}
+		s.numberPages(chapter.Pages)
 
 		if len(chapter.Pages) == 0 {
 			continue
@@ -908,50 +1727,104 @@ func discoverArchiveManga(bookshelfID string, path string) (mangaRecord, error)
 	if len(record.Chapters) > 0 && len(record.Chapters[0].Pages) > 0 {
 		record.CoverPath = record.Chapters[0].Pages[0].Path
 	}
+	if series := s.comicInfoSeries(record); series != "" {
+		record.Title = series
+		record.TitleSort = normalizeTitle(series)
+	}
+	record.ReadingDirection = s.readingDirection("", []string{path}, record)
+	record.ReadingMode = media.InferredReadingMode(record.ReadingDirection)
 
 	return record, nil
 }
 
-func buildFilePage(chapterID string, index int, path string) (pageRecord, time.Time, error) {
-	info, err := os.Stat(path)
+func (s *Service) buildFilePage(chapterID string, index int, path string) (pageRecord, time.Time, error) {
+	info, err := media.Stat(path)
 	if err != nil {
 		return pageRecord{}, time.Time{}, fmt.Errorf("stat image %q: %w", path, err)
 	}
 
-	width, height := readDimensions(media.FileRef(path))
+	start := s.now()
+	pageInfo := readPageInfo(media.FileRef(path))
+	mime, err := s.pageMime(media.FileRef(path), path)
+	if err != nil {
+		return pageRecord{}, time.Time{}, err
+	}
 	return pageRecord{
-		ID:        makeID("p", path),
-		ChapterID: chapterID,
-		Index:     index,
-		Path:      media.FileRef(path),
-		Mime:      media.GuessMime(path),
-		Width:     width,
-		Height:    height,
-		SizeBytes: info.Size(),
+		ID:          makeID("p", path),
+		ChapterID:   chapterID,
+		Index:       index,
+		Path:        media.FileRef(path),
+		Mime:        mime,
+		Width:       pageInfo.Width,
+		Height:      pageInfo.Height,
+		SizeBytes:   info.Size(),
+		Orientation: pageInfo.Orientation,
+		Animated:    pageInfo.Animated,
+		decodeTime:  s.now().Sub(start),
 	}, info.ModTime(), nil
 }
 
-func buildArchivePage(chapterID string, index int, kind string, archivePath string, entry media.ArchiveEntry) (pageRecord, time.Time, error) {
+func (s *Service) buildArchivePage(chapterID string, index int, kind string, archivePath string, entry media.ArchiveEntry) (pageRecord, time.Time, error) {
 	ref := media.ArchiveRef(kind, archivePath, entry.Name)
-	width, height := readDimensions(ref)
+	start := s.now()
+	pageInfo := readPageInfo(ref)
+	mime, err := s.pageMime(ref, entry.Name)
+	if err != nil {
+		return pageRecord{}, time.Time{}, err
+	}
 	return pageRecord{
-		ID:        makeID("p", archivePath+"|"+entry.Name),
-		ChapterID: chapterID,
-		Index:     index,
-		Path:      ref,
-		Mime:      media.GuessMime(entry.Name),
-		Width:     width,
-		Height:    height,
-		SizeBytes: entry.Size,
+		ID:          makeID("p", archivePath+"|"+entry.Name),
+		ChapterID:   chapterID,
+		Index:       index,
+		Path:        ref,
+		Mime:        mime,
+		Width:       pageInfo.Width,
+		Height:      pageInfo.Height,
+		SizeBytes:   entry.Size,
+		Orientation: pageInfo.Orientation,
+		Animated:    pageInfo.Animated,
+		decodeTime:  s.now().Sub(start),
 	}, entry.ModifiedTime, nil
 }
 
-func collectImages(root string) ([]string, error) {
+// pageMime returns the content type of a page, from its extension or with
+// SniffMime from its leading bytes. Sniffed content that is recognisably
+// not an image, such as a Thumbs.db renamed to .jpg, is errNotAnImage;
+// content the sniffer does not recognise keeps the extension's type, since
+// it may be an image format the sniffer does not know.
+func (s *Service) pageMime(ref string, name string) (string, error) {
+	guessed := media.GuessMime(name)
+	if !s.options.SniffMime {
+		return guessed, nil
+	}
+	sniffed, err := media.SniffMime(ref)
+	if err != nil || sniffed == "application/octet-stream" {
+		return guessed, nil
+	}
+	if !strings.HasPrefix(sniffed, "image/") {
+		if s.logger != nil {
+			s.logger.Warn("skipping file that is not an image", "path", ref, "sniffed_mime", sniffed)
+		}
+		return "", errNotAnImage
+	}
+	if sniffed != guessed && s.logger != nil {
+		s.logger.Debug("page mime differs from extension", "path", ref, "extension_mime", guessed, "sniffed_mime", sniffed)
+	}
+	return sniffed, nil
+}
+
+func collectImages(root string, skipHidden bool) ([]string, error) {
 	items := make([]string, 0)
-	err := filepath.WalkDir(root, func(path string, entry fs.DirEntry, err error) error {
+	err := media.WalkDir(root, func(path string, entry fs.DirEntry, err error) error {
 		if err != nil {
 			return err
 		}
+		if skipHidden && path != root && media.IsHiddenName(entry.Name()) {
+			if entry.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
 		if entry.IsDir() {
 			return nil
 		}
@@ -975,14 +1848,30 @@ func detectCover(imagePaths []string) string {
 		return ""
 	}
 	for _, imagePath := range imagePaths {
-		base := strings.ToLower(filepath.Base(imagePath))
-		if strings.HasPrefix(base, "cover.") || strings.HasPrefix(base, "folder.") || strings.HasPrefix(base, "front.") {
+		if isCoverImage(imagePath) {
 			return media.FileRef(imagePath)
 		}
 	}
 	return media.FileRef(imagePaths[0])
 }
 
+func isCoverImage(imagePath string) bool {
+	base := strings.ToLower(filepath.Base(imagePath))
+	return strings.HasPrefix(base, "cover.") || strings.HasPrefix(base, "folder.") || strings.HasPrefix(base, "front.")
+}
+
+func onlyCoverImages(imagePaths []string) bool {
+	if len(imagePaths) == 0 {
+		return false
+	}
+	for _, imagePath := range imagePaths {
+		if !isCoverImage(imagePath) {
+			return false
+		}
+	}
+	return true
+}
+
 func archiveChapterKey(entryName string, fallback string) (string, string) {
 	clean := filepath.ToSlash(strings.TrimSpace(entryName))
 	parts := strings.Split(clean, "/")
@@ -1078,24 +1967,117 @@ func normalizeChapterDisplayTitle(raw string, mangaTitle string) string {
 	return title
 }
 
-func readDimensions(ref string) (int, int) {
-	cfg, err := media.DecodeConfig(ref)
+// readPageInfo returns the upright size of a page, its EXIF orientation and
+// whether it is animated. Pages that cannot be read are recorded with no
+// size.
+func readPageInfo(ref string) media.PageInfo {
+	info, err := media.DecodePageInfo(ref)
 	if err != nil {
-		return 0, 0
+		return media.PageInfo{Orientation: media.OrientationNormal}
 	}
-	return cfg.Width, cfg.Height
+	return info
 }
 
-func parseChapterNumber(title string) *float64 {
-	matches := chapterNumberPattern.FindStringSubmatch(title)
-	if len(matches) < 2 {
-		return nil
+// parseChapterLabel extracts the chapter number and volume from a chapter
+// title, warning when the title only contains implausible chapter numbers.
+func (s *Service) parseChapterLabel(title string, path string) (*float64, *int) {
+	number, volume, rejected := chapterLabelNumbers(title, s.maxChapterNumber())
+	if number == nil && len(rejected) > 0 {
+		s.logger.Warn("ignored implausible chapter number",
+			"title", title,
+			"path", path,
+			"candidates", rejected,
+			"max", s.maxChapterNumber(),
+		)
 	}
-	value, err := strconv.ParseFloat(matches[1], 64)
-	if err != nil {
-		return nil
+	return number, volume
+}
+
+// LabelPreview shows how a chapter folder or file name is read: the title
+// shown for it, the chapter number and volume parsed from that title, and
+// the tokens chapters are naturally sorted by.
+type LabelPreview struct {
+	Name     string    `json:"name"`
+	Title    string    `json:"title"`
+	Number   *float64  `json:"number"`
+	Volume   *int      `json:"volume"`
+	Rejected []float64 `json:"rejectedNumbers"`
+	SortKey  []string  `json:"sortKey"`
+}
+
+// PreviewLabel parses name the way a chapter of mangaTitle would be during
+// a scan, without touching the filesystem.
+func (s *Service) PreviewLabel(name string, mangaTitle string) LabelPreview {
+	title := normalizeChapterDisplayTitle(name, mangaTitle)
+	number, volume, rejected := chapterLabelNumbers(title, s.maxChapterNumber())
+	if rejected == nil {
+		rejected = []float64{}
+	}
+	return LabelPreview{
+		Name:     name,
+		Title:    title,
+		Number:   number,
+		Volume:   volume,
+		Rejected: rejected,
+		SortKey:  tokenizeNatural(name),
+	}
+}
+
+func (s *Service) maxChapterNumber() float64 {
+	if s.options.MaxChapterNumber > 0 {
+		return s.options.MaxChapterNumber
+	}
+	return defaultMaxChapterNumber
+}
+
+// chapterLabelNumbers returns the first plausible chapter number and the
+// volume found in title, along with any candidates rejected by limit. The
+// volume token is removed before looking for the chapter number so
+// "Vol 2 Chapter 10" parses as chapter 10 of volume 2.
+func chapterLabelNumbers(title string, limit float64) (*float64, *int, []float64) {
+	volume := parseVolumeNumber(title)
+	if volume == nil {
+		number, rejected := parseChapterNumber(title, limit)
+		return number, nil, rejected
+	}
+	number, rejected := parseChapterNumber(volumePattern.ReplaceAllString(title, " "), limit)
+	if number == nil && len(rejected) == 0 {
+		number, rejected = parseChapterNumber(title, limit)
 	}
-	return &value
+	return number, volume, rejected
+}
+
+func parseVolumeNumber(title string) *int {
+	for _, matches := range volumePattern.FindAllStringSubmatch(title, -1) {
+		token := strings.ToLower(matches[0])
+		if strings.HasPrefix(token, "\u7b2c") && !strings.HasSuffix(token, "\u5377") {
+			continue
+		}
+		value, err := strconv.Atoi(matches[1])
+		if err != nil {
+			continue
+		}
+		return &value
+	}
+	return nil
+}
+
+// parseChapterNumber returns the first number in title below limit. Larger
+// numbers, such as dates in folder names, are returned as rejected candidates.
+func parseChapterNumber(title string, limit float64) (*float64, []float64) {
+	var rejected []float64
+	for _, matches := range chapterNumberPattern.FindAllStringSubmatch(title, -1) {
+		value, err := strconv.ParseFloat(matches[1], 64)
+		if err != nil {
+			continue
+		}
+		if value >= limit {
+			rejected = append(rejected, value)
+			continue
+		}
+		return &value, rejected
+	}
+	return nil, rejected
 }
 
 func normalizeTitle(title string) string {
@@ -1111,6 +2093,14 @@ func (s *Service) setScanBookshelfProgress(name string, completed int, total int
 	s.status.LastSummary = summary
 }
 
+// setScanSummaryProgress publishes the running summary of the current
+// bookshelf without moving the bookshelf counters.
+func (s *Service) setScanSummaryProgress(summary Summary) {
+	s.statusMu.Lock()
+	defer s.statusMu.Unlock()
+	s.status.LastSummary = summary
+}
+
 func normalizeScanPath(path string) string {
 	trimmed := strings.TrimSpace(path)
 	if trimmed == "" {
@@ -1121,9 +2111,12 @@ func normalizeScanPath(path string) string {
 	return strings.ToLower(cleaned)
 }
 
-func resolveBookshelves(items []Bookshelf) ([]bookshelfRecord, error) {
-	resolved := make([]bookshelfRecord, 0, len(items))
-	for index, shelf := range items {
+// resolveBookshelves turns the configured bookshelves into records,
+// leaving out roots that do not exist and, with a warning, roots that are
+// files rather than directories unless ArchiveRoots accepts them.
+func (s *Service) resolveBookshelves() ([]bookshelfRecord, error) {
+	resolved := make([]bookshelfRecord, 0, len(s.bookshelves))
+	for index, shelf := range s.bookshelves {
 		name := strings.TrimSpace(shelf.Name)
 		root := strings.TrimSpace(shelf.Path)
 		if name == "" || root == "" {
@@ -1133,26 +2126,45 @@ func resolveBookshelves(items []Bookshelf) ([]bookshelfRecord, error) {
 		if err != nil {
 			return nil, fmt.Errorf("resolve bookshelf root %q: %w", root, err)
 		}
-		info, err := os.Stat(abs)
+		info, err := media.Stat(abs)
 		if err != nil {
-			if os.IsNotExist(err) {
+			if errors.Is(err, fs.ErrNotExist) {
 				continue
 			}
 			return nil, fmt.Errorf("stat bookshelf root %q: %w", abs, err)
 		}
-		if info.IsDir() {
-			resolved = append(resolved, bookshelfRecord{
-				ID:        makeID("bs", abs),
-				Name:      name,
-				RootPath:  abs,
-				SortOrder: index,
-				UpdatedAt: info.ModTime(),
-			})
-		}
+		archiveRoot := false
+		if !info.IsDir() {
+			if !s.options.ArchiveRoots || !media.IsArchiveFile(abs) {
+				s.warnRootNotDirectory(name, abs)
+				continue
+			}
+			archiveRoot = true
+		}
+		resolved = append(resolved, bookshelfRecord{
+			ID:              makeID("bs", abs),
+			Name:            name,
+			RootPath:        abs,
+			SortOrder:       index,
+			UpdatedAt:       info.ModTime(),
+			SkipStartupScan: shelf.SkipStartupScan,
+			ArchiveRoot:     archiveRoot,
+		})
 	}
 	return resolved, nil
 }
 
+func (s *Service) warnRootNotDirectory(name string, path string) {
+	if s.logger == nil {
+		return
+	}
+	if media.IsArchiveFile(path) {
+		s.logger.Warn("library root is an archive, not a directory; skipping it (set storage.archiveRoots to read it as a single manga)", "bookshelf", name, "path", path)
+		return
+	}
+	s.logger.Warn("library root is not a directory; skipping it", "bookshelf", name, "path", path)
+}
+
 func (s *Service) mergeExistingBookshelves(ctx context.Context, configured []bookshelfRecord) ([]bookshelfRecord, error) {
 	if s == nil || s.db == nil {
 		return configured, nil
@@ -1187,7 +2199,7 @@ func (s *Service) mergeExistingBookshelves(ctx context.Context, configured []boo
 		if _, ok := seen[target]; ok {
 			continue
 		}
-		info, err := os.Stat(shelf.RootPath)
+		info, err := media.Stat(shelf.RootPath)
 		if err != nil || !info.IsDir() {
 			continue
 		}
@@ -1212,7 +2224,7 @@ func resolveDynamicBookshelf(rootPath string, sortOrder int) (bookshelfRecord, e
 	if err != nil {
 		return bookshelfRecord{}, fmt.Errorf("resolve bookshelf root %q: %w", rootPath, err)
 	}
-	info, err := os.Stat(abs)
+	info, err := media.Stat(abs)
 	if err != nil {
 		return bookshelfRecord{}, fmt.Errorf("stat bookshelf root %q: %w", abs, err)
 	}
@@ -1281,7 +2293,7 @@ func (s *Service) prepareLibraryScan(ctx context.Context, bookshelves []bookshel
 		return err
 	}
 
-	if err := tx.Commit(); err != nil {
+	if err := s.commit(tx); err != nil {
 		return fmt.Errorf("commit scan bootstrap: %w", err)
 	}
 	return nil
@@ -1298,7 +2310,7 @@ func (s *Service) removeMissingBookshelves(ctx context.Context, bookshelves []bo
 		return err
 	}
 
-	if err := tx.Commit(); err != nil {
+	if err := s.commit(tx); err != nil {
 		return fmt.Errorf("commit cleanup transaction: %w", err)
 	}
 	return nil
@@ -1333,29 +2345,129 @@ func cleanupMissingBookshelves(ctx context.Context, tx *sql.Tx, bookshelves []bo
 	return nil
 }
 
-func (s *Service) replaceBookshelfManga(ctx context.Context, shelf bookshelfRecord, manga []mangaRecord) error {
+// replaceManga swaps the stored rows of a manga for record, or just removes
+// them when the manga was not found, and reports whether it was stored
+// before.
+func (s *Service) replaceManga(ctx context.Context, mangaID string, record mangaRecord, found bool, cycleID string) (bool, error) {
 	tx, err := s.db.BeginTx(ctx, nil)
 	if err != nil {
-		return fmt.Errorf("begin bookshelf transaction: %w", err)
+		return false, fmt.Errorf("begin manga transaction: %w", err)
 	}
 
-	tagsByMangaID, err := loadBookshelfMangaTagIDs(ctx, tx, shelf.ID)
+	tagIDs, err := loadMangaTagIDs(ctx, tx, mangaID)
 	if err != nil {
 		tx.Rollback()
-		return err
+		return false, err
+	}
+	memberships, err := loadCollectionMemberships(ctx, tx, mangaID)
+	if err != nil {
+		tx.Rollback()
+		return false, err
+	}
+	state, err := loadMangaState(ctx, tx, mangaID)
+	if err != nil {
+		tx.Rollback()
+		return false, err
+	}
+	record.Favorite = state.Favorite
+	record.Collection = state.Collection
+	record.SortName = record.Title
+	if state.SortNameLocked {
+		record.SortName = state.SortName
+		record.SortNameLocked = true
+	}
+	if state.ReadingModeLocked {
+		record.ReadingMode = state.ReadingMode
+		record.ReadingModeLocked = true
+	}
+	chapterStates, err := loadChapterStates(ctx, tx, mangaID)
+	if err != nil {
+		tx.Rollback()
+		return false, err
+	}
+	applyChapterStates(&record, chapterStates, s.now())
+	record.ContentUpdatedAt = record.UpdatedAt
+	if state.ContentUpdatedAt.Valid && sameChapterSet(record, chapterStates) {
+		record.ContentUpdatedAt = state.ContentUpdatedAt.Time
 	}
 
-	if err := clearBookshelfManga(ctx, tx, shelf.ID); err != nil {
+	if err := deleteMangaRecord(ctx, tx, mangaID, s.now()); err != nil {
 		tx.Rollback()
-		return fmt.Errorf("clear bookshelf %q: %w", shelf.Name, err)
+		return false, err
 	}
 
-	for _, record := range manga {
+	if found {
 		if err := insertManga(ctx, tx, record); err != nil {
 			tx.Rollback()
-			return err
+			return false, err
+		}
+		if err := restoreMangaTags(ctx, tx, record.ID, tagIDs); err != nil {
+			tx.Rollback()
+			return false, err
+		}
+		if err := restoreCollectionMemberships(ctx, tx, record.ID, memberships); err != nil {
+			tx.Rollback()
+			return false, err
+		}
+	}
+
+	if cycleID != "" {
+		if err := recordScanCheckpoint(ctx, tx, cycleID, mangaID); err != nil {
+			tx.Rollback()
+			return false, err
+		}
+	}
+
+	if err := s.commit(tx); err != nil {
+		return false, fmt.Errorf("commit manga %q: %w", mangaID, err)
+	}
+	return state.Exists, nil
+}
+
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+}
+
+// recordScanCheckpoint marks a manga as done in the scan cycle, so resuming
+// the cycle does not rescan it.
+func recordScanCheckpoint(ctx context.Context, db execer, cycleID string, mangaID string) error {
+	if _, err := db.ExecContext(ctx, `
+		INSERT OR IGNORE INTO scan_checkpoint(cycle_id, manga_id)
+		VALUES(?, ?)
+	`, cycleID, mangaID); err != nil {
+		return fmt.Errorf("record scan checkpoint: %w", err)
+	}
+	return nil
+}
+
+func (s *Service) removeStaleBookshelfManga(ctx context.Context, shelf bookshelfRecord, seen map[string]string) error {
+	rows, err := s.db.QueryContext(ctx, `SELECT id FROM manga WHERE bookshelf_id = ?`, shelf.ID)
+	if err != nil {
+		return fmt.Errorf("load bookshelf manga: %w", err)
+	}
+	stale := make([]string, 0)
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return fmt.Errorf("scan bookshelf manga: %w", err)
+		}
+		if _, ok := seen[id]; !ok {
+			stale = append(stale, id)
 		}
-		if err := restoreMangaTags(ctx, tx, record.ID, tagsByMangaID[record.ID]); err != nil {
+	}
+	if err := rows.Err(); err != nil {
+		rows.Close()
+		return fmt.Errorf("iterate bookshelf manga: %w", err)
+	}
+	rows.Close()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin bookshelf cleanup transaction: %w", err)
+	}
+	for _, id := range stale {
+		if err := deleteMangaRecord(ctx, tx, id, s.now()); err != nil {
 			tx.Rollback()
 			return err
 		}
@@ -1363,56 +2475,132 @@ func (s *Service) replaceBookshelfManga(ctx context.Context, shelf bookshelfReco
 
 	if _, err := tx.ExecContext(ctx, `
 		UPDATE bookshelf
-		SET updated_at = CURRENT_TIMESTAMP
+		SET updated_at = ?
 		WHERE id = ?
-	`, shelf.ID); err != nil {
+	`, sqliteTime(s.now()), shelf.ID); err != nil {
 		tx.Rollback()
 		return fmt.Errorf("touch bookshelf %q: %w", shelf.Name, err)
 	}
-
-	if err := tx.Commit(); err != nil {
-		return fmt.Errorf("commit bookshelf %q: %w", shelf.Name, err)
+	if err := s.commit(tx); err != nil {
+		return fmt.Errorf("commit bookshelf cleanup %q: %w", shelf.Name, err)
+	}
+	for _, id := range stale {
+		s.logRun(RunLogEntry{Action: RunActionRemoved, MangaID: id, Detail: "folder no longer in bookshelf"})
 	}
 	return nil
 }
 
-func clearBookshelfManga(ctx context.Context, tx *sql.Tx, bookshelfID string) error {
+// deleteMangaRecord removes a manga and its rows, leaving a tombstone for each
+// chapter so clients syncing chapter deltas learn about the removal.
+// Chapters that are inserted again clear their tombstone in insertChapter.
+func deleteMangaRecord(ctx context.Context, tx *sql.Tx, mangaID string, now time.Time) error {
 	if _, err := tx.ExecContext(ctx, `
-		DELETE FROM page
-		WHERE chapter_id IN (
-			SELECT c.id
-			FROM chapter c
-			JOIN manga m ON m.id = c.manga_id
-			WHERE m.bookshelf_id = ?
-		)
-	`, bookshelfID); err != nil {
-		return fmt.Errorf("clear bookshelf pages: %w", err)
+		DELETE FROM chapter_tombstone
+		WHERE removed_at < ?
+	`, sqliteTime(now.AddDate(0, 0, -ChapterTombstoneRetentionDays))); err != nil {
+		return fmt.Errorf("prune chapter tombstones: %w", err)
 	}
-
 	if _, err := tx.ExecContext(ctx, `
-		DELETE FROM chapter
-		WHERE manga_id IN (
-			SELECT id
-			FROM manga
-			WHERE bookshelf_id = ?
-		)
-	`, bookshelfID); err != nil {
-		return fmt.Errorf("clear bookshelf chapters: %w", err)
+		INSERT OR REPLACE INTO chapter_tombstone(chapter_id, manga_id, removed_at)
+		SELECT id, manga_id, ? FROM chapter WHERE manga_id = ?
+	`, sqliteTime(now), mangaID); err != nil {
+		return fmt.Errorf("delete existing manga: %w", err)
 	}
+	for _, query := range []string{
+		`DELETE FROM page WHERE chapter_id IN (SELECT id FROM chapter WHERE manga_id = ?)`,
+		`DELETE FROM chapter WHERE manga_id = ?`,
+		`DELETE FROM manga_tag WHERE manga_id = ?`,
+		`DELETE FROM manga WHERE id = ?`,
+	} {
+		if _, err := tx.ExecContext(ctx, query, mangaID); err != nil {
+			return fmt.Errorf("delete existing manga: %w", err)
+		}
+	}
+	return nil
+}
 
-	if _, err := tx.ExecContext(ctx, `
-		DELETE FROM manga_tag
-		WHERE manga_id IN (
+func (s *Service) beginScanCycle(ctx context.Context, resume bool) (string, map[string]struct{}, error) {
+	if resume {
+		var cycleID string
+		err := s.db.QueryRowContext(ctx, `
 			SELECT id
-			FROM manga
-			WHERE bookshelf_id = ?
-		)
-	`, bookshelfID); err != nil {
-		return fmt.Errorf("clear bookshelf tags: %w", err)
+			FROM scan_cycle
+			WHERE finished_at IS NULL
+			ORDER BY started_at DESC, id DESC
+			LIMIT 1
+		`).Scan(&cycleID)
+		if err == nil {
+			completed, err := s.loadScanCheckpoints(ctx, cycleID)
+			if err != nil {
+				return "", nil, err
+			}
+			return cycleID, completed, nil
+		}
+		if err != sql.ErrNoRows {
+			return "", nil, fmt.Errorf("load pending scan cycle: %w", err)
+		}
+	}
+
+	cycleID := makeID("scan", s.now().UTC().Format(time.RFC3339Nano))
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return "", nil, fmt.Errorf("begin scan cycle transaction: %w", err)
+	}
+	for _, query := range []string{
+		`DELETE FROM scan_checkpoint`,
+		`DELETE FROM scan_cycle`,
+	} {
+		if _, err := tx.ExecContext(ctx, query); err != nil {
+			tx.Rollback()
+			return "", nil, fmt.Errorf("clear previous scan cycles: %w", err)
+		}
+	}
+	if _, err := tx.ExecContext(ctx, `INSERT INTO scan_cycle(id) VALUES(?)`, cycleID); err != nil {
+		tx.Rollback()
+		return "", nil, fmt.Errorf("insert scan cycle: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return "", nil, fmt.Errorf("commit scan cycle: %w", err)
+	}
+	return cycleID, map[string]struct{}{}, nil
+}
+
+func (s *Service) loadScanCheckpoints(ctx context.Context, cycleID string) (map[string]struct{}, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT manga_id FROM scan_checkpoint WHERE cycle_id = ?`, cycleID)
+	if err != nil {
+		return nil, fmt.Errorf("load scan checkpoints: %w", err)
 	}
+	defer rows.Close()
 
-	if _, err := tx.ExecContext(ctx, `DELETE FROM manga WHERE bookshelf_id = ?`, bookshelfID); err != nil {
-		return fmt.Errorf("clear bookshelf manga: %w", err)
+	completed := make(map[string]struct{})
+	for rows.Next() {
+		var mangaID string
+		if err := rows.Scan(&mangaID); err != nil {
+			return nil, fmt.Errorf("scan checkpoint: %w", err)
+		}
+		completed[mangaID] = struct{}{}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate scan checkpoints: %w", err)
+	}
+	return completed, nil
+}
+
+func (s *Service) finishScanCycle(ctx context.Context, cycleID string) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin scan cycle transaction: %w", err)
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM scan_checkpoint WHERE cycle_id = ?`, cycleID); err != nil {
+		tx.Rollback()
+		return fmt.Errorf("clear scan checkpoints: %w", err)
+	}
+	if _, err := tx.ExecContext(ctx, `UPDATE scan_cycle SET finished_at = ? WHERE id = ?`, sqliteTime(s.now()), cycleID); err != nil {
+		tx.Rollback()
+		return fmt.Errorf("finish scan cycle: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("commit scan cycle: %w", err)
 	}
 	return nil
 }
@@ -1443,32 +2631,217 @@ func loadMangaTagIDs(ctx context.Context, tx *sql.Tx, mangaID string) ([]string,
 	return tagIDs, nil
 }
 
-func loadBookshelfMangaTagIDs(ctx context.Context, tx *sql.Tx, bookshelfID string) (map[string][]string, error) {
+// mangaState holds user-set manga values that must survive a rescan.
+type mangaState struct {
+	Exists            bool
+	Favorite          bool
+	SortName          string
+	SortNameLocked    bool
+	Collection        string
+	ReadingMode       string
+	ReadingModeLocked bool
+	ContentUpdatedAt  sql.NullTime
+}
+
+func loadMangaState(ctx context.Context, tx *sql.Tx, mangaID string) (mangaState, error) {
+	var state mangaState
+	var favorite int
+	var sortNameLocked int
+	var readingModeLocked int
+	err := tx.QueryRowContext(ctx, `
+		SELECT favorite, sort_name, sort_name_locked, collection, reading_mode, reading_mode_locked, content_updated_at
+		FROM manga
+		WHERE id = ?
+	`, mangaID).Scan(&favorite, &state.SortName, &sortNameLocked, &state.Collection, &state.ReadingMode, &readingModeLocked, &state.ContentUpdatedAt)
+	if err == sql.ErrNoRows {
+		return state, nil
+	}
+	if err != nil {
+		return state, fmt.Errorf("load manga state: %w", err)
+	}
+	state.Exists = true
+	state.Favorite = favorite > 0
+	state.SortNameLocked = sortNameLocked > 0
+	state.ReadingModeLocked = readingModeLocked > 0
+	return state, nil
+}
+
+// chapterState holds chapter values that must survive a rescan: user edits
+// and the time the chapter was first indexed.
+type chapterState struct {
+	Volume          *int
+	VolumeLocked    bool
+	FirstSeenAt     time.Time
+	PageOrderLocked bool
+	PageOrder       map[string]int
+}
+
+func loadChapterStates(ctx context.Context, tx *sql.Tx, mangaID string) (map[string]chapterState, error) {
 	rows, err := tx.QueryContext(ctx, `
-		SELECT mt.manga_id, mt.tag_id
-		FROM manga_tag mt
-		JOIN manga m ON m.id = mt.manga_id
-		WHERE m.bookshelf_id = ?
-		ORDER BY mt.manga_id ASC, mt.tag_id ASC
-	`, bookshelfID)
+		SELECT id, volume, volume_locked, first_seen_at, page_order_locked
+		FROM chapter
+		WHERE manga_id = ?
+	`, mangaID)
 	if err != nil {
-		return nil, fmt.Errorf("load bookshelf manga tags: %w", err)
+		return nil, fmt.Errorf("load chapter states: %w", err)
 	}
 	defer rows.Close()
 
-	items := make(map[string][]string)
+	states := make(map[string]chapterState)
 	for rows.Next() {
-		var mangaID string
-		var tagID string
-		if err := rows.Scan(&mangaID, &tagID); err != nil {
-			return nil, fmt.Errorf("scan bookshelf manga tag: %w", err)
+		var id string
+		var state chapterState
+		var locked int
+		var orderLocked int
+		var firstSeenAt sql.NullTime
+		if err := rows.Scan(&id, &state.Volume, &locked, &firstSeenAt, &orderLocked); err != nil {
+			return nil, fmt.Errorf("scan chapter state: %w", err)
 		}
-		items[mangaID] = append(items[mangaID], tagID)
+		state.VolumeLocked = locked > 0
+		state.PageOrderLocked = orderLocked > 0
+		if firstSeenAt.Valid {
+			state.FirstSeenAt = firstSeenAt.Time
+		}
+		states[id] = state
 	}
 	if err := rows.Err(); err != nil {
-		return nil, fmt.Errorf("iterate bookshelf manga tags: %w", err)
+		return nil, fmt.Errorf("iterate chapter states: %w", err)
 	}
-	return items, nil
+	rows.Close()
+
+	if err := loadPageOrders(ctx, tx, mangaID, states); err != nil {
+		return nil, err
+	}
+	return states, nil
+}
+
+// loadPageOrders records the pinned page positions of chapters whose page
+// order was set by hand.
+func loadPageOrders(ctx context.Context, tx *sql.Tx, mangaID string, states map[string]chapterState) error {
+	rows, err := tx.QueryContext(ctx, `
+		SELECT p.chapter_id, p.id, p.page_index
+		FROM page p
+		JOIN chapter c ON c.id = p.chapter_id
+		WHERE c.manga_id = ? AND c.page_order_locked = 1
+	`, mangaID)
+	if err != nil {
+		return fmt.Errorf("load page orders: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var chapterID, pageID string
+		var index int
+		if err := rows.Scan(&chapterID, &pageID, &index); err != nil {
+			return fmt.Errorf("scan page order: %w", err)
+		}
+		state := states[chapterID]
+		if state.PageOrder == nil {
+			state.PageOrder = make(map[string]int)
+		}
+		state.PageOrder[pageID] = index
+		states[chapterID] = state
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("iterate page orders: %w", err)
+	}
+	return nil
+}
+
+// applyChapterStates carries stored chapter state over to a fresh scan
+// record; chapters seen for the first time are stamped with now.
+// sameChapterSet reports whether a rescan found exactly the chapters that
+// were stored before it.
+func sameChapterSet(record mangaRecord, states map[string]chapterState) bool {
+	if len(record.Chapters) != len(states) {
+		return false
+	}
+	for _, chapter := range record.Chapters {
+		if _, ok := states[chapter.ID]; !ok {
+			return false
+		}
+	}
+	return true
+}
+
+func applyChapterStates(record *mangaRecord, states map[string]chapterState, now time.Time) {
+	for i := range record.Chapters {
+		state, ok := states[record.Chapters[i].ID]
+		if !ok {
+			record.Chapters[i].FirstSeenAt = now
+			continue
+		}
+		if state.VolumeLocked {
+			record.Chapters[i].Volume = state.Volume
+			record.Chapters[i].VolumeLocked = true
+		}
+		record.Chapters[i].FirstSeenAt = state.FirstSeenAt
+		if state.PageOrderLocked {
+			applyPageOrder(&record.Chapters[i], state.PageOrder)
+		}
+	}
+}
+
+// applyPageOrder sorts pages by their pinned positions. Pages added since the
+// order was pinned keep their scanned order after the pinned ones.
+func applyPageOrder(chapter *chapterRecord, order map[string]int) {
+	chapter.PageOrderLocked = true
+	sort.SliceStable(chapter.Pages, func(i, j int) bool {
+		left, leftPinned := order[chapter.Pages[i].ID]
+		right, rightPinned := order[chapter.Pages[j].ID]
+		if leftPinned != rightPinned {
+			return leftPinned
+		}
+		return leftPinned && left < right
+	})
+	for index := range chapter.Pages {
+		chapter.Pages[index].Index = index
+	}
+}
+
+// collectionMembership is a manga's place in one of the user's collections,
+// kept across the delete and insert of a rescan.
+type collectionMembership struct {
+	CollectionID string
+	Position     int
+	AddedAt      string
+}
+
+func loadCollectionMemberships(ctx context.Context, tx *sql.Tx, mangaID string) ([]collectionMembership, error) {
+	rows, err := tx.QueryContext(ctx, `
+		SELECT collection_id, position, added_at
+		FROM collection_item
+		WHERE manga_id = ?
+	`, mangaID)
+	if err != nil {
+		return nil, fmt.Errorf("load collection memberships: %w", err)
+	}
+	defer rows.Close()
+
+	memberships := make([]collectionMembership, 0)
+	for rows.Next() {
+		var membership collectionMembership
+		if err := rows.Scan(&membership.CollectionID, &membership.Position, &membership.AddedAt); err != nil {
+			return nil, fmt.Errorf("scan collection membership: %w", err)
+		}
+		memberships = append(memberships, membership)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate collection memberships: %w", err)
+	}
+	return memberships, nil
+}
+
+func restoreCollectionMemberships(ctx context.Context, tx *sql.Tx, mangaID string, memberships []collectionMembership) error {
+	for _, membership := range memberships {
+		if _, err := tx.ExecContext(ctx, `
+			INSERT OR IGNORE INTO collection_item(collection_id, manga_id, position, added_at)
+			VALUES(?, ?, ?, ?)
+		`, membership.CollectionID, mangaID, membership.Position, membership.AddedAt); err != nil {
+			return fmt.Errorf("restore collection membership: %w", err)
+		}
+	}
+	return nil
 }
 
 func restoreMangaTags(ctx context.Context, tx *sql.Tx, mangaID string, tagIDs []string) error {
@@ -1488,8 +2861,12 @@ func restoreMangaTags(ctx context.Context, tx *sql.Tx, mangaID string, tagIDs []
 
 func insertManga(ctx context.Context, tx *sql.Tx, record mangaRecord) error {
 	if _, err := tx.ExecContext(ctx, `
-		INSERT INTO manga(id, bookshelf_id, title, title_sort, path, cover_path, page_count, created_at, updated_at, last_scan_at)
-		VALUES(?, ?, ?, ?, ?, ?, ?, CURRENT_TIMESTAMP, ?, CURRENT_TIMESTAMP)
+		INSERT INTO manga(
+			id, bookshelf_id, title, title_sort, path, cover_path, cover_blurhash, page_count, favorite,
+			sort_name, sort_name_locked, collection, reading_direction, reading_mode, reading_mode_locked,
+			folder_name, created_at, updated_at, content_updated_at, last_scan_at, source_fingerprint
+		)
+		VALUES(?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, CURRENT_TIMESTAMP, ?, ?, CURRENT_TIMESTAMP, ?)
 	`,
 		record.ID,
 		record.BookshelfID,
@@ -1497,8 +2874,19 @@ func insertManga(ctx context.Context, tx *sql.Tx, record mangaRecord) error {
 		record.TitleSort,
 		record.Path,
 		record.CoverPath,
+		nullableString(record.CoverBlurhash),
 		record.PageCount,
+		boolToInt(record.Favorite),
+		record.SortName,
+		boolToInt(record.SortNameLocked),
+		record.Collection,
+		record.ReadingDirection,
+		record.ReadingMode,
+		boolToInt(record.ReadingModeLocked),
+		record.FolderName,
 		sqliteTime(record.UpdatedAt),
+		sqliteTime(record.ContentUpdatedAt),
+		nullableString(record.SourceFingerprint),
 	); err != nil {
 		return fmt.Errorf("insert manga %q: %w", record.Title, err)
 	}
@@ -1534,40 +2922,83 @@ func upsertBookshelf(ctx context.Context, tx *sql.Tx, record bookshelfRecord) er
 
 func insertChapter(ctx context.Context, tx *sql.Tx, record chapterRecord) error {
 	if _, err := tx.ExecContext(ctx, `
-		INSERT INTO chapter(id, manga_id, title, chapter_number, path, page_count, created_at, updated_at)
-		VALUES(?, ?, ?, ?, ?, ?, CURRENT_TIMESTAMP, ?)
+		INSERT INTO chapter(id, manga_id, title, chapter_number, volume, volume_locked, page_order_locked, path, page_count, excluded_page_count, possibly_incomplete, created_at, updated_at, first_seen_at)
+		VALUES(?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, CURRENT_TIMESTAMP, ?, ?)
 	`,
 		record.ID,
 		record.MangaID,
 		record.Title,
 		record.Number,
+		record.Volume,
+		boolToInt(record.VolumeLocked),
+		boolToInt(record.PageOrderLocked),
 		record.Path,
 		record.PageCount,
+		record.ExcludedPages,
+		boolToInt(record.PossiblyIncomplete),
 		sqliteTime(record.UpdatedAt),
+		sqliteTime(record.FirstSeenAt),
 	); err != nil {
 		return fmt.Errorf("insert chapter %q: %w", record.Title, err)
 	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM chapter_tombstone WHERE chapter_id = ?`, record.ID); err != nil {
+		return fmt.Errorf("clear chapter tombstone %q: %w", record.Title, err)
+	}
 
-	for _, page := range record.Pages {
-		if _, err := tx.ExecContext(ctx, `
-			INSERT INTO page(id, chapter_id, page_index, path, width, height, mime, size_bytes, created_at)
-			VALUES(?, ?, ?, ?, ?, ?, ?, ?, CURRENT_TIMESTAMP)
-		`,
-			page.ID,
-			page.ChapterID,
-			page.Index,
-			page.Path,
-			page.Width,
-			page.Height,
-			page.Mime,
-			page.SizeBytes,
-		); err != nil {
-			return fmt.Errorf("insert page %q: %w", page.Path, err)
+	return insertPages(ctx, tx, record.Pages)
+}
+
+// insertPages writes pages with multi-row INSERT statements so a large
+// chapter costs a handful of round trips instead of one per page. Batches are
+// sized to stay below SQLite's historical 999 bound-variable limit.
+func insertPages(ctx context.Context, tx *sql.Tx, pages []pageRecord) error {
+	for start := 0; start < len(pages); start += pageInsertBatchSize {
+		batch := pages[start:min(start+pageInsertBatchSize, len(pages))]
+
+		var query strings.Builder
+		query.WriteString("INSERT INTO page(id, chapter_id, page_index, path, width, height, mime, size_bytes, orientation, is_animated, checksum, created_at) VALUES")
+		args := make([]any, 0, len(batch)*pageInsertColumns)
+		for i, page := range batch {
+			if i > 0 {
+				query.WriteByte(',')
+			}
+			query.WriteString("(?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, CURRENT_TIMESTAMP)")
+			args = append(args,
+				page.ID,
+				page.ChapterID,
+				page.Index,
+				page.Path,
+				page.Width,
+				page.Height,
+				page.Mime,
+				page.SizeBytes,
+				page.Orientation,
+				page.Animated,
+				nullableString(page.Checksum),
+			)
+		}
+
+		if _, err := tx.ExecContext(ctx, query.String(), args...); err != nil {
+			return fmt.Errorf("insert pages %q..%q: %w", batch[0].Path, batch[len(batch)-1].Path, err)
 		}
 	}
 	return nil
 }
 
+func nullableString(value string) any {
+	if value == "" {
+		return nil
+	}
+	return value
+}
+
+func boolToInt(value bool) int {
+	if value {
+		return 1
+	}
+	return 0
+}
+
 func sqliteTime(value time.Time) string {
 	if value.IsZero() {
 		value = time.Now()
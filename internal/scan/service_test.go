@@ -0,0 +1,414 @@
+package scan
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"mynewmangaui/internal/media"
+)
+
+func TestScanIndexesLooseImagesAsOneChapter(t *testing.T) {
+	s, root := newTestService(t, Options{})
+	oneShot := filepath.Join(root, "One Shot")
+	for _, name := range []string{"10.png", "2.png", "1.png"} {
+		writePNG(t, filepath.Join(oneShot, name), 8, 12)
+	}
+	// Next to chapter folders, images at the manga root are only the
+	// cover and not a chapter of their own.
+	writeChapter(t, root, "Series", "Chapter 1", 2)
+	writePNG(t, filepath.Join(root, "Series", "cover.png"), 8, 12)
+
+	summary := mustScan(t, s)
+	if summary.MangaCount != 2 || summary.ChapterCount != 2 || summary.PageCount != 5 {
+		t.Fatalf("summary = %+v, want 2 manga, 2 chapters and 5 pages", summary)
+	}
+
+	var title, chapterPath string
+	if err := s.db.QueryRow(`
+		SELECT c.title, c.path
+		FROM chapter c
+		JOIN manga m ON m.id = c.manga_id
+		WHERE m.path = ?
+	`, oneShot).Scan(&title, &chapterPath); err != nil {
+		t.Fatalf("load one-shot chapter: %v", err)
+	}
+	if title != looseImagesChapterTitle || chapterPath != oneShot {
+		t.Errorf("one-shot chapter = %q at %q, want %q at the manga folder", title, chapterPath, looseImagesChapterTitle)
+	}
+
+	rows, err := s.db.Query(`
+		SELECT p.path
+		FROM page p
+		JOIN chapter c ON c.id = p.chapter_id
+		WHERE c.path = ?
+		ORDER BY p.page_index
+	`, oneShot)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	var pages []string
+	for rows.Next() {
+		var path string
+		if err := rows.Scan(&path); err != nil {
+			t.Fatal(err)
+		}
+		pages = append(pages, filepath.Base(path))
+	}
+	if want := []string{"1.png", "2.png", "10.png"}; !slices.Equal(pages, want) {
+		t.Errorf("one-shot pages = %v, want %v", pages, want)
+	}
+
+	var chapterTitle, cover string
+	if err := s.db.QueryRow(`
+		SELECT c.title, m.cover_path
+		FROM chapter c
+		JOIN manga m ON m.id = c.manga_id
+		WHERE m.path = ?
+	`, filepath.Join(root, "Series")).Scan(&chapterTitle, &cover); err != nil {
+		t.Fatalf("load series chapter: %v", err)
+	}
+	if chapterTitle != "Chapter 1" || filepath.Base(cover) != "cover.png" {
+		t.Errorf("series chapter %q with cover %q, want the folder chapter and cover.png", chapterTitle, cover)
+	}
+}
+
+// minimalPDF is a three page PDF page tree, which is all a scan reads.
+const minimalPDF = `%PDF-1.4
+1 0 obj << /Type /Catalog /Pages 2 0 R >> endobj
+2 0 obj << /Type /Pages /Kids [3 0 R 4 0 R 5 0 R] /Count 3 >> endobj
+3 0 obj << /Type /Page /Parent 2 0 R >> endobj
+4 0 obj << /Type /Page /Parent 2 0 R >> endobj
+5 0 obj << /Type /Page /Parent 2 0 R >> endobj
+trailer << /Root 1 0 R >>
+%%EOF
+`
+
+func TestScanIndexesPDFChapters(t *testing.T) {
+	for _, enabled := range []bool{false, true} {
+		t.Run(fmt.Sprintf("enabled=%v", enabled), func(t *testing.T) {
+			s, root := newTestService(t, Options{EnablePDF: enabled})
+			writeChapter(t, root, "Alpha", "Chapter 1", 2)
+			pdf := filepath.Join(root, "Alpha", "Chapter 2.pdf")
+			if err := os.WriteFile(pdf, []byte(minimalPDF), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			mustScan(t, s)
+
+			pages := countRows(t, s.db, `SELECT COUNT(*) FROM page p JOIN chapter c ON c.id = p.chapter_id WHERE c.path = ?`, pdf)
+			if !enabled {
+				if chapters := countRows(t, s.db, `SELECT COUNT(*) FROM chapter`); chapters != 1 || pages != 0 {
+					t.Fatalf("with pdf disabled: %d chapters, %d pdf pages; want 1 and 0", chapters, pages)
+				}
+				return
+			}
+			if pages != 3 {
+				t.Fatalf("pdf pages = %d, want 3", pages)
+			}
+			var path string
+			if err := s.db.QueryRow(`SELECT p.path FROM page p JOIN chapter c ON c.id = p.chapter_id WHERE c.path = ? AND p.page_index = 2`, pdf).Scan(&path); err != nil {
+				t.Fatal(err)
+			}
+			if path != media.PDFRef(pdf, 2) {
+				t.Errorf("last page ref = %q, want %q", path, media.PDFRef(pdf, 2))
+			}
+			if count := countRows(t, s.db, `SELECT page_count FROM chapter WHERE path = ?`, pdf); count != 3 {
+				t.Errorf("chapter page count = %d, want 3", count)
+			}
+		})
+	}
+}
+
+// testPages returns count page records of chapterID with varied columns,
+// including unset checksums.
+func testPages(chapterID string, count int) []pageRecord {
+	pages := make([]pageRecord, count)
+	for i := range pages {
+		pages[i] = pageRecord{
+			ID:          fmt.Sprintf("%s-page-%03d", chapterID, i),
+			ChapterID:   chapterID,
+			Index:       i,
+			Path:        fmt.Sprintf("/library/%s/%03d.png", chapterID, i),
+			Mime:        "image/png",
+			Width:       800 + i,
+			Height:      1200,
+			SizeBytes:   int64(1000 * i),
+			Orientation: 1 + i%8,
+			Animated:    i%7 == 0,
+		}
+		if i%2 == 0 {
+			pages[i].Checksum = fmt.Sprintf("sum-%d", i)
+		}
+	}
+	return pages
+}
+
+// testChapterID scans a one-chapter manga and returns the chapter's id.
+func testChapterID(t testing.TB, s *Service, root string) string {
+	t.Helper()
+	writeChapter(t, root, "Alpha", "Chapter 1", 1)
+	if _, err := s.Scan(context.Background()); err != nil {
+		t.Fatalf("scan: %v", err)
+	}
+	var chapterID string
+	if err := s.db.QueryRow(`SELECT id FROM chapter`).Scan(&chapterID); err != nil {
+		t.Fatal(err)
+	}
+	return chapterID
+}
+
+func TestInsertPagesMatchesRowByRowInserts(t *testing.T) {
+	s, root := newTestService(t, Options{})
+	chapterID := testChapterID(t, s, root)
+	// Enough pages for several batches and a partial last one.
+	pages := testPages(chapterID, 3*pageInsertBatchSize+7)
+
+	insert := func(write func(tx *sql.Tx) error) []string {
+		t.Helper()
+		if _, err := s.db.Exec(`DELETE FROM page WHERE chapter_id = ?`, chapterID); err != nil {
+			t.Fatal(err)
+		}
+		tx, err := s.db.Begin()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := write(tx); err != nil {
+			tx.Rollback()
+			t.Fatalf("insert: %v", err)
+		}
+		if err := tx.Commit(); err != nil {
+			t.Fatal(err)
+		}
+
+		rows, err := s.db.Query(`
+			SELECT id, chapter_id, page_index, path, width, height, mime, size_bytes, orientation, is_animated, checksum
+			FROM page
+			WHERE chapter_id = ?
+			ORDER BY page_index
+		`, chapterID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer rows.Close()
+		var stored []string
+		for rows.Next() {
+			var page pageRecord
+			var checksum sql.NullString
+			if err := rows.Scan(&page.ID, &page.ChapterID, &page.Index, &page.Path, &page.Width, &page.Height, &page.Mime, &page.SizeBytes, &page.Orientation, &page.Animated, &checksum); err != nil {
+				t.Fatal(err)
+			}
+			stored = append(stored, fmt.Sprintf("%+v %v", page, checksum))
+		}
+		if err := rows.Err(); err != nil {
+			t.Fatal(err)
+		}
+		return stored
+	}
+
+	batched := insert(func(tx *sql.Tx) error { return insertPages(context.Background(), tx, pages) })
+	rowByRow := insert(func(tx *sql.Tx) error {
+		for _, page := range pages {
+			if _, err := tx.Exec(`
+				INSERT INTO page(id, chapter_id, page_index, path, width, height, mime, size_bytes, orientation, is_animated, checksum, created_at)
+				VALUES(?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, CURRENT_TIMESTAMP)
+			`, page.ID, page.ChapterID, page.Index, page.Path, page.Width, page.Height, page.Mime, page.SizeBytes, page.Orientation, page.Animated, nullableString(page.Checksum)); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if len(batched) != len(pages) || !slices.Equal(batched, rowByRow) {
+		t.Fatalf("batched inserts stored %d rows, row by row %d; first rows %q and %q", len(batched), len(rowByRow), batched[:1], rowByRow[:1])
+	}
+}
+
+func BenchmarkInsertPages(b *testing.B) {
+	s, root := newTestService(b, Options{})
+	chapterID := testChapterID(b, s, root)
+	pages := testPages(chapterID, 300)
+
+	for b.Loop() {
+		tx, err := s.db.Begin()
+		if err != nil {
+			b.Fatal(err)
+		}
+		if _, err := tx.Exec(`DELETE FROM page WHERE chapter_id = ?`, chapterID); err != nil {
+			b.Fatal(err)
+		}
+		if err := insertPages(context.Background(), tx, pages); err != nil {
+			b.Fatal(err)
+		}
+		if err := tx.Commit(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// BenchmarkScanHugeRoot scans a flat root of 50k empty manga folders, the
+// case rootReadBatchSize keeps from listing the whole root at once.
+func BenchmarkScanHugeRoot(b *testing.B) {
+	s, root := newTestService(b, Options{})
+	for i := range 50000 {
+		if err := os.Mkdir(filepath.Join(root, fmt.Sprintf("Manga %05d", i)), 0o755); err != nil {
+			b.Fatal(err)
+		}
+	}
+	shelves, err := s.resolveBookshelves()
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	b.ReportAllocs()
+	for b.Loop() {
+		if _, err := s.scanBookshelfManga(context.Background(), shelves[0], "", nil, nil); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestPreserveFilenameNumbers(t *testing.T) {
+	pageIndexes := func(s *Service) map[string]int {
+		t.Helper()
+		rows, err := s.db.Query(`SELECT path, page_index FROM page`)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer rows.Close()
+		indexes := make(map[string]int)
+		for rows.Next() {
+			var path string
+			var index int
+			if err := rows.Scan(&path, &index); err != nil {
+				t.Fatal(err)
+			}
+			indexes[filepath.Base(path)] = index
+		}
+		if err := rows.Err(); err != nil {
+			t.Fatal(err)
+		}
+		return indexes
+	}
+
+	tests := []struct {
+		name     string
+		preserve bool
+		want     map[string]int
+	}{
+		{name: "sequential", want: map[string]int{"001.png": 0, "002.png": 1, "004.png": 2}},
+		{name: "preserved", preserve: true, want: map[string]int{"001.png": 0, "002.png": 1, "004.png": 3}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, root := newTestService(t, Options{PreserveFilenameNumbers: tt.preserve})
+			for _, name := range []string{"001.png", "002.png", "004.png"} {
+				writePNG(t, filepath.Join(root, "Alpha", "Chapter 1", name), 8, 12)
+			}
+			mustScan(t, s)
+			if got := pageIndexes(s); !maps.Equal(got, tt.want) {
+				t.Fatalf("page indexes = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNumberPages(t *testing.T) {
+	tests := []struct {
+		name  string
+		files []string
+		want  []int
+	}{
+		{name: "one based with a gap", files: []string{"p1.png", "p2.png", "p5.png"}, want: []int{0, 1, 4}},
+		{name: "zero based", files: []string{"000.png", "001.png", "003.png"}, want: []int{0, 1, 3}},
+		{name: "collision", files: []string{"01.png", "1.png", "2.png"}, want: []int{0, 1, 2}},
+		{name: "unnumbered page", files: []string{"1.png", "3.png", "extra.png"}, want: []int{0, 2, 3}},
+		{name: "number before the previous", files: []string{"5.png", "2.png"}, want: []int{4, 5}},
+		{name: "implausible gap", files: []string{"1.png", "20240101.png"}, want: []int{0, 1}},
+	}
+	s := NewService(nil, nil, Options{PreserveFilenameNumbers: true}, testLogger())
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pages := make([]pageRecord, len(tt.files))
+			for i, file := range tt.files {
+				pages[i] = pageRecord{Index: i, Path: filepath.Join("/library", "Alpha", "Chapter 1", file)}
+			}
+			s.numberPages(pages)
+			got := make([]int, len(pages))
+			for i, page := range pages {
+				got[i] = page.Index
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Fatalf("indexes = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReadingDirectionPrecedence(t *testing.T) {
+	comicInfo := func(manga string) string {
+		return "<ComicInfo><Series>Alpha</Series><Manga>" + manga + "</Manga></ComicInfo>"
+	}
+	tests := []struct {
+		name      string
+		strips    bool
+		comicInfo string
+		metadata  string
+		want      string
+	}{
+		{name: "default", want: media.ReadingLeftToRight},
+		{name: "heuristic", strips: true, want: media.ReadingVertical},
+		{name: "comicinfo over heuristic", strips: true, comicInfo: comicInfo("YesAndRightToLeft"), want: media.ReadingRightToLeft},
+		{name: "comicinfo yes", comicInfo: comicInfo("Yes"), want: media.ReadingRightToLeft},
+		{name: "comicinfo no", strips: true, comicInfo: comicInfo("No"), want: media.ReadingLeftToRight},
+		{name: "comicinfo unknown", strips: true, comicInfo: comicInfo("Unknown"), want: media.ReadingVertical},
+		{name: "override over comicinfo", comicInfo: comicInfo("Yes"), metadata: `{"readingDirection":"vertical"}`, want: media.ReadingVertical},
+		{name: "invalid override", comicInfo: comicInfo("Yes"), metadata: `{"readingDirection":"sideways"}`, want: media.ReadingRightToLeft},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, root := newTestService(t, Options{})
+			chapter := filepath.Join(root, "Alpha", "Chapter 1")
+			width, height := 8, 12
+			if tt.strips {
+				width, height = 8, 40
+			}
+			for _, name := range []string{"a.png", "b.png"} {
+				writePNG(t, filepath.Join(chapter, name), width, height)
+			}
+			if tt.comicInfo != "" {
+				if err := os.WriteFile(filepath.Join(chapter, media.ComicInfoFileName), []byte(tt.comicInfo), 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if tt.metadata != "" {
+				if err := os.WriteFile(filepath.Join(root, "Alpha", "metadata.json"), []byte(tt.metadata), 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			mustScan(t, s)
+
+			var direction string
+			if err := s.db.QueryRow(`SELECT reading_direction FROM manga`).Scan(&direction); err != nil {
+				t.Fatal(err)
+			}
+			if direction != tt.want {
+				t.Fatalf("reading direction = %q, want %q", direction, tt.want)
+			}
+		})
+	}
+
+	t.Run("comicinfo in archive", func(t *testing.T) {
+		s, root := newTestService(t, Options{})
+		writeCBZ(t, filepath.Join(root, "Alpha.cbz"), map[string]string{"01.png": "", media.ComicInfoFileName: comicInfo("Yes")})
+		mustScan(t, s)
+		var direction string
+		if err := s.db.QueryRow(`SELECT reading_direction FROM manga`).Scan(&direction); err != nil || direction != media.ReadingRightToLeft {
+			t.Fatalf("reading direction = %q, %v; want rtl", direction, err)
+		}
+	})
+}
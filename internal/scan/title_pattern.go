@@ -0,0 +1,76 @@
+package scan
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+var titleTemplateField = regexp.MustCompile(`\{(\w+)\}`)
+
+// CompileTitlePattern compiles a bookshelf title pattern. A pattern holding
+// "{title}" is a template: "{author} - {title} ({year})" matches the whole
+// folder name with each {field} standing for any text. Anything else is a
+// regular expression with a named "title" group.
+func CompileTitlePattern(pattern string) (*regexp.Regexp, error) {
+	if strings.Contains(pattern, "{title}") {
+		pattern = titleTemplateRegexp(pattern)
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, err
+	}
+	if re.SubexpIndex("title") < 0 {
+		return nil, fmt.Errorf("title pattern %q has no title group", pattern)
+	}
+	return re, nil
+}
+
+func titleTemplateRegexp(template string) string {
+	var b strings.Builder
+	b.WriteString("^")
+	last := 0
+	for _, match := range titleTemplateField.FindAllStringSubmatchIndex(template, -1) {
+		b.WriteString(regexp.QuoteMeta(template[last:match[0]]))
+		fmt.Fprintf(&b, "(?P<%s>.+?)", template[match[2]:match[3]])
+		last = match[1]
+	}
+	b.WriteString(regexp.QuoteMeta(template[last:]))
+	b.WriteString("$")
+	return b.String()
+}
+
+// compileTitlePatterns keys the title patterns of the configured bookshelves
+// by bookshelf id. Patterns that do not compile were already reported when
+// the configuration was loaded and are left out.
+func compileTitlePatterns(bookshelves []Bookshelf) map[string]*regexp.Regexp {
+	patterns := make(map[string]*regexp.Regexp)
+	for _, shelf := range bookshelves {
+		if strings.TrimSpace(shelf.TitlePattern) == "" {
+			continue
+		}
+		abs, err := filepath.Abs(strings.TrimSpace(shelf.Path))
+		if err != nil {
+			continue
+		}
+		if re, err := CompileTitlePattern(shelf.TitlePattern); err == nil {
+			patterns[makeID("bs", abs)] = re
+		}
+	}
+	return patterns
+}
+
+// mangaTitle derives a manga title from its folder or archive name using
+// the bookshelf's title pattern, keeping the whole name when there is no
+// pattern, it does not match or the title it captures is empty.
+func (s *Service) mangaTitle(bookshelfID string, name string) string {
+	if re := s.titlePatterns[bookshelfID]; re != nil {
+		if match := re.FindStringSubmatch(name); match != nil {
+			if title := cleanDisplayTitle(match[re.SubexpIndex("title")]); title != "" {
+				return title
+			}
+		}
+	}
+	return cleanDisplayTitle(name)
+}
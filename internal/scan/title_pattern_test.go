@@ -0,0 +1,100 @@
+package scan
+
+import (
+	"context"
+	"maps"
+	"path/filepath"
+	"testing"
+)
+
+func TestTitlePatterns(t *testing.T) {
+	tests := []struct {
+		name    string
+		pattern string
+		folders []string
+		want    map[string]string
+	}{
+		{
+			name:    "template",
+			pattern: "{author} - {title} ({year})",
+			folders: []string{"Oda - One Piece (1997)", "Loose Folder"},
+			want:    map[string]string{"Oda - One Piece (1997)": "One Piece", "Loose Folder": "Loose Folder"},
+		},
+		{
+			name:    "regexp",
+			pattern: `^\[(?P<group>[^\]]+)\] (?P<title>.+)$`,
+			folders: []string{"[Scans] Berserk", "Berserk Deluxe"},
+			want:    map[string]string{"[Scans] Berserk": "Berserk", "Berserk Deluxe": "Berserk Deluxe"},
+		},
+		{
+			name:    "empty capture",
+			pattern: `^(?P<title>x*)Alpha$`,
+			folders: []string{"Alpha"},
+			want:    map[string]string{"Alpha": "Alpha"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, root := newTestService(t, Options{})
+			s = NewService(s.db, []Bookshelf{{Name: "main", Path: root, TitlePattern: tt.pattern}}, Options{}, testLogger())
+			for _, folder := range tt.folders {
+				writeChapter(t, root, folder, "Chapter 1", 1)
+			}
+			mustScan(t, s)
+
+			rows, err := s.db.QueryContext(context.Background(), `SELECT folder_name, title FROM manga`)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer rows.Close()
+			got := make(map[string]string)
+			for rows.Next() {
+				var folder, title string
+				if err := rows.Scan(&folder, &title); err != nil {
+					t.Fatal(err)
+				}
+				got[folder] = title
+			}
+			if err := rows.Err(); err != nil {
+				t.Fatal(err)
+			}
+			if !maps.Equal(got, tt.want) {
+				t.Fatalf("titles by folder = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTitlePatternAppliesToArchiveNames(t *testing.T) {
+	s, root := newTestService(t, Options{})
+	s = NewService(s.db, []Bookshelf{{Name: "main", Path: root, TitlePattern: "{author} - {title}"}}, Options{}, testLogger())
+	writeCBZ(t, filepath.Join(root, "Oda - Wanted.cbz"), map[string]string{"01.png": ""})
+	mustScan(t, s)
+
+	var folder, title string
+	if err := s.db.QueryRow(`SELECT folder_name, title FROM manga`).Scan(&folder, &title); err != nil {
+		t.Fatal(err)
+	}
+	if title != "Wanted" || folder != "Oda - Wanted.cbz" {
+		t.Fatalf("manga = %q from %q, want Wanted from the archive name", title, folder)
+	}
+}
+
+func TestCompileTitlePattern(t *testing.T) {
+	tests := []struct {
+		pattern string
+		wantErr bool
+	}{
+		{pattern: "{title}"},
+		{pattern: "{author} - {title} ({year})"},
+		{pattern: `^(?P<title>.+) v\d+$`},
+		{pattern: `^(.+) v\d+$`, wantErr: true},
+		{pattern: "{author} - {name}", wantErr: true},
+		{pattern: `^(?P<title>.+`, wantErr: true},
+	}
+	for _, tt := range tests {
+		if _, err := CompileTitlePattern(tt.pattern); (err != nil) != tt.wantErr {
+			t.Errorf("CompileTitlePattern(%q) error = %v, want error %v", tt.pattern, err, tt.wantErr)
+		}
+	}
+}
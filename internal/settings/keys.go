@@ -0,0 +1,45 @@
+package settings
+
+import (
+	"fmt"
+
+	"mynewmangaui/internal/media"
+)
+
+// maxScanIntervalMinutes is the longest periodic scan interval, a week.
+const maxScanIntervalMinutes = 7 * 24 * 60
+
+// Maintenance is set while the server is in maintenance mode. It is
+// changed through the admin API rather than as a Public setting.
+var Maintenance = Key[bool]{Name: "maintenance"}
+
+// DefaultReadingMode is the mode the reader opens manga in that have no
+// mode of their own; empty leaves it to each manga's reading direction.
+var DefaultReadingMode = Key[string]{
+	Name: "defaultReadingMode",
+	Validate: func(mode string) error {
+		if mode != "" && !media.ValidReadingMode(mode) {
+			return fmt.Errorf("must be empty, paged, continuous or double")
+		}
+		return nil
+	},
+}
+
+// ScanIntervalMinutes queues a library scan this often; zero turns periodic
+// scans off.
+var ScanIntervalMinutes = Key[int]{
+	Name: "scanIntervalMinutes",
+	Validate: func(minutes int) error {
+		if minutes < 0 || minutes > maxScanIntervalMinutes {
+			return fmt.Errorf("must be between 0 and %d", maxScanIntervalMinutes)
+		}
+		return nil
+	},
+}
+
+// Public lists the settings clients may read and change through the
+// settings API.
+var Public = []Setting{
+	DefaultReadingMode,
+	ScanIntervalMinutes,
+}
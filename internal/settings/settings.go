@@ -0,0 +1,225 @@
+// Package settings keeps small persisted settings, such as maintenance mode
+// and user preferences, as JSON values in the settings table. Each setting
+// is declared once as a typed Key with its default and validation.
+package settings
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// errUnknownSetting is wrapped by a ValidationError for keys that are not
+// Public.
+var errUnknownSetting = errors.New("unknown setting")
+
+var errNoDatabase = errors.New("database not initialized")
+
+// ValidationError reports a value a setting cannot take, or a key clients
+// may not set.
+type ValidationError struct {
+	Key string
+	Err error
+}
+
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("setting %q: %v", e.Key, e.Err)
+}
+
+func (e *ValidationError) Unwrap() error {
+	return e.Err
+}
+
+// Store reads and writes the settings table.
+type Store struct {
+	db *sql.DB
+}
+
+func New(db *sql.DB) *Store {
+	return &Store{db: db}
+}
+
+// Key is a setting holding values of type T. A setting that was never set,
+// or was reset, reads as Default.
+type Key[T any] struct {
+	Name    string
+	Default T
+	// Validate rejects values the setting cannot take; nil accepts any
+	// value of type T.
+	Validate func(T) error
+}
+
+// Get returns the setting's stored value, or its default when unset.
+func (k Key[T]) Get(ctx context.Context, s *Store) (T, error) {
+	raw, ok, err := s.load(ctx, k.Name)
+	if err != nil || !ok {
+		return k.Default, err
+	}
+	var value T
+	if err := json.Unmarshal(raw, &value); err != nil {
+		return k.Default, fmt.Errorf("decode setting %q: %w", k.Name, err)
+	}
+	return value, nil
+}
+
+// Set validates and stores value.
+func (k Key[T]) Set(ctx context.Context, s *Store, value T) error {
+	if s == nil || s.db == nil {
+		return errNoDatabase
+	}
+	if k.Validate != nil {
+		if err := k.Validate(value); err != nil {
+			return &ValidationError{Key: k.Name, Err: err}
+		}
+	}
+	raw, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Errorf("encode setting %q: %w", k.Name, err)
+	}
+	return s.save(ctx, s.db, k.Name, raw)
+}
+
+func (k Key[T]) name() string {
+	return k.Name
+}
+
+func (k Key[T]) parse(raw json.RawMessage) (json.RawMessage, error) {
+	var value T
+	if err := json.Unmarshal(raw, &value); err != nil {
+		return nil, &ValidationError{Key: k.Name, Err: fmt.Errorf("must be %s", jsonKind(value))}
+	}
+	if k.Validate != nil {
+		if err := k.Validate(value); err != nil {
+			return nil, &ValidationError{Key: k.Name, Err: err}
+		}
+	}
+	return json.Marshal(value)
+}
+
+func (k Key[T]) defaultJSON() (json.RawMessage, error) {
+	return json.Marshal(k.Default)
+}
+
+// jsonKind names the JSON type values like value are written as.
+func jsonKind(value any) string {
+	switch value.(type) {
+	case bool:
+		return "a boolean"
+	case string:
+		return "a string"
+	case int, int64, float64:
+		return "a number"
+	}
+	return fmt.Sprintf("a %T", value)
+}
+
+// Setting is a Key of any type, for handling settings by name.
+type Setting interface {
+	name() string
+	// parse decodes and validates a JSON value, returning it re-encoded.
+	parse(raw json.RawMessage) (json.RawMessage, error)
+	defaultJSON() (json.RawMessage, error)
+}
+
+// PublicValues returns every Public setting by name, defaults filled in.
+func (s *Store) PublicValues(ctx context.Context) (map[string]json.RawMessage, error) {
+	values := make(map[string]json.RawMessage, len(Public))
+	for _, setting := range Public {
+		raw, ok, err := s.load(ctx, setting.name())
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			if raw, err = setting.defaultJSON(); err != nil {
+				return nil, err
+			}
+		}
+		values[setting.name()] = raw
+	}
+	return values, nil
+}
+
+// UpdatePublic sets the given Public settings together, or none of them
+// when any key or value is rejected with a ValidationError. A null value
+// resets the setting to its default.
+func (s *Store) UpdatePublic(ctx context.Context, values map[string]json.RawMessage) error {
+	parsed := make(map[string]json.RawMessage, len(values))
+	for key, raw := range values {
+		setting, ok := lookupPublic(key)
+		if !ok {
+			return &ValidationError{Key: key, Err: errUnknownSetting}
+		}
+		if bytes.Equal(bytes.TrimSpace(raw), []byte("null")) {
+			parsed[key] = nil
+			continue
+		}
+		value, err := setting.parse(raw)
+		if err != nil {
+			return err
+		}
+		parsed[key] = value
+	}
+
+	if s == nil || s.db == nil {
+		return errNoDatabase
+	}
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin settings update: %w", err)
+	}
+	defer tx.Rollback()
+	for key, value := range parsed {
+		if value == nil {
+			if _, err := tx.ExecContext(ctx, `DELETE FROM settings WHERE key = ?`, key); err != nil {
+				return fmt.Errorf("reset setting %q: %w", key, err)
+			}
+			continue
+		}
+		if err := s.save(ctx, tx, key, value); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func lookupPublic(key string) (Setting, bool) {
+	for _, setting := range Public {
+		if setting.name() == key {
+			return setting, true
+		}
+	}
+	return nil, false
+}
+
+func (s *Store) load(ctx context.Context, key string) (json.RawMessage, bool, error) {
+	if s == nil || s.db == nil {
+		return nil, false, nil
+	}
+	var value string
+	err := s.db.QueryRowContext(ctx, `SELECT value FROM settings WHERE key = ?`, key).Scan(&value)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, fmt.Errorf("load setting %q: %w", key, err)
+	}
+	return json.RawMessage(value), true, nil
+}
+
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+}
+
+func (s *Store) save(ctx context.Context, q execer, key string, value json.RawMessage) error {
+	if _, err := q.ExecContext(ctx, `
+		INSERT INTO settings (key, value, updated_at)
+		VALUES (?, ?, CURRENT_TIMESTAMP)
+		ON CONFLICT(key) DO UPDATE SET value = excluded.value, updated_at = excluded.updated_at
+	`, key, string(value)); err != nil {
+		return fmt.Errorf("save setting %q: %w", key, err)
+	}
+	return nil
+}
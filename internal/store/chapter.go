@@ -0,0 +1,112 @@
+package store
+
+import (
+	"context"
+	"fmt"
+)
+
+type Chapter struct {
+	ID        string
+	MangaID   string
+	Title     string
+	Number    *float64
+	Volume    *int
+	PageCount int
+	UpdatedAt string
+	// PossiblyIncomplete marks chapters that were still being written when
+	// last scanned.
+	PossiblyIncomplete bool
+}
+
+// GetChapter returns the chapter with the given id, or sql.ErrNoRows.
+func (s *Store) GetChapter(ctx context.Context, id string) (Chapter, error) {
+	var chapter Chapter
+	err := s.db.QueryRowContext(ctx, `
+		SELECT id, manga_id, title, chapter_number, volume, page_count, updated_at, possibly_incomplete
+		FROM chapter
+		WHERE id = ?
+	`, id).Scan(
+		&chapter.ID,
+		&chapter.MangaID,
+		&chapter.Title,
+		&chapter.Number,
+		&chapter.Volume,
+		&chapter.PageCount,
+		&chapter.UpdatedAt,
+		&chapter.PossiblyIncomplete,
+	)
+	return chapter, err
+}
+
+// PageIndexProblem is a chapter whose page rows are not indexed 0 to
+// PageCount-1 exactly once each, as navigation by global page index
+// assumes; a rescan of its manga rebuilds them.
+type PageIndexProblem struct {
+	ChapterID    string
+	ChapterTitle string
+	MangaID      string
+	MangaTitle   string
+	// PageCount is the page count recorded for the chapter and Pages the
+	// number of page rows it has.
+	PageCount int
+	Pages     int
+	// FirstIndex and LastIndex are the lowest and highest page index, both
+	// -1 for a chapter without pages.
+	FirstIndex int
+	LastIndex  int
+	// Duplicates counts page rows sharing their index with another row.
+	// Missing counts the indexes from 0 to LastIndex that no page has.
+	Duplicates int
+	Missing    int
+}
+
+// PageIndexProblems finds every chapter whose page indexes have gaps or
+// duplicates, do not start at 0, or disagree with its page count.
+func (s *Store) PageIndexProblems(ctx context.Context) ([]PageIndexProblem, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT
+			c.id, c.title, c.manga_id, m.title, c.page_count,
+			COUNT(p.id), COUNT(DISTINCT p.page_index),
+			COALESCE(MIN(p.page_index), -1), COALESCE(MAX(p.page_index), -1)
+		FROM chapter c
+		JOIN manga m ON m.id = c.manga_id
+		LEFT JOIN page p ON p.chapter_id = c.id
+		GROUP BY c.id, c.title, c.manga_id, m.title, c.page_count
+		HAVING COUNT(p.id) <> c.page_count
+			OR COUNT(DISTINCT p.page_index) <> COUNT(p.id)
+			OR (COUNT(p.id) > 0 AND (MIN(p.page_index) <> 0 OR MAX(p.page_index) <> COUNT(p.id) - 1))
+		ORDER BY m.title ASC, c.manga_id ASC, c.chapter_number ASC, c.title ASC, c.id ASC
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("query page indexes: %w", err)
+	}
+	defer rows.Close()
+
+	problems := make([]PageIndexProblem, 0)
+	for rows.Next() {
+		var problem PageIndexProblem
+		var distinct int
+		if err := rows.Scan(
+			&problem.ChapterID,
+			&problem.ChapterTitle,
+			&problem.MangaID,
+			&problem.MangaTitle,
+			&problem.PageCount,
+			&problem.Pages,
+			&distinct,
+			&problem.FirstIndex,
+			&problem.LastIndex,
+		); err != nil {
+			return nil, fmt.Errorf("read page index row: %w", err)
+		}
+		problem.Duplicates = problem.Pages - distinct
+		if problem.Pages > 0 {
+			problem.Missing = max(problem.LastIndex+1-distinct, 0)
+		}
+		problems = append(problems, problem)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate page index rows: %w", err)
+	}
+	return problems, nil
+}
@@ -0,0 +1,101 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+)
+
+func TestGetChapter(t *testing.T) {
+	s, database := newTestStore(t)
+	exec(t, database,
+		`INSERT INTO manga(id, title, path) VALUES ('m1', 'Alpha', '/Alpha')`,
+		`INSERT INTO chapter(id, manga_id, title, chapter_number, volume, page_count, path) VALUES
+			('c1', 'm1', 'Chapter 1.5', 1.5, 2, 18, '/Alpha/1.5'),
+			('c2', 'm1', 'Extra', NULL, NULL, 4, '/Alpha/Extra')`,
+	)
+
+	chapter, err := s.GetChapter(context.Background(), "c1")
+	if err != nil {
+		t.Fatalf("GetChapter: %v", err)
+	}
+	if chapter.MangaID != "m1" || chapter.Title != "Chapter 1.5" || chapter.PageCount != 18 {
+		t.Errorf("chapter = %+v", chapter)
+	}
+	if chapter.Number == nil || *chapter.Number != 1.5 || chapter.Volume == nil || *chapter.Volume != 2 {
+		t.Errorf("number %v, volume %v; want 1.5 and 2", chapter.Number, chapter.Volume)
+	}
+
+	extra, err := s.GetChapter(context.Background(), "c2")
+	if err != nil {
+		t.Fatalf("GetChapter: %v", err)
+	}
+	if extra.Number != nil || extra.Volume != nil {
+		t.Errorf("unnumbered chapter has number %v, volume %v", extra.Number, extra.Volume)
+	}
+
+	if _, err := s.GetChapter(context.Background(), "missing"); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("missing chapter error = %v, want sql.ErrNoRows", err)
+	}
+}
+
+func TestPageIndexProblems(t *testing.T) {
+	s, database := newTestStore(t)
+	exec(t, database,
+		`INSERT INTO manga(id, title, path) VALUES ('m1', 'Alpha', '/Alpha')`,
+		`INSERT INTO chapter(id, manga_id, title, chapter_number, page_count, path) VALUES
+			('ok', 'm1', 'Intact', 1, 3, '/Alpha/1'),
+			('gap', 'm1', 'Gap', 2, 3, '/Alpha/2'),
+			('offset', 'm1', 'Offset', 3, 2, '/Alpha/3'),
+			('count', 'm1', 'Count', 4, 5, '/Alpha/4'),
+			('empty', 'm1', 'Empty', 5, 2, '/Alpha/5'),
+			('none', 'm1', 'No pages', 6, 0, '/Alpha/6')`,
+		`INSERT INTO page(id, chapter_id, page_index, path) VALUES
+			('ok0', 'ok', 0, '/Alpha/1/a'), ('ok1', 'ok', 1, '/Alpha/1/b'), ('ok2', 'ok', 2, '/Alpha/1/c'),
+			('gap0', 'gap', 0, '/Alpha/2/a'), ('gap2', 'gap', 2, '/Alpha/2/c'), ('gap4', 'gap', 4, '/Alpha/2/e'),
+			('offset1', 'offset', 1, '/Alpha/3/b'), ('offset2', 'offset', 2, '/Alpha/3/c'),
+			('count0', 'count', 0, '/Alpha/4/a'), ('count1', 'count', 1, '/Alpha/4/b')`,
+	)
+
+	problems, err := s.PageIndexProblems(context.Background())
+	if err != nil {
+		t.Fatalf("PageIndexProblems: %v", err)
+	}
+	byID := make(map[string]PageIndexProblem, len(problems))
+	for _, problem := range problems {
+		byID[problem.ChapterID] = problem
+	}
+
+	tests := []struct {
+		chapterID string
+		want      PageIndexProblem
+	}{
+		{"gap", PageIndexProblem{PageCount: 3, Pages: 3, FirstIndex: 0, LastIndex: 4, Missing: 2}},
+		{"offset", PageIndexProblem{PageCount: 2, Pages: 2, FirstIndex: 1, LastIndex: 2, Missing: 1}},
+		{"count", PageIndexProblem{PageCount: 5, Pages: 2, FirstIndex: 0, LastIndex: 1}},
+		{"empty", PageIndexProblem{PageCount: 2, Pages: 0, FirstIndex: -1, LastIndex: -1}},
+	}
+	for _, tt := range tests {
+		got, ok := byID[tt.chapterID]
+		if !ok {
+			t.Errorf("chapter %s not reported", tt.chapterID)
+			continue
+		}
+		if got.MangaID != "m1" || got.MangaTitle != "Alpha" {
+			t.Errorf("chapter %s manga = %s %q", tt.chapterID, got.MangaID, got.MangaTitle)
+		}
+		got.ChapterID, got.ChapterTitle, got.MangaID, got.MangaTitle = "", "", "", ""
+		if got != tt.want {
+			t.Errorf("chapter %s = %+v, want %+v", tt.chapterID, got, tt.want)
+		}
+	}
+	for _, healthy := range []string{"ok", "none"} {
+		if _, ok := byID[healthy]; ok {
+			t.Errorf("healthy chapter %s reported", healthy)
+		}
+	}
+	if len(problems) != len(tests) {
+		t.Errorf("reported %d chapters, want %d", len(problems), len(tests))
+	}
+}
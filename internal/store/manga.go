@@ -0,0 +1,475 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// Reading states a manga can be filtered by, derived from the progress
+// stored for its chapters.
+const (
+	ReadStateUnread    = "unread"
+	ReadStateReading   = "reading"
+	ReadStateCompleted = "completed"
+)
+
+// Manga orderings accepted by ListManga. Every ordering ends in the manga
+// id, so manga that tie on the sort keys, such as a bulk import sharing one
+// updated_at, keep the same order from page to page.
+const (
+	SortUpdated    = "updated"
+	SortName       = "name"
+	SortCollection = "collection"
+)
+
+var mangaOrderClauses = map[string]string{
+	SortUpdated:    "m.content_updated_at DESC, m.title ASC, m.id ASC",
+	SortName:       "m.sort_name COLLATE NOCASE ASC, m.id ASC",
+	SortCollection: "m.collection = '' ASC, m.collection COLLATE NOCASE ASC, m.sort_name COLLATE NOCASE ASC, m.id ASC",
+}
+
+type Manga struct {
+	ID            string
+	BookshelfID   string
+	BookshelfName string
+	Title         string
+	ChapterCount  int
+	PageCount     int
+	UpdatedAt     string
+	Path          string
+	Favorite      bool
+	SortName      string
+	Collection    string
+	// ReadingDirection is ltr, rtl or vertical.
+	ReadingDirection string
+	// FolderName is the name on disk the title was derived from.
+	FolderName string
+	// ReadingMode is paged, continuous or double.
+	ReadingMode string
+	// ContentUpdatedAt is when chapters were last added or removed, which
+	// SortUpdated orders by; UpdatedAt follows file modification times.
+	ContentUpdatedAt string
+	// CoverBlurhash is a BlurHash placeholder of the cover, empty until a
+	// scan computed one.
+	CoverBlurhash string
+}
+
+// MangaFilter narrows a manga listing. Every value is bound as a query
+// parameter; Query is matched literally, with LIKE wildcards escaped.
+type MangaFilter struct {
+	BookshelfID string
+	// TagIDs keeps manga carrying all of the listed tags.
+	TagIDs   []string
+	Query    string
+	Favorite bool
+	// States keeps manga in any of the listed reading states.
+	States     []string
+	Collection string
+}
+
+type ListMangaOptions struct {
+	Filter MangaFilter
+	// Sort is one of the Sort constants, SortUpdated when empty.
+	Sort   string
+	Limit  int
+	Offset int
+	// Fields narrows the columns read to the named Manga fields, such as
+	// "Title" or "ChapterCount"; ID is always read. Empty reads them all.
+	Fields []string
+}
+
+// ValidReadState reports whether state is one of the ReadState constants.
+func ValidReadState(state string) bool {
+	_, ok := readStateClause(state)
+	return ok
+}
+
+// ValidSort reports whether sort is one of the Sort constants.
+func ValidSort(sort string) bool {
+	_, ok := mangaOrderClauses[sort]
+	return ok
+}
+
+const mangaColumns = `
+	m.id,
+	m.bookshelf_id,
+	COALESCE(b.name, ''),
+	m.title,
+	COUNT(c.id) AS chapter_count,
+	m.page_count,
+	m.updated_at,
+	m.path,
+	m.favorite,
+	m.sort_name,
+	m.collection,
+	m.reading_direction,
+	m.folder_name,
+	m.reading_mode,
+	COALESCE(m.content_updated_at, m.updated_at),
+	COALESCE(m.cover_blurhash, '')
+`
+
+const mangaFrom = `
+	FROM manga m
+	LEFT JOIN bookshelf b ON b.id = m.bookshelf_id
+	LEFT JOIN chapter c ON c.manga_id = m.id
+`
+
+const mangaGroupBy = `
+	GROUP BY m.id, m.bookshelf_id, b.name, m.title, m.page_count, m.updated_at, m.path, m.favorite, m.sort_name, m.collection, m.reading_direction, m.folder_name, m.reading_mode, m.content_updated_at, m.cover_blurhash
+`
+
+// mangaFieldColumns maps Manga fields to the column each is read from, for
+// listings narrowed with ListMangaOptions.Fields.
+var mangaFieldColumns = map[string]struct {
+	column string
+	dest   func(*Manga) any
+}{
+	"BookshelfID":      {"m.bookshelf_id", func(m *Manga) any { return &m.BookshelfID }},
+	"BookshelfName":    {"COALESCE(b.name, '')", func(m *Manga) any { return &m.BookshelfName }},
+	"Title":            {"m.title", func(m *Manga) any { return &m.Title }},
+	"ChapterCount":     {"COUNT(c.id)", func(m *Manga) any { return &m.ChapterCount }},
+	"PageCount":        {"m.page_count", func(m *Manga) any { return &m.PageCount }},
+	"UpdatedAt":        {"m.updated_at", func(m *Manga) any { return &m.UpdatedAt }},
+	"Path":             {"m.path", func(m *Manga) any { return &m.Path }},
+	"Favorite":         {"m.favorite", func(m *Manga) any { return &m.Favorite }},
+	"SortName":         {"m.sort_name", func(m *Manga) any { return &m.SortName }},
+	"Collection":       {"m.collection", func(m *Manga) any { return &m.Collection }},
+	"ReadingDirection": {"m.reading_direction", func(m *Manga) any { return &m.ReadingDirection }},
+	"FolderName":       {"m.folder_name", func(m *Manga) any { return &m.FolderName }},
+	"ReadingMode":      {"m.reading_mode", func(m *Manga) any { return &m.ReadingMode }},
+	"ContentUpdatedAt": {"COALESCE(m.content_updated_at, m.updated_at)", func(m *Manga) any { return &m.ContentUpdatedAt }},
+	"CoverBlurhash":    {"COALESCE(m.cover_blurhash, '')", func(m *Manga) any { return &m.CoverBlurhash }},
+}
+
+// ValidMangaField reports whether name can be listed in
+// ListMangaOptions.Fields.
+func ValidMangaField(name string) bool {
+	_, ok := mangaFieldColumns[name]
+	return name == "ID" || ok
+}
+
+// mangaFieldsQuery builds the select list, joins and grouping for the named
+// fields, joining bookshelves and chapters only when a field needs them, and
+// returns the scan destinations for a row.
+func mangaFieldsQuery(fields []string) (string, string, func(*Manga) []any, error) {
+	columns := []string{"m.id"}
+	dests := []func(*Manga) any{func(m *Manga) any { return &m.ID }}
+	from := ` FROM manga m`
+	groupBy := ``
+	for _, name := range fields {
+		if name == "ID" {
+			continue
+		}
+		field, ok := mangaFieldColumns[name]
+		if !ok {
+			return "", "", nil, fmt.Errorf("unsupported manga field %q", name)
+		}
+		columns = append(columns, field.column)
+		dests = append(dests, field.dest)
+		switch name {
+		case "BookshelfName":
+			from += ` LEFT JOIN bookshelf b ON b.id = m.bookshelf_id`
+		case "ChapterCount":
+			from += ` LEFT JOIN chapter c ON c.manga_id = m.id`
+			groupBy = ` GROUP BY m.id`
+		}
+	}
+	return strings.Join(columns, ", ") + from, groupBy, func(m *Manga) []any {
+		targets := make([]any, len(dests))
+		for i, dest := range dests {
+			targets[i] = dest(m)
+		}
+		return targets
+	}, nil
+}
+
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+func scanManga(row scanner) (Manga, error) {
+	var manga Manga
+	err := row.Scan(
+		&manga.ID,
+		&manga.BookshelfID,
+		&manga.BookshelfName,
+		&manga.Title,
+		&manga.ChapterCount,
+		&manga.PageCount,
+		&manga.UpdatedAt,
+		&manga.Path,
+		&manga.Favorite,
+		&manga.SortName,
+		&manga.Collection,
+		&manga.ReadingDirection,
+		&manga.FolderName,
+		&manga.ReadingMode,
+		&manga.ContentUpdatedAt,
+		&manga.CoverBlurhash,
+	)
+	return manga, err
+}
+
+// ListManga returns one page of the manga matching the filter along with
+// the total number of matches.
+func (s *Store) ListManga(ctx context.Context, opts ListMangaOptions) ([]Manga, int, error) {
+	sort := opts.Sort
+	if sort == "" {
+		sort = SortUpdated
+	}
+	order, ok := mangaOrderClauses[sort]
+	if !ok {
+		return nil, 0, fmt.Errorf("unsupported manga sort %q", sort)
+	}
+
+	where, args := mangaFilterClause(opts.Filter)
+	total, err := s.count(ctx, `SELECT COUNT(*) FROM manga m WHERE `+where, args...)
+	if err != nil {
+		return nil, 0, fmt.Errorf("count manga: %w", err)
+	}
+
+	selectFrom, groupBy := mangaColumns+mangaFrom, mangaGroupBy
+	scan := scanManga
+	if len(opts.Fields) > 0 {
+		var targets func(*Manga) []any
+		selectFrom, groupBy, targets, err = mangaFieldsQuery(opts.Fields)
+		if err != nil {
+			return nil, 0, err
+		}
+		scan = func(row scanner) (Manga, error) {
+			var manga Manga
+			err := row.Scan(targets(&manga)...)
+			return manga, err
+		}
+	}
+
+	rows, err := s.db.QueryContext(ctx, `SELECT `+selectFrom+` WHERE `+where+groupBy+`
+		ORDER BY `+order+`
+		LIMIT ? OFFSET ?
+	`, append(args, opts.Limit, opts.Offset)...)
+	if err != nil {
+		return nil, 0, fmt.Errorf("query manga: %w", err)
+	}
+	defer rows.Close()
+
+	items := make([]Manga, 0, opts.Limit)
+	for rows.Next() {
+		manga, err := scan(rows)
+		if err != nil {
+			return nil, 0, fmt.Errorf("read manga row: %w", err)
+		}
+		items = append(items, manga)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, fmt.Errorf("iterate manga rows: %w", err)
+	}
+	return items, total, nil
+}
+
+// AllManga returns every manga in the library ordered by sort name.
+func (s *Store) AllManga(ctx context.Context) ([]Manga, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT `+mangaColumns+mangaFrom+mangaGroupBy+`
+		ORDER BY `+mangaOrderClauses[SortName])
+	if err != nil {
+		return nil, fmt.Errorf("query manga: %w", err)
+	}
+	defer rows.Close()
+
+	items := make([]Manga, 0)
+	for rows.Next() {
+		manga, err := scanManga(rows)
+		if err != nil {
+			return nil, fmt.Errorf("read manga row: %w", err)
+		}
+		items = append(items, manga)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate manga rows: %w", err)
+	}
+	return items, nil
+}
+
+// InitialCount is the number of manga whose sort name starts with Initial.
+type InitialCount struct {
+	Initial string
+	Count   int
+}
+
+// MangaInitials counts the manga matching the filter by the first
+// character of their sort name, case-insensitively, in the order SortName
+// lists them.
+func (s *Store) MangaInitials(ctx context.Context, filter MangaFilter) ([]InitialCount, error) {
+	where, args := mangaFilterClause(filter)
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT substr(m.sort_name, 1, 1) AS initial, COUNT(*)
+		FROM manga m
+		WHERE `+where+`
+		GROUP BY initial COLLATE NOCASE
+		ORDER BY initial COLLATE NOCASE ASC
+	`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("query manga initials: %w", err)
+	}
+	defer rows.Close()
+
+	counts := make([]InitialCount, 0)
+	for rows.Next() {
+		var count InitialCount
+		if err := rows.Scan(&count.Initial, &count.Count); err != nil {
+			return nil, fmt.Errorf("read manga initial row: %w", err)
+		}
+		counts = append(counts, count)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate manga initial rows: %w", err)
+	}
+	return counts, nil
+}
+
+// MangaSiblings returns the ids of the manga listed just before and after
+// the given one by ListManga with the same filter and sort; either is empty
+// at the ends of the listing. It returns sql.ErrNoRows when the manga is not
+// part of the listing.
+func (s *Store) MangaSiblings(ctx context.Context, id string, filter MangaFilter, sort string) (string, string, error) {
+	if sort == "" {
+		sort = SortUpdated
+	}
+	order, ok := mangaOrderClauses[sort]
+	if !ok {
+		return "", "", fmt.Errorf("unsupported manga sort %q", sort)
+	}
+
+	where, args := mangaFilterClause(filter)
+	var prev, next string
+	err := s.db.QueryRowContext(ctx, `
+		SELECT COALESCE(prev_id, ''), COALESCE(next_id, '')
+		FROM (
+			SELECT
+				m.id,
+				LAG(m.id) OVER (ORDER BY `+order+`) AS prev_id,
+				LEAD(m.id) OVER (ORDER BY `+order+`) AS next_id
+			FROM manga m
+			WHERE `+where+`
+		)
+		WHERE id = ?
+	`, append(args, id)...).Scan(&prev, &next)
+	return prev, next, err
+}
+
+// CollectionManga returns the manga of a user collection in the order they
+// were arranged in.
+func (s *Store) CollectionManga(ctx context.Context, collectionID string) ([]Manga, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT `+mangaColumns+mangaFrom+`
+		JOIN collection_item ci ON ci.manga_id = m.id
+		WHERE ci.collection_id = ?`+mangaGroupBy+`, ci.position
+		ORDER BY ci.position ASC`, collectionID)
+	if err != nil {
+		return nil, fmt.Errorf("query collection manga: %w", err)
+	}
+	defer rows.Close()
+
+	items := make([]Manga, 0)
+	for rows.Next() {
+		manga, err := scanManga(rows)
+		if err != nil {
+			return nil, fmt.Errorf("read manga row: %w", err)
+		}
+		items = append(items, manga)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate manga rows: %w", err)
+	}
+	return items, nil
+}
+
+// GetManga returns the manga with the given id, or sql.ErrNoRows.
+func (s *Store) GetManga(ctx context.Context, id string) (Manga, error) {
+	return scanManga(s.db.QueryRowContext(ctx, `SELECT `+mangaColumns+mangaFrom+` WHERE m.id = ?`+mangaGroupBy, id))
+}
+
+func mangaFilterClause(filter MangaFilter) (string, []any) {
+	clauses := make([]string, 0, 3)
+	args := make([]any, 0, len(filter.TagIDs)+4)
+
+	if filter.BookshelfID != "" {
+		clauses = append(clauses, "m.bookshelf_id = ?")
+		args = append(args, filter.BookshelfID)
+	}
+
+	if filter.Favorite {
+		clauses = append(clauses, "m.favorite = 1")
+	}
+
+	if filter.Collection != "" {
+		clauses = append(clauses, "m.collection = ?")
+		args = append(args, filter.Collection)
+	}
+
+	if filter.Query != "" {
+		clauses = append(clauses, `m.title_sort LIKE ? ESCAPE '\'`)
+		args = append(args, "%"+EscapeLike(strings.ToLower(filter.Query))+"%")
+	}
+
+	if len(filter.States) > 0 {
+		stateClauses := make([]string, 0, len(filter.States))
+		for _, state := range filter.States {
+			if clause, ok := readStateClause(state); ok {
+				stateClauses = append(stateClauses, "("+clause+")")
+			}
+		}
+		if len(stateClauses) > 0 {
+			clauses = append(clauses, "("+strings.Join(stateClauses, " OR ")+")")
+		}
+	}
+
+	if len(filter.TagIDs) > 0 {
+		clauses = append(clauses, fmt.Sprintf(`
+			m.id IN (
+				SELECT mt.manga_id
+				FROM manga_tag mt
+				WHERE mt.tag_id IN (%s)
+				GROUP BY mt.manga_id
+				HAVING COUNT(DISTINCT mt.tag_id) = ?
+			)
+		`, Placeholders(len(filter.TagIDs))))
+		args = append(args, Args(filter.TagIDs)...)
+		args = append(args, len(filter.TagIDs))
+	}
+
+	if len(clauses) == 0 {
+		return "1 = 1", args
+	}
+	return strings.Join(clauses, " AND "), args
+}
+
+// readStateClause returns the condition matching manga in the given reading
+// state: unread has no progress on any chapter, completed has every chapter
+// finished and reading is everything in between.
+func readStateClause(state string) (string, bool) {
+	const touched = `EXISTS (
+		SELECT 1 FROM reading_progress rp
+		JOIN chapter rc ON rc.id = rp.chapter_id
+		WHERE rc.manga_id = m.id
+	)`
+	const finished = `(
+		EXISTS (SELECT 1 FROM chapter fc WHERE fc.manga_id = m.id)
+		AND NOT EXISTS (
+			SELECT 1 FROM chapter fc
+			LEFT JOIN reading_progress fp ON fp.chapter_id = fc.id AND fp.completed = 1
+			WHERE fc.manga_id = m.id AND fp.chapter_id IS NULL
+		)
+	)`
+
+	switch state {
+	case ReadStateUnread:
+		return "NOT " + touched, true
+	case ReadStateReading:
+		return touched + " AND NOT " + finished, true
+	case ReadStateCompleted:
+		return finished, true
+	default:
+		return "", false
+	}
+}
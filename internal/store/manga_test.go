@@ -0,0 +1,183 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// seedFilterLibrary writes a library exercising every MangaFilter field:
+//
+//	m1 Alpha      shelf b1, favorite, collection Saga, tags x1 x2, completed
+//	m2 beta_x     shelf b1, tag x1, reading
+//	m3 Gamma 100% shelf b2, unread
+//	m4 Éclair     shelf b2, no chapters
+//	m5 zeta       shelf b2, favorite, collection Saga
+//	m6 Zulu       shelf b2
+func seedFilterLibrary(t *testing.T) *Store {
+	t.Helper()
+	s, database := newTestStore(t)
+	exec(t, database,
+		`INSERT INTO bookshelf(id, name, root_path) VALUES ('b1', 'One', '/one'), ('b2', 'Two', '/two')`,
+		`INSERT INTO manga(id, title, title_sort, sort_name, path, bookshelf_id, favorite, collection) VALUES
+			('m1', 'Alpha', 'alpha', 'Alpha', '/one/Alpha', 'b1', 1, 'Saga'),
+			('m2', 'beta_x', 'beta_x', 'beta_x', '/one/beta_x', 'b1', 0, ''),
+			('m3', 'Gamma 100%', 'gamma 100%', 'Gamma 100%', '/two/Gamma', 'b2', 0, ''),
+			('m4', 'Éclair', 'éclair', 'Éclair', '/two/Eclair', 'b2', 0, ''),
+			('m5', 'zeta', 'zeta', 'zeta', '/two/zeta', 'b2', 1, 'Saga'),
+			('m6', 'Zulu', 'zulu', 'Zulu', '/two/Zulu', 'b2', 0, '')`,
+		`INSERT INTO chapter(id, manga_id, title, path) VALUES
+			('c1a', 'm1', '1', '/one/Alpha/1'),
+			('c1b', 'm1', '2', '/one/Alpha/2'),
+			('c2a', 'm2', '1', '/one/beta_x/1'),
+			('c3a', 'm3', '1', '/two/Gamma/1'),
+			('c5a', 'm5', '1', '/two/zeta/1'),
+			('c6a', 'm6', '1', '/two/Zulu/1')`,
+		`INSERT INTO reading_progress(chapter_id, manga_id, page_index, completed) VALUES
+			('c1a', 'm1', 3, 1),
+			('c1b', 'm1', 5, 1),
+			('c2a', 'm2', 2, 0)`,
+		`INSERT INTO tag(id, name, slug) VALUES ('x1', 'X1', 'x1'), ('x2', 'X2', 'x2')`,
+		`INSERT INTO manga_tag(manga_id, tag_id) VALUES ('m1', 'x1'), ('m1', 'x2'), ('m2', 'x1')`,
+	)
+	return s
+}
+
+func TestListMangaFilters(t *testing.T) {
+	s := seedFilterLibrary(t)
+
+	tests := []struct {
+		name   string
+		filter MangaFilter
+		want   []string
+	}{
+		{name: "none", filter: MangaFilter{}, want: []string{"m1", "m2", "m3", "m5", "m6", "m4"}},
+		{name: "bookshelf", filter: MangaFilter{BookshelfID: "b1"}, want: []string{"m1", "m2"}},
+		{name: "favorite", filter: MangaFilter{Favorite: true}, want: []string{"m1", "m5"}},
+		{name: "collection", filter: MangaFilter{Collection: "Saga"}, want: []string{"m1", "m5"}},
+		{name: "query ignores case", filter: MangaFilter{Query: "ALP"}, want: []string{"m1"}},
+		{name: "query underscore is literal", filter: MangaFilter{Query: "_"}, want: []string{"m2"}},
+		{name: "query percent is literal", filter: MangaFilter{Query: "100%"}, want: []string{"m3"}},
+		{name: "query no match", filter: MangaFilter{Query: "omega"}, want: []string{}},
+		{name: "unread", filter: MangaFilter{States: []string{ReadStateUnread}}, want: []string{"m3", "m5", "m6", "m4"}},
+		{name: "reading", filter: MangaFilter{States: []string{ReadStateReading}}, want: []string{"m2"}},
+		{name: "completed", filter: MangaFilter{States: []string{ReadStateCompleted}}, want: []string{"m1"}},
+		{name: "any of several states", filter: MangaFilter{States: []string{ReadStateReading, ReadStateCompleted}}, want: []string{"m1", "m2"}},
+		{name: "unknown state ignored", filter: MangaFilter{States: []string{"shelved"}}, want: []string{"m1", "m2", "m3", "m5", "m6", "m4"}},
+		{name: "one tag", filter: MangaFilter{TagIDs: []string{"x1"}}, want: []string{"m1", "m2"}},
+		{name: "all tags required", filter: MangaFilter{TagIDs: []string{"x1", "x2"}}, want: []string{"m1"}},
+		{name: "combined", filter: MangaFilter{BookshelfID: "b2", Favorite: true, Collection: "Saga"}, want: []string{"m5"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			items, total, err := s.ListManga(context.Background(), ListMangaOptions{Filter: tt.filter, Sort: SortName, Limit: 100})
+			if err != nil {
+				t.Fatalf("ListManga: %v", err)
+			}
+			ids := make([]string, 0, len(items))
+			for _, item := range items {
+				ids = append(ids, item.ID)
+			}
+			if !reflect.DeepEqual(ids, tt.want) {
+				t.Errorf("ids = %v, want %v", ids, tt.want)
+			}
+			if total != len(tt.want) {
+				t.Errorf("total = %d, want %d", total, len(tt.want))
+			}
+		})
+	}
+}
+
+func TestMangaInitials(t *testing.T) {
+	s := seedFilterLibrary(t)
+
+	tests := []struct {
+		name   string
+		filter MangaFilter
+		want   []InitialCount
+	}{
+		{
+			name:   "whole library, case folded",
+			filter: MangaFilter{},
+			want:   []InitialCount{{"a", 1}, {"b", 1}, {"g", 1}, {"z", 2}, {"é", 1}},
+		},
+		{
+			name:   "filtered",
+			filter: MangaFilter{BookshelfID: "b2"},
+			want:   []InitialCount{{"g", 1}, {"z", 2}, {"é", 1}},
+		},
+		{
+			name:   "favorites",
+			filter: MangaFilter{Favorite: true},
+			want:   []InitialCount{{"a", 1}, {"z", 1}},
+		},
+		{
+			name:   "nothing matches",
+			filter: MangaFilter{Query: "omega"},
+			want:   []InitialCount{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			counts, err := s.MangaInitials(context.Background(), tt.filter)
+			if err != nil {
+				t.Fatalf("MangaInitials: %v", err)
+			}
+			for i := range counts {
+				counts[i].Initial = strings.ToLower(counts[i].Initial)
+			}
+			if !reflect.DeepEqual(counts, tt.want) {
+				t.Errorf("initials = %v, want %v", counts, tt.want)
+			}
+		})
+	}
+}
+
+func TestListMangaPagesThroughTies(t *testing.T) {
+	s, database := newTestStore(t)
+	// A bulk import: every manga shares its timestamps, title, sort name
+	// and collection, leaving only the id to order them by.
+	const count = 40
+	for i := 0; i < count; i++ {
+		if _, err := database.Exec(`
+			INSERT INTO manga(id, title, title_sort, sort_name, collection, path, updated_at, content_updated_at)
+			VALUES(?, 'Same', 'same', 'Same', 'Box', ?, '2026-01-02 03:04:05', '2026-01-02 03:04:05')
+		`, fmt.Sprintf("m%02d", (i*17)%count), fmt.Sprintf("/lib/%02d", i)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, sort := range []string{SortUpdated, SortName, SortCollection} {
+		t.Run(sort, func(t *testing.T) {
+			for _, limit := range []int{1, 7, 10, 39} {
+				seen := make(map[string]bool, count)
+				ids := make([]string, 0, count)
+				for offset := 0; offset < count; offset += limit {
+					items, total, err := s.ListManga(context.Background(), ListMangaOptions{Sort: sort, Limit: limit, Offset: offset})
+					if err != nil {
+						t.Fatalf("ListManga: %v", err)
+					}
+					if total != count {
+						t.Fatalf("total = %d, want %d", total, count)
+					}
+					for _, item := range items {
+						if seen[item.ID] {
+							t.Fatalf("limit %d: %s listed twice, at offset %d", limit, item.ID, offset)
+						}
+						seen[item.ID] = true
+						ids = append(ids, item.ID)
+					}
+				}
+				if len(ids) != count {
+					t.Fatalf("limit %d: paged through %d manga, want %d", limit, len(ids), count)
+				}
+				if !slices.IsSorted(ids) {
+					t.Fatalf("limit %d: tied manga not in id order: %v", limit, ids)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,63 @@
+// Package store holds the typed queries shared by the HTTP handlers, so the
+// same library reads are not rebuilt as inline SQL in every caller.
+package store
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+)
+
+// Counter runs count queries, typically caching their results until the
+// library changes.
+type Counter interface {
+	Count(ctx context.Context, db *sql.DB, query string, args ...any) (int, error)
+}
+
+type Store struct {
+	db     *sql.DB
+	counts Counter
+}
+
+// New returns a store reading from db. counts may be nil, in which case
+// count queries run on every call.
+func New(db *sql.DB, counts Counter) *Store {
+	return &Store{db: db, counts: counts}
+}
+
+func (s *Store) count(ctx context.Context, query string, args ...any) (int, error) {
+	if s.counts != nil {
+		return s.counts.Count(ctx, s.db, query, args...)
+	}
+	var total int
+	err := s.db.QueryRowContext(ctx, query, args...).Scan(&total)
+	return total, err
+}
+
+// EscapeLike escapes LIKE wildcards so value matches literally in a
+// pattern declared with ESCAPE '\'.
+func EscapeLike(value string) string {
+	replacer := strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`)
+	return replacer.Replace(value)
+}
+
+// Placeholders returns count comma-separated bind markers for an IN list.
+func Placeholders(count int) string {
+	if count <= 0 {
+		return ""
+	}
+	items := make([]string, count)
+	for i := range items {
+		items[i] = "?"
+	}
+	return strings.Join(items, ",")
+}
+
+// Args converts values to query arguments.
+func Args(values []string) []any {
+	items := make([]any, 0, len(values))
+	for _, value := range values {
+		items = append(items, value)
+	}
+	return items
+}
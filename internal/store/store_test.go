@@ -0,0 +1,34 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"io"
+	"log/slog"
+	"path/filepath"
+	"testing"
+
+	"mynewmangaui/internal/db"
+)
+
+// newTestStore returns a store over a fresh migrated database.
+func newTestStore(t *testing.T) (*Store, *sql.DB) {
+	t.Helper()
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	database, err := db.OpenAndMigrate(context.Background(), filepath.Join(t.TempDir(), "app.db"), nil, nil, logger)
+	if err != nil {
+		t.Fatalf("open database: %v", err)
+	}
+	t.Cleanup(func() { database.Close() })
+	return New(database, nil), database
+}
+
+// exec runs each statement and fails the test on the first error.
+func exec(t *testing.T, database *sql.DB, statements ...string) {
+	t.Helper()
+	for _, statement := range statements {
+		if _, err := database.Exec(statement); err != nil {
+			t.Fatalf("exec %q: %v", statement, err)
+		}
+	}
+}